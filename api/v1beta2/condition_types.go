@@ -21,10 +21,62 @@ const (
 	// health assessment result.
 	HealthyCondition string = "Healthy"
 
+	// AttestationVerifiedCondition represents the last recorded
+	// attestation verification result of the source artifact.
+	AttestationVerifiedCondition string = "AttestationVerified"
+
+	// AttestationVerificationFailedReason represents the fact that the
+	// attestations of the source artifact don't satisfy the policy.
+	AttestationVerificationFailedReason string = "AttestationVerificationFailed"
+
+	// AttestationVerificationSucceededReason represents the fact that an
+	// attestation of the source artifact satisfies the policy.
+	AttestationVerificationSucceededReason string = "AttestationVerificationSucceeded"
+
+	// PromotionPendingCondition represents the fact that the source revision
+	// of the Kustomization is waiting to be promoted by a Pipeline.
+	PromotionPendingCondition string = "PromotionPending"
+
+	// SourceStaleCondition represents the fact that the source
+	// has not produced a new artifact for longer than the stale threshold.
+	SourceStaleCondition string = "SourceStale"
+
+	// StaleArtifactReason represents the fact that the source
+	// is failing to update its artifact.
+	StaleArtifactReason string = "StaleArtifact"
+
 	// PruneFailedReason represents the fact that the
 	// pruning of the Kustomization failed.
 	PruneFailedReason string = "PruneFailed"
 
+	// PruneApprovalRequiredReason represents the fact that the
+	// garbage collection is waiting for approval.
+	PruneApprovalRequiredReason string = "PruneApprovalRequired"
+
+	// FirstApplyApprovalRequiredReason represents the fact that the
+	// first apply is waiting for the review to be approved.
+	FirstApplyApprovalRequiredReason string = "FirstApplyApprovalRequired"
+
+	// PruneBudgetExceededReason represents the fact that the
+	// garbage collection exceeds the disruption budget.
+	PruneBudgetExceededReason string = "PruneBudgetExceeded"
+
+	// PodDisruptionBudgetViolatedReason represents the fact that the
+	// garbage collection would violate a PodDisruptionBudget.
+	PodDisruptionBudgetViolatedReason string = "PodDisruptionBudgetViolated"
+
+	// PruneHookFailedReason represents the fact that a prune hook
+	// failed for some of the objects to be garbage collected.
+	PruneHookFailedReason string = "PruneHookFailed"
+
+	// QuotaExceededReason represents the fact that the
+	// objects exceed the quota set for Kustomizations.
+	QuotaExceededReason string = "QuotaExceeded"
+
+	// OutputLimitExceededReason represents the fact that the build
+	// output exceeds the size or object count limits.
+	OutputLimitExceededReason string = "OutputLimitExceeded"
+
 	// ArtifactFailedReason represents the fact that the
 	// source artifact download failed.
 	ArtifactFailedReason string = "ArtifactFailed"
@@ -33,19 +85,52 @@ const (
 	// kustomize build failed.
 	BuildFailedReason string = "BuildFailed"
 
+	// InvalidPatchReason represents the fact that the
+	// patches of the Kustomization spec can't be parsed.
+	InvalidPatchReason string = "InvalidPatch"
+
+	// ImageDigestRequiredReason represents the fact that
+	// some container images are not pinned to a digest.
+	ImageDigestRequiredReason string = "ImageDigestRequired"
+
+	// NotYetInstalledReason represents the fact that the apply failed
+	// because the CustomResourceDefinition of a kind is not registered yet.
+	NotYetInstalledReason string = "NotYetInstalled"
+
 	// HealthCheckFailedReason represents the fact that
 	// one of the health checks failed.
 	HealthCheckFailedReason string = "HealthCheckFailed"
 
+	// VerificationFailedReason represents the fact that
+	// one of the verification Jobs failed.
+	VerificationFailedReason string = "VerificationFailed"
+
+	// ClusterVersionMismatchReason represents the fact that the Kubernetes
+	// version of the target cluster doesn't satisfy spec.targetClusterVersion.
+	ClusterVersionMismatchReason string = "ClusterVersionMismatch"
+
 	// DependencyNotReadyReason represents the fact that
 	// one of the dependencies is not ready.
 	DependencyNotReadyReason string = "DependencyNotReady"
 
+	// KustomizationNotFoundReason represents the fact that a Kustomization
+	// referenced by a KustomizationGroup or a Pipeline does not exist.
+	KustomizationNotFoundReason string = "KustomizationNotFound"
+
 	// ReconciliationSucceededReason represents the fact that
 	// the reconciliation succeeded.
 	ReconciliationSucceededReason string = "ReconciliationSucceeded"
 
+	// ReconciliationPanicReason represents the fact that
+	// the reconciliation was aborted by a recovered panic.
+	ReconciliationPanicReason string = "ReconciliationPanic"
+
 	// ReconciliationFailedReason represents the fact that
 	// the reconciliation failed.
 	ReconciliationFailedReason string = "ReconciliationFailed"
+
+	// PromotionPendingReason represents the fact that the promotion
+	// of a revision to a Pipeline stage is waiting for approval, or that
+	// the source revision of a Kustomization is waiting to be promoted.
+	PromotionPendingReason string = "PromotionPending"
 )
@@ -16,6 +16,10 @@ limitations under the License.
 
 package v1beta2
 
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
 // ResourceInventory contains a list of Kubernetes resource object references that have been applied by a Kustomization.
 type ResourceInventory struct {
 	// Entries of Kubernetes resource object references.
@@ -30,4 +34,110 @@ type ResourceRef struct {
 
 	// Version is the API version of the Kubernetes resource object's kind.
 	Version string `json:"v"`
+
+	// Retain is set on the objects that are orphaned instead of being deleted
+	// when they are no longer managed, e.g. the namespace of a Tenant.
+	// +optional
+	Retain bool `json:"retain,omitempty"`
+
+	// Images are the container images of the workload applied in the last
+	// reconciliation, in the format 'name[:tag][@digest]'.
+	// +optional
+	Images []string `json:"images,omitempty"`
+}
+
+// PendingPrune contains a list of Kubernetes resource object references that
+// are subject to garbage collection and are waiting for approval.
+type PendingPrune struct {
+	// Digest of the pending deletions, in the format '<algo>:<checksum>'.
+	// +required
+	Digest string `json:"digest"`
+
+	// Revision is the source revision at which the deletions were detected.
+	// +optional
+	Revision string `json:"revision,omitempty"`
+
+	// Entries of Kubernetes resource object references, truncated
+	// to the first 100 objects in the order of their IDs.
+	Entries []ResourceRef `json:"entries"`
+
+	// Total is the number of objects waiting for approval.
+	// +optional
+	Total int `json:"total,omitempty"`
+}
+
+// PruneReport contains a list of Kubernetes resource object references
+// that garbage collection would delete.
+type PruneReport struct {
+	// Revision is the source revision at which the objects were reported.
+	// +optional
+	Revision string `json:"revision,omitempty"`
+
+	// Entries of Kubernetes resource object references, truncated
+	// to the first 100 objects in the order of their IDs.
+	Entries []ResourceRef `json:"entries"`
+
+	// Total is the number of objects that garbage collection would delete.
+	Total int `json:"total"`
+}
+
+// FirstApplyReview contains the changes that the first apply of a
+// Kustomization would make to the cluster, waiting for approval.
+type FirstApplyReview struct {
+	// Digest of the reviewed changes, in the format '<algo>:<checksum>'.
+	// +required
+	Digest string `json:"digest"`
+
+	// Revision is the source revision at which the changes were computed.
+	// +optional
+	Revision string `json:"revision,omitempty"`
+
+	// Entries of the objects that would be created or configured, truncated
+	// to the first 100 objects in the order of their IDs.
+	Entries []ObjectChange `json:"entries"`
+
+	// Created is the number of objects that would be created.
+	// +optional
+	Created int `json:"created,omitempty"`
+
+	// Configured is the number of existing objects that would be configured.
+	// +optional
+	Configured int `json:"configured,omitempty"`
+
+	// Unchanged is the number of existing objects that are already up-to-date.
+	// +optional
+	Unchanged int `json:"unchanged,omitempty"`
+}
+
+// ObjectChange contains the change that the server-side apply
+// would make to a Kubernetes resource object.
+type ObjectChange struct {
+	// ID is the string representation of the Kubernetes resource object's metadata,
+	// in the format '<namespace>_<name>_<group>_<kind>'.
+	ID string `json:"id"`
+
+	// Action is the server-side apply action, 'created' or 'configured'.
+	Action string `json:"action"`
+}
+
+// ObjectPolicy contains the list of Kubernetes resource object references
+// that carry the same reconcile policy.
+type ObjectPolicy struct {
+	// Policy is the name of the policy, one of 'Skip', 'DryRun',
+	// 'Force' or 'PruneDisabled'.
+	// +required
+	Policy string `json:"policy"`
+
+	// Entries of Kubernetes resource object references.
+	Entries []ResourceRef `json:"entries"`
+}
+
+// ApplyDuration contains the duration of the server-side apply of a Kubernetes resource object.
+type ApplyDuration struct {
+	// ID is the string representation of the Kubernetes resource object's metadata,
+	// in the format '<namespace>_<name>_<group>_<kind>'.
+	ID string `json:"id"`
+
+	// Duration is the time it took to apply the object.
+	Duration metav1.Duration `json:"duration"`
 }
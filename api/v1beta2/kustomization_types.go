@@ -24,6 +24,7 @@ import (
 	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
 	apimeta "k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
 )
 
 const (
@@ -31,7 +32,147 @@ const (
 	KustomizationFinalizer    = "finalizers.fluxcd.io"
 	MaxConditionMessageLength = 20000
 	DisabledValue             = "disabled"
+	EnabledValue              = "enabled"
 	MergeValue                = "merge"
+
+	// PruneApprovalAnnotation is the annotation used to approve the
+	// garbage collection of the objects listed in the PendingPrune status.
+	PruneApprovalAnnotation = "kustomize.toolkit.fluxcd.io/prune-approval"
+
+	// FirstApplyApprovalAnnotation is the annotation used to approve the
+	// first apply of the changes listed in the FirstApplyReview status.
+	FirstApplyApprovalAnnotation = "kustomize.toolkit.fluxcd.io/first-apply-approval"
+
+	// FirstReconcileApply and FirstReconcileObserve are the behaviours
+	// of the first apply of a Kustomization.
+	FirstReconcileApply   = "apply"
+	FirstReconcileObserve = "observe"
+
+	// LegacySortOrder and FIFOSortOrder are the sort orders of the build output.
+	LegacySortOrder = "legacy"
+	FIFOSortOrder   = "fifo"
+
+	// StatusSummaryAnnotation is the annotation used to publish the
+	// StatusSummary of a Kustomization in JSON format.
+	StatusSummaryAnnotation = "kustomize.toolkit.fluxcd.io/status-summary"
+
+	// StatusSummaryVersion is the version of the StatusSummary format.
+	StatusSummaryVersion = "v1"
+
+	// MaxLastAttemptedFailures is the maximum number of objects
+	// recorded in the LastAttemptedFailures status.
+	MaxLastAttemptedFailures = 50
+
+	// MaxStatusWarnings is the maximum number of warnings recorded in the
+	// Warnings status, including the truncation marker.
+	MaxStatusWarnings = 50
+
+	// MaxStatusSize is the size in bytes of the serialized status above which
+	// the informational status fields are cleared, to keep the Kustomization
+	// well below the etcd object size limit.
+	MaxStatusSize = 256 * 1024
+
+	// SplitPrunePolicy spreads the garbage collection across reconciliations.
+	SplitPrunePolicy = "Split"
+
+	// BlockPrunePolicy blocks the garbage collection.
+	BlockPrunePolicy = "Block"
+
+	// WarnPodDisruptionPolicy reports the deletions that violate
+	// a PodDisruptionBudget as a warning event.
+	WarnPodDisruptionPolicy = "Warn"
+
+	// BlockPodDisruptionPolicy blocks the garbage collection when
+	// a deletion violates a PodDisruptionBudget.
+	BlockPodDisruptionPolicy = "Block"
+
+	// FailPruneHookPolicy keeps the objects whose prune hook failed,
+	// and fails the reconciliation.
+	FailPruneHookPolicy = "Fail"
+
+	// IgnorePruneHookPolicy deletes the objects whose prune hook failed.
+	IgnorePruneHookPolicy = "Ignore"
+
+	// HTTPEndpointHealthCheck probes an endpoint with an HTTP GET request.
+	HTTPEndpointHealthCheck = "HTTP"
+
+	// TCPEndpointHealthCheck probes an endpoint by opening a TCP connection.
+	TCPEndpointHealthCheck = "TCP"
+
+	// WarnBuildPolicy reports the build issues, e.g. unknown fields
+	// in a kustomization.yaml, as a warning event.
+	WarnBuildPolicy = "Warn"
+
+	// ErrorBuildPolicy fails the build on issues, e.g. unknown fields
+	// in a kustomization.yaml.
+	ErrorBuildPolicy = "Error"
+
+	// KeepFirstBuildPolicy and KeepLastBuildPolicy resolve the duplicate
+	// resource IDs of the build by keeping the first or the last declaration.
+	KeepFirstBuildPolicy = "KeepFirst"
+	KeepLastBuildPolicy  = "KeepLast"
+
+	// OriginAnnotationsBuildMetadata annotates the objects with the
+	// source of the resource they were built from.
+	OriginAnnotationsBuildMetadata = "originAnnotations"
+
+	// TransformerAnnotationsBuildMetadata annotates the objects with the
+	// transformers and patches that modified them.
+	TransformerAnnotationsBuildMetadata = "transformerAnnotations"
+
+	// ManagedByLabelBuildMetadata labels the objects with the
+	// version of kustomize that built them.
+	ManagedByLabelBuildMetadata = "managedByLabel"
+
+	// DefaultOpenAPISchemaKey is the default key of the ConfigMap
+	// entry containing the OpenAPI schema.
+	DefaultOpenAPISchemaKey = "schema.json"
+
+	// SkipObjectPolicy is set on objects labeled or annotated with
+	// 'kustomize.toolkit.fluxcd.io/reconcile: disabled'.
+	SkipObjectPolicy = "Skip"
+
+	// DryRunObjectPolicy is set on objects labeled or annotated with
+	// 'kustomize.toolkit.fluxcd.io/dry-run: enabled'.
+	DryRunObjectPolicy = "DryRun"
+
+	// ForceObjectPolicy is set on objects labeled or annotated with
+	// 'kustomize.toolkit.fluxcd.io/force: enabled'.
+	ForceObjectPolicy = "Force"
+
+	// PruneDisabledObjectPolicy is set on objects labeled or annotated with
+	// 'kustomize.toolkit.fluxcd.io/prune: disabled'.
+	PruneDisabledObjectPolicy = "PruneDisabled"
+
+	// MigrateInventoryAnnotation is the annotation used to transfer the inventory
+	// of another Kustomization, referenced as '<namespace>/<name>', to a new one.
+	MigrateInventoryAnnotation = "kustomize.toolkit.fluxcd.io/migrate-inventory-from"
+
+	// ApplyStageAnnotation is the annotation used to assign an object
+	// to one of the stages listed in spec.applyOptions.stages.
+	ApplyStageAnnotation = "kustomize.toolkit.fluxcd.io/apply-stage"
+
+	// VerificationAnnotation is the annotation used to mark a Job as a
+	// verification Job, run after the health checks instead of being applied.
+	VerificationAnnotation = "kustomize.toolkit.fluxcd.io/verification"
+
+	// BulkSuspendAnnotation is the annotation set on a Namespace to a label
+	// selector, the Kustomizations of the namespace matching it are not
+	// reconciled while the annotation is present.
+	BulkSuspendAnnotation = "kustomize.toolkit.fluxcd.io/bulk-suspend"
+
+	// BulkSuspendReasonAnnotation is the annotation set on a Namespace
+	// with the reason of the bulk suspend, e.g. an incident reference.
+	BulkSuspendReasonAnnotation = "kustomize.toolkit.fluxcd.io/bulk-suspend-reason"
+
+	// ExternalSecretsAnnotation is the annotation set on a Namespace to the
+	// comma-separated list of the external secrets the Kustomizations of the
+	// namespace may read, as '<provider>:<name prefix>' entries.
+	ExternalSecretsAnnotation = "kustomize.toolkit.fluxcd.io/external-secrets"
+
+	// ClusterLabel is the label used to name the cluster targeted by a
+	// Kustomization in the per-cluster metrics.
+	ClusterLabel = "kustomize.toolkit.fluxcd.io/cluster"
 )
 
 // KustomizationSpec defines the configuration to calculate the desired state from a Source using Kustomize.
@@ -56,6 +197,19 @@ type KustomizationSpec struct {
 	// +optional
 	RetryInterval *metav1.Duration `json:"retryInterval,omitempty"`
 
+	// The interval at which to retry a reconciliation that failed because the
+	// health checks did not pass yet, e.g. while a rollout is in progress.
+	// When not specified, the controller uses the RetryInterval value.
+	// +optional
+	ProgressingInterval *metav1.Duration `json:"progressingInterval,omitempty"`
+
+	// RevisionSkewThreshold is the maximum duration the last applied revision
+	// can differ from the latest revision of the source, before the Kustomization
+	// is flagged as behind its source in status.revisionSkew.
+	// When not specified, the revision skew is not tracked.
+	// +optional
+	RevisionSkewThreshold *metav1.Duration `json:"revisionSkewThreshold,omitempty"`
+
 	// The KubeConfig for reconciling the Kustomization on a remote cluster.
 	// When used in combination with KustomizationSpec.ServiceAccountName,
 	// forces the controller to act on behalf of that Service Account at the
@@ -81,15 +235,29 @@ type KustomizationSpec struct {
 	// +required
 	Prune bool `json:"prune"`
 
+	// PruneOptions holds the options for garbage collection.
+	// +optional
+	PruneOptions *PruneOptions `json:"pruneOptions,omitempty"`
+
 	// A list of resources to be included in the health assessment.
 	// +optional
 	HealthChecks []meta.NamespacedObjectKindReference `json:"healthChecks,omitempty"`
 
+	// EndpointHealthChecks is a list of HTTP and TCP endpoints probed after
+	// the health assessment of the resources, e.g. the health endpoint of an
+	// application exposed by an Ingress.
+	// +optional
+	EndpointHealthChecks []EndpointHealthCheck `json:"endpointHealthChecks,omitempty"`
+
 	// Strategic merge and JSON patches, defined as inline YAML objects,
 	// capable of targeting objects based on kind, label and annotation selectors.
 	// +optional
 	Patches []kustomize.Patch `json:"patches,omitempty"`
 
+	// PatchOptions holds the kustomize options of the patches of spec.patches.
+	// +optional
+	PatchOptions *PatchOptions `json:"patchOptions,omitempty"`
+
 	// Strategic merge patches, defined as inline YAML objects.
 	// Deprecated: Use Patches instead.
 	// +optional
@@ -106,6 +274,24 @@ type KustomizationSpec struct {
 	// +optional
 	Images []kustomize.Image `json:"images,omitempty"`
 
+	// ImagePolicies is a list of (image name, ImagePolicy reference) for setting the
+	// new name, tag and digest of the images to the latest image of the policy at
+	// build time. They override the entries of spec.images with the same name.
+	// +optional
+	ImagePolicies []ImagePolicyOverride `json:"imagePolicies,omitempty"`
+
+	// ConfigMapGenerator is a list of ConfigMaps generated from literals, env files
+	// and files of the source artifact, merged into the configMapGenerator of the
+	// kustomization.yaml.
+	// +optional
+	ConfigMapGenerator []GeneratorArgs `json:"configMapGenerator,omitempty"`
+
+	// SecretGenerator is a list of Secrets generated from literals, env files
+	// and files of the source artifact, merged into the secretGenerator of the
+	// kustomization.yaml.
+	// +optional
+	SecretGenerator []SecretGeneratorArgs `json:"secretGenerator,omitempty"`
+
 	// The name of the Kubernetes service account to impersonate
 	// when reconciling this Kustomization.
 	// +optional
@@ -115,11 +301,43 @@ type KustomizationSpec struct {
 	// +required
 	SourceRef CrossNamespaceSourceReference `json:"sourceRef"`
 
+	// Sources may contain a list of additional sources whose artifacts are
+	// extracted at the given paths inside the build root, so that overlays
+	// can reference bases published in other repositories.
+	// +optional
+	Sources []SourceMount `json:"sources,omitempty"`
+
+	// ArtifactFilter defines which files of the SourceRef artifact are
+	// extracted before building the kustomization.
+	// +optional
+	ArtifactFilter *ArtifactFilter `json:"artifactFilter,omitempty"`
+
+	// Attestation defines the policy that the SLSA provenance attestations
+	// of the OCIRepository artifact must satisfy before the artifact is applied.
+	// +optional
+	Attestation *AttestationPolicy `json:"attestation,omitempty"`
+
+	// BuildOptions holds the options for building the kustomization.
+	// +optional
+	BuildOptions *BuildOptions `json:"buildOptions,omitempty"`
+
 	// This flag tells the controller to suspend subsequent kustomize executions,
 	// it does not apply to already started executions. Defaults to false.
 	// +optional
 	Suspend bool `json:"suspend,omitempty"`
 
+	// TakeoverFrom may contain a meta.NamespacedObjectReference slice with
+	// references to Kustomization resources from which this Kustomization can
+	// take over the ownership of objects present in their inventory.
+	// +optional
+	TakeoverFrom []meta.NamespacedObjectReference `json:"takeoverFrom,omitempty"`
+
+	// TargetClusterVersion is a semver range, e.g. '>=1.27.0 <1.30.0', that the
+	// Kubernetes version of the target cluster must satisfy. When the version is
+	// out of range, the reconciliation is held and nothing is applied.
+	// +optional
+	TargetClusterVersion string `json:"targetClusterVersion,omitempty"`
+
 	// TargetNamespace sets or overrides the namespace in the
 	// kustomization.yaml file.
 	// +kubebuilder:validation:MinLength=1
@@ -128,6 +346,12 @@ type KustomizationSpec struct {
 	// +optional
 	TargetNamespace string `json:"targetNamespace,omitempty"`
 
+	// TargetNamespaceExemptions selects the objects that keep their own namespace
+	// when TargetNamespace is set, e.g. the cluster-scoped custom resources or the
+	// patches of objects in a fixed namespace.
+	// +optional
+	TargetNamespaceExemptions []kustomize.Selector `json:"targetNamespaceExemptions,omitempty"`
+
 	// Timeout for validation, apply and health checking operations.
 	// Defaults to 'Interval' duration.
 	// +optional
@@ -139,17 +363,67 @@ type KustomizationSpec struct {
 	// +optional
 	Force bool `json:"force,omitempty"`
 
+	// FirstReconcile sets the behaviour of the first apply of the Kustomization.
+	// With 'observe', the controller performs a server-side dry-run of the
+	// resources and waits for the review to be approved before applying them.
+	// Defaults to 'apply'.
+	// +kubebuilder:validation:Enum=apply;observe
+	// +optional
+	FirstReconcile string `json:"firstReconcile,omitempty"`
+
+	// ApplyOptions holds the options for the server-side apply.
+	// +optional
+	ApplyOptions *ApplyOptions `json:"applyOptions,omitempty"`
+
+	// OwnerLabels configures the labels set on the applied objects to
+	// record their ownership. Defaults to 'kustomize.toolkit.fluxcd.io/name'
+	// and 'kustomize.toolkit.fluxcd.io/namespace'.
+	// +optional
+	OwnerLabels *OwnerLabels `json:"ownerLabels,omitempty"`
+
 	// Wait instructs the controller to check the health of all the reconciled resources.
 	// When enabled, the HealthChecks are ignored. Defaults to false.
 	// +optional
 	Wait bool `json:"wait,omitempty"`
 
+	// WaitOptions holds the options of the health assessment enabled with Wait.
+	// +optional
+	WaitOptions *WaitOptions `json:"waitOptions,omitempty"`
+
+	// HealthCheckOptions holds the options of the health assessment.
+	// +optional
+	HealthCheckOptions *HealthCheckOptions `json:"healthCheckOptions,omitempty"`
+
 	// Deprecated: Not used in v1beta2.
 	// +kubebuilder:validation:Enum=none;client;server
 	// +optional
 	Validation string `json:"validation,omitempty"`
 }
 
+// HealthCheckOptions defines how the health of the reconciled resources is assessed.
+type HealthCheckOptions struct {
+	// StableFor is the duration the health checks must keep passing, after they
+	// passed for the first time, before the Kustomization is marked as healthy.
+	// The checks are repeated during this window, which is not part of the Timeout.
+	// +optional
+	StableFor *metav1.Duration `json:"stableFor,omitempty"`
+
+	// ReadyWhenPaused instructs the controller to consider the workloads that are
+	// explicitly paused, such as Deployments with spec.paused and CronJobs with
+	// spec.suspend set to true, as healthy instead of waiting for their rollout.
+	// +optional
+	ReadyWhenPaused bool `json:"readyWhenPaused,omitempty"`
+}
+
+// WaitOptions defines which of the reconciled resources are health checked.
+type WaitOptions struct {
+	// Selectors is a list of selectors matching the resources that are health
+	// checked when Wait is enabled. The other resources are applied without
+	// waiting for them to become ready. Defaults to all the reconciled resources.
+	// +optional
+	Selectors []kustomize.Selector `json:"selectors,omitempty"`
+}
+
 // Decryption defines how decryption is handled for Kubernetes manifests.
 type Decryption struct {
 	// Provider is the name of the decryption engine.
@@ -162,6 +436,32 @@ type Decryption struct {
 	SecretRef *meta.LocalObjectReference `json:"secretRef,omitempty"`
 }
 
+// AttestationPolicy defines how the cosign attestations attached to an OCI artifact
+// are verified. The attestations must be signed with the cosign public key of the
+// referenced Secret, and carry an in-toto statement with a SLSA provenance predicate
+// whose subject matches the artifact digest.
+type AttestationPolicy struct {
+	// SecretRef holds the name of a Secret in the same namespace as the
+	// Kustomization, that contains the PEM-encoded cosign public key
+	// under the 'cosign.pub' key.
+	// +required
+	SecretRef meta.LocalObjectReference `json:"secretRef"`
+
+	// BuilderID is the expected builder.id of the SLSA provenance predicate.
+	// +optional
+	BuilderID string `json:"builderID,omitempty"`
+
+	// Repository is the expected repository of the invocation.configSource.uri
+	// of the SLSA provenance predicate, e.g. 'https://github.com/org/repo'.
+	// +optional
+	Repository string `json:"repository,omitempty"`
+
+	// Branch is the expected branch of the invocation.configSource.uri
+	// of the SLSA provenance predicate, e.g. 'main'.
+	// +optional
+	Branch string `json:"branch,omitempty"`
+}
+
 // KubeConfig references a Kubernetes secret that contains a kubeconfig file.
 type KubeConfig struct {
 	// SecretRef holds the name of a secret that contains a key with
@@ -175,6 +475,496 @@ type KubeConfig struct {
 	// the Kustomization.
 	// +required
 	SecretRef meta.SecretKeyReference `json:"secretRef,omitempty"`
+
+	// ServiceAccountToken configures the controller to authenticate to the
+	// API server with a token of a service account of the local cluster,
+	// issued for the given audiences, instead of the kubeconfig credentials.
+	// Meant for the remote clusters that are only reachable through an
+	// identity-aware proxy trusting the local cluster as identity provider.
+	// +optional
+	ServiceAccountToken *ServiceAccountToken `json:"serviceAccountToken,omitempty"`
+
+	// Headers holds the extra HTTP headers sent with the requests to the
+	// API server, e.g. the headers expected by an authenticating proxy.
+	// +optional
+	Headers map[string]string `json:"headers,omitempty"`
+
+	// HeadersSecretRef holds the name of a secret in the same namespace as the
+	// Kustomization, whose key/value pairs are sent as extra HTTP headers with
+	// the requests to the API server, overriding the ones of Headers.
+	// +optional
+	HeadersSecretRef *meta.LocalObjectReference `json:"headersSecretRef,omitempty"`
+}
+
+// ServiceAccountToken describes the service account token used to authenticate
+// to the API server of a kubeconfig.
+type ServiceAccountToken struct {
+	// Name of the service account, in the same namespace as the Kustomization.
+	// +required
+	Name string `json:"name"`
+
+	// Audiences of the token, e.g. the audience expected by the proxy.
+	// +kubebuilder:validation:MinItems=1
+	// +required
+	Audiences []string `json:"audiences"`
+
+	// ExpirationSeconds is the requested validity of the token,
+	// a new token is requested at each reconciliation. Defaults to 3600.
+	// +kubebuilder:validation:Minimum=600
+	// +optional
+	ExpirationSeconds *int64 `json:"expirationSeconds,omitempty"`
+}
+
+// ArtifactFilter defines the glob patterns matching the files to extract
+// from the source artifact. Patterns without a slash match any path element,
+// e.g. '*.md', while patterns with a slash match the path from the root of
+// the artifact, e.g. 'docs/*'.
+type ArtifactFilter struct {
+	// Include is a list of glob patterns, when specified only
+	// the matching files are extracted.
+	// +optional
+	Include []string `json:"include,omitempty"`
+
+	// Exclude is a list of glob patterns, the matching
+	// files are not extracted.
+	// +optional
+	Exclude []string `json:"exclude,omitempty"`
+}
+
+// BuildOptions defines how the kustomization is built.
+type BuildOptions struct {
+	// ManifestExtensions is the list of file extensions of the Kubernetes manifests
+	// included when generating the kustomization.yaml.
+	// Defaults to '.yaml', '.yml' and '.json'.
+	// +optional
+	ManifestExtensions []string `json:"manifestExtensions,omitempty"`
+
+	// RequireKustomizationFile makes the build fail if the path does not contain
+	// a kustomization.yaml, instead of generating one from the manifests found
+	// under that path. Defaults to false.
+	// +optional
+	RequireKustomizationFile bool `json:"requireKustomizationFile,omitempty"`
+
+	// UnknownFields defines how the unknown or misspelled fields of an existing
+	// kustomization.yaml are reported. With 'Warn' a warning event is issued,
+	// with 'Error' the build fails. Defaults to 'Warn'.
+	// +kubebuilder:validation:Enum=Warn;Error
+	// +kubebuilder:default:=Warn
+	// +optional
+	UnknownFields string `json:"unknownFields,omitempty"`
+
+	// UnmatchedImages defines how the spec.images entries that don't match any
+	// container image of the rendered objects are reported. With 'Warn' a warning
+	// event is issued, with 'Error' the build fails. When not set, the unmatched
+	// images are ignored.
+	// +kubebuilder:validation:Enum=Warn;Error
+	// +optional
+	UnmatchedImages string `json:"unmatchedImages,omitempty"`
+
+	// DeprecatedAPIs defines how the rendered objects using API versions that
+	// are removed in the current or the next Kubernetes minor version of the
+	// target cluster are reported. With 'Warn' a warning event is issued,
+	// with 'Error' the build fails. When not set, the API versions are not checked.
+	// +kubebuilder:validation:Enum=Warn;Error
+	// +optional
+	DeprecatedAPIs string `json:"deprecatedAPIs,omitempty"`
+
+	// DuplicateResources defines how the resources declared more than once by the
+	// kustomization files, e.g. by overlapping bases, are handled. With 'Error' the
+	// build fails, with 'KeepFirst' or 'KeepLast' the first or the last declaration
+	// is kept and a warning event is issued. Defaults to 'Error'.
+	// +kubebuilder:validation:Enum=Error;KeepFirst;KeepLast
+	// +kubebuilder:default:=Error
+	// +optional
+	DuplicateResources string `json:"duplicateResources,omitempty"`
+
+	// BuildMetadata is the list of kustomize build metadata options enabled
+	// in addition to the ones of the kustomization.yaml. Supported values are
+	// 'originAnnotations', 'transformerAnnotations' and 'managedByLabel'.
+	// +optional
+	BuildMetadata []string `json:"buildMetadata,omitempty"`
+
+	// OpenAPI references the OpenAPI schema used by kustomize to merge the
+	// strategic merge patches of custom resources, as with 'kustomize build --openapi'.
+	// +optional
+	OpenAPI *OpenAPISchema `json:"openapi,omitempty"`
+
+	// SortOptions defines the order of the objects in the build output, as the
+	// sortOptions field of kustomization.yaml, which it overrides.
+	// +optional
+	SortOptions *SortOptions `json:"sortOptions,omitempty"`
+
+	// EnableHelm enables the inflation of the helmCharts of the kustomization
+	// files with the HelmChartInflationGenerator, as with 'kustomize build --enable-helm'.
+	// It requires the controller to be started with the '--enable-helm' flag.
+	// +optional
+	EnableHelm bool `json:"enableHelm,omitempty"`
+}
+
+// SortOptions defines the order of the objects in the build output.
+type SortOptions struct {
+	// Order is the sort order, 'legacy' sorts the objects by kind and ID,
+	// 'fifo' keeps the order in which they are declared in the kustomization files.
+	// +kubebuilder:validation:Enum=legacy;fifo
+	// +required
+	Order string `json:"order"`
+
+	// LegacySortOptions overrides the kinds placed first and last by the 'legacy' order.
+	// Defaults to the kustomize ordering.
+	// +optional
+	LegacySortOptions *LegacySortOptions `json:"legacySortOptions,omitempty"`
+}
+
+// LegacySortOptions defines the kinds placed first and last by the 'legacy' sort order.
+type LegacySortOptions struct {
+	// OrderFirst is the list of kinds placed first, in this order.
+	// +optional
+	OrderFirst []string `json:"orderFirst,omitempty"`
+
+	// OrderLast is the list of kinds placed last, in this order.
+	// +optional
+	OrderLast []string `json:"orderLast,omitempty"`
+}
+
+// OpenAPISchema references an OpenAPI schema in JSON or YAML format, either
+// a file of the source artifact or a ConfigMap. Exactly one of Path or
+// ConfigMapRef must be specified.
+type OpenAPISchema struct {
+	// Path of the schema file, relative to the root of the source artifact.
+	// +optional
+	Path string `json:"path,omitempty"`
+
+	// ConfigMapRef references a ConfigMap in the namespace of the Kustomization
+	// containing the schema.
+	// +optional
+	ConfigMapRef *meta.LocalObjectReference `json:"configMapRef,omitempty"`
+
+	// Key of the ConfigMap entry containing the schema. Defaults to 'schema.json'.
+	// +optional
+	Key string `json:"key,omitempty"`
+}
+
+// ImagePolicyOverride contains an image name and a reference to the ImagePolicy
+// the new name, tag and digest of the image are resolved from.
+type ImagePolicyOverride struct {
+	// Name is a tag-less image name.
+	// +required
+	Name string `json:"name"`
+
+	// FromImagePolicy is a reference to an image.toolkit.fluxcd.io ImagePolicy,
+	// the new name, tag and digest are set from the latest image of the policy
+	// at build time.
+	// +required
+	FromImagePolicy meta.NamespacedObjectReference `json:"fromImagePolicy"`
+}
+
+// GeneratorArgs contains the arguments of a kustomize ConfigMap or Secret generator.
+type GeneratorArgs struct {
+	// Name of the generated object, suffixed with the hash of its
+	// content unless the name suffix hash is disabled.
+	// +required
+	Name string `json:"name"`
+
+	// Namespace of the generated object.
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+
+	// Behavior of the generator when an object with the same name
+	// is generated by a base, one of 'create', 'replace' or 'merge'.
+	// Defaults to 'create'.
+	// +kubebuilder:validation:Enum=create;replace;merge
+	// +optional
+	Behavior string `json:"behavior,omitempty"`
+
+	// Literals is a list of 'key=value' pairs.
+	// +optional
+	Literals []string `json:"literals,omitempty"`
+
+	// Envs is a list of env files of the source artifact, whose 'key=value'
+	// lines are added to the data, relative to spec.path.
+	// +optional
+	Envs []string `json:"envs,omitempty"`
+
+	// Files is a list of files of the source artifact, relative to spec.path,
+	// added to the data with their file name as key, or '<key>=<path>'.
+	// +optional
+	Files []string `json:"files,omitempty"`
+
+	// Options of the generated object.
+	// +optional
+	Options *GeneratorOptions `json:"options,omitempty"`
+}
+
+// SecretGeneratorArgs contains the arguments of a kustomize Secret generator.
+type SecretGeneratorArgs struct {
+	GeneratorArgs `json:",inline"`
+
+	// Type of the generated Secret, defaults to 'Opaque'.
+	// +optional
+	Type string `json:"type,omitempty"`
+}
+
+// GeneratorOptions contains the metadata and options of a generated object.
+type GeneratorOptions struct {
+	// Labels added to the generated object.
+	// +optional
+	Labels map[string]string `json:"labels,omitempty"`
+
+	// Annotations added to the generated object.
+	// +optional
+	Annotations map[string]string `json:"annotations,omitempty"`
+
+	// DisableNameSuffixHash disables the suffixing of the
+	// name of the generated object with the hash of its content.
+	// +optional
+	DisableNameSuffixHash bool `json:"disableNameSuffixHash,omitempty"`
+
+	// Immutable marks the generated object as immutable.
+	// +optional
+	Immutable bool `json:"immutable,omitempty"`
+}
+
+// PatchOptions defines the kustomize options of the patches.
+type PatchOptions struct {
+	// AllowNameChange allows the patch to change the name of the target objects.
+	// +optional
+	AllowNameChange bool `json:"allowNameChange,omitempty"`
+
+	// AllowKindChange allows the patch to change the kind of the target objects.
+	// +optional
+	AllowKindChange bool `json:"allowKindChange,omitempty"`
+}
+
+// ApplyOptions defines how the objects are applied on the cluster.
+type ApplyOptions struct {
+	// Exclude is a list of selectors matching the objects that are built,
+	// but never applied by the controller. Excluded objects are not part
+	// of the inventory, and are not subject to garbage collection.
+	// +optional
+	Exclude []kustomize.Selector `json:"exclude,omitempty"`
+
+	// ObjectTimeout is the maximum duration of the server-side apply of an object.
+	// When set, the objects are applied one at a time and the slowest
+	// applies are reported in the status.
+	// +optional
+	ObjectTimeout *metav1.Duration `json:"objectTimeout,omitempty"`
+
+	// Stages is the ordered list of stages in which the objects are applied.
+	// The objects of a stage are applied and health checked before the next
+	// stage starts. The objects that are not part of any stage are applied last.
+	// +optional
+	Stages []ApplyStage `json:"stages,omitempty"`
+
+	// DeferDuringRollout defers the changes to the Deployments and Argo Rollouts
+	// that are being rolled out until their rollout completes, so that the
+	// controller doesn't restart a rollout in progress.
+	// +optional
+	DeferDuringRollout bool `json:"deferDuringRollout,omitempty"`
+}
+
+// ApplyStage defines a group of objects applied together.
+type ApplyStage struct {
+	// Name of the stage, matched against the value of the
+	// 'kustomize.toolkit.fluxcd.io/apply-stage' annotation of the objects.
+	// +kubebuilder:validation:Pattern="^[a-z0-9]([a-z0-9-]*[a-z0-9])?$"
+	// +kubebuilder:validation:MaxLength=63
+	// +required
+	Name string `json:"name"`
+
+	// Selectors is a list of selectors matching the objects of the stage,
+	// in addition to the objects annotated with the stage name.
+	// +optional
+	Selectors []kustomize.Selector `json:"selectors,omitempty"`
+}
+
+// OwnerLabels defines the labels set on the applied objects, which are used
+// to verify the ownership of the objects before deleting them.
+type OwnerLabels struct {
+	// Labels replaces the default owner labels with the given key/value pairs.
+	// The labels must be unique to this Kustomization, objects without any
+	// of them are not deleted by garbage collection.
+	// +optional
+	Labels map[string]string `json:"labels,omitempty"`
+
+	// Disabled instructs the controller to not set any owner label on the
+	// applied objects. With the owner labels disabled, the ownership of the
+	// objects can't be verified, and garbage collection is skipped.
+	// +optional
+	Disabled bool `json:"disabled,omitempty"`
+}
+
+// EndpointHealthCheck defines an endpoint that must be available
+// for the Kustomization to be healthy.
+type EndpointHealthCheck struct {
+	// Type of the probe, 'HTTP' sends a GET request and expects a 2xx
+	// status code, 'TCP' opens a connection. Defaults to 'HTTP'.
+	// +kubebuilder:validation:Enum=HTTP;TCP
+	// +kubebuilder:default:=HTTP
+	// +optional
+	Type string `json:"type,omitempty"`
+
+	// Address of the endpoint, a URL for 'HTTP' probes,
+	// a 'host:port' for 'TCP' probes.
+	// +required
+	Address string `json:"address"`
+
+	// Timeout of a single probe. Defaults to 10s.
+	// +optional
+	Timeout *metav1.Duration `json:"timeout,omitempty"`
+}
+
+// PruneOptions defines how garbage collection is performed.
+type PruneOptions struct {
+	// DeleteEmptyNamespaces instructs the controller to delete the namespaces
+	// created by this Kustomization when garbage collection removes the last
+	// object it applied in them. Namespaces listed in the controller's
+	// --prune-namespace-deny-list are never deleted.
+	// +optional
+	DeleteEmptyNamespaces bool `json:"deleteEmptyNamespaces,omitempty"`
+
+	// ApprovalThreshold is the number of objects, or the percentage of the
+	// inventory, above which garbage collection is put on hold until approved.
+	// The objects pending deletion are listed in the status, and the deletion
+	// is approved by annotating the Kustomization with
+	// 'kustomize.toolkit.fluxcd.io/prune-approval: <status.pendingPrune.digest>'.
+	// +optional
+	ApprovalThreshold *intstr.IntOrString `json:"approvalThreshold,omitempty"`
+
+	// DisruptionBudget limits the number of objects that can be garbage
+	// collected in a single reconciliation. Deletions approved with the
+	// 'kustomize.toolkit.fluxcd.io/prune-approval' annotation are not
+	// subject to the budget.
+	// +optional
+	DisruptionBudget *PruneDisruptionBudget `json:"disruptionBudget,omitempty"`
+
+	// PodDisruptionBudgets defines how the PodDisruptionBudgets are honored
+	// when garbage collection deletes workloads. A deletion violates a budget
+	// when the workload has more ready pods selected by the budget than the
+	// disruptions it allows. With 'Warn' the violations are reported with an
+	// event, with 'Block' no object is deleted and the reconciliation fails.
+	// Deletions approved with the 'kustomize.toolkit.fluxcd.io/prune-approval'
+	// annotation are not checked. When not specified, the budgets are ignored.
+	// +kubebuilder:validation:Enum=Warn;Block
+	// +optional
+	PodDisruptionBudgets string `json:"podDisruptionBudgets,omitempty"`
+
+	// Hooks are invoked before garbage collection deletes the objects
+	// they target, e.g. to release the external resources of the objects.
+	// +optional
+	Hooks []PruneHook `json:"hooks,omitempty"`
+
+	// FinishedJobsTTL is the duration after which the Jobs applied by this
+	// Kustomization that completed or failed, and are no longer part of its
+	// inventory, are deleted. The Jobs are deleted even when Prune is disabled.
+	// +optional
+	FinishedJobsTTL *metav1.Duration `json:"finishedJobsTTL,omitempty"`
+
+	// GeneratedHistory is the number of previous generations of the ConfigMaps
+	// and Secrets generated by Kustomize with a hash suffix that are kept in the
+	// inventory instead of being deleted, so that the pods still mounting them
+	// during a rollout are not broken. A previous generation is kept only while
+	// its current generation is applied.
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=10
+	// +optional
+	GeneratedHistory int `json:"generatedHistory,omitempty"`
+
+	// Report records in the PruneReport status the objects that garbage
+	// collection would delete if Prune was enabled. The reported objects
+	// are kept in the inventory until they are deleted or applied again,
+	// so that they are deleted if Prune is enabled later. Nothing is
+	// reported when Prune is enabled.
+	// +optional
+	Report bool `json:"report,omitempty"`
+}
+
+// PruneHook defines an action performed for every object matching the target,
+// before the object is deleted by garbage collection. Exactly one of HTTP
+// or Job must be specified.
+type PruneHook struct {
+	// Name of the hook, unique within the Kustomization.
+	// +kubebuilder:validation:Pattern="^[a-z0-9]([a-z0-9-]*[a-z0-9])?$"
+	// +kubebuilder:validation:MaxLength=40
+	// +required
+	Name string `json:"name"`
+
+	// Target selects the objects the hook is invoked for, by group, version,
+	// kind, name, namespace, labels and annotations.
+	// +required
+	Target kustomize.Selector `json:"target"`
+
+	// HTTP posts the object to an HTTP endpoint.
+	// +optional
+	HTTP *PruneHookHTTP `json:"http,omitempty"`
+
+	// Job runs a container in the namespace of the Kustomization.
+	// +optional
+	Job *PruneHookJob `json:"job,omitempty"`
+
+	// Timeout for the hook to complete for an object.
+	// Defaults to the Kustomization timeout.
+	// +optional
+	Timeout *metav1.Duration `json:"timeout,omitempty"`
+
+	// FailurePolicy defines what happens when the hook fails or times out.
+	// With 'Fail' the object is kept in the inventory and the reconciliation
+	// fails, with 'Ignore' the object is deleted. Defaults to 'Fail'.
+	// +kubebuilder:validation:Enum=Fail;Ignore
+	// +kubebuilder:default:=Fail
+	// +optional
+	FailurePolicy string `json:"failurePolicy,omitempty"`
+}
+
+// PruneHookHTTP defines the HTTP endpoint notified before an object is deleted.
+type PruneHookHTTP struct {
+	// URL the object is posted to in JSON. The hook succeeds
+	// when the endpoint responds with a 2xx status code.
+	// +kubebuilder:validation:Pattern="^(http|https)://.*$"
+	// +required
+	URL string `json:"url"`
+
+	// SecretRef holds the name of a Secret in the same namespace as the
+	// Kustomization, that contains the bearer token under the 'token' key.
+	// +optional
+	SecretRef *meta.LocalObjectReference `json:"secretRef,omitempty"`
+}
+
+// PruneHookJob defines the container run to completion before an object is deleted.
+// The object is passed to the container in the 'OBJECT' environment variable
+// in JSON, and its coordinates in the 'OBJECT_API_VERSION', 'OBJECT_KIND',
+// 'OBJECT_NAMESPACE' and 'OBJECT_NAME' environment variables.
+type PruneHookJob struct {
+	// Image of the container.
+	// +required
+	Image string `json:"image"`
+
+	// Command overrides the entrypoint of the image.
+	// +optional
+	Command []string `json:"command,omitempty"`
+
+	// Args passed to the entrypoint.
+	// +optional
+	Args []string `json:"args,omitempty"`
+}
+
+// PruneDisruptionBudget defines the maximum number of objects
+// that can be deleted by garbage collection per reconciliation.
+type PruneDisruptionBudget struct {
+	// MaxDeletions is the number of objects, or the percentage of the
+	// inventory, that can be deleted per reconciliation. Percentages are
+	// rounded up, and with the 'Split' policy at least one object is deleted
+	// per reconciliation.
+	// +required
+	MaxDeletions intstr.IntOrString `json:"maxDeletions"`
+
+	// Policy defines what happens when the number of stale objects exceeds
+	// the budget. With 'Split' the garbage collection is spread across
+	// reconciliations, with 'Block' no object is deleted and the
+	// reconciliation fails. Defaults to 'Split'.
+	// +kubebuilder:validation:Enum=Split;Block
+	// +kubebuilder:default:=Split
+	// +optional
+	Policy string `json:"policy,omitempty"`
 }
 
 // PostBuild describes which actions to perform on the YAML manifest
@@ -185,12 +975,13 @@ type PostBuild struct {
 	// that match any of the keys defined in the map
 	// will be substituted with the set value.
 	// Includes support for bash string replacement functions
-	// e.g. ${var:=default}, ${var:position} and ${var/substring/replacement}.
+	// e.g. ${var:=default}, ${var:position} and ${var/substring/replacement},
+	// and for the ${b64:var}, ${sha256:var} and ${json:var} functions.
 	// +optional
 	Substitute map[string]string `json:"substitute,omitempty"`
 
-	// SubstituteFrom holds references to ConfigMaps and Secrets containing
-	// the variables and their values to be substituted in the YAML manifests.
+	// SubstituteFrom holds references to ConfigMaps, Secrets and external secrets
+	// containing the variables and their values to be substituted in the YAML manifests.
 	// The ConfigMap and the Secret data keys represent the var names and they
 	// must match the vars declared in the manifests for the substitution to happen.
 	// +optional
@@ -200,18 +991,32 @@ type PostBuild struct {
 // SubstituteReference contains a reference to a resource containing
 // the variables name and value.
 type SubstituteReference struct {
-	// Kind of the values referent, valid values are ('Secret', 'ConfigMap').
-	// +kubebuilder:validation:Enum=Secret;ConfigMap
+	// Kind of the values referent, valid values are ('Secret', 'ConfigMap', 'ExternalSecret').
+	// +kubebuilder:validation:Enum=Secret;ConfigMap;ExternalSecret
 	// +required
 	Kind string `json:"kind"`
 
 	// Name of the values referent. Should reside in the same namespace as the
-	// referring resource.
+	// referring resource. For an ExternalSecret, it is the name or the ARN of the
+	// AWS Secrets Manager secret, the resource name of the GCP Secret Manager
+	// secret or secret version, or the URL of the Azure Key Vault secret.
 	// +kubebuilder:validation:MinLength=1
 	// +kubebuilder:validation:MaxLength=253
 	// +required
 	Name string `json:"name"`
 
+	// Provider of the ExternalSecret, valid values are ('aws', 'gcp', 'azure').
+	// The secret is read at build time with the workload identity of the controller,
+	// and its value is never persisted in the cluster.
+	// +kubebuilder:validation:Enum=aws;gcp;azure
+	// +optional
+	Provider string `json:"provider,omitempty"`
+
+	// Key is the name of the var the value of the ExternalSecret is assigned to.
+	// When not specified, the value must be a JSON object of var names and values.
+	// +optional
+	Key string `json:"key,omitempty"`
+
 	// Optional indicates whether the referenced resource must exist, or whether to
 	// tolerate its absence. If true and the referenced resource is absent, proceed
 	// as if the resource was present but empty, without any variables defined.
@@ -240,34 +1045,293 @@ type KustomizationStatus struct {
 	// +optional
 	LastAttemptedRevision string `json:"lastAttemptedRevision,omitempty"`
 
+	// LastAttemptedFailures contains the objects that failed to apply
+	// during the last reconciliation, truncated to the first 50 objects.
+	// +optional
+	LastAttemptedFailures []ObjectFailure `json:"lastAttemptedFailures,omitempty"`
+
+	// RevisionSkew is set when the last applied revision differs from the
+	// latest revision of the source, and spec.revisionSkewThreshold is set.
+	// +optional
+	RevisionSkew *RevisionSkew `json:"revisionSkew,omitempty"`
+
+	// ApplyCheckpoint records the progress of an apply interrupted before
+	// completion, so that the next reconciliation of the same objects
+	// resumes from it instead of starting over.
+	// +optional
+	ApplyCheckpoint *ApplyCheckpoint `json:"applyCheckpoint,omitempty"`
+
+	// ClusterFingerprint identifies the API server and certificate authority
+	// of the remote cluster targeted by spec.kubeConfig when the inventory was
+	// last applied, a change means the objects may not exist on the cluster anymore.
+	// +optional
+	ClusterFingerprint string `json:"clusterFingerprint,omitempty"`
+
+	// ClusterVersion is the Kubernetes version of the target cluster when
+	// the inventory was last applied, a change means the cluster was upgraded
+	// and the API defaults of the objects may have changed.
+	// +optional
+	ClusterVersion string `json:"clusterVersion,omitempty"`
+
 	// Inventory contains the list of Kubernetes resource object references that have been successfully applied.
 	// +optional
 	Inventory *ResourceInventory `json:"inventory,omitempty"`
+
+	// PendingPrune contains the list of Kubernetes resource object references
+	// that are subject to garbage collection and are waiting for approval.
+	// +optional
+	PendingPrune *PendingPrune `json:"pendingPrune,omitempty"`
+
+	// FirstApplyReview contains the changes that the first apply would make
+	// to the cluster, recorded when FirstReconcile is set to 'observe'.
+	// +optional
+	FirstApplyReview *FirstApplyReview `json:"firstApplyReview,omitempty"`
+
+	// PruneReport contains the list of Kubernetes resource object references
+	// that garbage collection would delete, recorded when PruneOptions.Report is set.
+	// +optional
+	PruneReport *PruneReport `json:"pruneReport,omitempty"`
+
+	// DeferredDeletions is the number of stale objects kept in the inventory
+	// by the disruption budget, to be garbage collected at the next reconciliations.
+	// +optional
+	DeferredDeletions int `json:"deferredDeletions,omitempty"`
+
+	// DeferredApplies contains the Deployments and Argo Rollouts whose changes
+	// were not applied by the last reconciliation because they were being
+	// rolled out, when spec.applyOptions.deferDuringRollout is enabled.
+	// +optional
+	DeferredApplies []ResourceRef `json:"deferredApplies,omitempty"`
+
+	// HeldForTakeover contains the list of stale Kubernetes resource object
+	// references whose garbage collection is held until another Kustomization
+	// listing this one in spec.takeoverFrom becomes ready.
+	// +optional
+	HeldForTakeover []ResourceRef `json:"heldForTakeover,omitempty"`
+
+	// TakeoverClaims contains the list of Kubernetes resource object references
+	// rendered by the last build that are part of the inventory of a Kustomization
+	// listed in spec.takeoverFrom.
+	// +optional
+	TakeoverClaims []ResourceRef `json:"takeoverClaims,omitempty"`
+
+	// ObjectPolicies contains the list of Kubernetes resource object references
+	// that carry a reconcile policy, grouped by policy.
+	// +optional
+	ObjectPolicies []ObjectPolicy `json:"objectPolicies,omitempty"`
+
+	// BuildInputsDigest is the digest of the inputs of the last build, i.e.
+	// the source artifacts, the spec fields the kustomization.yaml is generated
+	// from, the substituted variables and the version of the decryption Secret.
+	// Two clusters reporting the same digest build the same configuration.
+	// +optional
+	BuildInputsDigest string `json:"buildInputsDigest,omitempty"`
+
+	// ObservedToolchain contains the versions of the tools used by the
+	// controller for the last build, e.g. kustomize, kyaml, sops and go.
+	// +optional
+	ObservedToolchain map[string]string `json:"observedToolchain,omitempty"`
+
+	// Warnings contains the non-fatal issues found by the last build, e.g.
+	// deprecated kustomize fields, overridden images or ignored files.
+	// +optional
+	Warnings []string `json:"warnings,omitempty"`
+
+	// SlowestApplies contains the objects that took the longest to apply
+	// during the last reconciliation, when spec.applyOptions.objectTimeout is set.
+	// +optional
+	SlowestApplies []ApplyDuration `json:"slowestApplies,omitempty"`
+
+	// TruncatedFields contains the status fields that were truncated or cleared
+	// by the last reconciliation to keep the status within its size limits.
+	// Their content is emitted as events instead.
+	// +optional
+	TruncatedFields []string `json:"truncatedFields,omitempty"`
+
+	// Dependents contains the number of Kustomizations that depend on this one,
+	// directly or transitively, and how many of them are blocked.
+	// +optional
+	Dependents *DependentsSummary `json:"dependents,omitempty"`
+
+	// Summary is a condensed representation of the status, for external
+	// systems that don't need the inventory, also published in JSON format
+	// in the kustomize.toolkit.fluxcd.io/status-summary annotation.
+	// +optional
+	Summary *StatusSummary `json:"summary,omitempty"`
+}
+
+// StatusSummary is a condensed, versioned representation of the Kustomization status.
+type StatusSummary struct {
+	// Version is the version of the summary format.
+	// +required
+	Version string `json:"version"`
+
+	// LastAppliedRevision is the last successfully applied revision.
+	// +optional
+	LastAppliedRevision string `json:"lastAppliedRevision,omitempty"`
+
+	// Objects is the number of objects in the inventory.
+	// +required
+	Objects int `json:"objects"`
+
+	// PendingPrune is the number of objects waiting for the prune approval.
+	// +required
+	PendingPrune int `json:"pendingPrune"`
+
+	// Warnings is the number of non-fatal issues found by the last build.
+	// +required
+	Warnings int `json:"warnings"`
+
+	// Conditions contains the type, status and reason of each condition.
+	// +optional
+	Conditions []ConditionSummary `json:"conditions,omitempty"`
+}
+
+// ConditionSummary contains the type, status and reason of a condition.
+type ConditionSummary struct {
+	// Type of the condition.
+	// +required
+	Type string `json:"type"`
+
+	// Status of the condition, one of True, False, Unknown.
+	// +required
+	Status metav1.ConditionStatus `json:"status"`
+
+	// Reason of the last transition of the condition.
+	// +optional
+	Reason string `json:"reason,omitempty"`
+}
+
+// NewStatusSummary returns the StatusSummary of the given status.
+func NewStatusSummary(status KustomizationStatus) *StatusSummary {
+	summary := &StatusSummary{
+		Version:             StatusSummaryVersion,
+		LastAppliedRevision: status.LastAppliedRevision,
+		Warnings:            len(status.Warnings),
+	}
+	if status.Inventory != nil {
+		summary.Objects = len(status.Inventory.Entries)
+	}
+	if status.PendingPrune != nil {
+		summary.PendingPrune = status.PendingPrune.Total
+		if summary.PendingPrune == 0 {
+			summary.PendingPrune = len(status.PendingPrune.Entries)
+		}
+	}
+	for _, c := range status.Conditions {
+		summary.Conditions = append(summary.Conditions, ConditionSummary{
+			Type:   c.Type,
+			Status: c.Status,
+			Reason: c.Reason,
+		})
+	}
+	return summary
+}
+
+// DependentsSummary contains the number of Kustomizations that depend on a Kustomization.
+type DependentsSummary struct {
+	// Direct is the number of Kustomizations that list this one in spec.dependsOn.
+	// +required
+	Direct int `json:"direct"`
+
+	// Total is the number of Kustomizations that depend on this one, directly or transitively.
+	// +required
+	Total int `json:"total"`
+
+	// Blocked is the number of dependents waiting for their dependencies to become ready.
+	// +required
+	Blocked int `json:"blocked"`
+}
+
+// ObjectFailure describes an object that failed to apply.
+type ObjectFailure struct {
+	// ID is the object metadata in the format '<namespace>_<name>_<group>_<kind>'.
+	// +required
+	ID string `json:"id"`
+
+	// Reason is the reason of the failure, e.g. Invalid, Forbidden or NotYetInstalled.
+	// +required
+	Reason string `json:"reason"`
+
+	// Message is the error returned by the API server.
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
+// ApplyCheckpoint contains the number of objects applied in order
+// for a revision, before the apply was interrupted.
+type ApplyCheckpoint struct {
+	// Revision is the source revision being applied.
+	// +required
+	Revision string `json:"revision"`
+
+	// Digest of the objects being applied, in the format '<algo>:<checksum>'.
+	// +required
+	Digest string `json:"digest"`
+
+	// Applied is the number of objects successfully applied, in apply order.
+	// +required
+	Applied int `json:"applied"`
+}
+
+// RevisionSkew contains the latest revision of the source that differs from
+// the last applied revision.
+type RevisionSkew struct {
+	// SourceRevision is the latest revision advertised by the source.
+	// +required
+	SourceRevision string `json:"sourceRevision"`
+
+	// Since is the time at which the last applied revision started
+	// to differ from the revision of the source.
+	// +required
+	Since metav1.Time `json:"since"`
+
+	// Behind is true when the revisions differ for longer than spec.revisionSkewThreshold.
+	// +required
+	Behind bool `json:"behind"`
 }
 
 // KustomizationProgressing resets the conditions of the given Kustomization to a single
 // ReadyCondition with status ConditionUnknown.
 func KustomizationProgressing(k Kustomization, message string) Kustomization {
 	newCondition := metav1.Condition{
-		Type:    meta.ReadyCondition,
-		Status:  metav1.ConditionUnknown,
-		Reason:  meta.ProgressingReason,
-		Message: trimString(message, MaxConditionMessageLength),
+		Type:               meta.ReadyCondition,
+		Status:             metav1.ConditionUnknown,
+		Reason:             meta.ProgressingReason,
+		Message:            trimString(message, MaxConditionMessageLength),
+		ObservedGeneration: k.Generation,
 	}
 	apimeta.SetStatusCondition(k.GetStatusConditions(), newCondition)
 	return k
 }
 
+// SetKustomizationAttestation sets the AttestationVerifiedCondition status for a Kustomization.
+func SetKustomizationAttestation(k *Kustomization, status metav1.ConditionStatus, reason, message string) {
+	if k.Spec.Attestation == nil {
+		apimeta.RemoveStatusCondition(k.GetStatusConditions(), AttestationVerifiedCondition)
+		return
+	}
+	newCondition := metav1.Condition{
+		Type:               AttestationVerifiedCondition,
+		Status:             status,
+		Reason:             reason,
+		Message:            trimString(message, MaxConditionMessageLength),
+		ObservedGeneration: k.Generation,
+	}
+	apimeta.SetStatusCondition(k.GetStatusConditions(), newCondition)
+}
+
 // SetKustomizationHealthiness sets the HealthyCondition status for a Kustomization.
 func SetKustomizationHealthiness(k *Kustomization, status metav1.ConditionStatus, reason, message string) {
 	if !k.Spec.Wait && len(k.Spec.HealthChecks) == 0 {
 		apimeta.RemoveStatusCondition(k.GetStatusConditions(), HealthyCondition)
 	} else {
 		newCondition := metav1.Condition{
-			Type:    HealthyCondition,
-			Status:  status,
-			Reason:  reason,
-			Message: trimString(message, MaxConditionMessageLength),
+			Type:               HealthyCondition,
+			Status:             status,
+			Reason:             reason,
+			Message:            trimString(message, MaxConditionMessageLength),
+			ObservedGeneration: k.Generation,
 		}
 		apimeta.SetStatusCondition(k.GetStatusConditions(), newCondition)
 	}
@@ -277,10 +1341,11 @@ func SetKustomizationHealthiness(k *Kustomization, status metav1.ConditionStatus
 // SetKustomizationReadiness sets the ReadyCondition, ObservedGeneration, and LastAttemptedRevision, on the Kustomization.
 func SetKustomizationReadiness(k *Kustomization, status metav1.ConditionStatus, reason, message string, revision string) {
 	newCondition := metav1.Condition{
-		Type:    meta.ReadyCondition,
-		Status:  status,
-		Reason:  reason,
-		Message: trimString(message, MaxConditionMessageLength),
+		Type:               meta.ReadyCondition,
+		Status:             status,
+		Reason:             reason,
+		Message:            trimString(message, MaxConditionMessageLength),
+		ObservedGeneration: k.Generation,
 	}
 	apimeta.SetStatusCondition(k.GetStatusConditions(), newCondition)
 
@@ -337,6 +1402,15 @@ func (in Kustomization) GetRetryInterval() time.Duration {
 	return in.GetRequeueAfter()
 }
 
+// GetProgressingInterval returns the interval at which to retry the
+// reconciliation while the health checks are failing.
+func (in Kustomization) GetProgressingInterval() time.Duration {
+	if in.Spec.ProgressingInterval != nil {
+		return in.Spec.ProgressingInterval.Duration
+	}
+	return in.GetRetryInterval()
+}
+
 // GetRequeueAfter returns the duration after which the Kustomization must be
 // reconciled again.
 func (in Kustomization) GetRequeueAfter() time.Duration {
@@ -373,6 +1447,8 @@ func (in *Kustomization) GetStatusConditions() *[]metav1.Condition {
 // +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp",description=""
 // +kubebuilder:printcolumn:name="Ready",type="string",JSONPath=".status.conditions[?(@.type==\"Ready\")].status",description=""
 // +kubebuilder:printcolumn:name="Status",type="string",JSONPath=".status.conditions[?(@.type==\"Ready\")].message",description=""
+// +kubebuilder:printcolumn:name="Revision",type="string",JSONPath=".status.summary.lastAppliedRevision",description="",priority=1
+// +kubebuilder:printcolumn:name="Objects",type="integer",JSONPath=".status.summary.objects",description="",priority=1
 
 // Kustomization is the Schema for the kustomizations API.
 type Kustomization struct {
@@ -0,0 +1,158 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta2
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/fluxcd/pkg/apis/meta"
+)
+
+const (
+	KustomizationGroupKind = "KustomizationGroup"
+
+	// MaxKustomizationGroupFailures is the maximum number of failures
+	// recorded in the status of a KustomizationGroup.
+	MaxKustomizationGroupFailures = 100
+)
+
+// KustomizationGroupSpec defines the Kustomizations aggregated by the group.
+type KustomizationGroupSpec struct {
+	// Kustomizations is a list of references to the Kustomizations of the group.
+	// The namespace defaults to the namespace of the group.
+	// +optional
+	Kustomizations []meta.NamespacedObjectReference `json:"kustomizations,omitempty"`
+
+	// Selector selects the Kustomizations of the group by their labels,
+	// in the namespace of the group.
+	// +optional
+	Selector *metav1.LabelSelector `json:"selector,omitempty"`
+}
+
+// KustomizationGroupStatus defines the aggregated state of the Kustomizations of a group.
+type KustomizationGroupStatus struct {
+	// ObservedGeneration is the last reconciled generation.
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+
+	// Total is the number of Kustomizations in the group.
+	// +optional
+	Total int `json:"total,omitempty"`
+
+	// Ready is the number of Kustomizations that are ready.
+	// +optional
+	Ready int `json:"ready,omitempty"`
+
+	// Failed is the number of Kustomizations that are not ready,
+	// including the referenced Kustomizations that don't exist.
+	// +optional
+	Failed int `json:"failed,omitempty"`
+
+	// Suspended is the number of suspended Kustomizations.
+	// +optional
+	Suspended int `json:"suspended,omitempty"`
+
+	// Revisions contains the number of Kustomizations for each last applied revision.
+	// +optional
+	Revisions []RevisionCount `json:"revisions,omitempty"`
+
+	// Failures contains the Kustomizations that are not ready,
+	// limited to the first 100 in the namespace/name order.
+	// +optional
+	Failures []KustomizationFailure `json:"failures,omitempty"`
+}
+
+// RevisionCount contains the number of Kustomizations that applied a revision.
+type RevisionCount struct {
+	// Revision is the last applied revision.
+	// +required
+	Revision string `json:"revision"`
+
+	// Count is the number of Kustomizations with this last applied revision.
+	// +required
+	Count int `json:"count"`
+}
+
+// KustomizationFailure describes a Kustomization that is not ready.
+type KustomizationFailure struct {
+	// Name of the Kustomization.
+	// +required
+	Name string `json:"name"`
+
+	// Namespace of the Kustomization.
+	// +required
+	Namespace string `json:"namespace"`
+
+	// Revision is the last attempted revision.
+	// +optional
+	Revision string `json:"revision,omitempty"`
+
+	// Reason of the Ready condition.
+	// +optional
+	Reason string `json:"reason,omitempty"`
+
+	// Message of the Ready condition.
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
+// GetConditions returns the status conditions of the object.
+func (in KustomizationGroup) GetConditions() []metav1.Condition {
+	return in.Status.Conditions
+}
+
+// SetConditions sets the status conditions on the object.
+func (in *KustomizationGroup) SetConditions(conditions []metav1.Condition) {
+	in.Status.Conditions = conditions
+}
+
+// +genclient
+// +genclient:Namespaced
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:shortName=ksg
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp",description=""
+// +kubebuilder:printcolumn:name="Total",type="integer",JSONPath=".status.total",description=""
+// +kubebuilder:printcolumn:name="Failed",type="integer",JSONPath=".status.failed",description=""
+// +kubebuilder:printcolumn:name="Ready",type="string",JSONPath=".status.conditions[?(@.type==\"Ready\")].status",description=""
+// +kubebuilder:printcolumn:name="Status",type="string",JSONPath=".status.conditions[?(@.type==\"Ready\")].message",description=""
+
+// KustomizationGroup is the Schema for the kustomizationgroups API.
+type KustomizationGroup struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec KustomizationGroupSpec `json:"spec,omitempty"`
+	// +kubebuilder:default:={"observedGeneration":-1}
+	Status KustomizationGroupStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// KustomizationGroupList contains a list of KustomizationGroups.
+type KustomizationGroupList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []KustomizationGroup `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&KustomizationGroup{}, &KustomizationGroupList{})
+}
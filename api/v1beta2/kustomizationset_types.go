@@ -0,0 +1,175 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta2
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	KustomizationSetKind = "KustomizationSet"
+
+	// KustomizationSetLabel is the label set on the generated Kustomizations
+	// with the name of the KustomizationSet.
+	KustomizationSetLabel = "kustomize.toolkit.fluxcd.io/set"
+)
+
+// KustomizationSetSpec defines the Kustomizations generated from a template
+// for each element of a list.
+type KustomizationSetSpec struct {
+	// Elements is the list of elements a Kustomization is generated for.
+	// +optional
+	Elements []KustomizationSetElement `json:"elements,omitempty"`
+
+	// Template is the Kustomization generated for each element.
+	// +required
+	Template KustomizationTemplate `json:"template"`
+
+	// This flag tells the controller to suspend the generation of the Kustomizations,
+	// it does not apply to already generated Kustomizations. Defaults to false.
+	// +optional
+	Suspend bool `json:"suspend,omitempty"`
+}
+
+// KustomizationSetElement holds the variables of a generated Kustomization.
+type KustomizationSetElement struct {
+	// Name of the element, the generated Kustomization is named '<set name>-<element name>'.
+	// +kubebuilder:validation:Pattern="^[a-z0-9]([a-z0-9-]*[a-z0-9])?$"
+	// +kubebuilder:validation:MaxLength=63
+	// +required
+	Name string `json:"name"`
+
+	// Variables holds the key/value pairs added to the post build substitutions
+	// of the generated Kustomization, overriding the ones of the template.
+	// +optional
+	Variables map[string]string `json:"variables,omitempty"`
+
+	// VariablesFrom holds references to Secrets and ConfigMaps containing the
+	// variables of the element, e.g. cluster credentials, region or tier.
+	// They are added to the post build substitutions of the generated Kustomization
+	// after the ones of the template, the values are not copied.
+	// +optional
+	VariablesFrom []SubstituteReference `json:"variablesFrom,omitempty"`
+}
+
+// KustomizationTemplate describes the Kustomizations generated by a KustomizationSet or a Tenant.
+type KustomizationTemplate struct {
+	// Labels set on the generated Kustomizations.
+	// +optional
+	Labels map[string]string `json:"labels,omitempty"`
+
+	// Annotations set on the generated Kustomizations.
+	// +optional
+	Annotations map[string]string `json:"annotations,omitempty"`
+
+	// Spec of the generated Kustomizations.
+	// +required
+	Spec KustomizationSpec `json:"spec"`
+}
+
+// KustomizationSetStatus defines the observed state of a KustomizationSet.
+type KustomizationSetStatus struct {
+	// ObservedGeneration is the last reconciled generation.
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+
+	// Kustomizations contains the names of the generated Kustomizations.
+	// +optional
+	Kustomizations []string `json:"kustomizations,omitempty"`
+
+	// Targets contains the applied revision and the readiness of the
+	// Kustomization generated for each element, in the order of the elements.
+	// +optional
+	Targets []KustomizationSetTarget `json:"targets,omitempty"`
+}
+
+// KustomizationSetTarget holds the state of the Kustomization generated for an element.
+type KustomizationSetTarget struct {
+	// Name of the element.
+	// +required
+	Name string `json:"name"`
+
+	// Kustomization is the name of the generated Kustomization.
+	// +required
+	Kustomization string `json:"kustomization"`
+
+	// LastAppliedRevision is the last revision successfully applied by the Kustomization.
+	// +optional
+	LastAppliedRevision string `json:"lastAppliedRevision,omitempty"`
+
+	// LastAttemptedRevision is the last revision the Kustomization tried to apply.
+	// +optional
+	LastAttemptedRevision string `json:"lastAttemptedRevision,omitempty"`
+
+	// Ready is the status of the Ready condition of the Kustomization,
+	// Unknown until the Kustomization is reconciled.
+	// +required
+	Ready metav1.ConditionStatus `json:"ready"`
+
+	// Reason is the reason of the Ready condition of the Kustomization.
+	// +optional
+	Reason string `json:"reason,omitempty"`
+
+	// Message is the message of the Ready condition of the Kustomization.
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
+// GetConditions returns the status conditions of the object.
+func (in KustomizationSet) GetConditions() []metav1.Condition {
+	return in.Status.Conditions
+}
+
+// SetConditions sets the status conditions on the object.
+func (in *KustomizationSet) SetConditions(conditions []metav1.Condition) {
+	in.Status.Conditions = conditions
+}
+
+// +genclient
+// +genclient:Namespaced
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:shortName=kss
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp",description=""
+// +kubebuilder:printcolumn:name="Ready",type="string",JSONPath=".status.conditions[?(@.type==\"Ready\")].status",description=""
+// +kubebuilder:printcolumn:name="Status",type="string",JSONPath=".status.conditions[?(@.type==\"Ready\")].message",description=""
+
+// KustomizationSet is the Schema for the kustomizationsets API.
+type KustomizationSet struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec KustomizationSetSpec `json:"spec,omitempty"`
+	// +kubebuilder:default:={"observedGeneration":-1}
+	Status KustomizationSetStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// KustomizationSetList contains a list of KustomizationSets.
+type KustomizationSetList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []KustomizationSet `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&KustomizationSet{}, &KustomizationSetList{})
+}
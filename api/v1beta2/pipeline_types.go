@@ -0,0 +1,156 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta2
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/fluxcd/pkg/apis/meta"
+)
+
+const (
+	PipelineKind = "Pipeline"
+
+	// PromotedRevisionAnnotation is the annotation set by a Pipeline on the
+	// Kustomizations of its stages, a Kustomization with this annotation
+	// only applies the source revision it contains.
+	PromotedRevisionAnnotation = "kustomize.toolkit.fluxcd.io/promoted-revision"
+
+	// PromotionApprovalAnnotation is the annotation used to approve the
+	// promotion of a revision to a stage, in the '<stage>@<revision>' format.
+	PromotionApprovalAnnotation = "kustomize.toolkit.fluxcd.io/promotion-approval"
+)
+
+// PipelineSpec defines the ordered stages of a Pipeline.
+type PipelineSpec struct {
+	// Stages is the ordered list of environments, the revisions applied by
+	// the Kustomization of a stage are promoted to the next stage.
+	// +kubebuilder:validation:MinItems=1
+	// +required
+	Stages []PipelineStage `json:"stages"`
+
+	// This flag tells the controller to suspend the promotions,
+	// it does not apply to the revisions already promoted.
+	// +optional
+	Suspend bool `json:"suspend,omitempty"`
+}
+
+// PipelineStage binds an environment to a Kustomization.
+type PipelineStage struct {
+	// Name of the stage, e.g. 'staging'.
+	// +kubebuilder:validation:MinLength=1
+	// +kubebuilder:validation:MaxLength=63
+	// +required
+	Name string `json:"name"`
+
+	// KustomizationRef is the reference to the Kustomization of the stage.
+	// The namespace defaults to the namespace of the Pipeline.
+	// +required
+	KustomizationRef meta.NamespacedObjectReference `json:"kustomizationRef"`
+
+	// SoakTime is the duration the previous stage must be ready with a revision
+	// before it is promoted to this stage. Ignored for the first stage.
+	// +optional
+	SoakTime *metav1.Duration `json:"soakTime,omitempty"`
+
+	// RequireApproval holds the promotion of a revision to this stage until it
+	// is approved with the 'kustomize.toolkit.fluxcd.io/promotion-approval'
+	// annotation. Ignored for the first stage.
+	// +optional
+	RequireApproval bool `json:"requireApproval,omitempty"`
+}
+
+// PipelineStatus defines the observed state of the stages of a Pipeline.
+type PipelineStatus struct {
+	// ObservedGeneration is the last reconciled generation.
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+
+	// Stages contains the state of each stage, in the order of the spec.
+	// +optional
+	Stages []PipelineStageStatus `json:"stages,omitempty"`
+}
+
+// PipelineStageStatus contains the promotion state of a stage.
+type PipelineStageStatus struct {
+	// Name of the stage.
+	// +required
+	Name string `json:"name"`
+
+	// LastAppliedRevision is the last revision applied by the Kustomization of the stage.
+	// +optional
+	LastAppliedRevision string `json:"lastAppliedRevision,omitempty"`
+
+	// ReadySince is the time the Kustomization of the stage was first
+	// observed ready with the last applied revision.
+	// +optional
+	ReadySince *metav1.Time `json:"readySince,omitempty"`
+
+	// PromotedRevision is the last revision promoted to the stage.
+	// +optional
+	PromotedRevision string `json:"promotedRevision,omitempty"`
+
+	// PendingRevision is the revision waiting for the soak time
+	// or the approval to be promoted to the stage.
+	// +optional
+	PendingRevision string `json:"pendingRevision,omitempty"`
+}
+
+// GetConditions returns the status conditions of the object.
+func (in Pipeline) GetConditions() []metav1.Condition {
+	return in.Status.Conditions
+}
+
+// SetConditions sets the status conditions on the object.
+func (in *Pipeline) SetConditions(conditions []metav1.Condition) {
+	in.Status.Conditions = conditions
+}
+
+// +genclient
+// +genclient:Namespaced
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:shortName=kspl
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp",description=""
+// +kubebuilder:printcolumn:name="Ready",type="string",JSONPath=".status.conditions[?(@.type==\"Ready\")].status",description=""
+// +kubebuilder:printcolumn:name="Status",type="string",JSONPath=".status.conditions[?(@.type==\"Ready\")].message",description=""
+
+// Pipeline is the Schema for the pipelines API.
+type Pipeline struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec PipelineSpec `json:"spec,omitempty"`
+	// +kubebuilder:default:={"observedGeneration":-1}
+	Status PipelineStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// PipelineList contains a list of Pipelines.
+type PipelineList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []Pipeline `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&Pipeline{}, &PipelineList{})
+}
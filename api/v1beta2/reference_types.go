@@ -45,3 +45,17 @@ func (s *CrossNamespaceSourceReference) String() string {
 	}
 	return fmt.Sprintf("%s/%s", s.Kind, s.Name)
 }
+
+// SourceMount contains a reference to an additional source and the
+// path at which its artifact is extracted inside the build root.
+type SourceMount struct {
+	// Reference of the additional source.
+	// +required
+	SourceRef CrossNamespaceSourceReference `json:"sourceRef"`
+
+	// Path relative to the root of the SourceRef artifact, where
+	// the artifact of the additional source is extracted.
+	// The path must not exist in the SourceRef artifact.
+	// +required
+	Path string `json:"path"`
+}
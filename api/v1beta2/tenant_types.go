@@ -0,0 +1,144 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta2
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	TenantKind = "Tenant"
+
+	// TenantLabel is the label set on the objects generated for a Tenant
+	// with the name of the Tenant.
+	TenantLabel = "kustomize.toolkit.fluxcd.io/tenant"
+)
+
+// TenantSpec defines the namespace, the RBAC and the Kustomization generated for a tenant.
+type TenantSpec struct {
+	// Namespace is the name of the namespace created for the tenant,
+	// defaults to the name of the Tenant.
+	// +kubebuilder:validation:Pattern="^[a-z0-9]([a-z0-9-]*[a-z0-9])?$"
+	// +kubebuilder:validation:MaxLength=63
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+
+	// ServiceAccountName is the name of the service account created in the
+	// tenant namespace and used to reconcile the tenant Kustomization,
+	// defaults to the name of the Tenant.
+	// +optional
+	ServiceAccountName string `json:"serviceAccountName,omitempty"`
+
+	// ClusterRole is the name of the ClusterRole bound to the service account
+	// in the tenant namespace, defaults to 'admin'.
+	// +kubebuilder:default:=admin
+	// +optional
+	ClusterRole string `json:"clusterRole,omitempty"`
+
+	// RetainNamespace orphans the tenant namespace instead of deleting it
+	// when the Tenant is deleted or the namespace is renamed. The namespace
+	// is recorded in the inventory with the retain flag.
+	// +optional
+	RetainNamespace bool `json:"retainNamespace,omitempty"`
+
+	// Kustomization is the template of the Kustomization created in the tenant namespace.
+	// The service account name is set to the tenant one, and the target namespace
+	// defaults to the tenant namespace.
+	// +required
+	Kustomization KustomizationTemplate `json:"kustomization"`
+}
+
+// TenantStatus defines the observed state of a Tenant.
+type TenantStatus struct {
+	// ObservedGeneration is the last reconciled generation.
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+
+	// Inventory contains the list of Kubernetes resource object references
+	// generated for the tenant.
+	// +optional
+	Inventory *ResourceInventory `json:"inventory,omitempty"`
+}
+
+// GetNamespaceName returns the name of the tenant namespace.
+func (in Tenant) GetNamespaceName() string {
+	if in.Spec.Namespace != "" {
+		return in.Spec.Namespace
+	}
+	return in.GetName()
+}
+
+// GetServiceAccountName returns the name of the tenant service account.
+func (in Tenant) GetServiceAccountName() string {
+	if in.Spec.ServiceAccountName != "" {
+		return in.Spec.ServiceAccountName
+	}
+	return in.GetName()
+}
+
+// GetClusterRole returns the name of the ClusterRole bound to the tenant service account.
+func (in Tenant) GetClusterRole() string {
+	if in.Spec.ClusterRole != "" {
+		return in.Spec.ClusterRole
+	}
+	return "admin"
+}
+
+// GetConditions returns the status conditions of the object.
+func (in Tenant) GetConditions() []metav1.Condition {
+	return in.Status.Conditions
+}
+
+// SetConditions sets the status conditions on the object.
+func (in *Tenant) SetConditions(conditions []metav1.Condition) {
+	in.Status.Conditions = conditions
+}
+
+// +genclient
+// +genclient:nonNamespaced
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:scope=Cluster
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp",description=""
+// +kubebuilder:printcolumn:name="Ready",type="string",JSONPath=".status.conditions[?(@.type==\"Ready\")].status",description=""
+// +kubebuilder:printcolumn:name="Status",type="string",JSONPath=".status.conditions[?(@.type==\"Ready\")].message",description=""
+
+// Tenant is the Schema for the tenants API.
+type Tenant struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec TenantSpec `json:"spec,omitempty"`
+	// +kubebuilder:default:={"observedGeneration":-1}
+	Status TenantStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// TenantList contains a list of Tenants.
+type TenantList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []Tenant `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&Tenant{}, &TenantList{})
+}
@@ -27,177 +27,236 @@ import (
 	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1"
 	runtime "k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
 )
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *CrossNamespaceSourceReference) DeepCopyInto(out *CrossNamespaceSourceReference) {
+func (in *ApplyCheckpoint) DeepCopyInto(out *ApplyCheckpoint) {
 	*out = *in
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CrossNamespaceSourceReference.
-func (in *CrossNamespaceSourceReference) DeepCopy() *CrossNamespaceSourceReference {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ApplyCheckpoint.
+func (in *ApplyCheckpoint) DeepCopy() *ApplyCheckpoint {
 	if in == nil {
 		return nil
 	}
-	out := new(CrossNamespaceSourceReference)
+	out := new(ApplyCheckpoint)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *Decryption) DeepCopyInto(out *Decryption) {
+func (in *ApplyDuration) DeepCopyInto(out *ApplyDuration) {
 	*out = *in
-	if in.SecretRef != nil {
-		in, out := &in.SecretRef, &out.SecretRef
-		*out = new(meta.LocalObjectReference)
-		**out = **in
-	}
+	out.Duration = in.Duration
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Decryption.
-func (in *Decryption) DeepCopy() *Decryption {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ApplyDuration.
+func (in *ApplyDuration) DeepCopy() *ApplyDuration {
 	if in == nil {
 		return nil
 	}
-	out := new(Decryption)
+	out := new(ApplyDuration)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *KubeConfig) DeepCopyInto(out *KubeConfig) {
+func (in *ApplyOptions) DeepCopyInto(out *ApplyOptions) {
 	*out = *in
-	out.SecretRef = in.SecretRef
+	if in.Exclude != nil {
+		in, out := &in.Exclude, &out.Exclude
+		*out = make([]kustomize.Selector, len(*in))
+		copy(*out, *in)
+	}
+	if in.ObjectTimeout != nil {
+		in, out := &in.ObjectTimeout, &out.ObjectTimeout
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.Stages != nil {
+		in, out := &in.Stages, &out.Stages
+		*out = make([]ApplyStage, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KubeConfig.
-func (in *KubeConfig) DeepCopy() *KubeConfig {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ApplyOptions.
+func (in *ApplyOptions) DeepCopy() *ApplyOptions {
 	if in == nil {
 		return nil
 	}
-	out := new(KubeConfig)
+	out := new(ApplyOptions)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *Kustomization) DeepCopyInto(out *Kustomization) {
+func (in *ApplyStage) DeepCopyInto(out *ApplyStage) {
 	*out = *in
-	out.TypeMeta = in.TypeMeta
-	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
-	in.Spec.DeepCopyInto(&out.Spec)
-	in.Status.DeepCopyInto(&out.Status)
+	if in.Selectors != nil {
+		in, out := &in.Selectors, &out.Selectors
+		*out = make([]kustomize.Selector, len(*in))
+		copy(*out, *in)
+	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Kustomization.
-func (in *Kustomization) DeepCopy() *Kustomization {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ApplyStage.
+func (in *ApplyStage) DeepCopy() *ApplyStage {
 	if in == nil {
 		return nil
 	}
-	out := new(Kustomization)
+	out := new(ApplyStage)
 	in.DeepCopyInto(out)
 	return out
 }
 
-// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
-func (in *Kustomization) DeepCopyObject() runtime.Object {
-	if c := in.DeepCopy(); c != nil {
-		return c
-	}
-	return nil
-}
-
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *KustomizationList) DeepCopyInto(out *KustomizationList) {
+func (in *ArtifactFilter) DeepCopyInto(out *ArtifactFilter) {
 	*out = *in
-	out.TypeMeta = in.TypeMeta
-	in.ListMeta.DeepCopyInto(&out.ListMeta)
-	if in.Items != nil {
-		in, out := &in.Items, &out.Items
-		*out = make([]Kustomization, len(*in))
-		for i := range *in {
-			(*in)[i].DeepCopyInto(&(*out)[i])
-		}
+	if in.Include != nil {
+		in, out := &in.Include, &out.Include
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Exclude != nil {
+		in, out := &in.Exclude, &out.Exclude
+		*out = make([]string, len(*in))
+		copy(*out, *in)
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KustomizationList.
-func (in *KustomizationList) DeepCopy() *KustomizationList {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ArtifactFilter.
+func (in *ArtifactFilter) DeepCopy() *ArtifactFilter {
 	if in == nil {
 		return nil
 	}
-	out := new(KustomizationList)
+	out := new(ArtifactFilter)
 	in.DeepCopyInto(out)
 	return out
 }
 
-// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
-func (in *KustomizationList) DeepCopyObject() runtime.Object {
-	if c := in.DeepCopy(); c != nil {
-		return c
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AttestationPolicy) DeepCopyInto(out *AttestationPolicy) {
+	*out = *in
+	out.SecretRef = in.SecretRef
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AttestationPolicy.
+func (in *AttestationPolicy) DeepCopy() *AttestationPolicy {
+	if in == nil {
+		return nil
 	}
-	return nil
+	out := new(AttestationPolicy)
+	in.DeepCopyInto(out)
+	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *KustomizationSpec) DeepCopyInto(out *KustomizationSpec) {
+func (in *BuildOptions) DeepCopyInto(out *BuildOptions) {
 	*out = *in
-	if in.DependsOn != nil {
-		in, out := &in.DependsOn, &out.DependsOn
-		*out = make([]meta.NamespacedObjectReference, len(*in))
+	if in.ManifestExtensions != nil {
+		in, out := &in.ManifestExtensions, &out.ManifestExtensions
+		*out = make([]string, len(*in))
 		copy(*out, *in)
 	}
-	if in.Decryption != nil {
-		in, out := &in.Decryption, &out.Decryption
-		*out = new(Decryption)
-		(*in).DeepCopyInto(*out)
-	}
-	out.Interval = in.Interval
-	if in.RetryInterval != nil {
-		in, out := &in.RetryInterval, &out.RetryInterval
-		*out = new(v1.Duration)
-		**out = **in
+	if in.BuildMetadata != nil {
+		in, out := &in.BuildMetadata, &out.BuildMetadata
+		*out = make([]string, len(*in))
+		copy(*out, *in)
 	}
-	if in.KubeConfig != nil {
-		in, out := &in.KubeConfig, &out.KubeConfig
-		*out = new(KubeConfig)
-		**out = **in
+	if in.OpenAPI != nil {
+		in, out := &in.OpenAPI, &out.OpenAPI
+		*out = new(OpenAPISchema)
+		(*in).DeepCopyInto(*out)
 	}
-	if in.PostBuild != nil {
-		in, out := &in.PostBuild, &out.PostBuild
-		*out = new(PostBuild)
+	if in.SortOptions != nil {
+		in, out := &in.SortOptions, &out.SortOptions
+		*out = new(SortOptions)
 		(*in).DeepCopyInto(*out)
 	}
-	if in.HealthChecks != nil {
-		in, out := &in.HealthChecks, &out.HealthChecks
-		*out = make([]meta.NamespacedObjectKindReference, len(*in))
-		copy(*out, *in)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BuildOptions.
+func (in *BuildOptions) DeepCopy() *BuildOptions {
+	if in == nil {
+		return nil
 	}
-	if in.Patches != nil {
-		in, out := &in.Patches, &out.Patches
-		*out = make([]kustomize.Patch, len(*in))
-		copy(*out, *in)
+	out := new(BuildOptions)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ConditionSummary) DeepCopyInto(out *ConditionSummary) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ConditionSummary.
+func (in *ConditionSummary) DeepCopy() *ConditionSummary {
+	if in == nil {
+		return nil
 	}
-	if in.PatchesStrategicMerge != nil {
-		in, out := &in.PatchesStrategicMerge, &out.PatchesStrategicMerge
-		*out = make([]apiextensionsv1.JSON, len(*in))
-		for i := range *in {
-			(*in)[i].DeepCopyInto(&(*out)[i])
-		}
+	out := new(ConditionSummary)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CrossNamespaceSourceReference) DeepCopyInto(out *CrossNamespaceSourceReference) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CrossNamespaceSourceReference.
+func (in *CrossNamespaceSourceReference) DeepCopy() *CrossNamespaceSourceReference {
+	if in == nil {
+		return nil
 	}
-	if in.PatchesJSON6902 != nil {
-		in, out := &in.PatchesJSON6902, &out.PatchesJSON6902
-		*out = make([]kustomize.JSON6902Patch, len(*in))
-		for i := range *in {
-			(*in)[i].DeepCopyInto(&(*out)[i])
-		}
+	out := new(CrossNamespaceSourceReference)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Decryption) DeepCopyInto(out *Decryption) {
+	*out = *in
+	if in.SecretRef != nil {
+		in, out := &in.SecretRef, &out.SecretRef
+		*out = new(meta.LocalObjectReference)
+		**out = **in
 	}
-	if in.Images != nil {
-		in, out := &in.Images, &out.Images
-		*out = make([]kustomize.Image, len(*in))
-		copy(*out, *in)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Decryption.
+func (in *Decryption) DeepCopy() *Decryption {
+	if in == nil {
+		return nil
 	}
-	out.SourceRef = in.SourceRef
+	out := new(Decryption)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DependentsSummary) DeepCopyInto(out *DependentsSummary) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DependentsSummary.
+func (in *DependentsSummary) DeepCopy() *DependentsSummary {
+	if in == nil {
+		return nil
+	}
+	out := new(DependentsSummary)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *EndpointHealthCheck) DeepCopyInto(out *EndpointHealthCheck) {
+	*out = *in
 	if in.Timeout != nil {
 		in, out := &in.Timeout, &out.Timeout
 		*out = new(v1.Duration)
@@ -205,117 +264,1622 @@ func (in *KustomizationSpec) DeepCopyInto(out *KustomizationSpec) {
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KustomizationSpec.
-func (in *KustomizationSpec) DeepCopy() *KustomizationSpec {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EndpointHealthCheck.
+func (in *EndpointHealthCheck) DeepCopy() *EndpointHealthCheck {
 	if in == nil {
 		return nil
 	}
-	out := new(KustomizationSpec)
+	out := new(EndpointHealthCheck)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *KustomizationStatus) DeepCopyInto(out *KustomizationStatus) {
+func (in *FirstApplyReview) DeepCopyInto(out *FirstApplyReview) {
 	*out = *in
-	out.ReconcileRequestStatus = in.ReconcileRequestStatus
-	if in.Conditions != nil {
-		in, out := &in.Conditions, &out.Conditions
-		*out = make([]v1.Condition, len(*in))
-		for i := range *in {
-			(*in)[i].DeepCopyInto(&(*out)[i])
-		}
-	}
-	if in.Inventory != nil {
-		in, out := &in.Inventory, &out.Inventory
-		*out = new(ResourceInventory)
-		(*in).DeepCopyInto(*out)
+	if in.Entries != nil {
+		in, out := &in.Entries, &out.Entries
+		*out = make([]ObjectChange, len(*in))
+		copy(*out, *in)
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KustomizationStatus.
-func (in *KustomizationStatus) DeepCopy() *KustomizationStatus {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FirstApplyReview.
+func (in *FirstApplyReview) DeepCopy() *FirstApplyReview {
 	if in == nil {
 		return nil
 	}
-	out := new(KustomizationStatus)
+	out := new(FirstApplyReview)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *PostBuild) DeepCopyInto(out *PostBuild) {
+func (in *GeneratorArgs) DeepCopyInto(out *GeneratorArgs) {
 	*out = *in
-	if in.Substitute != nil {
-		in, out := &in.Substitute, &out.Substitute
-		*out = make(map[string]string, len(*in))
-		for key, val := range *in {
-			(*out)[key] = val
-		}
+	if in.Literals != nil {
+		in, out := &in.Literals, &out.Literals
+		*out = make([]string, len(*in))
+		copy(*out, *in)
 	}
-	if in.SubstituteFrom != nil {
-		in, out := &in.SubstituteFrom, &out.SubstituteFrom
-		*out = make([]SubstituteReference, len(*in))
+	if in.Envs != nil {
+		in, out := &in.Envs, &out.Envs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Files != nil {
+		in, out := &in.Files, &out.Files
+		*out = make([]string, len(*in))
 		copy(*out, *in)
 	}
+	if in.Options != nil {
+		in, out := &in.Options, &out.Options
+		*out = new(GeneratorOptions)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PostBuild.
-func (in *PostBuild) DeepCopy() *PostBuild {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GeneratorArgs.
+func (in *GeneratorArgs) DeepCopy() *GeneratorArgs {
 	if in == nil {
 		return nil
 	}
-	out := new(PostBuild)
+	out := new(GeneratorArgs)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *ResourceInventory) DeepCopyInto(out *ResourceInventory) {
+func (in *GeneratorOptions) DeepCopyInto(out *GeneratorOptions) {
 	*out = *in
-	if in.Entries != nil {
-		in, out := &in.Entries, &out.Entries
-		*out = make([]ResourceRef, len(*in))
-		copy(*out, *in)
+	if in.Labels != nil {
+		in, out := &in.Labels, &out.Labels
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Annotations != nil {
+		in, out := &in.Annotations, &out.Annotations
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ResourceInventory.
-func (in *ResourceInventory) DeepCopy() *ResourceInventory {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GeneratorOptions.
+func (in *GeneratorOptions) DeepCopy() *GeneratorOptions {
 	if in == nil {
 		return nil
 	}
-	out := new(ResourceInventory)
+	out := new(GeneratorOptions)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *ResourceRef) DeepCopyInto(out *ResourceRef) {
+func (in *HealthCheckOptions) DeepCopyInto(out *HealthCheckOptions) {
 	*out = *in
+	if in.StableFor != nil {
+		in, out := &in.StableFor, &out.StableFor
+		*out = new(v1.Duration)
+		**out = **in
+	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ResourceRef.
-func (in *ResourceRef) DeepCopy() *ResourceRef {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HealthCheckOptions.
+func (in *HealthCheckOptions) DeepCopy() *HealthCheckOptions {
 	if in == nil {
 		return nil
 	}
-	out := new(ResourceRef)
+	out := new(HealthCheckOptions)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *SubstituteReference) DeepCopyInto(out *SubstituteReference) {
+func (in *ImagePolicyOverride) DeepCopyInto(out *ImagePolicyOverride) {
 	*out = *in
+	out.FromImagePolicy = in.FromImagePolicy
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SubstituteReference.
-func (in *SubstituteReference) DeepCopy() *SubstituteReference {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ImagePolicyOverride.
+func (in *ImagePolicyOverride) DeepCopy() *ImagePolicyOverride {
 	if in == nil {
 		return nil
 	}
-	out := new(SubstituteReference)
+	out := new(ImagePolicyOverride)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KubeConfig) DeepCopyInto(out *KubeConfig) {
+	*out = *in
+	out.SecretRef = in.SecretRef
+	if in.ServiceAccountToken != nil {
+		in, out := &in.ServiceAccountToken, &out.ServiceAccountToken
+		*out = new(ServiceAccountToken)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Headers != nil {
+		in, out := &in.Headers, &out.Headers
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.HeadersSecretRef != nil {
+		in, out := &in.HeadersSecretRef, &out.HeadersSecretRef
+		*out = new(meta.LocalObjectReference)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KubeConfig.
+func (in *KubeConfig) DeepCopy() *KubeConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(KubeConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Kustomization) DeepCopyInto(out *Kustomization) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Kustomization.
+func (in *Kustomization) DeepCopy() *Kustomization {
+	if in == nil {
+		return nil
+	}
+	out := new(Kustomization)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *Kustomization) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KustomizationFailure) DeepCopyInto(out *KustomizationFailure) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KustomizationFailure.
+func (in *KustomizationFailure) DeepCopy() *KustomizationFailure {
+	if in == nil {
+		return nil
+	}
+	out := new(KustomizationFailure)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KustomizationGroup) DeepCopyInto(out *KustomizationGroup) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KustomizationGroup.
+func (in *KustomizationGroup) DeepCopy() *KustomizationGroup {
+	if in == nil {
+		return nil
+	}
+	out := new(KustomizationGroup)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *KustomizationGroup) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KustomizationGroupList) DeepCopyInto(out *KustomizationGroupList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]KustomizationGroup, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KustomizationGroupList.
+func (in *KustomizationGroupList) DeepCopy() *KustomizationGroupList {
+	if in == nil {
+		return nil
+	}
+	out := new(KustomizationGroupList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *KustomizationGroupList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KustomizationGroupSpec) DeepCopyInto(out *KustomizationGroupSpec) {
+	*out = *in
+	if in.Kustomizations != nil {
+		in, out := &in.Kustomizations, &out.Kustomizations
+		*out = make([]meta.NamespacedObjectReference, len(*in))
+		copy(*out, *in)
+	}
+	if in.Selector != nil {
+		in, out := &in.Selector, &out.Selector
+		*out = new(v1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KustomizationGroupSpec.
+func (in *KustomizationGroupSpec) DeepCopy() *KustomizationGroupSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(KustomizationGroupSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KustomizationGroupStatus) DeepCopyInto(out *KustomizationGroupStatus) {
+	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]v1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Revisions != nil {
+		in, out := &in.Revisions, &out.Revisions
+		*out = make([]RevisionCount, len(*in))
+		copy(*out, *in)
+	}
+	if in.Failures != nil {
+		in, out := &in.Failures, &out.Failures
+		*out = make([]KustomizationFailure, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KustomizationGroupStatus.
+func (in *KustomizationGroupStatus) DeepCopy() *KustomizationGroupStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(KustomizationGroupStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KustomizationList) DeepCopyInto(out *KustomizationList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]Kustomization, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KustomizationList.
+func (in *KustomizationList) DeepCopy() *KustomizationList {
+	if in == nil {
+		return nil
+	}
+	out := new(KustomizationList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *KustomizationList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KustomizationSet) DeepCopyInto(out *KustomizationSet) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KustomizationSet.
+func (in *KustomizationSet) DeepCopy() *KustomizationSet {
+	if in == nil {
+		return nil
+	}
+	out := new(KustomizationSet)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *KustomizationSet) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KustomizationSetElement) DeepCopyInto(out *KustomizationSetElement) {
+	*out = *in
+	if in.Variables != nil {
+		in, out := &in.Variables, &out.Variables
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.VariablesFrom != nil {
+		in, out := &in.VariablesFrom, &out.VariablesFrom
+		*out = make([]SubstituteReference, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KustomizationSetElement.
+func (in *KustomizationSetElement) DeepCopy() *KustomizationSetElement {
+	if in == nil {
+		return nil
+	}
+	out := new(KustomizationSetElement)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KustomizationSetList) DeepCopyInto(out *KustomizationSetList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]KustomizationSet, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KustomizationSetList.
+func (in *KustomizationSetList) DeepCopy() *KustomizationSetList {
+	if in == nil {
+		return nil
+	}
+	out := new(KustomizationSetList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *KustomizationSetList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KustomizationSetSpec) DeepCopyInto(out *KustomizationSetSpec) {
+	*out = *in
+	if in.Elements != nil {
+		in, out := &in.Elements, &out.Elements
+		*out = make([]KustomizationSetElement, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	in.Template.DeepCopyInto(&out.Template)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KustomizationSetSpec.
+func (in *KustomizationSetSpec) DeepCopy() *KustomizationSetSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(KustomizationSetSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KustomizationSetStatus) DeepCopyInto(out *KustomizationSetStatus) {
+	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]v1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Kustomizations != nil {
+		in, out := &in.Kustomizations, &out.Kustomizations
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Targets != nil {
+		in, out := &in.Targets, &out.Targets
+		*out = make([]KustomizationSetTarget, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KustomizationSetStatus.
+func (in *KustomizationSetStatus) DeepCopy() *KustomizationSetStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(KustomizationSetStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KustomizationSetTarget) DeepCopyInto(out *KustomizationSetTarget) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KustomizationSetTarget.
+func (in *KustomizationSetTarget) DeepCopy() *KustomizationSetTarget {
+	if in == nil {
+		return nil
+	}
+	out := new(KustomizationSetTarget)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KustomizationSpec) DeepCopyInto(out *KustomizationSpec) {
+	*out = *in
+	if in.DependsOn != nil {
+		in, out := &in.DependsOn, &out.DependsOn
+		*out = make([]meta.NamespacedObjectReference, len(*in))
+		copy(*out, *in)
+	}
+	if in.Decryption != nil {
+		in, out := &in.Decryption, &out.Decryption
+		*out = new(Decryption)
+		(*in).DeepCopyInto(*out)
+	}
+	out.Interval = in.Interval
+	if in.RetryInterval != nil {
+		in, out := &in.RetryInterval, &out.RetryInterval
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.ProgressingInterval != nil {
+		in, out := &in.ProgressingInterval, &out.ProgressingInterval
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.RevisionSkewThreshold != nil {
+		in, out := &in.RevisionSkewThreshold, &out.RevisionSkewThreshold
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.KubeConfig != nil {
+		in, out := &in.KubeConfig, &out.KubeConfig
+		*out = new(KubeConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.PostBuild != nil {
+		in, out := &in.PostBuild, &out.PostBuild
+		*out = new(PostBuild)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.PruneOptions != nil {
+		in, out := &in.PruneOptions, &out.PruneOptions
+		*out = new(PruneOptions)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.HealthChecks != nil {
+		in, out := &in.HealthChecks, &out.HealthChecks
+		*out = make([]meta.NamespacedObjectKindReference, len(*in))
+		copy(*out, *in)
+	}
+	if in.EndpointHealthChecks != nil {
+		in, out := &in.EndpointHealthChecks, &out.EndpointHealthChecks
+		*out = make([]EndpointHealthCheck, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Patches != nil {
+		in, out := &in.Patches, &out.Patches
+		*out = make([]kustomize.Patch, len(*in))
+		copy(*out, *in)
+	}
+	if in.PatchOptions != nil {
+		in, out := &in.PatchOptions, &out.PatchOptions
+		*out = new(PatchOptions)
+		**out = **in
+	}
+	if in.PatchesStrategicMerge != nil {
+		in, out := &in.PatchesStrategicMerge, &out.PatchesStrategicMerge
+		*out = make([]apiextensionsv1.JSON, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.PatchesJSON6902 != nil {
+		in, out := &in.PatchesJSON6902, &out.PatchesJSON6902
+		*out = make([]kustomize.JSON6902Patch, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Images != nil {
+		in, out := &in.Images, &out.Images
+		*out = make([]kustomize.Image, len(*in))
+		copy(*out, *in)
+	}
+	if in.ImagePolicies != nil {
+		in, out := &in.ImagePolicies, &out.ImagePolicies
+		*out = make([]ImagePolicyOverride, len(*in))
+		copy(*out, *in)
+	}
+	if in.ConfigMapGenerator != nil {
+		in, out := &in.ConfigMapGenerator, &out.ConfigMapGenerator
+		*out = make([]GeneratorArgs, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.SecretGenerator != nil {
+		in, out := &in.SecretGenerator, &out.SecretGenerator
+		*out = make([]SecretGeneratorArgs, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	out.SourceRef = in.SourceRef
+	if in.Sources != nil {
+		in, out := &in.Sources, &out.Sources
+		*out = make([]SourceMount, len(*in))
+		copy(*out, *in)
+	}
+	if in.ArtifactFilter != nil {
+		in, out := &in.ArtifactFilter, &out.ArtifactFilter
+		*out = new(ArtifactFilter)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Attestation != nil {
+		in, out := &in.Attestation, &out.Attestation
+		*out = new(AttestationPolicy)
+		**out = **in
+	}
+	if in.BuildOptions != nil {
+		in, out := &in.BuildOptions, &out.BuildOptions
+		*out = new(BuildOptions)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.TakeoverFrom != nil {
+		in, out := &in.TakeoverFrom, &out.TakeoverFrom
+		*out = make([]meta.NamespacedObjectReference, len(*in))
+		copy(*out, *in)
+	}
+	if in.TargetNamespaceExemptions != nil {
+		in, out := &in.TargetNamespaceExemptions, &out.TargetNamespaceExemptions
+		*out = make([]kustomize.Selector, len(*in))
+		copy(*out, *in)
+	}
+	if in.Timeout != nil {
+		in, out := &in.Timeout, &out.Timeout
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.ApplyOptions != nil {
+		in, out := &in.ApplyOptions, &out.ApplyOptions
+		*out = new(ApplyOptions)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.OwnerLabels != nil {
+		in, out := &in.OwnerLabels, &out.OwnerLabels
+		*out = new(OwnerLabels)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.WaitOptions != nil {
+		in, out := &in.WaitOptions, &out.WaitOptions
+		*out = new(WaitOptions)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.HealthCheckOptions != nil {
+		in, out := &in.HealthCheckOptions, &out.HealthCheckOptions
+		*out = new(HealthCheckOptions)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KustomizationSpec.
+func (in *KustomizationSpec) DeepCopy() *KustomizationSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(KustomizationSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KustomizationStatus) DeepCopyInto(out *KustomizationStatus) {
+	*out = *in
+	out.ReconcileRequestStatus = in.ReconcileRequestStatus
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]v1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.LastAttemptedFailures != nil {
+		in, out := &in.LastAttemptedFailures, &out.LastAttemptedFailures
+		*out = make([]ObjectFailure, len(*in))
+		copy(*out, *in)
+	}
+	if in.RevisionSkew != nil {
+		in, out := &in.RevisionSkew, &out.RevisionSkew
+		*out = new(RevisionSkew)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ApplyCheckpoint != nil {
+		in, out := &in.ApplyCheckpoint, &out.ApplyCheckpoint
+		*out = new(ApplyCheckpoint)
+		**out = **in
+	}
+	if in.Inventory != nil {
+		in, out := &in.Inventory, &out.Inventory
+		*out = new(ResourceInventory)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.PendingPrune != nil {
+		in, out := &in.PendingPrune, &out.PendingPrune
+		*out = new(PendingPrune)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.FirstApplyReview != nil {
+		in, out := &in.FirstApplyReview, &out.FirstApplyReview
+		*out = new(FirstApplyReview)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.PruneReport != nil {
+		in, out := &in.PruneReport, &out.PruneReport
+		*out = new(PruneReport)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.DeferredApplies != nil {
+		in, out := &in.DeferredApplies, &out.DeferredApplies
+		*out = make([]ResourceRef, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.HeldForTakeover != nil {
+		in, out := &in.HeldForTakeover, &out.HeldForTakeover
+		*out = make([]ResourceRef, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.TakeoverClaims != nil {
+		in, out := &in.TakeoverClaims, &out.TakeoverClaims
+		*out = make([]ResourceRef, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.ObjectPolicies != nil {
+		in, out := &in.ObjectPolicies, &out.ObjectPolicies
+		*out = make([]ObjectPolicy, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.ObservedToolchain != nil {
+		in, out := &in.ObservedToolchain, &out.ObservedToolchain
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Warnings != nil {
+		in, out := &in.Warnings, &out.Warnings
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.SlowestApplies != nil {
+		in, out := &in.SlowestApplies, &out.SlowestApplies
+		*out = make([]ApplyDuration, len(*in))
+		copy(*out, *in)
+	}
+	if in.TruncatedFields != nil {
+		in, out := &in.TruncatedFields, &out.TruncatedFields
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Dependents != nil {
+		in, out := &in.Dependents, &out.Dependents
+		*out = new(DependentsSummary)
+		**out = **in
+	}
+	if in.Summary != nil {
+		in, out := &in.Summary, &out.Summary
+		*out = new(StatusSummary)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KustomizationStatus.
+func (in *KustomizationStatus) DeepCopy() *KustomizationStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(KustomizationStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KustomizationTemplate) DeepCopyInto(out *KustomizationTemplate) {
+	*out = *in
+	if in.Labels != nil {
+		in, out := &in.Labels, &out.Labels
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Annotations != nil {
+		in, out := &in.Annotations, &out.Annotations
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	in.Spec.DeepCopyInto(&out.Spec)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KustomizationTemplate.
+func (in *KustomizationTemplate) DeepCopy() *KustomizationTemplate {
+	if in == nil {
+		return nil
+	}
+	out := new(KustomizationTemplate)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LegacySortOptions) DeepCopyInto(out *LegacySortOptions) {
+	*out = *in
+	if in.OrderFirst != nil {
+		in, out := &in.OrderFirst, &out.OrderFirst
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.OrderLast != nil {
+		in, out := &in.OrderLast, &out.OrderLast
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LegacySortOptions.
+func (in *LegacySortOptions) DeepCopy() *LegacySortOptions {
+	if in == nil {
+		return nil
+	}
+	out := new(LegacySortOptions)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ObjectChange) DeepCopyInto(out *ObjectChange) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ObjectChange.
+func (in *ObjectChange) DeepCopy() *ObjectChange {
+	if in == nil {
+		return nil
+	}
+	out := new(ObjectChange)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ObjectFailure) DeepCopyInto(out *ObjectFailure) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ObjectFailure.
+func (in *ObjectFailure) DeepCopy() *ObjectFailure {
+	if in == nil {
+		return nil
+	}
+	out := new(ObjectFailure)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ObjectPolicy) DeepCopyInto(out *ObjectPolicy) {
+	*out = *in
+	if in.Entries != nil {
+		in, out := &in.Entries, &out.Entries
+		*out = make([]ResourceRef, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ObjectPolicy.
+func (in *ObjectPolicy) DeepCopy() *ObjectPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(ObjectPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *OpenAPISchema) DeepCopyInto(out *OpenAPISchema) {
+	*out = *in
+	if in.ConfigMapRef != nil {
+		in, out := &in.ConfigMapRef, &out.ConfigMapRef
+		*out = new(meta.LocalObjectReference)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OpenAPISchema.
+func (in *OpenAPISchema) DeepCopy() *OpenAPISchema {
+	if in == nil {
+		return nil
+	}
+	out := new(OpenAPISchema)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *OwnerLabels) DeepCopyInto(out *OwnerLabels) {
+	*out = *in
+	if in.Labels != nil {
+		in, out := &in.Labels, &out.Labels
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OwnerLabels.
+func (in *OwnerLabels) DeepCopy() *OwnerLabels {
+	if in == nil {
+		return nil
+	}
+	out := new(OwnerLabels)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PatchOptions) DeepCopyInto(out *PatchOptions) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PatchOptions.
+func (in *PatchOptions) DeepCopy() *PatchOptions {
+	if in == nil {
+		return nil
+	}
+	out := new(PatchOptions)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PendingPrune) DeepCopyInto(out *PendingPrune) {
+	*out = *in
+	if in.Entries != nil {
+		in, out := &in.Entries, &out.Entries
+		*out = make([]ResourceRef, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PendingPrune.
+func (in *PendingPrune) DeepCopy() *PendingPrune {
+	if in == nil {
+		return nil
+	}
+	out := new(PendingPrune)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Pipeline) DeepCopyInto(out *Pipeline) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Pipeline.
+func (in *Pipeline) DeepCopy() *Pipeline {
+	if in == nil {
+		return nil
+	}
+	out := new(Pipeline)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *Pipeline) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PipelineList) DeepCopyInto(out *PipelineList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]Pipeline, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PipelineList.
+func (in *PipelineList) DeepCopy() *PipelineList {
+	if in == nil {
+		return nil
+	}
+	out := new(PipelineList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *PipelineList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PipelineSpec) DeepCopyInto(out *PipelineSpec) {
+	*out = *in
+	if in.Stages != nil {
+		in, out := &in.Stages, &out.Stages
+		*out = make([]PipelineStage, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PipelineSpec.
+func (in *PipelineSpec) DeepCopy() *PipelineSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(PipelineSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PipelineStage) DeepCopyInto(out *PipelineStage) {
+	*out = *in
+	out.KustomizationRef = in.KustomizationRef
+	if in.SoakTime != nil {
+		in, out := &in.SoakTime, &out.SoakTime
+		*out = new(v1.Duration)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PipelineStage.
+func (in *PipelineStage) DeepCopy() *PipelineStage {
+	if in == nil {
+		return nil
+	}
+	out := new(PipelineStage)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PipelineStageStatus) DeepCopyInto(out *PipelineStageStatus) {
+	*out = *in
+	if in.ReadySince != nil {
+		in, out := &in.ReadySince, &out.ReadySince
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PipelineStageStatus.
+func (in *PipelineStageStatus) DeepCopy() *PipelineStageStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(PipelineStageStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PipelineStatus) DeepCopyInto(out *PipelineStatus) {
+	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]v1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Stages != nil {
+		in, out := &in.Stages, &out.Stages
+		*out = make([]PipelineStageStatus, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PipelineStatus.
+func (in *PipelineStatus) DeepCopy() *PipelineStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(PipelineStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PostBuild) DeepCopyInto(out *PostBuild) {
+	*out = *in
+	if in.Substitute != nil {
+		in, out := &in.Substitute, &out.Substitute
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.SubstituteFrom != nil {
+		in, out := &in.SubstituteFrom, &out.SubstituteFrom
+		*out = make([]SubstituteReference, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PostBuild.
+func (in *PostBuild) DeepCopy() *PostBuild {
+	if in == nil {
+		return nil
+	}
+	out := new(PostBuild)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PruneDisruptionBudget) DeepCopyInto(out *PruneDisruptionBudget) {
+	*out = *in
+	out.MaxDeletions = in.MaxDeletions
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PruneDisruptionBudget.
+func (in *PruneDisruptionBudget) DeepCopy() *PruneDisruptionBudget {
+	if in == nil {
+		return nil
+	}
+	out := new(PruneDisruptionBudget)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PruneHook) DeepCopyInto(out *PruneHook) {
+	*out = *in
+	out.Target = in.Target
+	if in.HTTP != nil {
+		in, out := &in.HTTP, &out.HTTP
+		*out = new(PruneHookHTTP)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Job != nil {
+		in, out := &in.Job, &out.Job
+		*out = new(PruneHookJob)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Timeout != nil {
+		in, out := &in.Timeout, &out.Timeout
+		*out = new(v1.Duration)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PruneHook.
+func (in *PruneHook) DeepCopy() *PruneHook {
+	if in == nil {
+		return nil
+	}
+	out := new(PruneHook)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PruneHookHTTP) DeepCopyInto(out *PruneHookHTTP) {
+	*out = *in
+	if in.SecretRef != nil {
+		in, out := &in.SecretRef, &out.SecretRef
+		*out = new(meta.LocalObjectReference)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PruneHookHTTP.
+func (in *PruneHookHTTP) DeepCopy() *PruneHookHTTP {
+	if in == nil {
+		return nil
+	}
+	out := new(PruneHookHTTP)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PruneHookJob) DeepCopyInto(out *PruneHookJob) {
+	*out = *in
+	if in.Command != nil {
+		in, out := &in.Command, &out.Command
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Args != nil {
+		in, out := &in.Args, &out.Args
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PruneHookJob.
+func (in *PruneHookJob) DeepCopy() *PruneHookJob {
+	if in == nil {
+		return nil
+	}
+	out := new(PruneHookJob)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PruneOptions) DeepCopyInto(out *PruneOptions) {
+	*out = *in
+	if in.ApprovalThreshold != nil {
+		in, out := &in.ApprovalThreshold, &out.ApprovalThreshold
+		*out = new(intstr.IntOrString)
+		**out = **in
+	}
+	if in.DisruptionBudget != nil {
+		in, out := &in.DisruptionBudget, &out.DisruptionBudget
+		*out = new(PruneDisruptionBudget)
+		**out = **in
+	}
+	if in.Hooks != nil {
+		in, out := &in.Hooks, &out.Hooks
+		*out = make([]PruneHook, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.FinishedJobsTTL != nil {
+		in, out := &in.FinishedJobsTTL, &out.FinishedJobsTTL
+		*out = new(v1.Duration)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PruneOptions.
+func (in *PruneOptions) DeepCopy() *PruneOptions {
+	if in == nil {
+		return nil
+	}
+	out := new(PruneOptions)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PruneReport) DeepCopyInto(out *PruneReport) {
+	*out = *in
+	if in.Entries != nil {
+		in, out := &in.Entries, &out.Entries
+		*out = make([]ResourceRef, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PruneReport.
+func (in *PruneReport) DeepCopy() *PruneReport {
+	if in == nil {
+		return nil
+	}
+	out := new(PruneReport)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ResourceInventory) DeepCopyInto(out *ResourceInventory) {
+	*out = *in
+	if in.Entries != nil {
+		in, out := &in.Entries, &out.Entries
+		*out = make([]ResourceRef, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ResourceInventory.
+func (in *ResourceInventory) DeepCopy() *ResourceInventory {
+	if in == nil {
+		return nil
+	}
+	out := new(ResourceInventory)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ResourceRef) DeepCopyInto(out *ResourceRef) {
+	*out = *in
+	if in.Images != nil {
+		in, out := &in.Images, &out.Images
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ResourceRef.
+func (in *ResourceRef) DeepCopy() *ResourceRef {
+	if in == nil {
+		return nil
+	}
+	out := new(ResourceRef)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RevisionCount) DeepCopyInto(out *RevisionCount) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RevisionCount.
+func (in *RevisionCount) DeepCopy() *RevisionCount {
+	if in == nil {
+		return nil
+	}
+	out := new(RevisionCount)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RevisionSkew) DeepCopyInto(out *RevisionSkew) {
+	*out = *in
+	in.Since.DeepCopyInto(&out.Since)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RevisionSkew.
+func (in *RevisionSkew) DeepCopy() *RevisionSkew {
+	if in == nil {
+		return nil
+	}
+	out := new(RevisionSkew)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SecretGeneratorArgs) DeepCopyInto(out *SecretGeneratorArgs) {
+	*out = *in
+	in.GeneratorArgs.DeepCopyInto(&out.GeneratorArgs)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SecretGeneratorArgs.
+func (in *SecretGeneratorArgs) DeepCopy() *SecretGeneratorArgs {
+	if in == nil {
+		return nil
+	}
+	out := new(SecretGeneratorArgs)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ServiceAccountToken) DeepCopyInto(out *ServiceAccountToken) {
+	*out = *in
+	if in.Audiences != nil {
+		in, out := &in.Audiences, &out.Audiences
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.ExpirationSeconds != nil {
+		in, out := &in.ExpirationSeconds, &out.ExpirationSeconds
+		*out = new(int64)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ServiceAccountToken.
+func (in *ServiceAccountToken) DeepCopy() *ServiceAccountToken {
+	if in == nil {
+		return nil
+	}
+	out := new(ServiceAccountToken)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SortOptions) DeepCopyInto(out *SortOptions) {
+	*out = *in
+	if in.LegacySortOptions != nil {
+		in, out := &in.LegacySortOptions, &out.LegacySortOptions
+		*out = new(LegacySortOptions)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SortOptions.
+func (in *SortOptions) DeepCopy() *SortOptions {
+	if in == nil {
+		return nil
+	}
+	out := new(SortOptions)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SourceMount) DeepCopyInto(out *SourceMount) {
+	*out = *in
+	out.SourceRef = in.SourceRef
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SourceMount.
+func (in *SourceMount) DeepCopy() *SourceMount {
+	if in == nil {
+		return nil
+	}
+	out := new(SourceMount)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *StatusSummary) DeepCopyInto(out *StatusSummary) {
+	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]ConditionSummary, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new StatusSummary.
+func (in *StatusSummary) DeepCopy() *StatusSummary {
+	if in == nil {
+		return nil
+	}
+	out := new(StatusSummary)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SubstituteReference) DeepCopyInto(out *SubstituteReference) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SubstituteReference.
+func (in *SubstituteReference) DeepCopy() *SubstituteReference {
+	if in == nil {
+		return nil
+	}
+	out := new(SubstituteReference)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Tenant) DeepCopyInto(out *Tenant) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Tenant.
+func (in *Tenant) DeepCopy() *Tenant {
+	if in == nil {
+		return nil
+	}
+	out := new(Tenant)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *Tenant) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TenantList) DeepCopyInto(out *TenantList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]Tenant, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TenantList.
+func (in *TenantList) DeepCopy() *TenantList {
+	if in == nil {
+		return nil
+	}
+	out := new(TenantList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *TenantList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TenantSpec) DeepCopyInto(out *TenantSpec) {
+	*out = *in
+	in.Kustomization.DeepCopyInto(&out.Kustomization)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TenantSpec.
+func (in *TenantSpec) DeepCopy() *TenantSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(TenantSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TenantStatus) DeepCopyInto(out *TenantStatus) {
+	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]v1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Inventory != nil {
+		in, out := &in.Inventory, &out.Inventory
+		*out = new(ResourceInventory)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TenantStatus.
+func (in *TenantStatus) DeepCopy() *TenantStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(TenantStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *WaitOptions) DeepCopyInto(out *WaitOptions) {
+	*out = *in
+	if in.Selectors != nil {
+		in, out := &in.Selectors, &out.Selectors
+		*out = make([]kustomize.Selector, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new WaitOptions.
+func (in *WaitOptions) DeepCopy() *WaitOptions {
+	if in == nil {
+		return nil
+	}
+	out := new(WaitOptions)
 	in.DeepCopyInto(out)
 	return out
 }
@@ -0,0 +1,289 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"crypto"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	ecr "github.com/awslabs/amazon-ecr-credential-helper/ecr-login"
+	"github.com/chrismellard/docker-credential-acr-env/pkg/credhelper"
+	"github.com/google/go-containerregistry/pkg/authn"
+	kauth "github.com/google/go-containerregistry/pkg/authn/kubernetes"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/google"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/sigstore/cosign/pkg/cosign"
+	"github.com/sigstore/cosign/pkg/oci"
+	ociremote "github.com/sigstore/cosign/pkg/oci/remote"
+	sigs "github.com/sigstore/cosign/pkg/signature"
+	"github.com/sigstore/sigstore/pkg/signature"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	sourcev1 "github.com/fluxcd/source-controller/api/v1beta2"
+
+	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta2"
+)
+
+const (
+	// AttestationPublicKey is the Secret key holding the PEM-encoded cosign public key.
+	AttestationPublicKey = "cosign.pub"
+
+	// inTotoPayloadType is the DSSE payload type of the in-toto statements.
+	inTotoPayloadType = "application/vnd.in-toto+json"
+
+	// slsaProvenancePredicateType is the prefix of the SLSA provenance predicate types.
+	slsaProvenancePredicateType = "https://slsa.dev/provenance/"
+
+	// attestationTimeout is the timeout of the attestations verification,
+	// including the requests made to the registry.
+	attestationTimeout = time.Minute
+)
+
+// amazonKeychain and azureKeychain resolve the registry credentials with
+// the workload identity of the controller on EKS and AKS.
+var (
+	amazonKeychain = authn.NewKeychainFromHelper(ecr.NewECRHelper(ecr.WithLogger(io.Discard)))
+	azureKeychain  = authn.NewKeychainFromHelper(credhelper.NewACRCredentialsHelper())
+)
+
+// dsseEnvelope holds the fields of the Dead Simple Signing Envelope of
+// a cosign attestation that are read once its signature is verified.
+type dsseEnvelope struct {
+	PayloadType string `json:"payloadType"`
+	Payload     string `json:"payload"`
+}
+
+// inTotoStatement holds the fields of an in-toto statement with
+// a SLSA provenance predicate that are checked against the policy.
+type inTotoStatement struct {
+	PredicateType string `json:"predicateType"`
+	Predicate     struct {
+		Builder struct {
+			ID string `json:"id"`
+		} `json:"builder"`
+		Invocation struct {
+			ConfigSource struct {
+				URI string `json:"uri"`
+			} `json:"configSource"`
+		} `json:"invocation"`
+	} `json:"predicate"`
+}
+
+// verifyAttestation checks that the artifact of the OCIRepository source has
+// an attestation signed with the policy key that satisfies the policy.
+func (r *KustomizationReconciler) verifyAttestation(ctx context.Context, kustomization kustomizev1.Kustomization, source sourcev1.Source) error {
+	policy := kustomization.Spec.Attestation
+	if policy == nil {
+		return nil
+	}
+
+	repository, ok := source.(*sourcev1.OCIRepository)
+	if !ok {
+		return fmt.Errorf("attestations can only be verified for %s sources", sourcev1.OCIRepositoryKind)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, attestationTimeout)
+	defer cancel()
+
+	verifier, err := r.getAttestationVerifier(ctx, kustomization)
+	if err != nil {
+		return err
+	}
+
+	digest, err := artifactDigest(repository.GetArtifact().Revision)
+	if err != nil {
+		return err
+	}
+
+	url := strings.TrimPrefix(repository.Spec.URL, sourcev1.OCIRepositoryPrefix)
+	ref, err := name.ParseReference(fmt.Sprintf("%s@sha256:%s", url, digest))
+	if err != nil {
+		return fmt.Errorf("invalid OCI repository URL '%s': %w", repository.Spec.URL, err)
+	}
+
+	auth, err := r.getRegistryAuthenticator(ctx, repository, ref)
+	if err != nil {
+		return err
+	}
+
+	co := &cosign.CheckOpts{
+		ClaimVerifier: cosign.IntotoSubjectClaimVerifier,
+		SigVerifier:   verifier,
+		RegistryClientOpts: []ociremote.Option{
+			ociremote.WithRemoteOptions(remote.WithContext(ctx), remote.WithAuth(auth)),
+		},
+	}
+	attestations, _, err := cosign.VerifyImageAttestations(ctx, ref, co)
+	if err != nil {
+		return fmt.Errorf("no attestation of '%s' is signed with the policy key: %w", ref, err)
+	}
+
+	return verifyAttestations(attestations, digest, *policy)
+}
+
+// getAttestationVerifier returns the verifier of the cosign public key of the policy Secret.
+func (r *KustomizationReconciler) getAttestationVerifier(ctx context.Context, kustomization kustomizev1.Kustomization) (signature.Verifier, error) {
+	secretName := types.NamespacedName{
+		Namespace: kustomization.GetNamespace(),
+		Name:      kustomization.Spec.Attestation.SecretRef.Name,
+	}
+
+	var secret corev1.Secret
+	if err := r.Client.Get(ctx, secretName, &secret); err != nil {
+		return nil, fmt.Errorf("unable to get attestation Secret '%s': %w", secretName, err)
+	}
+
+	data, ok := secret.Data[AttestationPublicKey]
+	if !ok {
+		return nil, fmt.Errorf("'%s' not found in attestation Secret '%s'", AttestationPublicKey, secretName)
+	}
+
+	verifier, err := sigs.LoadPublicKeyRaw(data, crypto.SHA256)
+	if err != nil {
+		return nil, fmt.Errorf("invalid public key in attestation Secret '%s': %w", secretName, err)
+	}
+	return verifier, nil
+}
+
+// getRegistryAuthenticator returns the authenticator of the OCIRepository registry:
+// the credentials of its docker config Secret for the generic provider, or else
+// the credentials obtained with the workload identity of the controller.
+func (r *KustomizationReconciler) getRegistryAuthenticator(ctx context.Context,
+	repository *sourcev1.OCIRepository, ref name.Reference) (authn.Authenticator, error) {
+	var keychain authn.Keychain
+	switch repository.Spec.Provider {
+	case sourcev1.AmazonOCIProvider:
+		keychain = amazonKeychain
+	case sourcev1.AzureOCIProvider:
+		keychain = azureKeychain
+	case sourcev1.GoogleOCIProvider:
+		keychain = google.Keychain
+	default:
+		if repository.Spec.SecretRef == nil {
+			return authn.Anonymous, nil
+		}
+
+		secretName := types.NamespacedName{
+			Namespace: repository.GetNamespace(),
+			Name:      repository.Spec.SecretRef.Name,
+		}
+		var secret corev1.Secret
+		if err := r.Client.Get(ctx, secretName, &secret); err != nil {
+			return nil, fmt.Errorf("unable to get registry Secret '%s': %w", secretName, err)
+		}
+
+		var err error
+		keychain, err = kauth.NewFromPullSecrets(ctx, []corev1.Secret{secret})
+		if err != nil {
+			return nil, fmt.Errorf("invalid registry Secret '%s': %w", secretName, err)
+		}
+	}
+
+	auth, err := keychain.Resolve(ref.Context())
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the registry credentials with the '%s' provider: %w", repository.Spec.Provider, err)
+	}
+	return auth, nil
+}
+
+// artifactDigest returns the sha256 hex digest contained in the
+// revision of an OCIRepository artifact, e.g. '<tag>/<digest>'.
+func artifactDigest(revision string) (string, error) {
+	digest := revision
+	if i := strings.LastIndexAny(digest, "/@:"); i != -1 {
+		digest = digest[i+1:]
+	}
+	if _, err := hex.DecodeString(digest); err != nil || len(digest) != sha256.Size*2 {
+		return "", fmt.Errorf("revision '%s' doesn't contain a sha256 digest", revision)
+	}
+	return digest, nil
+}
+
+// verifyAttestations returns an error if none of the attestations, whose signature
+// and subject have been verified by cosign, satisfies the policy.
+func verifyAttestations(attestations []oci.Signature, digest string, policy kustomizev1.AttestationPolicy) error {
+	if len(attestations) == 0 {
+		return fmt.Errorf("no attestations found for digest 'sha256:%s'", digest)
+	}
+
+	var errs []string
+	for _, attestation := range attestations {
+		err := verifyStatement(attestation, policy)
+		if err == nil {
+			return nil
+		}
+		errs = append(errs, err.Error())
+	}
+	return fmt.Errorf("none of the %d attestations satisfies the policy: %s", len(attestations), strings.Join(errs, "; "))
+}
+
+func verifyStatement(attestation oci.Signature, policy kustomizev1.AttestationPolicy) error {
+	data, err := attestation.Payload()
+	if err != nil {
+		return fmt.Errorf("invalid attestation: %w", err)
+	}
+
+	var envelope dsseEnvelope
+	if err := json.Unmarshal(data, &envelope); err != nil {
+		return fmt.Errorf("invalid attestation envelope: %w", err)
+	}
+	if envelope.PayloadType != inTotoPayloadType {
+		return fmt.Errorf("unsupported payload type '%s'", envelope.PayloadType)
+	}
+	payload, err := base64.StdEncoding.DecodeString(envelope.Payload)
+	if err != nil {
+		return fmt.Errorf("invalid payload: %w", err)
+	}
+
+	var statement inTotoStatement
+	if err := json.Unmarshal(payload, &statement); err != nil {
+		return fmt.Errorf("invalid in-toto statement: %w", err)
+	}
+	if !strings.HasPrefix(statement.PredicateType, slsaProvenancePredicateType) {
+		return fmt.Errorf("unsupported predicate type '%s'", statement.PredicateType)
+	}
+
+	if policy.BuilderID != "" && statement.Predicate.Builder.ID != policy.BuilderID {
+		return fmt.Errorf("builder '%s' doesn't match '%s'", statement.Predicate.Builder.ID, policy.BuilderID)
+	}
+
+	uri := statement.Predicate.Invocation.ConfigSource.URI
+	repository, ref, _ := strings.Cut(uri, "@")
+	if policy.Repository != "" && normalizeRepositoryURI(repository) != normalizeRepositoryURI(policy.Repository) {
+		return fmt.Errorf("config source '%s' doesn't match repository '%s'", uri, policy.Repository)
+	}
+	if policy.Branch != "" && ref != "refs/heads/"+policy.Branch {
+		return fmt.Errorf("config source '%s' doesn't match branch '%s'", uri, policy.Branch)
+	}
+
+	return nil
+}
+
+// normalizeRepositoryURI strips the 'git+' scheme prefix and the '.git' suffix of a repository URI.
+func normalizeRepositoryURI(uri string) string {
+	return strings.TrimSuffix(strings.TrimPrefix(uri, "git+"), ".git")
+}
@@ -0,0 +1,217 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/sigstore/cosign/pkg/oci"
+	"github.com/sigstore/cosign/pkg/oci/static"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/fluxcd/pkg/apis/meta"
+
+	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta2"
+)
+
+const testArtifactDigest = "4e1243bd22c66e76c2ba9eddc1f91394e57f9f83f4c6d0e2f6ba2b3e6f0b8c7a"
+
+func testProvenance(digest, builder, uri string) []byte {
+	return []byte(fmt.Sprintf(`{
+  "_type": "https://in-toto.io/Statement/v0.1",
+  "predicateType": "https://slsa.dev/provenance/v0.2",
+  "subject": [{"name": "ghcr.io/org/manifests", "digest": {"sha256": "%s"}}],
+  "predicate": {
+    "builder": {"id": "%s"},
+    "invocation": {"configSource": {"uri": "%s"}}
+  }
+}`, digest, builder, uri))
+}
+
+func newAttestation(t *testing.T, statement []byte) oci.Signature {
+	t.Helper()
+	envelope, err := json.Marshal(dsseEnvelope{
+		PayloadType: inTotoPayloadType,
+		Payload:     base64.StdEncoding.EncodeToString(statement),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	attestation, err := static.NewAttestation(envelope)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return attestation
+}
+
+func Test_verifyAttestations(t *testing.T) {
+	builder := "https://github.com/slsa-framework/slsa-github-generator/.github/workflows/generator_generic_slsa3.yml@refs/tags/v1.2.0"
+	provenance := testProvenance(testArtifactDigest, builder, "git+https://github.com/org/manifests@refs/heads/main")
+	policy := kustomizev1.AttestationPolicy{
+		BuilderID:  builder,
+		Repository: "https://github.com/org/manifests",
+		Branch:     "main",
+	}
+
+	tests := []struct {
+		name         string
+		attestations []oci.Signature
+		policy       kustomizev1.AttestationPolicy
+		wantErr      string
+	}{
+		{
+			name:         "valid",
+			attestations: []oci.Signature{newAttestation(t, provenance)},
+			policy:       policy,
+		},
+		{
+			name:    "no attestations",
+			policy:  policy,
+			wantErr: "no attestations found",
+		},
+		{
+			name: "one of many satisfies the policy",
+			attestations: []oci.Signature{
+				newAttestation(t, testProvenance(testArtifactDigest, "https://example.com/builder", "")),
+				newAttestation(t, provenance),
+			},
+			policy: policy,
+		},
+		{
+			name: "not a provenance",
+			attestations: []oci.Signature{newAttestation(t,
+				[]byte(`{"predicateType": "https://cosign.sigstore.dev/attestation/vuln/v1"}`))},
+			policy:  policy,
+			wantErr: "unsupported predicate type",
+		},
+		{
+			name: "builder mismatch",
+			attestations: []oci.Signature{newAttestation(t,
+				testProvenance(testArtifactDigest, "https://example.com/builder", "git+https://github.com/org/manifests@refs/heads/main"))},
+			policy:  policy,
+			wantErr: "builder 'https://example.com/builder' doesn't match",
+		},
+		{
+			name: "repository mismatch",
+			attestations: []oci.Signature{newAttestation(t,
+				testProvenance(testArtifactDigest, builder, "git+https://github.com/fork/manifests@refs/heads/main"))},
+			policy:  policy,
+			wantErr: "doesn't match repository",
+		},
+		{
+			name: "branch mismatch",
+			attestations: []oci.Signature{newAttestation(t,
+				testProvenance(testArtifactDigest, builder, "git+https://github.com/org/manifests@refs/heads/dev"))},
+			policy:  policy,
+			wantErr: "doesn't match branch 'main'",
+		},
+		{
+			name: "empty policy only checks the predicate type",
+			attestations: []oci.Signature{newAttestation(t,
+				testProvenance(testArtifactDigest, "https://example.com/builder", "git+https://github.com/fork/manifests@refs/heads/dev"))},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			err := verifyAttestations(tt.attestations, testArtifactDigest, tt.policy)
+			if tt.wantErr == "" {
+				g.Expect(err).NotTo(HaveOccurred())
+				return
+			}
+			g.Expect(err).To(HaveOccurred())
+			g.Expect(err.Error()).To(ContainSubstring(tt.wantErr))
+		})
+	}
+}
+
+func Test_artifactDigest(t *testing.T) {
+	g := NewWithT(t)
+
+	for _, revision := range []string{
+		testArtifactDigest,
+		"sha256:" + testArtifactDigest,
+		"v1.0.0/" + testArtifactDigest,
+		"v1.0.0@sha256:" + testArtifactDigest,
+	} {
+		digest, err := artifactDigest(revision)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(digest).To(Equal(testArtifactDigest))
+	}
+
+	_, err := artifactDigest("main/5394cb7f48332b2de7c17dd8b8384bbc84b7e738")
+	g.Expect(err).To(HaveOccurred())
+}
+
+func TestKustomizationReconciler_getAttestationVerifier(t *testing.T) {
+	g := NewWithT(t)
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	g.Expect(err).NotTo(HaveOccurred())
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	scheme := runtime.NewScheme()
+	g.Expect(corev1.AddToScheme(scheme)).To(Succeed())
+	r := &KustomizationReconciler{
+		Client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+			&corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "apps", Name: "cosign"},
+				Data: map[string][]byte{
+					AttestationPublicKey: pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}),
+				},
+			},
+			&corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "apps", Name: "invalid"},
+				Data:       map[string][]byte{AttestationPublicKey: []byte("not a key")},
+			},
+		).Build(),
+	}
+
+	kustomization := kustomizev1.Kustomization{ObjectMeta: metav1.ObjectMeta{Namespace: "apps", Name: "app"}}
+	for name, wantErr := range map[string]string{
+		"cosign":  "",
+		"invalid": "invalid public key",
+		"missing": "unable to get attestation Secret",
+	} {
+		kustomization.Spec.Attestation = &kustomizev1.AttestationPolicy{
+			SecretRef: meta.LocalObjectReference{Name: name},
+		}
+		verifier, err := r.getAttestationVerifier(context.TODO(), kustomization)
+		if wantErr == "" {
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(verifier).NotTo(BeNil())
+			continue
+		}
+		g.Expect(err).To(MatchError(ContainSubstring(wantErr)), fmt.Sprintf("secret %s", name))
+	}
+}
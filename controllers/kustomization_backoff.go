@@ -0,0 +1,263 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// BackoffStore persists the next retry of the failing Kustomizations in a ConfigMap,
+// so that after a restart the controller waits for the scheduled retries
+// instead of reconciling all the failing Kustomizations at once.
+type BackoffStore struct {
+	client        client.Client
+	reader        client.Reader
+	configMap     types.NamespacedName
+	flushInterval time.Duration
+	now           func() time.Time
+
+	mu       sync.Mutex
+	entries  map[string]backoffEntry
+	restored map[string]bool
+	changed  map[string]bool
+}
+
+// backoffEntry is the retry state of a Kustomization.
+type backoffEntry struct {
+	// Revision is the source revision of the failed reconciliation.
+	Revision string `json:"revision"`
+
+	// Failures is the number of consecutive failures.
+	Failures int `json:"failures"`
+
+	// RetryAt is the time of the next retry.
+	RetryAt time.Time `json:"retryAt"`
+}
+
+// NewBackoffStore returns a BackoffStore for the given ConfigMap, the changes are written
+// with the client at the flush interval, and the ConfigMap is read with the given reader
+// which must not be backed by the cache.
+func NewBackoffStore(c client.Client, reader client.Reader, configMap types.NamespacedName, flushInterval time.Duration) *BackoffStore {
+	return &BackoffStore{
+		client:        c,
+		reader:        reader,
+		configMap:     configMap,
+		flushInterval: flushInterval,
+		now:           time.Now,
+		entries:       make(map[string]backoffEntry),
+		restored:      make(map[string]bool),
+		changed:       make(map[string]bool),
+	}
+}
+
+// backoffKey returns the ConfigMap key of a Kustomization, the namespace
+// can't contain dots so the first dot separates it from the name.
+func backoffKey(key types.NamespacedName) string {
+	return fmt.Sprintf("%s.%s", key.Namespace, key.Name)
+}
+
+// Load restores the retry state persisted by the previous controller run.
+func (s *BackoffStore) Load(ctx context.Context) error {
+	var cm corev1.ConfigMap
+	if err := s.reader.Get(ctx, s.configMap, &cm); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for key, value := range cm.Data {
+		var entry backoffEntry
+		if !strings.Contains(key, ".") || json.Unmarshal([]byte(value), &entry) != nil {
+			continue
+		}
+		s.entries[key] = entry
+		s.restored[key] = true
+	}
+	return nil
+}
+
+// Delay returns the time left until the retry of the Kustomization scheduled before the
+// controller restart, it returns zero if the source revision changed since the failure.
+// A Kustomization is delayed at most once after the restart.
+func (s *BackoffStore) Delay(key types.NamespacedName, revision string) time.Duration {
+	if s == nil {
+		return 0
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	k := backoffKey(key)
+	if !s.restored[k] {
+		return 0
+	}
+	delete(s.restored, k)
+
+	entry := s.entries[k]
+	if entry.Revision != revision {
+		return 0
+	}
+	if delay := entry.RetryAt.Sub(s.now()); delay > 0 {
+		return delay
+	}
+	return 0
+}
+
+// Record stores the failure of the Kustomization at the given revision,
+// the next retry being scheduled after the given delay.
+func (s *BackoffStore) Record(key types.NamespacedName, revision string, delay time.Duration) {
+	if s == nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	k := backoffKey(key)
+	entry := s.entries[k]
+	if entry.Revision != revision {
+		entry.Failures = 0
+	}
+	entry.Revision = revision
+	entry.Failures++
+	entry.RetryAt = s.now().Add(delay)
+	s.entries[k] = entry
+	delete(s.restored, k)
+	s.changed[k] = true
+}
+
+// Forget removes the retry state of the Kustomization.
+func (s *BackoffStore) Forget(key types.NamespacedName) {
+	if s == nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	k := backoffKey(key)
+	delete(s.restored, k)
+	if _, ok := s.entries[k]; ok {
+		delete(s.entries, k)
+		s.changed[k] = true
+	}
+}
+
+// NeedLeaderElection returns false, when sharding is enabled all the replicas
+// write the state of their Kustomizations.
+func (s *BackoffStore) NeedLeaderElection() bool {
+	return false
+}
+
+// Start writes the changes to the ConfigMap at the flush interval
+// until the context is cancelled, then it writes the pending changes.
+func (s *BackoffStore) Start(ctx context.Context) error {
+	log := ctrl.Log.WithName("backoff")
+	ticker := time.NewTicker(s.flushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			flushCtx, cancel := context.WithTimeout(context.Background(), s.flushInterval)
+			defer cancel()
+			if err := s.flush(flushCtx); err != nil {
+				log.Error(err, "unable to persist the retry state")
+			}
+			return nil
+		case <-ticker.C:
+			if err := s.flush(ctx); err != nil {
+				log.Error(err, "unable to persist the retry state")
+			}
+		}
+	}
+}
+
+// flush writes the changed entries with a merge patch, so that the replicas
+// only overwrite the entries of the Kustomizations they reconcile.
+func (s *BackoffStore) flush(ctx context.Context) error {
+	s.mu.Lock()
+	data := make(map[string]*string, len(s.changed))
+	for k := range s.changed {
+		if entry, ok := s.entries[k]; ok {
+			b, err := json.Marshal(entry)
+			if err != nil {
+				s.mu.Unlock()
+				return err
+			}
+			value := string(b)
+			data[k] = &value
+		} else {
+			data[k] = nil
+		}
+	}
+	s.changed = make(map[string]bool)
+	s.mu.Unlock()
+
+	if len(data) == 0 {
+		return nil
+	}
+
+	err := s.patch(ctx, data)
+	if apierrors.IsNotFound(err) {
+		cm := &corev1.ConfigMap{}
+		cm.SetName(s.configMap.Name)
+		cm.SetNamespace(s.configMap.Namespace)
+		cm.Data = make(map[string]string)
+		for k, v := range data {
+			if v != nil {
+				cm.Data[k] = *v
+			}
+		}
+		err = s.client.Create(ctx, cm)
+		if apierrors.IsAlreadyExists(err) {
+			err = s.patch(ctx, data)
+		}
+	}
+	if err != nil {
+		// retry the failed changes at the next flush
+		s.mu.Lock()
+		for k := range data {
+			s.changed[k] = true
+		}
+		s.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+func (s *BackoffStore) patch(ctx context.Context, data map[string]*string) error {
+	patch, err := json.Marshal(map[string]interface{}{"data": data})
+	if err != nil {
+		return err
+	}
+	cm := &corev1.ConfigMap{}
+	cm.SetName(s.configMap.Name)
+	cm.SetNamespace(s.configMap.Namespace)
+	return s.client.Patch(ctx, cm, client.RawPatch(types.MergePatchType, patch))
+}
@@ -0,0 +1,142 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta2"
+)
+
+func TestKustomizationReconciler_backoffDelay(t *testing.T) {
+	g := NewWithT(t)
+
+	scheme := runtime.NewScheme()
+	g.Expect(corev1.AddToScheme(scheme)).To(Succeed())
+	kubeClient := fake.NewClientBuilder().WithScheme(scheme).Build()
+
+	ctx := context.TODO()
+	now := time.Now()
+	configMap := types.NamespacedName{Namespace: "flux-system", Name: "kustomize-controller-backoff"}
+	newStore := func() *BackoffStore {
+		s := NewBackoffStore(kubeClient, kubeClient, configMap, time.Second)
+		s.now = func() time.Time { return now }
+		return s
+	}
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Namespace: "default", Name: "apps"}}
+
+	store := newStore()
+	for i := 0; i < 5; i++ {
+		store.Record(req.NamespacedName, "main/1", time.Hour)
+	}
+	g.Expect(store.flush(ctx)).To(Succeed())
+
+	k := kustomizev1.Kustomization{}
+	k.Status.LastAttemptedRevision = "main/1"
+
+	// after a restart, the retry of the failed revision is delayed
+	r := &KustomizationReconciler{BackoffStore: newStore()}
+	g.Expect(r.BackoffStore.Load(ctx)).To(Succeed())
+	g.Expect(r.backoffDelay(req, k, "main/1")).To(Equal(time.Hour))
+
+	// a new revision is reconciled right away and its failures are counted from zero
+	r = &KustomizationReconciler{BackoffStore: newStore()}
+	g.Expect(r.BackoffStore.Load(ctx)).To(Succeed())
+	g.Expect(r.backoffDelay(req, k, "main/2")).To(BeZero())
+	g.Expect(r.BackoffStore.entries).To(BeEmpty())
+	r.BackoffStore.Record(req.NamespacedName, "main/2", time.Minute)
+	g.Expect(r.BackoffStore.entries[backoffKey(req.NamespacedName)].Failures).To(Equal(1))
+
+	// the retry state is removed from the ConfigMap
+	g.Expect(r.BackoffStore.flush(ctx)).To(Succeed())
+	var cm corev1.ConfigMap
+	g.Expect(kubeClient.Get(ctx, configMap, &cm)).To(Succeed())
+	var entry backoffEntry
+	g.Expect(json.Unmarshal([]byte(cm.Data[backoffKey(req.NamespacedName)]), &entry)).To(Succeed())
+	g.Expect(entry.Revision).To(Equal("main/2"))
+
+	// without persisted state, there is no delay
+	r = &KustomizationReconciler{}
+	g.Expect(r.backoffDelay(req, k, "main/1")).To(BeZero())
+}
+
+func TestBackoffStore(t *testing.T) {
+	g := NewWithT(t)
+
+	scheme := runtime.NewScheme()
+	g.Expect(corev1.AddToScheme(scheme)).To(Succeed())
+	kubeClient := fake.NewClientBuilder().WithScheme(scheme).Build()
+
+	ctx := context.TODO()
+	now := time.Now()
+	configMap := types.NamespacedName{Namespace: "flux-system", Name: "kustomize-controller-backoff"}
+	newStore := func() *BackoffStore {
+		s := NewBackoffStore(kubeClient, kubeClient, configMap, time.Second)
+		s.now = func() time.Time { return now }
+		return s
+	}
+	app := types.NamespacedName{Namespace: "apps", Name: "app.v1"}
+	infra := types.NamespacedName{Namespace: "flux-system", Name: "infra"}
+
+	store := newStore()
+	g.Expect(store.Load(ctx)).To(Succeed())
+	store.Record(app, "main/1", time.Minute)
+	store.Record(app, "main/1", time.Minute)
+	store.Record(infra, "main/1", time.Minute)
+	g.Expect(store.flush(ctx)).To(Succeed())
+
+	var cm corev1.ConfigMap
+	g.Expect(kubeClient.Get(ctx, configMap, &cm)).To(Succeed())
+	g.Expect(cm.Data).To(HaveLen(2))
+	var entry backoffEntry
+	g.Expect(json.Unmarshal([]byte(cm.Data["apps.app.v1"]), &entry)).To(Succeed())
+	g.Expect(entry.Revision).To(Equal("main/1"))
+	g.Expect(entry.Failures).To(Equal(2))
+
+	store.Forget(infra)
+	g.Expect(store.flush(ctx)).To(Succeed())
+	g.Expect(kubeClient.Get(ctx, configMap, &cm)).To(Succeed())
+	g.Expect(cm.Data).To(HaveKey("apps.app.v1"))
+	g.Expect(cm.Data).NotTo(HaveKey("flux-system.infra"))
+
+	// after a restart, the retry scheduled at the same revision is honoured once
+	now = now.Add(20 * time.Second)
+	restarted := newStore()
+	g.Expect(restarted.Load(ctx)).To(Succeed())
+	g.Expect(restarted.Delay(app, "main/1")).To(Equal(40 * time.Second))
+	g.Expect(restarted.Delay(app, "main/1")).To(BeZero())
+	g.Expect(restarted.Delay(infra, "main/1")).To(BeZero())
+
+	// a new revision is reconciled right away
+	restarted = newStore()
+	g.Expect(restarted.Load(ctx)).To(Succeed())
+	g.Expect(restarted.Delay(app, "main/2")).To(BeZero())
+
+	var nilStore *BackoffStore
+	nilStore.Record(app, "main/1", time.Minute)
+	g.Expect(nilStore.Delay(app, "main/1")).To(BeZero())
+}
@@ -0,0 +1,212 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	sourcev1 "github.com/fluxcd/source-controller/api/v1beta2"
+
+	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta2"
+)
+
+// BuildCache keeps in memory the build results of the Kustomizations,
+// keyed by the hash of the build inputs, evicting the least recently used.
+type BuildCache struct {
+	size int
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	lru     *list.List
+	hits    uint64
+	misses  uint64
+}
+
+type buildCacheEntry struct {
+	key          string
+	resources    []byte
+	warnings     []string
+	inputsDigest string
+}
+
+// NewBuildCache returns a BuildCache holding at most size build results.
+func NewBuildCache(size int) *BuildCache {
+	return &BuildCache{
+		size:    size,
+		entries: make(map[string]*list.Element),
+		lru:     list.New(),
+	}
+}
+
+// Get returns the build result, the warnings and the build inputs digest stored for the key.
+func (c *BuildCache) Get(key string) ([]byte, []string, string, bool) {
+	if c == nil || key == "" {
+		return nil, nil, "", false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.entries[key]
+	if !ok {
+		c.misses++
+		return nil, nil, "", false
+	}
+	c.hits++
+	c.lru.MoveToFront(elem)
+	entry := elem.Value.(*buildCacheEntry)
+	return entry.resources, append([]string(nil), entry.warnings...), entry.inputsDigest, true
+}
+
+// Set stores the build result, the warnings and the build inputs digest for the key.
+func (c *BuildCache) Set(key string, resources []byte, warnings []string, inputsDigest string) {
+	if c == nil || key == "" || c.size <= 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry := &buildCacheEntry{
+		key:          key,
+		resources:    resources,
+		warnings:     append([]string(nil), warnings...),
+		inputsDigest: inputsDigest,
+	}
+	if elem, ok := c.entries[key]; ok {
+		elem.Value = entry
+		c.lru.MoveToFront(elem)
+		return
+	}
+	c.entries[key] = c.lru.PushFront(entry)
+	for c.lru.Len() > c.size {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.entries, oldest.Value.(*buildCacheEntry).key)
+	}
+}
+
+// Stats returns the number of build results held in the cache,
+// and the number of lookups that found or missed a build result.
+func (c *BuildCache) Stats() (int, uint64, uint64) {
+	if c == nil {
+		return 0, 0, 0
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lru.Len(), c.hits, c.misses
+}
+
+// buildCacheKey returns the hash of the inputs of the Kustomization build:
+// the spec with the resolved images, the artifacts of the sources and the
+// versions of the objects the variables and the OpenAPI schema are read from.
+// It returns an empty key when the build result must not be cached.
+func (r *KustomizationReconciler) buildCacheKey(ctx context.Context, kustomization kustomizev1.Kustomization,
+	source sourcev1.Source) (string, error) {
+	// the decrypted secrets and the values of the external secrets are not kept in memory
+	if r.BuildCache == nil || kustomization.Spec.Decryption != nil || hasExternalSecrets(kustomization) {
+		return "", nil
+	}
+
+	h := sha256.New()
+	spec, err := json.Marshal(kustomization.Spec)
+	if err != nil {
+		return "", err
+	}
+	fmt.Fprintf(h, "%s/%s\n%s\n", kustomization.GetNamespace(), kustomization.GetName(), spec)
+	if err := r.writeArtifactChecksums(ctx, h, kustomization, source); err != nil {
+		return "", err
+	}
+
+	if kustomization.Spec.PostBuild != nil {
+		for _, reference := range kustomization.Spec.PostBuild.SubstituteFrom {
+			var obj client.Object
+			switch reference.Kind {
+			case "ConfigMap":
+				obj = &corev1.ConfigMap{}
+			case "Secret":
+				obj = &corev1.Secret{}
+			default:
+				continue
+			}
+			key := types.NamespacedName{Namespace: kustomization.GetNamespace(), Name: reference.Name}
+			if err := r.writeObjectVersion(ctx, h, reference.Kind+"/"+reference.Name, key, obj); err != nil {
+				return "", err
+			}
+		}
+	}
+
+	if opts := kustomization.Spec.BuildOptions; opts != nil && opts.OpenAPI != nil && opts.OpenAPI.ConfigMapRef != nil {
+		key := types.NamespacedName{Namespace: kustomization.GetNamespace(), Name: opts.OpenAPI.ConfigMapRef.Name}
+		if err := r.writeObjectVersion(ctx, h, "openapi/"+key.Name, key, &corev1.ConfigMap{}); err != nil {
+			return "", err
+		}
+	}
+
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
+}
+
+// writeArtifactChecksums writes to the hash the checksums of the artifacts
+// of the source and of the additional sources of the Kustomization.
+func (r *KustomizationReconciler) writeArtifactChecksums(ctx context.Context, w io.Writer,
+	kustomization kustomizev1.Kustomization, source sourcev1.Source) error {
+	fmt.Fprintf(w, "artifact:%s\n", source.GetArtifact().Checksum)
+	for _, src := range kustomization.Spec.Sources {
+		s, err := r.getSourceByRef(ctx, kustomization, src.SourceRef)
+		if err != nil {
+			return err
+		}
+		if s.GetArtifact() == nil {
+			return fmt.Errorf("source '%s' is not ready, artifact not found", src.SourceRef.String())
+		}
+		fmt.Fprintf(w, "artifact:%s\n", s.GetArtifact().Checksum)
+	}
+	return nil
+}
+
+// writeObjectVersion writes to the hash the version of the object, or 'missing'
+// if it doesn't exist. The content of the object is left out of the hash.
+func (r *KustomizationReconciler) writeObjectVersion(ctx context.Context, w io.Writer, label string,
+	key types.NamespacedName, obj client.Object) error {
+	if err := r.Get(ctx, key, obj); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return err
+		}
+		fmt.Fprintf(w, "%s:missing\n", label)
+		return nil
+	}
+	fmt.Fprintf(w, "%s:%s\n", label, objectVersion(obj))
+	return nil
+}
+
+// objectVersion returns the UID and the resource version of the object,
+// which change along with its content.
+func objectVersion(obj client.Object) string {
+	return fmt.Sprintf("%s/%s", obj.GetUID(), obj.GetResourceVersion())
+}
@@ -0,0 +1,134 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/fluxcd/pkg/apis/meta"
+	sourcev1 "github.com/fluxcd/source-controller/api/v1beta2"
+
+	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta2"
+)
+
+func TestBuildCache(t *testing.T) {
+	g := NewWithT(t)
+
+	c := NewBuildCache(2)
+	c.Set("a", []byte("a"), []string{"warning"}, "sha256:a")
+	c.Set("b", []byte("b"), nil, "sha256:b")
+
+	resources, warnings, digest, ok := c.Get("a")
+	g.Expect(ok).To(BeTrue())
+	g.Expect(string(resources)).To(Equal("a"))
+	g.Expect(warnings).To(Equal([]string{"warning"}))
+	g.Expect(digest).To(Equal("sha256:a"))
+
+	// the returned warnings are a copy
+	warnings[0] = "changed"
+	_, warnings, _, _ = c.Get("a")
+	g.Expect(warnings).To(Equal([]string{"warning"}))
+
+	// evicts the least recently used
+	c.Set("c", []byte("c"), nil, "sha256:c")
+	_, _, _, ok = c.Get("b")
+	g.Expect(ok).To(BeFalse())
+	_, _, _, ok = c.Get("a")
+	g.Expect(ok).To(BeTrue())
+
+	// disabled
+	var disabled *BuildCache
+	disabled.Set("a", []byte("a"), nil, "sha256:a")
+	_, _, _, ok = disabled.Get("a")
+	g.Expect(ok).To(BeFalse())
+	_, _, _, ok = c.Get("")
+	g.Expect(ok).To(BeFalse())
+
+	entries, hits, misses := c.Stats()
+	g.Expect(entries).To(Equal(2))
+	g.Expect(hits).To(Equal(uint64(3)))
+	g.Expect(misses).To(Equal(uint64(1)))
+	entries, _, _ = disabled.Stats()
+	g.Expect(entries).To(BeZero())
+}
+
+func TestKustomizationReconciler_buildCacheKey(t *testing.T) {
+	g := NewWithT(t)
+
+	scheme := runtime.NewScheme()
+	g.Expect(corev1.AddToScheme(scheme)).To(Succeed())
+	vars := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "vars", Namespace: "apps"},
+		Data:       map[string]string{"cluster": "staging"},
+	}
+	kubeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(vars).Build()
+	r := &KustomizationReconciler{Client: kubeClient, BuildCache: NewBuildCache(10)}
+
+	k := kustomizev1.Kustomization{
+		ObjectMeta: metav1.ObjectMeta{Name: "apps", Namespace: "apps"},
+		Spec: kustomizev1.KustomizationSpec{
+			Path: "./deploy",
+			PostBuild: &kustomizev1.PostBuild{
+				SubstituteFrom: []kustomizev1.SubstituteReference{{Kind: "ConfigMap", Name: "vars"}},
+			},
+		},
+	}
+	source := &sourcev1.GitRepository{}
+	source.Status.Artifact = &sourcev1.Artifact{Revision: "main/1", Checksum: "1"}
+
+	key, err := r.buildCacheKey(context.TODO(), k, source)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(key).NotTo(BeEmpty())
+
+	same, err := r.buildCacheKey(context.TODO(), k, source)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(same).To(Equal(key))
+
+	// a new artifact
+	source.Status.Artifact.Checksum = "2"
+	changed, err := r.buildCacheKey(context.TODO(), k, source)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(changed).NotTo(Equal(key))
+	key = changed
+
+	// a change of the substitution variables
+	vars.Data["cluster"] = "production"
+	g.Expect(kubeClient.Update(context.TODO(), vars)).To(Succeed())
+	changed, err = r.buildCacheKey(context.TODO(), k, source)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(changed).NotTo(Equal(key))
+	key = changed
+
+	// a change of the spec
+	k.Spec.Path = "./overlays/production"
+	changed, err = r.buildCacheKey(context.TODO(), k, source)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(changed).NotTo(Equal(key))
+
+	// the decrypted builds are not cached
+	k.Spec.Decryption = &kustomizev1.Decryption{Provider: "sops", SecretRef: &meta.LocalObjectReference{Name: "sops"}}
+	key, err = r.buildCacheKey(context.TODO(), k, source)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(key).To(BeEmpty())
+}
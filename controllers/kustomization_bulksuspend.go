@@ -0,0 +1,91 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta2"
+)
+
+// defaultBulkSuspendReason is the reason of the bulk suspend
+// when the namespace doesn't specify one.
+const defaultBulkSuspendReason = "bulk suspend"
+
+// namespaceMetadata returns the object used to watch the metadata of the Namespaces.
+func namespaceMetadata() *metav1.PartialObjectMetadata {
+	namespace := &metav1.PartialObjectMetadata{}
+	namespace.SetGroupVersionKind(corev1.SchemeGroupVersion.WithKind("Namespace"))
+	return namespace
+}
+
+// bulkSuspended returns the reason of the bulk suspend of the Kustomization,
+// and true if its namespace is annotated with a selector matching its labels.
+func (r *KustomizationReconciler) bulkSuspended(ctx context.Context, kustomization kustomizev1.Kustomization) (string, bool, error) {
+	namespace := namespaceMetadata()
+	if err := r.Get(ctx, types.NamespacedName{Name: kustomization.GetNamespace()}, namespace); err != nil {
+		return "", false, client.IgnoreNotFound(err)
+	}
+	return bulkSuspendReason(namespace, kustomization)
+}
+
+// bulkSuspendReason returns the reason of the bulk suspend of the Kustomization,
+// and true if the bulk suspend annotation of the namespace selects it. An empty
+// selector selects all the Kustomizations of the namespace.
+func bulkSuspendReason(namespace client.Object, kustomization kustomizev1.Kustomization) (string, bool, error) {
+	annotations := namespace.GetAnnotations()
+	value, ok := annotations[kustomizev1.BulkSuspendAnnotation]
+	if !ok {
+		return "", false, nil
+	}
+	selector, err := labels.Parse(value)
+	if err != nil {
+		return "", false, fmt.Errorf("invalid %s annotation on namespace '%s': %w",
+			kustomizev1.BulkSuspendAnnotation, namespace.GetName(), err)
+	}
+	if !selector.Matches(labels.Set(kustomization.GetLabels())) {
+		return "", false, nil
+	}
+
+	reason := annotations[kustomizev1.BulkSuspendReasonAnnotation]
+	if reason == "" {
+		reason = defaultBulkSuspendReason
+	}
+	return reason, true, nil
+}
+
+// requestsForBulkSuspendChange returns the Kustomizations of the namespace,
+// so that they're reconciled as soon as the bulk suspend is lifted.
+func (r *KustomizationReconciler) requestsForBulkSuspendChange(obj client.Object) []reconcile.Request {
+	var list kustomizev1.KustomizationList
+	if err := r.List(context.Background(), &list, client.InNamespace(obj.GetName())); err != nil {
+		return nil
+	}
+	reqs := make([]reconcile.Request, len(list.Items))
+	for i := range list.Items {
+		reqs[i].NamespacedName = client.ObjectKeyFromObject(&list.Items[i])
+	}
+	return reqs
+}
@@ -0,0 +1,118 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta2"
+)
+
+func Test_bulkSuspendReason(t *testing.T) {
+	frontend := kustomizev1.Kustomization{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "apps", Name: "frontend", Labels: map[string]string{"tier": "apps"}},
+	}
+
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		wantReason  string
+		wantErr     bool
+	}{
+		{
+			name: "not annotated",
+		},
+		{
+			name: "selector matches",
+			annotations: map[string]string{
+				kustomizev1.BulkSuspendAnnotation:       "tier=apps",
+				kustomizev1.BulkSuspendReasonAnnotation: "INC-1234",
+			},
+			wantReason: "INC-1234",
+		},
+		{
+			name:        "empty selector matches all",
+			annotations: map[string]string{kustomizev1.BulkSuspendAnnotation: ""},
+			wantReason:  defaultBulkSuspendReason,
+		},
+		{
+			name:        "selector doesn't match",
+			annotations: map[string]string{kustomizev1.BulkSuspendAnnotation: "tier=infra"},
+		},
+		{
+			name:        "invalid selector",
+			annotations: map[string]string{kustomizev1.BulkSuspendAnnotation: "tier in apps"},
+			wantErr:     true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			namespace := namespaceMetadata()
+			namespace.SetName("apps")
+			namespace.SetAnnotations(tt.annotations)
+			reason, suspended, err := bulkSuspendReason(namespace, frontend)
+			if tt.wantErr {
+				g.Expect(err).To(HaveOccurred())
+				return
+			}
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(suspended).To(Equal(tt.wantReason != ""))
+			g.Expect(reason).To(Equal(tt.wantReason))
+		})
+	}
+}
+
+func TestKustomizationReconciler_requestsForBulkSuspendChange(t *testing.T) {
+	g := NewWithT(t)
+
+	scheme := runtime.NewScheme()
+	g.Expect(kustomizev1.AddToScheme(scheme)).To(Succeed())
+	kubeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+		&kustomizev1.Kustomization{ObjectMeta: metav1.ObjectMeta{Namespace: "apps", Name: "frontend"}},
+		&kustomizev1.Kustomization{ObjectMeta: metav1.ObjectMeta{Namespace: "apps", Name: "backend"}},
+		&kustomizev1.Kustomization{ObjectMeta: metav1.ObjectMeta{Namespace: "flux-system", Name: "infra"}},
+	).Build()
+	r := &KustomizationReconciler{Client: kubeClient}
+
+	namespace := namespaceMetadata()
+	namespace.SetName("apps")
+	var names []types.NamespacedName
+	for _, req := range r.requestsForBulkSuspendChange(namespace) {
+		names = append(names, req.NamespacedName)
+	}
+	g.Expect(names).To(ConsistOf(
+		types.NamespacedName{Namespace: "apps", Name: "frontend"},
+		types.NamespacedName{Namespace: "apps", Name: "backend"},
+	))
+
+	// the Kustomizations of a namespace that doesn't exist are not suspended
+	_, suspended, err := r.bulkSuspended(context.TODO(), kustomizev1.Kustomization{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "apps", Name: "frontend"},
+	})
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(suspended).To(BeFalse())
+}
@@ -0,0 +1,141 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/cli-utils/pkg/object"
+
+	"github.com/fluxcd/pkg/ssa"
+
+	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta2"
+)
+
+// applyCheckpointBatchSize is the number of objects applied between two
+// checkpoints, the objects of smaller change sets are applied at once.
+const applyCheckpointBatchSize = 100
+
+// applyCheckpoint tracks the objects applied in order, and records the
+// progress of large applies so that an interrupted apply can be resumed.
+type applyCheckpoint struct {
+	revision string
+	digest   string
+	enabled  bool
+
+	// resume is the number of objects applied before the interruption
+	resume int
+
+	// applied is the number of objects applied, or skipped, so far
+	applied int
+
+	// persist records the checkpoint in the Kustomization status
+	persist func(ctx context.Context, checkpoint *kustomizev1.ApplyCheckpoint) error
+}
+
+// newApplyCheckpoint returns the checkpoint of the objects to be applied,
+// resuming from the recorded checkpoint if it matches the revision and the objects.
+func newApplyCheckpoint(recorded *kustomizev1.ApplyCheckpoint, revision string,
+	objects []*unstructured.Unstructured) (*applyCheckpoint, error) {
+	c := &applyCheckpoint{
+		revision: revision,
+		enabled:  len(objects) > applyCheckpointBatchSize,
+	}
+	if !c.enabled {
+		return c, nil
+	}
+
+	h := sha256.New()
+	for _, u := range objects {
+		data, err := json.Marshal(u.Object)
+		if err != nil {
+			return nil, err
+		}
+		h.Write(data)
+	}
+	c.digest = fmt.Sprintf("sha256:%x", h.Sum(nil))
+
+	if recorded != nil && recorded.Revision == revision && recorded.Digest == c.digest {
+		c.resume = recorded.Applied
+	}
+	return c, nil
+}
+
+// skip returns as unchanged the leading objects that were applied before
+// the interruption, and the objects left to apply.
+func (c *applyCheckpoint) skip(objects []*unstructured.Unstructured) ([]ssa.ChangeSetEntry, []*unstructured.Unstructured) {
+	n := c.resume - c.applied
+	if n <= 0 {
+		return nil, objects
+	}
+	if n > len(objects) {
+		n = len(objects)
+	}
+
+	entries := make([]ssa.ChangeSetEntry, n)
+	for i, u := range objects[:n] {
+		entries[i] = ssa.ChangeSetEntry{
+			ObjMetadata:  object.UnstructuredToObjMetadata(u),
+			GroupVersion: u.GroupVersionKind().Version,
+			Subject:      ssa.FmtUnstructured(u),
+			Action:       string(ssa.UnchangedAction),
+		}
+	}
+	c.applied += n
+	return entries, objects[n:]
+}
+
+// batchSize returns the number of objects to apply before the next checkpoint.
+func (c *applyCheckpoint) batchSize(total int) int {
+	if !c.enabled || total < applyCheckpointBatchSize {
+		return total
+	}
+	return applyCheckpointBatchSize
+}
+
+// done records that n more objects were applied.
+func (c *applyCheckpoint) done(ctx context.Context, n int) error {
+	c.applied += n
+	if !c.enabled || c.persist == nil || n == 0 {
+		return nil
+	}
+	return c.persist(ctx, c.current())
+}
+
+// status returns the checkpoint to be recorded in the Kustomization status
+// after the apply, or nil if the apply completed.
+func (c *applyCheckpoint) status(applyErr error) *kustomizev1.ApplyCheckpoint {
+	if c == nil || applyErr == nil {
+		return nil
+	}
+	return c.current()
+}
+
+func (c *applyCheckpoint) current() *kustomizev1.ApplyCheckpoint {
+	if !c.enabled || c.applied == 0 {
+		return nil
+	}
+	return &kustomizev1.ApplyCheckpoint{
+		Revision: c.revision,
+		Digest:   c.digest,
+		Applied:  c.applied,
+	}
+}
@@ -0,0 +1,97 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/fluxcd/pkg/ssa"
+
+	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta2"
+)
+
+func TestApplyCheckpoint(t *testing.T) {
+	g := NewWithT(t)
+
+	objects := make([]*unstructured.Unstructured, 250)
+	for i := range objects {
+		objects[i] = &unstructured.Unstructured{}
+		objects[i].SetAPIVersion("v1")
+		objects[i].SetKind("ConfigMap")
+		objects[i].SetNamespace("apps")
+		objects[i].SetName(fmt.Sprintf("cm-%03d", i))
+	}
+
+	// small change sets are applied at once
+	c, err := newApplyCheckpoint(nil, "main/1", objects[:10])
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(c.batchSize(10)).To(Equal(10))
+	g.Expect(c.done(context.TODO(), 10)).To(Succeed())
+	g.Expect(c.status(fmt.Errorf("failed"))).To(BeNil())
+
+	var persisted *kustomizev1.ApplyCheckpoint
+	c, err = newApplyCheckpoint(nil, "main/1", objects)
+	g.Expect(err).NotTo(HaveOccurred())
+	c.persist = func(ctx context.Context, checkpoint *kustomizev1.ApplyCheckpoint) error {
+		persisted = checkpoint
+		return nil
+	}
+	skipped, rest := c.skip(objects)
+	g.Expect(skipped).To(BeEmpty())
+	g.Expect(rest).To(HaveLen(250))
+	g.Expect(c.batchSize(len(rest))).To(Equal(applyCheckpointBatchSize))
+
+	g.Expect(c.done(context.TODO(), 100)).To(Succeed())
+	g.Expect(c.done(context.TODO(), 100)).To(Succeed())
+	g.Expect(persisted.Revision).To(Equal("main/1"))
+	g.Expect(persisted.Applied).To(Equal(200))
+	g.Expect(persisted.Digest).To(HavePrefix("sha256:"))
+
+	// the apply failed in the last batch
+	recorded := c.status(fmt.Errorf("failed"))
+	g.Expect(recorded).To(Equal(persisted))
+
+	// resumes from the checkpoint
+	c, err = newApplyCheckpoint(recorded, "main/1", objects)
+	g.Expect(err).NotTo(HaveOccurred())
+	skipped, rest = c.skip(objects[:50])
+	g.Expect(skipped).To(HaveLen(50))
+	g.Expect(rest).To(BeEmpty())
+	skipped, rest = c.skip(objects[50:])
+	g.Expect(skipped).To(HaveLen(150))
+	g.Expect(skipped[0].Subject).To(Equal("ConfigMap/apps/cm-050"))
+	g.Expect(skipped[0].Action).To(Equal(string(ssa.UnchangedAction)))
+	g.Expect(rest).To(HaveLen(50))
+	g.Expect(rest[0].GetName()).To(Equal("cm-200"))
+	g.Expect(c.done(context.TODO(), 50)).To(Succeed())
+	g.Expect(c.status(nil)).To(BeNil())
+
+	// doesn't resume a different revision or different objects
+	c, err = newApplyCheckpoint(recorded, "main/2", objects)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(c.resume).To(BeZero())
+
+	objects[0].SetLabels(map[string]string{"app": "changed"})
+	c, err = newApplyCheckpoint(recorded, "main/1", objects)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(c.resume).To(BeZero())
+}
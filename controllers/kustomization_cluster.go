@@ -0,0 +1,128 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/tools/clientcmd"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta2"
+)
+
+// clusterFingerprint returns the digest of the API server address and of the
+// certificate authority of the current context of the kubeconfig. The digest
+// changes when the kubeconfig is rotated to target a new or replaced cluster,
+// but not when only the credentials are renewed.
+func clusterFingerprint(kubeConfig []byte) (string, error) {
+	restConfig, err := clientcmd.RESTConfigFromKubeConfig(kubeConfig)
+	if err != nil {
+		return "", fmt.Errorf("unable to load the kubeconfig: %w", err)
+	}
+
+	h := sha256.New()
+	h.Write([]byte(restConfig.Host))
+	h.Write([]byte{0})
+	h.Write(restConfig.CAData)
+	h.Write([]byte{0})
+	h.Write([]byte(restConfig.CAFile))
+	return fmt.Sprintf("sha256:%x", h.Sum(nil)), nil
+}
+
+// clusterChanged returns true if the inventory was applied to another cluster
+// than the one identified by the fingerprint.
+func clusterChanged(kustomization kustomizev1.Kustomization, fingerprint string) bool {
+	recorded := kustomization.Status.ClusterFingerprint
+	return recorded != "" && recorded != fingerprint
+}
+
+// missingInventoryObjects returns the objects of the inventory that don't
+// exist on the cluster targeted by the client, including the custom resources
+// whose definitions are not registered.
+func missingInventoryObjects(ctx context.Context, kubeClient client.Client,
+	inventory *kustomizev1.ResourceInventory) ([]*unstructured.Unstructured, error) {
+	if inventory == nil {
+		return nil, nil
+	}
+
+	objects, err := ListObjectsInInventory(inventory)
+	if err != nil {
+		return nil, err
+	}
+
+	var missing []*unstructured.Unstructured
+	for _, obj := range objects {
+		existing := &unstructured.Unstructured{}
+		existing.SetGroupVersionKind(obj.GroupVersionKind())
+		err := kubeClient.Get(ctx, client.ObjectKeyFromObject(obj), existing)
+		switch {
+		case apierrors.IsNotFound(err), apimeta.IsNoMatchError(err):
+			missing = append(missing, obj)
+		case err != nil:
+			return nil, fmt.Errorf("unable to verify the inventory on the cluster: %w", err)
+		}
+	}
+	return missing, nil
+}
+
+// kubeConfigSecretMetadata returns the object used to watch the metadata
+// of the Secrets, without caching their data.
+func kubeConfigSecretMetadata() *metav1.PartialObjectMetadata {
+	secret := &metav1.PartialObjectMetadata{}
+	secret.SetGroupVersionKind(corev1.SchemeGroupVersion.WithKind("Secret"))
+	return secret
+}
+
+// indexByKubeConfigSecret indexes the Kustomizations by the Secret of their kubeconfig.
+func (r *KustomizationReconciler) indexByKubeConfigSecret(o client.Object) []string {
+	k, ok := o.(*kustomizev1.Kustomization)
+	if !ok {
+		panic(fmt.Sprintf("Expected a Kustomization, got %T", o))
+	}
+
+	if k.Spec.KubeConfig == nil {
+		return nil
+	}
+	return []string{fmt.Sprintf("%s/%s", k.GetNamespace(), k.Spec.KubeConfig.SecretRef.Name)}
+}
+
+// requestsForKubeConfigChange returns the Kustomizations using the Secret as kubeconfig,
+// so that the rotation of the kubeconfig is detected without waiting for the interval.
+func (r *KustomizationReconciler) requestsForKubeConfigChange(indexKey string) func(obj client.Object) []reconcile.Request {
+	return func(obj client.Object) []reconcile.Request {
+		var list kustomizev1.KustomizationList
+		if err := r.List(context.Background(), &list, client.MatchingFields{
+			indexKey: client.ObjectKeyFromObject(obj).String(),
+		}); err != nil {
+			return nil
+		}
+		reqs := make([]reconcile.Request, len(list.Items))
+		for i := range list.Items {
+			reqs[i].NamespacedName = client.ObjectKeyFromObject(&list.Items[i])
+		}
+		return reqs
+	}
+}
@@ -0,0 +1,138 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta2"
+	"github.com/fluxcd/pkg/apis/meta"
+)
+
+func testKubeConfig(server, ca, token string) []byte {
+	return []byte(fmt.Sprintf(`apiVersion: v1
+kind: Config
+clusters:
+- name: remote
+  cluster:
+    server: %s
+    certificate-authority-data: %s
+contexts:
+- name: remote
+  context:
+    cluster: remote
+    user: flux
+current-context: remote
+users:
+- name: flux
+  user:
+    token: %s
+`, server, ca, token))
+}
+
+func Test_clusterFingerprint(t *testing.T) {
+	g := NewWithT(t)
+
+	fingerprint, err := clusterFingerprint(testKubeConfig("https://blue:6443", "Y2EtYmx1ZQ==", "token-1"))
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(fingerprint).To(HavePrefix("sha256:"))
+
+	renewed, err := clusterFingerprint(testKubeConfig("https://blue:6443", "Y2EtYmx1ZQ==", "token-2"))
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(renewed).To(Equal(fingerprint))
+
+	rotatedCA, err := clusterFingerprint(testKubeConfig("https://blue:6443", "Y2EtZ3JlZW4=", "token-1"))
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(rotatedCA).ToNot(Equal(fingerprint))
+
+	swapped, err := clusterFingerprint(testKubeConfig("https://green:6443", "Y2EtYmx1ZQ==", "token-1"))
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(swapped).ToNot(Equal(fingerprint))
+
+	_, err = clusterFingerprint([]byte("not a kubeconfig"))
+	g.Expect(err).To(HaveOccurred())
+}
+
+func Test_clusterChanged(t *testing.T) {
+	g := NewWithT(t)
+
+	k := kustomizev1.Kustomization{}
+	g.Expect(clusterChanged(k, "sha256:blue")).To(BeFalse())
+
+	k.Status.ClusterFingerprint = "sha256:blue"
+	g.Expect(clusterChanged(k, "sha256:blue")).To(BeFalse())
+	g.Expect(clusterChanged(k, "sha256:green")).To(BeTrue())
+}
+
+func Test_missingInventoryObjects(t *testing.T) {
+	g := NewWithT(t)
+
+	scheme := runtime.NewScheme()
+	g.Expect(corev1.AddToScheme(scheme)).To(Succeed())
+
+	mapper := apimeta.NewDefaultRESTMapper(nil)
+	mapper.Add(corev1.SchemeGroupVersion.WithKind("ConfigMap"), apimeta.RESTScopeNamespace)
+
+	kubeClient := fake.NewClientBuilder().WithScheme(scheme).WithRESTMapper(mapper).WithObjects(
+		&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: "apps", Name: "present"}},
+	).Build()
+
+	newObject := func(apiVersion, kind, name string) *unstructured.Unstructured {
+		u := &unstructured.Unstructured{}
+		u.SetAPIVersion(apiVersion)
+		u.SetKind(kind)
+		u.SetNamespace("apps")
+		u.SetName(name)
+		return u
+	}
+	inventory := &kustomizev1.ResourceInventory{Entries: objectsToResourceRefs([]*unstructured.Unstructured{
+		newObject("v1", "ConfigMap", "present"),
+		newObject("v1", "ConfigMap", "missing"),
+		newObject("example.com/v1", "Widget", "unregistered"),
+	})}
+
+	missing, err := missingInventoryObjects(context.TODO(), kubeClient, inventory)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(missing).To(HaveLen(2))
+	g.Expect(missing[0].GetName()).To(Equal("missing"))
+	g.Expect(missing[1].GetName()).To(Equal("unregistered"))
+
+	missing, err = missingInventoryObjects(context.TODO(), kubeClient, nil)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(missing).To(BeEmpty())
+}
+
+func Test_indexByKubeConfigSecret(t *testing.T) {
+	g := NewWithT(t)
+
+	r := &KustomizationReconciler{}
+	k := &kustomizev1.Kustomization{ObjectMeta: metav1.ObjectMeta{Namespace: "fleet", Name: "apps"}}
+	g.Expect(r.indexByKubeConfigSecret(k)).To(BeEmpty())
+
+	k.Spec.KubeConfig = &kustomizev1.KubeConfig{SecretRef: meta.SecretKeyReference{Name: "blue-kubeconfig"}}
+	g.Expect(r.indexByKubeConfigSecret(k)).To(ConsistOf("fleet/blue-kubeconfig"))
+}
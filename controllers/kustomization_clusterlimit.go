@@ -0,0 +1,65 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"time"
+
+	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta2"
+)
+
+// ClusterLimitRequeueInterval is the interval at which the Kustomizations
+// waiting for a remote cluster slot are requeued.
+const ClusterLimitRequeueInterval = 5 * time.Second
+
+// ClusterLimiter caps the number of Kustomizations that reconcile the
+// objects of the same remote cluster at once.
+type ClusterLimiter struct {
+	slots *SourceLimiter
+}
+
+// NewClusterLimiter returns a ClusterLimiter allowing max concurrent
+// reconciliations per remote cluster, zero means no limit.
+func NewClusterLimiter(max int) *ClusterLimiter {
+	return &ClusterLimiter{slots: NewSourceLimiter(max)}
+}
+
+// TryAcquire reserves a slot for a reconciliation targeting the cluster,
+// it returns false if all the slots of the cluster are taken.
+func (l *ClusterLimiter) TryAcquire(cluster string) bool {
+	if l == nil {
+		return true
+	}
+	return l.slots.TryAcquire(cluster)
+}
+
+// Release frees the slot reserved with TryAcquire.
+func (l *ClusterLimiter) Release(cluster string) {
+	if l == nil {
+		return
+	}
+	l.slots.Release(cluster)
+}
+
+// clusterLimitKey returns the name of the remote cluster targeted by the
+// Kustomization, and false for the cluster the controller runs on.
+func clusterLimitKey(kustomization kustomizev1.Kustomization) (string, bool) {
+	if kustomization.Spec.KubeConfig == nil {
+		return "", false
+	}
+	return targetCluster(kustomization), true
+}
@@ -0,0 +1,63 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"testing"
+
+	"github.com/fluxcd/pkg/apis/meta"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta2"
+)
+
+func TestClusterLimiter(t *testing.T) {
+	g := NewWithT(t)
+
+	l := NewClusterLimiter(1)
+	g.Expect(l.TryAcquire("edge-042")).To(BeTrue())
+	g.Expect(l.TryAcquire("edge-042")).To(BeFalse())
+	g.Expect(l.TryAcquire("edge-043")).To(BeTrue())
+
+	l.Release("edge-042")
+	g.Expect(l.TryAcquire("edge-042")).To(BeTrue())
+
+	// no limit
+	var disabled *ClusterLimiter
+	g.Expect(disabled.TryAcquire("edge-042")).To(BeTrue())
+	disabled.Release("edge-042")
+}
+
+func Test_clusterLimitKey(t *testing.T) {
+	g := NewWithT(t)
+
+	k := kustomizev1.Kustomization{
+		ObjectMeta: metav1.ObjectMeta{Name: "apps", Namespace: "fleet"},
+	}
+	_, ok := clusterLimitKey(k)
+	g.Expect(ok).To(BeFalse())
+
+	k.Spec.KubeConfig = &kustomizev1.KubeConfig{SecretRef: meta.SecretKeyReference{Name: "edge-042-kubeconfig"}}
+	key, ok := clusterLimitKey(k)
+	g.Expect(ok).To(BeTrue())
+	g.Expect(key).To(Equal("fleet/edge-042-kubeconfig"))
+
+	k.SetLabels(map[string]string{kustomizev1.ClusterLabel: "edge-042"})
+	key, _ = clusterLimitKey(k)
+	g.Expect(key).To(Equal("edge-042"))
+}
@@ -0,0 +1,106 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta2"
+)
+
+// localClusterName is the name of the cluster the controller runs on in the per-cluster metrics.
+const localClusterName = "in-cluster"
+
+// ClusterMetricsRecorder records the results and durations of the reconciliations
+// and applies by target cluster, so that the Kustomizations of a fleet can be
+// aggregated per cluster.
+type ClusterMetricsRecorder struct {
+	reconcileCounter  *prometheus.CounterVec
+	reconcileDuration *prometheus.HistogramVec
+	applyDuration     *prometheus.HistogramVec
+}
+
+// NewClusterMetricsRecorder returns a ClusterMetricsRecorder, its collectors
+// must be registered with the metrics registry.
+func NewClusterMetricsRecorder() *ClusterMetricsRecorder {
+	return &ClusterMetricsRecorder{
+		reconcileCounter: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "gotk_kustomization_cluster_reconciles_total",
+				Help: "The number of Kustomization reconciliations by target cluster and result: success or failure.",
+			},
+			[]string{"cluster", "result"},
+		),
+		reconcileDuration: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "gotk_kustomization_cluster_reconcile_duration_seconds",
+				Help:    "The duration in seconds of the Kustomization reconciliations by target cluster.",
+				Buckets: prometheus.ExponentialBuckets(0.1, 2, 12),
+			},
+			[]string{"cluster"},
+		),
+		applyDuration: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "gotk_kustomization_cluster_apply_duration_seconds",
+				Help:    "The duration in seconds of the server-side apply of the Kustomizations by target cluster.",
+				Buckets: prometheus.ExponentialBuckets(0.1, 2, 12),
+			},
+			[]string{"cluster"},
+		),
+	}
+}
+
+// Collectors returns the metrics.Collector objects for the ClusterMetricsRecorder.
+func (r *ClusterMetricsRecorder) Collectors() []prometheus.Collector {
+	return []prometheus.Collector{
+		r.reconcileCounter,
+		r.reconcileDuration,
+		r.applyDuration,
+	}
+}
+
+// RecordReconcile counts the reconciliation of a Kustomization targeting the
+// cluster as a success or failure, and records its duration since start.
+func (r *ClusterMetricsRecorder) RecordReconcile(cluster string, reconcileErr error, start time.Time) {
+	result := "success"
+	if reconcileErr != nil {
+		result = "failure"
+	}
+	r.reconcileCounter.WithLabelValues(cluster, result).Inc()
+	r.reconcileDuration.WithLabelValues(cluster).Observe(time.Since(start).Seconds())
+}
+
+// RecordApply records the duration of the apply on the cluster since start.
+func (r *ClusterMetricsRecorder) RecordApply(cluster string, start time.Time) {
+	r.applyDuration.WithLabelValues(cluster).Observe(time.Since(start).Seconds())
+}
+
+// targetCluster returns the name of the cluster targeted by the Kustomization:
+// the value of its cluster label if set, the '<namespace>/<secret>' of its
+// kubeconfig for a remote cluster, or 'in-cluster'.
+func targetCluster(kustomization kustomizev1.Kustomization) string {
+	if name := kustomization.GetLabels()[kustomizev1.ClusterLabel]; name != "" {
+		return name
+	}
+	if kc := kustomization.Spec.KubeConfig; kc != nil {
+		return fmt.Sprintf("%s/%s", kustomization.GetNamespace(), kc.SecretRef.Name)
+	}
+	return localClusterName
+}
@@ -0,0 +1,62 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta2"
+	"github.com/fluxcd/pkg/apis/meta"
+)
+
+func Test_targetCluster(t *testing.T) {
+	g := NewWithT(t)
+
+	k := kustomizev1.Kustomization{ObjectMeta: metav1.ObjectMeta{Namespace: "fleet", Name: "apps"}}
+	g.Expect(targetCluster(k)).To(Equal("in-cluster"))
+
+	k.Spec.KubeConfig = &kustomizev1.KubeConfig{SecretRef: meta.SecretKeyReference{Name: "prod-eu-kubeconfig"}}
+	g.Expect(targetCluster(k)).To(Equal("fleet/prod-eu-kubeconfig"))
+
+	k.SetLabels(map[string]string{kustomizev1.ClusterLabel: "prod-eu"})
+	g.Expect(targetCluster(k)).To(Equal("prod-eu"))
+}
+
+func TestClusterMetricsRecorder(t *testing.T) {
+	g := NewWithT(t)
+
+	r := NewClusterMetricsRecorder()
+	start := time.Now().Add(-time.Second)
+
+	r.RecordReconcile("prod-eu", nil, start)
+	r.RecordReconcile("prod-eu", errors.New("apply failed"), start)
+	r.RecordReconcile("prod-eu", nil, start)
+	r.RecordReconcile("in-cluster", nil, start)
+	r.RecordApply("prod-eu", start)
+
+	g.Expect(testutil.ToFloat64(r.reconcileCounter.WithLabelValues("prod-eu", "success"))).To(Equal(float64(2)))
+	g.Expect(testutil.ToFloat64(r.reconcileCounter.WithLabelValues("prod-eu", "failure"))).To(Equal(float64(1)))
+	g.Expect(testutil.ToFloat64(r.reconcileCounter.WithLabelValues("in-cluster", "success"))).To(Equal(float64(1)))
+	g.Expect(testutil.CollectAndCount(r.reconcileDuration)).To(Equal(2))
+	g.Expect(testutil.CollectAndCount(r.applyDuration)).To(Equal(1))
+}
@@ -21,7 +21,6 @@ import (
 	"context"
 	"fmt"
 	"os"
-	"sort"
 	"strings"
 	"time"
 
@@ -47,13 +46,13 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/ratelimiter"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 	"sigs.k8s.io/controller-runtime/pkg/source"
+	"sigs.k8s.io/kustomize/api/resmap"
 
 	apiacl "github.com/fluxcd/pkg/apis/acl"
 	"github.com/fluxcd/pkg/apis/meta"
 	"github.com/fluxcd/pkg/runtime/acl"
 	runtimeClient "github.com/fluxcd/pkg/runtime/client"
 	"github.com/fluxcd/pkg/runtime/events"
-	"github.com/fluxcd/pkg/runtime/metrics"
 	"github.com/fluxcd/pkg/runtime/predicates"
 	"github.com/fluxcd/pkg/ssa"
 	sourcev1 "github.com/fluxcd/source-controller/api/v1beta2"
@@ -66,31 +65,61 @@ import (
 // +kubebuilder:rbac:groups=kustomize.toolkit.fluxcd.io,resources=kustomizations/finalizers,verbs=get;create;update;patch;delete
 // +kubebuilder:rbac:groups=source.toolkit.fluxcd.io,resources=buckets;ocirepositories;gitrepositories,verbs=get;list;watch
 // +kubebuilder:rbac:groups=source.toolkit.fluxcd.io,resources=buckets/status;ocirepositories/status;gitrepositories/status,verbs=get
+// +kubebuilder:rbac:groups=image.toolkit.fluxcd.io,resources=imagepolicies,verbs=get;list;watch
 // +kubebuilder:rbac:groups="",resources=configmaps;secrets;serviceaccounts,verbs=get;list;watch
 // +kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+// +kubebuilder:rbac:groups="",resources=namespaces,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=serviceaccounts/token,verbs=create
+// +kubebuilder:rbac:groups=apiextensions.k8s.io,resources=customresourcedefinitions,verbs=get;list;watch
 
 // KustomizationReconciler reconciles a Kustomization object
 type KustomizationReconciler struct {
 	client.Client
-	artifactFetcher       *ArtifactFetcher
-	requeueDependency     time.Duration
-	Scheme                *runtime.Scheme
-	EventRecorder         kuberecorder.EventRecorder
-	MetricsRecorder       *metrics.Recorder
-	StatusPoller          *polling.StatusPoller
-	PollingOpts           polling.Options
-	ControllerName        string
-	statusManager         string
-	NoCrossNamespaceRefs  bool
-	NoRemoteBases         bool
-	DefaultServiceAccount string
-	KubeConfigOpts        runtimeClient.KubeConfigOptions
+	artifactFetcher        *ArtifactFetcher
+	requeueDependency      time.Duration
+	kindWaitList           *kindWaitList
+	Scheme                 *runtime.Scheme
+	EventRecorder          kuberecorder.EventRecorder
+	MetricsRecorder        ObjectMetricsRecorder
+	DependentsRecorder     *DependentsRecorder
+	PruneReportRecorder    *PruneReportRecorder
+	ClusterMetricsRecorder *ClusterMetricsRecorder
+	ControllerMetrics      *ControllerMetricsRecorder
+	StatusPoller           *polling.StatusPoller
+	PollingOpts            polling.Options
+	ControllerName         string
+	statusManager          string
+	NoCrossNamespaceRefs   bool
+	NoRemoteBases          bool
+	EnableHelm             bool
+	HelmCommand            string
+	DefaultServiceAccount  string
+	KubeConfigOpts         runtimeClient.KubeConfigOptions
+	PruneNamespaceDenyList []string
+	AllowedObjectPolicies  []string
+	RequireImageDigests    bool
+	SandboxBuild           bool
+	ObjectQuota            ObjectQuota
+	OutputLimits           OutputLimits
+	DefaultSubstitutions   map[string]string
+	ExternalSecrets        ExternalSecretResolver
+	EndpointProber         *EndpointProber
+	PruneHookClient        *PruneHookClient
+	Shards                 *ShardManager
+	StartupScheduler       *StartupScheduler
+	BackoffStore           *BackoffStore
+	SourceStaleThreshold   time.Duration
+	SourceLimiter          *SourceLimiter
+	ClusterLimiter         *ClusterLimiter
+	BuildCache             *BuildCache
+	MemoryBudget           *MemoryBudget
 }
 
 // KustomizationReconcilerOptions contains options for the KustomizationReconciler.
 type KustomizationReconcilerOptions struct {
 	MaxConcurrentReconciles   int
 	HTTPRetry                 int
+	ArtifactMirrors           ArtifactMirrors
 	DependencyRequeueInterval time.Duration
 	RateLimiter               ratelimiter.RateLimiter
 }
@@ -100,6 +129,9 @@ func (r *KustomizationReconciler) SetupWithManager(mgr ctrl.Manager, opts Kustom
 		ociRepositoryIndexKey string = ".metadata.ociRepository"
 		gitRepositoryIndexKey string = ".metadata.gitRepository"
 		bucketIndexKey        string = ".metadata.bucket"
+		imagePolicyIndexKey   string = ".spec.images.fromImagePolicy"
+		dependsOnIndexKey     string = ".spec.dependsOn"
+		kubeConfigIndexKey    string = ".spec.kubeConfig.secretRef"
 	)
 
 	// Index the Kustomizations by the OCIRepository references they (may) point at.
@@ -120,41 +152,124 @@ func (r *KustomizationReconciler) SetupWithManager(mgr ctrl.Manager, opts Kustom
 		return fmt.Errorf("failed setting index fields: %w", err)
 	}
 
+	// Index the Kustomizations by the ImagePolicy references they (may) point at.
+	if err := mgr.GetCache().IndexField(context.TODO(), &kustomizev1.Kustomization{}, imagePolicyIndexKey,
+		r.indexByImagePolicy); err != nil {
+		return fmt.Errorf("failed setting index fields: %w", err)
+	}
+
+	// Index the Kustomizations by the Kustomizations they depend on.
+	if err := mgr.GetCache().IndexField(context.TODO(), &kustomizev1.Kustomization{}, dependsOnIndexKey,
+		r.indexByDependsOn); err != nil {
+		return fmt.Errorf("failed setting index fields: %w", err)
+	}
+
+	// Index the Kustomizations by the Secrets of their kubeconfig.
+	if err := mgr.GetCache().IndexField(context.TODO(), &kustomizev1.Kustomization{}, kubeConfigIndexKey,
+		r.indexByKubeConfigSecret); err != nil {
+		return fmt.Errorf("failed setting index fields: %w", err)
+	}
+
 	r.requeueDependency = opts.DependencyRequeueInterval
 	r.statusManager = fmt.Sprintf("gotk-%s", r.ControllerName)
 	r.artifactFetcher = NewArtifactFetcher(opts.HTTPRetry)
+	r.artifactFetcher.mirrors = opts.ArtifactMirrors
+	r.kindWaitList = newKindWaitList()
 
-	return ctrl.NewControllerManagedBy(mgr).
+	b := ctrl.NewControllerManagedBy(mgr).
 		For(&kustomizev1.Kustomization{}, builder.WithPredicates(
-			predicate.Or(predicate.GenerationChangedPredicate{}, predicates.ReconcileRequestedPredicate{}),
+			predicate.Or(
+				predicate.GenerationChangedPredicate{},
+				predicates.ReconcileRequestedPredicate{},
+				AnnotationChangedPredicate{Annotations: []string{
+					kustomizev1.PruneApprovalAnnotation,
+					kustomizev1.FirstApplyApprovalAnnotation,
+					kustomizev1.PromotedRevisionAnnotation,
+				}},
+			),
 		)).
 		Watches(
 			&source.Kind{Type: &sourcev1.OCIRepository{}},
-			handler.EnqueueRequestsFromMapFunc(r.requestsForRevisionChangeOf(ociRepositoryIndexKey)),
+			handler.EnqueueRequestsFromMapFunc(r.queuedRequests(r.requestsForRevisionChangeOf(ociRepositoryIndexKey))),
 			builder.WithPredicates(SourceRevisionChangePredicate{}),
 		).
 		Watches(
 			&source.Kind{Type: &sourcev1.GitRepository{}},
-			handler.EnqueueRequestsFromMapFunc(r.requestsForRevisionChangeOf(gitRepositoryIndexKey)),
+			handler.EnqueueRequestsFromMapFunc(r.queuedRequests(r.requestsForRevisionChangeOf(gitRepositoryIndexKey))),
 			builder.WithPredicates(SourceRevisionChangePredicate{}),
 		).
 		Watches(
 			&source.Kind{Type: &sourcev1.Bucket{}},
-			handler.EnqueueRequestsFromMapFunc(r.requestsForRevisionChangeOf(bucketIndexKey)),
+			handler.EnqueueRequestsFromMapFunc(r.queuedRequests(r.requestsForRevisionChangeOf(bucketIndexKey))),
 			builder.WithPredicates(SourceRevisionChangePredicate{}),
 		).
-		WithOptions(controller.Options{
-			MaxConcurrentReconciles: opts.MaxConcurrentReconciles,
-			RateLimiter:             opts.RateLimiter,
-			RecoverPanic:            true,
-		}).
+		Watches(
+			&source.Kind{Type: &kustomizev1.Kustomization{}},
+			handler.EnqueueRequestsFromMapFunc(r.queuedRequests(r.requestsForDependencyReady(dependsOnIndexKey))),
+			builder.WithPredicates(DependencyReadyPredicate{}),
+		).
+		Watches(
+			&source.Kind{Type: customResourceDefinitionMetadata()},
+			handler.EnqueueRequestsFromMapFunc(r.queuedRequests(r.requestsForDefinitionChange)),
+		).
+		Watches(
+			&source.Kind{Type: kubeConfigSecretMetadata()},
+			handler.EnqueueRequestsFromMapFunc(r.queuedRequests(r.requestsForKubeConfigChange(kubeConfigIndexKey))),
+			builder.WithPredicates(predicate.ResourceVersionChangedPredicate{}),
+		).
+		Watches(
+			&source.Kind{Type: namespaceMetadata()},
+			handler.EnqueueRequestsFromMapFunc(r.queuedRequests(r.requestsForBulkSuspendChange)),
+			builder.WithPredicates(AnnotationChangedPredicate{Annotations: []string{
+				kustomizev1.BulkSuspendAnnotation,
+			}}),
+		)
+
+	// Watch the ImagePolicies only when the image-reflector-controller CRDs are installed,
+	// otherwise the informer would fail to sync and block the controller start.
+	if _, err := mgr.GetRESTMapper().RESTMapping(imagePolicyGVK.GroupKind(), imagePolicyGVK.Version); err == nil {
+		imagePolicy := &unstructured.Unstructured{}
+		imagePolicy.SetGroupVersionKind(imagePolicyGVK)
+		b = b.Watches(
+			&source.Kind{Type: imagePolicy},
+			handler.EnqueueRequestsFromMapFunc(r.queuedRequests(r.requestsForImagePolicyChange(imagePolicyIndexKey))),
+			builder.WithPredicates(LatestImageChangePredicate{}),
+		)
+	} else {
+		mgr.GetLogger().Info(fmt.Sprintf("%s API not found, changes to image policies will not trigger a reconciliation", imagePolicyGVK.Kind))
+	}
+
+	// Reconcile the Kustomizations of the shards acquired by this replica.
+	if r.Shards != nil {
+		b = b.Watches(
+			&source.Channel{Source: r.Shards.Events()},
+			&handler.EnqueueRequestForObject{},
+		)
+	}
+
+	return b.WithOptions(controller.Options{
+		MaxConcurrentReconciles: opts.MaxConcurrentReconciles,
+		RateLimiter:             opts.RateLimiter,
+		RecoverPanic:            true,
+	}).
 		Complete(r)
 }
 
-func (r *KustomizationReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+func (r *KustomizationReconciler) Reconcile(ctx context.Context, req ctrl.Request) (result ctrl.Result, retErr error) {
 	log := ctrl.LoggerFrom(ctx)
 	reconcileStart := time.Now()
 
+	// track the pending reconciliations for the work queue metrics
+	r.ControllerMetrics.Dequeued(req.NamespacedName)
+	defer func() {
+		r.ControllerMetrics.Requeued(req.NamespacedName, result, retErr)
+	}()
+
+	// Leave the Kustomizations of the shards held by other replicas
+	if r.Shards != nil && !r.Shards.Owns(req.NamespacedName) {
+		return ctrl.Result{}, nil
+	}
+
 	var kustomization kustomizev1.Kustomization
 	if err := r.Get(ctx, req.NamespacedName, &kustomization); err != nil {
 		return ctrl.Result{}, client.IgnoreNotFound(err)
@@ -163,6 +278,13 @@ func (r *KustomizationReconciler) Reconcile(ctx context.Context, req ctrl.Reques
 	// Record suspended status metric
 	defer r.recordSuspension(ctx, kustomization)
 
+	// Isolate the panics of this object's reconciliation from the other Kustomizations
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			result, retErr = r.recoverPanic(ctx, req, kustomization, recovered)
+		}
+	}()
+
 	// Add our finalizer if it does not exist
 	if !controllerutil.ContainsFinalizer(&kustomization, kustomizev1.KustomizationFinalizer) {
 		patch := client.MergeFrom(kustomization.DeepCopy())
@@ -175,6 +297,8 @@ func (r *KustomizationReconciler) Reconcile(ctx context.Context, req ctrl.Reques
 
 	// Examine if the object is under deletion
 	if !kustomization.ObjectMeta.DeletionTimestamp.IsZero() {
+		r.BackoffStore.Forget(req.NamespacedName)
+		r.kindWaitList.Forget(req.NamespacedName)
 		return r.finalize(ctx, kustomization)
 	}
 
@@ -184,6 +308,37 @@ func (r *KustomizationReconciler) Reconcile(ctx context.Context, req ctrl.Reques
 		return ctrl.Result{}, nil
 	}
 
+	// Return early if the Kustomization is suspended by its namespace.
+	if reason, suspended, err := r.bulkSuspended(ctx, kustomization); err != nil {
+		return ctrl.Result{}, err
+	} else if suspended {
+		log.Info("Reconciliation is suspended by the namespace", "reason", reason)
+		return ctrl.Result{}, nil
+	}
+
+	// Reject the invalid patches before fetching the source, the
+	// Kustomization is reconciled again when its spec changes.
+	if err := validatePatches(kustomization.Spec); err != nil {
+		kustomization = kustomizev1.KustomizationNotReady(kustomization, "", kustomizev1.InvalidPatchReason, err.Error())
+		if err := r.patchStatus(ctx, req, kustomization.Status); err != nil {
+			return ctrl.Result{Requeue: true}, err
+		}
+		log.Error(err, "invalid patches")
+		r.recordReadiness(ctx, kustomization)
+		r.event(ctx, kustomization, "unknown", events.EventSeverityError, err.Error(), nil)
+		return ctrl.Result{}, nil
+	}
+
+	// Stagger the first reconciliation after the controller start
+	startupDelay, err := r.StartupScheduler.Delay(ctx, r.Client, req.NamespacedName)
+	if err != nil {
+		return ctrl.Result{Requeue: true}, err
+	}
+	if startupDelay > 0 {
+		log.Info(fmt.Sprintf("Startup reconciliation scheduled in %s", startupDelay.Round(time.Second).String()))
+		return ctrl.Result{RequeueAfter: startupDelay}, nil
+	}
+
 	// resolve source reference
 	source, err := r.getSource(ctx, kustomization)
 	if err != nil {
@@ -215,6 +370,9 @@ func (r *KustomizationReconciler) Reconcile(ctx context.Context, req ctrl.Reques
 
 	}
 
+	// flag the source failing to produce new artifacts
+	r.checkSourceStaleness(ctx, &kustomization, source)
+
 	if source.GetArtifact() == nil {
 		msg := "Source is not ready, artifact not found"
 		kustomization = kustomizev1.KustomizationNotReady(kustomization, "", kustomizev1.ArtifactFailedReason, msg)
@@ -228,26 +386,78 @@ func (r *KustomizationReconciler) Reconcile(ctx context.Context, req ctrl.Reques
 		return ctrl.Result{RequeueAfter: kustomization.GetRetryInterval()}, nil
 	}
 
+	// wait for the retry scheduled before the controller restart
+	if delay := r.backoffDelay(req, kustomization, source.GetArtifact().Revision); delay > 0 {
+		log.Info(fmt.Sprintf("Reconciliation failed before the controller restart, next try in %s",
+			delay.Round(time.Second).String()))
+		return ctrl.Result{RequeueAfter: delay}, nil
+	}
+
 	// check dependencies
 	if len(kustomization.Spec.DependsOn) > 0 {
 		if err := r.checkDependencies(source, kustomization); err != nil {
 			kustomization = kustomizev1.KustomizationNotReady(
 				kustomization, source.GetArtifact().Revision, kustomizev1.DependencyNotReadyReason, err.Error())
+			r.checkRevisionSkew(ctx, &kustomization, source.GetArtifact().Revision)
 			if err := r.patchStatus(ctx, req, kustomization.Status); err != nil {
 				log.Error(err, "unable to update status for dependency not ready")
 				return ctrl.Result{Requeue: true}, err
 			}
-			// we can't rely on exponential backoff because it will prolong the execution too much,
-			// instead we requeue on a fix interval.
-			msg := fmt.Sprintf("Dependencies do not meet ready condition, retrying in %s", r.requeueDependency.String())
+			// the Kustomization is requeued as soon as a dependency becomes ready,
+			// the retry interval is a safety net for missed events.
+			msg := fmt.Sprintf("Dependencies do not meet ready condition, retrying when they become ready or in %s",
+				kustomization.GetRetryInterval().String())
 			log.Info(msg)
 			r.event(ctx, kustomization, source.GetArtifact().Revision, events.EventSeverityInfo, msg, nil)
 			r.recordReadiness(ctx, kustomization)
-			return ctrl.Result{RequeueAfter: r.requeueDependency}, nil
+			return ctrl.Result{RequeueAfter: kustomization.GetRetryInterval()}, nil
 		}
 		log.Info("All dependencies are ready, proceeding with reconciliation")
 	}
 
+	// keep the revision promoted by a Pipeline until the next promotion, the
+	// Kustomization is reconciled when the Pipeline patches the annotation
+	promotionPending := recordPromotionPending(&kustomization, source.GetArtifact().Revision)
+	if pending := apimeta.FindStatusCondition(kustomization.Status.Conditions, kustomizev1.PromotionPendingCondition); pending != nil {
+		if err := r.patchStatus(ctx, req, kustomization.Status); err != nil {
+			log.Error(err, "unable to update status for promotion pending")
+			return ctrl.Result{Requeue: true}, err
+		}
+		log.Info(pending.Message)
+		if promotionPending {
+			r.event(ctx, kustomization, source.GetArtifact().Revision, events.EventSeverityInfo, pending.Message, nil)
+		}
+		return ctrl.Result{}, nil
+	}
+
+	// limit the number of Kustomizations that reconcile the same source at once
+	sourceKey := sourceLimitKey(kustomization)
+	if !r.SourceLimiter.TryAcquire(sourceKey) {
+		log.Info(fmt.Sprintf("Concurrent reconciliations limit reached for source '%s', retrying in %s",
+			kustomization.Spec.SourceRef.String(), SourceLimitRequeueInterval.String()))
+		return ctrl.Result{RequeueAfter: SourceLimitRequeueInterval}, nil
+	}
+	defer r.SourceLimiter.Release(sourceKey)
+
+	// limit the number of Kustomizations that reconcile the same remote cluster at once
+	if clusterKey, ok := clusterLimitKey(kustomization); ok {
+		if !r.ClusterLimiter.TryAcquire(clusterKey) {
+			log.Info(fmt.Sprintf("Concurrent reconciliations limit reached for cluster '%s', retrying in %s",
+				clusterKey, ClusterLimitRequeueInterval.String()))
+			return ctrl.Result{RequeueAfter: ClusterLimitRequeueInterval}, nil
+		}
+		defer r.ClusterLimiter.Release(clusterKey)
+	}
+
+	// defer the reconciliation if the in-flight ones would exceed the memory budget
+	footprint := memoryFootprint(kustomization, source.GetArtifact())
+	if !r.MemoryBudget.TryReserve(footprint) {
+		log.Info(fmt.Sprintf("Memory budget exceeded, %d bytes reserved by the in-flight reconciliations, retrying in %s",
+			r.MemoryBudget.Reserved(), MemoryBudgetRequeueInterval.String()))
+		return ctrl.Result{RequeueAfter: MemoryBudgetRequeueInterval}, nil
+	}
+	defer r.MemoryBudget.Release(footprint)
+
 	// record reconciliation duration
 	if r.MetricsRecorder != nil {
 		objRef, err := reference.GetReference(r.Scheme, &kustomization)
@@ -266,6 +476,22 @@ func (r *KustomizationReconciler) Reconcile(ctx context.Context, req ctrl.Reques
 
 	// reconcile kustomization by applying the latest revision
 	reconciledKustomization, reconcileErr := r.reconcile(ctx, *kustomization.DeepCopy(), source)
+	normalizeStatus(&reconciledKustomization.Status)
+
+	// keep the status within its size limits, the truncated fields are emitted as events once per revision
+	for _, msg := range limitStatus(&reconciledKustomization.Status) {
+		log.Info(msg)
+		if source.GetArtifact().Revision != kustomization.Status.LastAttemptedRevision {
+			r.event(ctx, reconciledKustomization, source.GetArtifact().Revision, events.EventSeverityInfo, msg, nil)
+		}
+	}
+
+	// retry as soon as the definition of a kind not yet installed is registered
+	if gk, ok := missingKind(reconcileErr); ok && kustomization.Spec.KubeConfig == nil {
+		r.kindWaitList.Wait(req.NamespacedName, gk.Group)
+	} else {
+		r.kindWaitList.Forget(req.NamespacedName)
+	}
 
 	// requeue if the artifact is not found
 	if reconcileErr == ArtifactNotFoundError {
@@ -278,31 +504,76 @@ func (r *KustomizationReconciler) Reconcile(ctx context.Context, req ctrl.Reques
 		return ctrl.Result{RequeueAfter: r.requeueDependency}, nil
 	}
 
+	// summarize the Kustomizations that depend on this one
+	if err := r.recordDependents(ctx, &reconciledKustomization); err != nil {
+		log.Error(err, "unable to record dependents")
+	}
+
+	// flag the Kustomization as behind its source when the revisions differ for too long
+	r.checkRevisionSkew(ctx, &reconciledKustomization, source.GetArtifact().Revision)
+
 	if err := r.patchStatus(ctx, req, reconciledKustomization.Status); err != nil {
 		return ctrl.Result{Requeue: true}, err
 	}
 	r.recordReadiness(ctx, reconciledKustomization)
 
-	// broadcast the reconciliation failure and requeue at the specified retry interval
+	// publish the result of the reconciliation in the status-summary annotation
+	if err := r.patchStatusSummary(ctx, reconciledKustomization); err != nil {
+		log.Error(err, "unable to publish the status summary")
+	}
+
+	// record the result of the reconciliation per target cluster
+	if r.ClusterMetricsRecorder != nil {
+		r.ClusterMetricsRecorder.RecordReconcile(targetCluster(kustomization), reconcileErr, reconcileStart)
+	}
+
+	// broadcast the reconciliation failure and requeue at the specified retry interval,
+	// or at the progressing interval while the health checks are pending
 	if reconcileErr != nil {
+		retryInterval := kustomization.GetRetryInterval()
+		if isProgressing(reconciledKustomization) {
+			retryInterval = kustomization.GetProgressingInterval()
+		}
 		log.Error(reconcileErr, fmt.Sprintf("Reconciliation failed after %s, next try in %s",
 			time.Since(reconcileStart).String(),
-			kustomization.GetRetryInterval().String()),
+			retryInterval.String()),
 			"revision",
 			source.GetArtifact().Revision)
 		r.event(ctx, reconciledKustomization, source.GetArtifact().Revision, events.EventSeverityError,
 			reconcileErr.Error(), nil)
-		return ctrl.Result{RequeueAfter: kustomization.GetRetryInterval()}, nil
+		r.BackoffStore.Record(req.NamespacedName, source.GetArtifact().Revision, retryInterval)
+		return ctrl.Result{RequeueAfter: retryInterval}, nil
+	}
+	r.BackoffStore.Forget(req.NamespacedName)
+
+	// requeue at the retry interval until the deferred deletions are garbage collected
+	// and the changes deferred during a rollout are applied
+	requeueAfter := kustomization.Spec.Interval.Duration
+	deferred := reconciledKustomization.Status.DeferredDeletions > 0 || len(reconciledKustomization.Status.DeferredApplies) > 0
+	if deferred && kustomization.GetRetryInterval() < requeueAfter {
+		requeueAfter = kustomization.GetRetryInterval()
 	}
 
 	// broadcast the reconciliation result and requeue at the specified interval
 	msg := fmt.Sprintf("Reconciliation finished in %s, next run in %s",
 		time.Since(reconcileStart).String(),
-		kustomization.Spec.Interval.Duration.String())
+		requeueAfter.String())
 	log.Info(msg, "revision", source.GetArtifact().Revision)
 	r.event(ctx, reconciledKustomization, source.GetArtifact().Revision, events.EventSeverityInfo,
 		msg, map[string]string{kustomizev1.GroupVersion.Group + "/commit_status": "update"})
-	return ctrl.Result{RequeueAfter: kustomization.Spec.Interval.Duration}, nil
+	return ctrl.Result{RequeueAfter: requeueAfter}, nil
+}
+
+// backoffDelay returns the time left until the retry of the failed reconciliation
+// scheduled before the controller restart. The retry state is cleared when the
+// source revision differs from the last attempted one, as the new revision likely
+// contains the fix for the previous failures and is reconciled right away.
+func (r *KustomizationReconciler) backoffDelay(req ctrl.Request, kustomization kustomizev1.Kustomization, revision string) time.Duration {
+	if revision != kustomization.Status.LastAttemptedRevision {
+		r.BackoffStore.Forget(req.NamespacedName)
+		return 0
+	}
+	return r.BackoffStore.Delay(req.NamespacedName, revision)
 }
 
 func (r *KustomizationReconciler) reconcile(
@@ -316,6 +587,20 @@ func (r *KustomizationReconciler) reconcile(
 
 	revision := source.GetArtifact().Revision
 
+	// verify the attestations of the source artifact
+	if err := r.verifyAttestation(ctx, kustomization, source); err != nil {
+		kustomizev1.SetKustomizationAttestation(&kustomization, metav1.ConditionFalse,
+			kustomizev1.AttestationVerificationFailedReason, err.Error())
+		return kustomizev1.KustomizationNotReady(
+			kustomization,
+			revision,
+			kustomizev1.AttestationVerificationFailedReason,
+			err.Error(),
+		), err
+	}
+	kustomizev1.SetKustomizationAttestation(&kustomization, metav1.ConditionTrue,
+		kustomizev1.AttestationVerificationSucceededReason, fmt.Sprintf("Attestation verified for revision %s", revision))
+
 	// create tmp dir
 	tmpDir, err := MkdirTempAbs("", "kustomization-")
 	if err != nil {
@@ -330,7 +615,27 @@ func (r *KustomizationReconciler) reconcile(
 	defer os.RemoveAll(tmpDir)
 
 	// download artifact and extract files
-	err = r.artifactFetcher.Fetch(source.GetArtifact(), tmpDir)
+	filter, err := NewArtifactFilter(kustomization.Spec.ArtifactFilter)
+	if err != nil {
+		return kustomizev1.KustomizationNotReady(
+			kustomization,
+			revision,
+			kustomizev1.ArtifactFailedReason,
+			err.Error(),
+		), err
+	}
+	err = r.fetchArtifact(ctx, kustomization, source.GetArtifact(), tmpDir, filter)
+	if err != nil {
+		return kustomizev1.KustomizationNotReady(
+			kustomization,
+			revision,
+			kustomizev1.ArtifactFailedReason,
+			err.Error(),
+		), err
+	}
+
+	// download the additional sources and extract them inside the build root
+	err = r.fetchSources(ctx, kustomization, tmpDir)
 	if err != nil {
 		return kustomizev1.KustomizationNotReady(
 			kustomization,
@@ -372,8 +677,90 @@ func (r *KustomizationReconciler) reconcile(
 		), fmt.Errorf("failed to build kube client: %w", err)
 	}
 
-	// generate kustomization.yaml if needed
-	err = r.generate(kustomization, tmpDir, dirPath)
+	// verify the inventory on the remote cluster after a kubeconfig rotation
+	clusterID, err := impersonation.GetClusterFingerprint(ctx)
+	if err != nil {
+		return kustomizev1.KustomizationNotReady(
+			kustomization,
+			revision,
+			kustomizev1.ReconciliationFailedReason,
+			err.Error(),
+		), err
+	}
+	clusterReplaced := clusterChanged(kustomization, clusterID)
+	if clusterReplaced {
+		missing, err := missingInventoryObjects(ctx, kubeClient, kustomization.Status.Inventory)
+		if err != nil {
+			return kustomizev1.KustomizationNotReady(
+				kustomization,
+				revision,
+				kustomizev1.ReconciliationFailedReason,
+				err.Error(),
+			), err
+		}
+		msg := "KubeConfig targets a new cluster, re-applying all objects"
+		if len(missing) > 0 {
+			msg = fmt.Sprintf("KubeConfig targets a new cluster, re-applying all objects, %d objects of the inventory are missing\n%s",
+				len(missing), fmtTruncatedList(missing, maxPendingPruneEntries))
+		}
+		ctrl.LoggerFrom(ctx).Info(msg)
+		r.event(ctx, kustomization, revision, events.EventSeverityInfo, msg, nil)
+
+		// the progress of an interrupted apply doesn't hold on the new cluster
+		kustomization.Status.ApplyCheckpoint = nil
+	}
+
+	// re-apply all objects and re-assess their health after a cluster upgrade,
+	// as the API defaults may have changed between the Kubernetes versions
+	var clusterVersion string
+	if discoveryClient, err := impersonation.GetDiscoveryClient(ctx); err != nil {
+		ctrl.LoggerFrom(ctx).Error(err, "failed to create discovery client, skipping the cluster upgrade detection")
+	} else if clusterVersion, err = serverVersion(discoveryClient); err != nil {
+		ctrl.LoggerFrom(ctx).Error(err, "skipping the cluster upgrade detection")
+	}
+	upgraded := clusterUpgraded(kustomization, clusterVersion)
+	if upgraded {
+		msg := fmt.Sprintf("Cluster upgraded from %s to %s, re-applying all objects",
+			kustomization.Status.ClusterVersion, clusterVersion)
+		ctrl.LoggerFrom(ctx).Info(msg)
+		r.event(ctx, kustomization, revision, events.EventSeverityInfo, msg, nil)
+		kustomization.Status.ApplyCheckpoint = nil
+	}
+
+	// hold the reconciliation if the cluster version is out of the target range
+	if constraint := kustomization.Spec.TargetClusterVersion; constraint != "" {
+		discoveryClient, err := impersonation.GetDiscoveryClient(ctx)
+		if err != nil {
+			err = fmt.Errorf("failed to create discovery client: %w", err)
+			return kustomizev1.KustomizationNotReady(
+				kustomization,
+				revision,
+				kustomizev1.ReconciliationFailedReason,
+				err.Error(),
+			), err
+		}
+		clusterVersion, ok, err := matchClusterVersion(discoveryClient, constraint)
+		if err != nil {
+			return kustomizev1.KustomizationNotReady(
+				kustomization,
+				revision,
+				kustomizev1.ReconciliationFailedReason,
+				err.Error(),
+			), err
+		}
+		if !ok {
+			err := fmt.Errorf("cluster version %s does not satisfy the target cluster version '%s'", clusterVersion, constraint)
+			return kustomizev1.KustomizationNotReady(
+				kustomization,
+				revision,
+				kustomizev1.ClusterVersionMismatchReason,
+				err.Error(),
+			), err
+		}
+	}
+
+	// set the images referencing ImagePolicies to their latest image
+	buildKustomization, err := r.resolveImages(ctx, kustomization)
 	if err != nil {
 		return kustomizev1.KustomizationNotReady(
 			kustomization,
@@ -383,8 +770,11 @@ func (r *KustomizationReconciler) reconcile(
 		), err
 	}
 
-	// build the kustomization
-	resources, err := r.build(ctx, tmpDir, kustomization, dirPath)
+	// reuse the build result if the inputs haven't changed
+	kustomization.Status.Warnings = nil
+	kustomization.Status.LastAttemptedFailures = nil
+	kustomization.Status.DeferredApplies = nil
+	cacheKey, err := r.buildCacheKey(ctx, buildKustomization, source)
 	if err != nil {
 		return kustomizev1.KustomizationNotReady(
 			kustomization,
@@ -393,6 +783,75 @@ func (r *KustomizationReconciler) reconcile(
 			err.Error(),
 		), err
 	}
+	resources, warnings, inputsDigest, cached := r.BuildCache.Get(cacheKey)
+	if !cached {
+		// write the OpenAPI schema used to merge the patches of custom resources
+		schemaPath, err := r.openAPISchema(ctx, buildKustomization, tmpDir)
+		if err != nil {
+			return kustomizev1.KustomizationNotReady(
+				kustomization,
+				revision,
+				kustomizev1.BuildFailedReason,
+				err.Error(),
+			), err
+		}
+
+		// generate kustomization.yaml if needed
+		var sortOptions *kustomizev1.SortOptions
+		warnings, sortOptions, err = r.generate(buildKustomization, tmpDir, dirPath, schemaPath)
+		if err != nil {
+			return kustomizev1.KustomizationNotReady(
+				kustomization,
+				revision,
+				kustomizev1.BuildFailedReason,
+				err.Error(),
+			), err
+		}
+		// build the kustomization
+		var buildWarnings []string
+		var inputs *buildInputs
+		resources, buildWarnings, inputs, err = r.build(ctx, tmpDir, kustomization, dirPath, sortOptions)
+		if err != nil {
+			return kustomizev1.KustomizationNotReady(
+				kustomization,
+				revision,
+				kustomizev1.BuildFailedReason,
+				err.Error(),
+			), err
+		}
+		warnings = append(warnings, buildWarnings...)
+
+		// record the digest of the build inputs to compare configurations across clusters
+		inputsDigest, err = r.buildInputsDigest(ctx, buildKustomization, source, inputs)
+		if err != nil {
+			return kustomizev1.KustomizationNotReady(
+				kustomization,
+				revision,
+				kustomizev1.BuildFailedReason,
+				err.Error(),
+			), err
+		}
+		r.BuildCache.Set(cacheKey, resources, warnings, inputsDigest)
+	}
+	kustomization.Status.BuildInputsDigest = inputsDigest
+
+	// record the versions of the tools used for the build
+	currentToolchain := toolchain()
+	if msg := toolchainChanges(kustomization.Status.ObservedToolchain, currentToolchain); msg != "" {
+		ctrl.LoggerFrom(ctx).Info(msg)
+		r.event(ctx, kustomization, revision, events.EventSeverityInfo, msg, nil)
+	}
+	kustomization.Status.ObservedToolchain = currentToolchain
+
+	// enforce the limits on the build output before decoding it
+	if err := checkOutputSize(r.OutputLimits, kustomization.GetNamespace(), resources); err != nil {
+		return kustomizev1.KustomizationNotReady(
+			kustomization,
+			revision,
+			kustomizev1.OutputLimitExceededReason,
+			err.Error(),
+		), err
+	}
 
 	// convert the build result into Kubernetes unstructured objects
 	objects, err := ssa.ReadObjects(bytes.NewReader(resources))
@@ -404,27 +863,297 @@ func (r *KustomizationReconciler) reconcile(
 			err.Error(),
 		), err
 	}
+	if err := checkOutputObjects(r.OutputLimits, kustomization.GetNamespace(), len(objects)); err != nil {
+		return kustomizev1.KustomizationNotReady(
+			kustomization,
+			revision,
+			kustomizev1.OutputLimitExceededReason,
+			err.Error(),
+		), err
+	}
+
+	// report the image overrides that don't match any container
+	if opts := kustomization.Spec.BuildOptions; opts != nil && opts.UnmatchedImages != "" {
+		if unmatched := unmatchedImages(buildKustomization.Spec.Images, objects); len(unmatched) > 0 {
+			err := fmt.Errorf("images not found in the rendered objects: %s", strings.Join(unmatched, ", "))
+			if opts.UnmatchedImages == kustomizev1.ErrorBuildPolicy {
+				return kustomizev1.KustomizationNotReady(
+					kustomization,
+					revision,
+					kustomizev1.BuildFailedReason,
+					err.Error(),
+				), err
+			}
+			warnings = append(warnings, err.Error())
+		}
+	}
+
+	// report the objects using API versions removed by the next Kubernetes minor version
+	if opts := kustomization.Spec.BuildOptions; opts != nil && opts.DeprecatedAPIs != "" {
+		discoveryClient, err := impersonation.GetDiscoveryClient(ctx)
+		if err != nil {
+			err = fmt.Errorf("failed to create discovery client: %w", err)
+			return kustomizev1.KustomizationNotReady(
+				kustomization,
+				revision,
+				kustomizev1.ReconciliationFailedReason,
+				err.Error(),
+			), err
+		}
+		minor, err := clusterMinorVersion(discoveryClient)
+		if err != nil {
+			return kustomizev1.KustomizationNotReady(
+				kustomization,
+				revision,
+				kustomizev1.ReconciliationFailedReason,
+				err.Error(),
+			), err
+		}
+		if deprecated := deprecatedAPIs(objects, minor); len(deprecated) > 0 {
+			if opts.DeprecatedAPIs == kustomizev1.ErrorBuildPolicy {
+				err := fmt.Errorf("deprecated API versions found: %s", strings.Join(deprecated, "; "))
+				return kustomizev1.KustomizationNotReady(
+					kustomization,
+					revision,
+					kustomizev1.BuildFailedReason,
+					err.Error(),
+				), err
+			}
+			warnings = append(warnings, deprecated...)
+		}
+	}
+
+	// surface the build warnings in status and, once per revision, as events
+	kustomization.Status.Warnings = warnings
+	for _, warning := range warnings {
+		ctrl.LoggerFrom(ctx).Info(warning)
+		if revision != kustomization.Status.LastAttemptedRevision {
+			r.event(ctx, kustomization, revision, events.EventSeverityInfo, warning, nil)
+		}
+	}
+
+	// record the rendered objects that are taken over from other Kustomizations
+	kustomization.Status.TakeoverClaims = nil
+	if len(kustomization.Spec.TakeoverFrom) > 0 {
+		claims, err := r.takeoverRenders(ctx, kustomization, objects)
+		if err != nil {
+			return kustomizev1.KustomizationNotReady(
+				kustomization,
+				revision,
+				kustomizev1.ReconciliationFailedReason,
+				err.Error(),
+			), err
+		}
+		kustomization.Status.TakeoverClaims = claims
+	}
+
+	// create a snapshot of the current inventory
+	oldStatus := kustomization.Status.DeepCopy()
+
+	// take over the inventory of the Kustomization this one was renamed from
+	migratedInventory, migratedFrom, err := r.migrateInventory(ctx, kustomization)
+	if err != nil {
+		reason := kustomizev1.ReconciliationFailedReason
+		if acl.IsAccessDenied(err) {
+			reason = apiacl.AccessDeniedReason
+		}
+		return kustomizev1.KustomizationNotReady(
+			kustomization,
+			revision,
+			reason,
+			err.Error(),
+		), err
+	}
+	if migratedInventory != nil {
+		oldStatus.Inventory = migratedInventory
+		r.event(ctx, kustomization, revision, events.EventSeverityInfo,
+			fmt.Sprintf("Inventory migrated from Kustomization '%s'", migratedFrom), nil)
+	}
+
+	// create the server-side apply manager
+	resourceManager := ssa.NewResourceManager(kubeClient, statusPoller, ssa.Owner{
+		Field: r.ControllerName,
+		Group: kustomizev1.GroupVersion.Group,
+	})
+	setOwnerLabels(objects, ownerLabels(resourceManager, kustomization))
+
+	// leave out the objects owned by other controllers
+	objects, excludedObjects, err := excludeObjects(kustomization, objects)
+	if err != nil {
+		return kustomizev1.KustomizationNotReady(
+			kustomization,
+			revision,
+			kustomizev1.ReconciliationFailedReason,
+			err.Error(),
+		), err
+	}
+	logDecisions(ctx, "exclusion", 0, skipDecisions(object.UnstructuredSetToObjMetadataSet(excludedObjects),
+		"excluded from apply, left to the controller that owns the object"))
+	if len(excludedObjects) > 0 {
+		msg := fmt.Sprintf("excluded %d objects from apply\n%s",
+			len(excludedObjects), fmtTruncatedList(excludedObjects, maxPendingPruneEntries))
+		ctrl.LoggerFrom(ctx).Info(msg)
+		r.event(ctx, kustomization, revision, events.EventSeverityInfo, msg, nil)
+	}
+
+	// set aside the verification Jobs, they are run after the health checks
+	objects, verificationJobs := splitVerificationJobs(objects)
+	logDecisions(ctx, "verification", 0, skipDecisions(object.UnstructuredSetToObjMetadataSet(verificationJobs),
+		"verification Job, run after the health checks"))
+
+	// report the reconcile policies carried by the objects
+	policies := objectPolicies(objects)
+	kustomization.Status.ObjectPolicies = NewObjectPolicies(policies)
+	if err := checkObjectPolicies(policies, r.AllowedObjectPolicies); err != nil {
+		return kustomizev1.KustomizationNotReady(
+			kustomization,
+			revision,
+			kustomizev1.ReconciliationFailedReason,
+			err.Error(),
+		), err
+	}
+
+	// enforce the limits on the number of managed objects
+	if err := checkObjectQuota(r.ObjectQuota, objects); err != nil {
+		return kustomizev1.KustomizationNotReady(
+			kustomization,
+			revision,
+			kustomizev1.QuotaExceededReason,
+			err.Error(),
+		), err
+	}
+	if err := r.checkNamespaceQuota(ctx, kustomization, objects); err != nil {
+		return kustomizev1.KustomizationNotReady(
+			kustomization,
+			revision,
+			kustomizev1.QuotaExceededReason,
+			err.Error(),
+		), err
+	}
+
+	// reject the container images referenced by tag
+	if r.RequireImageDigests {
+		if err := checkImageDigests(objects); err != nil {
+			return kustomizev1.KustomizationNotReady(
+				kustomization,
+				revision,
+				kustomizev1.ImageDigestRequiredReason,
+				err.Error(),
+			), err
+		}
+	}
+
+	// hold the first apply until the server-side dry-run is approved
+	kustomization.Status.FirstApplyReview = nil
+	if observesFirstReconcile(kustomization) {
+		review, err := reviewFirstApply(ctx, resourceManager, revision, objects)
+		if err != nil {
+			return kustomizev1.KustomizationNotReady(
+				kustomization,
+				revision,
+				kustomizev1.ReconciliationFailedReason,
+				err.Error(),
+			), err
+		}
+		if kustomization.GetAnnotations()[kustomizev1.FirstApplyApprovalAnnotation] != review.Digest {
+			err = fmt.Errorf("first apply is waiting for approval, %d objects would be created and %d configured, "+
+				"annotate the Kustomization with '%s: %s' to proceed",
+				review.Created, review.Configured, kustomizev1.FirstApplyApprovalAnnotation, review.Digest)
+			k := kustomizev1.KustomizationNotReady(
+				kustomization,
+				revision,
+				kustomizev1.FirstApplyApprovalRequiredReason,
+				err.Error(),
+			)
+			k.Status.FirstApplyReview = review
+			return k, err
+		}
+	}
+
+	// defer the changes to the workloads that are being rolled out
+	var deferredObjects []*unstructured.Unstructured
+	if opts := kustomization.Spec.ApplyOptions; opts != nil && opts.DeferDuringRollout {
+		objects, deferredObjects, err = deferRollingOut(ctx, resourceManager, objects)
+		if err != nil {
+			return kustomizev1.KustomizationNotReady(
+				kustomization,
+				revision,
+				kustomizev1.ReconciliationFailedReason,
+				err.Error(),
+			), err
+		}
+		logDecisions(ctx, "deferral", 0, skipDecisions(object.UnstructuredSetToObjMetadataSet(deferredObjects),
+			"workload rollout in progress, apply deferred"))
+		if len(deferredObjects) > 0 {
+			kustomization.Status.DeferredApplies = objectsToResourceRefs(deferredObjects)
+			msg := fmt.Sprintf("deferred the apply of %d objects until their rollout completes\n%s",
+				len(deferredObjects), fmtTruncatedList(deferredObjects, maxPendingPruneEntries))
+			ctrl.LoggerFrom(ctx).Info(msg)
+			r.event(ctx, kustomization, revision, events.EventSeverityInfo, msg, nil)
+		}
+	}
+
+	// resume the apply interrupted at the same revision
+	checkpoint, err := newApplyCheckpoint(kustomization.Status.ApplyCheckpoint, revision, objects)
+	if err != nil {
+		return kustomizev1.KustomizationNotReady(
+			kustomization,
+			revision,
+			kustomizev1.ReconciliationFailedReason,
+			err.Error(),
+		), err
+	}
+	if checkpoint.resume > 0 {
+		ctrl.LoggerFrom(ctx).Info(fmt.Sprintf("Resuming the apply of revision %s from object %d",
+			revision, checkpoint.resume))
+	}
+	checkpoint.persist = func(ctx context.Context, c *kustomizev1.ApplyCheckpoint) error {
+		k := kustomization.DeepCopy()
+		k.Status.ApplyCheckpoint = c
+		return r.patchStatus(ctx, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(k)}, k.Status)
+	}
 
-	// create a snapshot of the current inventory
-	oldStatus := kustomization.Status.DeepCopy()
-
-	// create the server-side apply manager
-	resourceManager := ssa.NewResourceManager(kubeClient, statusPoller, ssa.Owner{
-		Field: r.ControllerName,
-		Group: kustomizev1.GroupVersion.Group,
-	})
-	resourceManager.SetOwnerLabels(objects, kustomization.GetName(), kustomization.GetNamespace())
+	// record the images of the workloads set by the image overrides
+	var previousImages map[string]map[string]string
+	if len(buildKustomization.Spec.Images) > 0 {
+		previousImages, err = liveImages(ctx, kubeClient, overriddenWorkloads(buildKustomization.Spec.Images, objects))
+		if err != nil {
+			ctrl.LoggerFrom(ctx).Error(err, "unable to read the images of the overridden workloads")
+		}
+	}
 
 	// validate and apply resources in stages
-	drifted, changeSet, err := r.apply(ctx, resourceManager, kustomization, revision, objects)
+	timings := &applyTimings{}
+	applyStart := time.Now()
+	drifted, changeSet, err := r.apply(ctx, resourceManager, kustomization, revision, objects, timings, checkpoint)
+	if r.ClusterMetricsRecorder != nil {
+		r.ClusterMetricsRecorder.RecordApply(targetCluster(kustomization), applyStart)
+	}
+	kustomization.Status.SlowestApplies = timings.slowest(maxSlowestApplies)
+	kustomization.Status.ApplyCheckpoint = checkpoint.status(err)
+	kustomization.Status.LastAttemptedFailures = applyFailures(err)
 	if err != nil {
+		reason := kustomizev1.ReconciliationFailedReason
+		if _, ok := missingKind(err); ok {
+			reason = kustomizev1.NotYetInstalledReason
+		}
 		return kustomizev1.KustomizationNotReady(
 			kustomization,
 			revision,
-			kustomizev1.ReconciliationFailedReason,
+			reason,
 			err.Error(),
 		), err
 	}
+	kustomization.Status.ClusterFingerprint = clusterID
+	if clusterVersion != "" {
+		kustomization.Status.ClusterVersion = clusterVersion
+	}
+	drifted = drifted || clusterReplaced || upgraded
+
+	// emit an event for every container image changed by the apply
+	for _, change := range imageChanges(previousImages, objects, changeSet) {
+		r.imageChangedEvent(kustomization, revision, change)
+	}
 
 	// create an inventory of objects to be reconciled
 	newInventory := NewInventory()
@@ -438,6 +1167,13 @@ func (r *KustomizationReconciler) reconcile(
 		), err
 	}
 
+	// record the applied container images of the workloads
+	AddImagesToInventory(newInventory, objects)
+
+	// keep the workloads deferred during a rollout in the inventory until they are applied
+	newInventory.Entries = append(newInventory.Entries, objectsToResourceRefs(deferredObjects)...)
+	KeepInventoryImages(newInventory, oldStatus.Inventory, deferredObjects)
+
 	// detect stale objects which are subject to garbage collection
 	var staleObjects []*unstructured.Unstructured
 	if oldStatus.Inventory != nil {
@@ -474,6 +1210,224 @@ func (r *KustomizationReconciler) reconcile(
 		}
 	}
 
+	// objects validated with dry-run are not in the inventory but must not be deleted
+	staleObjects = withoutObjects(staleObjects, policies[kustomizev1.DryRunObjectPolicy])
+
+	// objects excluded from apply are left to the controller that owns them
+	staleObjects = withoutObjects(staleObjects, excludedObjects)
+
+	// hand over the stale objects taken over by other Kustomizations
+	kustomization.Status.HeldForTakeover = nil
+	if kustomization.Spec.Prune {
+		stale, held, takers, err := r.handOverObjects(ctx, kustomization, staleObjects)
+		if err != nil {
+			return kustomizev1.KustomizationNotReadyInventory(
+				kustomization,
+				newInventory,
+				revision,
+				kustomizev1.PruneFailedReason,
+				err.Error(),
+			), err
+		}
+		if len(held) > 0 {
+			// keep track of the stale objects until the takeover completes
+			heldRefs := objectsToResourceRefs(held)
+			newInventory.Entries = append(newInventory.Entries, heldRefs...)
+			kustomization.Status.HeldForTakeover = heldRefs
+
+			msg := fmt.Sprintf("garbage collection of %d objects is held until taken over by Kustomization %s\n%s",
+				len(held), strings.Join(takers, ", "), fmtTruncatedList(held, maxPendingPruneEntries))
+			ctrl.LoggerFrom(ctx).Info(msg)
+			r.event(ctx, kustomization, revision, events.EventSeverityInfo, msg, nil)
+		}
+		staleObjects = stale
+	}
+
+	// keep the previous generations of the generated ConfigMaps and Secrets
+	if history := generatedHistory(kustomization); kustomization.Spec.Prune && history > 0 {
+		stale, kept, err := keepGeneratedHistory(ctx, kubeClient, history, objects, staleObjects)
+		if err != nil {
+			return kustomizev1.KustomizationNotReadyInventory(
+				kustomization,
+				newInventory,
+				revision,
+				kustomizev1.PruneFailedReason,
+				err.Error(),
+			), err
+		}
+		newInventory.Entries = append(newInventory.Entries, objectsToResourceRefs(kept)...)
+		logDecisions(ctx, "generated history", 0, skipDecisions(object.UnstructuredSetToObjMetadataSet(kept),
+			"previous generation of a generated object, kept from garbage collection"))
+		staleObjects = stale
+	}
+
+	// report the objects taken over from other Kustomizations
+	if len(kustomization.Spec.TakeoverFrom) > 0 {
+		takeoverLog, err := r.takeoverChangeLog(ctx, kustomization, oldStatus.Inventory, newInventory)
+		if err != nil {
+			return kustomizev1.KustomizationNotReadyInventory(
+				kustomization,
+				newInventory,
+				revision,
+				kustomizev1.ReconciliationFailedReason,
+				err.Error(),
+			), err
+		}
+		if takeoverLog != "" {
+			r.event(ctx, kustomization, revision, events.EventSeverityInfo, takeoverLog, nil)
+		}
+	}
+
+	// report the objects that garbage collection would delete when prune is disabled,
+	// when it's enabled the stale objects are deleted below
+	kustomization.Status.PruneReport = nil
+	if kustomization.Spec.Prune && r.PruneReportRecorder != nil {
+		r.PruneReportRecorder.Delete(kustomization)
+	}
+	if !kustomization.Spec.Prune && (r.PruneReportRecorder != nil || pruneReportEnabled(kustomization)) {
+		prunable, err := prunableObjects(ctx, kubeClient, ownerLabels(resourceManager, kustomization), staleObjects)
+		if err != nil {
+			return kustomizev1.KustomizationNotReadyInventory(
+				kustomization,
+				newInventory,
+				revision,
+				kustomizev1.PruneFailedReason,
+				err.Error(),
+			), err
+		}
+		if r.PruneReportRecorder != nil {
+			r.PruneReportRecorder.Record(kustomization, len(prunable))
+		}
+		if pruneReportEnabled(kustomization) {
+			kustomization.Status.PruneReport = NewPruneReport(revision, prunable)
+			// keep reporting the objects until they are deleted or applied again
+			newInventory.Entries = append(newInventory.Entries, objectsToResourceRefs(prunable)...)
+		}
+	}
+
+	// hold the garbage collection of mass deletions until approved
+	kustomization.Status.PendingPrune = nil
+	kustomization.Status.DeferredDeletions = 0
+	if kustomization.Spec.Prune && len(staleObjects) > 0 {
+		approvalRequired, err := requiresPruneApproval(kustomization, oldStatus.Inventory, staleObjects)
+		if err != nil {
+			return kustomizev1.KustomizationNotReadyInventory(
+				kustomization,
+				newInventory,
+				revision,
+				kustomizev1.PruneFailedReason,
+				err.Error(),
+			), err
+		}
+
+		pendingPrune := NewPendingPrune(revision, staleObjects)
+		approved := kustomization.GetAnnotations()[kustomizev1.PruneApprovalAnnotation] == pendingPrune.Digest
+		if approvalRequired && !approved {
+			// keep track of the stale objects until the deletion is approved
+			newInventory.Entries = append(newInventory.Entries, objectsToResourceRefs(staleObjects)...)
+
+			err = fmt.Errorf("garbage collection of %d objects is waiting for approval, "+
+				"annotate the Kustomization with '%s: %s' to proceed\n%s",
+				len(staleObjects), kustomizev1.PruneApprovalAnnotation, pendingPrune.Digest,
+				fmtTruncatedList(staleObjects, maxPendingPruneEntries))
+			k := kustomizev1.KustomizationNotReadyInventory(
+				kustomization,
+				newInventory,
+				revision,
+				kustomizev1.PruneApprovalRequiredReason,
+				err.Error(),
+			)
+			k.Status.PendingPrune = pendingPrune
+			return k, err
+		}
+
+		// enforce the disruption budget on deletions that were not explicitly approved
+		if !approved {
+			allowed, deferred, err := splitByPruneBudget(kustomization, oldStatus.Inventory, staleObjects)
+			if err != nil {
+				return kustomizev1.KustomizationNotReadyInventory(
+					kustomization,
+					newInventory,
+					revision,
+					kustomizev1.PruneFailedReason,
+					err.Error(),
+				), err
+			}
+
+			if len(deferred) > 0 {
+				budget := kustomization.Spec.PruneOptions.DisruptionBudget
+				if budget.Policy == kustomizev1.BlockPrunePolicy {
+					newInventory.Entries = append(newInventory.Entries, objectsToResourceRefs(staleObjects)...)
+					err = fmt.Errorf("garbage collection of %d objects exceeds the disruption budget of %s\n%s",
+						len(staleObjects), budget.MaxDeletions.String(), fmtTruncatedList(staleObjects, maxPendingPruneEntries))
+					return kustomizev1.KustomizationNotReadyInventory(
+						kustomization,
+						newInventory,
+						revision,
+						kustomizev1.PruneBudgetExceededReason,
+						err.Error(),
+					), err
+				}
+
+				// keep track of the deferred objects, they are deleted in the next reconciliations
+				newInventory.Entries = append(newInventory.Entries, objectsToResourceRefs(deferred)...)
+				r.event(ctx, kustomization, revision, events.EventSeverityInfo,
+					fmt.Sprintf("garbage collection of %d objects deferred due to the disruption budget of %s",
+						len(deferred), budget.MaxDeletions.String()), nil)
+				kustomization.Status.DeferredDeletions = len(deferred)
+				staleObjects = allowed
+			}
+		}
+
+		// honor the PodDisruptionBudgets of the workloads that were not explicitly approved for deletion
+		if policy := podDisruptionPolicy(kustomization); !approved && policy != "" {
+			disruptions, err := podDisruptions(ctx, kubeClient, staleObjects)
+			if err != nil {
+				return kustomizev1.KustomizationNotReadyInventory(
+					kustomization,
+					newInventory,
+					revision,
+					kustomizev1.PruneFailedReason,
+					err.Error(),
+				), err
+			}
+
+			if len(disruptions) > 0 {
+				var sb strings.Builder
+				for _, d := range disruptions {
+					sb.WriteString(d.String() + "\n")
+				}
+				violations := strings.TrimSuffix(sb.String(), "\n")
+
+				if policy == kustomizev1.BlockPodDisruptionPolicy {
+					newInventory.Entries = append(newInventory.Entries, objectsToResourceRefs(staleObjects)...)
+					err = fmt.Errorf("garbage collection of %d objects would violate the PodDisruptionBudgets\n%s",
+						len(staleObjects), violations)
+					return kustomizev1.KustomizationNotReadyInventory(
+						kustomization,
+						newInventory,
+						revision,
+						kustomizev1.PodDisruptionBudgetViolatedReason,
+						err.Error(),
+					), err
+				}
+
+				ctrl.LoggerFrom(ctx).Info(violations)
+				if revision != kustomization.Status.LastAttemptedRevision {
+					r.event(ctx, kustomization, revision, events.EventSeverityInfo, violations, nil)
+				}
+			}
+		}
+	}
+
+	// invoke the prune hooks, the objects whose hooks failed are kept in the inventory
+	var hooksErr error
+	if kustomization.Spec.Prune && len(staleObjects) > 0 {
+		var retained []*unstructured.Unstructured
+		staleObjects, retained, hooksErr = r.runPruneHooks(ctx, kubeClient, resourceManager, kustomization, revision, staleObjects)
+		newInventory.Entries = append(newInventory.Entries, objectsToResourceRefs(retained)...)
+	}
+
 	// run garbage collection for stale objects that do not have pruning disabled
 	if _, err := r.prune(ctx, resourceManager, kustomization, revision, staleObjects); err != nil {
 		return kustomizev1.KustomizationNotReadyInventory(
@@ -485,8 +1439,56 @@ func (r *KustomizationReconciler) reconcile(
 		), err
 	}
 
-	// health assessment
-	if err := r.checkHealth(ctx, resourceManager, kustomization, revision, drifted, changeSet.ToObjMetadataSet()); err != nil {
+	// delete the namespaces left empty by garbage collection
+	if kustomization.Spec.Prune {
+		nsLog, err := r.pruneEmptyNamespaces(ctx, kubeClient, impersonation, resourceManager, kustomization, staleObjects, newInventory)
+		if err != nil {
+			return kustomizev1.KustomizationNotReadyInventory(
+				kustomization,
+				newInventory,
+				revision,
+				kustomizev1.PruneFailedReason,
+				err.Error(),
+			), err
+		}
+		if nsLog != "" {
+			ctrl.LoggerFrom(ctx).Info(fmt.Sprintf("garbage collection of empty namespaces completed: %s", nsLog))
+			r.event(ctx, kustomization, revision, events.EventSeverityInfo, nsLog, nil)
+		}
+	}
+
+	// delete the finished Jobs that are no longer part of the inventory
+	jobsLog, err := r.deleteFinishedJobs(ctx, kubeClient, resourceManager, kustomization, newInventory)
+	if err != nil {
+		ctrl.LoggerFrom(ctx).Error(err, "unable to delete finished Jobs")
+	}
+	if jobsLog != "" {
+		ctrl.LoggerFrom(ctx).Info(fmt.Sprintf("garbage collection of finished Jobs completed: %s", jobsLog))
+		r.event(ctx, kustomization, revision, events.EventSeverityInfo, jobsLog, nil)
+	}
+
+	if hooksErr != nil {
+		return kustomizev1.KustomizationNotReadyInventory(
+			kustomization,
+			newInventory,
+			revision,
+			kustomizev1.PruneHookFailedReason,
+			hooksErr.Error(),
+		), hooksErr
+	}
+
+	// health assessment, restricted to the objects matching the wait selectors
+	waitSet, err := waitObjects(kustomization, objects, changeSet.ToObjMetadataSet())
+	if err != nil {
+		return kustomizev1.KustomizationNotReadyInventory(
+			kustomization,
+			newInventory,
+			revision,
+			kustomizev1.HealthCheckFailedReason,
+			err.Error(),
+		), err
+	}
+	if err := r.checkHealth(ctx, resourceManager, kustomization, revision, drifted, waitSet); err != nil {
 		return kustomizev1.KustomizationNotReadyInventory(
 			kustomization,
 			newInventory,
@@ -496,6 +1498,31 @@ func (r *KustomizationReconciler) reconcile(
 		), err
 	}
 
+	// run the verification Jobs once the objects are healthy
+	if len(verificationJobs) > 0 {
+		coreClient, err := impersonation.GetCoreClient(ctx)
+		if err != nil {
+			err = fmt.Errorf("failed to create core client: %w", err)
+			return kustomizev1.KustomizationNotReadyInventory(
+				kustomization,
+				newInventory,
+				revision,
+				kustomizev1.ReconciliationFailedReason,
+				err.Error(),
+			), err
+		}
+		if err := r.runVerificationJobs(ctx, kubeClient, coreClient, kustomization, revision, verificationJobs,
+			ownerLabels(resourceManager, kustomization), kustomization.GetTimeout()); err != nil {
+			return kustomizev1.KustomizationNotReadyInventory(
+				kustomization,
+				newInventory,
+				revision,
+				kustomizev1.VerificationFailedReason,
+				err.Error(),
+			), err
+		}
+	}
+
 	return kustomizev1.KustomizationReadyInventory(
 		kustomization,
 		newInventory,
@@ -537,23 +1564,28 @@ func (r *KustomizationReconciler) checkDependencies(source sourcev1.Source, kust
 }
 
 func (r *KustomizationReconciler) getSource(ctx context.Context, kustomization kustomizev1.Kustomization) (sourcev1.Source, error) {
+	return r.getSourceByRef(ctx, kustomization, kustomization.Spec.SourceRef)
+}
+
+func (r *KustomizationReconciler) getSourceByRef(ctx context.Context, kustomization kustomizev1.Kustomization,
+	sourceRef kustomizev1.CrossNamespaceSourceReference) (sourcev1.Source, error) {
 	var source sourcev1.Source
 	sourceNamespace := kustomization.GetNamespace()
-	if kustomization.Spec.SourceRef.Namespace != "" {
-		sourceNamespace = kustomization.Spec.SourceRef.Namespace
+	if sourceRef.Namespace != "" {
+		sourceNamespace = sourceRef.Namespace
 	}
 	namespacedName := types.NamespacedName{
 		Namespace: sourceNamespace,
-		Name:      kustomization.Spec.SourceRef.Name,
+		Name:      sourceRef.Name,
 	}
 
 	if r.NoCrossNamespaceRefs && sourceNamespace != kustomization.GetNamespace() {
 		return source, acl.AccessDeniedError(
 			fmt.Sprintf("can't access '%s/%s', cross-namespace references have been blocked",
-				kustomization.Spec.SourceRef.Kind, namespacedName))
+				sourceRef.Kind, namespacedName))
 	}
 
-	switch kustomization.Spec.SourceRef.Kind {
+	switch sourceRef.Kind {
 	case sourcev1.OCIRepositoryKind:
 		var repository sourcev1.OCIRepository
 		err := r.Client.Get(ctx, namespacedName, &repository)
@@ -586,70 +1618,147 @@ func (r *KustomizationReconciler) getSource(ctx context.Context, kustomization k
 		source = &bucket
 	default:
 		return source, fmt.Errorf("source `%s` kind '%s' not supported",
-			kustomization.Spec.SourceRef.Name, kustomization.Spec.SourceRef.Kind)
+			sourceRef.Name, sourceRef.Kind)
 	}
 	return source, nil
 }
 
-func (r *KustomizationReconciler) generate(kustomization kustomizev1.Kustomization, workDir string, dirPath string) error {
+// generate writes the kustomization.yaml at dirPath, and returns the build warnings,
+// including the unknown fields of the existing kustomization file if the policy allows it.
+func (r *KustomizationReconciler) generate(kustomization kustomizev1.Kustomization, workDir string, dirPath string,
+	openAPISchema string) ([]string, *kustomizev1.SortOptions, error) {
 	gen := NewGenerator(workDir, kustomization)
-	return gen.WriteFile(dirPath)
+	gen.openAPISchema = openAPISchema
+
+	var warnings []string
+	if err := gen.ValidateFile(dirPath); err != nil {
+		if opts := kustomization.Spec.BuildOptions; opts != nil && opts.UnknownFields == kustomizev1.ErrorBuildPolicy {
+			return nil, nil, err
+		}
+		warnings = append(warnings, err.Error())
+	}
+
+	if err := gen.WriteFile(dirPath); err != nil {
+		return nil, nil, err
+	}
+	return append(warnings, gen.Warnings()...), gen.SortOptions(), nil
 }
 
-func (r *KustomizationReconciler) build(ctx context.Context, workDir string, kustomization kustomizev1.Kustomization, dirPath string) ([]byte, error) {
+func (r *KustomizationReconciler) build(ctx context.Context, workDir string, kustomization kustomizev1.Kustomization,
+	dirPath string, sortOptions *kustomizev1.SortOptions) ([]byte, []string, *buildInputs, error) {
 	dec, cleanup, err := NewTempDecryptor(workDir, r.Client, kustomization)
 	if err != nil {
-		return nil, err
+		return nil, nil, nil, err
 	}
 	defer cleanup()
 
 	// Import decryption keys
 	if err := dec.ImportKeys(ctx); err != nil {
-		return nil, err
+		return nil, nil, nil, err
 	}
 
 	// Decrypt Kustomize EnvSources files before build
 	if err = dec.DecryptEnvSources(dirPath); err != nil {
-		return nil, fmt.Errorf("error decrypting env sources: %w", err)
+		return nil, nil, nil, fmt.Errorf("error decrypting env sources: %w", err)
+	}
+
+	// resolve the resources declared more than once as set by the build options
+	duplicatePolicy := kustomizev1.ErrorBuildPolicy
+	if opts := kustomization.Spec.BuildOptions; opts != nil && opts.DuplicateResources != "" {
+		duplicatePolicy = opts.DuplicateResources
 	}
 
-	m, err := secureBuildKustomization(workDir, dirPath, !r.NoRemoteBases)
+	// inflate the helmCharts of the kustomization files if allowed by the controller
+	var helmCommand string
+	if opts := kustomization.Spec.BuildOptions; opts != nil && opts.EnableHelm {
+		switch {
+		case !r.EnableHelm:
+			return nil, nil, nil, fmt.Errorf("the Helm chart inflation is disabled, the controller must be started with --enable-helm")
+		case r.SandboxBuild:
+			return nil, nil, nil, fmt.Errorf("the Helm chart inflation is not supported by the sandboxed builds")
+		}
+		helmCommand = r.HelmCommand
+	}
+
+	var m resmap.ResMap
+	var warnings []string
+	for i := 0; ; i++ {
+		if r.SandboxBuild {
+			m, err = sandboxBuildKustomization(ctx, workDir, dirPath)
+		} else {
+			m, err = secureBuildKustomization(workDir, dirPath, !r.NoRemoteBases, helmCommand)
+		}
+		if err == nil || i == maxDuplicateResolutions {
+			break
+		}
+		warning, resolveErr := resolveDuplicateResource(workDir, dirPath, err, duplicatePolicy)
+		if resolveErr != nil {
+			err = resolveErr
+			break
+		}
+		warnings = append(warnings, warning)
+	}
 	if err != nil {
-		return nil, fmt.Errorf("kustomize build failed: %w", err)
+		if pos := buildErrorContext(workDir, dirPath, err); pos != "" {
+			return nil, nil, nil, fmt.Errorf("kustomize build failed: %w\nmalformed manifest: %s", err, pos)
+		}
+		return nil, nil, nil, fmt.Errorf("kustomize build failed: %w", err)
+	}
+
+	// order the objects as set by the sortOptions
+	if err := sortResources(m, sortOptions); err != nil {
+		return nil, nil, nil, fmt.Errorf("kustomize build failed: %w", err)
+	}
+
+	// set the target namespace on the objects that are not exempted
+	if ns := kustomization.Spec.TargetNamespace; ns != "" && len(kustomization.Spec.TargetNamespaceExemptions) > 0 {
+		if err := setTargetNamespace(m, ns, kustomization.Spec.TargetNamespaceExemptions); err != nil {
+			return nil, nil, nil, fmt.Errorf("kustomize build failed: %w", err)
+		}
+	}
+
+	// load the vars once, the external secrets are read at every build
+	var vars map[string]string
+	if kustomization.Spec.PostBuild != nil {
+		vars, err = loadVariables(ctx, r.Client, r.ExternalSecrets, kustomization, r.DefaultSubstitutions)
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("var substitution failed: %w", err)
+		}
 	}
+	inputs := &buildInputs{vars: vars, decryptionKeys: dec.keysVersion}
 
 	for _, res := range m.Resources() {
 		// check if resources conform to the Kubernetes API conventions
 		if res.GetName() == "" || res.GetKind() == "" || res.GetApiVersion() == "" {
-			return nil, fmt.Errorf("failed to decode Kubernetes apiVersion, kind and name from: %v", res.String())
+			return nil, nil, nil, fmt.Errorf("failed to decode Kubernetes apiVersion, kind and name from: %v", res.String())
 		}
 
 		// check if resources are encrypted and decrypt them before generating the final YAML
 		if kustomization.Spec.Decryption != nil {
 			outRes, err := dec.DecryptResource(res)
 			if err != nil {
-				return nil, fmt.Errorf("decryption failed for '%s': %w", res.GetName(), err)
+				return nil, nil, nil, fmt.Errorf("decryption failed for '%s': %w", res.GetName(), err)
 			}
 
 			if outRes != nil {
 				_, err = m.Replace(res)
 				if err != nil {
-					return nil, err
+					return nil, nil, nil, err
 				}
 			}
 		}
 
 		// run variable substitutions
 		if kustomization.Spec.PostBuild != nil {
-			outRes, err := substituteVariables(ctx, r.Client, kustomization, res)
+			outRes, err := substituteVariables(vars, res)
 			if err != nil {
-				return nil, fmt.Errorf("var substitution failed for '%s': %w", res.GetName(), err)
+				return nil, nil, nil, fmt.Errorf("var substitution failed for '%s': %w", res.GetName(), err)
 			}
 
 			if outRes != nil {
 				_, err = m.Replace(res)
 				if err != nil {
-					return nil, err
+					return nil, nil, nil, err
 				}
 			}
 		}
@@ -657,13 +1766,13 @@ func (r *KustomizationReconciler) build(ctx context.Context, workDir string, kus
 
 	resources, err := m.AsYaml()
 	if err != nil {
-		return nil, fmt.Errorf("kustomize build failed: %w", err)
+		return nil, nil, nil, fmt.Errorf("kustomize build failed: %w", err)
 	}
 
-	return resources, nil
+	return resources, warnings, inputs, nil
 }
 
-func (r *KustomizationReconciler) apply(ctx context.Context, manager *ssa.ResourceManager, kustomization kustomizev1.Kustomization, revision string, objects []*unstructured.Unstructured) (bool, *ssa.ChangeSet, error) {
+func (r *KustomizationReconciler) apply(ctx context.Context, manager *ssa.ResourceManager, kustomization kustomizev1.Kustomization, revision string, objects []*unstructured.Unstructured, timings *applyTimings, checkpoint *applyCheckpoint) (bool, *ssa.ChangeSet, error) {
 	log := ctrl.LoggerFrom(ctx)
 
 	if err := ssa.SetNativeKindsDefaults(objects); err != nil {
@@ -720,6 +1829,9 @@ func (r *KustomizationReconciler) apply(ctx context.Context, manager *ssa.Resour
 	// contains all objects except for CRDs and Namespaces
 	var stageTwo []*unstructured.Unstructured
 
+	// contains the objects validated with a server-side dry-run only
+	var dryRun []*unstructured.Unstructured
+
 	// contains the objects' metadata after apply
 	resultSet := ssa.NewChangeSet()
 
@@ -730,7 +1842,9 @@ func (r *KustomizationReconciler) apply(ctx context.Context, manager *ssa.Resour
 					ssa.FmtUnstructured(u))
 		}
 
-		if ssa.IsClusterDefinition(u) {
+		if hasObjectPolicy(u, kustomizev1.DryRunObjectPolicy) {
+			dryRun = append(dryRun, u)
+		} else if ssa.IsClusterDefinition(u) {
 			stageOne = append(stageOne, u)
 		} else {
 			stageTwo = append(stageTwo, u)
@@ -739,13 +1853,20 @@ func (r *KustomizationReconciler) apply(ctx context.Context, manager *ssa.Resour
 
 	var changeSetLog strings.Builder
 
+	// skip the CRDs and Namespaces applied before an interruption
+	skipped, stageOne := checkpoint.skip(stageOne)
+	resultSet.Append(skipped)
+	logDecisions(ctx, "apply definitions", 0, skipDecisions(changeSetIDs(skipped), "applied before the interruption"))
+
 	// validate, apply and wait for CRDs and Namespaces to register
 	if len(stageOne) > 0 {
-		changeSet, err := manager.ApplyAll(ctx, stageOne, applyOpts)
+		applyStart := time.Now()
+		changeSet, err := applyAll(ctx, manager, stageOne, applyOpts, objectTimeout(kustomization), timings)
 		if err != nil {
-			return false, nil, err
+			return false, nil, collectFailures(ctx, manager, stageOne, applyOpts, err)
 		}
 		resultSet.Append(changeSet.Entries)
+		logDecisions(ctx, "apply definitions", time.Since(applyStart), applyDecisions(changeSet.Entries, timings))
 
 		if changeSet != nil && len(changeSet.Entries) > 0 {
 			log.Info("server-side apply completed", "output", changeSet.ToMap())
@@ -762,24 +1883,80 @@ func (r *KustomizationReconciler) apply(ctx context.Context, manager *ssa.Resour
 		}); err != nil {
 			return false, nil, err
 		}
+
+		if err := checkpoint.done(ctx, len(stageOne)); err != nil {
+			return false, nil, err
+		}
 	}
 
-	// sort by kind, validate and apply all the others objects
-	sort.Sort(ssa.SortableUnstructureds(stageTwo))
-	if len(stageTwo) > 0 {
-		changeSet, err := manager.ApplyAll(ctx, stageTwo, applyOpts)
-		if err != nil {
-			return false, nil, fmt.Errorf("%w\n%s", err, changeSetLog.String())
+	// split the others objects in the apply stages sorted by kind
+	stages, err := applyStages(kustomization, stageTwo)
+	if err != nil {
+		return false, nil, err
+	}
+
+	// validate and apply the objects of each stage, in batches recorded
+	// in the checkpoint for large change sets, and wait for them to become
+	// ready before applying the next stage
+	for i, stage := range stages {
+		phase := "apply"
+		if stage.name != "" {
+			phase = fmt.Sprintf("apply stage '%s'", stage.name)
 		}
-		resultSet.Append(changeSet.Entries)
+		skipped, pending := checkpoint.skip(stage.objects)
+		resultSet.Append(skipped)
+		logDecisions(ctx, phase, 0, skipDecisions(changeSetIDs(skipped), "applied before the interruption"))
+		for len(pending) > 0 {
+			batch := pending[:checkpoint.batchSize(len(pending))]
+			pending = pending[len(batch):]
+
+			applyStart := time.Now()
+			changeSet, err := applyAll(ctx, manager, batch, applyOpts, objectTimeout(kustomization), timings)
+			if err != nil {
+				err = collectFailures(ctx, manager, batch, applyOpts, err)
+				return false, nil, fmt.Errorf("%w\n%s", err, changeSetLog.String())
+			}
+			resultSet.Append(changeSet.Entries)
+			logDecisions(ctx, phase, time.Since(applyStart), applyDecisions(changeSet.Entries, timings))
 
-		if changeSet != nil && len(changeSet.Entries) > 0 {
-			log.Info("server-side apply completed", "output", changeSet.ToMap())
-			for _, change := range changeSet.Entries {
-				if change.Action != string(ssa.UnchangedAction) {
-					changeSetLog.WriteString(change.String() + "\n")
+			if changeSet != nil && len(changeSet.Entries) > 0 {
+				log.Info("server-side apply completed", "output", changeSet.ToMap())
+				for _, change := range changeSet.Entries {
+					if change.Action != string(ssa.UnchangedAction) {
+						changeSetLog.WriteString(change.String() + "\n")
+					}
 				}
 			}
+
+			if err := checkpoint.done(ctx, len(batch)); err != nil {
+				return false, nil, err
+			}
+		}
+
+		if i == len(stages)-1 {
+			continue
+		}
+		if err := manager.WaitForSet(stage.ids(), ssa.WaitOptions{
+			Interval: 5 * time.Second,
+			Timeout:  kustomization.GetTimeout(),
+		}); err != nil {
+			return false, nil, fmt.Errorf("apply stage '%s' health check failed: %w\n%s",
+				stage.name, err, changeSetLog.String())
+		}
+		log.Info(fmt.Sprintf("apply stage '%s' is ready", stage.name))
+	}
+
+	// validate the objects with the DryRun policy without persisting them
+	logDecisions(ctx, "dry-run", 0, skipDecisions(object.UnstructuredSetToObjMetadataSet(dryRun),
+		"validated with a server-side dry-run by the DryRun policy"))
+	for _, u := range dryRun {
+		change, _, _, err := manager.Diff(ctx, u, ssa.DiffOptions{Exclusions: applyOpts.Exclusions})
+		if err != nil {
+			err = &applyFailuresError{err: err, failures: []kustomizev1.ObjectFailure{newObjectFailure(u, err)}}
+			return false, nil, fmt.Errorf("%w\n%s", err, changeSetLog.String())
+		}
+		if change != nil && change.Action != string(ssa.UnchangedAction) {
+			log.Info("server-side apply dry-run completed", "output", change.String())
 		}
 	}
 
@@ -792,8 +1969,48 @@ func (r *KustomizationReconciler) apply(ctx context.Context, manager *ssa.Resour
 	return applyLog != "", resultSet, nil
 }
 
+// applyAll applies the objects on the cluster, the objects with the Force
+// policy are recreated when patching fails due to an immutable field change.
+func applyAll(ctx context.Context, manager *ssa.ResourceManager, objects []*unstructured.Unstructured,
+	opts ssa.ApplyOptions, timeout time.Duration, timings *applyTimings) (*ssa.ChangeSet, error) {
+	if timeout > 0 {
+		return applyEach(ctx, manager, objects, opts, timeout, timings)
+	}
+
+	var forced, others []*unstructured.Unstructured
+	for _, u := range objects {
+		if !opts.Force && hasObjectPolicy(u, kustomizev1.ForceObjectPolicy) {
+			forced = append(forced, u)
+		} else {
+			others = append(others, u)
+		}
+	}
+
+	changeSet := ssa.NewChangeSet()
+	if len(others) > 0 {
+		cs, err := manager.ApplyAll(ctx, others, opts)
+		if err != nil {
+			return nil, err
+		}
+		changeSet.Append(cs.Entries)
+	}
+
+	if len(forced) > 0 {
+		forceOpts := opts
+		forceOpts.Force = true
+		cs, err := manager.ApplyAll(ctx, forced, forceOpts)
+		if err != nil {
+			return nil, err
+		}
+		changeSet.Append(cs.Entries)
+	}
+
+	return changeSet, nil
+}
+
 func (r *KustomizationReconciler) checkHealth(ctx context.Context, manager *ssa.ResourceManager, kustomization kustomizev1.Kustomization, revision string, drifted bool, objects object.ObjMetadataSet) error {
-	if len(kustomization.Spec.HealthChecks) == 0 && !kustomization.Spec.Wait {
+	if len(kustomization.Spec.HealthChecks) == 0 && !kustomization.Spec.Wait &&
+		len(kustomization.Spec.EndpointHealthChecks) == 0 {
 		return nil
 	}
 
@@ -806,7 +2023,7 @@ func (r *KustomizationReconciler) checkHealth(ctx context.Context, manager *ssa.
 		}
 	}
 
-	if len(objects) == 0 {
+	if len(objects) == 0 && len(kustomization.Spec.EndpointHealthChecks) == 0 {
 		return nil
 	}
 
@@ -821,6 +2038,19 @@ func (r *KustomizationReconciler) checkHealth(ctx context.Context, manager *ssa.
 		toCheck = append(toCheck, object)
 	}
 
+	// skip the workloads that are intentionally paused
+	if readyWhenPaused(kustomization) && len(toCheck) > 0 {
+		active, paused, err := excludePausedWorkloads(ctx, manager.Client(), toCheck)
+		if err != nil {
+			return fmt.Errorf("Health check failed, %w", err)
+		}
+		for _, obj := range paused {
+			ctrl.LoggerFrom(ctx).Info(fmt.Sprintf("Health check skipped for paused %s/%s/%s",
+				obj.GroupKind.Kind, obj.Namespace, obj.Name))
+		}
+		toCheck = active
+	}
+
 	// find the previous health check result
 	wasHealthy := apimeta.IsStatusConditionTrue(kustomization.Status.Conditions, kustomizev1.HealthyCondition)
 
@@ -833,11 +2063,39 @@ func (r *KustomizationReconciler) checkHealth(ctx context.Context, manager *ssa.
 	}
 
 	// check the health with a default timeout of 30sec shorter than the reconciliation interval
-	if err := manager.WaitForSet(toCheck, ssa.WaitOptions{
-		Interval: 5 * time.Second,
-		Timeout:  kustomization.GetTimeout(),
-	}); err != nil {
-		return fmt.Errorf("Health check failed after %s, %w", time.Since(checkStart).String(), err)
+	if len(toCheck) > 0 {
+		if err := manager.WaitForSet(toCheck, ssa.WaitOptions{
+			Interval: 5 * time.Second,
+			Timeout:  kustomization.GetTimeout(),
+		}); err != nil {
+			return fmt.Errorf("Health check failed after %s, %w", time.Since(checkStart).String(), err)
+		}
+	}
+
+	// probe the endpoints within what's left of the timeout
+	if len(kustomization.Spec.EndpointHealthChecks) > 0 {
+		if r.EndpointProber == nil {
+			return fmt.Errorf("endpoint health checks are disabled, the controller must be started with --endpoint-health-check-hosts")
+		}
+		if err := r.EndpointProber.checkEndpoints(ctx, kustomization.Spec.EndpointHealthChecks,
+			kustomization.GetTimeout()-time.Since(checkStart)); err != nil {
+			return fmt.Errorf("Health check failed after %s, %w", time.Since(checkStart).String(), err)
+		}
+	}
+
+	// wait for the health checks to stay green after a change
+	if window := stabilityWindow(kustomization); window > 0 &&
+		(!wasHealthy || kustomization.Status.LastAppliedRevision != revision || drifted) {
+		message := fmt.Sprintf("health checks passed, waiting for them to stay green for %s", window.String())
+		k := kustomizev1.KustomizationProgressing(kustomization, message)
+		kustomizev1.SetKustomizationHealthiness(&k, metav1.ConditionUnknown, meta.ProgressingReason, message)
+		if err := r.patchStatus(ctx, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&kustomization)}, k.Status); err != nil {
+			return fmt.Errorf("unable to update the healthy status to progressing, error: %w", err)
+		}
+
+		if err := checkStability(ctx, manager, toCheck, r.EndpointProber, kustomization.Spec.EndpointHealthChecks, window); err != nil {
+			return fmt.Errorf("Health check failed after %s, %w", time.Since(checkStart).String(), err)
+		}
 	}
 
 	// emit event if the previous health check failed
@@ -849,6 +2107,14 @@ func (r *KustomizationReconciler) checkHealth(ctx context.Context, manager *ssa.
 	return nil
 }
 
+// isProgressing returns true if the reconciliation failed
+// because the health checks did not pass yet.
+func isProgressing(kustomization kustomizev1.Kustomization) bool {
+	ready := apimeta.FindStatusCondition(kustomization.Status.Conditions, meta.ReadyCondition)
+	return ready != nil && ready.Status == metav1.ConditionFalse &&
+		ready.Reason == kustomizev1.HealthCheckFailedReason
+}
+
 func (r *KustomizationReconciler) prune(ctx context.Context, manager *ssa.ResourceManager, kustomization kustomizev1.Kustomization, revision string, objects []*unstructured.Unstructured) (bool, error) {
 	if !kustomization.Spec.Prune {
 		return false, nil
@@ -856,19 +2122,25 @@ func (r *KustomizationReconciler) prune(ctx context.Context, manager *ssa.Resour
 
 	log := ctrl.LoggerFrom(ctx)
 
+	if ownerLabelsDisabled(kustomization) {
+		log.Info(fmt.Sprintf("garbage collection skipped for %d objects, owner labels are disabled", len(objects)))
+		return false, nil
+	}
+
 	opts := ssa.DeleteOptions{
 		PropagationPolicy: metav1.DeletePropagationBackground,
-		Inclusions:        manager.GetOwnerLabels(kustomization.Name, kustomization.Namespace),
-		Exclusions: map[string]string{
-			fmt.Sprintf("%s/prune", kustomizev1.GroupVersion.Group):     kustomizev1.DisabledValue,
-			fmt.Sprintf("%s/reconcile", kustomizev1.GroupVersion.Group): kustomizev1.DisabledValue,
-		},
+		Inclusions:        ownerLabels(manager, kustomization),
+		Exclusions:        pruneExclusions,
 	}
 
+	pruneStart := time.Now()
 	changeSet, err := manager.DeleteAll(ctx, objects, opts)
 	if err != nil {
 		return false, err
 	}
+	if changeSet != nil {
+		logDecisions(ctx, "prune", time.Since(pruneStart), pruneDecisions(changeSet.Entries))
+	}
 
 	// emit event only if the prune operation resulted in changes
 	if changeSet != nil && len(changeSet.Entries) > 0 {
@@ -882,7 +2154,19 @@ func (r *KustomizationReconciler) prune(ctx context.Context, manager *ssa.Resour
 
 func (r *KustomizationReconciler) finalize(ctx context.Context, kustomization kustomizev1.Kustomization) (ctrl.Result, error) {
 	log := ctrl.LoggerFrom(ctx)
+
+	// skip pruning if the inventory is being migrated to another Kustomization
+	claimed, err := r.isInventoryClaimed(ctx, kustomization)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if claimed {
+		log.Info("Inventory claimed by another Kustomization, skipping garbage collection")
+	}
+
 	if kustomization.Spec.Prune &&
+		!claimed &&
+		!ownerLabelsDisabled(kustomization) &&
 		!kustomization.Spec.Suspend &&
 		kustomization.Status.Inventory != nil &&
 		kustomization.Status.Inventory.Entries != nil {
@@ -902,13 +2186,17 @@ func (r *KustomizationReconciler) finalize(ctx context.Context, kustomization ku
 
 			opts := ssa.DeleteOptions{
 				PropagationPolicy: metav1.DeletePropagationBackground,
-				Inclusions:        resourceManager.GetOwnerLabels(kustomization.Name, kustomization.Namespace),
+				Inclusions:        ownerLabels(resourceManager, kustomization),
 				Exclusions: map[string]string{
 					fmt.Sprintf("%s/prune", kustomizev1.GroupVersion.Group):     kustomizev1.DisabledValue,
 					fmt.Sprintf("%s/reconcile", kustomizev1.GroupVersion.Group): kustomizev1.DisabledValue,
 				},
 			}
 
+			// invoke the prune hooks, the objects whose hooks failed are retried at the next attempt
+			var hooksErr error
+			objects, _, hooksErr = r.runPruneHooks(ctx, kubeClient, resourceManager, kustomization, kustomization.Status.LastAppliedRevision, objects)
+
 			changeSet, err := resourceManager.DeleteAll(ctx, objects, opts)
 			if err != nil {
 				r.event(ctx, kustomization, kustomization.Status.LastAppliedRevision, events.EventSeverityError, "pruning for deleted resource failed", nil)
@@ -919,6 +2207,21 @@ func (r *KustomizationReconciler) finalize(ctx context.Context, kustomization ku
 			if changeSet != nil && len(changeSet.Entries) > 0 {
 				r.event(ctx, kustomization, kustomization.Status.LastAppliedRevision, events.EventSeverityInfo, changeSet.String(), nil)
 			}
+
+			nsLog, err := r.pruneEmptyNamespaces(ctx, kubeClient, impersonation, resourceManager, kustomization, objects, nil)
+			if err != nil {
+				r.event(ctx, kustomization, kustomization.Status.LastAppliedRevision, events.EventSeverityError, "pruning of empty namespaces failed", nil)
+				return ctrl.Result{}, err
+			}
+			if nsLog != "" {
+				r.event(ctx, kustomization, kustomization.Status.LastAppliedRevision, events.EventSeverityInfo, nsLog, nil)
+			}
+
+			if hooksErr != nil {
+				r.event(ctx, kustomization, kustomization.Status.LastAppliedRevision, events.EventSeverityError, hooksErr.Error(), nil)
+				// Return the error so we retry the objects whose prune hooks failed
+				return ctrl.Result{}, hooksErr
+			}
 		} else {
 			// when the account to impersonate is gone, log the stale objects and continue with the finalization
 			msg := fmt.Sprintf("unable to prune objects: \n%s", ssa.FmtUnstructuredList(objects))
@@ -929,6 +2232,12 @@ func (r *KustomizationReconciler) finalize(ctx context.Context, kustomization ku
 
 	// Record deleted status
 	r.recordReadiness(ctx, kustomization)
+	if r.DependentsRecorder != nil {
+		r.DependentsRecorder.Record(kustomization, nil)
+	}
+	if r.PruneReportRecorder != nil {
+		r.PruneReportRecorder.Delete(kustomization)
+	}
 
 	// Remove our finalizer from the list and update it
 	controllerutil.RemoveFinalizer(&kustomization, kustomizev1.KustomizationFinalizer)
@@ -1009,5 +2318,6 @@ func (r *KustomizationReconciler) patchStatus(ctx context.Context, req ctrl.Requ
 
 	patch := client.MergeFrom(kustomization.DeepCopy())
 	kustomization.Status = newStatus
+	kustomization.Status.Summary = kustomizev1.NewStatusSummary(newStatus)
 	return r.Status().Patch(ctx, &kustomization, patch, client.FieldOwner(r.statusManager))
 }
@@ -0,0 +1,105 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"errors"
+	"strings"
+	"sync"
+
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// missingKind returns the group and kind of the object that failed to apply
+// because its CustomResourceDefinition is not registered.
+func missingKind(err error) (schema.GroupKind, bool) {
+	var noKindMatch *apimeta.NoKindMatchError
+	if errors.As(err, &noKindMatch) {
+		return noKindMatch.GroupKind, true
+	}
+	return schema.GroupKind{}, false
+}
+
+// kindWaitList records the Kustomizations waiting for the CustomResourceDefinitions
+// of an API group to be registered, so that they can be reconciled as soon as
+// a definition of that group is created or updated.
+type kindWaitList struct {
+	mu      sync.Mutex
+	waiting map[types.NamespacedName]string
+}
+
+func newKindWaitList() *kindWaitList {
+	return &kindWaitList{
+		waiting: make(map[types.NamespacedName]string),
+	}
+}
+
+// Wait records the Kustomization as waiting for the API group.
+func (w *kindWaitList) Wait(key types.NamespacedName, group string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.waiting[key] = group
+}
+
+// Forget removes the Kustomization from the wait list.
+func (w *kindWaitList) Forget(key types.NamespacedName) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	delete(w.waiting, key)
+}
+
+// Waiting returns the Kustomizations waiting for the API group.
+func (w *kindWaitList) Waiting(group string) []types.NamespacedName {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	var keys []types.NamespacedName
+	for key, g := range w.waiting {
+		if g == group {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// customResourceDefinitionMetadata returns the object used to watch the
+// metadata of the CustomResourceDefinitions, without caching their schemas.
+func customResourceDefinitionMetadata() *metav1.PartialObjectMetadata {
+	crd := &metav1.PartialObjectMetadata{}
+	crd.SetGroupVersionKind(apiextensionsv1.SchemeGroupVersion.WithKind("CustomResourceDefinition"))
+	return crd
+}
+
+// requestsForDefinitionChange returns the Kustomizations waiting for the API group
+// of the CustomResourceDefinition, the group is the part of the name, formatted
+// as '<plural>.<group>', that follows the first dot.
+func (r *KustomizationReconciler) requestsForDefinitionChange(obj client.Object) []reconcile.Request {
+	parts := strings.SplitN(obj.GetName(), ".", 2)
+	if len(parts) != 2 {
+		return nil
+	}
+	var reqs []reconcile.Request
+	for _, key := range r.kindWaitList.Waiting(parts[1]) {
+		reqs = append(reqs, reconcile.Request{NamespacedName: key})
+	}
+	return reqs
+}
@@ -0,0 +1,68 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func Test_missingKind(t *testing.T) {
+	g := NewWithT(t)
+
+	noMatch := &apimeta.NoKindMatchError{
+		GroupKind:        schema.GroupKind{Group: "cert-manager.io", Kind: "Certificate"},
+		SearchedVersions: []string{"v1"},
+	}
+	err := fmt.Errorf("Certificate/apps/tls dry-run failed, error: %w", noMatch)
+
+	gk, ok := missingKind(fmt.Errorf("%w\n%s", err, "Namespace/apps created"))
+	g.Expect(ok).To(BeTrue())
+	g.Expect(gk).To(Equal(noMatch.GroupKind))
+
+	_, ok = missingKind(fmt.Errorf("ConfigMap/apps/config dry-run failed, error: invalid"))
+	g.Expect(ok).To(BeFalse())
+	_, ok = missingKind(nil)
+	g.Expect(ok).To(BeFalse())
+}
+
+func TestKustomizationReconciler_requestsForDefinitionChange(t *testing.T) {
+	g := NewWithT(t)
+
+	apps := types.NamespacedName{Namespace: "default", Name: "apps"}
+	infra := types.NamespacedName{Namespace: "default", Name: "infra"}
+
+	r := &KustomizationReconciler{kindWaitList: newKindWaitList()}
+	r.kindWaitList.Wait(apps, "cert-manager.io")
+	r.kindWaitList.Wait(infra, "monitoring.coreos.com")
+
+	crd := customResourceDefinitionMetadata()
+	crd.SetName("certificates.cert-manager.io")
+	g.Expect(r.requestsForDefinitionChange(crd)).To(Equal([]reconcile.Request{{NamespacedName: apps}}))
+
+	r.kindWaitList.Forget(apps)
+	g.Expect(r.requestsForDefinitionChange(crd)).To(BeEmpty())
+
+	crd.SetName("invalid")
+	g.Expect(r.requestsForDefinitionChange(crd)).To(BeEmpty())
+}
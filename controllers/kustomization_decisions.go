@@ -0,0 +1,138 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"time"
+
+	"sigs.k8s.io/cli-utils/pkg/object"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	"github.com/fluxcd/pkg/runtime/logger"
+	"github.com/fluxcd/pkg/ssa"
+)
+
+// The decisions taken for each object during the apply and the garbage collection.
+const (
+	createdDecision    = "created"
+	configuredDecision = "configured"
+	unchangedDecision  = "unchanged"
+	skippedDecision    = "skipped"
+	prunedDecision     = "pruned"
+)
+
+// objectDecision is the decision taken for an object, logged at debug level
+// to allow the forensic analysis of a rollout.
+type objectDecision struct {
+	id       string
+	decision string
+	reason   string
+
+	// duration is the time taken to apply the object, zero if not measured
+	// because the object was applied in a batch.
+	duration time.Duration
+}
+
+// applyDecisions returns the decisions for the entries of an apply change set,
+// with the per-object durations recorded in the timings.
+func applyDecisions(entries []ssa.ChangeSetEntry, timings *applyTimings) []objectDecision {
+	durations := make(map[string]time.Duration)
+	if timings != nil {
+		for _, entry := range timings.entries {
+			durations[entry.ID] = entry.Duration.Duration
+		}
+	}
+
+	decisions := make([]objectDecision, 0, len(entries))
+	for _, entry := range entries {
+		id := entry.ObjMetadata.String()
+		d := objectDecision{id: id, duration: durations[id]}
+		switch ssa.Action(entry.Action) {
+		case ssa.CreatedAction:
+			d.decision, d.reason = createdDecision, "object not found on the cluster"
+		case ssa.ConfiguredAction:
+			d.decision, d.reason = configuredDecision, "object differs from the desired state"
+		case ssa.UnchangedAction:
+			d.decision, d.reason = unchangedDecision, "object matches the desired state or has reconcile disabled"
+		default:
+			d.decision, d.reason = entry.Action, "unknown apply result"
+		}
+		decisions = append(decisions, d)
+	}
+	return decisions
+}
+
+// pruneDecisions returns the decisions for the entries of a garbage collection change set.
+func pruneDecisions(entries []ssa.ChangeSetEntry) []objectDecision {
+	decisions := make([]objectDecision, 0, len(entries))
+	for _, entry := range entries {
+		d := objectDecision{id: entry.ObjMetadata.String()}
+		switch ssa.Action(entry.Action) {
+		case ssa.DeletedAction:
+			d.decision, d.reason = prunedDecision, "object removed from the source"
+		default:
+			d.decision, d.reason = skippedDecision, "object not owned by the Kustomization or has prune disabled"
+		}
+		decisions = append(decisions, d)
+	}
+	return decisions
+}
+
+// skipDecisions returns the decisions for the objects left out for the given reason.
+func skipDecisions(ids object.ObjMetadataSet, reason string) []objectDecision {
+	decisions := make([]objectDecision, 0, len(ids))
+	for _, id := range ids {
+		decisions = append(decisions, objectDecision{
+			id:       id.String(),
+			decision: skippedDecision,
+			reason:   reason,
+		})
+	}
+	return decisions
+}
+
+// changeSetIDs returns the IDs of the objects of the change set entries.
+func changeSetIDs(entries []ssa.ChangeSetEntry) object.ObjMetadataSet {
+	ids := make(object.ObjMetadataSet, 0, len(entries))
+	for _, entry := range entries {
+		ids = append(ids, entry.ObjMetadata)
+	}
+	return ids
+}
+
+// logDecisions logs one structured record per decision at debug level, with the phase
+// of the reconciliation and the time the phase took for the whole batch of objects.
+func logDecisions(ctx context.Context, phase string, elapsed time.Duration, decisions []objectDecision) {
+	log := ctrl.LoggerFrom(ctx).V(logger.DebugLevel)
+	if !log.Enabled() {
+		return
+	}
+	for _, d := range decisions {
+		keysAndValues := []interface{}{
+			"object", d.id,
+			"decision", d.decision,
+			"reason", d.reason,
+			"phase", phase,
+			"phaseDuration", elapsed.String(),
+		}
+		if d.duration > 0 {
+			keysAndValues = append(keysAndValues, "duration", d.duration.String())
+		}
+		log.Info("object decision", keysAndValues...)
+	}
+}
@@ -0,0 +1,100 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/cli-utils/pkg/object"
+
+	"github.com/fluxcd/pkg/ssa"
+
+	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta2"
+)
+
+func decisionEntry(kind, name string, action ssa.Action) ssa.ChangeSetEntry {
+	return ssa.ChangeSetEntry{
+		ObjMetadata: object.ObjMetadata{
+			Namespace: "apps",
+			Name:      name,
+			GroupKind: schema.GroupKind{Group: "apps", Kind: kind},
+		},
+		Action: string(action),
+	}
+}
+
+func Test_applyDecisions(t *testing.T) {
+	g := NewWithT(t)
+
+	entries := []ssa.ChangeSetEntry{
+		decisionEntry("Deployment", "frontend", ssa.CreatedAction),
+		decisionEntry("Deployment", "backend", ssa.ConfiguredAction),
+		decisionEntry("StatefulSet", "db", ssa.UnchangedAction),
+	}
+	timings := &applyTimings{entries: []kustomizev1.ApplyDuration{
+		{ID: entries[1].ObjMetadata.String(), Duration: metav1.Duration{Duration: 2 * time.Second}},
+	}}
+
+	decisions := applyDecisions(entries, timings)
+	g.Expect(decisions).To(HaveLen(3))
+
+	g.Expect(decisions[0].id).To(Equal("apps_frontend_apps_Deployment"))
+	g.Expect(decisions[0].decision).To(Equal(createdDecision))
+	g.Expect(decisions[0].duration).To(BeZero())
+
+	g.Expect(decisions[1].decision).To(Equal(configuredDecision))
+	g.Expect(decisions[1].duration).To(Equal(2 * time.Second))
+
+	g.Expect(decisions[2].decision).To(Equal(unchangedDecision))
+	g.Expect(decisions[2].reason).ToNot(BeEmpty())
+
+	g.Expect(applyDecisions(entries, nil)[1].duration).To(BeZero())
+}
+
+func Test_pruneDecisions(t *testing.T) {
+	g := NewWithT(t)
+
+	decisions := pruneDecisions([]ssa.ChangeSetEntry{
+		decisionEntry("Deployment", "legacy", ssa.DeletedAction),
+		decisionEntry("Deployment", "kept", ssa.UnchangedAction),
+	})
+	g.Expect(decisions).To(HaveLen(2))
+	g.Expect(decisions[0].decision).To(Equal(prunedDecision))
+	g.Expect(decisions[1].decision).To(Equal(skippedDecision))
+}
+
+func Test_skipDecisions(t *testing.T) {
+	g := NewWithT(t)
+
+	skipped := []ssa.ChangeSetEntry{
+		decisionEntry("Deployment", "frontend", ssa.UnchangedAction),
+		decisionEntry("Deployment", "backend", ssa.UnchangedAction),
+	}
+	decisions := skipDecisions(changeSetIDs(skipped), "applied before the interruption")
+	g.Expect(decisions).To(HaveLen(2))
+	for i, d := range decisions {
+		g.Expect(d.id).To(Equal(skipped[i].ObjMetadata.String()))
+		g.Expect(d.decision).To(Equal(skippedDecision))
+		g.Expect(d.reason).To(Equal("applied before the interruption"))
+	}
+
+	g.Expect(skipDecisions(nil, "excluded")).To(BeEmpty())
+}
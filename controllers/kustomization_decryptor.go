@@ -144,6 +144,9 @@ type KustomizeDecryptor struct {
 	// gcpCredsJSON is the JSON credential file of the service account used to
 	// authenticate towards any GCP KMS.
 	gcpCredsJSON []byte
+	// keysVersion is the version of the decryption Secret the keys were
+	// imported from, set by ImportKeys().
+	keysVersion string
 
 	// keyServices are the SOPS keyservice.KeyServiceClient's available to the
 	// decryptor.
@@ -213,6 +216,7 @@ func (d *KustomizeDecryptor) ImportKeys(ctx context.Context) error {
 			}
 			return fmt.Errorf("cannot get %s decryption Secret '%s': %w", provider, secretName, err)
 		}
+		d.keysVersion = objectVersion(&secret)
 
 		var err error
 		for name, value := range secret.Data {
@@ -342,6 +342,7 @@ func TestKustomizeDecryptor_ImportKeys(t *testing.T) {
 			},
 			inspectFunc: func(g *GomegaWithT, decryptor *KustomizeDecryptor) {
 				g.Expect(decryptor.ageIdentities).To(HaveLen(1))
+				g.Expect(decryptor.keysVersion).ToNot(BeEmpty())
 			},
 		},
 		{
@@ -0,0 +1,138 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/prometheus/client_golang/prometheus"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+
+	"github.com/fluxcd/pkg/apis/meta"
+
+	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta2"
+)
+
+// DependentsRecorder records the number of dependents of the Kustomizations.
+type DependentsRecorder struct {
+	dependentsGauge *prometheus.GaugeVec
+}
+
+// NewDependentsRecorder returns a DependentsRecorder, its collectors
+// must be registered with the metrics registry.
+func NewDependentsRecorder() *DependentsRecorder {
+	return &DependentsRecorder{
+		dependentsGauge: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "gotk_kustomization_dependents",
+				Help: "The number of Kustomizations depending on a Kustomization, by type: direct, total or blocked.",
+			},
+			[]string{"name", "namespace", "type"},
+		),
+	}
+}
+
+// Collectors returns the metrics.Collector objects for the DependentsRecorder.
+func (r *DependentsRecorder) Collectors() []prometheus.Collector {
+	return []prometheus.Collector{r.dependentsGauge}
+}
+
+// Record sets the gauges of the Kustomization from its dependents summary,
+// the gauges are removed when the summary is nil.
+func (r *DependentsRecorder) Record(kustomization kustomizev1.Kustomization, summary *kustomizev1.DependentsSummary) {
+	name, namespace := kustomization.GetName(), kustomization.GetNamespace()
+	if summary == nil {
+		for _, t := range []string{"direct", "total", "blocked"} {
+			r.dependentsGauge.DeleteLabelValues(name, namespace, t)
+		}
+		return
+	}
+	r.dependentsGauge.WithLabelValues(name, namespace, "direct").Set(float64(summary.Direct))
+	r.dependentsGauge.WithLabelValues(name, namespace, "total").Set(float64(summary.Total))
+	r.dependentsGauge.WithLabelValues(name, namespace, "blocked").Set(float64(summary.Blocked))
+}
+
+// recordDependents computes the dependents summary of the Kustomization
+// and records it in the status and the metrics.
+func (r *KustomizationReconciler) recordDependents(ctx context.Context, kustomization *kustomizev1.Kustomization) error {
+	var list kustomizev1.KustomizationList
+	if err := r.List(ctx, &list); err != nil {
+		return fmt.Errorf("unable to list Kustomizations: %w", err)
+	}
+
+	kustomization.Status.Dependents = summarizeDependents(*kustomization, list.Items)
+	if r.DependentsRecorder != nil {
+		r.DependentsRecorder.Record(*kustomization, kustomization.Status.Dependents)
+	}
+	return nil
+}
+
+// summarizeDependents returns the number of Kustomizations that depend on the given one,
+// directly or transitively, and how many of them are waiting for their dependencies.
+// It returns nil if there are no dependents.
+func summarizeDependents(kustomization kustomizev1.Kustomization, all []kustomizev1.Kustomization) *kustomizev1.DependentsSummary {
+	key := func(namespace, name string) string {
+		return fmt.Sprintf("%s/%s", namespace, name)
+	}
+
+	dependents := make(map[string][]int)
+	for i, k := range all {
+		for _, dep := range k.Spec.DependsOn {
+			namespace := k.GetNamespace()
+			if dep.Namespace != "" {
+				namespace = dep.Namespace
+			}
+			parent := key(namespace, dep.Name)
+			dependents[parent] = append(dependents[parent], i)
+		}
+	}
+
+	root := key(kustomization.GetNamespace(), kustomization.GetName())
+	visited := map[string]bool{root: true}
+	summary := &kustomizev1.DependentsSummary{}
+	queue := []string{root}
+	for depth := 0; len(queue) > 0; depth++ {
+		var next []string
+		for _, parent := range queue {
+			for _, i := range dependents[parent] {
+				k := all[i]
+				id := key(k.GetNamespace(), k.GetName())
+				if visited[id] {
+					continue
+				}
+				visited[id] = true
+
+				if depth == 0 {
+					summary.Direct++
+				}
+				summary.Total++
+				if c := apimeta.FindStatusCondition(k.Status.Conditions, meta.ReadyCondition); c != nil &&
+					c.Reason == kustomizev1.DependencyNotReadyReason {
+					summary.Blocked++
+				}
+				next = append(next, id)
+			}
+		}
+		queue = next
+	}
+
+	if summary.Total == 0 {
+		return nil
+	}
+	return summary
+}
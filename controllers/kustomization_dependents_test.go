@@ -0,0 +1,69 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/fluxcd/pkg/apis/meta"
+
+	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta2"
+)
+
+func Test_summarizeDependents(t *testing.T) {
+	g := NewWithT(t)
+
+	newKustomization := func(namespace, name, reason string, dependsOn ...meta.NamespacedObjectReference) kustomizev1.Kustomization {
+		k := kustomizev1.Kustomization{
+			ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name},
+			Spec:       kustomizev1.KustomizationSpec{DependsOn: dependsOn},
+		}
+		if reason != "" {
+			k.Status.Conditions = []metav1.Condition{{Type: meta.ReadyCondition, Status: metav1.ConditionFalse, Reason: reason}}
+		}
+		return k
+	}
+
+	base := newKustomization("flux-system", "base", "",
+		meta.NamespacedObjectReference{Name: "monitoring"})
+	all := []kustomizev1.Kustomization{
+		base,
+		newKustomization("flux-system", "infra", kustomizev1.DependencyNotReadyReason,
+			meta.NamespacedObjectReference{Name: "base"}),
+		newKustomization("flux-system", "monitoring", kustomizev1.DependencyNotReadyReason,
+			meta.NamespacedObjectReference{Name: "infra"}, meta.NamespacedObjectReference{Name: "base"}),
+		newKustomization("apps", "frontend", kustomizev1.BuildFailedReason,
+			meta.NamespacedObjectReference{Name: "base", Namespace: "flux-system"}),
+		newKustomization("apps", "backend", "",
+			meta.NamespacedObjectReference{Name: "base"}),
+	}
+
+	g.Expect(summarizeDependents(base, all)).To(Equal(&kustomizev1.DependentsSummary{
+		Direct:  3,
+		Total:   3,
+		Blocked: 2,
+	}))
+	g.Expect(summarizeDependents(all[1], all)).To(Equal(&kustomizev1.DependentsSummary{
+		Direct:  1,
+		Total:   3,
+		Blocked: 1,
+	}))
+	g.Expect(summarizeDependents(all[3], all)).To(BeNil())
+}
@@ -0,0 +1,129 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/version"
+	"k8s.io/client-go/discovery"
+
+	"github.com/fluxcd/pkg/ssa"
+)
+
+// removedAPI describes a built-in Kubernetes API version removed in a minor release.
+type removedAPI struct {
+	apiVersion string
+	kind       string
+	// minor is the Kubernetes 1.x release that no longer serves the API version.
+	minor int
+	// replacement is the API version to migrate to, if any.
+	replacement string
+}
+
+// removedAPIs contains the built-in API versions removed from Kubernetes.
+var removedAPIs = []removedAPI{
+	{"extensions/v1beta1", "DaemonSet", 16, "apps/v1"},
+	{"extensions/v1beta1", "Deployment", 16, "apps/v1"},
+	{"extensions/v1beta1", "NetworkPolicy", 16, "networking.k8s.io/v1"},
+	{"extensions/v1beta1", "PodSecurityPolicy", 16, "policy/v1beta1"},
+	{"extensions/v1beta1", "ReplicaSet", 16, "apps/v1"},
+	{"apps/v1beta1", "Deployment", 16, "apps/v1"},
+	{"apps/v1beta1", "StatefulSet", 16, "apps/v1"},
+	{"apps/v1beta2", "DaemonSet", 16, "apps/v1"},
+	{"apps/v1beta2", "Deployment", 16, "apps/v1"},
+	{"apps/v1beta2", "ReplicaSet", 16, "apps/v1"},
+	{"apps/v1beta2", "StatefulSet", 16, "apps/v1"},
+	{"extensions/v1beta1", "Ingress", 22, "networking.k8s.io/v1"},
+	{"networking.k8s.io/v1beta1", "Ingress", 22, "networking.k8s.io/v1"},
+	{"networking.k8s.io/v1beta1", "IngressClass", 22, "networking.k8s.io/v1"},
+	{"apiextensions.k8s.io/v1beta1", "CustomResourceDefinition", 22, "apiextensions.k8s.io/v1"},
+	{"apiregistration.k8s.io/v1beta1", "APIService", 22, "apiregistration.k8s.io/v1"},
+	{"admissionregistration.k8s.io/v1beta1", "MutatingWebhookConfiguration", 22, "admissionregistration.k8s.io/v1"},
+	{"admissionregistration.k8s.io/v1beta1", "ValidatingWebhookConfiguration", 22, "admissionregistration.k8s.io/v1"},
+	{"certificates.k8s.io/v1beta1", "CertificateSigningRequest", 22, "certificates.k8s.io/v1"},
+	{"coordination.k8s.io/v1beta1", "Lease", 22, "coordination.k8s.io/v1"},
+	{"rbac.authorization.k8s.io/v1beta1", "ClusterRole", 22, "rbac.authorization.k8s.io/v1"},
+	{"rbac.authorization.k8s.io/v1beta1", "ClusterRoleBinding", 22, "rbac.authorization.k8s.io/v1"},
+	{"rbac.authorization.k8s.io/v1beta1", "Role", 22, "rbac.authorization.k8s.io/v1"},
+	{"rbac.authorization.k8s.io/v1beta1", "RoleBinding", 22, "rbac.authorization.k8s.io/v1"},
+	{"scheduling.k8s.io/v1beta1", "PriorityClass", 22, "scheduling.k8s.io/v1"},
+	{"storage.k8s.io/v1beta1", "CSIDriver", 22, "storage.k8s.io/v1"},
+	{"storage.k8s.io/v1beta1", "CSINode", 22, "storage.k8s.io/v1"},
+	{"storage.k8s.io/v1beta1", "StorageClass", 22, "storage.k8s.io/v1"},
+	{"storage.k8s.io/v1beta1", "VolumeAttachment", 22, "storage.k8s.io/v1"},
+	{"batch/v1beta1", "CronJob", 25, "batch/v1"},
+	{"discovery.k8s.io/v1beta1", "EndpointSlice", 25, "discovery.k8s.io/v1"},
+	{"events.k8s.io/v1beta1", "Event", 25, "events.k8s.io/v1"},
+	{"autoscaling/v2beta1", "HorizontalPodAutoscaler", 25, "autoscaling/v2"},
+	{"node.k8s.io/v1beta1", "RuntimeClass", 25, "node.k8s.io/v1"},
+	{"policy/v1beta1", "PodDisruptionBudget", 25, "policy/v1"},
+	{"policy/v1beta1", "PodSecurityPolicy", 25, ""},
+	{"autoscaling/v2beta2", "HorizontalPodAutoscaler", 26, "autoscaling/v2"},
+	{"flowcontrol.apiserver.k8s.io/v1beta1", "FlowSchema", 26, "flowcontrol.apiserver.k8s.io/v1beta3"},
+	{"flowcontrol.apiserver.k8s.io/v1beta1", "PriorityLevelConfiguration", 26, "flowcontrol.apiserver.k8s.io/v1beta3"},
+	{"storage.k8s.io/v1beta1", "CSIStorageCapacity", 27, "storage.k8s.io/v1"},
+	{"flowcontrol.apiserver.k8s.io/v1beta2", "FlowSchema", 29, "flowcontrol.apiserver.k8s.io/v1"},
+	{"flowcontrol.apiserver.k8s.io/v1beta2", "PriorityLevelConfiguration", 29, "flowcontrol.apiserver.k8s.io/v1"},
+}
+
+// clusterMinorVersion returns the Kubernetes 1.x minor version of the API server.
+func clusterMinorVersion(discoveryClient discovery.ServerVersionInterface) (int, error) {
+	info, err := discoveryClient.ServerVersion()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get the cluster version: %w", err)
+	}
+	return parseMinorVersion(info)
+}
+
+// parseMinorVersion parses the minor version of the API server, ignoring the
+// suffix added by some providers, e.g. '24+'.
+func parseMinorVersion(info *version.Info) (int, error) {
+	if info.Major != "1" {
+		return 0, fmt.Errorf("unsupported Kubernetes major version '%s'", info.Major)
+	}
+	minor, err := strconv.Atoi(strings.TrimSuffix(info.Minor, "+"))
+	if err != nil {
+		return 0, fmt.Errorf("invalid Kubernetes minor version '%s'", info.Minor)
+	}
+	return minor, nil
+}
+
+// deprecatedAPIs returns the objects using an API version that is removed
+// in the given or the next Kubernetes minor version, sorted by object ID.
+func deprecatedAPIs(objects []*unstructured.Unstructured, minor int) []string {
+	var result []string
+	for _, object := range objects {
+		for _, api := range removedAPIs {
+			if api.apiVersion != object.GetAPIVersion() || api.kind != object.GetKind() || api.minor > minor+1 {
+				continue
+			}
+			msg := fmt.Sprintf("%s uses %s removed in Kubernetes 1.%d",
+				ssa.FmtUnstructured(object), api.apiVersion, api.minor)
+			if api.replacement != "" {
+				msg += fmt.Sprintf(", use %s instead", api.replacement)
+			}
+			result = append(result, msg)
+		}
+	}
+	sort.Strings(result)
+	return result
+}
@@ -0,0 +1,93 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"strings"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/version"
+
+	"github.com/fluxcd/pkg/ssa"
+)
+
+func Test_parseMinorVersion(t *testing.T) {
+	tests := []struct {
+		name    string
+		info    version.Info
+		want    int
+		wantErr bool
+	}{
+		{name: "release", info: version.Info{Major: "1", Minor: "24"}, want: 24},
+		{name: "provider suffix", info: version.Info{Major: "1", Minor: "23+"}, want: 23},
+		{name: "invalid minor", info: version.Info{Major: "1", Minor: "x"}, wantErr: true},
+		{name: "unsupported major", info: version.Info{Major: "2", Minor: "0"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			got, err := parseMinorVersion(&tt.info)
+			if tt.wantErr {
+				g.Expect(err).To(HaveOccurred())
+				return
+			}
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(got).To(Equal(tt.want))
+		})
+	}
+}
+
+func Test_deprecatedAPIs(t *testing.T) {
+	g := NewWithT(t)
+
+	manifests := `
+apiVersion: batch/v1beta1
+kind: CronJob
+metadata:
+  name: backup
+  namespace: apps
+---
+apiVersion: policy/v1beta1
+kind: PodSecurityPolicy
+metadata:
+  name: restricted
+---
+apiVersion: autoscaling/v2beta2
+kind: HorizontalPodAutoscaler
+metadata:
+  name: podinfo
+  namespace: apps
+---
+apiVersion: batch/v1
+kind: CronJob
+metadata:
+  name: cleanup
+  namespace: apps
+`
+	objects, err := ssa.ReadObjects(strings.NewReader(manifests))
+	g.Expect(err).NotTo(HaveOccurred())
+
+	g.Expect(deprecatedAPIs(objects, 23)).To(BeEmpty())
+	g.Expect(deprecatedAPIs(objects, 24)).To(Equal([]string{
+		"CronJob/apps/backup uses batch/v1beta1 removed in Kubernetes 1.25, use batch/v1 instead",
+		"PodSecurityPolicy/restricted uses policy/v1beta1 removed in Kubernetes 1.25",
+	}))
+	g.Expect(deprecatedAPIs(objects, 25)).To(HaveLen(3))
+}
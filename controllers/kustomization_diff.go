@@ -0,0 +1,35 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta2"
+	"github.com/fluxcd/kustomize-controller/pkg/diff"
+)
+
+// diffOptions returns the options of the server-side dry-run diff, leaving
+// out the objects with the Skip or DryRun policy, as the apply does not
+// persist them.
+func diffOptions() diff.Options {
+	opts := diff.DefaultOptions()
+	opts.Skip = func(obj *unstructured.Unstructured) bool {
+		return hasObjectPolicy(obj, kustomizev1.SkipObjectPolicy) || hasObjectPolicy(obj, kustomizev1.DryRunObjectPolicy)
+	}
+	return opts
+}
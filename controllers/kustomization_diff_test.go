@@ -0,0 +1,41 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func Test_diffOptions(t *testing.T) {
+	g := NewWithT(t)
+
+	newConfigMap := func(annotations map[string]interface{}) *unstructured.Unstructured {
+		return &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "v1",
+			"kind":       "ConfigMap",
+			"metadata":   map[string]interface{}{"name": "test", "namespace": "test", "annotations": annotations},
+		}}
+	}
+
+	opts := diffOptions()
+	g.Expect(opts.Exclusions).To(HaveKeyWithValue("kustomize.toolkit.fluxcd.io/reconcile", "disabled"))
+	g.Expect(opts.Skip(newConfigMap(nil))).To(BeFalse())
+	g.Expect(opts.Skip(newConfigMap(map[string]interface{}{"kustomize.toolkit.fluxcd.io/dry-run": "enabled"}))).To(BeTrue())
+}
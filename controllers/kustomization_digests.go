@@ -0,0 +1,81 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/fluxcd/pkg/ssa"
+)
+
+// containerFields are the pod spec fields holding lists of containers.
+var containerFields = map[string]bool{
+	"containers":          true,
+	"initContainers":      true,
+	"ephemeralContainers": true,
+}
+
+// checkImageDigests returns an error listing the container images
+// that are referenced by tag instead of digest.
+func checkImageDigests(objects []*unstructured.Unstructured) error {
+	var unpinned []string
+	for _, object := range objects {
+		for _, image := range containerImages(object.Object["spec"]) {
+			if !strings.Contains(image, "@") {
+				unpinned = append(unpinned, fmt.Sprintf("%s image '%s'", ssa.FmtUnstructured(object), image))
+			}
+		}
+	}
+
+	if len(unpinned) > 0 {
+		sort.Strings(unpinned)
+		return fmt.Errorf("images must be pinned to a digest: %s", strings.Join(unpinned, ", "))
+	}
+	return nil
+}
+
+// containerImages walks the given field and returns the images of the containers
+// found at any depth, this covers pod templates nested in custom resources.
+func containerImages(field interface{}) []string {
+	var images []string
+	switch v := field.(type) {
+	case map[string]interface{}:
+		for key, value := range v {
+			containers, ok := value.([]interface{})
+			if !containerFields[key] || !ok {
+				images = append(images, containerImages(value)...)
+				continue
+			}
+			for _, c := range containers {
+				if container, ok := c.(map[string]interface{}); ok {
+					if image, ok := container["image"].(string); ok && image != "" {
+						images = append(images, image)
+					}
+				}
+			}
+		}
+	case []interface{}:
+		for _, value := range v {
+			images = append(images, containerImages(value)...)
+		}
+	}
+	return images
+}
@@ -0,0 +1,80 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"strings"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/fluxcd/pkg/ssa"
+)
+
+func Test_checkImageDigests(t *testing.T) {
+	g := NewWithT(t)
+
+	manifests := `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: pinned
+  namespace: apps
+spec:
+  template:
+    spec:
+      initContainers:
+      - name: init
+        image: busybox@sha256:2832f53c577d44753e97b0ed5f00e7e3a06979c9fab77d0e78bdac4b612b14fb
+      containers:
+      - name: app
+        image: podinfo:6.2.0@sha256:2832f53c577d44753e97b0ed5f00e7e3a06979c9fab77d0e78bdac4b612b14fb
+---
+apiVersion: batch/v1
+kind: CronJob
+metadata:
+  name: tagged
+  namespace: apps
+spec:
+  jobTemplate:
+    spec:
+      template:
+        spec:
+          containers:
+          - name: job
+            image: busybox:1.35
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: config
+  namespace: apps
+data:
+  image: busybox:1.35
+`
+	objects, err := ssa.ReadObjects(strings.NewReader(manifests))
+	g.Expect(err).NotTo(HaveOccurred())
+
+	err = checkImageDigests(objects)
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(err.Error()).To(ContainSubstring("CronJob/apps/tagged image 'busybox:1.35'"))
+	g.Expect(err.Error()).NotTo(ContainSubstring("pinned"))
+	g.Expect(err.Error()).NotTo(ContainSubstring("ConfigMap"))
+
+	g.Expect(checkImageDigests([]*unstructured.Unstructured{objects[0], objects[2]})).To(Succeed())
+}
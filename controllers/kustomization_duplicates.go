@@ -0,0 +1,234 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/kustomize/api/filesys"
+	"sigs.k8s.io/kustomize/api/konfig"
+	kustypes "sigs.k8s.io/kustomize/api/types"
+	"sigs.k8s.io/kustomize/kyaml/resid"
+	"sigs.k8s.io/yaml"
+
+	securefs "github.com/fluxcd/pkg/kustomize/filesys"
+
+	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta2"
+)
+
+// duplicateIDRegexp matches the resource ID of the kustomize build error
+// reported when a resource is declared more than once.
+var duplicateIDRegexp = regexp.MustCompile(`already registered id: ([^\s']+)`)
+
+// maxDuplicateResolutions is the maximum number of duplicate resources
+// resolved for a single build.
+const maxDuplicateResolutions = 100
+
+// resourceDeclaration is a document of a manifest declaring a resource.
+type resourceDeclaration struct {
+	// path is the absolute path of the manifest
+	path string
+	// name is the path of the manifest relative to the build root
+	name string
+	doc  yamlDocument
+}
+
+func (d resourceDeclaration) String() string {
+	return fmt.Sprintf("%s (lines %d-%d)", d.name, d.doc.firstLine, d.doc.lastLine)
+}
+
+// duplicateResourceID returns the ID of the resource named by
+// a kustomize duplicate resource error.
+func duplicateResourceID(err error) (resid.ResId, bool) {
+	match := duplicateIDRegexp.FindStringSubmatch(err.Error())
+	if match == nil {
+		return resid.ResId{}, false
+	}
+	return resid.FromString(match[1]), true
+}
+
+// resolveDuplicateResource handles the build error of a resource declared more
+// than once with the given policy. With 'KeepFirst' or 'KeepLast', the other
+// declarations are removed from the manifests and a warning is returned, so that
+// the build can be retried. Otherwise, the build error is returned with the
+// files declaring the resource.
+func resolveDuplicateResource(root, dirPath string, buildErr error, policy string) (string, error) {
+	id, ok := duplicateResourceID(buildErr)
+	if !ok {
+		return "", buildErr
+	}
+
+	fs, err := securefs.MakeFsOnDiskSecure(root)
+	if err != nil {
+		return "", buildErr
+	}
+	decls, err := findDeclarations(fs, root, dirPath, id, make(map[string]bool))
+	if err != nil || len(decls) < 2 {
+		// the duplicates produced by generators or name transformers can't be located
+		return "", buildErr
+	}
+	names := make([]string, 0, len(decls))
+	for _, decl := range decls {
+		names = append(names, decl.String())
+	}
+
+	var kept resourceDeclaration
+	var removed []resourceDeclaration
+	switch policy {
+	case kustomizev1.KeepFirstBuildPolicy:
+		kept, removed = decls[0], decls[1:]
+	case kustomizev1.KeepLastBuildPolicy:
+		kept, removed = decls[len(decls)-1], decls[:len(decls)-1]
+	default:
+		return "", fmt.Errorf("resource '%s' is declared more than once, in %s: %w",
+			id, strings.Join(names, " and "), buildErr)
+	}
+
+	if err := removeDeclarations(fs, removed); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("resource '%s' is declared more than once, in %s, the declaration of %s is kept",
+		id, strings.Join(names, " and "), kept.String()), nil
+}
+
+// findDeclarations returns the documents declaring the resource with the given ID
+// in the manifests of the kustomization at dirPath and of its local bases and
+// components, in the order kustomize accumulates them. The namespaces and names
+// set by the kustomizations are not taken into account.
+func findDeclarations(fs filesys.FileSystem, root, dirPath string, id resid.ResId,
+	visited map[string]bool) ([]resourceDeclaration, error) {
+	dirPath = filepath.Clean(dirPath)
+	if visited[dirPath] {
+		return nil, nil
+	}
+	visited[dirPath] = true
+
+	for _, kfilename := range konfig.RecognizedKustomizationFileNames() {
+		kpath := filepath.Join(dirPath, kfilename)
+		if !fs.Exists(kpath) || fs.IsDir(kpath) {
+			continue
+		}
+		data, err := fs.ReadFile(kpath)
+		if err != nil {
+			return nil, err
+		}
+		var kus kustypes.Kustomization
+		if err := yaml.Unmarshal(data, &kus); err != nil {
+			return nil, err
+		}
+
+		var decls []resourceDeclaration
+		refs := append(append(append([]string{}, kus.Resources...), kus.Bases...), kus.Components...)
+		for _, ref := range refs {
+			if strings.Contains(ref, "://") {
+				continue
+			}
+			refPath := filepath.Join(dirPath, ref)
+			if fs.IsDir(refPath) {
+				found, err := findDeclarations(fs, root, refPath, id, visited)
+				if err != nil {
+					return nil, err
+				}
+				decls = append(decls, found...)
+				continue
+			}
+			if !fs.Exists(refPath) {
+				continue
+			}
+			data, err := fs.ReadFile(refPath)
+			if err != nil {
+				return nil, err
+			}
+			name := ref
+			if rel, err := filepath.Rel(root, refPath); err == nil {
+				name = rel
+			}
+			for _, doc := range splitYAMLDocuments(data) {
+				if declaresResource(doc.data, id) {
+					decls = append(decls, resourceDeclaration{path: refPath, name: name, doc: doc})
+				}
+			}
+		}
+		return decls, nil
+	}
+	return nil, nil
+}
+
+// declaresResource returns true if the YAML document declares the resource
+// with the given ID, in any namespace if the document doesn't set one.
+func declaresResource(data []byte, id resid.ResId) bool {
+	var obj struct {
+		APIVersion string `json:"apiVersion"`
+		Kind       string `json:"kind"`
+		Metadata   struct {
+			Name      string `json:"name"`
+			Namespace string `json:"namespace"`
+		} `json:"metadata"`
+	}
+	if err := yaml.Unmarshal(data, &obj); err != nil {
+		return false
+	}
+	gv, err := schema.ParseGroupVersion(obj.APIVersion)
+	if err != nil {
+		return false
+	}
+	return obj.Kind == id.Kind && gv.Group == id.Group && obj.Metadata.Name == id.Name &&
+		(obj.Metadata.Namespace == "" || obj.Metadata.Namespace == id.Namespace)
+}
+
+// removeDeclarations rewrites the manifests without the given documents.
+func removeDeclarations(fs filesys.FileSystem, decls []resourceDeclaration) error {
+	byPath := make(map[string]map[int]bool)
+	for _, decl := range decls {
+		if byPath[decl.path] == nil {
+			byPath[decl.path] = make(map[int]bool)
+		}
+		byPath[decl.path][decl.doc.firstLine] = true
+	}
+
+	paths := make([]string, 0, len(byPath))
+	for path := range byPath {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		data, err := fs.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		var out bytes.Buffer
+		for _, doc := range splitYAMLDocuments(data) {
+			if byPath[path][doc.firstLine] {
+				continue
+			}
+			if out.Len() > 0 {
+				out.WriteString("---\n")
+			}
+			out.Write(doc.data)
+		}
+		if err := fs.WriteFile(path, out.Bytes()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
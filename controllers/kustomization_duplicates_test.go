@@ -0,0 +1,106 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"sigs.k8s.io/kustomize/kyaml/resid"
+
+	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta2"
+)
+
+func Test_duplicateResourceID(t *testing.T) {
+	g := NewWithT(t)
+
+	err := fmt.Errorf("accumulating resources: accumulation err='merging resources from 'y.yaml': " +
+		"may not add resource with an already registered id: Deployment.v1.apps/podinfo.apps': must build at directory")
+	id, ok := duplicateResourceID(err)
+	g.Expect(ok).To(BeTrue())
+	g.Expect(id).To(Equal(resid.NewResIdWithNamespace(resid.NewGvk("apps", "v1", "Deployment"), "podinfo", "apps")))
+
+	_, ok = duplicateResourceID(fmt.Errorf("accumulating resources: file not found"))
+	g.Expect(ok).To(BeFalse())
+}
+
+func Test_resolveDuplicateResource(t *testing.T) {
+	configMap := func(value string) string {
+		return fmt.Sprintf("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: config\ndata:\n  value: %s\n", value)
+	}
+	newTree := func(t *testing.T) string {
+		g := NewWithT(t)
+		root := t.TempDir()
+		files := map[string]string{
+			"kustomization.yaml":        "resources:\n- base-a\n- base-b\n",
+			"base-a/kustomization.yaml": "resources:\n- config.yaml\n",
+			"base-a/config.yaml":        configMap("a"),
+			"base-b/kustomization.yaml": "resources:\n- all.yaml\n",
+			"base-b/all.yaml":           configMap("b") + "---\napiVersion: v1\nkind: Secret\nmetadata:\n  name: secret\n",
+		}
+		for name, data := range files {
+			path := filepath.Join(root, name)
+			g.Expect(os.MkdirAll(filepath.Dir(path), 0o755)).To(Succeed())
+			g.Expect(os.WriteFile(path, []byte(data), 0o644)).To(Succeed())
+		}
+		return root
+	}
+	buildErr := fmt.Errorf("may not add resource with an already registered id: ConfigMap.v1.[noGrp]/config.[noNs]")
+
+	t.Run("error names both files", func(t *testing.T) {
+		g := NewWithT(t)
+		root := newTree(t)
+		_, err := resolveDuplicateResource(root, root, buildErr, kustomizev1.ErrorBuildPolicy)
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(err.Error()).To(ContainSubstring("in base-a/config.yaml (lines 1-6) and base-b/all.yaml (lines 1-6)"))
+	})
+
+	t.Run("keep first", func(t *testing.T) {
+		g := NewWithT(t)
+		root := newTree(t)
+		warning, err := resolveDuplicateResource(root, root, buildErr, kustomizev1.KeepFirstBuildPolicy)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(warning).To(ContainSubstring("the declaration of base-a/config.yaml (lines 1-6) is kept"))
+
+		data, err := os.ReadFile(filepath.Join(root, "base-b/all.yaml"))
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(string(data)).To(Equal("apiVersion: v1\nkind: Secret\nmetadata:\n  name: secret\n"))
+	})
+
+	t.Run("keep last", func(t *testing.T) {
+		g := NewWithT(t)
+		root := newTree(t)
+		warning, err := resolveDuplicateResource(root, root, buildErr, kustomizev1.KeepLastBuildPolicy)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(warning).To(ContainSubstring("the declaration of base-b/all.yaml (lines 1-6) is kept"))
+
+		data, err := os.ReadFile(filepath.Join(root, "base-a/config.yaml"))
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(string(data)).To(BeEmpty())
+	})
+
+	t.Run("not a duplicate error", func(t *testing.T) {
+		g := NewWithT(t)
+		root := newTree(t)
+		otherErr := fmt.Errorf("accumulating resources: file not found")
+		_, err := resolveDuplicateResource(root, root, otherErr, kustomizev1.KeepFirstBuildPolicy)
+		g.Expect(err).To(Equal(otherErr))
+	})
+}
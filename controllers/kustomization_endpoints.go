@@ -0,0 +1,195 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/wait"
+
+	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta2"
+)
+
+// endpointCheckInterval is the interval at which the
+// unavailable endpoints are probed during the health assessment.
+var endpointCheckInterval = 5 * time.Second
+
+// defaultEndpointProbeTimeout is the timeout of a single probe,
+// when the endpoint health check doesn't specify one.
+const defaultEndpointProbeTimeout = 10 * time.Second
+
+// EndpointProber probes the endpoints of the health checks from the controller pod,
+// restricted to the hosts allowed with --endpoint-health-check-hosts.
+type EndpointProber struct {
+	allowedHosts []string
+	httpClient   *http.Client
+}
+
+// NewEndpointProber returns an EndpointProber for the allowed hosts. A host
+// is either a hostname or IP address, a wildcard domain such as '*.example.com',
+// or '*' to allow any host.
+func NewEndpointProber(allowedHosts []string) *EndpointProber {
+	return &EndpointProber{
+		allowedHosts: allowedHosts,
+		httpClient: &http.Client{
+			Transport: http.DefaultTransport.(*http.Transport).Clone(),
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+	}
+}
+
+// checkEndpoints probes the endpoints until all of them are available,
+// or until the timeout expires. An endpoint found available isn't probed again.
+func (p *EndpointProber) checkEndpoints(ctx context.Context, checks []kustomizev1.EndpointHealthCheck, timeout time.Duration) error {
+	failures := make(map[int]error, len(checks))
+	for i := range checks {
+		failures[i] = errors.New("not probed")
+	}
+
+	err := wait.PollImmediateWithContext(ctx, endpointCheckInterval, timeout, func(ctx context.Context) (bool, error) {
+		for i, check := range checks {
+			if _, ok := failures[i]; !ok {
+				continue
+			}
+			if err := p.probeEndpoint(ctx, check); err != nil {
+				failures[i] = err
+				continue
+			}
+			delete(failures, i)
+		}
+		return len(failures) == 0, nil
+	})
+	if err == nil {
+		return nil
+	}
+
+	var msgs []string
+	for i, check := range checks {
+		if failure, ok := failures[i]; ok {
+			msgs = append(msgs, fmt.Sprintf("%s endpoint '%s' is unavailable: %s", endpointCheckType(check), check.Address, failure))
+		}
+	}
+	return errors.New(strings.Join(msgs, "; "))
+}
+
+// probeEndpoint returns an error if the endpoint is unavailable or its host is not allowed.
+// HTTP endpoints are available when they respond to a GET request with a 2xx status code,
+// redirects are not followed, TCP endpoints when they accept a connection.
+func (p *EndpointProber) probeEndpoint(ctx context.Context, check kustomizev1.EndpointHealthCheck) error {
+	host, err := endpointHost(check)
+	if err != nil {
+		return err
+	}
+	if !p.isAllowed(host) {
+		return fmt.Errorf("the host '%s' is not allowed by --endpoint-health-check-hosts", host)
+	}
+
+	timeout := defaultEndpointProbeTimeout
+	if check.Timeout != nil {
+		timeout = check.Timeout.Duration
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	if endpointCheckType(check) == kustomizev1.TCPEndpointHealthCheck {
+		var dialer net.Dialer
+		conn, err := dialer.DialContext(ctx, "tcp", check.Address)
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, check.Address, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected status code %s", resp.Status)
+	}
+	return nil
+}
+
+// endpointCheckType returns the type of the endpoint health check, 'HTTP' if not set.
+func endpointCheckType(check kustomizev1.EndpointHealthCheck) string {
+	if check.Type == "" {
+		return kustomizev1.HTTPEndpointHealthCheck
+	}
+	return check.Type
+}
+
+// endpointHost returns the host of the endpoint address, the address
+// of an HTTP endpoint must be an absolute http or https URL.
+func endpointHost(check kustomizev1.EndpointHealthCheck) (string, error) {
+	if endpointCheckType(check) == kustomizev1.TCPEndpointHealthCheck {
+		host, _, err := net.SplitHostPort(check.Address)
+		if err != nil {
+			return "", fmt.Errorf("invalid address: %w", err)
+		}
+		return host, nil
+	}
+
+	u, err := url.Parse(check.Address)
+	if err != nil {
+		return "", fmt.Errorf("invalid address: %w", err)
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+		return "", fmt.Errorf("invalid address, expected an http or https URL")
+	}
+	return u.Hostname(), nil
+}
+
+// isAllowed returns true if the host matches one of the allowed hosts.
+func (p *EndpointProber) isAllowed(host string) bool {
+	return hostAllowed(p.allowedHosts, host)
+}
+
+// hostAllowed returns true if the host matches one of the allowed hosts:
+// a hostname or IP address, a wildcard domain such as '*.example.com',
+// or '*' matching any host.
+func hostAllowed(allowedHosts []string, host string) bool {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	for _, allowed := range allowedHosts {
+		allowed = strings.ToLower(strings.TrimSuffix(allowed, "."))
+		switch {
+		case allowed == "*":
+			return true
+		case strings.HasPrefix(allowed, "*."):
+			if strings.HasSuffix(host, allowed[1:]) {
+				return true
+			}
+		case host == allowed:
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,165 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta2"
+)
+
+func Test_checkEndpoints(t *testing.T) {
+	endpointCheckInterval = 10 * time.Millisecond
+	defer func() {
+		endpointCheckInterval = 5 * time.Second
+	}()
+
+	healthy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer healthy.Close()
+
+	unavailable := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer unavailable.Close()
+
+	var requests int32
+	starting := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) < 3 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer starting.Close()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closedAddress := closed.Addr().String()
+	closed.Close()
+
+	redirect := httptest.NewServer(http.RedirectHandler(healthy.URL, http.StatusFound))
+	defer redirect.Close()
+
+	tests := []struct {
+		name    string
+		checks  []kustomizev1.EndpointHealthCheck
+		wantErr string
+	}{
+		{
+			name: "available endpoints",
+			checks: []kustomizev1.EndpointHealthCheck{
+				{Address: healthy.URL},
+				{Type: kustomizev1.TCPEndpointHealthCheck, Address: listener.Addr().String()},
+			},
+		},
+		{
+			name: "endpoint becomes available",
+			checks: []kustomizev1.EndpointHealthCheck{
+				{Type: kustomizev1.HTTPEndpointHealthCheck, Address: starting.URL},
+			},
+		},
+		{
+			name: "unexpected status code",
+			checks: []kustomizev1.EndpointHealthCheck{
+				{Address: healthy.URL},
+				{Address: unavailable.URL},
+			},
+			wantErr: "HTTP endpoint '" + unavailable.URL + "' is unavailable: unexpected status code 503 Service Unavailable",
+		},
+		{
+			name: "connection refused",
+			checks: []kustomizev1.EndpointHealthCheck{
+				{
+					Type:    kustomizev1.TCPEndpointHealthCheck,
+					Address: closedAddress,
+					Timeout: &metav1.Duration{Duration: 50 * time.Millisecond},
+				},
+			},
+			wantErr: "TCP endpoint '" + closedAddress + "' is unavailable",
+		},
+		{
+			name: "redirects are not followed",
+			checks: []kustomizev1.EndpointHealthCheck{
+				{Address: redirect.URL},
+			},
+			wantErr: "unexpected status code 302 Found",
+		},
+		{
+			name: "host not allowed",
+			checks: []kustomizev1.EndpointHealthCheck{
+				{Address: "http://localhost:8080/metrics"},
+			},
+			wantErr: "the host 'localhost' is not allowed by --endpoint-health-check-hosts",
+		},
+		{
+			name: "unsupported scheme",
+			checks: []kustomizev1.EndpointHealthCheck{
+				{Address: "file:///etc/passwd"},
+			},
+			wantErr: "expected an http or https URL",
+		},
+	}
+
+	prober := NewEndpointProber([]string{"127.0.0.1"})
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			err := prober.checkEndpoints(context.TODO(), tt.checks, time.Second)
+			if tt.wantErr == "" {
+				g.Expect(err).NotTo(HaveOccurred())
+				return
+			}
+			g.Expect(err).To(HaveOccurred())
+			g.Expect(err.Error()).To(ContainSubstring(tt.wantErr))
+		})
+	}
+}
+
+func TestEndpointProber_isAllowed(t *testing.T) {
+	g := NewWithT(t)
+
+	p := NewEndpointProber([]string{"podinfo.example.com", "*.svc.cluster.local"})
+	g.Expect(p.isAllowed("podinfo.example.com")).To(BeTrue())
+	g.Expect(p.isAllowed("PODINFO.example.com.")).To(BeTrue())
+	g.Expect(p.isAllowed("redis.apps.svc.cluster.local")).To(BeTrue())
+	g.Expect(p.isAllowed("svc.cluster.local")).To(BeFalse())
+	g.Expect(p.isAllowed("example.com")).To(BeFalse())
+	g.Expect(p.isAllowed("169.254.169.254")).To(BeFalse())
+
+	g.Expect(NewEndpointProber([]string{"*"}).isAllowed("169.254.169.254")).To(BeTrue())
+}
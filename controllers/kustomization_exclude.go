@@ -0,0 +1,109 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+	"regexp"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+
+	"github.com/fluxcd/pkg/apis/kustomize"
+
+	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta2"
+)
+
+// excludeObjects splits the objects into the ones that should be applied
+// and the ones matching the spec.applyOptions.exclude selectors.
+func excludeObjects(kustomization kustomizev1.Kustomization,
+	objects []*unstructured.Unstructured) ([]*unstructured.Unstructured, []*unstructured.Unstructured, error) {
+	if kustomization.Spec.ApplyOptions == nil || len(kustomization.Spec.ApplyOptions.Exclude) == 0 {
+		return objects, nil, nil
+	}
+
+	var applied, excluded []*unstructured.Unstructured
+	for _, obj := range objects {
+		match := false
+		for _, selector := range kustomization.Spec.ApplyOptions.Exclude {
+			ok, err := selectorMatches(selector, obj)
+			if err != nil {
+				return nil, nil, err
+			}
+			if ok {
+				match = true
+				break
+			}
+		}
+
+		if match {
+			excluded = append(excluded, obj)
+		} else {
+			applied = append(applied, obj)
+		}
+	}
+
+	return applied, excluded, nil
+}
+
+// selectorMatches returns true if the object matches all the conditions of the selector.
+// The name and namespace are matched as anchored regular expressions, as in kustomize.
+func selectorMatches(selector kustomize.Selector, obj *unstructured.Unstructured) (bool, error) {
+	gvk := obj.GroupVersionKind()
+	if (selector.Group != "" && selector.Group != gvk.Group) ||
+		(selector.Version != "" && selector.Version != gvk.Version) ||
+		(selector.Kind != "" && selector.Kind != gvk.Kind) {
+		return false, nil
+	}
+
+	for _, field := range []struct{ expr, value string }{
+		{selector.Name, obj.GetName()},
+		{selector.Namespace, obj.GetNamespace()},
+	} {
+		if field.expr == "" {
+			continue
+		}
+		re, err := regexp.Compile("^(?:" + field.expr + ")$")
+		if err != nil {
+			return false, fmt.Errorf("invalid selector '%s': %w", field.expr, err)
+		}
+		if !re.MatchString(field.value) {
+			return false, nil
+		}
+	}
+
+	for _, field := range []struct {
+		expr string
+		set  map[string]string
+	}{
+		{selector.LabelSelector, obj.GetLabels()},
+		{selector.AnnotationSelector, obj.GetAnnotations()},
+	} {
+		if field.expr == "" {
+			continue
+		}
+		sel, err := labels.Parse(field.expr)
+		if err != nil {
+			return false, fmt.Errorf("invalid selector '%s': %w", field.expr, err)
+		}
+		if !sel.Matches(labels.Set(field.set)) {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
@@ -0,0 +1,209 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/fluxcd/pkg/apis/kustomize"
+	"github.com/fluxcd/pkg/apis/meta"
+	"github.com/fluxcd/pkg/testserver"
+	sourcev1 "github.com/fluxcd/source-controller/api/v1beta2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta2"
+)
+
+func Test_excludeObjects(t *testing.T) {
+	newObject := func(apiVersion, kind, namespace, name string, labels map[string]string) *unstructured.Unstructured {
+		u := &unstructured.Unstructured{}
+		u.SetAPIVersion(apiVersion)
+		u.SetKind(kind)
+		u.SetNamespace(namespace)
+		u.SetName(name)
+		u.SetLabels(labels)
+		return u
+	}
+
+	objects := []*unstructured.Unstructured{
+		newObject("apps/v1", "Deployment", "apps", "frontend", nil),
+		newObject("monitoring.coreos.com/v1", "PrometheusRule", "apps", "frontend", nil),
+		newObject("monitoring.coreos.com/v1", "PrometheusRule", "apps", "backend", map[string]string{"team": "sre"}),
+		newObject("v1", "ConfigMap", "monitoring", "rules", nil),
+	}
+
+	tests := []struct {
+		name     string
+		exclude  []kustomize.Selector
+		excluded []string
+		wantErr  bool
+	}{
+		{
+			name:     "no selectors",
+			exclude:  nil,
+			excluded: nil,
+		},
+		{
+			name:     "by kind",
+			exclude:  []kustomize.Selector{{Kind: "PrometheusRule"}},
+			excluded: []string{"PrometheusRule/apps/frontend", "PrometheusRule/apps/backend"},
+		},
+		{
+			name:     "by group and label",
+			exclude:  []kustomize.Selector{{Group: "monitoring.coreos.com", LabelSelector: "team=sre"}},
+			excluded: []string{"PrometheusRule/apps/backend"},
+		},
+		{
+			name:     "by namespace regex",
+			exclude:  []kustomize.Selector{{Namespace: "mon.*"}},
+			excluded: []string{"ConfigMap/monitoring/rules"},
+		},
+		{
+			name:     "by name is anchored",
+			exclude:  []kustomize.Selector{{Name: "front"}},
+			excluded: nil,
+		},
+		{
+			name:    "invalid label selector",
+			exclude: []kustomize.Selector{{LabelSelector: "team in (sre"}},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			kustomization := kustomizev1.Kustomization{
+				Spec: kustomizev1.KustomizationSpec{
+					ApplyOptions: &kustomizev1.ApplyOptions{
+						Exclude: tt.exclude,
+					},
+				},
+			}
+
+			applied, excluded, err := excludeObjects(kustomization, objects)
+			if tt.wantErr {
+				g.Expect(err).To(HaveOccurred())
+				return
+			}
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(applied).To(HaveLen(len(objects) - len(tt.excluded)))
+
+			var got []string
+			for _, obj := range excluded {
+				got = append(got, obj.GetKind()+"/"+obj.GetNamespace()+"/"+obj.GetName())
+			}
+			g.Expect(got).To(Equal(tt.excluded))
+		})
+	}
+}
+
+func TestKustomizationReconciler_ExcludeObjects(t *testing.T) {
+	g := NewWithT(t)
+	id := "exclude-" + randStringRunes(5)
+	revision := "v1.0.0"
+
+	err := createNamespace(id)
+	g.Expect(err).NotTo(HaveOccurred(), "failed to create test namespace")
+
+	err = createKubeConfigSecret(id)
+	g.Expect(err).NotTo(HaveOccurred(), "failed to create kubeconfig secret")
+
+	var files []testserver.File
+	for _, name := range []string{"app", "rules"} {
+		files = append(files, testserver.File{
+			Name: name + ".yaml",
+			Body: fmt.Sprintf(`---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: %[1]s
+data:
+  key: "%[1]s"
+`, name),
+		})
+	}
+
+	artifact, err := testServer.ArtifactFromFiles(files)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	repositoryName := types.NamespacedName{
+		Name:      fmt.Sprintf("exclude-%s", randStringRunes(5)),
+		Namespace: id,
+	}
+	g.Expect(applyGitRepository(repositoryName, artifact, revision)).To(Succeed())
+
+	kustomization := &kustomizev1.Kustomization{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fmt.Sprintf("exclude-%s", randStringRunes(5)),
+			Namespace: id,
+		},
+		Spec: kustomizev1.KustomizationSpec{
+			Interval: metav1.Duration{Duration: reconciliationInterval},
+			Path:     "./",
+			KubeConfig: &kustomizev1.KubeConfig{
+				SecretRef: meta.SecretKeyReference{
+					Name: "kubeconfig",
+				},
+			},
+			SourceRef: kustomizev1.CrossNamespaceSourceReference{
+				Name:      repositoryName.Name,
+				Namespace: repositoryName.Namespace,
+				Kind:      sourcev1.GitRepositoryKind,
+			},
+			TargetNamespace: id,
+			Prune:           true,
+		},
+	}
+	g.Expect(k8sClient.Create(context.Background(), kustomization)).To(Succeed())
+
+	resultK := &kustomizev1.Kustomization{}
+	g.Eventually(func() bool {
+		_ = k8sClient.Get(context.Background(), client.ObjectKeyFromObject(kustomization), resultK)
+		return resultK.Status.LastAppliedRevision == revision
+	}, timeout, time.Second).Should(BeTrue())
+	g.Expect(resultK.Status.Inventory.Entries).To(HaveLen(2))
+
+	t.Run("keeps previously applied objects once excluded", func(t *testing.T) {
+		resultK.Spec.ApplyOptions = &kustomizev1.ApplyOptions{
+			Exclude: []kustomize.Selector{{Kind: "ConfigMap", Name: "rules"}},
+		}
+		g.Expect(k8sClient.Update(context.Background(), resultK)).To(Succeed())
+
+		g.Eventually(func() bool {
+			_ = k8sClient.Get(context.Background(), client.ObjectKeyFromObject(kustomization), resultK)
+			return resultK.Status.ObservedGeneration == resultK.GetGeneration() &&
+				len(resultK.Status.Inventory.Entries) == 1
+		}, timeout, time.Second).Should(BeTrue())
+
+		g.Expect(resultK.Status.Inventory.Entries[0].ID).To(Equal(fmt.Sprintf("%s_app__ConfigMap", id)))
+		g.Expect(k8sClient.Get(context.Background(), types.NamespacedName{Name: "rules", Namespace: id}, &corev1.ConfigMap{})).To(Succeed())
+
+		events := getEvents(kustomization.GetName(), nil)
+		g.Expect(events).To(ContainElement(WithTransform(func(e corev1.Event) string { return e.Message },
+			ContainSubstring("excluded 1 objects from apply"))))
+	})
+}
@@ -0,0 +1,284 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/arn"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/secretsmanager"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/secretmanager/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta2"
+)
+
+const (
+	// ExternalSecretKind is the kind of the substituteFrom references
+	// to the secrets stored in the cloud secret managers.
+	ExternalSecretKind = "ExternalSecret"
+
+	// azureKeyVaultAPIVersion is the version of the Key Vault REST API
+	// the secrets are read with.
+	azureKeyVaultAPIVersion = "7.3"
+)
+
+// azureKeyVaultDomains are the DNS suffixes of the Key Vault endpoints of the
+// Azure clouds, the access tokens are only sent to the vaults of these domains.
+var azureKeyVaultDomains = []string{
+	"vault.azure.net",
+	"vault.azure.cn",
+	"vault.usgovcloudapi.net",
+	"vault.microsoftazure.de",
+}
+
+// errExternalSecretNotFound is returned by the ExternalSecretResolver
+// when the secret doesn't exist in the secret manager.
+var errExternalSecretNotFound = errors.New("external secret not found")
+
+// ExternalSecretResolver reads the value of the secrets stored in the
+// cloud secret managers.
+type ExternalSecretResolver interface {
+	// Resolve returns the value of the named secret of the provider,
+	// or errExternalSecretNotFound if the secret doesn't exist.
+	Resolve(ctx context.Context, provider, name string) ([]byte, error)
+}
+
+// CloudSecretResolver is an ExternalSecretResolver reading the secrets with
+// the workload identity of the controller: IRSA on EKS, Workload Identity
+// on GKE and the managed identity on AKS. The values are read at every build
+// and never cached.
+type CloudSecretResolver struct {
+	httpClient *http.Client
+}
+
+// NewCloudSecretResolver returns a CloudSecretResolver.
+func NewCloudSecretResolver() *CloudSecretResolver {
+	return &CloudSecretResolver{httpClient: http.DefaultClient}
+}
+
+// Resolve returns the value of the named secret of the provider.
+func (r *CloudSecretResolver) Resolve(ctx context.Context, provider, name string) ([]byte, error) {
+	switch provider {
+	case "aws":
+		return r.resolveAWS(ctx, name)
+	case "gcp":
+		return r.resolveGCP(ctx, name)
+	case "azure":
+		return r.resolveAzure(ctx, name)
+	default:
+		return nil, fmt.Errorf("unsupported external secret provider '%s'", provider)
+	}
+}
+
+// resolveAWS reads the AWS Secrets Manager secret with the given name or ARN,
+// in the region of the ARN or else in the region of the controller.
+func (r *CloudSecretResolver) resolveAWS(ctx context.Context, name string) ([]byte, error) {
+	cfg := aws.NewConfig().WithHTTPClient(r.httpClient)
+	if a, err := arn.Parse(name); err == nil {
+		cfg = cfg.WithRegion(a.Region)
+	}
+	sess, err := session.NewSession(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create AWS session: %w", err)
+	}
+
+	out, err := secretsmanager.New(sess).GetSecretValueWithContext(ctx, &secretsmanager.GetSecretValueInput{
+		SecretId: aws.String(name),
+	})
+	if err != nil {
+		var aerr awserr.Error
+		if errors.As(err, &aerr) && aerr.Code() == secretsmanager.ErrCodeResourceNotFoundException {
+			return nil, errExternalSecretNotFound
+		}
+		return nil, err
+	}
+	if out.SecretString != nil {
+		return []byte(*out.SecretString), nil
+	}
+	return out.SecretBinary, nil
+}
+
+// resolveGCP reads the GCP Secret Manager secret version with the given resource
+// name, e.g. 'projects/<project>/secrets/<secret>/versions/<version>', the latest
+// version is read when the name doesn't include one.
+func (r *CloudSecretResolver) resolveGCP(ctx context.Context, name string) ([]byte, error) {
+	if !strings.Contains(name, "/versions/") {
+		name = name + "/versions/latest"
+	}
+	svc, err := secretmanager.NewService(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create GCP Secret Manager client: %w", err)
+	}
+
+	resp, err := svc.Projects.Secrets.Versions.Access(name).Context(ctx).Do()
+	if err != nil {
+		var gerr *googleapi.Error
+		if errors.As(err, &gerr) && gerr.Code == http.StatusNotFound {
+			return nil, errExternalSecretNotFound
+		}
+		return nil, err
+	}
+	if resp.Payload == nil {
+		return nil, nil
+	}
+	return base64.StdEncoding.DecodeString(resp.Payload.Data)
+}
+
+// resolveAzure reads the Azure Key Vault secret with the given URL, e.g.
+// 'https://<vault>.vault.azure.net/secrets/<secret>[/<version>]'.
+func (r *CloudSecretResolver) resolveAzure(ctx context.Context, name string) ([]byte, error) {
+	u, err := url.Parse(name)
+	if err != nil || u.Scheme != "https" || !strings.HasPrefix(u.Path, "/secrets/") {
+		return nil, fmt.Errorf("invalid Azure Key Vault secret URL '%s'", name)
+	}
+	domain := ""
+	for _, d := range azureKeyVaultDomains {
+		if strings.HasSuffix(u.Hostname(), "."+d) {
+			domain = d
+			break
+		}
+	}
+	if domain == "" {
+		return nil, fmt.Errorf("invalid Azure Key Vault secret URL '%s', the host is not a Key Vault endpoint", name)
+	}
+
+	cred, err := azidentity.NewDefaultAzureCredential(nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get Azure credential: %w", err)
+	}
+	token, err := cred.GetToken(ctx, policy.TokenRequestOptions{
+		Scopes: []string{fmt.Sprintf("https://%s/.default", domain)},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get Azure Key Vault token: %w", err)
+	}
+
+	q := u.Query()
+	q.Set("api-version", azureKeyVaultAPIVersion)
+	u.RawQuery = q.Encode()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+token.Token)
+
+	resp, err := r.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return nil, errExternalSecretNotFound
+	case resp.StatusCode != http.StatusOK:
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("failed to read Azure Key Vault secret: %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	var secret struct {
+		Value string `json:"value"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&secret); err != nil {
+		return nil, fmt.Errorf("failed to decode Azure Key Vault secret: %w", err)
+	}
+	return []byte(secret.Value), nil
+}
+
+// hasExternalSecrets returns true if the vars of the Kustomization
+// are read from external secrets.
+func hasExternalSecrets(kustomization kustomizev1.Kustomization) bool {
+	if kustomization.Spec.PostBuild == nil {
+		return false
+	}
+	for _, reference := range kustomization.Spec.PostBuild.SubstituteFrom {
+		if reference.Kind == ExternalSecretKind {
+			return true
+		}
+	}
+	return false
+}
+
+// externalSecretAllowed returns true if the name of the provider secret starts
+// with one of the '<provider>:<name prefix>' entries of the external secrets
+// annotation. Names with '..' segments are never allowed, so that they can't
+// escape the prefix once resolved by the secret manager.
+func externalSecretAllowed(annotation, provider, name string) bool {
+	if strings.Contains(name, "..") {
+		return false
+	}
+	for _, entry := range strings.Split(annotation, ",") {
+		p, prefix, ok := strings.Cut(strings.TrimSpace(entry), ":")
+		if ok && p == provider && prefix != "" && strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// loadExternalSecret returns the vars of the ExternalSecret reference: its value
+// assigned to the var named after the reference key, or else the entries of the
+// JSON object the value is made of. The secret must be allowed by the external
+// secrets annotation of the namespace of the Kustomization, as the controller
+// identity may read the secrets of all the tenants.
+func loadExternalSecret(ctx context.Context, kubeClient client.Client, resolver ExternalSecretResolver,
+	namespace string, reference kustomizev1.SubstituteReference) (map[string]string, error) {
+	if resolver == nil {
+		return nil, fmt.Errorf("external secrets are disabled, the controller must be started with --enable-external-secrets")
+	}
+	if reference.Provider == "" {
+		return nil, fmt.Errorf("the provider of the external secret is not specified")
+	}
+
+	ns := namespaceMetadata()
+	if err := kubeClient.Get(ctx, types.NamespacedName{Name: namespace}, ns); err != nil {
+		return nil, fmt.Errorf("failed to get namespace '%s': %w", namespace, err)
+	}
+	if !externalSecretAllowed(ns.GetAnnotations()[kustomizev1.ExternalSecretsAnnotation], reference.Provider, reference.Name) {
+		return nil, fmt.Errorf("the '%s' secret is not allowed by the %s annotation of namespace '%s'",
+			reference.Provider, kustomizev1.ExternalSecretsAnnotation, namespace)
+	}
+
+	value, err := resolver.Resolve(ctx, reference.Provider, reference.Name)
+	if err != nil {
+		return nil, err
+	}
+	if reference.Key != "" {
+		return map[string]string{reference.Key: string(value)}, nil
+	}
+
+	vars := make(map[string]string)
+	if err := json.Unmarshal(value, &vars); err != nil {
+		return nil, fmt.Errorf("the value is not a JSON object of strings, the key of the var must be specified")
+	}
+	return vars, nil
+}
@@ -0,0 +1,138 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta2"
+)
+
+type fakeSecretResolver map[string]string
+
+func (f fakeSecretResolver) Resolve(_ context.Context, provider, name string) ([]byte, error) {
+	value, ok := f[provider+"/"+name]
+	if !ok {
+		return nil, errExternalSecretNotFound
+	}
+	return []byte(value), nil
+}
+
+func Test_loadVariables_externalSecrets(t *testing.T) {
+	g := NewWithT(t)
+
+	scheme := runtime.NewScheme()
+	g.Expect(corev1.AddToScheme(scheme)).To(Succeed())
+	kubeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+			Name: "apps",
+			Annotations: map[string]string{
+				kustomizev1.ExternalSecretsAnnotation: "aws:apps/, gcp:projects/apps/secrets/,azure:https://apps.vault.azure.net/secrets/",
+			},
+		}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "tenant"}},
+		&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "apps", Name: "vars"},
+			Data:       map[string]string{"db_host": "db.staging", "db_user": "app"},
+		},
+	).Build()
+	resolver := fakeSecretResolver{
+		"aws/apps/db":                     `{"db_user":"admin","db_password":"s3cr3t"}`,
+		"aws/infra/db":                    `{"db_password":"r00t"}`,
+		"gcp/projects/apps/secrets/token": "t0k3n",
+	}
+
+	k := kustomizev1.Kustomization{ObjectMeta: metav1.ObjectMeta{Namespace: "apps", Name: "app"}}
+	k.Spec.PostBuild = &kustomizev1.PostBuild{
+		SubstituteFrom: []kustomizev1.SubstituteReference{
+			{Kind: "ConfigMap", Name: "vars"},
+			{Kind: ExternalSecretKind, Provider: "aws", Name: "apps/db"},
+			{Kind: ExternalSecretKind, Provider: "gcp", Name: "projects/apps/secrets/token", Key: "api_token"},
+			{Kind: ExternalSecretKind, Provider: "azure", Name: "https://apps.vault.azure.net/secrets/missing", Optional: true},
+		},
+	}
+	g.Expect(hasExternalSecrets(k)).To(BeTrue())
+
+	vars, err := loadVariables(context.TODO(), kubeClient, resolver, k, nil)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(vars).To(Equal(map[string]string{
+		"db_host":     "db.staging",
+		"db_user":     "admin",
+		"db_password": "s3cr3t",
+		"api_token":   "t0k3n",
+	}))
+
+	// the external secrets must be enabled
+	_, err = loadVariables(context.TODO(), kubeClient, nil, k, nil)
+	g.Expect(err).To(MatchError(ContainSubstring("--enable-external-secrets")))
+
+	// the secret must be allowed by the namespace annotation
+	k.Spec.PostBuild.SubstituteFrom[1].Name = "infra/db"
+	_, err = loadVariables(context.TODO(), kubeClient, resolver, k, nil)
+	g.Expect(err).To(MatchError(ContainSubstring("not allowed by the %s annotation", kustomizev1.ExternalSecretsAnnotation)))
+	g.Expect(err.Error()).NotTo(ContainSubstring("r00t"))
+
+	k.Spec.PostBuild.SubstituteFrom[1].Name = "apps/../infra/db"
+	_, err = loadVariables(context.TODO(), kubeClient, resolver, k, nil)
+	g.Expect(err).To(MatchError(ContainSubstring("not allowed")))
+
+	// the namespaces without annotation can't read external secrets
+	k.Spec.PostBuild.SubstituteFrom[1].Name = "apps/db"
+	tenant := *k.DeepCopy()
+	tenant.Namespace = "tenant"
+	tenant.Spec.PostBuild.SubstituteFrom = tenant.Spec.PostBuild.SubstituteFrom[1:2]
+	_, err = loadVariables(context.TODO(), kubeClient, resolver, tenant, nil)
+	g.Expect(err).To(MatchError(ContainSubstring("not allowed")))
+
+	// a missing secret which is not optional
+	k.Spec.PostBuild.SubstituteFrom[3].Optional = false
+	_, err = loadVariables(context.TODO(), kubeClient, resolver, k, nil)
+	g.Expect(err).To(MatchError(errExternalSecretNotFound))
+
+	// a value which is not a JSON object
+	k.Spec.PostBuild.SubstituteFrom = []kustomizev1.SubstituteReference{
+		{Kind: ExternalSecretKind, Provider: "gcp", Name: "projects/apps/secrets/token"},
+	}
+	_, err = loadVariables(context.TODO(), kubeClient, resolver, k, nil)
+	g.Expect(err).To(MatchError(ContainSubstring("not a JSON object")))
+	g.Expect(err.Error()).NotTo(ContainSubstring("t0k3n"))
+}
+
+func TestCloudSecretResolver_azureURL(t *testing.T) {
+	g := NewWithT(t)
+
+	r := NewCloudSecretResolver()
+	for _, name := range []string{
+		"apps.vault.azure.net/secrets/db",
+		"http://apps.vault.azure.net/secrets/db",
+		"https://apps.vault.azure.net/keys/db",
+		"https://vault.azure.net.example.com/secrets/db",
+	} {
+		_, err := r.Resolve(context.TODO(), "azure", name)
+		g.Expect(err).To(MatchError(ContainSubstring("invalid Azure Key Vault secret URL")), name)
+	}
+
+	_, err := r.Resolve(context.TODO(), "vault", "db")
+	g.Expect(err).To(MatchError(ContainSubstring("unsupported external secret provider")))
+}
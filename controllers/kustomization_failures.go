@@ -0,0 +1,117 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/fluxcd/pkg/ssa"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/cli-utils/pkg/object"
+
+	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta2"
+)
+
+// maxObjectFailureMessageLength is the maximum length of the message
+// recorded for an object that failed to apply.
+const maxObjectFailureMessageLength = 1024
+
+// applyFailuresError wraps an apply error with the objects that caused it.
+type applyFailuresError struct {
+	err      error
+	failures []kustomizev1.ObjectFailure
+}
+
+func (e *applyFailuresError) Error() string {
+	return e.err.Error()
+}
+
+func (e *applyFailuresError) Unwrap() error {
+	return e.err
+}
+
+// applyFailures returns the objects that failed to apply, if any.
+func applyFailures(err error) []kustomizev1.ObjectFailure {
+	var failuresErr *applyFailuresError
+	if errors.As(err, &failuresErr) {
+		return failuresErr.failures
+	}
+	return nil
+}
+
+// collectFailures validates each object with a server-side dry-run to find
+// the ones that caused the apply error. When the dry-run of all objects
+// succeeds, the object named by the apply error is recorded instead.
+func collectFailures(ctx context.Context, manager *ssa.ResourceManager, objects []*unstructured.Unstructured,
+	opts ssa.ApplyOptions, applyErr error) error {
+	var failures []kustomizev1.ObjectFailure
+	for _, u := range objects {
+		if len(failures) >= kustomizev1.MaxLastAttemptedFailures {
+			break
+		}
+		_, _, _, err := manager.Diff(ctx, u, ssa.DiffOptions{Exclusions: opts.Exclusions})
+		if err == nil {
+			continue
+		}
+		// immutable field changes are handled by recreating the object
+		forced := opts.Force || hasObjectPolicy(u, kustomizev1.ForceObjectPolicy)
+		if forced && ssa.IsImmutableError(err) {
+			continue
+		}
+		failures = append(failures, newObjectFailure(u, err))
+	}
+
+	if len(failures) == 0 {
+		for _, u := range objects {
+			if strings.HasPrefix(applyErr.Error(), ssa.FmtUnstructured(u)) {
+				failures = append(failures, newObjectFailure(u, applyErr))
+				break
+			}
+		}
+	}
+
+	return &applyFailuresError{err: applyErr, failures: failures}
+}
+
+// newObjectFailure returns the failure record of the object,
+// with the message truncated to maxObjectFailureMessageLength.
+func newObjectFailure(u *unstructured.Unstructured, err error) kustomizev1.ObjectFailure {
+	msg := err.Error()
+	if len(msg) > maxObjectFailureMessageLength {
+		msg = msg[:maxObjectFailureMessageLength-3] + "..."
+	}
+	return kustomizev1.ObjectFailure{
+		ID:      object.UnstructuredToObjMetadata(u).String(),
+		Reason:  failureReason(err),
+		Message: msg,
+	}
+}
+
+// failureReason returns the reason of the apply error, as reported by
+// the Kubernetes API server.
+func failureReason(err error) string {
+	if _, ok := missingKind(err); ok {
+		return kustomizev1.NotYetInstalledReason
+	}
+	if reason := apierrors.ReasonForError(err); reason != "" {
+		return string(reason)
+	}
+	return kustomizev1.ReconciliationFailedReason
+}
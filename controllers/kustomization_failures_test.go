@@ -0,0 +1,72 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+
+	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta2"
+)
+
+func Test_newObjectFailure(t *testing.T) {
+	g := NewWithT(t)
+
+	u := &unstructured.Unstructured{}
+	u.SetAPIVersion("apps/v1")
+	u.SetKind("Deployment")
+	u.SetNamespace("apps")
+	u.SetName("podinfo")
+
+	invalid := apierrors.NewInvalid(schema.GroupKind{Group: "apps", Kind: "Deployment"}, "podinfo",
+		field.ErrorList{field.Invalid(field.NewPath("spec", "selector"), nil, "field is immutable")})
+	failure := newObjectFailure(u, fmt.Errorf("Deployment/apps/podinfo dry-run failed, error: %w", invalid))
+	g.Expect(failure.ID).To(Equal("apps_podinfo_apps_Deployment"))
+	g.Expect(failure.Reason).To(Equal("Invalid"))
+	g.Expect(failure.Message).To(ContainSubstring("field is immutable"))
+
+	noMatch := &apimeta.NoKindMatchError{GroupKind: schema.GroupKind{Group: "apps", Kind: "Deployment"}}
+	failure = newObjectFailure(u, noMatch)
+	g.Expect(failure.Reason).To(Equal(kustomizev1.NotYetInstalledReason))
+
+	failure = newObjectFailure(u, fmt.Errorf("%s", strings.Repeat("x", 2*maxObjectFailureMessageLength)))
+	g.Expect(failure.Reason).To(Equal(kustomizev1.ReconciliationFailedReason))
+	g.Expect(failure.Message).To(HaveLen(maxObjectFailureMessageLength))
+}
+
+func Test_applyFailures(t *testing.T) {
+	g := NewWithT(t)
+
+	failures := []kustomizev1.ObjectFailure{
+		{ID: "apps_podinfo_apps_Deployment", Reason: "Invalid", Message: "field is immutable"},
+	}
+	err := &applyFailuresError{err: fmt.Errorf("Deployment/apps/podinfo dry-run failed"), failures: failures}
+	wrapped := fmt.Errorf("%w\n%s", err, "Namespace/apps created")
+
+	g.Expect(wrapped.Error()).To(HavePrefix("Deployment/apps/podinfo dry-run failed"))
+	g.Expect(applyFailures(wrapped)).To(Equal(failures))
+	g.Expect(applyFailures(fmt.Errorf("apply failed"))).To(BeEmpty())
+	g.Expect(applyFailures(nil)).To(BeEmpty())
+}
@@ -17,7 +17,9 @@ limitations under the License.
 package controllers
 
 import (
+	"archive/tar"
 	"bytes"
+	"compress/gzip"
 	"crypto/sha1"
 	"crypto/sha256"
 	"errors"
@@ -26,22 +28,56 @@ import (
 	"net/http"
 	"net/url"
 	"os"
+	"path"
+	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/fluxcd/pkg/untar"
 	sourcev1 "github.com/fluxcd/source-controller/api/v1beta2"
 	"github.com/hashicorp/go-retryablehttp"
+
+	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta2"
 )
 
 // ArtifactFetcher holds the HTTP client that reties with back off when
 // the artifact server is offline.
 type ArtifactFetcher struct {
 	httpClient *retryablehttp.Client
+
+	// mirrors holds the mirrors the artifacts are fetched from instead of
+	// the hosts advertised by the sources.
+	mirrors ArtifactMirrors
 }
 
+// ArtifactFilterFunc returns true if the artifact entry with the given path should be extracted.
+type ArtifactFilterFunc func(name string, isDir bool) bool
+
 // ArtifactNotFoundError is an error type used to signal 404 HTTP status code responses.
 var ArtifactNotFoundError = errors.New("artifact not found")
 
+// ArtifactCorruptedError is an error type used to signal that the downloaded artifact
+// doesn't match the advertised checksum or can't be extracted.
+var ArtifactCorruptedError = errors.New("artifact corrupted")
+
+// artifactCorruptedError wraps the verification and extraction errors,
+// so that they match ArtifactCorruptedError while keeping their message.
+type artifactCorruptedError struct {
+	err error
+}
+
+func (e *artifactCorruptedError) Error() string {
+	return e.err.Error()
+}
+
+func (e *artifactCorruptedError) Unwrap() error {
+	return e.err
+}
+
+func (e *artifactCorruptedError) Is(target error) bool {
+	return target == ArtifactCorruptedError
+}
+
 // NewArtifactFetcher configures the retryable http client used for fetching artifacts.
 // By default, it retries 10 times within a 3.5 minutes window.
 func NewArtifactFetcher(retries int) *ArtifactFetcher {
@@ -58,9 +94,19 @@ func NewArtifactFetcher(retries int) *ArtifactFetcher {
 // If the artifact server responds with 5xx errors, the download operation is retried.
 // If the artifact server responds with 404, the returned error is of type ArtifactNotFoundError.
 // If the artifact server is unavailable for more than 3 minutes, the returned error contains the original status code.
+// If the checksum verification or the extraction fails, the returned error is of type ArtifactCorruptedError.
 func (r *ArtifactFetcher) Fetch(artifact *sourcev1.Artifact, dir string) error {
-	artifactURL := artifact.URL
-	if hostname := os.Getenv("SOURCE_CONTROLLER_LOCALHOST"); hostname != "" {
+	return r.FetchWithFilter(artifact, dir, nil)
+}
+
+// FetchWithFilter downloads and verifies the artifact, then extracts to the specified
+// directory only the files for which the filter returns true. A nil filter extracts all files.
+func (r *ArtifactFetcher) FetchWithFilter(artifact *sourcev1.Artifact, dir string, filter ArtifactFilterFunc) error {
+	artifactURL, mirrored, err := r.mirrors.Rewrite(artifact.URL)
+	if err != nil {
+		return err
+	}
+	if hostname := os.Getenv("SOURCE_CONTROLLER_LOCALHOST"); hostname != "" && !mirrored {
 		u, err := url.Parse(artifactURL)
 		if err != nil {
 			return err
@@ -91,12 +137,84 @@ func (r *ArtifactFetcher) Fetch(artifact *sourcev1.Artifact, dir string) error {
 
 	// verify checksum matches origin
 	if err := r.Verify(artifact, &buf, resp.Body); err != nil {
-		return err
+		return &artifactCorruptedError{err}
+	}
+
+	// extract only the files that pass the filter
+	if filter != nil {
+		if err := untarWithFilter(&buf, dir, filter); err != nil {
+			return &artifactCorruptedError{fmt.Errorf("failed to untar artifact, error: %w", err)}
+		}
+		return nil
 	}
 
 	// extract
 	if _, err = untar.Untar(&buf, dir); err != nil {
-		return fmt.Errorf("failed to untar artifact, error: %w", err)
+		return &artifactCorruptedError{fmt.Errorf("failed to untar artifact, error: %w", err)}
+	}
+
+	return nil
+}
+
+// untarWithFilter reads the gzip compressed tarball from r and writes into dir
+// the entries that pass the filter, without buffering the decompressed content.
+// Like untar.Untar, only regular files and directories are supported.
+func untarWithFilter(r io.Reader, dir string, filter ArtifactFilterFunc) error {
+	gzr, err := gzip.NewReader(r)
+	if err != nil {
+		return fmt.Errorf("requires gzip-compressed body: %w", err)
+	}
+	defer gzr.Close()
+
+	tr := tar.NewReader(gzr)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("tar error: %w", err)
+		}
+
+		if hdr.Name == "" || strings.Contains(hdr.Name, `\`) ||
+			strings.HasPrefix(hdr.Name, "/") || strings.Contains(hdr.Name, "../") {
+			return fmt.Errorf("tar contained invalid name error %q", hdr.Name)
+		}
+
+		mode := hdr.FileInfo().Mode()
+		if !mode.IsRegular() && !mode.IsDir() {
+			return fmt.Errorf("tar file entry %s contained unsupported file type %v", hdr.Name, mode)
+		}
+
+		if !filter(hdr.Name, mode.IsDir()) {
+			continue
+		}
+
+		abs := filepath.Join(dir, filepath.FromSlash(hdr.Name))
+		if mode.IsDir() {
+			if err := os.MkdirAll(abs, 0o755); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if err := os.MkdirAll(filepath.Dir(abs), 0o755); err != nil {
+			return err
+		}
+		f, err := os.OpenFile(abs, os.O_RDWR|os.O_CREATE|os.O_TRUNC, mode.Perm())
+		if err != nil {
+			return err
+		}
+		n, err := io.Copy(f, tr)
+		if closeErr := f.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return fmt.Errorf("error writing to %s: %w", abs, err)
+		}
+		if n != hdr.Size {
+			return fmt.Errorf("only wrote %d bytes to %s; expected %d", n, abs, hdr.Size)
+		}
 	}
 
 	return nil
@@ -125,3 +243,56 @@ func (r *ArtifactFetcher) Verify(artifact *sourcev1.Artifact, buf *bytes.Buffer,
 
 	return nil
 }
+
+// NewArtifactFilter returns the filter function matching the include and exclude
+// patterns of the given ArtifactFilter, or nil if there is nothing to filter.
+// Patterns without a slash match any path element, e.g. '*.md' or 'tests',
+// while patterns with a slash match the path from the root of the artifact,
+// e.g. 'docs/*'. A pattern matching a directory matches all the files in it.
+func NewArtifactFilter(filter *kustomizev1.ArtifactFilter) (ArtifactFilterFunc, error) {
+	if filter == nil || (len(filter.Include) == 0 && len(filter.Exclude) == 0) {
+		return nil, nil
+	}
+
+	for _, pattern := range append(append([]string{}, filter.Include...), filter.Exclude...) {
+		if _, err := path.Match(cleanPattern(pattern), ""); err != nil {
+			return nil, fmt.Errorf("invalid artifact filter pattern '%s': %w", pattern, err)
+		}
+	}
+
+	return func(name string, isDir bool) bool {
+		if matchAnyPattern(filter.Exclude, name) {
+			return false
+		}
+		if len(filter.Include) == 0 {
+			return true
+		}
+		// directories are created when extracting the files they contain
+		if isDir {
+			return false
+		}
+		return matchAnyPattern(filter.Include, name)
+	}, nil
+}
+
+func cleanPattern(pattern string) string {
+	return strings.Trim(strings.TrimPrefix(pattern, "./"), "/")
+}
+
+func matchAnyPattern(patterns []string, name string) bool {
+	name = strings.TrimPrefix(path.Clean("/"+name), "/")
+	elems := strings.Split(name, "/")
+	for _, pattern := range patterns {
+		pattern = cleanPattern(pattern)
+		for i := range elems {
+			subject := elems[i]
+			if strings.Contains(pattern, "/") {
+				subject = strings.Join(elems[:i+1], "/")
+			}
+			if ok, _ := path.Match(pattern, subject); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -17,8 +17,17 @@ limitations under the License.
 package controllers
 
 import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
 	"context"
+	"crypto/sha256"
+	"errors"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
@@ -29,6 +38,7 @@ import (
 	apimeta "k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta2"
@@ -153,3 +163,208 @@ stringData:
 		}, timeout, time.Second).Should(BeTrue())
 	})
 }
+
+func TestNewArtifactFilter(t *testing.T) {
+	tests := []struct {
+		name    string
+		filter  *kustomizev1.ArtifactFilter
+		entries map[string]bool
+	}{
+		{
+			name: "exclude by extension and directory",
+			filter: &kustomizev1.ArtifactFilter{
+				Exclude: []string{"*.md", "tests"},
+			},
+			entries: map[string]bool{
+				"README.md":                  false,
+				"apps/podinfo/README.md":     false,
+				"apps/podinfo/tests/a.yaml":  false,
+				"apps/podinfo/kustomization": true,
+				"apps/podinfo/deploy.yaml":   true,
+			},
+		},
+		{
+			name: "include anchored path",
+			filter: &kustomizev1.ArtifactFilter{
+				Include: []string{"./deploy/*"},
+				Exclude: []string{"deploy/staging"},
+			},
+			entries: map[string]bool{
+				"deploy/production/app.yaml": true,
+				"deploy/staging/app.yaml":    false,
+				"apps/deploy/app.yaml":       false,
+				"docs/index.md":              false,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			filter, err := NewArtifactFilter(tt.filter)
+			g.Expect(err).NotTo(HaveOccurred())
+			for name, want := range tt.entries {
+				g.Expect(filter(name, false)).To(Equal(want), name)
+			}
+		})
+	}
+
+	t.Run("no patterns", func(t *testing.T) {
+		g := NewWithT(t)
+
+		filter, err := NewArtifactFilter(&kustomizev1.ArtifactFilter{})
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(filter).To(BeNil())
+	})
+
+	t.Run("invalid pattern", func(t *testing.T) {
+		g := NewWithT(t)
+
+		_, err := NewArtifactFilter(&kustomizev1.ArtifactFilter{Exclude: []string{"[a-"}})
+		g.Expect(err).To(HaveOccurred())
+	})
+}
+
+func Test_untarWithFilter(t *testing.T) {
+	tarball := func(files map[string]string) *bytes.Buffer {
+		var buf bytes.Buffer
+		gzw := gzip.NewWriter(&buf)
+		tw := tar.NewWriter(gzw)
+		for name, content := range files {
+			hdr := &tar.Header{Name: name, Mode: 0o644, Size: int64(len(content)), Typeflag: tar.TypeReg}
+			if content == "" {
+				hdr = &tar.Header{Name: name, Mode: 0o755, Typeflag: tar.TypeDir}
+			}
+			if err := tw.WriteHeader(hdr); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := tw.Write([]byte(content)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := tw.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if err := gzw.Close(); err != nil {
+			t.Fatal(err)
+		}
+		return &buf
+	}
+
+	filter, err := NewArtifactFilter(&kustomizev1.ArtifactFilter{
+		Include: []string{"deploy/*"},
+		Exclude: []string{"*.md"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("extracts the filtered entries", func(t *testing.T) {
+		g := NewWithT(t)
+
+		dir := t.TempDir()
+		err := untarWithFilter(tarball(map[string]string{
+			"deploy/":              "",
+			"deploy/app/":          "",
+			"deploy/app/app.yaml":  "kind: ConfigMap",
+			"deploy/app/README.md": "# app",
+			"docs/":                "",
+			"docs/index.yaml":      "kind: Secret",
+			"deploy/infra/ns.yaml": "kind: Namespace",
+		}), dir, filter)
+		g.Expect(err).NotTo(HaveOccurred())
+
+		data, err := os.ReadFile(filepath.Join(dir, "deploy", "app", "app.yaml"))
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(string(data)).To(Equal("kind: ConfigMap"))
+		g.Expect(filepath.Join(dir, "deploy", "infra", "ns.yaml")).To(BeARegularFile())
+		g.Expect(filepath.Join(dir, "deploy", "app", "README.md")).NotTo(BeAnExistingFile())
+		g.Expect(filepath.Join(dir, "docs")).NotTo(BeAnExistingFile())
+	})
+
+	t.Run("rejects invalid paths", func(t *testing.T) {
+		g := NewWithT(t)
+
+		err := untarWithFilter(tarball(map[string]string{
+			"deploy/../../escape.yaml": "kind: ConfigMap",
+		}), t.TempDir(), filter)
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(err.Error()).To(ContainSubstring("invalid name"))
+	})
+}
+
+func TestKustomizationReconciler_fetchArtifact(t *testing.T) {
+	var buf bytes.Buffer
+	gzw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gzw)
+	content := "kind: ConfigMap"
+	if err := tw.WriteHeader(&tar.Header{Name: "app.yaml", Mode: 0o644, Size: int64(len(content)), Typeflag: tar.TypeReg}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tw.Write([]byte(content)); err != nil {
+		t.Fatal(err)
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gzw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	tarball := buf.Bytes()
+
+	// serve a truncated tarball on the first requests
+	corrupted := 0
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests <= corrupted {
+			_, _ = w.Write(tarball[:len(tarball)/2])
+			return
+		}
+		_, _ = w.Write(tarball)
+	}))
+	defer server.Close()
+
+	artifact := &sourcev1.Artifact{
+		URL:      server.URL + "/artifact.tar.gz",
+		Revision: "main/1",
+		Checksum: fmt.Sprintf("%x", sha256.Sum256(tarball)),
+	}
+
+	t.Run("re-fetches a corrupted artifact once", func(t *testing.T) {
+		g := NewWithT(t)
+		corrupted, requests = 1, 0
+		recorder := record.NewFakeRecorder(10)
+		r := &KustomizationReconciler{
+			artifactFetcher: NewArtifactFetcher(0),
+			EventRecorder:   recorder,
+		}
+
+		dir := t.TempDir()
+		g.Expect(os.WriteFile(filepath.Join(dir, "partial.yaml"), nil, 0o644)).To(Succeed())
+
+		err := r.fetchArtifact(context.TODO(), kustomizev1.Kustomization{}, artifact, dir, nil)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(requests).To(Equal(2))
+		g.Expect(filepath.Join(dir, "app.yaml")).To(BeARegularFile())
+		g.Expect(filepath.Join(dir, "partial.yaml")).NotTo(BeAnExistingFile())
+		g.Expect(recorder.Events).To(HaveLen(1))
+		g.Expect(<-recorder.Events).To(ContainSubstring("is corrupted, fetching it again"))
+	})
+
+	t.Run("fails when the artifact is still corrupted", func(t *testing.T) {
+		g := NewWithT(t)
+		corrupted, requests = 2, 0
+		r := &KustomizationReconciler{
+			artifactFetcher: NewArtifactFetcher(0),
+			EventRecorder:   record.NewFakeRecorder(10),
+		}
+
+		err := r.fetchArtifact(context.TODO(), kustomizev1.Kustomization{}, artifact, t.TempDir(), nil)
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(errors.Is(err, ArtifactCorruptedError)).To(BeTrue())
+		g.Expect(err.Error()).To(ContainSubstring("doesn't match advertised"))
+		g.Expect(requests).To(Equal(2))
+	})
+}
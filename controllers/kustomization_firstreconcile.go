@@ -0,0 +1,94 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"sort"
+
+	"github.com/fluxcd/pkg/ssa"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta2"
+	"github.com/fluxcd/kustomize-controller/pkg/diff"
+)
+
+// observesFirstReconcile returns true if the Kustomization has never applied
+// its resources and the first apply must be reviewed before proceeding.
+func observesFirstReconcile(kustomization kustomizev1.Kustomization) bool {
+	if kustomization.Spec.FirstReconcile != kustomizev1.FirstReconcileObserve {
+		return false
+	}
+	inv := kustomization.Status.Inventory
+	return kustomization.Status.LastAppliedRevision == "" && (inv == nil || len(inv.Entries) == 0)
+}
+
+// reviewFirstApply performs a server-side dry-run of the objects and returns
+// the changes that their apply would make to the cluster.
+func reviewFirstApply(ctx context.Context, manager *ssa.ResourceManager, revision string,
+	objects []*unstructured.Unstructured) (*kustomizev1.FirstApplyReview, error) {
+	entries, err := diff.Objects(ctx, manager, objects, diffOptions())
+	if err != nil {
+		return nil, err
+	}
+
+	var changes []kustomizev1.ObjectChange
+	unchanged := 0
+	for _, entry := range entries {
+		switch ssa.Action(entry.Action) {
+		case ssa.CreatedAction, ssa.ConfiguredAction:
+			changes = append(changes, kustomizev1.ObjectChange{ID: entry.ID, Action: entry.Action})
+		default:
+			unchanged++
+		}
+	}
+	return NewFirstApplyReview(revision, changes, unchanged), nil
+}
+
+// NewFirstApplyReview returns the review of the given changes, sorted by
+// object ID and truncated to the first maxPendingPruneEntries entries. The
+// digest is computed over all the changes.
+func NewFirstApplyReview(revision string, changes []kustomizev1.ObjectChange, unchanged int) *kustomizev1.FirstApplyReview {
+	entries := make([]kustomizev1.ObjectChange, len(changes))
+	copy(entries, changes)
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].ID < entries[j].ID
+	})
+
+	review := &kustomizev1.FirstApplyReview{
+		Revision:  revision,
+		Unchanged: unchanged,
+	}
+	hasher := sha256.New()
+	for _, entry := range entries {
+		hasher.Write([]byte(entry.ID + " " + entry.Action + "\n"))
+		if entry.Action == string(ssa.CreatedAction) {
+			review.Created++
+		} else {
+			review.Configured++
+		}
+	}
+	review.Digest = fmt.Sprintf("sha256:%x", hasher.Sum(nil))
+
+	if len(entries) > maxPendingPruneEntries {
+		entries = entries[:maxPendingPruneEntries]
+	}
+	review.Entries = entries
+	return review
+}
@@ -0,0 +1,101 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+	"testing"
+
+	. "github.com/onsi/gomega"
+
+	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta2"
+)
+
+func Test_observesFirstReconcile(t *testing.T) {
+	tests := []struct {
+		name   string
+		mode   string
+		status kustomizev1.KustomizationStatus
+		want   bool
+	}{
+		{name: "default", want: false},
+		{name: "apply", mode: kustomizev1.FirstReconcileApply, want: false},
+		{name: "observe new", mode: kustomizev1.FirstReconcileObserve, want: true},
+		{
+			name:   "observe empty inventory",
+			mode:   kustomizev1.FirstReconcileObserve,
+			status: kustomizev1.KustomizationStatus{Inventory: &kustomizev1.ResourceInventory{}},
+			want:   true,
+		},
+		{
+			name:   "observe applied",
+			mode:   kustomizev1.FirstReconcileObserve,
+			status: kustomizev1.KustomizationStatus{LastAppliedRevision: "main/1"},
+			want:   false,
+		},
+		{
+			name: "observe with inventory",
+			mode: kustomizev1.FirstReconcileObserve,
+			status: kustomizev1.KustomizationStatus{Inventory: &kustomizev1.ResourceInventory{
+				Entries: []kustomizev1.ResourceRef{{ID: "test_podinfo_apps_Deployment", Version: "v1"}},
+			}},
+			want: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			k := kustomizev1.Kustomization{
+				Spec:   kustomizev1.KustomizationSpec{FirstReconcile: tt.mode},
+				Status: tt.status,
+			}
+			g.Expect(observesFirstReconcile(k)).To(Equal(tt.want))
+		})
+	}
+}
+
+func TestNewFirstApplyReview(t *testing.T) {
+	g := NewWithT(t)
+
+	changes := []kustomizev1.ObjectChange{
+		{ID: "test_frontend__Service", Action: "created"},
+		{ID: "test_backend_apps_Deployment", Action: "configured"},
+	}
+	review := NewFirstApplyReview("main/1", changes, 3)
+	g.Expect(review.Revision).To(Equal("main/1"))
+	g.Expect(review.Created).To(Equal(1))
+	g.Expect(review.Configured).To(Equal(1))
+	g.Expect(review.Unchanged).To(Equal(3))
+	g.Expect(review.Entries[0].ID).To(Equal("test_backend_apps_Deployment"))
+	g.Expect(review.Digest).To(HavePrefix("sha256:"))
+
+	// the digest does not depend on the order of the changes
+	reversed := NewFirstApplyReview("main/1", []kustomizev1.ObjectChange{changes[1], changes[0]}, 3)
+	g.Expect(reversed.Digest).To(Equal(review.Digest))
+
+	// the digest changes with the action
+	changes[1].Action = "created"
+	g.Expect(NewFirstApplyReview("main/1", changes, 3).Digest).NotTo(Equal(review.Digest))
+
+	var many []kustomizev1.ObjectChange
+	for i := 0; i < maxPendingPruneEntries+10; i++ {
+		many = append(many, kustomizev1.ObjectChange{ID: fmt.Sprintf("test_cm%03d__ConfigMap", i), Action: "created"})
+	}
+	truncated := NewFirstApplyReview("main/1", many, 0)
+	g.Expect(truncated.Entries).To(HaveLen(maxPendingPruneEntries))
+	g.Expect(truncated.Created).To(Equal(maxPendingPruneEntries + 10))
+}
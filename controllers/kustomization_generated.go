@@ -0,0 +1,123 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta2"
+)
+
+// generatedNameSuffix matches the names of the ConfigMaps and Secrets suffixed
+// by the Kustomize generators with the hash of their content, made of ten
+// characters from the alphabet of the Kustomize hasher.
+var generatedNameSuffix = regexp.MustCompile(`^(.+)-[2456789bcdfghkmt]{10}$`)
+
+// generatedKey returns the key shared by the generations of a ConfigMap or Secret
+// created by the Kustomize generators, and false for the other objects.
+func generatedKey(obj *unstructured.Unstructured) (string, bool) {
+	gvk := obj.GroupVersionKind()
+	if gvk.Group != "" || (gvk.Kind != "ConfigMap" && gvk.Kind != "Secret") {
+		return "", false
+	}
+	match := generatedNameSuffix.FindStringSubmatch(obj.GetName())
+	if match == nil {
+		return "", false
+	}
+	return fmt.Sprintf("%s/%s/%s", gvk.Kind, obj.GetNamespace(), match[1]), true
+}
+
+// generatedHistory returns the number of previous generations of the
+// generated objects that are kept from garbage collection.
+func generatedHistory(kustomization kustomizev1.Kustomization) int {
+	if kustomization.Spec.PruneOptions == nil {
+		return 0
+	}
+	return kustomization.Spec.PruneOptions.GeneratedHistory
+}
+
+// keepGeneratedHistory splits the stale objects into the ones to delete and the
+// previous generations of the generated ConfigMaps and Secrets to keep, i.e. the
+// most recently created ones, up to the history limit, whose current generation
+// is still applied. The generations are ordered by their creation timestamp
+// on the cluster, the ones that are not found are left to garbage collection.
+func keepGeneratedHistory(ctx context.Context, kubeClient client.Client, history int,
+	objects []*unstructured.Unstructured, stale []*unstructured.Unstructured) ([]*unstructured.Unstructured, []*unstructured.Unstructured, error) {
+	if history <= 0 || len(stale) == 0 {
+		return stale, nil, nil
+	}
+
+	current := make(map[string]bool)
+	for _, obj := range objects {
+		if key, ok := generatedKey(obj); ok {
+			current[key] = true
+		}
+	}
+
+	generations := make(map[string][]*unstructured.Unstructured)
+	for _, obj := range stale {
+		key, ok := generatedKey(obj)
+		if !ok || !current[key] {
+			continue
+		}
+
+		existing := &unstructured.Unstructured{}
+		existing.SetGroupVersionKind(obj.GroupVersionKind())
+		if err := kubeClient.Get(ctx, client.ObjectKeyFromObject(obj), existing); err != nil {
+			if apierrors.IsNotFound(err) {
+				continue
+			}
+			return nil, nil, fmt.Errorf("failed to get %s '%s': %w", obj.GetKind(), client.ObjectKeyFromObject(obj), err)
+		}
+		obj.SetCreationTimestamp(existing.GetCreationTimestamp())
+		generations[key] = append(generations[key], obj)
+	}
+
+	keep := make(map[*unstructured.Unstructured]bool)
+	for _, objs := range generations {
+		sort.SliceStable(objs, func(i, j int) bool {
+			ti, tj := objs[i].GetCreationTimestamp(), objs[j].GetCreationTimestamp()
+			if !ti.Equal(&tj) {
+				return tj.Before(&ti)
+			}
+			return objs[i].GetName() < objs[j].GetName()
+		})
+		if len(objs) > history {
+			objs = objs[:history]
+		}
+		for _, obj := range objs {
+			keep[obj] = true
+		}
+	}
+
+	var deleted, kept []*unstructured.Unstructured
+	for _, obj := range stale {
+		if keep[obj] {
+			kept = append(kept, obj)
+		} else {
+			deleted = append(deleted, obj)
+		}
+	}
+	return deleted, kept, nil
+}
@@ -0,0 +1,124 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func Test_generatedKey(t *testing.T) {
+	g := NewWithT(t)
+
+	newObject := func(apiVersion, kind, name string) *unstructured.Unstructured {
+		obj := &unstructured.Unstructured{}
+		obj.SetAPIVersion(apiVersion)
+		obj.SetKind(kind)
+		obj.SetNamespace("apps")
+		obj.SetName(name)
+		return obj
+	}
+
+	key, ok := generatedKey(newObject("v1", "ConfigMap", "app-config-7b2fm9kd5t"))
+	g.Expect(ok).To(BeTrue())
+	g.Expect(key).To(Equal("ConfigMap/apps/app-config"))
+
+	key, ok = generatedKey(newObject("v1", "Secret", "app-tls-h4c6g8k2mt"))
+	g.Expect(ok).To(BeTrue())
+	g.Expect(key).To(Equal("Secret/apps/app-tls"))
+
+	_, ok = generatedKey(newObject("v1", "ConfigMap", "app-config"))
+	g.Expect(ok).To(BeFalse())
+	_, ok = generatedKey(newObject("v1", "ConfigMap", "app-configuration"))
+	g.Expect(ok).To(BeFalse())
+	_, ok = generatedKey(newObject("v1", "ConfigMap", "app-config-abcdefghij"))
+	g.Expect(ok).To(BeFalse())
+	_, ok = generatedKey(newObject("apps/v1", "Deployment", "app-7b2fm9kd5t"))
+	g.Expect(ok).To(BeFalse())
+}
+
+func Test_keepGeneratedHistory(t *testing.T) {
+	g := NewWithT(t)
+
+	scheme := runtime.NewScheme()
+	g.Expect(corev1.AddToScheme(scheme)).To(Succeed())
+
+	now := time.Now()
+	configMap := func(name string, age time.Duration) *corev1.ConfigMap {
+		return &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{
+			Namespace:         "apps",
+			Name:              name,
+			CreationTimestamp: metav1.NewTime(now.Add(-age).Truncate(time.Second)),
+		}}
+	}
+	kubeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+		configMap("app-config-g2g2g2g2g2", time.Minute),
+		configMap("app-config-h4h4h4h4h4", time.Hour),
+		configMap("app-config-k5k5k5k5k5", 2*time.Hour),
+		configMap("removed-config-m6m6m6m6m6", time.Hour),
+		configMap("static-config", time.Hour),
+	).Build()
+
+	toUnstructured := func(name string) *unstructured.Unstructured {
+		obj := &unstructured.Unstructured{}
+		obj.SetAPIVersion("v1")
+		obj.SetKind("ConfigMap")
+		obj.SetNamespace("apps")
+		obj.SetName(name)
+		return obj
+	}
+	names := func(objects []*unstructured.Unstructured) []string {
+		var result []string
+		for _, obj := range objects {
+			result = append(result, obj.GetName())
+		}
+		return result
+	}
+
+	objects := []*unstructured.Unstructured{toUnstructured("app-config-t7t7t7t7t7")}
+	stale := []*unstructured.Unstructured{
+		toUnstructured("app-config-k5k5k5k5k5"),
+		toUnstructured("app-config-g2g2g2g2g2"),
+		toUnstructured("app-config-h4h4h4h4h4"),
+		toUnstructured("app-config-b9b9b9b9b9"),
+		toUnstructured("removed-config-m6m6m6m6m6"),
+		toUnstructured("static-config"),
+	}
+
+	deleted, kept, err := keepGeneratedHistory(context.TODO(), kubeClient, 2, objects, stale)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(names(kept)).To(Equal([]string{"app-config-g2g2g2g2g2", "app-config-h4h4h4h4h4"}))
+	g.Expect(names(deleted)).To(Equal([]string{
+		"app-config-k5k5k5k5k5",
+		"app-config-b9b9b9b9b9",
+		"removed-config-m6m6m6m6m6",
+		"static-config",
+	}))
+
+	deleted, kept, err = keepGeneratedHistory(context.TODO(), kubeClient, 0, objects, stale)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(kept).To(BeEmpty())
+	g.Expect(deleted).To(HaveLen(len(stale)))
+}
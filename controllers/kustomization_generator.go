@@ -21,6 +21,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"sync"
 
@@ -41,6 +42,14 @@ import (
 type KustomizeGenerator struct {
 	root          string
 	kustomization kustomizev1.Kustomization
+	warnings      []string
+
+	// openAPISchema is the path of the OpenAPI schema set in the kustomization.yaml
+	openAPISchema string
+
+	// sortOptions is the order of the build output, read from the kustomization.yaml
+	// or from the spec.buildOptions
+	sortOptions *kustomizev1.SortOptions
 }
 
 func NewGenerator(root string, kustomization kustomizev1.Kustomization) *KustomizeGenerator {
@@ -50,6 +59,17 @@ func NewGenerator(root string, kustomization kustomizev1.Kustomization) *Kustomi
 	}
 }
 
+// SortOptions returns the order of the build output, set by WriteFile.
+func (kg *KustomizeGenerator) SortOptions() *kustomizev1.SortOptions {
+	return kg.sortOptions
+}
+
+// Warnings returns the non-fatal issues found while validating and
+// generating the kustomization files.
+func (kg *KustomizeGenerator) Warnings() []string {
+	return kg.warnings
+}
+
 func (kg *KustomizeGenerator) WriteFile(dirPath string) error {
 	if err := kg.generateKustomization(dirPath); err != nil {
 		return err
@@ -62,6 +82,22 @@ func (kg *KustomizeGenerator) WriteFile(dirPath string) error {
 		return err
 	}
 
+	// the kustomize API rejects the sortOptions field, it is applied after the build
+	kg.sortOptions, data, err = extractSortOptions(data)
+	if err != nil {
+		return fmt.Errorf("invalid %s: %w", konfig.DefaultKustomizationFileName(), err)
+	}
+	if opts := kg.kustomization.Spec.BuildOptions; opts != nil && opts.SortOptions != nil {
+		if kg.sortOptions != nil {
+			kg.warnings = append(kg.warnings,
+				fmt.Sprintf("sortOptions set in %s is overridden by spec.buildOptions.sortOptions", konfig.DefaultKustomizationFileName()))
+		}
+		kg.sortOptions = opts.SortOptions
+	}
+	if err := removeSortOptions(kg.root); err != nil {
+		return err
+	}
+
 	kus := kustypes.Kustomization{
 		TypeMeta: kustypes.TypeMeta{
 			APIVersion: kustypes.KustomizationVersion,
@@ -74,13 +110,19 @@ func (kg *KustomizeGenerator) WriteFile(dirPath string) error {
 	}
 
 	if kg.kustomization.Spec.TargetNamespace != "" {
-		kus.Namespace = kg.kustomization.Spec.TargetNamespace
+		// with exemptions, the namespace is set after the build by setTargetNamespace
+		if len(kg.kustomization.Spec.TargetNamespaceExemptions) > 0 {
+			kus.Namespace = ""
+		} else {
+			kus.Namespace = kg.kustomization.Spec.TargetNamespace
+		}
 	}
 
 	for _, m := range kg.kustomization.Spec.Patches {
 		kus.Patches = append(kus.Patches, kustypes.Patch{
-			Patch:  m.Patch,
-			Target: adaptSelector(&m.Target),
+			Patch:   m.Patch,
+			Target:  adaptSelector(&m.Target),
+			Options: adaptPatchOptions(kg.kustomization.Spec.PatchOptions),
 		})
 	}
 
@@ -107,12 +149,40 @@ func (kg *KustomizeGenerator) WriteFile(dirPath string) error {
 			Digest:  image.Digest,
 		}
 		if exists, index := checkKustomizeImageExists(kus.Images, image.Name); exists {
+			if kus.Images[index] != newImage {
+				kg.warnings = append(kg.warnings,
+					fmt.Sprintf("image '%s' set in %s is overridden by spec.images", image.Name, konfig.DefaultKustomizationFileName()))
+			}
 			kus.Images[index] = newImage
 		} else {
 			kus.Images = append(kus.Images, newImage)
 		}
 	}
 
+	kg.mergeGenerators(&kus)
+
+	buildMetadata, err := kg.buildMetadata(kus.BuildMetadata)
+	if err != nil {
+		return err
+	}
+	kus.BuildMetadata = buildMetadata
+
+	if kg.openAPISchema != "" {
+		abs, err := filepath.Abs(dirPath)
+		if err != nil {
+			return err
+		}
+		path, err := filepath.Rel(abs, kg.openAPISchema)
+		if err != nil {
+			return err
+		}
+		if len(kus.OpenAPI) > 0 {
+			kg.warnings = append(kg.warnings,
+				fmt.Sprintf("openapi set in %s is overridden by spec.buildOptions.openapi", konfig.DefaultKustomizationFileName()))
+		}
+		kus.OpenAPI = map[string]string{"path": path}
+	}
+
 	kd, err := yaml.Marshal(kus)
 	if err != nil {
 		return err
@@ -120,6 +190,163 @@ func (kg *KustomizeGenerator) WriteFile(dirPath string) error {
 	return os.WriteFile(kfile, kd, os.ModePerm)
 }
 
+// ValidateFile decodes strictly the kustomization file found at dirPath, if any,
+// along with the kustomization files of the local bases and components it refers to,
+// and returns an error listing the unknown or misspelled fields. Remote bases are not validated.
+func (kg *KustomizeGenerator) ValidateFile(dirPath string) error {
+	fs, err := securefs.MakeFsOnDiskSecure(kg.root)
+	if err != nil {
+		return err
+	}
+
+	return kg.validateTree(fs, dirPath, make(map[string]bool))
+}
+
+func (kg *KustomizeGenerator) validateTree(fs filesys.FileSystem, dirPath string, visited map[string]bool) error {
+	dirPath = filepath.Clean(dirPath)
+	if visited[dirPath] {
+		return nil
+	}
+	visited[dirPath] = true
+
+	for _, kfilename := range konfig.RecognizedKustomizationFileNames() {
+		kpath := filepath.Join(dirPath, kfilename)
+		if !fs.Exists(kpath) || fs.IsDir(kpath) {
+			continue
+		}
+
+		data, err := fs.ReadFile(kpath)
+		if err != nil {
+			return err
+		}
+
+		name := kfilename
+		if rel, err := filepath.Rel(kg.root, kpath); err == nil {
+			name = rel
+		}
+
+		kg.warnings = append(kg.warnings, deprecatedFields(name, data)...)
+
+		_, data, err = extractSortOptions(data)
+		if err != nil {
+			return fmt.Errorf("invalid %s: %w", name, err)
+		}
+
+		var kus kustypes.Kustomization
+		if err := yaml.UnmarshalStrict(data, &kus); err != nil {
+			return fmt.Errorf("invalid %s: %w", name, err)
+		}
+
+		refs := append(append(append([]string{}, kus.Resources...), kus.Components...), kus.Bases...)
+		for _, ref := range refs {
+			if strings.Contains(ref, "://") {
+				continue
+			}
+			if refPath := filepath.Join(dirPath, ref); fs.IsDir(refPath) {
+				if err := kg.validateTree(fs, refPath, visited); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+	return nil
+}
+
+// deprecatedKustomizeFields maps the kustomization fields deprecated by
+// kustomize to the fields replacing them.
+var deprecatedKustomizeFields = map[string]string{
+	"bases":                 "resources",
+	"commonLabels":          "labels",
+	"imageTags":             "images",
+	"patchesJson6902":       "patches",
+	"patchesStrategicMerge": "patches",
+	"vars":                  "replacements",
+}
+
+// deprecatedFields returns a warning for each deprecated field set in the
+// kustomization file data, sorted by field name.
+func deprecatedFields(name string, data []byte) []string {
+	var fields map[string]interface{}
+	if err := yaml.Unmarshal(data, &fields); err != nil {
+		return nil
+	}
+
+	var warnings []string
+	for field := range fields {
+		if replacement, ok := deprecatedKustomizeFields[field]; ok {
+			warnings = append(warnings,
+				fmt.Sprintf("deprecated field '%s' in %s, use '%s' instead", field, name, replacement))
+		}
+	}
+	sort.Strings(warnings)
+	return warnings
+}
+
+// mergeGenerators adds the spec.configMapGenerator and spec.secretGenerator
+// entries to the generators of the kustomization file, replacing the ones
+// with the same name and namespace.
+func (kg *KustomizeGenerator) mergeGenerators(kus *kustypes.Kustomization) {
+	for _, gen := range kg.kustomization.Spec.ConfigMapGenerator {
+		args := kustypes.ConfigMapArgs{GeneratorArgs: adaptGeneratorArgs(gen)}
+		index := -1
+		for i, existing := range kus.ConfigMapGenerator {
+			if existing.Name == gen.Name && existing.Namespace == gen.Namespace {
+				index = i
+				break
+			}
+		}
+		if index < 0 {
+			kus.ConfigMapGenerator = append(kus.ConfigMapGenerator, args)
+			continue
+		}
+		kg.warnings = append(kg.warnings,
+			fmt.Sprintf("configMapGenerator '%s' set in %s is overridden by spec.configMapGenerator", gen.Name, konfig.DefaultKustomizationFileName()))
+		kus.ConfigMapGenerator[index] = args
+	}
+
+	for _, gen := range kg.kustomization.Spec.SecretGenerator {
+		args := kustypes.SecretArgs{GeneratorArgs: adaptGeneratorArgs(gen.GeneratorArgs), Type: gen.Type}
+		index := -1
+		for i, existing := range kus.SecretGenerator {
+			if existing.Name == gen.Name && existing.Namespace == gen.Namespace {
+				index = i
+				break
+			}
+		}
+		if index < 0 {
+			kus.SecretGenerator = append(kus.SecretGenerator, args)
+			continue
+		}
+		kg.warnings = append(kg.warnings,
+			fmt.Sprintf("secretGenerator '%s' set in %s is overridden by spec.secretGenerator", gen.Name, konfig.DefaultKustomizationFileName()))
+		kus.SecretGenerator[index] = args
+	}
+}
+
+// adaptGeneratorArgs converts the generator arguments of the spec to the kustomize ones.
+func adaptGeneratorArgs(gen kustomizev1.GeneratorArgs) kustypes.GeneratorArgs {
+	args := kustypes.GeneratorArgs{
+		Namespace: gen.Namespace,
+		Name:      gen.Name,
+		Behavior:  gen.Behavior,
+		KvPairSources: kustypes.KvPairSources{
+			LiteralSources: gen.Literals,
+			FileSources:    gen.Files,
+			EnvSources:     gen.Envs,
+		},
+	}
+	if opts := gen.Options; opts != nil {
+		args.Options = &kustypes.GeneratorOptions{
+			Labels:                opts.Labels,
+			Annotations:           opts.Annotations,
+			DisableNameSuffixHash: opts.DisableNameSuffixHash,
+			Immutable:             opts.Immutable,
+		}
+	}
+	return args
+}
+
 func checkKustomizeImageExists(images []kustypes.Image, imageName string) (bool, int) {
 	for i, image := range images {
 		if imageName == image.Name {
@@ -144,6 +371,16 @@ func (kg *KustomizeGenerator) generateKustomization(dirPath string) error {
 		}
 	}
 
+	if opts := kg.kustomization.Spec.BuildOptions; opts != nil && opts.RequireKustomizationFile {
+		return fmt.Errorf("%s not found in %s, generation is disabled by buildOptions.requireKustomizationFile",
+			konfig.DefaultKustomizationFileName(), strings.TrimPrefix(dirPath, kg.root))
+	}
+
+	extensions := make(map[string]bool)
+	for _, ext := range kg.manifestExtensions() {
+		extensions[ext] = true
+	}
+
 	scan := func(base string) ([]string, error) {
 		var paths []string
 		pvd := provider.NewDefaultDepProvider()
@@ -168,7 +405,7 @@ func (kg *KustomizeGenerator) generateKustomization(dirPath string) error {
 			}
 
 			extension := filepath.Ext(path)
-			if extension != ".yaml" && extension != ".yml" {
+			if !extensions[extension] || path == kg.openAPISchema {
 				return nil
 			}
 
@@ -177,8 +414,15 @@ func (kg *KustomizeGenerator) generateKustomization(dirPath string) error {
 				return err
 			}
 
+			// JSON files are often used for other purposes than Kubernetes manifests
+			if extension == ".json" && isPlainJSON(fContents) {
+				kg.warnings = append(kg.warnings,
+					fmt.Sprintf("ignored %s, not a Kubernetes manifest", strings.Replace(path, kg.root, ".", 1)))
+				return nil
+			}
+
 			if _, err := rf.SliceFromBytes(fContents); err != nil {
-				return fmt.Errorf("failed to decode Kubernetes YAML from %s: %w", path, err)
+				return decodeManifestError(strings.Replace(path, kg.root, ".", 1), fContents, err)
 			}
 			paths = append(paths, path)
 			return nil
@@ -226,6 +470,83 @@ func (kg *KustomizeGenerator) generateKustomization(dirPath string) error {
 	return os.WriteFile(kfile, kd, os.ModePerm)
 }
 
+// isPlainJSON returns true if the data is valid JSON, which doesn't
+// describe a Kubernetes object, e.g. a package.json or a tsconfig.json.
+func isPlainJSON(data []byte) bool {
+	var obj struct {
+		APIVersion string `json:"apiVersion"`
+		Kind       string `json:"kind"`
+	}
+	if !json.Valid(data) {
+		return false
+	}
+	if err := json.Unmarshal(data, &obj); err != nil {
+		// valid JSON which is not an object, e.g. an array
+		return true
+	}
+	return obj.APIVersion == "" || obj.Kind == ""
+}
+
+// manifestExtensions returns the file extensions of the Kubernetes manifests
+// to include in the generated kustomization.yaml.
+func (kg *KustomizeGenerator) manifestExtensions() []string {
+	opts := kg.kustomization.Spec.BuildOptions
+	if opts == nil || len(opts.ManifestExtensions) == 0 {
+		return []string{".yaml", ".yml", ".json"}
+	}
+
+	extensions := make([]string, 0, len(opts.ManifestExtensions))
+	for _, ext := range opts.ManifestExtensions {
+		if !strings.HasPrefix(ext, ".") {
+			ext = "." + ext
+		}
+		extensions = append(extensions, ext)
+	}
+	return extensions
+}
+
+// buildMetadata returns the build metadata options of the kustomization.yaml,
+// extended with the options set in spec.buildOptions.buildMetadata.
+func (kg *KustomizeGenerator) buildMetadata(options []string) ([]string, error) {
+	opts := kg.kustomization.Spec.BuildOptions
+	if opts == nil || len(opts.BuildMetadata) == 0 {
+		return options, nil
+	}
+
+	enabled := make(map[string]bool, len(options))
+	for _, option := range options {
+		enabled[option] = true
+	}
+
+	for _, option := range opts.BuildMetadata {
+		switch option {
+		case kustomizev1.OriginAnnotationsBuildMetadata,
+			kustomizev1.TransformerAnnotationsBuildMetadata,
+			kustomizev1.ManagedByLabelBuildMetadata:
+		default:
+			return nil, fmt.Errorf("invalid buildOptions.buildMetadata option '%s', must be one of '%s', '%s' or '%s'",
+				option, kustomizev1.OriginAnnotationsBuildMetadata,
+				kustomizev1.TransformerAnnotationsBuildMetadata, kustomizev1.ManagedByLabelBuildMetadata)
+		}
+		if !enabled[option] {
+			enabled[option] = true
+			options = append(options, option)
+		}
+	}
+	return options, nil
+}
+
+// adaptPatchOptions converts the patch options to the kustomize options map.
+func adaptPatchOptions(opts *kustomizev1.PatchOptions) map[string]bool {
+	if opts == nil || (!opts.AllowNameChange && !opts.AllowKindChange) {
+		return nil
+	}
+	return map[string]bool{
+		"allowNameChange": opts.AllowNameChange,
+		"allowKindChange": opts.AllowKindChange,
+	}
+}
+
 func adaptSelector(selector *kustomize.Selector) (output *kustypes.Selector) {
 	if selector != nil {
 		output = &kustypes.Selector{}
@@ -248,7 +569,8 @@ var kustomizeBuildMutex sync.Mutex
 //  - load files from outside the kustomization dir path
 //    (but not outside root)
 //  - disable plugins except for the builtin ones
-func secureBuildKustomization(root, dirPath string, allowRemoteBases bool) (_ resmap.ResMap, err error) {
+//  - enable the HelmChartInflationGenerator with the helm binary if helmCommand is set
+func secureBuildKustomization(root, dirPath string, allowRemoteBases bool, helmCommand string) (_ resmap.ResMap, err error) {
 	var fs filesys.FileSystem
 
 	// Create secure FS for root with or without remote base support
@@ -280,9 +602,20 @@ func secureBuildKustomization(root, dirPath string, allowRemoteBases bool) (_ re
 
 	buildOptions := &krusty.Options{
 		LoadRestrictions: kustypes.LoadRestrictionsNone,
-		PluginConfig:     kustypes.DisabledPluginConfig(),
+		PluginConfig:     buildPluginConfig(helmCommand),
 	}
 
 	k := krusty.MakeKustomizer(buildOptions)
 	return k.Run(fs, dirPath)
 }
+
+// buildPluginConfig returns the config of the builtin plugins, with the
+// helmCharts inflation enabled only when the helm command is set.
+func buildPluginConfig(helmCommand string) *kustypes.PluginConfig {
+	config := kustypes.DisabledPluginConfig()
+	if helmCommand != "" {
+		config.HelmConfig.Enabled = true
+		config.HelmConfig.Command = helmCommand
+	}
+	return config
+}
@@ -17,36 +17,58 @@ limitations under the License.
 package controllers
 
 import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
+	"github.com/fluxcd/pkg/apis/kustomize"
 	. "github.com/onsi/gomega"
+	"sigs.k8s.io/kustomize/api/konfig"
+	kustypes "sigs.k8s.io/kustomize/api/types"
+	"sigs.k8s.io/yaml"
+
+	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta2"
 )
 
 func Test_secureBuildKustomization(t *testing.T) {
 	t.Run("remote build", func(t *testing.T) {
 		g := NewWithT(t)
 
-		_, err := secureBuildKustomization("testdata/remote", "testdata/remote", true)
+		_, err := secureBuildKustomization("testdata/remote", "testdata/remote", true, "")
 		g.Expect(err).ToNot(HaveOccurred())
 	})
 
 	t.Run("no remote build", func(t *testing.T) {
 		g := NewWithT(t)
 
-		_, err := secureBuildKustomization("testdata/remote", "testdata/remote", false)
+		_, err := secureBuildKustomization("testdata/remote", "testdata/remote", false, "")
 		g.Expect(err).To(HaveOccurred())
 	})
 }
 
+func Test_buildPluginConfig(t *testing.T) {
+	g := NewWithT(t)
+
+	config := buildPluginConfig("")
+	g.Expect(config.HelmConfig.Enabled).To(BeFalse())
+
+	config = buildPluginConfig("/usr/local/bin/helm")
+	g.Expect(config.HelmConfig.Enabled).To(BeTrue())
+	g.Expect(config.HelmConfig.Command).To(Equal("/usr/local/bin/helm"))
+	g.Expect(config.PluginRestrictions).To(Equal(kustypes.DisabledPluginConfig().PluginRestrictions))
+}
+
 func Test_secureBuildKustomization_panic(t *testing.T) {
 	t.Run("build panic", func(t *testing.T) {
 		g := NewWithT(t)
 
-		_, err := secureBuildKustomization("testdata/panic", "testdata/panic", false)
+		_, err := secureBuildKustomization("testdata/panic", "testdata/panic", false, "")
 		g.Expect(err).To(HaveOccurred())
 		g.Expect(err.Error()).To(ContainSubstring("recovered from kustomize build panic"))
 		// Run again to ensure the lock is released
-		_, err = secureBuildKustomization("testdata/panic", "testdata/panic", false)
+		_, err = secureBuildKustomization("testdata/panic", "testdata/panic", false, "")
 		g.Expect(err).To(HaveOccurred())
 	})
 }
@@ -54,6 +76,353 @@ func Test_secureBuildKustomization_panic(t *testing.T) {
 func Test_secureBuildKustomization_rel_basedir(t *testing.T) {
 	g := NewWithT(t)
 
-	_, err := secureBuildKustomization("testdata/relbase", "testdata/relbase/clusters/staging/flux-system", false)
+	_, err := secureBuildKustomization("testdata/relbase", "testdata/relbase/clusters/staging/flux-system", false, "")
 	g.Expect(err).ToNot(HaveOccurred())
 }
+
+func Test_generateKustomization_manifestExtensions(t *testing.T) {
+	files := map[string]string{
+		"config.yaml":  "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: yaml\n",
+		"secret.json":  `{"apiVersion": "v1", "kind": "Secret", "metadata": {"name": "json"}}`,
+		"package.json": `{"name": "scripts", "version": "1.0.0"}`,
+		"notes.txt":    "not a manifest",
+	}
+
+	tests := []struct {
+		name       string
+		extensions []string
+		want       []string
+	}{
+		{
+			name: "defaults",
+			want: []string{"./config.yaml", "./secret.json"},
+		},
+		{
+			name:       "custom",
+			extensions: []string{"json"},
+			want:       []string{"./secret.json"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			dir := t.TempDir()
+			for name, body := range files {
+				g.Expect(os.WriteFile(filepath.Join(dir, name), []byte(body), 0o644)).To(Succeed())
+			}
+
+			kustomization := kustomizev1.Kustomization{}
+			if tt.extensions != nil {
+				kustomization.Spec.BuildOptions = &kustomizev1.BuildOptions{
+					ManifestExtensions: tt.extensions,
+				}
+			}
+
+			g.Expect(NewGenerator(dir, kustomization).generateKustomization(dir)).To(Succeed())
+
+			data, err := os.ReadFile(filepath.Join(dir, konfig.DefaultKustomizationFileName()))
+			g.Expect(err).NotTo(HaveOccurred())
+
+			var kus kustypes.Kustomization
+			g.Expect(yaml.Unmarshal(data, &kus)).To(Succeed())
+			g.Expect(kus.Resources).To(Equal(tt.want))
+		})
+	}
+}
+
+func Test_generateKustomization_invalidJSON(t *testing.T) {
+	g := NewWithT(t)
+
+	dir := t.TempDir()
+	g.Expect(os.WriteFile(filepath.Join(dir, "secret.json"),
+		[]byte(`{"apiVersion": "v1", "kind": "Secret", "metadata": {"name": "json"`), 0o644)).To(Succeed())
+
+	err := NewGenerator(dir, kustomizev1.Kustomization{}).generateKustomization(dir)
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(err.Error()).To(ContainSubstring("secret.json"))
+}
+
+func Test_isPlainJSON(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want bool
+	}{
+		{name: "package", data: `{"name": "scripts", "version": "1.0.0"}`, want: true},
+		{name: "array", data: `[{"apiVersion": "v1", "kind": "Secret"}]`, want: true},
+		{name: "manifest", data: `{"apiVersion": "v1", "kind": "Secret"}`, want: false},
+		{name: "invalid", data: `{"apiVersion": "v1",`, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			g.Expect(isPlainJSON([]byte(tt.data))).To(Equal(tt.want))
+		})
+	}
+}
+
+func Test_generateKustomization_requireKustomizationFile(t *testing.T) {
+	g := NewWithT(t)
+
+	dir := t.TempDir()
+	g.Expect(os.WriteFile(filepath.Join(dir, "scratch.yaml"),
+		[]byte("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: scratch\n"), 0o644)).To(Succeed())
+
+	kustomization := kustomizev1.Kustomization{}
+	kustomization.Spec.BuildOptions = &kustomizev1.BuildOptions{
+		RequireKustomizationFile: true,
+	}
+
+	err := NewGenerator(dir, kustomization).generateKustomization(dir)
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(err.Error()).To(ContainSubstring("requireKustomizationFile"))
+	g.Expect(filepath.Join(dir, konfig.DefaultKustomizationFileName())).NotTo(BeAnExistingFile())
+
+	g.Expect(os.WriteFile(filepath.Join(dir, konfig.DefaultKustomizationFileName()),
+		[]byte("resources:\n- scratch.yaml\n"), 0o644)).To(Succeed())
+	g.Expect(NewGenerator(dir, kustomization).generateKustomization(dir)).To(Succeed())
+}
+
+func TestKustomizeGenerator_ValidateFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr string
+	}{
+		{
+			name:    "valid",
+			content: "resources:\n- deployment.yaml\npatchesStrategicMerge:\n- patch.yaml\n",
+		},
+		{
+			name:    "misspelled field",
+			content: "resources:\n- deployment.yaml\npatchesStrategicmerge:\n- patch.yaml\n",
+			wantErr: `unknown field "patchesStrategicmerge"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			dir := t.TempDir()
+			g.Expect(os.WriteFile(filepath.Join(dir, konfig.DefaultKustomizationFileName()),
+				[]byte(tt.content), 0o644)).To(Succeed())
+
+			err := NewGenerator(dir, kustomizev1.Kustomization{}).ValidateFile(dir)
+			if tt.wantErr == "" {
+				g.Expect(err).NotTo(HaveOccurred())
+				return
+			}
+			g.Expect(err).To(HaveOccurred())
+			g.Expect(err.Error()).To(ContainSubstring(tt.wantErr))
+		})
+	}
+}
+
+func TestKustomizeGenerator_ValidateFile_bases(t *testing.T) {
+	g := NewWithT(t)
+
+	dir := t.TempDir()
+	for _, sub := range []string{"base", "component"} {
+		g.Expect(os.MkdirAll(filepath.Join(dir, sub), 0o755)).To(Succeed())
+	}
+	g.Expect(os.WriteFile(filepath.Join(dir, konfig.DefaultKustomizationFileName()),
+		[]byte("resources:\n- base\n- deployment.yaml\ncomponents:\n- component\n"), 0o644)).To(Succeed())
+	g.Expect(os.WriteFile(filepath.Join(dir, "base", konfig.DefaultKustomizationFileName()),
+		[]byte("resources:\n- ../base\n"), 0o644)).To(Succeed())
+	g.Expect(os.WriteFile(filepath.Join(dir, "component", konfig.DefaultKustomizationFileName()),
+		[]byte("apiVersion: kustomize.config.k8s.io/v1alpha1\nkind: Component\nimagez:\n- name: app\n"), 0o644)).To(Succeed())
+
+	err := NewGenerator(dir, kustomizev1.Kustomization{}).ValidateFile(dir)
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(err.Error()).To(ContainSubstring(filepath.Join("component", konfig.DefaultKustomizationFileName())))
+	g.Expect(err.Error()).To(ContainSubstring(`unknown field "imagez"`))
+}
+
+func TestKustomizeGenerator_Warnings(t *testing.T) {
+	g := NewWithT(t)
+
+	dir := t.TempDir()
+	g.Expect(os.WriteFile(filepath.Join(dir, konfig.DefaultKustomizationFileName()),
+		[]byte("bases:\n- base\ncommonLabels:\n  app: podinfo\nimages:\n- name: podinfo\n  newTag: 6.0.0\n"), 0o644)).To(Succeed())
+
+	kustomization := kustomizev1.Kustomization{}
+	kustomization.Spec.Images = []kustomize.Image{{Name: "podinfo", NewTag: "6.1.0"}}
+
+	gen := NewGenerator(dir, kustomization)
+	g.Expect(gen.ValidateFile(dir)).To(Succeed())
+	g.Expect(gen.WriteFile(dir)).To(Succeed())
+	g.Expect(gen.Warnings()).To(Equal([]string{
+		"deprecated field 'bases' in kustomization.yaml, use 'resources' instead",
+		"deprecated field 'commonLabels' in kustomization.yaml, use 'labels' instead",
+		"image 'podinfo' set in kustomization.yaml is overridden by spec.images",
+	}))
+
+	t.Run("ignored files", func(t *testing.T) {
+		g := NewWithT(t)
+
+		dir := t.TempDir()
+		g.Expect(os.WriteFile(filepath.Join(dir, "package.json"),
+			[]byte(`{"name": "scripts", "version": "1.0.0"}`), 0o644)).To(Succeed())
+
+		gen := NewGenerator(dir, kustomizev1.Kustomization{})
+		g.Expect(gen.WriteFile(dir)).To(Succeed())
+		g.Expect(gen.Warnings()).To(Equal([]string{"ignored ./package.json, not a Kubernetes manifest"}))
+	})
+}
+
+func TestKustomizeGenerator_WriteFile_patches(t *testing.T) {
+	g := NewWithT(t)
+
+	dir := t.TempDir()
+	manifests := `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: keep
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: remove
+`
+	g.Expect(os.WriteFile(filepath.Join(dir, "configmaps.yaml"), []byte(manifests), 0o644)).To(Succeed())
+
+	kustomization := kustomizev1.Kustomization{}
+	kustomization.Spec.Patches = []kustomize.Patch{
+		{
+			Patch: `
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: remove
+$patch: delete
+`,
+		},
+		{
+			Patch: `
+- op: replace
+  path: /metadata/name
+  value: renamed
+`,
+			Target: kustomize.Selector{
+				Kind: "ConfigMap",
+				Name: "keep",
+			},
+		},
+	}
+	kustomization.Spec.PatchOptions = &kustomizev1.PatchOptions{
+		AllowNameChange: true,
+	}
+
+	g.Expect(NewGenerator(dir, kustomization).WriteFile(dir)).To(Succeed())
+
+	resMap, err := secureBuildKustomization(dir, dir, false, "")
+	g.Expect(err).NotTo(HaveOccurred())
+
+	var names []string
+	for _, res := range resMap.Resources() {
+		names = append(names, res.GetName())
+	}
+	g.Expect(names).To(Equal([]string{"renamed"}))
+}
+
+func TestKustomizeGenerator_WriteFile_buildMetadata(t *testing.T) {
+	g := NewWithT(t)
+
+	dir := t.TempDir()
+	g.Expect(os.WriteFile(filepath.Join(dir, "configmap.yaml"),
+		[]byte("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: app\n"), 0o644)).To(Succeed())
+	g.Expect(os.WriteFile(filepath.Join(dir, konfig.DefaultKustomizationFileName()),
+		[]byte("resources:\n- configmap.yaml\nbuildMetadata:\n- managedByLabel\n"), 0o644)).To(Succeed())
+
+	kustomization := kustomizev1.Kustomization{}
+	kustomization.Spec.BuildOptions = &kustomizev1.BuildOptions{
+		BuildMetadata: []string{
+			kustomizev1.OriginAnnotationsBuildMetadata,
+			kustomizev1.ManagedByLabelBuildMetadata,
+		},
+	}
+	g.Expect(NewGenerator(dir, kustomization).WriteFile(dir)).To(Succeed())
+
+	resMap, err := secureBuildKustomization(dir, dir, false, "")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(resMap.Resources()).To(HaveLen(1))
+	res := resMap.Resources()[0]
+	g.Expect(res.GetAnnotations()).To(HaveKeyWithValue("config.kubernetes.io/origin", "path: configmap.yaml\n"))
+	g.Expect(res.GetLabels()).To(HaveKey("app.kubernetes.io/managed-by"))
+
+	kustomization.Spec.BuildOptions.BuildMetadata = []string{"commitAnnotations"}
+	err = NewGenerator(dir, kustomization).WriteFile(dir)
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(err.Error()).To(ContainSubstring("invalid buildOptions.buildMetadata option 'commitAnnotations'"))
+}
+
+func TestKustomizeGenerator_WriteFile_generators(t *testing.T) {
+	g := NewWithT(t)
+
+	dir := t.TempDir()
+	g.Expect(os.WriteFile(filepath.Join(dir, "app.env"), []byte("LOG_LEVEL=debug\n"), 0o644)).To(Succeed())
+	g.Expect(os.WriteFile(filepath.Join(dir, "tls.crt"), []byte("cert"), 0o644)).To(Succeed())
+	g.Expect(os.WriteFile(filepath.Join(dir, konfig.DefaultKustomizationFileName()),
+		[]byte("configMapGenerator:\n- name: app\n  literals:\n  - replicas=1\n"), 0o644)).To(Succeed())
+
+	kustomization := kustomizev1.Kustomization{}
+	kustomization.Spec.ConfigMapGenerator = []kustomizev1.GeneratorArgs{
+		{
+			Name:     "app",
+			Literals: []string{"replicas=2"},
+			Envs:     []string{"app.env"},
+			Options:  &kustomizev1.GeneratorOptions{DisableNameSuffixHash: true},
+		},
+	}
+	kustomization.Spec.SecretGenerator = []kustomizev1.SecretGeneratorArgs{
+		{
+			GeneratorArgs: kustomizev1.GeneratorArgs{
+				Name:  "tls",
+				Files: []string{"tls.crt"},
+			},
+			Type: "kubernetes.io/tls",
+		},
+	}
+	gen := NewGenerator(dir, kustomization)
+	g.Expect(gen.WriteFile(dir)).To(Succeed())
+	g.Expect(gen.Warnings()).To(Equal([]string{
+		"configMapGenerator 'app' set in kustomization.yaml is overridden by spec.configMapGenerator",
+	}))
+
+	resMap, err := secureBuildKustomization(dir, dir, false, "")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(resMap.Resources()).To(HaveLen(2))
+
+	cm := resMap.Resources()[0]
+	g.Expect(cm.GetName()).To(Equal("app"))
+	data := cm.GetDataMap()
+	g.Expect(data).To(HaveKeyWithValue("replicas", "2"))
+	g.Expect(data).To(HaveKeyWithValue("LOG_LEVEL", "debug"))
+
+	secret := resMap.Resources()[1]
+	g.Expect(secret.GetKind()).To(Equal("Secret"))
+	g.Expect(secret.GetName()).To(HavePrefix("tls-"))
+	g.Expect(secret.GetDataMap()).To(HaveKey("tls.crt"))
+}
+
+func Test_sandboxBuildKustomization(t *testing.T) {
+	g := NewWithT(t)
+
+	if err := CheckSandbox(); err != nil {
+		t.Skipf("the test binary can't be sandboxed: %v", err)
+	}
+
+	m, err := sandboxBuildKustomization(context.TODO(), "testdata/relbase", "testdata/relbase/clusters/staging/flux-system")
+	if err != nil && strings.Contains(err.Error(), "landlock is not available") {
+		t.Skipf("landlock is not supported by the kernel or the test binary: %v", err)
+	}
+	g.Expect(err).NotTo(HaveOccurred())
+
+	expected, err := secureBuildKustomization("testdata/relbase", "testdata/relbase/clusters/staging/flux-system", false, "")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(m.Size()).To(Equal(expected.Size()))
+}
@@ -0,0 +1,180 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/fluxcd/pkg/apis/meta"
+
+	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta2"
+)
+
+// DependencyGraphPath is the path of the dependency graph endpoint,
+// served on the metrics address.
+const DependencyGraphPath = "/dependencies"
+
+// DependencyGraph contains the Kustomizations and their dependsOn relationships.
+type DependencyGraph struct {
+	Nodes []DependencyNode `json:"nodes"`
+	Edges []DependencyEdge `json:"edges"`
+}
+
+// DependencyNode is a Kustomization of the dependency graph.
+type DependencyNode struct {
+	// ID is the '<namespace>/<name>' of the Kustomization.
+	ID string `json:"id"`
+
+	// Ready is the status of the Ready condition, 'Unknown' if the
+	// Kustomization has no Ready condition or is not found.
+	Ready metav1.ConditionStatus `json:"ready"`
+
+	// Reason is the reason of the Ready condition.
+	Reason string `json:"reason,omitempty"`
+
+	// Missing is true when the Kustomization is referenced in dependsOn
+	// but isn't found among the listed Kustomizations.
+	Missing bool `json:"missing,omitempty"`
+}
+
+// DependencyEdge links a Kustomization to one of its dependencies.
+type DependencyEdge struct {
+	// From is the ID of the dependent Kustomization.
+	From string `json:"from"`
+
+	// To is the ID of the Kustomization listed in dependsOn.
+	To string `json:"to"`
+}
+
+// NewDependencyGraph returns the dependency graph of the Kustomizations,
+// the nodes and edges are sorted by ID.
+func NewDependencyGraph(kustomizations []kustomizev1.Kustomization) DependencyGraph {
+	graph := DependencyGraph{Nodes: []DependencyNode{}, Edges: []DependencyEdge{}}
+
+	nodes := make(map[string]bool)
+	for _, k := range kustomizations {
+		node := DependencyNode{
+			ID:    fmt.Sprintf("%s/%s", k.GetNamespace(), k.GetName()),
+			Ready: metav1.ConditionUnknown,
+		}
+		if c := apimeta.FindStatusCondition(k.Status.Conditions, meta.ReadyCondition); c != nil {
+			node.Ready = c.Status
+			node.Reason = c.Reason
+		}
+		graph.Nodes = append(graph.Nodes, node)
+		nodes[node.ID] = true
+	}
+
+	for _, k := range kustomizations {
+		for _, dep := range k.Spec.DependsOn {
+			namespace := k.GetNamespace()
+			if dep.Namespace != "" {
+				namespace = dep.Namespace
+			}
+			edge := DependencyEdge{
+				From: fmt.Sprintf("%s/%s", k.GetNamespace(), k.GetName()),
+				To:   fmt.Sprintf("%s/%s", namespace, dep.Name),
+			}
+			graph.Edges = append(graph.Edges, edge)
+
+			if !nodes[edge.To] {
+				graph.Nodes = append(graph.Nodes, DependencyNode{
+					ID:      edge.To,
+					Ready:   metav1.ConditionUnknown,
+					Missing: true,
+				})
+				nodes[edge.To] = true
+			}
+		}
+	}
+
+	sort.Slice(graph.Nodes, func(i, j int) bool {
+		return graph.Nodes[i].ID < graph.Nodes[j].ID
+	})
+	sort.Slice(graph.Edges, func(i, j int) bool {
+		if graph.Edges[i].From != graph.Edges[j].From {
+			return graph.Edges[i].From < graph.Edges[j].From
+		}
+		return graph.Edges[i].To < graph.Edges[j].To
+	})
+	return graph
+}
+
+// DOT returns the graph in the Graphviz DOT format, the edges point from
+// the dependencies to their dependents in the order of the rollout.
+func (g DependencyGraph) DOT() string {
+	var sb strings.Builder
+	sb.WriteString("digraph kustomizations {\n")
+	for _, node := range g.Nodes {
+		color := "gray"
+		switch {
+		case node.Missing:
+			color = "orange"
+		case node.Ready == metav1.ConditionTrue:
+			color = "green"
+		case node.Ready == metav1.ConditionFalse:
+			color = "red"
+		}
+		fmt.Fprintf(&sb, "  %q [color=%s];\n", node.ID, color)
+	}
+	for _, edge := range g.Edges {
+		fmt.Fprintf(&sb, "  %q -> %q;\n", edge.To, edge.From)
+	}
+	sb.WriteString("}\n")
+	return sb.String()
+}
+
+// DependencyGraphHandler serves the dependency graph of the Kustomizations
+// in JSON, or in the DOT format with the 'format=dot' query parameter.
+func DependencyGraphHandler(reader client.Reader) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		var list kustomizev1.KustomizationList
+		var opts []client.ListOption
+		if namespace := r.URL.Query().Get("namespace"); namespace != "" {
+			opts = append(opts, client.InNamespace(namespace))
+		}
+		if err := reader.List(r.Context(), &list, opts...); err != nil {
+			http.Error(w, fmt.Sprintf("unable to list Kustomizations: %s", err), http.StatusInternalServerError)
+			return
+		}
+		graph := NewDependencyGraph(list.Items)
+
+		switch format := r.URL.Query().Get("format"); format {
+		case "dot":
+			w.Header().Set("Content-Type", "text/vnd.graphviz")
+			fmt.Fprint(w, graph.DOT())
+		case "", "json":
+			w.Header().Set("Content-Type", "application/json")
+			_ = json.NewEncoder(w).Encode(graph)
+		default:
+			http.Error(w, fmt.Sprintf("unsupported format '%s'", format), http.StatusBadRequest)
+		}
+	})
+}
@@ -0,0 +1,109 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/fluxcd/pkg/apis/meta"
+
+	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta2"
+)
+
+func testDependencyKustomizations() []kustomizev1.Kustomization {
+	return []kustomizev1.Kustomization{
+		{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "flux-system", Name: "infra"},
+			Status: kustomizev1.KustomizationStatus{
+				Conditions: []metav1.Condition{{Type: meta.ReadyCondition, Status: metav1.ConditionTrue, Reason: meta.ReconciliationSucceededReason}},
+			},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "apps", Name: "podinfo"},
+			Spec: kustomizev1.KustomizationSpec{
+				DependsOn: []meta.NamespacedObjectReference{
+					{Name: "infra", Namespace: "flux-system"},
+					{Name: "database"},
+				},
+			},
+			Status: kustomizev1.KustomizationStatus{
+				Conditions: []metav1.Condition{{Type: meta.ReadyCondition, Status: metav1.ConditionFalse, Reason: kustomizev1.DependencyNotReadyReason}},
+			},
+		},
+	}
+}
+
+func TestNewDependencyGraph(t *testing.T) {
+	g := NewWithT(t)
+
+	graph := NewDependencyGraph(testDependencyKustomizations())
+	g.Expect(graph.Nodes).To(Equal([]DependencyNode{
+		{ID: "apps/database", Ready: metav1.ConditionUnknown, Missing: true},
+		{ID: "apps/podinfo", Ready: metav1.ConditionFalse, Reason: kustomizev1.DependencyNotReadyReason},
+		{ID: "flux-system/infra", Ready: metav1.ConditionTrue, Reason: meta.ReconciliationSucceededReason},
+	}))
+	g.Expect(graph.Edges).To(Equal([]DependencyEdge{
+		{From: "apps/podinfo", To: "apps/database"},
+		{From: "apps/podinfo", To: "flux-system/infra"},
+	}))
+
+	g.Expect(graph.DOT()).To(Equal(`digraph kustomizations {
+  "apps/database" [color=orange];
+  "apps/podinfo" [color=red];
+  "flux-system/infra" [color=green];
+  "apps/database" -> "apps/podinfo";
+  "flux-system/infra" -> "apps/podinfo";
+}
+`))
+}
+
+func TestDependencyGraphHandler(t *testing.T) {
+	g := NewWithT(t)
+
+	scheme := runtime.NewScheme()
+	g.Expect(kustomizev1.AddToScheme(scheme)).To(Succeed())
+	builder := fake.NewClientBuilder().WithScheme(scheme)
+	for _, k := range testDependencyKustomizations() {
+		builder = builder.WithObjects(k.DeepCopy())
+	}
+	handler := DependencyGraphHandler(builder.Build())
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, DependencyGraphPath, nil))
+	g.Expect(rec.Code).To(Equal(http.StatusOK))
+	var graph DependencyGraph
+	g.Expect(json.Unmarshal(rec.Body.Bytes(), &graph)).To(Succeed())
+	g.Expect(graph.Nodes).To(HaveLen(3))
+	g.Expect(graph.Edges).To(HaveLen(2))
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, DependencyGraphPath+"?format=dot&namespace=flux-system", nil))
+	g.Expect(rec.Code).To(Equal(http.StatusOK))
+	g.Expect(rec.Body.String()).To(Equal("digraph kustomizations {\n  \"flux-system/infra\" [color=green];\n}\n"))
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, DependencyGraphPath+"?format=xml", nil))
+	g.Expect(rec.Code).To(Equal(http.StatusBadRequest))
+}
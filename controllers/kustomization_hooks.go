@@ -0,0 +1,428 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"sigs.k8s.io/cli-utils/pkg/object"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/fluxcd/pkg/ssa"
+
+	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta2"
+)
+
+// pruneHookPollInterval is the interval at which
+// the Jobs of the prune hooks are checked for completion.
+var pruneHookPollInterval = 2 * time.Second
+
+// pruneHookJobTTL is the number of seconds the finished Jobs
+// of the prune hooks are kept before being deleted by Kubernetes.
+const pruneHookJobTTL int32 = 3600
+
+// PruneHookClient posts the objects to the HTTP prune hooks from the controller pod,
+// restricted to the hosts allowed with --prune-hook-hosts.
+type PruneHookClient struct {
+	allowedHosts []string
+	httpClient   *http.Client
+}
+
+// NewPruneHookClient returns a PruneHookClient for the allowed hosts. A host
+// is either a hostname or IP address, a wildcard domain such as '*.example.com',
+// or '*' to allow any host.
+func NewPruneHookClient(allowedHosts []string) *PruneHookClient {
+	return &PruneHookClient{
+		allowedHosts: allowedHosts,
+		httpClient: &http.Client{
+			Transport: http.DefaultTransport.(*http.Transport).Clone(),
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+	}
+}
+
+// pruneHookHost returns the host of the URL of an HTTP prune hook,
+// which must be an absolute http or https URL.
+func pruneHookHost(hookURL string) (string, error) {
+	u, err := url.Parse(hookURL)
+	if err != nil {
+		return "", fmt.Errorf("invalid URL: %w", err)
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+		return "", fmt.Errorf("invalid URL, expected an http or https URL")
+	}
+	return u.Hostname(), nil
+}
+
+// pruneHookLabel is set on the Jobs of the prune hooks to the name of the hook.
+var pruneHookLabel = fmt.Sprintf("%s/prune-hook", kustomizev1.GroupVersion.Group)
+
+// pruneHookRequest is the payload posted to the HTTP prune hooks,
+// the object is reduced to its metadata by pruneHookObject.
+type pruneHookRequest struct {
+	Hook          string                     `json:"hook"`
+	Kustomization types.NamespacedName       `json:"kustomization"`
+	Revision      string                     `json:"revision"`
+	Object        *unstructured.Unstructured `json:"object"`
+}
+
+// runPruneHooks invokes the prune hooks of the Kustomization for the stale objects
+// that are about to be garbage collected. It returns the objects that can be deleted,
+// and the objects that must be kept in the inventory because a hook with the 'Fail'
+// policy failed for them, along with the errors of the failed hooks.
+func (r *KustomizationReconciler) runPruneHooks(ctx context.Context,
+	kubeClient client.Client,
+	manager *ssa.ResourceManager,
+	kustomization kustomizev1.Kustomization,
+	revision string,
+	objects []*unstructured.Unstructured) ([]*unstructured.Unstructured, []*unstructured.Unstructured, error) {
+	if kustomization.Spec.PruneOptions == nil || len(kustomization.Spec.PruneOptions.Hooks) == 0 ||
+		ownerLabelsDisabled(kustomization) {
+		return objects, nil, nil
+	}
+
+	owner := ownerLabels(manager, kustomization)
+	exclusions := map[string]string{
+		fmt.Sprintf("%s/prune", kustomizev1.GroupVersion.Group):     kustomizev1.DisabledValue,
+		fmt.Sprintf("%s/reconcile", kustomizev1.GroupVersion.Group): kustomizev1.DisabledValue,
+	}
+
+	var deletable, retained []*unstructured.Unstructured
+	var failures []string
+	for _, obj := range objects {
+		existing := &unstructured.Unstructured{}
+		existing.SetGroupVersionKind(obj.GroupVersionKind())
+		if err := kubeClient.Get(ctx, client.ObjectKeyFromObject(obj), existing); err != nil {
+			if apierrors.IsNotFound(err) {
+				deletable = append(deletable, obj)
+				continue
+			}
+			retained = append(retained, obj)
+			failures = append(failures, fmt.Sprintf("failed to get %s: %s", ssa.FmtUnstructured(obj), err))
+			continue
+		}
+
+		// skip the objects that are not deleted by garbage collection
+		if !hasLabels(existing.GetLabels(), owner) ||
+			hasAnyLabelOrAnnotation(existing.GetLabels(), existing.GetAnnotations(), exclusions) {
+			deletable = append(deletable, obj)
+			continue
+		}
+
+		failed := false
+		for _, hook := range kustomization.Spec.PruneOptions.Hooks {
+			match, err := selectorMatches(hook.Target, existing)
+			if err == nil && !match {
+				continue
+			}
+			if err == nil {
+				err = r.runPruneHook(ctx, kubeClient, kustomization, revision, hook, existing)
+			}
+			if err == nil {
+				continue
+			}
+
+			msg := fmt.Sprintf("prune hook '%s' failed for %s: %s", hook.Name, ssa.FmtUnstructured(obj), err)
+			if hook.FailurePolicy == kustomizev1.IgnorePruneHookPolicy {
+				ctrl.LoggerFrom(ctx).Info(msg)
+				continue
+			}
+			failures = append(failures, msg)
+			failed = true
+			break
+		}
+
+		if failed {
+			retained = append(retained, obj)
+		} else {
+			deletable = append(deletable, obj)
+		}
+	}
+
+	if len(failures) > 0 {
+		return deletable, retained, errors.New(strings.Join(failures, "\n"))
+	}
+	return deletable, nil, nil
+}
+
+// runPruneHook invokes the hook for the object and waits for its completion,
+// for at most the hook timeout.
+func (r *KustomizationReconciler) runPruneHook(ctx context.Context,
+	kubeClient client.Client,
+	kustomization kustomizev1.Kustomization,
+	revision string,
+	hook kustomizev1.PruneHook,
+	obj *unstructured.Unstructured) error {
+	timeout := kustomization.GetTimeout()
+	if hook.Timeout != nil {
+		timeout = hook.Timeout.Duration
+	}
+
+	switch {
+	case hook.HTTP != nil && hook.Job == nil:
+		return r.postPruneHook(ctx, kustomization, revision, hook, obj, timeout)
+	case hook.Job != nil && hook.HTTP == nil:
+		return r.runPruneHookJob(ctx, kubeClient, kustomization, hook, obj, timeout)
+	default:
+		return fmt.Errorf("exactly one of http or job must be specified")
+	}
+}
+
+// postPruneHook posts the object metadata to the HTTP endpoint of the hook,
+// any response status code other than 2xx is considered a failure. Redirects
+// are not followed and the response body is never read, only its status is
+// reported.
+func (r *KustomizationReconciler) postPruneHook(ctx context.Context,
+	kustomization kustomizev1.Kustomization,
+	revision string,
+	hook kustomizev1.PruneHook,
+	obj *unstructured.Unstructured,
+	timeout time.Duration) error {
+	if r.PruneHookClient == nil {
+		return fmt.Errorf("HTTP prune hooks are disabled, the controller must be started with --prune-hook-hosts")
+	}
+	host, err := pruneHookHost(hook.HTTP.URL)
+	if err != nil {
+		return err
+	}
+	if !hostAllowed(r.PruneHookClient.allowedHosts, host) {
+		return fmt.Errorf("the host '%s' is not allowed by --prune-hook-hosts", host)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	payload, err := json.Marshal(pruneHookRequest{
+		Hook: hook.Name,
+		Kustomization: types.NamespacedName{
+			Namespace: kustomization.GetNamespace(),
+			Name:      kustomization.GetName(),
+		},
+		Revision: revision,
+		Object:   pruneHookObject(obj),
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hook.HTTP.URL, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	if hook.HTTP.SecretRef != nil {
+		secretName := types.NamespacedName{
+			Namespace: kustomization.GetNamespace(),
+			Name:      hook.HTTP.SecretRef.Name,
+		}
+		var secret corev1.Secret
+		if err := r.Get(ctx, secretName, &secret); err != nil {
+			return fmt.Errorf("failed to get secret '%s': %w", secretName, err)
+		}
+		token, ok := secret.Data["token"]
+		if !ok {
+			return fmt.Errorf("'token' not found in secret '%s'", secretName)
+		}
+		req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+	}
+
+	resp, err := r.PruneHookClient.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected status code %s", resp.Status)
+	}
+	return nil
+}
+
+// runPruneHookJob runs the Job of the hook for the object and waits for it to complete.
+// The Job name is derived from the Kustomization and the object, so that a Job that
+// completed in a previous reconciliation isn't run again. Failed Jobs are deleted,
+// for the hook to be retried at the next reconciliation.
+func (r *KustomizationReconciler) runPruneHookJob(ctx context.Context,
+	kubeClient client.Client,
+	kustomization kustomizev1.Kustomization,
+	hook kustomizev1.PruneHook,
+	obj *unstructured.Unstructured,
+	timeout time.Duration) error {
+	job, err := newPruneHookJob(kustomization, hook, obj, r.DefaultServiceAccount, timeout)
+	if err != nil {
+		return err
+	}
+	jobName := client.ObjectKeyFromObject(job)
+
+	if err := kubeClient.Get(ctx, jobName, job); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return fmt.Errorf("failed to get Job '%s': %w", jobName, err)
+		}
+		if err := kubeClient.Create(ctx, job); err != nil {
+			return fmt.Errorf("failed to create Job '%s': %w", jobName, err)
+		}
+	}
+
+	var jobErr error
+	pollCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	err = wait.PollImmediateUntilWithContext(pollCtx, pruneHookPollInterval, func(ctx context.Context) (bool, error) {
+		if err := kubeClient.Get(ctx, jobName, job); err != nil {
+			return false, err
+		}
+		for _, c := range job.Status.Conditions {
+			if c.Status != corev1.ConditionTrue {
+				continue
+			}
+			switch c.Type {
+			case batchv1.JobComplete:
+				return true, nil
+			case batchv1.JobFailed:
+				jobErr = fmt.Errorf("the Job '%s' failed: %s", jobName, c.Message)
+				return true, nil
+			}
+		}
+		return false, nil
+	})
+	if err != nil {
+		if errors.Is(err, wait.ErrWaitTimeout) {
+			err = fmt.Errorf("timeout waiting for Job '%s' to complete", jobName)
+		}
+		jobErr = err
+	}
+
+	if jobErr != nil {
+		if err := kubeClient.Delete(ctx, job, client.PropagationPolicy(metav1.DeletePropagationBackground)); err != nil &&
+			!apierrors.IsNotFound(err) {
+			ctrl.LoggerFrom(ctx).Error(err, fmt.Sprintf("failed to delete Job '%s'", jobName))
+		}
+		return jobErr
+	}
+	return nil
+}
+
+// newPruneHookJob returns the Job that runs the hook container for the object,
+// in the namespace of the Kustomization.
+func newPruneHookJob(kustomization kustomizev1.Kustomization,
+	hook kustomizev1.PruneHook,
+	obj *unstructured.Unstructured,
+	defaultServiceAccount string,
+	timeout time.Duration) (*batchv1.Job, error) {
+	payload, err := json.Marshal(pruneHookObject(obj))
+	if err != nil {
+		return nil, err
+	}
+
+	id := object.UnstructuredToObjMetadata(obj).String()
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s/%s/%s", kustomization.GetNamespace(), kustomization.GetName(), id)))
+
+	serviceAccountName := kustomization.Spec.ServiceAccountName
+	if serviceAccountName == "" {
+		serviceAccountName = defaultServiceAccount
+	}
+
+	labels := map[string]string{pruneHookLabel: hook.Name}
+	backoffLimit := int32(0)
+	ttl := pruneHookJobTTL
+	deadline := int64(timeout.Seconds())
+	if deadline < 1 {
+		deadline = 1
+	}
+
+	return &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fmt.Sprintf("%s-%x", hook.Name, sum[:5]),
+			Namespace: kustomization.GetNamespace(),
+			Labels:    labels,
+			Annotations: map[string]string{
+				fmt.Sprintf("%s/object", kustomizev1.GroupVersion.Group): id,
+			},
+		},
+		Spec: batchv1.JobSpec{
+			BackoffLimit:            &backoffLimit,
+			ActiveDeadlineSeconds:   &deadline,
+			TTLSecondsAfterFinished: &ttl,
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: labels,
+				},
+				Spec: corev1.PodSpec{
+					RestartPolicy:      corev1.RestartPolicyNever,
+					ServiceAccountName: serviceAccountName,
+					Containers: []corev1.Container{
+						{
+							Name:    "hook",
+							Image:   hook.Job.Image,
+							Command: hook.Job.Command,
+							Args:    hook.Job.Args,
+							Env: []corev1.EnvVar{
+								{Name: "OBJECT", Value: string(payload)},
+								{Name: "OBJECT_API_VERSION", Value: obj.GetAPIVersion()},
+								{Name: "OBJECT_KIND", Value: obj.GetKind()},
+								{Name: "OBJECT_NAMESPACE", Value: obj.GetNamespace()},
+								{Name: "OBJECT_NAME", Value: obj.GetName()},
+							},
+						},
+					},
+				},
+			},
+		},
+	}, nil
+}
+
+// pruneHookObject returns the apiVersion, kind and metadata of the object passed
+// to the hooks. The rest of the object, e.g. the data of a Secret, is left out,
+// as are the managed fields and the last applied configuration which can hold it.
+func pruneHookObject(obj *unstructured.Unstructured) *unstructured.Unstructured {
+	u := &unstructured.Unstructured{}
+	u.SetAPIVersion(obj.GetAPIVersion())
+	u.SetKind(obj.GetKind())
+	u.SetNamespace(obj.GetNamespace())
+	u.SetName(obj.GetName())
+	u.SetUID(obj.GetUID())
+	u.SetLabels(obj.GetLabels())
+	if annotations := obj.GetAnnotations(); len(annotations) > 0 {
+		filtered := make(map[string]string, len(annotations))
+		for k, v := range annotations {
+			if k != corev1.LastAppliedConfigAnnotation {
+				filtered[k] = v
+			}
+		}
+		u.SetAnnotations(filtered)
+	}
+	return u
+}
@@ -0,0 +1,273 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/fluxcd/pkg/apis/kustomize"
+	"github.com/fluxcd/pkg/apis/meta"
+
+	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta2"
+)
+
+func TestKustomizationReconciler_runPruneHooks(t *testing.T) {
+	g := NewWithT(t)
+
+	var mu sync.Mutex
+	var notified []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer s3cr3t" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		var payload pruneHookRequest
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if _, ok := payload.Object.Object["data"]; ok {
+			http.Error(w, "unexpected object data", http.StatusBadRequest)
+			return
+		}
+		if _, ok := payload.Object.GetAnnotations()[corev1.LastAppliedConfigAnnotation]; ok {
+			http.Error(w, "unexpected last applied configuration", http.StatusBadRequest)
+			return
+		}
+		if payload.Object.GetName() == "fail" {
+			http.Error(w, "deregistration failed", http.StatusInternalServerError)
+			return
+		}
+		mu.Lock()
+		notified = append(notified, payload.Object.GetName())
+		mu.Unlock()
+	}))
+	defer server.Close()
+
+	owner := map[string]string{"owner": "test"}
+	configMap := func(name string, annotations map[string]string) *corev1.ConfigMap {
+		return &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        name,
+				Namespace:   "default",
+				Labels:      owner,
+				Annotations: annotations,
+			},
+		}
+	}
+
+	scheme := runtime.NewScheme()
+	g.Expect(corev1.AddToScheme(scheme)).To(Succeed())
+	kubeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+		configMap("dns", nil),
+		configMap("fail", nil),
+		configMap("excluded", map[string]string{"kustomize.toolkit.fluxcd.io/prune": "disabled"}),
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "hook-token", Namespace: "flux-system"},
+			Data:       map[string][]byte{"token": []byte("s3cr3t\n")},
+		},
+	).Build()
+
+	kustomization := kustomizev1.Kustomization{
+		ObjectMeta: metav1.ObjectMeta{Name: "apps", Namespace: "flux-system"},
+		Spec: kustomizev1.KustomizationSpec{
+			Prune:       true,
+			OwnerLabels: &kustomizev1.OwnerLabels{Labels: owner},
+			PruneOptions: &kustomizev1.PruneOptions{
+				Hooks: []kustomizev1.PruneHook{
+					{
+						Name:   "dns",
+						Target: kustomize.Selector{Kind: "ConfigMap", Name: "dns|fail|excluded"},
+						HTTP: &kustomizev1.PruneHookHTTP{
+							URL:       server.URL,
+							SecretRef: &meta.LocalObjectReference{Name: "hook-token"},
+						},
+						Timeout: &metav1.Duration{Duration: 5 * time.Second},
+					},
+				},
+			},
+		},
+	}
+
+	stale := func(name string) *unstructured.Unstructured {
+		u := &unstructured.Unstructured{}
+		u.SetAPIVersion("v1")
+		u.SetKind("ConfigMap")
+		u.SetNamespace("default")
+		u.SetName(name)
+		u.SetAnnotations(map[string]string{corev1.LastAppliedConfigAnnotation: `{"data":{"password":"s3cr3t"}}`})
+		g.Expect(unstructured.SetNestedStringMap(u.Object, map[string]string{"password": "s3cr3t"}, "data")).To(Succeed())
+		return u
+	}
+	objects := []*unstructured.Unstructured{stale("dns"), stale("fail"), stale("excluded"), stale("gone")}
+
+	// the HTTP prune hooks are disabled by default
+	r := &KustomizationReconciler{Client: kubeClient}
+	_, retained, err := r.runPruneHooks(context.TODO(), kubeClient, nil, kustomization, "main/1", objects)
+	g.Expect(err).To(MatchError(ContainSubstring("--prune-hook-hosts")))
+	g.Expect(retained).To(HaveLen(2))
+
+	// the host of the hook must be allowed
+	r.PruneHookClient = NewPruneHookClient([]string{"*.svc.cluster.local"})
+	_, _, err = r.runPruneHooks(context.TODO(), kubeClient, nil, kustomization, "main/1", objects)
+	g.Expect(err).To(MatchError(ContainSubstring("the host '127.0.0.1' is not allowed")))
+	g.Expect(notified).To(BeEmpty())
+
+	r.PruneHookClient = NewPruneHookClient([]string{"127.0.0.1"})
+	deletable, retained, err := r.runPruneHooks(context.TODO(), kubeClient, nil, kustomization, "main/1", objects)
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(err.Error()).To(ContainSubstring("prune hook 'dns' failed for ConfigMap/default/fail: unexpected status code 500 Internal Server Error"))
+	g.Expect(err.Error()).NotTo(ContainSubstring("deregistration failed"))
+	g.Expect(retained).To(ConsistOf(objects[1]))
+	g.Expect(deletable).To(ConsistOf(objects[0], objects[2], objects[3]))
+	g.Expect(notified).To(ConsistOf("dns"))
+
+	// failures are ignored with the 'Ignore' policy
+	kustomization.Spec.PruneOptions.Hooks[0].FailurePolicy = kustomizev1.IgnorePruneHookPolicy
+	deletable, retained, err = r.runPruneHooks(context.TODO(), kubeClient, nil, kustomization, "main/1", objects)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(retained).To(BeEmpty())
+	g.Expect(deletable).To(HaveLen(4))
+}
+
+func TestKustomizationReconciler_postPruneHook(t *testing.T) {
+	g := NewWithT(t)
+
+	redirected := false
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		redirected = true
+	}))
+	defer target.Close()
+	server := httptest.NewServer(http.RedirectHandler(target.URL, http.StatusFound))
+	defer server.Close()
+
+	r := &KustomizationReconciler{PruneHookClient: NewPruneHookClient([]string{"*"})}
+	kustomization := kustomizev1.Kustomization{ObjectMeta: metav1.ObjectMeta{Name: "apps", Namespace: "flux-system"}}
+	obj := &unstructured.Unstructured{}
+	obj.SetAPIVersion("v1")
+	obj.SetKind("ConfigMap")
+	obj.SetName("dns")
+
+	// redirects are not followed
+	hook := kustomizev1.PruneHook{Name: "dns", HTTP: &kustomizev1.PruneHookHTTP{URL: server.URL}}
+	err := r.postPruneHook(context.TODO(), kustomization, "main/1", hook, obj, 5*time.Second)
+	g.Expect(err).To(MatchError("unexpected status code 302 Found"))
+	g.Expect(redirected).To(BeFalse())
+
+	// only http and https URLs are allowed
+	hook.HTTP.URL = "file:///var/run/secrets/kubernetes.io/serviceaccount/token"
+	err = r.postPruneHook(context.TODO(), kustomization, "main/1", hook, obj, 5*time.Second)
+	g.Expect(err).To(MatchError(ContainSubstring("expected an http or https URL")))
+}
+
+func TestKustomizationReconciler_runPruneHookJob(t *testing.T) {
+	g := NewWithT(t)
+
+	pruneHookPollInterval = 10 * time.Millisecond
+	defer func() {
+		pruneHookPollInterval = 2 * time.Second
+	}()
+
+	scheme := runtime.NewScheme()
+	g.Expect(corev1.AddToScheme(scheme)).To(Succeed())
+	g.Expect(batchv1.AddToScheme(scheme)).To(Succeed())
+
+	kustomization := kustomizev1.Kustomization{
+		ObjectMeta: metav1.ObjectMeta{Name: "apps", Namespace: "flux-system"},
+		Spec: kustomizev1.KustomizationSpec{
+			ServiceAccountName: "hooks",
+		},
+	}
+	hook := kustomizev1.PruneHook{
+		Name: "drain",
+		Job: &kustomizev1.PruneHookJob{
+			Image:   "ghcr.io/org/drain:v1",
+			Command: []string{"/drain"},
+		},
+	}
+	obj := &unstructured.Unstructured{}
+	obj.SetAPIVersion("example.com/v1")
+	obj.SetKind("NodePool")
+	obj.SetName("pool-a")
+	obj.Object["spec"] = map[string]interface{}{"nodes": int64(3)}
+
+	job, err := newPruneHookJob(kustomization, hook, obj, "", time.Minute)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(job.GetNamespace()).To(Equal("flux-system"))
+	g.Expect(job.GetName()).To(HavePrefix("drain-"))
+	g.Expect(job.GetName()).To(HaveLen(len("drain-") + 10))
+	g.Expect(*job.Spec.ActiveDeadlineSeconds).To(BeEquivalentTo(60))
+	g.Expect(job.Spec.Template.Spec.ServiceAccountName).To(Equal("hooks"))
+	g.Expect(job.Spec.Template.Spec.Containers[0].Env).To(ContainElement(
+		corev1.EnvVar{Name: "OBJECT_KIND", Value: "NodePool"}))
+	g.Expect(job.Spec.Template.Spec.Containers[0].Env).To(ContainElement(corev1.EnvVar{
+		Name:  "OBJECT",
+		Value: `{"apiVersion":"example.com/v1","kind":"NodePool","metadata":{"name":"pool-a"}}`,
+	}))
+
+	finished := func(condition batchv1.JobConditionType) *batchv1.Job {
+		j := job.DeepCopy()
+		j.Status.Conditions = []batchv1.JobCondition{
+			{Type: condition, Status: corev1.ConditionTrue, Message: "BackoffLimitExceeded"},
+		}
+		return j
+	}
+
+	t.Run("completed Job", func(t *testing.T) {
+		g := NewWithT(t)
+		kubeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(finished(batchv1.JobComplete)).Build()
+		r := &KustomizationReconciler{Client: kubeClient}
+		g.Expect(r.runPruneHookJob(context.TODO(), kubeClient, kustomization, hook, obj, time.Second)).To(Succeed())
+	})
+
+	t.Run("failed Job is deleted", func(t *testing.T) {
+		g := NewWithT(t)
+		kubeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(finished(batchv1.JobFailed)).Build()
+		r := &KustomizationReconciler{Client: kubeClient}
+		err := r.runPruneHookJob(context.TODO(), kubeClient, kustomization, hook, obj, time.Second)
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(err.Error()).To(ContainSubstring("failed: BackoffLimitExceeded"))
+
+		err = kubeClient.Get(context.TODO(), client.ObjectKeyFromObject(job), &batchv1.Job{})
+		g.Expect(apierrors.IsNotFound(err)).To(BeTrue())
+	})
+
+	t.Run("Job is created and times out", func(t *testing.T) {
+		g := NewWithT(t)
+		kubeClient := fake.NewClientBuilder().WithScheme(scheme).Build()
+		r := &KustomizationReconciler{Client: kubeClient}
+		err := r.runPruneHookJob(context.TODO(), kubeClient, kustomization, hook, obj, 100*time.Millisecond)
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(err.Error()).To(ContainSubstring("timeout waiting for Job"))
+	})
+}
@@ -0,0 +1,177 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/fluxcd/pkg/apis/kustomize"
+	"github.com/fluxcd/pkg/ssa"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta2"
+)
+
+// ImageChangedEventReason is the reason of the events recording the
+// workload images changed by the spec.images overrides.
+const ImageChangedEventReason = "ImageChanged"
+
+// imageChange holds the previous and the applied image of a container.
+type imageChange struct {
+	Object    string
+	Container string
+	OldImage  string
+	NewImage  string
+}
+
+// overriddenWorkloads returns the objects that have at least one container
+// running an image set by the given overrides.
+func overriddenWorkloads(images []kustomize.Image, objects []*unstructured.Unstructured) []*unstructured.Unstructured {
+	overridden := make(map[string]bool)
+	for _, image := range images {
+		if image.NewName != "" {
+			overridden[image.NewName] = true
+		} else {
+			overridden[image.Name] = true
+		}
+	}
+
+	var result []*unstructured.Unstructured
+	for _, object := range objects {
+		for _, image := range namedContainerImages(object.Object["spec"]) {
+			if name, _, _ := parseImageReference(image); overridden[name] {
+				result = append(result, object)
+				break
+			}
+		}
+	}
+	return result
+}
+
+// liveImages returns the container images of the in-cluster version of the
+// given objects, indexed by object and container name. The objects that are
+// not yet created are left out.
+func liveImages(ctx context.Context, kubeClient client.Client,
+	objects []*unstructured.Unstructured) (map[string]map[string]string, error) {
+	result := make(map[string]map[string]string)
+	for _, object := range objects {
+		existing := &unstructured.Unstructured{}
+		existing.SetGroupVersionKind(object.GroupVersionKind())
+		if err := kubeClient.Get(ctx, client.ObjectKeyFromObject(object), existing); err != nil {
+			if apierrors.IsNotFound(err) {
+				continue
+			}
+			return nil, fmt.Errorf("failed to get %s: %w", ssa.FmtUnstructured(object), err)
+		}
+		result[ssa.FmtUnstructured(object)] = namedContainerImages(existing.Object["spec"])
+	}
+	return result, nil
+}
+
+// imageChanges returns the containers of the objects configured by the apply
+// whose image differs from the one recorded in the live images.
+func imageChanges(live map[string]map[string]string, objects []*unstructured.Unstructured,
+	changeSet *ssa.ChangeSet) []imageChange {
+	configured := make(map[string]bool)
+	if changeSet != nil {
+		for _, entry := range changeSet.Entries {
+			if entry.Action == string(ssa.ConfiguredAction) {
+				configured[entry.Subject] = true
+			}
+		}
+	}
+
+	var changes []imageChange
+	for _, object := range objects {
+		id := ssa.FmtUnstructured(object)
+		previous, ok := live[id]
+		if !ok || !configured[id] {
+			continue
+		}
+		for container, image := range namedContainerImages(object.Object["spec"]) {
+			if old, ok := previous[container]; ok && old != image {
+				changes = append(changes, imageChange{
+					Object:    id,
+					Container: container,
+					OldImage:  old,
+					NewImage:  image,
+				})
+			}
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		if changes[i].Object != changes[j].Object {
+			return changes[i].Object < changes[j].Object
+		}
+		return changes[i].Container < changes[j].Container
+	})
+	return changes
+}
+
+// namedContainerImages walks the given field and returns the images of the
+// containers found at any depth, indexed by container name.
+func namedContainerImages(field interface{}) map[string]string {
+	images := make(map[string]string)
+	switch v := field.(type) {
+	case map[string]interface{}:
+		for key, value := range v {
+			containers, ok := value.([]interface{})
+			if !containerFields[key] || !ok {
+				for name, image := range namedContainerImages(value) {
+					images[name] = image
+				}
+				continue
+			}
+			for _, c := range containers {
+				if container, ok := c.(map[string]interface{}); ok {
+					name, _ := container["name"].(string)
+					if image, ok := container["image"].(string); ok && name != "" && image != "" {
+						images[name] = image
+					}
+				}
+			}
+		}
+	case []interface{}:
+		for _, value := range v {
+			for name, image := range namedContainerImages(value) {
+				images[name] = image
+			}
+		}
+	}
+	return images
+}
+
+// imageChangedEvent records an event with the previous and the applied image
+// of a container, the references are set as event metadata for the
+// deployment tracking systems.
+func (r *KustomizationReconciler) imageChangedEvent(kustomization kustomizev1.Kustomization, revision string, change imageChange) {
+	metadata := map[string]string{
+		kustomizev1.GroupVersion.Group + "/revision":  revision,
+		kustomizev1.GroupVersion.Group + "/object":    change.Object,
+		kustomizev1.GroupVersion.Group + "/container": change.Container,
+		kustomizev1.GroupVersion.Group + "/old-image": change.OldImage,
+		kustomizev1.GroupVersion.Group + "/new-image": change.NewImage,
+	}
+	r.EventRecorder.AnnotatedEventf(&kustomization, metadata, "Normal", ImageChangedEventReason,
+		"%s container '%s' image changed from '%s' to '%s'",
+		change.Object, change.Container, change.OldImage, change.NewImage)
+}
@@ -0,0 +1,143 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/fluxcd/pkg/apis/kustomize"
+	"github.com/fluxcd/pkg/ssa"
+	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta2"
+)
+
+func Test_imageChanges(t *testing.T) {
+	g := NewWithT(t)
+
+	manifests := `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: podinfo
+  namespace: apps
+spec:
+  template:
+    spec:
+      initContainers:
+      - name: init
+        image: busybox:1.35
+      containers:
+      - name: podinfo
+        image: ghcr.io/stefanprodan/podinfo:6.2.0
+      - name: sidecar
+        image: envoyproxy/envoy:v1.23.0
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: new
+  namespace: apps
+spec:
+  template:
+    spec:
+      containers:
+      - name: podinfo
+        image: ghcr.io/stefanprodan/podinfo:6.2.0
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: redis
+  namespace: apps
+spec:
+  template:
+    spec:
+      containers:
+      - name: redis
+        image: redis:7.0
+`
+	objects, err := ssa.ReadObjects(strings.NewReader(manifests))
+	g.Expect(err).NotTo(HaveOccurred())
+
+	images := []kustomize.Image{
+		{Name: "podinfo", NewName: "ghcr.io/stefanprodan/podinfo", NewTag: "6.2.0"},
+		{Name: "busybox", NewTag: "1.35"},
+	}
+	workloads := overriddenWorkloads(images, objects)
+	g.Expect(workloads).To(HaveLen(2))
+	g.Expect(workloads[0].GetName()).To(Equal("podinfo"))
+	g.Expect(workloads[1].GetName()).To(Equal("new"))
+
+	scheme := runtime.NewScheme()
+	g.Expect(appsv1.AddToScheme(scheme)).To(Succeed())
+	kubeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(&appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "apps", Name: "podinfo"},
+		Spec: appsv1.DeploymentSpec{
+			Template: corev1.PodTemplateSpec{
+				Spec: corev1.PodSpec{
+					InitContainers: []corev1.Container{{Name: "init", Image: "busybox:1.35"}},
+					Containers: []corev1.Container{
+						{Name: "podinfo", Image: "ghcr.io/stefanprodan/podinfo:6.1.8"},
+						{Name: "sidecar", Image: "envoyproxy/envoy:v1.22.0"},
+					},
+				},
+			},
+		},
+	}).Build()
+
+	live, err := liveImages(context.TODO(), kubeClient, workloads)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(live).To(Equal(map[string]map[string]string{
+		"Deployment/apps/podinfo": {
+			"init":    "busybox:1.35",
+			"podinfo": "ghcr.io/stefanprodan/podinfo:6.1.8",
+			"sidecar": "envoyproxy/envoy:v1.22.0",
+		},
+	}))
+
+	changeSet := ssa.NewChangeSet()
+	changeSet.Add(ssa.ChangeSetEntry{Subject: "Deployment/apps/podinfo", Action: string(ssa.ConfiguredAction)})
+	changeSet.Add(ssa.ChangeSetEntry{Subject: "Deployment/apps/new", Action: string(ssa.CreatedAction)})
+
+	g.Expect(imageChanges(live, workloads, changeSet)).To(Equal([]imageChange{
+		{
+			Object:    "Deployment/apps/podinfo",
+			Container: "podinfo",
+			OldImage:  "ghcr.io/stefanprodan/podinfo:6.1.8",
+			NewImage:  "ghcr.io/stefanprodan/podinfo:6.2.0",
+		},
+		{
+			Object:    "Deployment/apps/podinfo",
+			Container: "sidecar",
+			OldImage:  "envoyproxy/envoy:v1.22.0",
+			NewImage:  "envoyproxy/envoy:v1.23.0",
+		},
+	}))
+
+	// the objects left unchanged by the apply are skipped
+	unchanged := ssa.NewChangeSet()
+	unchanged.Add(ssa.ChangeSetEntry{Subject: "Deployment/apps/podinfo", Action: string(ssa.UnchangedAction)})
+	g.Expect(imageChanges(live, workloads, unchanged)).To(BeEmpty())
+}
@@ -0,0 +1,138 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/fluxcd/pkg/apis/kustomize"
+	"github.com/fluxcd/pkg/runtime/acl"
+
+	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta2"
+)
+
+// imagePolicyGVK is the group version kind of the image-reflector-controller ImagePolicy.
+var imagePolicyGVK = schema.GroupVersionKind{
+	Group:   "image.toolkit.fluxcd.io",
+	Version: "v1beta1",
+	Kind:    "ImagePolicy",
+}
+
+// resolveImages returns a copy of the Kustomization with the images of
+// spec.imagePolicies set to the latest image of their policy in spec.images.
+func (r *KustomizationReconciler) resolveImages(ctx context.Context, kustomization kustomizev1.Kustomization) (kustomizev1.Kustomization, error) {
+	resolved := *kustomization.DeepCopy()
+
+	for _, image := range kustomization.Spec.ImagePolicies {
+		latestImage, err := r.getLatestImage(ctx, kustomization, image.FromImagePolicy.Namespace, image.FromImagePolicy.Name)
+		if err != nil {
+			return kustomization, err
+		}
+
+		name, tag, digest := parseImageReference(latestImage)
+		override := kustomize.Image{Name: image.Name, NewName: name, NewTag: tag, Digest: digest}
+		if exists, index := checkImageExists(resolved.Spec.Images, image.Name); exists {
+			resolved.Spec.Images[index] = override
+		} else {
+			resolved.Spec.Images = append(resolved.Spec.Images, override)
+		}
+	}
+
+	return resolved, nil
+}
+
+// checkImageExists returns true and the index of the image with the given name.
+func checkImageExists(images []kustomize.Image, name string) (bool, int) {
+	for i, image := range images {
+		if image.Name == name {
+			return true, i
+		}
+	}
+	return false, -1
+}
+
+// getLatestImage returns the latest image recorded in the status of the ImagePolicy.
+func (r *KustomizationReconciler) getLatestImage(ctx context.Context, kustomization kustomizev1.Kustomization, namespace, name string) (string, error) {
+	if namespace == "" {
+		namespace = kustomization.GetNamespace()
+	}
+	namespacedName := types.NamespacedName{Namespace: namespace, Name: name}
+
+	if r.NoCrossNamespaceRefs && namespace != kustomization.GetNamespace() {
+		return "", acl.AccessDeniedError(
+			fmt.Sprintf("can't access '%s/%s', cross-namespace references have been blocked",
+				imagePolicyGVK.Kind, namespacedName))
+	}
+
+	policy := &unstructured.Unstructured{}
+	policy.SetGroupVersionKind(imagePolicyGVK)
+	if err := r.Client.Get(ctx, namespacedName, policy); err != nil {
+		return "", fmt.Errorf("unable to get %s '%s': %w", imagePolicyGVK.Kind, namespacedName, err)
+	}
+
+	latestImage, _, err := unstructured.NestedString(policy.Object, "status", "latestImage")
+	if err != nil {
+		return "", err
+	}
+	if latestImage == "" {
+		return "", fmt.Errorf("%s '%s' has not resolved an image yet", imagePolicyGVK.Kind, namespacedName)
+	}
+
+	return latestImage, nil
+}
+
+// parseImageReference splits an image reference in the form of
+// 'name[:tag][@digest]' into its components.
+func parseImageReference(ref string) (name, tag, digest string) {
+	name = ref
+	if i := strings.Index(name, "@"); i != -1 {
+		name, digest = name[:i], name[i+1:]
+	}
+	// The tag separator must come after the last path component,
+	// as the registry host may contain a port number.
+	if i := strings.LastIndex(name, ":"); i > strings.LastIndex(name, "/") {
+		name, tag = name[:i], name[i+1:]
+	}
+	return name, tag, digest
+}
+
+// unmatchedImages returns the names of the images that don't match
+// any container image of the objects.
+func unmatchedImages(images []kustomize.Image, objects []*unstructured.Unstructured) []string {
+	rendered := make(map[string]bool)
+	for _, object := range objects {
+		for _, image := range containerImages(object.Object["spec"]) {
+			name, _, _ := parseImageReference(image)
+			rendered[name] = true
+		}
+	}
+
+	var unmatched []string
+	for _, image := range images {
+		if rendered[image.Name] || (image.NewName != "" && rendered[image.NewName]) {
+			continue
+		}
+		unmatched = append(unmatched, image.Name)
+	}
+	return unmatched
+}
@@ -0,0 +1,98 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"strings"
+	"testing"
+
+	. "github.com/onsi/gomega"
+
+	"github.com/fluxcd/pkg/apis/kustomize"
+	"github.com/fluxcd/pkg/ssa"
+
+	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta2"
+)
+
+func Test_parseImageReference(t *testing.T) {
+	tests := []struct {
+		ref    string
+		name   string
+		tag    string
+		digest string
+	}{
+		{
+			ref:  "ghcr.io/stefanprodan/podinfo:6.2.0",
+			name: "ghcr.io/stefanprodan/podinfo",
+			tag:  "6.2.0",
+		},
+		{
+			ref:  "localhost:5000/podinfo:6.2.0",
+			name: "localhost:5000/podinfo",
+			tag:  "6.2.0",
+		},
+		{
+			ref:  "localhost:5000/podinfo",
+			name: "localhost:5000/podinfo",
+		},
+		{
+			ref:    "ghcr.io/stefanprodan/podinfo:6.2.0@sha256:2832f53c577d44753e97b0ed5f00e7e3a06979c9fab77d0e78bdac4b612b14fb",
+			name:   "ghcr.io/stefanprodan/podinfo",
+			tag:    "6.2.0",
+			digest: "sha256:2832f53c577d44753e97b0ed5f00e7e3a06979c9fab77d0e78bdac4b612b14fb",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.ref, func(t *testing.T) {
+			g := NewWithT(t)
+
+			name, tag, digest := parseImageReference(tt.ref)
+			g.Expect(name).To(Equal(tt.name))
+			g.Expect(tag).To(Equal(tt.tag))
+			g.Expect(digest).To(Equal(tt.digest))
+		})
+	}
+}
+
+func Test_unmatchedImages(t *testing.T) {
+	g := NewWithT(t)
+
+	manifests := `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: podinfo
+spec:
+  template:
+    spec:
+      containers:
+      - name: podinfo
+        image: ghcr.io/stefanprodan/podinfo:6.2.0
+      - name: redis
+        image: my-registry/redis:7.0
+`
+	objects, err := ssa.ReadObjects(strings.NewReader(manifests))
+	g.Expect(err).NotTo(HaveOccurred())
+
+	images := []kustomize.Image{
+		{Name: "ghcr.io/stefanprodan/podinfo", NewTag: "6.2.0"},
+		{Name: "redis", NewName: "my-registry/redis"},
+		{Name: "ghcr.io/stefanprodan/podinfoo", NewTag: "6.2.0"},
+	}
+	g.Expect(unmatchedImages(images, objects)).To(Equal([]string{"ghcr.io/stefanprodan/podinfoo"}))
+}
@@ -23,6 +23,8 @@ import (
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/discovery"
+	corev1client "k8s.io/client-go/kubernetes/typed/core/v1"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
 	"sigs.k8s.io/cli-utils/pkg/kstatus/polling"
@@ -43,6 +45,10 @@ type KustomizeImpersonation struct {
 	defaultServiceAccount string
 	pollingOpts           polling.Options
 	kubeConfigOpts        runtimeClient.KubeConfigOptions
+
+	// serviceAccounts requests the tokens of spec.kubeConfig.serviceAccountToken,
+	// defaults to a client of the local cluster.
+	serviceAccounts corev1client.ServiceAccountsGetter
 }
 
 // NewKustomizeImpersonation creates a new KustomizeImpersonation.
@@ -79,6 +85,67 @@ func (ki *KustomizeImpersonation) GetClient(ctx context.Context) (client.Client,
 	}
 }
 
+// GetDiscoveryClient creates a discovery client for the API server targeted by GetClient.
+func (ki *KustomizeImpersonation) GetDiscoveryClient(ctx context.Context) (discovery.DiscoveryInterface, error) {
+	restConfig, err := ki.getRESTConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return discovery.NewDiscoveryClientForConfig(restConfig)
+}
+
+// GetCoreClient creates a core/v1 client for the API server targeted by GetClient,
+// used for the subresources the controller-runtime client doesn't support, e.g. the Pod logs.
+func (ki *KustomizeImpersonation) GetCoreClient(ctx context.Context) (corev1client.CoreV1Interface, error) {
+	restConfig, err := ki.getRESTConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return corev1client.NewForConfig(restConfig)
+}
+
+// GetClusterFingerprint returns the fingerprint of the remote cluster targeted by
+// spec.kubeConfig, or an empty string if the Kustomization targets the local cluster.
+func (ki *KustomizeImpersonation) GetClusterFingerprint(ctx context.Context) (string, error) {
+	if ki.kustomization.Spec.KubeConfig == nil {
+		return "", nil
+	}
+	kubeConfigBytes, err := ki.getKubeConfig(ctx)
+	if err != nil {
+		return "", err
+	}
+	return clusterFingerprint(kubeConfigBytes)
+}
+
+// getRESTConfig returns the REST config of the API server targeted by GetClient,
+// with the impersonation settings of the Kustomization.
+func (ki *KustomizeImpersonation) getRESTConfig(ctx context.Context) (*rest.Config, error) {
+	var restConfig *rest.Config
+	if ki.kustomization.Spec.KubeConfig != nil {
+		kubeConfigBytes, err := ki.getKubeConfig(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		restConfig, err = clientcmd.RESTConfigFromKubeConfig(kubeConfigBytes)
+		if err != nil {
+			return nil, err
+		}
+		restConfig = runtimeClient.KubeConfig(restConfig, ki.kubeConfigOpts)
+		if err := ki.configureKubeConfigProxy(ctx, restConfig); err != nil {
+			return nil, err
+		}
+	} else {
+		var err error
+		restConfig, err = config.GetConfig()
+		if err != nil {
+			return nil, err
+		}
+	}
+	ki.setImpersonationConfig(restConfig)
+	return restConfig, nil
+}
+
 // CanFinalize asserts if the given Kustomization can be finalized using impersonation.
 func (ki *KustomizeImpersonation) CanFinalize(ctx context.Context) bool {
 	name := ki.defaultServiceAccount
@@ -151,6 +218,9 @@ func (ki *KustomizeImpersonation) clientForKubeConfig(ctx context.Context) (clie
 	}
 
 	restConfig = runtimeClient.KubeConfig(restConfig, ki.kubeConfigOpts)
+	if err := ki.configureKubeConfigProxy(ctx, restConfig); err != nil {
+		return nil, nil, err
+	}
 	ki.setImpersonationConfig(restConfig)
 
 	restMapper, err := apiutil.NewDynamicRESTMapper(restConfig)
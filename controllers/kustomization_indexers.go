@@ -20,10 +20,12 @@ import (
 	"context"
 	"fmt"
 
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta2"
+	"github.com/fluxcd/pkg/apis/meta"
 	"github.com/fluxcd/pkg/runtime/dependency"
 	sourcev1 "github.com/fluxcd/source-controller/api/v1beta2"
 )
@@ -77,14 +79,98 @@ func (r *KustomizationReconciler) indexBy(kind string) func(o client.Object) []s
 			panic(fmt.Sprintf("Expected a Kustomization, got %T", o))
 		}
 
-		if k.Spec.SourceRef.Kind == kind {
-			namespace := k.GetNamespace()
-			if k.Spec.SourceRef.Namespace != "" {
-				namespace = k.Spec.SourceRef.Namespace
+		var keys []string
+		refs := []kustomizev1.CrossNamespaceSourceReference{k.Spec.SourceRef}
+		for _, src := range k.Spec.Sources {
+			refs = append(refs, src.SourceRef)
+		}
+		for _, ref := range refs {
+			if ref.Kind == kind {
+				namespace := k.GetNamespace()
+				if ref.Namespace != "" {
+					namespace = ref.Namespace
+				}
+				keys = append(keys, fmt.Sprintf("%s/%s", namespace, ref.Name))
+			}
+		}
+
+		return keys
+	}
+}
+
+func (r *KustomizationReconciler) requestsForImagePolicyChange(indexKey string) func(obj client.Object) []reconcile.Request {
+	return func(obj client.Object) []reconcile.Request {
+		ctx := context.Background()
+		var list kustomizev1.KustomizationList
+		if err := r.List(ctx, &list, client.MatchingFields{
+			indexKey: client.ObjectKeyFromObject(obj).String(),
+		}); err != nil {
+			return nil
+		}
+		reqs := make([]reconcile.Request, len(list.Items))
+		for i := range list.Items {
+			reqs[i].NamespacedName.Name = list.Items[i].Name
+			reqs[i].NamespacedName.Namespace = list.Items[i].Namespace
+		}
+		return reqs
+	}
+}
+
+func (r *KustomizationReconciler) indexByImagePolicy(o client.Object) []string {
+	k, ok := o.(*kustomizev1.Kustomization)
+	if !ok {
+		panic(fmt.Sprintf("Expected a Kustomization, got %T", o))
+	}
+
+	var keys []string
+	for _, image := range k.Spec.ImagePolicies {
+		namespace := k.GetNamespace()
+		if image.FromImagePolicy.Namespace != "" {
+			namespace = image.FromImagePolicy.Namespace
+		}
+		keys = append(keys, fmt.Sprintf("%s/%s", namespace, image.FromImagePolicy.Name))
+	}
+
+	return keys
+}
+
+// requestsForDependencyReady enqueues the Kustomizations that wait
+// for the given Kustomization to become ready.
+func (r *KustomizationReconciler) requestsForDependencyReady(indexKey string) func(obj client.Object) []reconcile.Request {
+	return func(obj client.Object) []reconcile.Request {
+		ctx := context.Background()
+		var list kustomizev1.KustomizationList
+		if err := r.List(ctx, &list, client.MatchingFields{
+			indexKey: client.ObjectKeyFromObject(obj).String(),
+		}); err != nil {
+			return nil
+		}
+		var reqs []reconcile.Request
+		for _, d := range list.Items {
+			ready := apimeta.FindStatusCondition(d.Status.Conditions, meta.ReadyCondition)
+			if ready == nil || ready.Reason != kustomizev1.DependencyNotReadyReason {
+				continue
 			}
-			return []string{fmt.Sprintf("%s/%s", namespace, k.Spec.SourceRef.Name)}
+			reqs = append(reqs, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&d)})
 		}
+		return reqs
+	}
+}
 
-		return nil
+func (r *KustomizationReconciler) indexByDependsOn(o client.Object) []string {
+	k, ok := o.(*kustomizev1.Kustomization)
+	if !ok {
+		panic(fmt.Sprintf("Expected a Kustomization, got %T", o))
 	}
+
+	var keys []string
+	for _, d := range k.Spec.DependsOn {
+		namespace := k.GetNamespace()
+		if d.Namespace != "" {
+			namespace = d.Namespace
+		}
+		keys = append(keys, fmt.Sprintf("%s/%s", namespace, d.Name))
+	}
+
+	return keys
 }
@@ -0,0 +1,102 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"testing"
+
+	"github.com/fluxcd/pkg/apis/kustomize"
+	"github.com/fluxcd/pkg/apis/meta"
+	sourcev1 "github.com/fluxcd/source-controller/api/v1beta2"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta2"
+)
+
+func Test_indexBy(t *testing.T) {
+	g := NewWithT(t)
+
+	kustomization := &kustomizev1.Kustomization{
+		ObjectMeta: metav1.ObjectMeta{Name: "apps", Namespace: "flux-system"},
+		Spec: kustomizev1.KustomizationSpec{
+			SourceRef: kustomizev1.CrossNamespaceSourceReference{
+				Kind: sourcev1.GitRepositoryKind,
+				Name: "apps",
+			},
+			Sources: []kustomizev1.SourceMount{
+				{
+					SourceRef: kustomizev1.CrossNamespaceSourceReference{
+						Kind:      sourcev1.OCIRepositoryKind,
+						Name:      "bases",
+						Namespace: "shared",
+					},
+					Path: "./vendor/bases",
+				},
+				{
+					SourceRef: kustomizev1.CrossNamespaceSourceReference{
+						Kind: sourcev1.GitRepositoryKind,
+						Name: "infra",
+					},
+					Path: "./vendor/infra",
+				},
+			},
+		},
+	}
+
+	r := &KustomizationReconciler{}
+	g.Expect(r.indexBy(sourcev1.GitRepositoryKind)(kustomization)).To(Equal([]string{"flux-system/apps", "flux-system/infra"}))
+	g.Expect(r.indexBy(sourcev1.OCIRepositoryKind)(kustomization)).To(Equal([]string{"shared/bases"}))
+	g.Expect(r.indexBy(sourcev1.BucketKind)(kustomization)).To(BeEmpty())
+}
+
+func Test_indexByImagePolicy(t *testing.T) {
+	g := NewWithT(t)
+
+	kustomization := &kustomizev1.Kustomization{
+		ObjectMeta: metav1.ObjectMeta{Name: "apps", Namespace: "flux-system"},
+		Spec: kustomizev1.KustomizationSpec{
+			Images: []kustomize.Image{
+				{Name: "nginx", NewTag: "1.23"},
+			},
+			ImagePolicies: []kustomizev1.ImagePolicyOverride{
+				{Name: "podinfo", FromImagePolicy: meta.NamespacedObjectReference{Name: "podinfo"}},
+				{Name: "redis", FromImagePolicy: meta.NamespacedObjectReference{Name: "redis", Namespace: "shared"}},
+			},
+		},
+	}
+
+	r := &KustomizationReconciler{}
+	g.Expect(r.indexByImagePolicy(kustomization)).To(Equal([]string{"flux-system/podinfo", "shared/redis"}))
+}
+
+func Test_indexByDependsOn(t *testing.T) {
+	g := NewWithT(t)
+
+	kustomization := &kustomizev1.Kustomization{
+		ObjectMeta: metav1.ObjectMeta{Name: "apps", Namespace: "flux-system"},
+		Spec: kustomizev1.KustomizationSpec{
+			DependsOn: []meta.NamespacedObjectReference{
+				{Name: "infra"},
+				{Name: "cert-manager", Namespace: "cert-manager"},
+			},
+		},
+	}
+
+	r := &KustomizationReconciler{}
+	g.Expect(r.indexByDependsOn(kustomization)).To(Equal([]string{"flux-system/infra", "cert-manager/cert-manager"}))
+}
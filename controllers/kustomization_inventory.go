@@ -50,6 +50,105 @@ func AddObjectsToInventory(inv *kustomizev1.ResourceInventory, set *ssa.ChangeSe
 	return nil
 }
 
+// AddImagesToInventory records the container images of the given objects in
+// their inventory entries. The objects skipped or validated with a server-side
+// dry-run are left out, as their images are not applied.
+func AddImagesToInventory(inv *kustomizev1.ResourceInventory, objects []*unstructured.Unstructured) {
+	images := make(map[string][]string)
+	for _, obj := range objects {
+		if hasObjectPolicy(obj, kustomizev1.SkipObjectPolicy) || hasObjectPolicy(obj, kustomizev1.DryRunObjectPolicy) {
+			continue
+		}
+		if refs := uniqueImages(containerImages(obj.Object["spec"])); len(refs) > 0 {
+			images[object.UnstructuredToObjMetadata(obj).String()] = refs
+		}
+	}
+	for i := range inv.Entries {
+		if refs, ok := images[inv.Entries[i].ID]; ok {
+			inv.Entries[i].Images = refs
+		}
+	}
+}
+
+// KeepInventoryImages copies the images recorded in the old inventory to the
+// entries of the given objects, which are kept in the inventory without
+// being applied.
+func KeepInventoryImages(inv *kustomizev1.ResourceInventory, old *kustomizev1.ResourceInventory,
+	objects []*unstructured.Unstructured) {
+	if old == nil || len(objects) == 0 {
+		return
+	}
+	images := make(map[string][]string)
+	for _, entry := range old.Entries {
+		images[entry.ID] = entry.Images
+	}
+	kept := make(map[string]bool)
+	for _, obj := range objects {
+		kept[object.UnstructuredToObjMetadata(obj).String()] = true
+	}
+	for i := range inv.Entries {
+		if id := inv.Entries[i].ID; kept[id] && len(images[id]) > 0 {
+			inv.Entries[i].Images = images[id]
+		}
+	}
+}
+
+// uniqueImages returns the given images sorted and deduplicated.
+func uniqueImages(images []string) []string {
+	if len(images) == 0 {
+		return nil
+	}
+	sort.Strings(images)
+	result := images[:1]
+	for _, image := range images[1:] {
+		if image != result[len(result)-1] {
+			result = append(result, image)
+		}
+	}
+	return result
+}
+
+// SortInventory sorts the inventory entries by object ID and removes the
+// duplicates, so that the inventory doesn't change between reconciliations
+// unless the set of objects changes.
+func SortInventory(inv *kustomizev1.ResourceInventory) {
+	if inv == nil || len(inv.Entries) == 0 {
+		return
+	}
+	inv.Entries = sortResourceRefs(inv.Entries)
+}
+
+// sortResourceRefs returns the given entries sorted by object ID, keeping
+// the first entry of each ID.
+func sortResourceRefs(entries []kustomizev1.ResourceRef) []kustomizev1.ResourceRef {
+	if len(entries) == 0 {
+		return entries
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].ID < entries[j].ID
+	})
+	result := entries[:1]
+	for _, entry := range entries[1:] {
+		if entry.ID != result[len(result)-1].ID {
+			result = append(result, entry)
+		}
+	}
+	return result
+}
+
+// normalizeStatus sorts the object lists recorded in the given status, so that
+// the status of a Kustomization is the same across reconciliations of the
+// same revision regardless of the order in which the objects were applied.
+func normalizeStatus(status *kustomizev1.KustomizationStatus) {
+	SortInventory(status.Inventory)
+	status.TakeoverClaims = sortResourceRefs(status.TakeoverClaims)
+	status.HeldForTakeover = sortResourceRefs(status.HeldForTakeover)
+	status.DeferredApplies = sortResourceRefs(status.DeferredApplies)
+	sort.SliceStable(status.LastAttemptedFailures, func(i, j int) bool {
+		return status.LastAttemptedFailures[i].ID < status.LastAttemptedFailures[j].ID
+	})
+}
+
 // ListObjectsInInventory returns the inventory entries as unstructured.Unstructured objects.
 func ListObjectsInInventory(inv *kustomizev1.ResourceInventory) ([]*unstructured.Unstructured, error) {
 	objects := make([]*unstructured.Unstructured, 0)
@@ -19,6 +19,7 @@ package controllers
 import (
 	"context"
 	"fmt"
+	"strings"
 	"testing"
 	"time"
 
@@ -28,6 +29,7 @@ import (
 	"sigs.k8s.io/cli-utils/pkg/object"
 
 	"github.com/fluxcd/pkg/apis/meta"
+	"github.com/fluxcd/pkg/ssa"
 	"github.com/fluxcd/pkg/testserver"
 	sourcev1 "github.com/fluxcd/source-controller/api/v1beta2"
 	. "github.com/onsi/gomega"
@@ -271,3 +273,136 @@ stringData:
 		g.Expect(configMap.Data["key"]).To(Equal(id))
 	})
 }
+
+func Test_normalizeStatus(t *testing.T) {
+	g := NewWithT(t)
+
+	status := kustomizev1.KustomizationStatus{
+		Inventory: &kustomizev1.ResourceInventory{
+			Entries: []kustomizev1.ResourceRef{
+				{ID: "default_b__ConfigMap", Version: "v1"},
+				{ID: "_default__Namespace", Version: "v1"},
+				{ID: "default_a__ConfigMap", Version: "v1"},
+				{ID: "default_b__ConfigMap", Version: "v1"},
+			},
+		},
+		TakeoverClaims: []kustomizev1.ResourceRef{
+			{ID: "default_b__Secret", Version: "v1"},
+			{ID: "default_a__Secret", Version: "v1"},
+		},
+		HeldForTakeover: []kustomizev1.ResourceRef{
+			{ID: "default_d__Secret", Version: "v1"},
+			{ID: "default_c__Secret", Version: "v1"},
+		},
+		LastAttemptedFailures: []kustomizev1.ObjectFailure{
+			{ID: "default_z__ConfigMap", Reason: "Invalid"},
+			{ID: "default_y__ConfigMap", Reason: "Forbidden"},
+		},
+	}
+
+	normalizeStatus(&status)
+
+	ids := func(entries []kustomizev1.ResourceRef) []string {
+		var result []string
+		for _, entry := range entries {
+			result = append(result, entry.ID)
+		}
+		return result
+	}
+	g.Expect(ids(status.Inventory.Entries)).To(Equal([]string{
+		"_default__Namespace",
+		"default_a__ConfigMap",
+		"default_b__ConfigMap",
+	}))
+	g.Expect(ids(status.TakeoverClaims)).To(Equal([]string{"default_a__Secret", "default_b__Secret"}))
+	g.Expect(ids(status.HeldForTakeover)).To(Equal([]string{"default_c__Secret", "default_d__Secret"}))
+	g.Expect(status.LastAttemptedFailures[0].ID).To(Equal("default_y__ConfigMap"))
+
+	empty := kustomizev1.KustomizationStatus{}
+	normalizeStatus(&empty)
+	g.Expect(empty.Inventory).To(BeNil())
+	g.Expect(empty.TakeoverClaims).To(BeNil())
+}
+
+func Test_AddImagesToInventory(t *testing.T) {
+	g := NewWithT(t)
+
+	manifests := `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: podinfo
+  namespace: apps
+spec:
+  template:
+    spec:
+      initContainers:
+      - name: init
+        image: busybox:1.35
+      containers:
+      - name: podinfo
+        image: ghcr.io/stefanprodan/podinfo:6.2.0@sha256:24a0c4b4a4c0eb97a1aabb8e29f18e917d05abfe1b7a7c07857230879ce7d3d3
+      - name: sidecar
+        image: busybox:1.35
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: podinfo
+  namespace: apps
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: canary
+  namespace: apps
+  annotations:
+    kustomize.toolkit.fluxcd.io/dry-run: enabled
+spec:
+  template:
+    spec:
+      containers:
+      - name: podinfo
+        image: ghcr.io/stefanprodan/podinfo:6.3.0
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: redis
+  namespace: apps
+spec:
+  template:
+    spec:
+      containers:
+      - name: redis
+        image: redis:7.0
+`
+	objects, err := ssa.ReadObjects(strings.NewReader(manifests))
+	g.Expect(err).NotTo(HaveOccurred())
+
+	inventory := &kustomizev1.ResourceInventory{
+		Entries: []kustomizev1.ResourceRef{
+			{ID: "apps_podinfo_apps_Deployment", Version: "v1"},
+			{ID: "apps_podinfo__ConfigMap", Version: "v1"},
+			{ID: "apps_canary_apps_Deployment", Version: "v1"},
+		},
+	}
+	AddImagesToInventory(inventory, objects[:3])
+
+	g.Expect(inventory.Entries[0].Images).To(Equal([]string{
+		"busybox:1.35",
+		"ghcr.io/stefanprodan/podinfo:6.2.0@sha256:24a0c4b4a4c0eb97a1aabb8e29f18e917d05abfe1b7a7c07857230879ce7d3d3",
+	}))
+	g.Expect(inventory.Entries[1].Images).To(BeEmpty())
+	g.Expect(inventory.Entries[2].Images).To(BeEmpty())
+
+	// the images of the deferred workloads are carried over from the old inventory
+	old := &kustomizev1.ResourceInventory{
+		Entries: []kustomizev1.ResourceRef{
+			{ID: "apps_redis_apps_Deployment", Version: "v1", Images: []string{"redis:6.2"}},
+		},
+	}
+	inventory.Entries = append(inventory.Entries, objectsToResourceRefs(objects[3:])...)
+	KeepInventoryImages(inventory, old, objects[3:])
+	g.Expect(inventory.Entries[3].Images).To(Equal([]string{"redis:6.2"}))
+}
@@ -0,0 +1,134 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/cli-utils/pkg/object"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/fluxcd/pkg/ssa"
+
+	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta2"
+)
+
+// jobFinishedAt returns the time the Job completed or failed,
+// and false if the Job is still running.
+func jobFinishedAt(job batchv1.Job) (time.Time, bool) {
+	for _, c := range job.Status.Conditions {
+		if (c.Type == batchv1.JobComplete || c.Type == batchv1.JobFailed) && c.Status == corev1.ConditionTrue {
+			return c.LastTransitionTime.Time, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// expiredJobs returns the Jobs that finished more than ttl ago and are not
+// part of the inventory, sorted by name. The Jobs with reconciliation
+// disabled are excluded.
+func expiredJobs(jobs []batchv1.Job, inventory object.ObjMetadataSet, ttl time.Duration, now time.Time) []batchv1.Job {
+	exclusions := map[string]string{
+		fmt.Sprintf("%s/reconcile", kustomizev1.GroupVersion.Group): kustomizev1.DisabledValue,
+	}
+
+	var result []batchv1.Job
+	for _, job := range jobs {
+		if !job.DeletionTimestamp.IsZero() ||
+			hasAnyLabelOrAnnotation(job.GetLabels(), job.GetAnnotations(), exclusions) {
+			continue
+		}
+		id := object.ObjMetadata{
+			Namespace: job.GetNamespace(),
+			Name:      job.GetName(),
+			GroupKind: batchv1.SchemeGroupVersion.WithKind("Job").GroupKind(),
+		}
+		if inventory.Contains(id) {
+			continue
+		}
+		if finishedAt, ok := jobFinishedAt(job); ok && now.Sub(finishedAt) >= ttl {
+			result = append(result, job)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].GetName() < result[j].GetName()
+	})
+	return result
+}
+
+// deleteFinishedJobs deletes the Jobs applied by the Kustomization that finished
+// more than spec.pruneOptions.finishedJobsTTL ago, and are no longer part of the
+// inventory. The Jobs are looked up in the namespaces of the inventory objects
+// and are identified by the Kustomization owner labels.
+func (r *KustomizationReconciler) deleteFinishedJobs(ctx context.Context,
+	kubeClient client.Client,
+	manager *ssa.ResourceManager,
+	kustomization kustomizev1.Kustomization,
+	inventory *kustomizev1.ResourceInventory) (string, error) {
+	if kustomization.Spec.PruneOptions == nil || kustomization.Spec.PruneOptions.FinishedJobsTTL == nil ||
+		ownerLabelsDisabled(kustomization) || inventory == nil {
+		return "", nil
+	}
+
+	metas, err := ListMetaInInventory(inventory)
+	if err != nil {
+		return "", err
+	}
+	namespaces := make(map[string]bool)
+	for _, m := range metas {
+		if m.Namespace != "" {
+			namespaces[m.Namespace] = true
+		}
+	}
+	sorted := make([]string, 0, len(namespaces))
+	for ns := range namespaces {
+		sorted = append(sorted, ns)
+	}
+	sort.Strings(sorted)
+
+	owner := ownerLabels(manager, kustomization)
+	ttl := kustomization.Spec.PruneOptions.FinishedJobsTTL.Duration
+
+	var changeLog strings.Builder
+	for _, ns := range sorted {
+		jobs := &batchv1.JobList{}
+		if err := kubeClient.List(ctx, jobs, client.InNamespace(ns), client.MatchingLabels(owner)); err != nil {
+			return "", fmt.Errorf("failed to list Jobs in namespace '%s': %w", ns, err)
+		}
+
+		for _, job := range expiredJobs(jobs.Items, metas, ttl, time.Now()) {
+			job := job
+			if err := kubeClient.Delete(ctx, &job, client.PropagationPolicy(metav1.DeletePropagationBackground)); err != nil {
+				if apierrors.IsNotFound(err) {
+					continue
+				}
+				return "", fmt.Errorf("failed to delete Job '%s/%s': %w", job.GetNamespace(), job.GetName(), err)
+			}
+			changeLog.WriteString(fmt.Sprintf("Job/%s/%s deleted\n", job.GetNamespace(), job.GetName()))
+		}
+	}
+
+	return strings.TrimSuffix(changeLog.String(), "\n"), nil
+}
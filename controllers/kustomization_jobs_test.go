@@ -0,0 +1,97 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta2"
+)
+
+func TestKustomizationReconciler_deleteFinishedJobs(t *testing.T) {
+	g := NewWithT(t)
+
+	now := time.Now()
+	owner := map[string]string{"app.example.com/owner": "apps"}
+	newJob := func(name string, labels map[string]string, condition batchv1.JobConditionType, finishedAt time.Time) *batchv1.Job {
+		job := &batchv1.Job{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "apps", Name: name, Labels: labels},
+		}
+		if condition != "" {
+			job.Status.Conditions = []batchv1.JobCondition{{
+				Type:               condition,
+				Status:             corev1.ConditionTrue,
+				LastTransitionTime: metav1.NewTime(finishedAt),
+			}}
+		}
+		return job
+	}
+
+	scheme := runtime.NewScheme()
+	g.Expect(batchv1.AddToScheme(scheme)).To(Succeed())
+	kubeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+		newJob("migrate-abc", owner, batchv1.JobComplete, now.Add(-2*time.Hour)),
+		newJob("migrate-def", owner, batchv1.JobFailed, now.Add(-2*time.Hour)),
+		newJob("migrate-ghi", owner, batchv1.JobComplete, now.Add(-2*time.Hour)),
+		newJob("migrate-jkl", owner, batchv1.JobComplete, now.Add(-time.Minute)),
+		newJob("migrate-mno", owner, "", time.Time{}),
+		newJob("other", nil, batchv1.JobComplete, now.Add(-2*time.Hour)),
+	).Build()
+
+	inventory := &kustomizev1.ResourceInventory{
+		Entries: []kustomizev1.ResourceRef{
+			{ID: "apps_migrate-ghi_batch_Job", Version: "v1"},
+			{ID: "apps_app__ConfigMap", Version: "v1"},
+		},
+	}
+
+	k := kustomizev1.Kustomization{}
+	k.Spec.OwnerLabels = &kustomizev1.OwnerLabels{Labels: owner}
+
+	r := &KustomizationReconciler{}
+	changeLog, err := r.deleteFinishedJobs(context.TODO(), kubeClient, nil, k, inventory)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(changeLog).To(BeEmpty())
+
+	k.Spec.PruneOptions = &kustomizev1.PruneOptions{FinishedJobsTTL: &metav1.Duration{Duration: time.Hour}}
+	changeLog, err = r.deleteFinishedJobs(context.TODO(), kubeClient, nil, k, inventory)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(changeLog).To(Equal("Job/apps/migrate-abc deleted\nJob/apps/migrate-def deleted"))
+
+	for name, deleted := range map[string]bool{
+		"migrate-abc": true,
+		"migrate-def": true,
+		"migrate-ghi": false,
+		"migrate-jkl": false,
+		"migrate-mno": false,
+		"other":       false,
+	} {
+		err := kubeClient.Get(context.TODO(), types.NamespacedName{Namespace: "apps", Name: name}, &batchv1.Job{})
+		g.Expect(apierrors.IsNotFound(err)).To(Equal(deleted), name)
+	}
+}
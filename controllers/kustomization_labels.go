@@ -0,0 +1,70 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/fluxcd/pkg/ssa"
+
+	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta2"
+)
+
+// ownerLabels returns the labels that record the ownership of the objects
+// applied by the Kustomization. When the owner labels are disabled,
+// an empty map is returned.
+func ownerLabels(manager *ssa.ResourceManager, kustomization kustomizev1.Kustomization) map[string]string {
+	spec := kustomization.Spec.OwnerLabels
+	switch {
+	case spec == nil:
+		return manager.GetOwnerLabels(kustomization.GetName(), kustomization.GetNamespace())
+	case spec.Disabled:
+		return map[string]string{}
+	case len(spec.Labels) > 0:
+		labels := make(map[string]string, len(spec.Labels))
+		for k, v := range spec.Labels {
+			labels[k] = v
+		}
+		return labels
+	default:
+		return manager.GetOwnerLabels(kustomization.GetName(), kustomization.GetNamespace())
+	}
+}
+
+// ownerLabelsDisabled returns true if the Kustomization doesn't set owner labels,
+// in which case the ownership of the objects can't be verified before deleting them.
+func ownerLabelsDisabled(kustomization kustomizev1.Kustomization) bool {
+	return kustomization.Spec.OwnerLabels != nil && kustomization.Spec.OwnerLabels.Disabled
+}
+
+// setOwnerLabels adds the owner labels to the given objects.
+func setOwnerLabels(objects []*unstructured.Unstructured, owner map[string]string) {
+	if len(owner) == 0 {
+		return
+	}
+
+	for _, obj := range objects {
+		labels := obj.GetLabels()
+		if labels == nil {
+			labels = make(map[string]string, len(owner))
+		}
+		for k, v := range owner {
+			labels[k] = v
+		}
+		obj.SetLabels(labels)
+	}
+}
@@ -0,0 +1,80 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"testing"
+
+	"github.com/fluxcd/pkg/ssa"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta2"
+)
+
+func Test_ownerLabels(t *testing.T) {
+	manager := ssa.NewResourceManager(nil, nil, ssa.Owner{
+		Field: "kustomize-controller",
+		Group: kustomizev1.GroupVersion.Group,
+	})
+
+	tests := []struct {
+		name   string
+		labels *kustomizev1.OwnerLabels
+		want   map[string]string
+	}{
+		{
+			name:   "defaults",
+			labels: nil,
+			want: map[string]string{
+				"kustomize.toolkit.fluxcd.io/name":      "apps",
+				"kustomize.toolkit.fluxcd.io/namespace": "flux-system",
+			},
+		},
+		{
+			name:   "custom",
+			labels: &kustomizev1.OwnerLabels{Labels: map[string]string{"example.com/owner": "apps"}},
+			want:   map[string]string{"example.com/owner": "apps"},
+		},
+		{
+			name:   "disabled",
+			labels: &kustomizev1.OwnerLabels{Disabled: true, Labels: map[string]string{"example.com/owner": "apps"}},
+			want:   map[string]string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			kustomization := kustomizev1.Kustomization{
+				ObjectMeta: metav1.ObjectMeta{Name: "apps", Namespace: "flux-system"},
+				Spec:       kustomizev1.KustomizationSpec{OwnerLabels: tt.labels},
+			}
+
+			owner := ownerLabels(manager, kustomization)
+			g.Expect(owner).To(Equal(tt.want))
+
+			obj := &unstructured.Unstructured{}
+			obj.SetLabels(map[string]string{"app": "podinfo"})
+			setOwnerLabels([]*unstructured.Unstructured{obj}, owner)
+			g.Expect(obj.GetLabels()).To(HaveLen(len(tt.want) + 1))
+			g.Expect(obj.GetLabels()).To(HaveKeyWithValue("app", "podinfo"))
+		})
+	}
+}
@@ -0,0 +1,120 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"sync"
+	"time"
+
+	sourcev1 "github.com/fluxcd/source-controller/api/v1beta2"
+
+	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta2"
+)
+
+const (
+	// MemoryBudgetRequeueInterval is the interval at which the Kustomizations
+	// deferred by the memory budget are requeued.
+	MemoryBudgetRequeueInterval = 5 * time.Second
+
+	// artifactMemoryRatio is the ratio between the memory used to extract,
+	// build and decode an artifact and the size of the compressed artifact.
+	artifactMemoryRatio = 20
+
+	// objectMemoryFootprint is the approximate memory used to dry-run,
+	// apply and health check an object of the inventory.
+	objectMemoryFootprint = 32 << 10
+
+	// minMemoryFootprint is the memory reserved for a reconciliation
+	// when the artifact size and the inventory are unknown.
+	minMemoryFootprint = 1 << 20
+)
+
+// MemoryBudget caps the approximate memory footprint of the in-flight
+// reconciliations, the reconciliations that would exceed it are deferred.
+type MemoryBudget struct {
+	limit int64
+
+	mu       sync.Mutex
+	reserved int64
+	inFlight int
+}
+
+// NewMemoryBudget returns a MemoryBudget of limit bytes, zero means no limit.
+func NewMemoryBudget(limit int64) *MemoryBudget {
+	return &MemoryBudget{limit: limit}
+}
+
+// TryReserve reserves the given number of bytes, it returns false if
+// the reservation would exceed the budget. A reconciliation larger than
+// the budget is allowed when no other reconciliation is in flight.
+func (b *MemoryBudget) TryReserve(size int64) bool {
+	if b == nil || b.limit <= 0 {
+		return true
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.inFlight > 0 && b.reserved+size > b.limit {
+		return false
+	}
+	b.reserved += size
+	b.inFlight++
+	return true
+}
+
+// Release frees the bytes reserved with TryReserve.
+func (b *MemoryBudget) Release(size int64) {
+	if b == nil || b.limit <= 0 {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.reserved -= size
+	b.inFlight--
+}
+
+// Reserved returns the number of bytes reserved by the in-flight reconciliations.
+func (b *MemoryBudget) Reserved() int64 {
+	if b == nil {
+		return 0
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.reserved
+}
+
+// memoryFootprint estimates the memory used by the reconciliation of the
+// Kustomization from the size of the source artifact and the number of
+// objects applied by the previous reconciliation.
+func memoryFootprint(kustomization kustomizev1.Kustomization, artifact *sourcev1.Artifact) int64 {
+	var size int64
+	if artifact != nil && artifact.Size != nil {
+		size += *artifact.Size * artifactMemoryRatio
+	}
+	if inv := kustomization.Status.Inventory; inv != nil {
+		size += int64(len(inv.Entries)) * objectMemoryFootprint
+	}
+	if size < minMemoryFootprint {
+		size = minMemoryFootprint
+	}
+	return size
+}
@@ -0,0 +1,63 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+
+	sourcev1 "github.com/fluxcd/source-controller/api/v1beta2"
+
+	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta2"
+)
+
+func TestMemoryBudget(t *testing.T) {
+	g := NewWithT(t)
+
+	b := NewMemoryBudget(100)
+	g.Expect(b.TryReserve(60)).To(BeTrue())
+	g.Expect(b.TryReserve(50)).To(BeFalse())
+	g.Expect(b.TryReserve(40)).To(BeTrue())
+	g.Expect(b.Reserved()).To(Equal(int64(100)))
+
+	b.Release(60)
+	b.Release(40)
+	g.Expect(b.Reserved()).To(BeZero())
+
+	// a reconciliation larger than the budget runs alone
+	g.Expect(b.TryReserve(150)).To(BeTrue())
+	g.Expect(b.TryReserve(1)).To(BeFalse())
+	b.Release(150)
+
+	// no limit
+	var disabled *MemoryBudget
+	g.Expect(disabled.TryReserve(1 << 40)).To(BeTrue())
+	g.Expect(NewMemoryBudget(0).TryReserve(1 << 40)).To(BeTrue())
+}
+
+func Test_memoryFootprint(t *testing.T) {
+	g := NewWithT(t)
+
+	k := kustomizev1.Kustomization{}
+	g.Expect(memoryFootprint(k, nil)).To(Equal(int64(minMemoryFootprint)))
+
+	size := int64(1 << 20)
+	artifact := &sourcev1.Artifact{Size: &size}
+	k.Status.Inventory = &kustomizev1.ResourceInventory{Entries: make([]kustomizev1.ResourceRef, 10)}
+	g.Expect(memoryFootprint(k, artifact)).To(Equal(size*artifactMemoryRatio + 10*objectMemoryFootprint))
+}
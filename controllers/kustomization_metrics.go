@@ -0,0 +1,259 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/fluxcd/pkg/runtime/metrics"
+)
+
+// ObjectMetricsRecorder records the readiness, suspension and duration metrics
+// of the reconciled objects, it's implemented by the GitOps Toolkit metrics.Recorder
+// and by the LimitedMetricsRecorder.
+type ObjectMetricsRecorder interface {
+	RecordCondition(ref corev1.ObjectReference, condition metav1.Condition, deleted bool)
+	RecordSuspend(ref corev1.ObjectReference, suspend bool)
+	RecordDuration(ref corev1.ObjectReference, start time.Time)
+}
+
+// MetricsCardinalityOptions limits the number of series of the per-object metrics.
+type MetricsCardinalityOptions struct {
+	// ObjectLabels are the per-object labels attached to the metrics,
+	// the supported values are 'name' and 'namespace'.
+	ObjectLabels []string
+
+	// MaxObjectsPerNamespace is the maximum number of objects of a namespace
+	// with their own series, zero means no limit.
+	MaxObjectsPerNamespace int
+}
+
+// Limited returns true if the options restrict the per-object series.
+func (o MetricsCardinalityOptions) Limited() bool {
+	labels := make(map[string]bool)
+	for _, l := range o.ObjectLabels {
+		labels[l] = true
+	}
+	return !labels["name"] || !labels["namespace"] || o.MaxObjectsPerNamespace > 0
+}
+
+// LimitedMetricsRecorder records the GitOps Toolkit metrics of the reconciled objects
+// with a limited cardinality. When the name or namespace labels are dropped, or when
+// a namespace has more objects than allowed, the objects are summed in a shared series
+// without these labels: the condition and suspend gauges count the objects, and the
+// duration histogram contains the observations of all the objects.
+type LimitedMetricsRecorder struct {
+	keepName               bool
+	keepNamespace          bool
+	maxObjectsPerNamespace int
+
+	conditionGauge    *prometheus.GaugeVec
+	suspendGauge      *prometheus.GaugeVec
+	durationHistogram *prometheus.HistogramVec
+
+	mu         sync.Mutex
+	objects    map[metricsSeries]*objectMetrics
+	named      map[string]int
+	conditions map[metricsSeries]map[string]map[string]int
+	suspended  map[metricsSeries]int
+}
+
+// metricsSeries contains the per-object label values of a series.
+type metricsSeries struct {
+	kind      string
+	name      string
+	namespace string
+}
+
+// objectMetrics contains the last recorded state of an object.
+type objectMetrics struct {
+	series     metricsSeries
+	conditions map[string]string
+	suspended  bool
+}
+
+// NewLimitedMetricsRecorder returns a LimitedMetricsRecorder, its collectors
+// must be registered with the metrics registry.
+func NewLimitedMetricsRecorder(opts MetricsCardinalityOptions) (*LimitedMetricsRecorder, error) {
+	r := &LimitedMetricsRecorder{
+		maxObjectsPerNamespace: opts.MaxObjectsPerNamespace,
+		conditionGauge: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "gotk_reconcile_condition",
+				Help: "The current condition status of a GitOps Toolkit resource reconciliation.",
+			},
+			[]string{"kind", "name", "namespace", "type", "status"},
+		),
+		suspendGauge: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "gotk_suspend_status",
+				Help: "The current suspend status of a GitOps Toolkit resource.",
+			},
+			[]string{"kind", "name", "namespace"},
+		),
+		durationHistogram: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "gotk_reconcile_duration_seconds",
+				Help:    "The duration in seconds of a GitOps Toolkit resource reconciliation.",
+				Buckets: prometheus.ExponentialBuckets(10e-9, 10, 10),
+			},
+			[]string{"kind", "name", "namespace"},
+		),
+		objects:    make(map[metricsSeries]*objectMetrics),
+		named:      make(map[string]int),
+		conditions: make(map[metricsSeries]map[string]map[string]int),
+		suspended:  make(map[metricsSeries]int),
+	}
+
+	for _, label := range opts.ObjectLabels {
+		switch label {
+		case "name":
+			r.keepName = true
+		case "namespace":
+			r.keepNamespace = true
+		default:
+			return nil, fmt.Errorf("unsupported metrics label '%s', must be one of: name, namespace", label)
+		}
+	}
+	return r, nil
+}
+
+// Collectors returns the metrics.Collector objects for the LimitedMetricsRecorder.
+func (r *LimitedMetricsRecorder) Collectors() []prometheus.Collector {
+	return []prometheus.Collector{
+		r.conditionGauge,
+		r.suspendGauge,
+		r.durationHistogram,
+	}
+}
+
+// object returns the state of the object, the series of a new object
+// has the name label only if the namespace has a free slot.
+func (r *LimitedMetricsRecorder) object(ref corev1.ObjectReference) *objectMetrics {
+	key := metricsSeries{kind: ref.Kind, name: ref.Name, namespace: ref.Namespace}
+	if obj, ok := r.objects[key]; ok {
+		return obj
+	}
+
+	obj := &objectMetrics{
+		series:     metricsSeries{kind: ref.Kind},
+		conditions: make(map[string]string),
+	}
+	if r.keepNamespace {
+		obj.series.namespace = ref.Namespace
+	}
+	if r.keepName && (r.maxObjectsPerNamespace == 0 || r.named[ref.Namespace] < r.maxObjectsPerNamespace) {
+		obj.series.name = ref.Name
+		r.named[ref.Namespace]++
+	}
+	r.objects[key] = obj
+	return obj
+}
+
+// perObject returns true if the series contains only the given object.
+func (r *LimitedMetricsRecorder) perObject(series metricsSeries) bool {
+	return r.keepNamespace && series.name != ""
+}
+
+func (r *LimitedMetricsRecorder) setConditionGauges(series metricsSeries, conditionType string) {
+	counts := r.conditions[series][conditionType]
+	for _, status := range []string{string(metav1.ConditionTrue), string(metav1.ConditionFalse), string(metav1.ConditionUnknown)} {
+		r.conditionGauge.WithLabelValues(series.kind, series.name, series.namespace, conditionType, status).
+			Set(float64(counts[status]))
+	}
+}
+
+// RecordCondition records the condition as given for the ref, a deleted object
+// is removed from the counts and its slot is freed.
+func (r *LimitedMetricsRecorder) RecordCondition(ref corev1.ObjectReference, condition metav1.Condition, deleted bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	obj := r.object(ref)
+	if _, ok := r.conditions[obj.series]; !ok {
+		r.conditions[obj.series] = make(map[string]map[string]int)
+	}
+	if _, ok := r.conditions[obj.series][condition.Type]; !ok {
+		r.conditions[obj.series][condition.Type] = make(map[string]int)
+	}
+	counts := r.conditions[obj.series][condition.Type]
+
+	if status, ok := obj.conditions[condition.Type]; ok {
+		counts[status]--
+	}
+
+	if !deleted {
+		obj.conditions[condition.Type] = string(condition.Status)
+		counts[string(condition.Status)]++
+		r.setConditionGauges(obj.series, condition.Type)
+		if r.perObject(obj.series) {
+			r.conditionGauge.WithLabelValues(obj.series.kind, obj.series.name, obj.series.namespace, condition.Type, metrics.ConditionDeleted).Set(0)
+		}
+		return
+	}
+
+	r.setConditionGauges(obj.series, condition.Type)
+	if r.perObject(obj.series) {
+		r.conditionGauge.WithLabelValues(obj.series.kind, obj.series.name, obj.series.namespace, condition.Type, metrics.ConditionDeleted).Set(1)
+	}
+	if obj.suspended {
+		r.suspended[obj.series]--
+		r.suspendGauge.WithLabelValues(obj.series.kind, obj.series.name, obj.series.namespace).Set(float64(r.suspended[obj.series]))
+	}
+	if obj.series.name != "" {
+		r.named[ref.Namespace]--
+	}
+	delete(r.objects, metricsSeries{kind: ref.Kind, name: ref.Name, namespace: ref.Namespace})
+}
+
+// RecordSuspend records the suspend status as given for the ref,
+// objects that are not suspended are only counted once their condition is recorded.
+func (r *LimitedMetricsRecorder) RecordSuspend(ref corev1.ObjectReference, suspend bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := metricsSeries{kind: ref.Kind, name: ref.Name, namespace: ref.Namespace}
+	if _, ok := r.objects[key]; !ok && !suspend {
+		return
+	}
+
+	obj := r.object(ref)
+	if obj.suspended != suspend {
+		if suspend {
+			r.suspended[obj.series]++
+		} else {
+			r.suspended[obj.series]--
+		}
+		obj.suspended = suspend
+	}
+	r.suspendGauge.WithLabelValues(obj.series.kind, obj.series.name, obj.series.namespace).Set(float64(r.suspended[obj.series]))
+}
+
+// RecordDuration records the duration since start for the given ref.
+func (r *LimitedMetricsRecorder) RecordDuration(ref corev1.ObjectReference, start time.Time) {
+	r.mu.Lock()
+	series := r.object(ref).series
+	r.mu.Unlock()
+
+	r.durationHistogram.WithLabelValues(series.kind, series.name, series.namespace).Observe(time.Since(start).Seconds())
+}
@@ -0,0 +1,100 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/fluxcd/pkg/apis/meta"
+)
+
+func TestLimitedMetricsRecorder(t *testing.T) {
+	g := NewWithT(t)
+
+	_, err := NewLimitedMetricsRecorder(MetricsCardinalityOptions{ObjectLabels: []string{"name", "kind"}})
+	g.Expect(err).To(HaveOccurred())
+
+	r, err := NewLimitedMetricsRecorder(MetricsCardinalityOptions{
+		ObjectLabels:           []string{"name", "namespace"},
+		MaxObjectsPerNamespace: 1,
+	})
+	g.Expect(err).NotTo(HaveOccurred())
+
+	ref := func(namespace, name string) corev1.ObjectReference {
+		return corev1.ObjectReference{Kind: "Kustomization", Namespace: namespace, Name: name}
+	}
+	ready := func(status metav1.ConditionStatus) metav1.Condition {
+		return metav1.Condition{Type: meta.ReadyCondition, Status: status}
+	}
+	condition := func(name, namespace string, status string) float64 {
+		return testutil.ToFloat64(r.conditionGauge.WithLabelValues("Kustomization", name, namespace, meta.ReadyCondition, status))
+	}
+
+	r.RecordCondition(ref("apps", "app1"), ready(metav1.ConditionTrue), false)
+	r.RecordCondition(ref("apps", "app2"), ready(metav1.ConditionFalse), false)
+	r.RecordCondition(ref("apps", "app3"), ready(metav1.ConditionFalse), false)
+	r.RecordSuspend(ref("apps", "app3"), true)
+	r.RecordDuration(ref("apps", "app3"), time.Now())
+
+	// the first object has its own series, the others are summed
+	g.Expect(condition("app1", "apps", "True")).To(Equal(float64(1)))
+	g.Expect(condition("", "apps", "False")).To(Equal(float64(2)))
+	g.Expect(condition("", "apps", "True")).To(BeZero())
+	g.Expect(testutil.ToFloat64(r.suspendGauge.WithLabelValues("Kustomization", "", "apps"))).To(Equal(float64(1)))
+	g.Expect(testutil.CollectAndCount(r.durationHistogram)).To(Equal(1))
+
+	// status changes move the object between the counts
+	r.RecordCondition(ref("apps", "app2"), ready(metav1.ConditionTrue), false)
+	g.Expect(condition("", "apps", "False")).To(Equal(float64(1)))
+	g.Expect(condition("", "apps", "True")).To(Equal(float64(1)))
+
+	// deleting an object frees its slot
+	r.RecordCondition(ref("apps", "app1"), ready(metav1.ConditionTrue), true)
+	r.RecordSuspend(ref("apps", "app1"), false)
+	g.Expect(condition("app1", "apps", "True")).To(BeZero())
+	g.Expect(condition("app1", "apps", "Deleted")).To(Equal(float64(1)))
+	r.RecordCondition(ref("apps", "app4"), ready(metav1.ConditionUnknown), false)
+	g.Expect(condition("app4", "apps", "Unknown")).To(Equal(float64(1)))
+
+	r.RecordCondition(ref("apps", "app3"), ready(metav1.ConditionFalse), true)
+	g.Expect(condition("", "apps", "False")).To(BeZero())
+	g.Expect(testutil.ToFloat64(r.suspendGauge.WithLabelValues("Kustomization", "", "apps"))).To(BeZero())
+}
+
+func TestLimitedMetricsRecorder_labels(t *testing.T) {
+	g := NewWithT(t)
+
+	r, err := NewLimitedMetricsRecorder(MetricsCardinalityOptions{ObjectLabels: []string{"namespace"}})
+	g.Expect(err).NotTo(HaveOccurred())
+
+	for _, name := range []string{"app1", "app2", "app3"} {
+		r.RecordCondition(corev1.ObjectReference{Kind: "Kustomization", Namespace: "apps", Name: name},
+			metav1.Condition{Type: meta.ReadyCondition, Status: metav1.ConditionTrue}, false)
+	}
+	g.Expect(testutil.ToFloat64(r.conditionGauge.WithLabelValues("Kustomization", "", "apps", meta.ReadyCondition, "True"))).
+		To(Equal(float64(3)))
+
+	g.Expect(MetricsCardinalityOptions{ObjectLabels: []string{"namespace", "name"}}.Limited()).To(BeFalse())
+	g.Expect(MetricsCardinalityOptions{ObjectLabels: []string{"namespace"}}.Limited()).To(BeTrue())
+	g.Expect(MetricsCardinalityOptions{ObjectLabels: []string{"namespace", "name"}, MaxObjectsPerNamespace: 10}.Limited()).To(BeTrue())
+}
@@ -0,0 +1,126 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	"github.com/fluxcd/pkg/runtime/acl"
+
+	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta2"
+)
+
+// migrationSource returns the Kustomization referenced by the
+// migrate-inventory-from annotation. The namespace defaults to the
+// namespace of the given Kustomization.
+func migrationSource(kustomization kustomizev1.Kustomization) (types.NamespacedName, bool) {
+	ref := strings.TrimSpace(kustomization.GetAnnotations()[kustomizev1.MigrateInventoryAnnotation])
+	if ref == "" {
+		return types.NamespacedName{}, false
+	}
+
+	source := types.NamespacedName{Namespace: kustomization.GetNamespace(), Name: ref}
+	if parts := strings.SplitN(ref, "/", 2); len(parts) == 2 {
+		source.Namespace, source.Name = parts[0], parts[1]
+	}
+
+	if source.Name == "" || (source.Namespace == kustomization.GetNamespace() && source.Name == kustomization.GetName()) {
+		return types.NamespacedName{}, false
+	}
+	return source, true
+}
+
+// checkMigrationSource returns an access denied error if the source Kustomization
+// is in another namespace and cross-namespace references have been blocked.
+func (r *KustomizationReconciler) checkMigrationSource(kustomization kustomizev1.Kustomization, source types.NamespacedName) error {
+	if r.NoCrossNamespaceRefs && source.Namespace != kustomization.GetNamespace() {
+		return acl.AccessDeniedError(
+			fmt.Sprintf("can't migrate the inventory from '%s/%s', cross-namespace references have been blocked",
+				kustomizev1.KustomizationKind, source))
+	}
+	return nil
+}
+
+// migrateInventory returns a copy of the inventory of the Kustomization referenced by
+// the migrate-inventory-from annotation. The inventory is transferred only if the
+// given Kustomization has no inventory of its own, and the source Kustomization is
+// either suspended or under deletion, to prevent the two from fighting over the objects.
+func (r *KustomizationReconciler) migrateInventory(ctx context.Context,
+	kustomization kustomizev1.Kustomization) (*kustomizev1.ResourceInventory, string, error) {
+	sourceName, ok := migrationSource(kustomization)
+	if !ok || kustomization.Status.Inventory != nil {
+		return nil, "", nil
+	}
+
+	if err := r.checkMigrationSource(kustomization, sourceName); err != nil {
+		return nil, "", err
+	}
+
+	var source kustomizev1.Kustomization
+	if err := r.Get(ctx, sourceName, &source); err != nil {
+		if apierrors.IsNotFound(err) {
+			// the source was finalized without pruning the objects claimed by this Kustomization
+			return nil, "", nil
+		}
+		return nil, "", fmt.Errorf("failed to get Kustomization '%s' to migrate the inventory from: %w", sourceName, err)
+	}
+
+	if !source.Spec.Suspend && source.ObjectMeta.DeletionTimestamp.IsZero() {
+		return nil, "", fmt.Errorf("inventory migration from Kustomization '%s' requires the source to be suspended or deleted", sourceName)
+	}
+
+	if source.Status.Inventory == nil {
+		return nil, "", nil
+	}
+
+	return source.Status.Inventory.DeepCopy(), sourceName.String(), nil
+}
+
+// isInventoryClaimed returns true if another Kustomization, which is not
+// under deletion, migrates the inventory of the given Kustomization.
+// Claims denied by the cross-namespace references policy are ignored,
+// as the claiming Kustomization can't adopt the inventory.
+func (r *KustomizationReconciler) isInventoryClaimed(ctx context.Context, kustomization kustomizev1.Kustomization) (bool, error) {
+	var list kustomizev1.KustomizationList
+	if err := r.List(ctx, &list); err != nil {
+		return false, fmt.Errorf("failed to list Kustomizations: %w", err)
+	}
+
+	for _, k := range list.Items {
+		if !k.ObjectMeta.DeletionTimestamp.IsZero() {
+			continue
+		}
+		source, ok := migrationSource(k)
+		if !ok || source.Namespace != kustomization.GetNamespace() || source.Name != kustomization.GetName() {
+			continue
+		}
+		if err := r.checkMigrationSource(k, source); err != nil {
+			ctrl.LoggerFrom(ctx).Info(fmt.Sprintf("ignoring inventory claim from Kustomization '%s/%s': %s",
+				k.GetNamespace(), k.GetName(), err.Error()))
+			continue
+		}
+		return true, nil
+	}
+
+	return false, nil
+}
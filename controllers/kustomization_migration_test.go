@@ -0,0 +1,130 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/fluxcd/pkg/runtime/acl"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta2"
+)
+
+func Test_migrationSource(t *testing.T) {
+	tests := []struct {
+		name       string
+		annotation string
+		want       types.NamespacedName
+		wantOK     bool
+	}{
+		{name: "no annotation", annotation: "", wantOK: false},
+		{name: "name only", annotation: "old", want: types.NamespacedName{Namespace: "apps", Name: "old"}, wantOK: true},
+		{name: "namespaced", annotation: "flux-system/old", want: types.NamespacedName{Namespace: "flux-system", Name: "old"}, wantOK: true},
+		{name: "self reference", annotation: "apps/new", wantOK: false},
+		{name: "empty name", annotation: "apps/", wantOK: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			kustomization := kustomizev1.Kustomization{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "new",
+					Namespace: "apps",
+					Annotations: map[string]string{
+						kustomizev1.MigrateInventoryAnnotation: tt.annotation,
+					},
+				},
+			}
+
+			got, ok := migrationSource(kustomization)
+			g.Expect(ok).To(Equal(tt.wantOK))
+			if tt.wantOK {
+				g.Expect(got).To(Equal(tt.want))
+			}
+		})
+	}
+}
+
+func TestKustomizationReconciler_migrateInventory_crossNamespace(t *testing.T) {
+	scheme := runtime.NewScheme()
+	NewWithT(t).Expect(kustomizev1.AddToScheme(scheme)).To(Succeed())
+
+	source := &kustomizev1.Kustomization{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "old",
+			Namespace: "flux-system",
+		},
+		Spec: kustomizev1.KustomizationSpec{
+			Suspend: true,
+		},
+		Status: kustomizev1.KustomizationStatus{
+			Inventory: &kustomizev1.ResourceInventory{
+				Entries: []kustomizev1.ResourceRef{{ID: "apps_web_apps_Deployment", Version: "v1"}},
+			},
+		},
+	}
+	claimer := &kustomizev1.Kustomization{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "new",
+			Namespace: "apps",
+			Annotations: map[string]string{
+				kustomizev1.MigrateInventoryAnnotation: "flux-system/old",
+			},
+		},
+	}
+
+	tests := []struct {
+		name                 string
+		noCrossNamespaceRefs bool
+		wantDenied           bool
+	}{
+		{name: "allowed", noCrossNamespaceRefs: false, wantDenied: false},
+		{name: "blocked", noCrossNamespaceRefs: true, wantDenied: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			r := &KustomizationReconciler{
+				Client:               fake.NewClientBuilder().WithScheme(scheme).WithObjects(source.DeepCopy(), claimer.DeepCopy()).Build(),
+				NoCrossNamespaceRefs: tt.noCrossNamespaceRefs,
+			}
+
+			inventory, _, err := r.migrateInventory(context.TODO(), *claimer)
+			if tt.wantDenied {
+				g.Expect(acl.IsAccessDenied(err)).To(BeTrue())
+				g.Expect(inventory).To(BeNil())
+			} else {
+				g.Expect(err).NotTo(HaveOccurred())
+				g.Expect(inventory.Entries).To(HaveLen(1))
+			}
+
+			claimed, err := r.isInventoryClaimed(context.TODO(), *source)
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(claimed).To(Equal(!tt.wantDenied))
+		})
+	}
+}
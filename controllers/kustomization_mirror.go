@@ -0,0 +1,88 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// anyArtifactHost is the key of the mirror used for the artifact hosts
+// that don't have a mirror of their own.
+const anyArtifactHost = "*"
+
+// ArtifactMirrors maps the hosts serving the artifacts, with or without port,
+// to the base URL of the mirror or pull-through cache they are fetched from.
+type ArtifactMirrors map[string]*url.URL
+
+// ParseArtifactMirrors parses the mirrors given as '<host>=<mirror URL>',
+// e.g. 'source-controller.flux-system.svc.cluster.local.=https://mirror.internal/flux'.
+// The host '*' matches the hosts without a mirror of their own.
+func ParseArtifactMirrors(mirrors map[string]string) (ArtifactMirrors, error) {
+	if len(mirrors) == 0 {
+		return nil, nil
+	}
+	result := make(ArtifactMirrors, len(mirrors))
+	for host, mirror := range mirrors {
+		if host == "" {
+			return nil, fmt.Errorf("invalid artifact mirror '%s': empty host", mirror)
+		}
+		u, err := url.Parse(mirror)
+		if err != nil {
+			return nil, fmt.Errorf("invalid artifact mirror URL '%s' for host '%s': %w", mirror, host, err)
+		}
+		if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || u.RawQuery != "" || u.Fragment != "" {
+			return nil, fmt.Errorf("invalid artifact mirror URL '%s' for host '%s': expected 'http(s)://<host>[/<path>]'",
+				mirror, host)
+		}
+		result[strings.ToLower(host)] = u
+	}
+	return result, nil
+}
+
+// Rewrite returns the URL of the artifact on its mirror, and whether a mirror
+// was found for the artifact host. The path of the artifact is appended to
+// the path of the mirror, the query is preserved.
+func (m ArtifactMirrors) Rewrite(artifactURL string) (string, bool, error) {
+	if len(m) == 0 {
+		return artifactURL, false, nil
+	}
+
+	u, err := url.Parse(artifactURL)
+	if err != nil {
+		return "", false, fmt.Errorf("invalid artifact URL '%s': %w", artifactURL, err)
+	}
+
+	mirror, ok := m[strings.ToLower(u.Host)]
+	if !ok {
+		mirror, ok = m[strings.ToLower(u.Hostname())]
+	}
+	if !ok {
+		mirror, ok = m[anyArtifactHost]
+	}
+	if !ok {
+		return artifactURL, false, nil
+	}
+
+	u.Scheme = mirror.Scheme
+	u.Host = mirror.Host
+	u.User = mirror.User
+	u.Path = strings.TrimSuffix(mirror.Path, "/") + "/" + strings.TrimPrefix(u.Path, "/")
+	u.RawPath = ""
+	return u.String(), true, nil
+}
@@ -0,0 +1,106 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestParseArtifactMirrors(t *testing.T) {
+	g := NewWithT(t)
+
+	mirrors, err := ParseArtifactMirrors(nil)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(mirrors).To(BeNil())
+
+	mirrors, err = ParseArtifactMirrors(map[string]string{
+		"Source-Controller.flux-system.svc.cluster.local.": "https://mirror.internal/flux",
+		"*": "http://cache.internal:8080",
+	})
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(mirrors).To(HaveKey("source-controller.flux-system.svc.cluster.local."))
+	g.Expect(mirrors).To(HaveKey("*"))
+
+	for _, mirror := range []string{"mirror.internal", "ftp://mirror.internal", "https://mirror.internal/?x=1", "://"} {
+		_, err = ParseArtifactMirrors(map[string]string{"source-controller": mirror})
+		g.Expect(err).To(HaveOccurred(), mirror)
+	}
+}
+
+func TestArtifactMirrors_Rewrite(t *testing.T) {
+	mirrors, err := ParseArtifactMirrors(map[string]string{
+		"source-controller.flux-system.svc.cluster.local.": "https://mirror.internal/flux/",
+		"source-controller:9090":                           "http://cache.internal:8080",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		mirrors  ArtifactMirrors
+		url      string
+		want     string
+		mirrored bool
+	}{
+		{
+			name:     "host without port",
+			mirrors:  mirrors,
+			url:      "http://source-controller.flux-system.svc.cluster.local./gitrepository/flux-system/podinfo/6f2b.tar.gz",
+			want:     "https://mirror.internal/flux/gitrepository/flux-system/podinfo/6f2b.tar.gz",
+			mirrored: true,
+		},
+		{
+			name:     "host with port",
+			mirrors:  mirrors,
+			url:      "http://source-controller:9090/ocirepository/apps/podinfo/sha256:3a1c.tar.gz?token=1",
+			want:     "http://cache.internal:8080/ocirepository/apps/podinfo/sha256:3a1c.tar.gz?token=1",
+			mirrored: true,
+		},
+		{
+			name:    "unmapped host",
+			mirrors: mirrors,
+			url:     "http://source-controller/gitrepository/apps/podinfo/6f2b.tar.gz",
+			want:    "http://source-controller/gitrepository/apps/podinfo/6f2b.tar.gz",
+		},
+		{
+			name:     "any host",
+			mirrors:  ArtifactMirrors{anyArtifactHost: mirrors["source-controller:9090"]},
+			url:      "http://source-controller/gitrepository/apps/podinfo/6f2b.tar.gz",
+			want:     "http://cache.internal:8080/gitrepository/apps/podinfo/6f2b.tar.gz",
+			mirrored: true,
+		},
+		{
+			name: "no mirrors",
+			url:  "http://source-controller/gitrepository/apps/podinfo/6f2b.tar.gz",
+			want: "http://source-controller/gitrepository/apps/podinfo/6f2b.tar.gz",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			got, mirrored, err := tt.mirrors.Rewrite(tt.url)
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(got).To(Equal(tt.want))
+			g.Expect(mirrored).To(Equal(tt.mirrored))
+		})
+	}
+}
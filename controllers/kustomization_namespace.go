@@ -0,0 +1,77 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+
+	"sigs.k8s.io/kustomize/api/builtins"
+	"sigs.k8s.io/kustomize/api/filters/namespace"
+	"sigs.k8s.io/kustomize/api/konfig/builtinpluginconsts"
+	"sigs.k8s.io/kustomize/api/resmap"
+	"sigs.k8s.io/kustomize/api/resource"
+	kustypes "sigs.k8s.io/kustomize/api/types"
+	"sigs.k8s.io/yaml"
+
+	"github.com/fluxcd/pkg/apis/kustomize"
+)
+
+// setTargetNamespace sets the namespace of the resources that are not selected
+// by the exemptions, in place of the namespace of the kustomization.yaml.
+// It uses the kustomize namespace transformer with the default field specs,
+// so the namespace of the RoleBinding subjects and of the webhook services
+// is set as it would be by kustomize.
+func setTargetNamespace(m resmap.ResMap, targetNamespace string, exemptions []kustomize.Selector) error {
+	kustomizeBuildMutex.Lock()
+	defer kustomizeBuildMutex.Unlock()
+
+	exempted := make(map[*resource.Resource]bool)
+	for i := range exemptions {
+		selected, err := m.Select(*adaptSelector(&exemptions[i]))
+		if err != nil {
+			return fmt.Errorf("invalid target namespace exemption: %w", err)
+		}
+		for _, res := range selected {
+			exempted[res] = true
+		}
+	}
+
+	// the resources are shared with m, so they are transformed in place
+	targets := resmap.New()
+	for _, res := range m.Resources() {
+		if exempted[res] {
+			continue
+		}
+		if err := targets.Append(res); err != nil {
+			return err
+		}
+	}
+
+	var fieldSpecs struct {
+		Namespace []kustypes.FieldSpec `json:"namespace"`
+	}
+	if err := yaml.Unmarshal([]byte(builtinpluginconsts.GetDefaultFieldSpecsAsMap()["namespace"]), &fieldSpecs); err != nil {
+		return err
+	}
+
+	transformer := builtins.NamespaceTransformerPlugin{
+		ObjectMeta:             kustypes.ObjectMeta{Namespace: targetNamespace},
+		FieldSpecs:             fieldSpecs.Namespace,
+		SetRoleBindingSubjects: namespace.DefaultSubjectsOnly,
+	}
+	return transformer.Transform(targets)
+}
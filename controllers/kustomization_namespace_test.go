@@ -0,0 +1,102 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/fluxcd/pkg/apis/kustomize"
+	. "github.com/onsi/gomega"
+
+	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta2"
+)
+
+func Test_setTargetNamespace(t *testing.T) {
+	g := NewWithT(t)
+
+	dir := t.TempDir()
+	manifests := `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: app
+  namespace: default
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: coredns-custom
+  namespace: kube-system
+  annotations:
+    kustomize.toolkit.fluxcd.io/target-namespace: disabled
+---
+apiVersion: example.com/v1
+kind: ClusterPolicy
+metadata:
+  name: baseline
+---
+apiVersion: rbac.authorization.k8s.io/v1
+kind: RoleBinding
+metadata:
+  name: app
+roleRef:
+  apiGroup: rbac.authorization.k8s.io
+  kind: Role
+  name: app
+subjects:
+- kind: ServiceAccount
+  name: default
+  namespace: default
+`
+	g.Expect(os.WriteFile(filepath.Join(dir, "manifests.yaml"), []byte(manifests), 0o644)).To(Succeed())
+	g.Expect(os.WriteFile(filepath.Join(dir, "kustomization.yaml"), []byte(`apiVersion: kustomize.config.k8s.io/v1beta1
+kind: Kustomization
+namespace: base
+resources:
+- manifests.yaml
+`), 0o644)).To(Succeed())
+
+	kustomization := kustomizev1.Kustomization{}
+	kustomization.Spec.TargetNamespace = "apps"
+	kustomization.Spec.TargetNamespaceExemptions = []kustomize.Selector{
+		{Kind: "ClusterPolicy"},
+		{AnnotationSelector: "kustomize.toolkit.fluxcd.io/target-namespace=disabled"},
+	}
+
+	gen := NewGenerator(dir, kustomization)
+	g.Expect(gen.WriteFile(dir)).To(Succeed())
+
+	resMap, err := secureBuildKustomization(dir, dir, false, "")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(setTargetNamespace(resMap, kustomization.Spec.TargetNamespace,
+		kustomization.Spec.TargetNamespaceExemptions)).To(Succeed())
+
+	namespaces := make(map[string]string)
+	for _, res := range resMap.Resources() {
+		namespaces[res.GetKind()+"/"+res.GetName()] = res.GetNamespace()
+	}
+	g.Expect(namespaces).To(Equal(map[string]string{
+		"ConfigMap/app":            "apps",
+		"ConfigMap/coredns-custom": "kube-system",
+		"ClusterPolicy/baseline":   "",
+		"RoleBinding/app":          "apps",
+	}))
+
+	g.Expect(setTargetNamespace(resMap, "apps", []kustomize.Selector{{LabelSelector: "app in (("}})).
+		To(MatchError(ContainSubstring("invalid target namespace exemption")))
+}
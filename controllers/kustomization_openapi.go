@@ -0,0 +1,88 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	securejoin "github.com/cyphar/filepath-securejoin"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta2"
+)
+
+// openAPISchemaPattern is the name pattern of the files the
+// OpenAPI schemas read from ConfigMaps are written to.
+const openAPISchemaPattern = "openapi-schema-*.json"
+
+// openAPISchema returns the path of the OpenAPI schema referenced by
+// spec.buildOptions.openapi, or an empty string if none is referenced.
+// As kustomize loads the schema from disk, the schema of a ConfigMap
+// is written to a file in the build directory.
+func (r *KustomizationReconciler) openAPISchema(ctx context.Context,
+	kustomization kustomizev1.Kustomization, workDir string) (string, error) {
+	opts := kustomization.Spec.BuildOptions
+	if opts == nil || opts.OpenAPI == nil {
+		return "", nil
+	}
+	schema := opts.OpenAPI
+
+	switch {
+	case schema.Path != "" && schema.ConfigMapRef == nil:
+		path, err := securejoin.SecureJoin(workDir, schema.Path)
+		if err != nil {
+			return "", err
+		}
+		if _, err := os.Stat(path); err != nil {
+			return "", fmt.Errorf("openapi schema not found: %w", err)
+		}
+		return path, nil
+	case schema.ConfigMapRef != nil && schema.Path == "":
+		key := schema.Key
+		if key == "" {
+			key = kustomizev1.DefaultOpenAPISchemaKey
+		}
+
+		name := types.NamespacedName{Namespace: kustomization.GetNamespace(), Name: schema.ConfigMapRef.Name}
+		cm := &corev1.ConfigMap{}
+		if err := r.Get(ctx, name, cm); err != nil {
+			return "", fmt.Errorf("unable to read the openapi schema from ConfigMap '%s': %w", name, err)
+		}
+		data, ok := cm.Data[key]
+		if !ok {
+			return "", fmt.Errorf("openapi schema key '%s' not found in ConfigMap '%s'", key, name)
+		}
+
+		f, err := os.CreateTemp(workDir, openAPISchemaPattern)
+		if err != nil {
+			return "", err
+		}
+		if _, err := f.WriteString(data); err != nil {
+			f.Close()
+			return "", err
+		}
+		if err := f.Close(); err != nil {
+			return "", err
+		}
+		return f.Name(), nil
+	default:
+		return "", fmt.Errorf("exactly one of buildOptions.openapi.path or buildOptions.openapi.configMapRef must be specified")
+	}
+}
@@ -0,0 +1,162 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/fluxcd/pkg/apis/kustomize"
+	"github.com/fluxcd/pkg/apis/meta"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta2"
+)
+
+const gatewaySchema = `{
+  "definitions": {
+    "com.example.v1.Gateway": {
+      "type": "object",
+      "x-kubernetes-group-version-kind": [{"group": "example.com", "kind": "Gateway", "version": "v1"}],
+      "properties": {
+        "apiVersion": {"type": "string"},
+        "kind": {"type": "string"},
+        "metadata": {"type": "object"},
+        "spec": {
+          "type": "object",
+          "properties": {
+            "listeners": {
+              "type": "array",
+              "x-kubernetes-patch-merge-key": "name",
+              "x-kubernetes-patch-strategy": "merge",
+              "items": {
+                "type": "object",
+                "properties": {
+                  "name": {"type": "string"},
+                  "port": {"type": "integer"}
+                }
+              }
+            }
+          }
+        }
+      }
+    }
+  }
+}`
+
+func TestKustomizationReconciler_openAPISchema(t *testing.T) {
+	g := NewWithT(t)
+
+	scheme := runtime.NewScheme()
+	g.Expect(corev1.AddToScheme(scheme)).To(Succeed())
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "apps", Name: "schemas"},
+		Data:       map[string]string{kustomizev1.DefaultOpenAPISchemaKey: gatewaySchema},
+	}
+	r := &KustomizationReconciler{Client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(cm).Build()}
+
+	dir := t.TempDir()
+	g.Expect(os.MkdirAll(filepath.Join(dir, "schemas"), 0o755)).To(Succeed())
+	g.Expect(os.WriteFile(filepath.Join(dir, "schemas", "gateway.json"), []byte(gatewaySchema), 0o644)).To(Succeed())
+
+	k := kustomizev1.Kustomization{ObjectMeta: metav1.ObjectMeta{Namespace: "apps", Name: "gateway"}}
+	path, err := r.openAPISchema(context.TODO(), k, dir)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(path).To(BeEmpty())
+
+	k.Spec.BuildOptions = &kustomizev1.BuildOptions{OpenAPI: &kustomizev1.OpenAPISchema{Path: "./schemas/gateway.json"}}
+	path, err = r.openAPISchema(context.TODO(), k, dir)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(path).To(Equal(filepath.Join(dir, "schemas", "gateway.json")))
+
+	k.Spec.BuildOptions.OpenAPI = &kustomizev1.OpenAPISchema{Path: "./schemas/missing.json"}
+	_, err = r.openAPISchema(context.TODO(), k, dir)
+	g.Expect(err).To(HaveOccurred())
+
+	k.Spec.BuildOptions.OpenAPI = &kustomizev1.OpenAPISchema{ConfigMapRef: &meta.LocalObjectReference{Name: "schemas"}}
+	path, err = r.openAPISchema(context.TODO(), k, dir)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(filepath.Dir(path)).To(Equal(dir))
+	data, err := os.ReadFile(path)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(string(data)).To(Equal(gatewaySchema))
+
+	k.Spec.BuildOptions.OpenAPI.Key = "missing.json"
+	_, err = r.openAPISchema(context.TODO(), k, dir)
+	g.Expect(err).To(HaveOccurred())
+
+	k.Spec.BuildOptions.OpenAPI = &kustomizev1.OpenAPISchema{
+		Path:         "./schemas/gateway.json",
+		ConfigMapRef: &meta.LocalObjectReference{Name: "schemas"},
+	}
+	_, err = r.openAPISchema(context.TODO(), k, dir)
+	g.Expect(err).To(HaveOccurred())
+}
+
+func TestKustomizeGenerator_WriteFile_openAPISchema(t *testing.T) {
+	g := NewWithT(t)
+
+	dir := t.TempDir()
+	gateway := `apiVersion: example.com/v1
+kind: Gateway
+metadata:
+  name: public
+spec:
+  listeners:
+  - name: http
+    port: 80
+`
+	g.Expect(os.WriteFile(filepath.Join(dir, "gateway.yaml"), []byte(gateway), 0o644)).To(Succeed())
+	g.Expect(os.WriteFile(filepath.Join(dir, "schema.json"), []byte(gatewaySchema), 0o644)).To(Succeed())
+
+	kustomization := kustomizev1.Kustomization{}
+	kustomization.Spec.Patches = []kustomize.Patch{
+		{
+			Patch: `
+apiVersion: example.com/v1
+kind: Gateway
+metadata:
+  name: public
+spec:
+  listeners:
+  - name: https
+    port: 443
+`,
+		},
+	}
+
+	gen := NewGenerator(dir, kustomization)
+	gen.openAPISchema = filepath.Join(dir, "schema.json")
+	g.Expect(gen.WriteFile(dir)).To(Succeed())
+	g.Expect(gen.Warnings()).To(BeEmpty())
+
+	resMap, err := secureBuildKustomization(dir, dir, false, "")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(resMap.Resources()).To(HaveLen(1))
+
+	data, err := resMap.Resources()[0].AsYAML()
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(strings.Contains(string(data), "name: http\n")).To(BeTrue(), string(data))
+	g.Expect(strings.Contains(string(data), "name: https\n")).To(BeTrue(), string(data))
+}
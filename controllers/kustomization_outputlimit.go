@@ -0,0 +1,92 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// OutputLimits caps the size of the build output of the Kustomizations,
+// a zero value means no limit.
+type OutputLimits struct {
+	// MaxBytes is the maximum size in bytes of the output of a Kustomization build.
+	MaxBytes int64
+
+	// MaxObjects is the maximum number of objects a Kustomization build can render.
+	MaxObjects int
+
+	// MaxBytesPerNamespace overrides MaxBytes for the Kustomizations in a namespace.
+	MaxBytesPerNamespace map[string]int64
+
+	// MaxObjectsPerNamespace overrides MaxObjects for the Kustomizations in a namespace.
+	MaxObjectsPerNamespace map[string]int
+}
+
+// maxBytes returns the size limit of the Kustomizations in the namespace.
+func (l OutputLimits) maxBytes(namespace string) int64 {
+	if max, ok := l.MaxBytesPerNamespace[namespace]; ok {
+		return max
+	}
+	return l.MaxBytes
+}
+
+// maxObjects returns the object count limit of the Kustomizations in the namespace.
+func (l OutputLimits) maxObjects(namespace string) int {
+	if max, ok := l.MaxObjectsPerNamespace[namespace]; ok {
+		return max
+	}
+	return l.MaxObjects
+}
+
+// checkOutputSize returns an error if the build output exceeds the size limit
+// of the namespace, it's checked before the output is decoded.
+func checkOutputSize(limits OutputLimits, namespace string, resources []byte) error {
+	if max := limits.maxBytes(namespace); max > 0 && int64(len(resources)) > max {
+		return fmt.Errorf("the build output is %s, the limit is %s",
+			resource.NewQuantity(int64(len(resources)), resource.BinarySI),
+			resource.NewQuantity(max, resource.BinarySI))
+	}
+	return nil
+}
+
+// checkOutputObjects returns an error if the build renders more objects
+// than the limit of the namespace.
+func checkOutputObjects(limits OutputLimits, namespace string, count int) error {
+	if max := limits.maxObjects(namespace); max > 0 && count > max {
+		return fmt.Errorf("the build output contains %d objects, the limit is %d", count, max)
+	}
+	return nil
+}
+
+// ParseOutputByteLimits parses the per namespace size limits,
+// given as quantities e.g. '10Mi'.
+func ParseOutputByteLimits(limits map[string]string) (map[string]int64, error) {
+	if len(limits) == 0 {
+		return nil, nil
+	}
+	result := make(map[string]int64, len(limits))
+	for namespace, value := range limits {
+		q, err := resource.ParseQuantity(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid size limit '%s' for namespace '%s': %w", value, namespace, err)
+		}
+		result[namespace] = q.Value()
+	}
+	return result, nil
+}
@@ -0,0 +1,118 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"bytes"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func Test_checkOutputSize(t *testing.T) {
+	resources := bytes.Repeat([]byte("a"), 2048)
+
+	tests := []struct {
+		name      string
+		limits    OutputLimits
+		namespace string
+		wantErr   string
+	}{
+		{
+			name:      "no limits",
+			limits:    OutputLimits{},
+			namespace: "team-a",
+		},
+		{
+			name:      "within limit",
+			limits:    OutputLimits{MaxBytes: 4096},
+			namespace: "team-a",
+		},
+		{
+			name:      "limit exceeded",
+			limits:    OutputLimits{MaxBytes: 1024},
+			namespace: "team-a",
+			wantErr:   "the build output is 2Ki, the limit is 1Ki",
+		},
+		{
+			name:      "namespace override raises the limit",
+			limits:    OutputLimits{MaxBytes: 1024, MaxBytesPerNamespace: map[string]int64{"team-a": 4096}},
+			namespace: "team-a",
+		},
+		{
+			name:      "namespace override lowers the limit",
+			limits:    OutputLimits{MaxBytes: 4096, MaxBytesPerNamespace: map[string]int64{"team-a": 1024}},
+			namespace: "team-a",
+			wantErr:   "the limit is 1Ki",
+		},
+		{
+			name:      "zero namespace override removes the limit",
+			limits:    OutputLimits{MaxBytes: 1024, MaxBytesPerNamespace: map[string]int64{"team-a": 0}},
+			namespace: "team-a",
+		},
+		{
+			name:      "override of another namespace",
+			limits:    OutputLimits{MaxBytes: 1024, MaxBytesPerNamespace: map[string]int64{"team-b": 4096}},
+			namespace: "team-a",
+			wantErr:   "the limit is 1Ki",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			err := checkOutputSize(tt.limits, tt.namespace, resources)
+			if tt.wantErr == "" {
+				g.Expect(err).NotTo(HaveOccurred())
+				return
+			}
+			g.Expect(err).To(HaveOccurred())
+			g.Expect(err.Error()).To(ContainSubstring(tt.wantErr))
+		})
+	}
+}
+
+func Test_checkOutputObjects(t *testing.T) {
+	g := NewWithT(t)
+
+	limits := OutputLimits{MaxObjects: 10, MaxObjectsPerNamespace: map[string]int{"team-a": 100}}
+	g.Expect(checkOutputObjects(limits, "team-a", 50)).To(Succeed())
+	g.Expect(checkOutputObjects(limits, "team-b", 10)).To(Succeed())
+
+	err := checkOutputObjects(limits, "team-b", 50)
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(err.Error()).To(Equal("the build output contains 50 objects, the limit is 10"))
+
+	g.Expect(checkOutputObjects(OutputLimits{}, "team-b", 50)).To(Succeed())
+}
+
+func Test_ParseOutputByteLimits(t *testing.T) {
+	g := NewWithT(t)
+
+	limits, err := ParseOutputByteLimits(map[string]string{"team-a": "5Mi", "team-b": "1000"})
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(limits).To(Equal(map[string]int64{"team-a": 5 * 1024 * 1024, "team-b": 1000}))
+
+	_, err = ParseOutputByteLimits(map[string]string{"team-a": "five"})
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(err.Error()).To(ContainSubstring("namespace 'team-a'"))
+
+	limits, err = ParseOutputByteLimits(nil)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(limits).To(BeNil())
+}
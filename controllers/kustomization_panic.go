@@ -0,0 +1,48 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+
+	"github.com/fluxcd/pkg/runtime/events"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta2"
+)
+
+// recoverPanic converts a panic recovered from the reconciliation of a Kustomization
+// into a failed Ready condition, so that a malformed object can't crash the controller.
+// The stack trace is logged, and the error is returned to retry with backoff.
+func (r *KustomizationReconciler) recoverPanic(ctx context.Context, req ctrl.Request,
+	kustomization kustomizev1.Kustomization, recovered interface{}) (ctrl.Result, error) {
+	log := ctrl.LoggerFrom(ctx)
+	err := fmt.Errorf("reconciliation panic: %v", recovered)
+	log.Error(err, "recovered from panic", "stacktrace", string(debug.Stack()))
+
+	revision := kustomization.Status.LastAttemptedRevision
+	kustomization = kustomizev1.KustomizationNotReady(kustomization, revision,
+		kustomizev1.ReconciliationPanicReason, err.Error())
+	if patchErr := r.patchStatus(ctx, req, kustomization.Status); patchErr != nil {
+		log.Error(patchErr, "unable to update status after panic")
+	}
+	r.recordReadiness(ctx, kustomization)
+	r.event(ctx, kustomization, revision, events.EventSeverityError, err.Error(), nil)
+	return ctrl.Result{}, err
+}
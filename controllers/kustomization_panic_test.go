@@ -0,0 +1,74 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/fluxcd/pkg/apis/meta"
+
+	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta2"
+)
+
+func TestKustomizationReconciler_recoverPanic(t *testing.T) {
+	g := NewWithT(t)
+
+	scheme := runtime.NewScheme()
+	g.Expect(kustomizev1.AddToScheme(scheme)).To(Succeed())
+	k := &kustomizev1.Kustomization{
+		ObjectMeta: metav1.ObjectMeta{Name: "apps", Namespace: "flux-system"},
+		Status:     kustomizev1.KustomizationStatus{LastAttemptedRevision: "main/1"},
+	}
+	kubeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(k).Build()
+	recorder := record.NewFakeRecorder(10)
+	r := &KustomizationReconciler{Client: kubeClient, EventRecorder: recorder}
+	req := ctrl.Request{NamespacedName: client.ObjectKeyFromObject(k)}
+
+	reconcile := func() (result ctrl.Result, retErr error) {
+		defer func() {
+			if recovered := recover(); recovered != nil {
+				result, retErr = r.recoverPanic(context.TODO(), req, *k, recovered)
+			}
+		}()
+		var objects map[string]string
+		objects["apps"] = "podinfo"
+		return ctrl.Result{}, nil
+	}
+
+	result, err := reconcile()
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(err.Error()).To(ContainSubstring("reconciliation panic: assignment to entry in nil map"))
+	g.Expect(result).To(Equal(ctrl.Result{}))
+
+	var got kustomizev1.Kustomization
+	g.Expect(kubeClient.Get(context.TODO(), req.NamespacedName, &got)).To(Succeed())
+	ready := apimeta.FindStatusCondition(got.Status.Conditions, meta.ReadyCondition)
+	g.Expect(ready).NotTo(BeNil())
+	g.Expect(ready.Status).To(Equal(metav1.ConditionFalse))
+	g.Expect(ready.Reason).To(Equal(kustomizev1.ReconciliationPanicReason))
+	g.Expect(recorder.Events).To(Receive(ContainSubstring("reconciliation panic")))
+}
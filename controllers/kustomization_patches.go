@@ -0,0 +1,177 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/fluxcd/pkg/apis/kustomize"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+
+	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta2"
+)
+
+// patchErrors holds the invalid fields of the patches with their location
+// in the Kustomization spec.
+type patchErrors []string
+
+func (e *patchErrors) add(fldPath *field.Path, format string, args ...interface{}) {
+	*e = append(*e, fmt.Sprintf("%s: %s", fldPath.String(), fmt.Sprintf(format, args...)))
+}
+
+// validatePatches checks that the patches of the given spec can be parsed
+// by kustomize, the returned error lists the path of every invalid field,
+// e.g. 'spec.patches[1].patch[0].path'.
+func validatePatches(spec kustomizev1.KustomizationSpec) error {
+	var errs patchErrors
+	specPath := field.NewPath("spec")
+
+	for i, patch := range spec.Patches {
+		idxPath := specPath.Child("patches").Index(i)
+		validatePatchDocument(&errs, idxPath.Child("patch"), patch.Patch)
+		validatePatchTarget(&errs, idxPath.Child("target"), patch.Target)
+	}
+
+	for i, patch := range spec.PatchesStrategicMerge {
+		var doc map[string]interface{}
+		if err := json.Unmarshal(patch.Raw, &doc); err != nil || doc == nil {
+			errs.add(specPath.Child("patchesStrategicMerge").Index(i), "must be a YAML object")
+		}
+	}
+
+	for i, patch := range spec.PatchesJSON6902 {
+		idxPath := specPath.Child("patchesJson6902").Index(i)
+		if len(patch.Patch) == 0 {
+			errs.add(idxPath.Child("patch"), "must contain at least one operation")
+		}
+		for j, patchOp := range patch.Patch {
+			op := map[string]interface{}{"op": patchOp.Op, "path": patchOp.Path, "from": patchOp.From}
+			if patchOp.Value != nil {
+				op["value"] = patchOp.Value
+			}
+			validatePatchOp(&errs, idxPath.Child("patch").Index(j), op)
+		}
+		validatePatchTarget(&errs, idxPath.Child("target"), patch.Target)
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid patches: %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// validatePatchDocument checks that the given patch is a single YAML document
+// holding either a strategic merge patch or a list of JSON6902 operations.
+func validatePatchDocument(errs *patchErrors, fldPath *field.Path, patch string) {
+	if strings.TrimSpace(patch) == "" {
+		errs.add(fldPath, "must contain a strategic merge patch or a JSON6902 patch")
+		return
+	}
+
+	var docs []interface{}
+	decoder := utilyaml.NewYAMLOrJSONDecoder(strings.NewReader(patch), 4096)
+	for {
+		var doc interface{}
+		if err := decoder.Decode(&doc); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			errs.add(fldPath, "%s", err.Error())
+			return
+		}
+		if doc != nil {
+			docs = append(docs, doc)
+		}
+	}
+	if len(docs) != 1 {
+		errs.add(fldPath, "must contain a single YAML document, found %d", len(docs))
+		return
+	}
+
+	switch doc := docs[0].(type) {
+	case map[string]interface{}:
+	case []interface{}:
+		if len(doc) == 0 {
+			errs.add(fldPath, "must contain at least one operation")
+		}
+		for i, item := range doc {
+			op, ok := item.(map[string]interface{})
+			if !ok {
+				errs.add(fldPath.Index(i), "must be a JSON6902 operation object")
+				continue
+			}
+			validatePatchOp(errs, fldPath.Index(i), op)
+		}
+	default:
+		errs.add(fldPath, "must be a strategic merge patch object or a list of JSON6902 operations")
+	}
+}
+
+// validatePatchOp checks the fields of a JSON6902 operation against RFC 6902.
+func validatePatchOp(errs *patchErrors, fldPath *field.Path, op map[string]interface{}) {
+	name, _ := op["op"].(string)
+	switch name {
+	case "":
+		errs.add(fldPath.Child("op"), "required value")
+	case "add", "remove", "replace", "move", "copy", "test":
+	default:
+		errs.add(fldPath.Child("op"), "unsupported operation '%s'", name)
+	}
+
+	validatePointer(errs, fldPath.Child("path"), op["path"])
+
+	switch name {
+	case "add", "replace", "test":
+		if _, ok := op["value"]; !ok {
+			errs.add(fldPath.Child("value"), "required for the '%s' operation", name)
+		}
+	case "move", "copy":
+		validatePointer(errs, fldPath.Child("from"), op["from"])
+	}
+}
+
+// validatePointer checks that the given value is a non-empty JSON pointer.
+func validatePointer(errs *patchErrors, fldPath *field.Path, value interface{}) {
+	pointer, ok := value.(string)
+	switch {
+	case value == nil || (ok && pointer == ""):
+		errs.add(fldPath, "required value")
+	case !ok || !strings.HasPrefix(pointer, "/"):
+		errs.add(fldPath, "must be a JSON pointer starting with '/'")
+	}
+}
+
+// validatePatchTarget checks the syntax of the label and annotation selectors
+// of the given patch target.
+func validatePatchTarget(errs *patchErrors, fldPath *field.Path, target kustomize.Selector) {
+	if target.LabelSelector != "" {
+		if _, err := labels.Parse(target.LabelSelector); err != nil {
+			errs.add(fldPath.Child("labelSelector"), "%s", err.Error())
+		}
+	}
+	if target.AnnotationSelector != "" {
+		if _, err := labels.Parse(target.AnnotationSelector); err != nil {
+			errs.add(fldPath.Child("annotationSelector"), "%s", err.Error())
+		}
+	}
+}
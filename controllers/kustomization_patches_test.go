@@ -0,0 +1,174 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"testing"
+
+	"github.com/fluxcd/pkg/apis/kustomize"
+	. "github.com/onsi/gomega"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+
+	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta2"
+)
+
+func Test_validatePatches(t *testing.T) {
+	tests := []struct {
+		name    string
+		spec    kustomizev1.KustomizationSpec
+		wantErr []string
+	}{
+		{
+			name: "valid patches",
+			spec: kustomizev1.KustomizationSpec{
+				Patches: []kustomize.Patch{
+					{
+						Patch: `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: not-used
+spec:
+  replicas: 2
+`,
+						Target: kustomize.Selector{Kind: "Deployment", LabelSelector: "app in (podinfo)"},
+					},
+					{
+						Patch: `- op: add
+  path: /metadata/labels/env
+  value: prod
+- op: move
+  from: /spec/replicas
+  path: /spec/count
+- op: remove
+  path: /metadata/annotations
+`,
+					},
+					{
+						Patch: `[{"op": "replace", "path": "/spec/replicas", "value": 3}]`,
+					},
+				},
+				PatchesStrategicMerge: []apiextensionsv1.JSON{
+					{Raw: []byte(`{"kind":"Deployment","metadata":{"name":"podinfo"}}`)},
+				},
+				PatchesJSON6902: []kustomize.JSON6902Patch{
+					{
+						Patch:  []kustomize.JSON6902{{Op: "add", Path: "/spec/replicas", Value: &apiextensionsv1.JSON{Raw: []byte("1")}}},
+						Target: kustomize.Selector{Kind: "Deployment", Name: "podinfo"},
+					},
+				},
+			},
+		},
+		{
+			name: "unparsable patch",
+			spec: kustomizev1.KustomizationSpec{
+				Patches: []kustomize.Patch{
+					{Patch: "- op: add\n  path: /spec/replicas\n  value: 1\n"},
+					{Patch: "kind: Deployment\nmetadata:\n\tname: podinfo\n"},
+				},
+			},
+			wantErr: []string{"spec.patches[1].patch: error converting YAML to JSON: yaml: line 3"},
+		},
+		{
+			name: "empty and multi-document patches",
+			spec: kustomizev1.KustomizationSpec{
+				Patches: []kustomize.Patch{
+					{Patch: " \n"},
+					{Patch: "kind: ConfigMap\n---\nkind: Secret\n"},
+					{Patch: "podinfo"},
+				},
+			},
+			wantErr: []string{
+				"spec.patches[0].patch: must contain a strategic merge patch or a JSON6902 patch",
+				"spec.patches[1].patch: must contain a single YAML document, found 2",
+				"spec.patches[2].patch: must be a strategic merge patch object or a list of JSON6902 operations",
+			},
+		},
+		{
+			name: "invalid operations",
+			spec: kustomizev1.KustomizationSpec{
+				Patches: []kustomize.Patch{
+					{
+						Patch: `- op: add
+  path: /spec/replicas
+- op: upsert
+  path: spec/replicas
+- op: copy
+  path: /spec/count
+- remove
+`,
+					},
+				},
+			},
+			wantErr: []string{
+				"spec.patches[0].patch[0].value: required for the 'add' operation",
+				"spec.patches[0].patch[1].op: unsupported operation 'upsert'",
+				"spec.patches[0].patch[1].path: must be a JSON pointer starting with '/'",
+				"spec.patches[0].patch[2].from: required value",
+				"spec.patches[0].patch[3]: must be a JSON6902 operation object",
+			},
+		},
+		{
+			name: "invalid deprecated patches",
+			spec: kustomizev1.KustomizationSpec{
+				PatchesStrategicMerge: []apiextensionsv1.JSON{
+					{Raw: []byte(`["kind"]`)},
+				},
+				PatchesJSON6902: []kustomize.JSON6902Patch{
+					{
+						Patch: []kustomize.JSON6902{{Op: "replace", Path: ""}},
+					},
+				},
+			},
+			wantErr: []string{
+				"spec.patchesStrategicMerge[0]: must be a YAML object",
+				"spec.patchesJson6902[0].patch[0].path: required value",
+				"spec.patchesJson6902[0].patch[0].value: required for the 'replace' operation",
+			},
+		},
+		{
+			name: "invalid target selectors",
+			spec: kustomizev1.KustomizationSpec{
+				Patches: []kustomize.Patch{
+					{
+						Patch:  "kind: Deployment\n",
+						Target: kustomize.Selector{LabelSelector: "app in podinfo", AnnotationSelector: "=="},
+					},
+				},
+			},
+			wantErr: []string{
+				"spec.patches[0].target.labelSelector: ",
+				"spec.patches[0].target.annotationSelector: ",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			err := validatePatches(tt.spec)
+			if len(tt.wantErr) == 0 {
+				g.Expect(err).NotTo(HaveOccurred())
+				return
+			}
+			g.Expect(err).To(HaveOccurred())
+			for _, want := range tt.wantErr {
+				g.Expect(err.Error()).To(ContainSubstring(want))
+			}
+		})
+	}
+}
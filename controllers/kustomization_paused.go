@@ -0,0 +1,91 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/cli-utils/pkg/object"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta2"
+)
+
+// pausableWorkloads maps the kinds of the workloads that can be paused
+// to the path of the field that pauses them.
+var pausableWorkloads = map[schema.GroupKind][]string{
+	{Group: "apps", Kind: "Deployment"}: {"spec", "paused"},
+	{Group: "batch", Kind: "CronJob"}:   {"spec", "suspend"},
+}
+
+// readyWhenPaused returns true if the paused workloads are considered healthy.
+func readyWhenPaused(kustomization kustomizev1.Kustomization) bool {
+	opts := kustomization.Spec.HealthCheckOptions
+	return opts != nil && opts.ReadyWhenPaused
+}
+
+// excludePausedWorkloads returns the objects that are not explicitly paused,
+// along with the ones that are and should be skipped by the health assessment.
+func excludePausedWorkloads(ctx context.Context, kubeClient client.Client,
+	objects []object.ObjMetadata) ([]object.ObjMetadata, []object.ObjMetadata, error) {
+	var active, paused []object.ObjMetadata
+	for _, obj := range objects {
+		isPaused, err := isWorkloadPaused(ctx, kubeClient, obj)
+		if err != nil {
+			return nil, nil, err
+		}
+		if isPaused {
+			paused = append(paused, obj)
+			continue
+		}
+		active = append(active, obj)
+	}
+	return active, paused, nil
+}
+
+// isWorkloadPaused returns true if the object is a workload with its pause field set to true.
+// The objects that are not found are left for the health assessment to report.
+func isWorkloadPaused(ctx context.Context, kubeClient client.Client, obj object.ObjMetadata) (bool, error) {
+	field, ok := pausableWorkloads[obj.GroupKind]
+	if !ok {
+		return false, nil
+	}
+
+	mapping, err := kubeClient.RESTMapper().RESTMapping(obj.GroupKind)
+	if err != nil {
+		return false, fmt.Errorf("unable to map %s/%s: %w", obj.GroupKind.Kind, obj.Name, err)
+	}
+
+	u := &unstructured.Unstructured{}
+	u.SetGroupVersionKind(mapping.GroupVersionKind)
+	if err := kubeClient.Get(ctx, client.ObjectKey{Namespace: obj.Namespace, Name: obj.Name}, u); err != nil {
+		if apierrors.IsNotFound(err) {
+			return false, nil
+		}
+		return false, fmt.Errorf("unable to get %s/%s/%s: %w", obj.GroupKind.Kind, obj.Namespace, obj.Name, err)
+	}
+
+	paused, _, err := unstructured.NestedBool(u.Object, field...)
+	if err != nil {
+		return false, fmt.Errorf("invalid %s/%s/%s: %w", obj.GroupKind.Kind, obj.Namespace, obj.Name, err)
+	}
+	return paused, nil
+}
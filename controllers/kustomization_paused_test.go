@@ -0,0 +1,91 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/cli-utils/pkg/object"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta2"
+)
+
+func Test_readyWhenPaused(t *testing.T) {
+	g := NewWithT(t)
+
+	k := kustomizev1.Kustomization{}
+	g.Expect(readyWhenPaused(k)).To(BeFalse())
+
+	k.Spec.HealthCheckOptions = &kustomizev1.HealthCheckOptions{}
+	g.Expect(readyWhenPaused(k)).To(BeFalse())
+
+	k.Spec.HealthCheckOptions.ReadyWhenPaused = true
+	g.Expect(readyWhenPaused(k)).To(BeTrue())
+}
+
+func Test_excludePausedWorkloads(t *testing.T) {
+	g := NewWithT(t)
+
+	scheme := runtime.NewScheme()
+	g.Expect(appsv1.AddToScheme(scheme)).To(Succeed())
+	g.Expect(batchv1.AddToScheme(scheme)).To(Succeed())
+	g.Expect(corev1.AddToScheme(scheme)).To(Succeed())
+
+	mapper := meta.NewDefaultRESTMapper(nil)
+	mapper.Add(appsv1.SchemeGroupVersion.WithKind("Deployment"), meta.RESTScopeNamespace)
+	mapper.Add(batchv1.SchemeGroupVersion.WithKind("CronJob"), meta.RESTScopeNamespace)
+	mapper.Add(corev1.SchemeGroupVersion.WithKind("ConfigMap"), meta.RESTScopeNamespace)
+
+	suspended, scheduled := true, false
+	objectMeta := func(name string) metav1.ObjectMeta {
+		return metav1.ObjectMeta{Namespace: "apps", Name: name}
+	}
+	kubeClient := fake.NewClientBuilder().WithScheme(scheme).WithRESTMapper(mapper).WithObjects(
+		&appsv1.Deployment{ObjectMeta: objectMeta("paused"), Spec: appsv1.DeploymentSpec{Paused: true}},
+		&appsv1.Deployment{ObjectMeta: objectMeta("running")},
+		&batchv1.CronJob{ObjectMeta: objectMeta("suspended"), Spec: batchv1.CronJobSpec{Suspend: &suspended}},
+		&batchv1.CronJob{ObjectMeta: objectMeta("scheduled"), Spec: batchv1.CronJobSpec{Suspend: &scheduled}},
+		&corev1.ConfigMap{ObjectMeta: objectMeta("config")},
+	).Build()
+
+	objMeta := func(group, kind, name string) object.ObjMetadata {
+		return object.ObjMetadata{Namespace: "apps", Name: name, GroupKind: schema.GroupKind{Group: group, Kind: kind}}
+	}
+	objects := []object.ObjMetadata{
+		objMeta("apps", "Deployment", "paused"),
+		objMeta("apps", "Deployment", "running"),
+		objMeta("apps", "Deployment", "missing"),
+		objMeta("batch", "CronJob", "suspended"),
+		objMeta("batch", "CronJob", "scheduled"),
+		objMeta("", "ConfigMap", "config"),
+	}
+
+	active, paused, err := excludePausedWorkloads(context.TODO(), kubeClient, objects)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(paused).To(ConsistOf(objects[0], objects[3]))
+	g.Expect(active).To(ConsistOf(objects[1], objects[2], objects[4], objects[5]))
+}
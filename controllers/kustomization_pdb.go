@@ -0,0 +1,158 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	policyv1 "k8s.io/api/policy/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/fluxcd/pkg/ssa"
+
+	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta2"
+)
+
+// podDisruption is a stale workload whose deletion would evict
+// more ready pods than a PodDisruptionBudget allows.
+type podDisruption struct {
+	object  *unstructured.Unstructured
+	budget  string
+	pods    int64
+	allowed int64
+}
+
+func (d podDisruption) String() string {
+	return fmt.Sprintf("%s deletion would disrupt %d pods, PodDisruptionBudget/%s allows %d disruptions",
+		ssa.FmtUnstructured(d.object), d.pods, d.budget, d.allowed)
+}
+
+// podDisruptions returns the stale workloads whose deletion would violate the
+// PodDisruptionBudgets in their namespace. The disruptions allowed by a budget
+// are consumed in order, so that the workloads selected by the same budget are
+// checked against the disruptions it allows in total.
+func podDisruptions(ctx context.Context, kubeClient client.Client, objects []*unstructured.Unstructured) ([]podDisruption, error) {
+	budgets := make(map[string][]policyv1.PodDisruptionBudget)
+	allowed := make(map[string]int64)
+
+	var result []podDisruption
+	for _, obj := range objects {
+		if !isPodWorkload(obj) {
+			continue
+		}
+
+		existing := &unstructured.Unstructured{}
+		existing.SetGroupVersionKind(obj.GroupVersionKind())
+		if err := kubeClient.Get(ctx, client.ObjectKeyFromObject(obj), existing); err != nil {
+			if apierrors.IsNotFound(err) {
+				continue
+			}
+			return nil, fmt.Errorf("failed to get %s: %w", ssa.FmtUnstructured(obj), err)
+		}
+
+		podLabels, pods := workloadPods(existing)
+		if pods == 0 {
+			continue
+		}
+
+		namespace := obj.GetNamespace()
+		if _, ok := budgets[namespace]; !ok {
+			var list policyv1.PodDisruptionBudgetList
+			if err := kubeClient.List(ctx, &list, client.InNamespace(namespace)); err != nil {
+				return nil, fmt.Errorf("failed to list PodDisruptionBudgets in namespace '%s': %w", namespace, err)
+			}
+			budgets[namespace] = list.Items
+			for _, pdb := range list.Items {
+				allowed[namespace+"/"+pdb.GetName()] = int64(pdb.Status.DisruptionsAllowed)
+			}
+		}
+
+		var violation *podDisruption
+		for _, pdb := range budgets[namespace] {
+			// a nil selector matches no pods, an empty selector matches all pods
+			selector, err := metav1.LabelSelectorAsSelector(pdb.Spec.Selector)
+			if err != nil || !selector.Matches(labels.Set(podLabels)) {
+				continue
+			}
+
+			key := namespace + "/" + pdb.GetName()
+			if pods > allowed[key] && violation == nil {
+				violation = &podDisruption{object: obj, budget: key, pods: pods, allowed: allowed[key]}
+			}
+			allowed[key] -= pods
+			if allowed[key] < 0 {
+				allowed[key] = 0
+			}
+		}
+		if violation != nil {
+			result = append(result, *violation)
+		}
+	}
+	return result, nil
+}
+
+// isPodWorkload returns true for the kinds whose deletion evicts pods.
+func isPodWorkload(obj *unstructured.Unstructured) bool {
+	gk := obj.GroupVersionKind().GroupKind()
+	switch gk.Group {
+	case "":
+		return gk.Kind == "Pod"
+	case "apps":
+		switch gk.Kind {
+		case "Deployment", "StatefulSet", "ReplicaSet", "DaemonSet":
+			return true
+		}
+	}
+	return false
+}
+
+// workloadPods returns the labels of the pods managed by the workload
+// and the number of pods that are ready.
+func workloadPods(obj *unstructured.Unstructured) (map[string]string, int64) {
+	if obj.GetKind() == "Pod" {
+		conditions, _, _ := unstructured.NestedSlice(obj.Object, "status", "conditions")
+		for _, c := range conditions {
+			if condition, ok := c.(map[string]interface{}); ok &&
+				condition["type"] == "Ready" && condition["status"] == "True" {
+				return obj.GetLabels(), 1
+			}
+		}
+		return obj.GetLabels(), 0
+	}
+
+	field := "readyReplicas"
+	if obj.GetKind() == "DaemonSet" {
+		field = "numberReady"
+	}
+	ready, _, _ := unstructured.NestedInt64(obj.Object, "status", field)
+	podLabels, _, _ := unstructured.NestedStringMap(obj.Object, "spec", "template", "metadata", "labels")
+	return podLabels, ready
+}
+
+// podDisruptionPolicy returns the policy applied to the deletions
+// that violate a PodDisruptionBudget, empty if the budgets are ignored.
+func podDisruptionPolicy(kustomization kustomizev1.Kustomization) string {
+	if kustomization.Spec.PruneOptions == nil {
+		return ""
+	}
+	return kustomization.Spec.PruneOptions.PodDisruptionBudgets
+}
@@ -0,0 +1,143 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func Test_podDisruptions(t *testing.T) {
+	g := NewWithT(t)
+
+	scheme := runtime.NewScheme()
+	g.Expect(corev1.AddToScheme(scheme)).To(Succeed())
+	g.Expect(appsv1.AddToScheme(scheme)).To(Succeed())
+	g.Expect(policyv1.AddToScheme(scheme)).To(Succeed())
+
+	statefulSet := func(name string, ready int32) *appsv1.StatefulSet {
+		return &appsv1.StatefulSet{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+			Spec: appsv1.StatefulSetSpec{
+				Template: corev1.PodTemplateSpec{
+					ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"app": name}},
+				},
+			},
+			Status: appsv1.StatefulSetStatus{ReadyReplicas: ready},
+		}
+	}
+	budget := func(name string, selector *metav1.LabelSelector, allowed int32) *policyv1.PodDisruptionBudget {
+		return &policyv1.PodDisruptionBudget{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+			Spec:       policyv1.PodDisruptionBudgetSpec{Selector: selector},
+			Status:     policyv1.PodDisruptionBudgetStatus{DisruptionsAllowed: allowed},
+		}
+	}
+
+	kubeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+		statefulSet("etcd", 3),
+		statefulSet("redis", 1),
+		statefulSet("cache", 1),
+		statefulSet("web", 2),
+		budget("etcd", &metav1.LabelSelector{MatchLabels: map[string]string{"app": "etcd"}}, 1),
+		budget("cache", &metav1.LabelSelector{MatchExpressions: []metav1.LabelSelectorRequirement{
+			{Key: "app", Operator: metav1.LabelSelectorOpIn, Values: []string{"redis", "cache"}},
+		}}, 1),
+		budget("none", nil, 0),
+	).Build()
+
+	stale := func(kind, name string) *unstructured.Unstructured {
+		u := &unstructured.Unstructured{}
+		u.SetGroupVersionKind(schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: kind})
+		u.SetNamespace("default")
+		u.SetName(name)
+		return u
+	}
+	configMap := &unstructured.Unstructured{}
+	configMap.SetGroupVersionKind(schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"})
+	configMap.SetNamespace("default")
+	configMap.SetName("etcd")
+
+	disruptions, err := podDisruptions(context.TODO(), kubeClient, []*unstructured.Unstructured{
+		configMap,
+		stale("StatefulSet", "etcd"),
+		stale("StatefulSet", "redis"),
+		stale("StatefulSet", "cache"),
+		stale("StatefulSet", "web"),
+		stale("StatefulSet", "missing"),
+	})
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(disruptions).To(HaveLen(2))
+
+	// the etcd budget allows a single disruption
+	g.Expect(disruptions[0].object.GetName()).To(Equal("etcd"))
+	g.Expect(disruptions[0].String()).To(Equal(
+		"StatefulSet/default/etcd deletion would disrupt 3 pods, PodDisruptionBudget/default/etcd allows 1 disruptions"))
+
+	// the disruption allowed by the cache budget is consumed by redis
+	g.Expect(disruptions[1].object.GetName()).To(Equal("cache"))
+	g.Expect(disruptions[1].allowed).To(BeEquivalentTo(0))
+}
+
+func Test_workloadPods(t *testing.T) {
+	g := NewWithT(t)
+
+	pod := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "Pod",
+		"metadata":   map[string]interface{}{"name": "test", "labels": map[string]interface{}{"app": "test"}},
+		"status": map[string]interface{}{
+			"conditions": []interface{}{
+				map[string]interface{}{"type": "Ready", "status": "True"},
+			},
+		},
+	}}
+	g.Expect(isPodWorkload(pod)).To(BeTrue())
+	podLabels, ready := workloadPods(pod)
+	g.Expect(podLabels).To(Equal(map[string]string{"app": "test"}))
+	g.Expect(ready).To(BeEquivalentTo(1))
+
+	daemonSet := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "apps/v1",
+		"kind":       "DaemonSet",
+		"metadata":   map[string]interface{}{"name": "test"},
+		"spec": map[string]interface{}{
+			"template": map[string]interface{}{
+				"metadata": map[string]interface{}{"labels": map[string]interface{}{"app": "agent"}},
+			},
+		},
+		"status": map[string]interface{}{"numberReady": int64(5)},
+	}}
+	g.Expect(isPodWorkload(daemonSet)).To(BeTrue())
+	podLabels, ready = workloadPods(daemonSet)
+	g.Expect(podLabels).To(Equal(map[string]string{"app": "agent"}))
+	g.Expect(ready).To(BeEquivalentTo(5))
+
+	job := &unstructured.Unstructured{}
+	job.SetGroupVersionKind(schema.GroupVersionKind{Group: "batch", Version: "v1", Kind: "Job"})
+	g.Expect(isPodWorkload(job)).To(BeFalse())
+}
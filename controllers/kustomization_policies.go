@@ -0,0 +1,129 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+	"sort"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/cli-utils/pkg/object"
+
+	"github.com/fluxcd/pkg/ssa"
+
+	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta2"
+)
+
+// objectPolicyMarkers maps the object policies to the label or annotation that sets them.
+var objectPolicyMarkers = map[string][2]string{
+	kustomizev1.SkipObjectPolicy:          {fmt.Sprintf("%s/reconcile", kustomizev1.GroupVersion.Group), kustomizev1.DisabledValue},
+	kustomizev1.DryRunObjectPolicy:        {fmt.Sprintf("%s/dry-run", kustomizev1.GroupVersion.Group), kustomizev1.EnabledValue},
+	kustomizev1.ForceObjectPolicy:         {fmt.Sprintf("%s/force", kustomizev1.GroupVersion.Group), kustomizev1.EnabledValue},
+	kustomizev1.PruneDisabledObjectPolicy: {fmt.Sprintf("%s/prune", kustomizev1.GroupVersion.Group), kustomizev1.DisabledValue},
+}
+
+// hasObjectPolicy returns true if the object is labeled or annotated with the given policy.
+func hasObjectPolicy(obj *unstructured.Unstructured, policy string) bool {
+	marker, ok := objectPolicyMarkers[policy]
+	if !ok {
+		return false
+	}
+	key, value := marker[0], marker[1]
+	return obj.GetLabels()[key] == value || obj.GetAnnotations()[key] == value
+}
+
+// objectPolicies groups the objects by the reconcile policies they carry.
+func objectPolicies(objects []*unstructured.Unstructured) map[string][]*unstructured.Unstructured {
+	result := make(map[string][]*unstructured.Unstructured)
+	for _, obj := range objects {
+		for policy := range objectPolicyMarkers {
+			if hasObjectPolicy(obj, policy) {
+				result[policy] = append(result[policy], obj)
+			}
+		}
+	}
+	return result
+}
+
+// checkObjectPolicies returns an error listing the objects that carry
+// a policy which is not in the allowed list. An empty list allows all policies.
+func checkObjectPolicies(policies map[string][]*unstructured.Unstructured, allowed []string) error {
+	if len(allowed) == 0 {
+		return nil
+	}
+
+	isAllowed := make(map[string]bool, len(allowed))
+	for _, policy := range allowed {
+		isAllowed[policy] = true
+	}
+
+	var denied []*unstructured.Unstructured
+	var names []string
+	for policy, objects := range policies {
+		if !isAllowed[policy] {
+			names = append(names, policy)
+			denied = append(denied, objects...)
+		}
+	}
+
+	if len(denied) > 0 {
+		sort.Strings(names)
+		return fmt.Errorf("objects carry policies %v which are not allowed by the controller\n%s",
+			names, ssa.FmtUnstructuredList(denied))
+	}
+	return nil
+}
+
+// NewObjectPolicies returns the status entries of the given object
+// policies, sorted by policy name and object ID.
+func NewObjectPolicies(policies map[string][]*unstructured.Unstructured) []kustomizev1.ObjectPolicy {
+	var result []kustomizev1.ObjectPolicy
+	for policy, objects := range policies {
+		entries := objectsToResourceRefs(objects)
+		sort.Slice(entries, func(i, j int) bool {
+			return entries[i].ID < entries[j].ID
+		})
+		result = append(result, kustomizev1.ObjectPolicy{
+			Policy:  policy,
+			Entries: entries,
+		})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Policy < result[j].Policy
+	})
+	return result
+}
+
+// withoutObjects returns the objects that are not present in the exclusion list.
+func withoutObjects(objects []*unstructured.Unstructured, exclude []*unstructured.Unstructured) []*unstructured.Unstructured {
+	if len(exclude) == 0 {
+		return objects
+	}
+
+	excluded := make(map[string]bool, len(exclude))
+	for _, obj := range exclude {
+		excluded[object.UnstructuredToObjMetadata(obj).String()] = true
+	}
+
+	var result []*unstructured.Unstructured
+	for _, obj := range objects {
+		if !excluded[object.UnstructuredToObjMetadata(obj).String()] {
+			result = append(result, obj)
+		}
+	}
+	return result
+}
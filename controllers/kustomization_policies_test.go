@@ -0,0 +1,76 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta2"
+)
+
+func Test_objectPolicies(t *testing.T) {
+	g := NewWithT(t)
+
+	newConfigMap := func(name string, labels, annotations map[string]string) *unstructured.Unstructured {
+		u := &unstructured.Unstructured{}
+		u.SetAPIVersion("v1")
+		u.SetKind("ConfigMap")
+		u.SetNamespace("apps")
+		u.SetName(name)
+		u.SetLabels(labels)
+		u.SetAnnotations(annotations)
+		return u
+	}
+
+	objects := []*unstructured.Unstructured{
+		newConfigMap("plain", nil, nil),
+		newConfigMap("forced", nil, map[string]string{"kustomize.toolkit.fluxcd.io/force": "enabled"}),
+		newConfigMap("preview", map[string]string{"kustomize.toolkit.fluxcd.io/dry-run": "enabled"}, nil),
+		newConfigMap("kept", nil, map[string]string{
+			"kustomize.toolkit.fluxcd.io/prune": "disabled",
+			"kustomize.toolkit.fluxcd.io/force": "enabled",
+		}),
+	}
+
+	policies := objectPolicies(objects)
+	g.Expect(policies).To(HaveLen(3))
+	g.Expect(policies[kustomizev1.ForceObjectPolicy]).To(HaveLen(2))
+	g.Expect(policies[kustomizev1.DryRunObjectPolicy]).To(HaveLen(1))
+	g.Expect(policies[kustomizev1.PruneDisabledObjectPolicy]).To(HaveLen(1))
+
+	status := NewObjectPolicies(policies)
+	g.Expect(status).To(HaveLen(3))
+	g.Expect(status[0].Policy).To(Equal(kustomizev1.DryRunObjectPolicy))
+	g.Expect(status[1].Policy).To(Equal(kustomizev1.ForceObjectPolicy))
+	g.Expect(status[1].Entries[0].ID).To(Equal("apps_forced__ConfigMap"))
+	g.Expect(status[1].Entries[1].ID).To(Equal("apps_kept__ConfigMap"))
+
+	g.Expect(checkObjectPolicies(policies, nil)).To(Succeed())
+	g.Expect(checkObjectPolicies(policies, []string{
+		kustomizev1.DryRunObjectPolicy,
+		kustomizev1.ForceObjectPolicy,
+		kustomizev1.PruneDisabledObjectPolicy,
+	})).To(Succeed())
+	err := checkObjectPolicies(policies, []string{kustomizev1.PruneDisabledObjectPolicy})
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(err.Error()).To(ContainSubstring("ConfigMap/apps/preview"))
+
+	g.Expect(withoutObjects(objects, policies[kustomizev1.DryRunObjectPolicy])).To(HaveLen(3))
+}
@@ -0,0 +1,49 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta2"
+)
+
+// recordPromotionPending sets the PromotionPendingCondition on the Kustomization
+// when the source revision differs from the revision promoted by a Pipeline, and
+// removes it otherwise. The Ready condition is left as is, as the promoted revision
+// is still applied. It returns true if the source revision has just become pending.
+func recordPromotionPending(kustomization *kustomizev1.Kustomization, revision string) bool {
+	promoted, ok := kustomization.GetAnnotations()[kustomizev1.PromotedRevisionAnnotation]
+	if !ok || promoted == revision {
+		apimeta.RemoveStatusCondition(kustomization.GetStatusConditions(), kustomizev1.PromotionPendingCondition)
+		return false
+	}
+
+	msg := fmt.Sprintf("Source revision '%s' has not been promoted, the promoted revision is '%s'", revision, promoted)
+	prev := apimeta.FindStatusCondition(kustomization.Status.Conditions, kustomizev1.PromotionPendingCondition)
+	apimeta.SetStatusCondition(kustomization.GetStatusConditions(), metav1.Condition{
+		Type:               kustomizev1.PromotionPendingCondition,
+		Status:             metav1.ConditionTrue,
+		Reason:             kustomizev1.PromotionPendingReason,
+		Message:            msg,
+		ObservedGeneration: kustomization.Generation,
+	})
+	return prev == nil || prev.Message != msg
+}
@@ -0,0 +1,58 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta2"
+)
+
+func Test_recordPromotionPending(t *testing.T) {
+	g := NewWithT(t)
+
+	k := &kustomizev1.Kustomization{ObjectMeta: metav1.ObjectMeta{Namespace: "apps", Name: "prod"}}
+
+	// not managed by a Pipeline
+	g.Expect(recordPromotionPending(k, "main/2")).To(BeFalse())
+	g.Expect(k.Status.Conditions).To(BeEmpty())
+
+	// the source revision is pending promotion
+	k.SetAnnotations(map[string]string{kustomizev1.PromotedRevisionAnnotation: "main/1"})
+	g.Expect(recordPromotionPending(k, "main/2")).To(BeTrue())
+	pending := apimeta.FindStatusCondition(k.Status.Conditions, kustomizev1.PromotionPendingCondition)
+	g.Expect(pending).NotTo(BeNil())
+	g.Expect(pending.Status).To(Equal(metav1.ConditionTrue))
+	g.Expect(pending.Reason).To(Equal(kustomizev1.PromotionPendingReason))
+	g.Expect(pending.Message).To(ContainSubstring("the promoted revision is 'main/1'"))
+
+	// the same revision is reported once
+	g.Expect(recordPromotionPending(k, "main/2")).To(BeFalse())
+	g.Expect(apimeta.IsStatusConditionTrue(k.Status.Conditions, kustomizev1.PromotionPendingCondition)).To(BeTrue())
+
+	// a new source revision
+	g.Expect(recordPromotionPending(k, "main/3")).To(BeTrue())
+
+	// the source revision is promoted
+	k.SetAnnotations(map[string]string{kustomizev1.PromotedRevisionAnnotation: "main/3"})
+	g.Expect(recordPromotionPending(k, "main/3")).To(BeFalse())
+	g.Expect(apimeta.FindStatusCondition(k.Status.Conditions, kustomizev1.PromotionPendingCondition)).To(BeNil())
+}
@@ -0,0 +1,124 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+
+	"github.com/fluxcd/pkg/apis/kustomize"
+	sourcev1 "github.com/fluxcd/source-controller/api/v1beta2"
+
+	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta2"
+)
+
+// buildSpec contains the fields of the Kustomization spec
+// the kustomization.yaml of the build is generated from.
+type buildSpec struct {
+	Path                      string                            `json:"path"`
+	Sources                   []kustomizev1.SourceMount         `json:"sources,omitempty"`
+	ArtifactFilter            *kustomizev1.ArtifactFilter       `json:"artifactFilter,omitempty"`
+	TargetNamespace           string                            `json:"targetNamespace,omitempty"`
+	TargetNamespaceExemptions []kustomize.Selector              `json:"targetNamespaceExemptions,omitempty"`
+	Patches                   []kustomize.Patch                 `json:"patches,omitempty"`
+	PatchOptions              *kustomizev1.PatchOptions         `json:"patchOptions,omitempty"`
+	PatchesStrategicMerge     []apiextensionsv1.JSON            `json:"patchesStrategicMerge,omitempty"`
+	PatchesJSON6902           []kustomize.JSON6902Patch         `json:"patchesJson6902,omitempty"`
+	Images                    []kustomize.Image                 `json:"images,omitempty"`
+	ImagePolicies             []kustomizev1.ImagePolicyOverride `json:"imagePolicies,omitempty"`
+	ConfigMapGenerator        []kustomizev1.GeneratorArgs       `json:"configMapGenerator,omitempty"`
+	SecretGenerator           []kustomizev1.SecretGeneratorArgs `json:"secretGenerator,omitempty"`
+	BuildOptions              *kustomizev1.BuildOptions         `json:"buildOptions,omitempty"`
+}
+
+// buildInputs are the inputs resolved by the build that take part in
+// the build inputs digest.
+type buildInputs struct {
+	// vars are the values of the substituted variables.
+	vars map[string]string
+	// decryptionKeys is the version of the decryption Secret, empty
+	// if the keys are not read from a Secret.
+	decryptionKeys string
+}
+
+// buildInputsDigest returns the digest of the inputs of the Kustomization build:
+// the checksums of the source artifacts, the spec fields the kustomization.yaml
+// is generated from, the values of the substituted variables as resolved by
+// the build, and the version of the decryption Secret. The decryption keys are
+// left out, and so are the versions of the objects the variables are read from,
+// so that two clusters applying the same configuration without decryption
+// report the same digest.
+func (r *KustomizationReconciler) buildInputsDigest(ctx context.Context, kustomization kustomizev1.Kustomization,
+	source sourcev1.Source, inputs *buildInputs) (string, error) {
+	h := sha256.New()
+
+	if err := r.writeArtifactChecksums(ctx, h, kustomization, source); err != nil {
+		return "", err
+	}
+
+	spec, err := json.Marshal(buildSpec{
+		Path:                      kustomization.Spec.Path,
+		Sources:                   kustomization.Spec.Sources,
+		ArtifactFilter:            kustomization.Spec.ArtifactFilter,
+		TargetNamespace:           kustomization.Spec.TargetNamespace,
+		TargetNamespaceExemptions: kustomization.Spec.TargetNamespaceExemptions,
+		Patches:                   kustomization.Spec.Patches,
+		PatchOptions:              kustomization.Spec.PatchOptions,
+		PatchesStrategicMerge:     kustomization.Spec.PatchesStrategicMerge,
+		PatchesJSON6902:           kustomization.Spec.PatchesJSON6902,
+		Images:                    kustomization.Spec.Images,
+		ImagePolicies:             kustomization.Spec.ImagePolicies,
+		ConfigMapGenerator:        kustomization.Spec.ConfigMapGenerator,
+		SecretGenerator:           kustomization.Spec.SecretGenerator,
+		BuildOptions:              kustomization.Spec.BuildOptions,
+	})
+	if err != nil {
+		return "", err
+	}
+	fmt.Fprintf(h, "spec:%s\n", spec)
+
+	writeSortedDigests(h, "var", inputs.vars)
+
+	if d := kustomization.Spec.Decryption; d != nil {
+		fmt.Fprintf(h, "decryption:%s\n", d.Provider)
+		if inputs.decryptionKeys != "" {
+			fmt.Fprintf(h, "keys:%s\n", inputs.decryptionKeys)
+		}
+	}
+
+	return fmt.Sprintf("sha256:%x", h.Sum(nil)), nil
+}
+
+// writeSortedDigests writes to the hash the sorted names of the entries
+// along with the digests of their values, for the values not to be
+// recoverable from the hash inputs.
+func writeSortedDigests(w io.Writer, prefix string, entries map[string]string) {
+	names := make([]string, 0, len(entries))
+	for name := range entries {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(w, "%s:%s=%x\n", prefix, name, sha256.Sum256([]byte(entries[name])))
+	}
+}
@@ -0,0 +1,98 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/fluxcd/pkg/apis/meta"
+	sourcev1 "github.com/fluxcd/source-controller/api/v1beta2"
+
+	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta2"
+)
+
+func TestKustomizationReconciler_buildInputsDigest(t *testing.T) {
+	g := NewWithT(t)
+
+	scheme := runtime.NewScheme()
+	g.Expect(corev1.AddToScheme(scheme)).To(Succeed())
+	kubeClient := fake.NewClientBuilder().WithScheme(scheme).Build()
+	r := &KustomizationReconciler{Client: kubeClient}
+
+	k := kustomizev1.Kustomization{
+		ObjectMeta: metav1.ObjectMeta{Name: "apps", Namespace: "apps"},
+		Spec: kustomizev1.KustomizationSpec{
+			Interval: metav1.Duration{Duration: time.Minute},
+			Path:     "./deploy",
+			PostBuild: &kustomizev1.PostBuild{
+				SubstituteFrom: []kustomizev1.SubstituteReference{{Kind: "ConfigMap", Name: "vars"}},
+			},
+			Decryption: &kustomizev1.Decryption{Provider: "sops", SecretRef: &meta.LocalObjectReference{Name: "sops"}},
+		},
+	}
+	source := &sourcev1.GitRepository{}
+	source.Status.Artifact = &sourcev1.Artifact{Revision: "main/1", Checksum: "1"}
+	inputs := &buildInputs{
+		vars:           map[string]string{"cluster": "staging", "region": "eu"},
+		decryptionKeys: "uid/1",
+	}
+
+	digest, err := r.buildInputsDigest(context.TODO(), k, source, inputs)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(digest).To(HavePrefix("sha256:"))
+
+	// the fields that don't take part in the build are left out
+	k.Spec.Interval = metav1.Duration{Duration: time.Hour}
+	same, err := r.buildInputsDigest(context.TODO(), k, source, inputs)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(same).To(Equal(digest))
+
+	// a change of the substitution variables
+	inputs.vars["cluster"] = "production"
+	changed, err := r.buildInputsDigest(context.TODO(), k, source, inputs)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(changed).NotTo(Equal(digest))
+	digest = changed
+
+	// a change of the decryption Secret
+	inputs.decryptionKeys = "uid/2"
+	changed, err = r.buildInputsDigest(context.TODO(), k, source, inputs)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(changed).NotTo(Equal(digest))
+	digest = changed
+
+	// a new artifact
+	source.Status.Artifact.Checksum = "2"
+	changed, err = r.buildInputsDigest(context.TODO(), k, source, inputs)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(changed).NotTo(Equal(digest))
+	digest = changed
+
+	// a change of the spec
+	k.Spec.TargetNamespace = "production"
+	changed, err = r.buildInputsDigest(context.TODO(), k, source, inputs)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(changed).NotTo(Equal(digest))
+}
@@ -0,0 +1,156 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	utilnet "k8s.io/apimachinery/pkg/util/net"
+	corev1client "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/transport"
+	"sigs.k8s.io/controller-runtime/pkg/client/config"
+
+	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta2"
+)
+
+// defaultTokenExpirationSeconds is the validity of the service account tokens
+// requested for a kubeconfig, when not set in the spec.
+const defaultTokenExpirationSeconds int64 = 3600
+
+// headerRoundTripper sets extra headers on the requests sent to the API server.
+type headerRoundTripper struct {
+	headers map[string]string
+	rt      http.RoundTripper
+}
+
+func (rt *headerRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = utilnet.CloneRequest(req)
+	for k, v := range rt.headers {
+		req.Header.Set(k, v)
+	}
+	return rt.rt.RoundTrip(req)
+}
+
+// setKubeConfigProxy configures the REST config to authenticate with the given
+// bearer token instead of the kubeconfig credentials, if not empty, and to
+// send the given headers with each request.
+func setKubeConfigProxy(restConfig *rest.Config, token string, headers map[string]string) {
+	if token != "" {
+		restConfig.BearerToken = token
+		restConfig.BearerTokenFile = ""
+		restConfig.Username = ""
+		restConfig.Password = ""
+		restConfig.AuthProvider = nil
+		restConfig.ExecProvider = nil
+	}
+	if len(headers) > 0 {
+		restConfig.WrapTransport = transport.Wrappers(restConfig.WrapTransport, func(rt http.RoundTripper) http.RoundTripper {
+			return &headerRoundTripper{headers: headers, rt: rt}
+		})
+	}
+}
+
+// requestServiceAccountToken returns a token of the service account issued
+// for the audiences of the spec.
+func requestServiceAccountToken(ctx context.Context, serviceAccounts corev1client.ServiceAccountsGetter,
+	namespace string, spec kustomizev1.ServiceAccountToken) (string, error) {
+	expiration := defaultTokenExpirationSeconds
+	if spec.ExpirationSeconds != nil {
+		expiration = *spec.ExpirationSeconds
+	}
+
+	tokenRequest := &authenticationv1.TokenRequest{
+		Spec: authenticationv1.TokenRequestSpec{
+			Audiences:         spec.Audiences,
+			ExpirationSeconds: &expiration,
+		},
+	}
+	result, err := serviceAccounts.ServiceAccounts(namespace).CreateToken(ctx, spec.Name, tokenRequest, metav1.CreateOptions{})
+	if err != nil {
+		return "", fmt.Errorf("unable to request a token for service account '%s/%s': %w", namespace, spec.Name, err)
+	}
+	return result.Status.Token, nil
+}
+
+// configureKubeConfigProxy sets the service account token and the extra headers
+// of spec.kubeConfig on the REST config of the remote cluster.
+func (ki *KustomizeImpersonation) configureKubeConfigProxy(ctx context.Context, restConfig *rest.Config) error {
+	kubeConfig := ki.kustomization.Spec.KubeConfig
+	if kubeConfig.ServiceAccountToken == nil && len(kubeConfig.Headers) == 0 && kubeConfig.HeadersSecretRef == nil {
+		return nil
+	}
+
+	var token string
+	if kubeConfig.ServiceAccountToken != nil {
+		serviceAccounts := ki.serviceAccounts
+		if serviceAccounts == nil {
+			localConfig, err := config.GetConfig()
+			if err != nil {
+				return err
+			}
+			if serviceAccounts, err = corev1client.NewForConfig(localConfig); err != nil {
+				return err
+			}
+		}
+
+		var err error
+		token, err = requestServiceAccountToken(ctx, serviceAccounts, ki.kustomization.GetNamespace(), *kubeConfig.ServiceAccountToken)
+		if err != nil {
+			return err
+		}
+	}
+
+	headers, err := ki.getKubeConfigHeaders(ctx)
+	if err != nil {
+		return err
+	}
+
+	setKubeConfigProxy(restConfig, token, headers)
+	return nil
+}
+
+// getKubeConfigHeaders returns the headers of spec.kubeConfig, merged with
+// the key/value pairs of the headers secret.
+func (ki *KustomizeImpersonation) getKubeConfigHeaders(ctx context.Context) (map[string]string, error) {
+	kubeConfig := ki.kustomization.Spec.KubeConfig
+	headers := mergeStringMaps(nil, kubeConfig.Headers)
+	if kubeConfig.HeadersSecretRef == nil {
+		return headers, nil
+	}
+
+	secretName := types.NamespacedName{
+		Namespace: ki.kustomization.GetNamespace(),
+		Name:      kubeConfig.HeadersSecretRef.Name,
+	}
+	var secret corev1.Secret
+	if err := ki.Get(ctx, secretName, &secret); err != nil {
+		return nil, fmt.Errorf("unable to read KubeConfig headers secret '%s' error: %w", secretName.String(), err)
+	}
+
+	values := make(map[string]string, len(secret.Data))
+	for k, v := range secret.Data {
+		values[k] = string(v)
+	}
+	return mergeStringMaps(headers, values), nil
+}
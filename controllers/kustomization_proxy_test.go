@@ -0,0 +1,132 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/rest"
+	k8stesting "k8s.io/client-go/testing"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/fluxcd/pkg/apis/meta"
+
+	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta2"
+)
+
+func TestSetKubeConfigProxy(t *testing.T) {
+	g := NewWithT(t)
+
+	var received http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r.Header.Clone()
+	}))
+	defer server.Close()
+
+	restConfig := &rest.Config{
+		Host:     server.URL,
+		Username: "admin",
+		Password: "secret",
+	}
+	setKubeConfigProxy(restConfig, "sa-token", map[string]string{"X-Cluster": "eu-1"})
+	g.Expect(restConfig.Username).To(BeEmpty())
+	g.Expect(restConfig.Password).To(BeEmpty())
+
+	rt, err := rest.TransportFor(restConfig)
+	g.Expect(err).NotTo(HaveOccurred())
+	req, err := http.NewRequest(http.MethodGet, server.URL+"/version", nil)
+	g.Expect(err).NotTo(HaveOccurred())
+	resp, err := rt.RoundTrip(req)
+	g.Expect(err).NotTo(HaveOccurred())
+	resp.Body.Close()
+
+	g.Expect(received.Get("Authorization")).To(Equal("Bearer sa-token"))
+	g.Expect(received.Get("X-Cluster")).To(Equal("eu-1"))
+	g.Expect(req.Header.Get("X-Cluster")).To(BeEmpty())
+}
+
+func TestRequestServiceAccountToken(t *testing.T) {
+	g := NewWithT(t)
+
+	var requested *authenticationv1.TokenRequest
+	clientset := kubefake.NewSimpleClientset()
+	clientset.PrependReactor("create", "serviceaccounts", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		create := action.(k8stesting.CreateAction)
+		if create.GetSubresource() != "token" {
+			return false, nil, nil
+		}
+		requested = create.GetObject().(*authenticationv1.TokenRequest)
+		result := requested.DeepCopy()
+		result.Status.Token = "sa-token"
+		return true, result, nil
+	})
+
+	token, err := requestServiceAccountToken(context.TODO(), clientset.CoreV1(), "flux-system",
+		kustomizev1.ServiceAccountToken{Name: "proxy", Audiences: []string{"teleport.example.com"}})
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(token).To(Equal("sa-token"))
+	g.Expect(requested.Spec.Audiences).To(Equal([]string{"teleport.example.com"}))
+	g.Expect(*requested.Spec.ExpirationSeconds).To(Equal(defaultTokenExpirationSeconds))
+}
+
+func TestKustomizeImpersonation_GetKubeConfigHeaders(t *testing.T) {
+	g := NewWithT(t)
+
+	scheme := runtime.NewScheme()
+	g.Expect(corev1.AddToScheme(scheme)).To(Succeed())
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "proxy-headers", Namespace: "flux-system"},
+		Data:       map[string][]byte{"X-Boundary-Token": []byte("at_123"), "X-Cluster": []byte("eu-2")},
+	}
+	kubeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(secret).Build()
+
+	kustomization := kustomizev1.Kustomization{
+		ObjectMeta: metav1.ObjectMeta{Name: "apps", Namespace: "flux-system"},
+		Spec: kustomizev1.KustomizationSpec{
+			KubeConfig: &kustomizev1.KubeConfig{
+				SecretRef:        meta.SecretKeyReference{Name: "kubeconfig"},
+				Headers:          map[string]string{"X-Cluster": "eu-1", "X-Tenant": "apps"},
+				HeadersSecretRef: &meta.LocalObjectReference{Name: "proxy-headers"},
+			},
+		},
+	}
+	ki := &KustomizeImpersonation{Client: kubeClient, kustomization: kustomization}
+
+	headers, err := ki.getKubeConfigHeaders(context.TODO())
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(headers).To(Equal(map[string]string{
+		"X-Boundary-Token": "at_123",
+		"X-Cluster":        "eu-2",
+		"X-Tenant":         "apps",
+	}))
+	// the spec is not modified
+	g.Expect(kustomization.Spec.KubeConfig.Headers["X-Cluster"]).To(Equal("eu-1"))
+
+	kustomization.Spec.KubeConfig.HeadersSecretRef.Name = "missing"
+	ki.kustomization = kustomization
+	_, err = ki.getKubeConfigHeaders(context.TODO())
+	g.Expect(err).To(MatchError(ContainSubstring("unable to read KubeConfig headers secret")))
+}
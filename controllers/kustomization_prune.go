@@ -0,0 +1,356 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/discovery"
+	"sigs.k8s.io/cli-utils/pkg/object"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/fluxcd/pkg/ssa"
+
+	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta2"
+)
+
+// emptyNamespaces returns the sorted list of namespaces that contained
+// at least one of the pruned objects, or were pruned themselves, and that
+// no longer hold any object from the given inventory. Namespaces in the deny
+// list, the namespace of the Kustomization itself and namespaces that are
+// declared in the inventory are excluded.
+func emptyNamespaces(kustomization kustomizev1.Kustomization, pruned []*unstructured.Unstructured,
+	inventory *kustomizev1.ResourceInventory, denyList []string) ([]string, error) {
+	excluded := map[string]bool{
+		kustomization.GetNamespace(): true,
+	}
+	for _, ns := range denyList {
+		excluded[ns] = true
+	}
+
+	if inventory != nil {
+		metas, err := ListMetaInInventory(inventory)
+		if err != nil {
+			return nil, err
+		}
+		for _, m := range metas {
+			if m.Namespace != "" {
+				excluded[m.Namespace] = true
+			}
+			if m.GroupKind.Group == "" && m.GroupKind.Kind == "Namespace" {
+				excluded[m.Name] = true
+			}
+		}
+	}
+
+	candidates := make(map[string]bool)
+	for _, obj := range pruned {
+		ns := obj.GetNamespace()
+		if obj.GetAPIVersion() == "v1" && obj.GetKind() == "Namespace" {
+			ns = obj.GetName()
+		}
+		if ns != "" && !excluded[ns] {
+			candidates[ns] = true
+		}
+	}
+
+	result := make([]string, 0, len(candidates))
+	for ns := range candidates {
+		result = append(result, ns)
+	}
+	sort.Strings(result)
+	return result, nil
+}
+
+// pruneEmptyNamespaces deletes the namespaces created by the Kustomization
+// that became empty after garbage collection. A namespace is considered to be
+// created by the Kustomization if it carries the Kustomization owner labels.
+// Namespaces with pruning or reconciliation disabled are never deleted.
+func (r *KustomizationReconciler) pruneEmptyNamespaces(ctx context.Context,
+	kubeClient client.Client,
+	impersonation *KustomizeImpersonation,
+	manager *ssa.ResourceManager,
+	kustomization kustomizev1.Kustomization,
+	pruned []*unstructured.Unstructured,
+	inventory *kustomizev1.ResourceInventory) (string, error) {
+	if kustomization.Spec.PruneOptions == nil || !kustomization.Spec.PruneOptions.DeleteEmptyNamespaces ||
+		ownerLabelsDisabled(kustomization) {
+		return "", nil
+	}
+
+	namespaces, err := emptyNamespaces(kustomization, pruned, inventory, r.PruneNamespaceDenyList)
+	if err != nil {
+		return "", err
+	}
+	if len(namespaces) == 0 {
+		return "", nil
+	}
+
+	owner := ownerLabels(manager, kustomization)
+	exclusions := map[string]string{
+		fmt.Sprintf("%s/prune", kustomizev1.GroupVersion.Group):     kustomizev1.DisabledValue,
+		fmt.Sprintf("%s/reconcile", kustomizev1.GroupVersion.Group): kustomizev1.DisabledValue,
+	}
+
+	var resources []schema.GroupVersionKind
+	var changeLog strings.Builder
+	for _, name := range namespaces {
+		ns := &corev1.Namespace{}
+		if err := kubeClient.Get(ctx, types.NamespacedName{Name: name}, ns); err != nil {
+			if apierrors.IsNotFound(err) {
+				continue
+			}
+			return "", fmt.Errorf("failed to get namespace '%s': %w", name, err)
+		}
+
+		if !ns.DeletionTimestamp.IsZero() ||
+			!hasLabels(ns.GetLabels(), owner) ||
+			hasAnyLabelOrAnnotation(ns.GetLabels(), ns.GetAnnotations(), exclusions) {
+			continue
+		}
+
+		// discover the namespaced kinds only when there is a namespace to delete
+		if resources == nil {
+			discoveryClient, err := impersonation.GetDiscoveryClient(ctx)
+			if err != nil {
+				return "", fmt.Errorf("failed to create discovery client: %w", err)
+			}
+			resources, err = namespacedResources(discoveryClient)
+			if err != nil {
+				return "", err
+			}
+		}
+
+		empty, err := namespaceIsEmpty(ctx, kubeClient, resources, name)
+		if err != nil {
+			return "", err
+		}
+		if !empty {
+			continue
+		}
+
+		if err := kubeClient.Delete(ctx, ns, client.PropagationPolicy(metav1.DeletePropagationBackground)); err != nil {
+			if apierrors.IsNotFound(err) {
+				continue
+			}
+			return "", fmt.Errorf("failed to delete empty namespace '%s': %w", name, err)
+		}
+		changeLog.WriteString(fmt.Sprintf("Namespace/%s deleted\n", name))
+	}
+
+	return strings.TrimSuffix(changeLog.String(), "\n"), nil
+}
+
+// namespacedResources returns the namespaced kinds served by the API server
+// that can be listed. The discovery must succeed for all the API groups,
+// otherwise the content of a namespace can't be fully verified.
+func namespacedResources(discoveryClient discovery.DiscoveryInterface) ([]schema.GroupVersionKind, error) {
+	lists, err := discoveryClient.ServerPreferredNamespacedResources()
+	if err != nil {
+		return nil, fmt.Errorf("failed to discover the namespaced API resources: %w", err)
+	}
+
+	var result []schema.GroupVersionKind
+	for _, list := range lists {
+		gv, err := schema.ParseGroupVersion(list.GroupVersion)
+		if err != nil {
+			return nil, err
+		}
+		for _, resource := range list.APIResources {
+			if strings.Contains(resource.Name, "/") || !sets.NewString(resource.Verbs...).Has("list") {
+				continue
+			}
+			result = append(result, gv.WithKind(resource.Kind))
+		}
+	}
+	return result, nil
+}
+
+// namespaceIsEmpty returns true if the namespace holds no objects other than the
+// ones created by Kubernetes in every namespace, or the ones being deleted.
+func namespaceIsEmpty(ctx context.Context, kubeClient client.Client, resources []schema.GroupVersionKind, namespace string) (bool, error) {
+	for _, gvk := range resources {
+		list := &metav1.PartialObjectMetadataList{}
+		list.SetGroupVersionKind(gvk.GroupVersion().WithKind(gvk.Kind + "List"))
+		if err := kubeClient.List(ctx, list, client.InNamespace(namespace)); err != nil {
+			if apierrors.IsNotFound(err) || apierrors.IsMethodNotSupported(err) {
+				continue
+			}
+			return false, fmt.Errorf("failed to list %s in namespace '%s': %w", gvk.Kind, namespace, err)
+		}
+		for _, item := range list.Items {
+			if item.DeletionTimestamp.IsZero() && !isNamespaceDefault(gvk, item.GetName()) {
+				return false, nil
+			}
+		}
+	}
+	return true, nil
+}
+
+// isNamespaceDefault returns true for the objects that Kubernetes
+// creates or records in every namespace.
+func isNamespaceDefault(gvk schema.GroupVersionKind, name string) bool {
+	switch {
+	case gvk.Kind == "Event":
+		return true
+	case gvk.Group == "" && gvk.Kind == "ServiceAccount" && name == "default":
+		return true
+	case gvk.Group == "" && gvk.Kind == "ConfigMap" && name == "kube-root-ca.crt":
+		return true
+	case gvk.Group == "" && gvk.Kind == "Secret" && strings.HasPrefix(name, "default-token-"):
+		return true
+	default:
+		return false
+	}
+}
+
+// hasLabels returns true if all the given key/value pairs are set in labels.
+func hasLabels(labels map[string]string, selector map[string]string) bool {
+	for k, v := range selector {
+		if labels[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+// hasAnyLabelOrAnnotation returns true if at least one of the given key/value pairs
+// is set in labels or annotations.
+func hasAnyLabelOrAnnotation(labels map[string]string, annotations map[string]string, selector map[string]string) bool {
+	for k, v := range selector {
+		if labels[k] == v || annotations[k] == v {
+			return true
+		}
+	}
+	return false
+}
+
+// maxPendingPruneEntries is the maximum number of objects
+// listed in the PendingPrune status and in the Ready condition message.
+const maxPendingPruneEntries = 100
+
+// NewPendingPrune returns the list of objects that are waiting
+// for approval to be garbage collected, along with their digest.
+// The digest is computed over all objects, while the entries are
+// truncated to maxPendingPruneEntries.
+func NewPendingPrune(revision string, objects []*unstructured.Unstructured) *kustomizev1.PendingPrune {
+	entries := objectsToResourceRefs(objects)
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].ID < entries[j].ID
+	})
+
+	hasher := sha256.New()
+	for _, entry := range entries {
+		hasher.Write([]byte(entry.ID + "\n"))
+	}
+
+	if len(entries) > maxPendingPruneEntries {
+		entries = entries[:maxPendingPruneEntries]
+	}
+
+	return &kustomizev1.PendingPrune{
+		Digest:   fmt.Sprintf("sha256:%x", hasher.Sum(nil)),
+		Revision: revision,
+		Entries:  entries,
+		Total:    len(objects),
+	}
+}
+
+// fmtTruncatedList returns the string representation of at most max objects,
+// followed by the number of objects left out.
+func fmtTruncatedList(objects []*unstructured.Unstructured, max int) string {
+	if len(objects) <= max {
+		return ssa.FmtUnstructuredList(objects)
+	}
+	return fmt.Sprintf("%s\n... and %d more", ssa.FmtUnstructuredList(objects[:max]), len(objects)-max)
+}
+
+// objectsToResourceRefs returns the inventory entries of the given objects.
+func objectsToResourceRefs(objects []*unstructured.Unstructured) []kustomizev1.ResourceRef {
+	entries := make([]kustomizev1.ResourceRef, 0, len(objects))
+	for _, obj := range objects {
+		entries = append(entries, kustomizev1.ResourceRef{
+			ID:      object.UnstructuredToObjMetadata(obj).String(),
+			Version: obj.GroupVersionKind().Version,
+		})
+	}
+	return entries
+}
+
+// requiresPruneApproval returns true if the number of stale objects exceeds
+// the approval threshold computed against the size of the current inventory.
+func requiresPruneApproval(kustomization kustomizev1.Kustomization, inventory *kustomizev1.ResourceInventory,
+	objects []*unstructured.Unstructured) (bool, error) {
+	if kustomization.Spec.PruneOptions == nil || kustomization.Spec.PruneOptions.ApprovalThreshold == nil {
+		return false, nil
+	}
+
+	total := len(objects)
+	if inventory != nil {
+		total = len(inventory.Entries)
+	}
+
+	threshold, err := intstr.GetScaledValueFromIntOrPercent(kustomization.Spec.PruneOptions.ApprovalThreshold, total, true)
+	if err != nil {
+		return false, fmt.Errorf("invalid prune approval threshold: %w", err)
+	}
+
+	return len(objects) > threshold, nil
+}
+
+// splitByPruneBudget splits the stale objects into the ones that can be deleted
+// in this reconciliation and the ones that exceed the disruption budget.
+func splitByPruneBudget(kustomization kustomizev1.Kustomization, inventory *kustomizev1.ResourceInventory,
+	objects []*unstructured.Unstructured) ([]*unstructured.Unstructured, []*unstructured.Unstructured, error) {
+	if kustomization.Spec.PruneOptions == nil || kustomization.Spec.PruneOptions.DisruptionBudget == nil {
+		return objects, nil, nil
+	}
+
+	total := len(objects)
+	if inventory != nil {
+		total = len(inventory.Entries)
+	}
+
+	// round up percentages, so that a small inventory is not blocked by a zero budget
+	budget, err := intstr.GetScaledValueFromIntOrPercent(&kustomization.Spec.PruneOptions.DisruptionBudget.MaxDeletions, total, true)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid prune disruption budget: %w", err)
+	}
+
+	// make progress at every reconciliation when splitting the garbage collection
+	if budget < 1 && kustomization.Spec.PruneOptions.DisruptionBudget.Policy != kustomizev1.BlockPrunePolicy {
+		budget = 1
+	}
+
+	if len(objects) <= budget {
+		return objects, nil, nil
+	}
+
+	return objects[:budget], objects[budget:], nil
+}
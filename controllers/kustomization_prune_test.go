@@ -19,6 +19,7 @@ package controllers
 import (
 	"context"
 	"fmt"
+	"strings"
 	"testing"
 	"time"
 
@@ -29,8 +30,11 @@ import (
 	. "github.com/onsi/gomega"
 	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
@@ -426,3 +430,457 @@ data:
 	})
 
 }
+
+func TestKustomizationReconciler_PruneEmptyNamespaces(t *testing.T) {
+	g := NewWithT(t)
+	id := "gc-" + randStringRunes(5)
+	revision := "v1.0.0"
+
+	err := createNamespace(id)
+	g.Expect(err).NotTo(HaveOccurred(), "failed to create test namespace")
+
+	err = createKubeConfigSecret(id)
+	g.Expect(err).NotTo(HaveOccurred(), "failed to create kubeconfig secret")
+
+	nsEmpty := id + "-empty"
+	nsNotEmpty := id + "-not-empty"
+	nsPruneDisabled := id + "-prune-disabled"
+	nsFinalize := id + "-finalize"
+
+	namespace := func(name string, labels string) string {
+		return fmt.Sprintf(`---
+apiVersion: v1
+kind: Namespace
+metadata:
+  name: %[1]s
+%[2]s`, name, labels)
+	}
+	configMap := func(namespace string) string {
+		return fmt.Sprintf(`---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: config
+  namespace: %[1]s
+data:
+  key: value
+`, namespace)
+	}
+
+	manifests := func(docs ...string) []testserver.File {
+		return []testserver.File{
+			{
+				Name: "manifests.yaml",
+				Body: strings.Join(docs, ""),
+			},
+		}
+	}
+
+	artifact, err := testServer.ArtifactFromFiles(manifests(
+		namespace(nsEmpty, ""),
+		namespace(nsNotEmpty, ""),
+		namespace(nsPruneDisabled, "  labels:\n    kustomize.toolkit.fluxcd.io/prune: disabled\n"),
+		namespace(nsFinalize, ""),
+		configMap(nsEmpty),
+		configMap(nsNotEmpty),
+		configMap(nsPruneDisabled),
+		configMap(nsFinalize),
+	))
+	g.Expect(err).NotTo(HaveOccurred())
+
+	repositoryName := types.NamespacedName{
+		Name:      fmt.Sprintf("gc-%s", randStringRunes(5)),
+		Namespace: id,
+	}
+
+	err = applyGitRepository(repositoryName, artifact, revision)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	kustomization := &kustomizev1.Kustomization{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fmt.Sprintf("gc-%s", randStringRunes(5)),
+			Namespace: id,
+		},
+		Spec: kustomizev1.KustomizationSpec{
+			Interval: metav1.Duration{Duration: reconciliationInterval},
+			Path:     "./",
+			KubeConfig: &kustomizev1.KubeConfig{
+				SecretRef: meta.SecretKeyReference{
+					Name: "kubeconfig",
+				},
+			},
+			SourceRef: kustomizev1.CrossNamespaceSourceReference{
+				Name:      repositoryName.Name,
+				Namespace: repositoryName.Namespace,
+				Kind:      sourcev1.GitRepositoryKind,
+			},
+			Prune: false,
+			PruneOptions: &kustomizev1.PruneOptions{
+				DeleteEmptyNamespaces: true,
+			},
+		},
+	}
+
+	g.Expect(k8sClient.Create(context.Background(), kustomization)).To(Succeed())
+
+	resultK := &kustomizev1.Kustomization{}
+	waitForRevision := func(revision string) {
+		g.Eventually(func() bool {
+			_ = k8sClient.Get(context.Background(), client.ObjectKeyFromObject(kustomization), resultK)
+			return resultK.Status.LastAppliedRevision == revision &&
+				resultK.Status.ObservedGeneration == resultK.GetGeneration()
+		}, timeout, time.Second).Should(BeTrue())
+	}
+	isTerminating := func(name string) bool {
+		ns := &corev1.Namespace{}
+		err := k8sClient.Get(context.Background(), types.NamespacedName{Name: name}, ns)
+		return apierrors.IsNotFound(err) || (err == nil && !ns.DeletionTimestamp.IsZero())
+	}
+
+	waitForRevision(revision)
+
+	// remove the namespaces from the inventory without garbage collecting them
+	artifact, err = testServer.ArtifactFromFiles(manifests(
+		configMap(nsEmpty),
+		configMap(nsNotEmpty),
+		configMap(nsPruneDisabled),
+		configMap(nsFinalize),
+	))
+	g.Expect(err).NotTo(HaveOccurred())
+	revision = "v2.0.0"
+	g.Expect(applyGitRepository(repositoryName, artifact, revision)).To(Succeed())
+	waitForRevision(revision)
+
+	g.Expect(k8sClient.Get(context.Background(), client.ObjectKeyFromObject(kustomization), resultK)).To(Succeed())
+	resultK.Spec.Prune = true
+	g.Expect(k8sClient.Update(context.Background(), resultK)).To(Succeed())
+	waitForRevision(revision)
+
+	extra := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "extra",
+			Namespace: nsNotEmpty,
+		},
+	}
+	g.Expect(k8sClient.Create(context.Background(), extra)).To(Succeed())
+
+	t.Run("deletes empty namespaces", func(t *testing.T) {
+		artifact, err := testServer.ArtifactFromFiles(manifests(configMap(nsFinalize)))
+		g.Expect(err).NotTo(HaveOccurred())
+		revision = "v3.0.0"
+		g.Expect(applyGitRepository(repositoryName, artifact, revision)).To(Succeed())
+		waitForRevision(revision)
+
+		g.Expect(isTerminating(nsEmpty)).To(BeTrue())
+	})
+
+	t.Run("preserves namespaces with objects", func(t *testing.T) {
+		g.Expect(isTerminating(nsNotEmpty)).To(BeFalse())
+		g.Expect(isTerminating(nsFinalize)).To(BeFalse())
+	})
+
+	t.Run("preserves namespaces with pruning disabled", func(t *testing.T) {
+		g.Expect(isTerminating(nsPruneDisabled)).To(BeFalse())
+	})
+
+	t.Run("deletes empty namespaces on finalize", func(t *testing.T) {
+		g.Expect(k8sClient.Delete(context.Background(), kustomization)).To(Succeed())
+		g.Eventually(func() bool {
+			err := k8sClient.Get(context.Background(), client.ObjectKeyFromObject(kustomization), kustomization)
+			return apierrors.IsNotFound(err)
+		}, timeout, time.Second).Should(BeTrue())
+
+		g.Expect(isTerminating(nsFinalize)).To(BeTrue())
+		g.Expect(isTerminating(nsNotEmpty)).To(BeFalse())
+	})
+}
+
+func TestKustomizationReconciler_PruneApproval(t *testing.T) {
+	g := NewWithT(t)
+	id := "gc-" + randStringRunes(5)
+	revision := "v1.0.0"
+
+	err := createNamespace(id)
+	g.Expect(err).NotTo(HaveOccurred(), "failed to create test namespace")
+
+	err = createKubeConfigSecret(id)
+	g.Expect(err).NotTo(HaveOccurred(), "failed to create kubeconfig secret")
+
+	manifests := func(names ...string) []testserver.File {
+		var files []testserver.File
+		for _, name := range names {
+			files = append(files, testserver.File{
+				Name: name + ".yaml",
+				Body: fmt.Sprintf(`---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: %[1]s
+data:
+  key: "%[1]s"
+`, name),
+			})
+		}
+		return files
+	}
+
+	artifact, err := testServer.ArtifactFromFiles(manifests("first", "second", "third"))
+	g.Expect(err).NotTo(HaveOccurred())
+
+	repositoryName := types.NamespacedName{
+		Name:      fmt.Sprintf("gc-%s", randStringRunes(5)),
+		Namespace: id,
+	}
+
+	err = applyGitRepository(repositoryName, artifact, revision)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	threshold := intstr.FromInt(1)
+	kustomization := &kustomizev1.Kustomization{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fmt.Sprintf("gc-%s", randStringRunes(5)),
+			Namespace: id,
+		},
+		Spec: kustomizev1.KustomizationSpec{
+			// reconcile only on source and annotation changes
+			Interval:      metav1.Duration{Duration: time.Hour},
+			RetryInterval: &metav1.Duration{Duration: time.Hour},
+			Path:          "./",
+			KubeConfig: &kustomizev1.KubeConfig{
+				SecretRef: meta.SecretKeyReference{
+					Name: "kubeconfig",
+				},
+			},
+			SourceRef: kustomizev1.CrossNamespaceSourceReference{
+				Name:      repositoryName.Name,
+				Namespace: repositoryName.Namespace,
+				Kind:      sourcev1.GitRepositoryKind,
+			},
+			TargetNamespace: id,
+			Prune:           true,
+			PruneOptions: &kustomizev1.PruneOptions{
+				ApprovalThreshold: &threshold,
+			},
+		},
+	}
+
+	g.Expect(k8sClient.Create(context.Background(), kustomization)).To(Succeed())
+
+	resultK := &kustomizev1.Kustomization{}
+	g.Eventually(func() bool {
+		_ = k8sClient.Get(context.Background(), client.ObjectKeyFromObject(kustomization), resultK)
+		return resultK.Status.LastAppliedRevision == revision
+	}, timeout, time.Second).Should(BeTrue())
+
+	configMapExists := func(name string) bool {
+		err := k8sClient.Get(context.Background(), types.NamespacedName{Name: name, Namespace: id}, &corev1.ConfigMap{})
+		return err == nil
+	}
+
+	t.Run("holds garbage collection", func(t *testing.T) {
+		artifact, err := testServer.ArtifactFromFiles(manifests("first"))
+		g.Expect(err).NotTo(HaveOccurred())
+		revision = "v2.0.0"
+		g.Expect(applyGitRepository(repositoryName, artifact, revision)).To(Succeed())
+
+		g.Eventually(func() bool {
+			_ = k8sClient.Get(context.Background(), client.ObjectKeyFromObject(kustomization), resultK)
+			return resultK.Status.PendingPrune != nil && resultK.Status.PendingPrune.Revision == revision
+		}, timeout, time.Second).Should(BeTrue())
+
+		ready := apimeta.FindStatusCondition(resultK.Status.Conditions, meta.ReadyCondition)
+		g.Expect(ready.Status).To(Equal(metav1.ConditionFalse))
+		g.Expect(ready.Reason).To(Equal(kustomizev1.PruneApprovalRequiredReason))
+		g.Expect(ready.Message).To(ContainSubstring(resultK.Status.PendingPrune.Digest))
+
+		g.Expect(resultK.Status.PendingPrune.Total).To(Equal(2))
+		g.Expect(resultK.Status.PendingPrune.Entries).To(HaveLen(2))
+		g.Expect(resultK.Status.Inventory.Entries).To(HaveLen(3))
+		g.Expect(configMapExists("second")).To(BeTrue())
+		g.Expect(configMapExists("third")).To(BeTrue())
+	})
+
+	t.Run("prunes once approved", func(t *testing.T) {
+		digest := resultK.Status.PendingPrune.Digest
+		patch := client.MergeFrom(resultK.DeepCopy())
+		resultK.SetAnnotations(map[string]string{
+			kustomizev1.PruneApprovalAnnotation: digest,
+		})
+		g.Expect(k8sClient.Patch(context.Background(), resultK, patch)).To(Succeed())
+
+		// the annotation change triggers the reconciliation without a new source revision
+		g.Eventually(func() bool {
+			_ = k8sClient.Get(context.Background(), client.ObjectKeyFromObject(kustomization), resultK)
+			return resultK.Status.LastAppliedRevision == revision
+		}, timeout, time.Second).Should(BeTrue())
+
+		g.Expect(resultK.Status.PendingPrune).To(BeNil())
+		g.Expect(resultK.Status.Inventory.Entries).To(HaveLen(1))
+		g.Expect(configMapExists("first")).To(BeTrue())
+		g.Expect(configMapExists("second")).To(BeFalse())
+		g.Expect(configMapExists("third")).To(BeFalse())
+	})
+}
+
+func Test_emptyNamespaces(t *testing.T) {
+	g := NewWithT(t)
+
+	kustomization := kustomizev1.Kustomization{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "tenants",
+			Namespace: "flux-system",
+		},
+	}
+
+	object := func(apiVersion, kind, namespace, name string) *unstructured.Unstructured {
+		u := &unstructured.Unstructured{}
+		u.SetAPIVersion(apiVersion)
+		u.SetKind(kind)
+		u.SetNamespace(namespace)
+		u.SetName(name)
+		return u
+	}
+
+	pruned := []*unstructured.Unstructured{
+		object("v1", "ConfigMap", "tenant-a", "config"),
+		object("v1", "ConfigMap", "tenant-b", "config"),
+		object("v1", "Namespace", "", "tenant-c"),
+		object("v1", "ConfigMap", "kube-system", "config"),
+		object("v1", "ConfigMap", "flux-system", "config"),
+		object("v1", "ConfigMap", "tenant-d", "config"),
+	}
+
+	inventory := &kustomizev1.ResourceInventory{
+		Entries: []kustomizev1.ResourceRef{
+			{ID: "tenant-b_config_apps_Deployment", Version: "v1"},
+			{ID: "_tenant-d__Namespace", Version: "v1"},
+		},
+	}
+
+	namespaces, err := emptyNamespaces(kustomization, pruned, inventory, []string{"kube-system"})
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(namespaces).To(Equal([]string{"tenant-a", "tenant-c"}))
+}
+
+func Test_requiresPruneApproval(t *testing.T) {
+	inventory := &kustomizev1.ResourceInventory{
+		Entries: make([]kustomizev1.ResourceRef, 10),
+	}
+
+	stale := func(n int) []*unstructured.Unstructured {
+		objects := make([]*unstructured.Unstructured, n)
+		for i := range objects {
+			u := &unstructured.Unstructured{}
+			u.SetAPIVersion("v1")
+			u.SetKind("ConfigMap")
+			u.SetNamespace("default")
+			u.SetName(fmt.Sprintf("config-%d", i))
+			objects[i] = u
+		}
+		return objects
+	}
+
+	tests := []struct {
+		name      string
+		threshold *intstr.IntOrString
+		stale     int
+		want      bool
+	}{
+		{name: "no threshold", threshold: nil, stale: 10, want: false},
+		{name: "below count", threshold: intstrPtr(intstr.FromInt(5)), stale: 5, want: false},
+		{name: "above count", threshold: intstrPtr(intstr.FromInt(5)), stale: 6, want: true},
+		{name: "below percentage", threshold: intstrPtr(intstr.FromString("50%")), stale: 5, want: false},
+		{name: "above percentage", threshold: intstrPtr(intstr.FromString("50%")), stale: 6, want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			kustomization := kustomizev1.Kustomization{
+				Spec: kustomizev1.KustomizationSpec{
+					Prune: true,
+					PruneOptions: &kustomizev1.PruneOptions{
+						ApprovalThreshold: tt.threshold,
+					},
+				},
+			}
+
+			got, err := requiresPruneApproval(kustomization, inventory, stale(tt.stale))
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(got).To(Equal(tt.want))
+		})
+	}
+
+	t.Run("stable digest", func(t *testing.T) {
+		g := NewWithT(t)
+
+		objects := stale(3)
+		reversed := []*unstructured.Unstructured{objects[2], objects[1], objects[0]}
+		g.Expect(NewPendingPrune("v1", objects).Digest).To(Equal(NewPendingPrune("v2", reversed).Digest))
+		g.Expect(NewPendingPrune("v1", objects).Digest).NotTo(Equal(NewPendingPrune("v1", objects[1:]).Digest))
+	})
+
+	t.Run("truncated entries", func(t *testing.T) {
+		g := NewWithT(t)
+
+		objects := stale(maxPendingPruneEntries + 50)
+		pendingPrune := NewPendingPrune("v1", objects)
+		g.Expect(pendingPrune.Entries).To(HaveLen(maxPendingPruneEntries))
+		g.Expect(pendingPrune.Total).To(Equal(maxPendingPruneEntries + 50))
+		g.Expect(pendingPrune.Digest).NotTo(Equal(NewPendingPrune("v1", objects[:maxPendingPruneEntries]).Digest))
+
+		msg := fmtTruncatedList(objects, maxPendingPruneEntries)
+		g.Expect(strings.Split(msg, "\n")).To(HaveLen(maxPendingPruneEntries + 1))
+		g.Expect(msg).To(HaveSuffix("... and 50 more"))
+	})
+}
+
+func Test_splitByPruneBudget(t *testing.T) {
+	inventory := &kustomizev1.ResourceInventory{
+		Entries: make([]kustomizev1.ResourceRef, 10),
+	}
+	objects := make([]*unstructured.Unstructured, 6)
+	for i := range objects {
+		objects[i] = &unstructured.Unstructured{}
+	}
+
+	tests := []struct {
+		name         string
+		budget       *kustomizev1.PruneDisruptionBudget
+		wantAllowed  int
+		wantDeferred int
+	}{
+		{name: "no budget", budget: nil, wantAllowed: 6, wantDeferred: 0},
+		{name: "within count", budget: &kustomizev1.PruneDisruptionBudget{MaxDeletions: intstr.FromInt(6)}, wantAllowed: 6, wantDeferred: 0},
+		{name: "exceeds count", budget: &kustomizev1.PruneDisruptionBudget{MaxDeletions: intstr.FromInt(4)}, wantAllowed: 4, wantDeferred: 2},
+		{name: "exceeds percentage", budget: &kustomizev1.PruneDisruptionBudget{MaxDeletions: intstr.FromString("20%")}, wantAllowed: 2, wantDeferred: 4},
+		{name: "rounds up percentage", budget: &kustomizev1.PruneDisruptionBudget{MaxDeletions: intstr.FromString("25%")}, wantAllowed: 3, wantDeferred: 3},
+		{name: "split at least one", budget: &kustomizev1.PruneDisruptionBudget{MaxDeletions: intstr.FromInt(0)}, wantAllowed: 1, wantDeferred: 5},
+		{name: "block zero", budget: &kustomizev1.PruneDisruptionBudget{MaxDeletions: intstr.FromInt(0), Policy: kustomizev1.BlockPrunePolicy}, wantAllowed: 0, wantDeferred: 6},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			kustomization := kustomizev1.Kustomization{
+				Spec: kustomizev1.KustomizationSpec{
+					Prune: true,
+					PruneOptions: &kustomizev1.PruneOptions{
+						DisruptionBudget: tt.budget,
+					},
+				},
+			}
+
+			allowed, deferred, err := splitByPruneBudget(kustomization, inventory, objects)
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(allowed).To(HaveLen(tt.wantAllowed))
+			g.Expect(deferred).To(HaveLen(tt.wantDeferred))
+		})
+	}
+}
+
+func intstrPtr(v intstr.IntOrString) *intstr.IntOrString {
+	return &v
+}
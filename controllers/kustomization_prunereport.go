@@ -0,0 +1,126 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/fluxcd/pkg/ssa"
+	"github.com/prometheus/client_golang/prometheus"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta2"
+)
+
+// PruneReportRecorder records the number of objects that garbage collection
+// would delete for the Kustomizations with prune disabled.
+type PruneReportRecorder struct {
+	staleGauge *prometheus.GaugeVec
+}
+
+// NewPruneReportRecorder returns a PruneReportRecorder, its collectors
+// must be registered with the metrics registry.
+func NewPruneReportRecorder() *PruneReportRecorder {
+	return &PruneReportRecorder{
+		staleGauge: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "gotk_kustomization_prunable_objects",
+				Help: "The number of objects that garbage collection would delete if prune was enabled.",
+			},
+			[]string{"name", "namespace"},
+		),
+	}
+}
+
+// Collectors returns the metrics.Collector objects for the PruneReportRecorder.
+func (r *PruneReportRecorder) Collectors() []prometheus.Collector {
+	return []prometheus.Collector{r.staleGauge}
+}
+
+// Record sets the gauge of the Kustomization to the given number of objects.
+func (r *PruneReportRecorder) Record(kustomization kustomizev1.Kustomization, count int) {
+	r.staleGauge.WithLabelValues(kustomization.GetName(), kustomization.GetNamespace()).Set(float64(count))
+}
+
+// Delete removes the gauge of the Kustomization.
+func (r *PruneReportRecorder) Delete(kustomization kustomizev1.Kustomization) {
+	r.staleGauge.DeleteLabelValues(kustomization.GetName(), kustomization.GetNamespace())
+}
+
+// pruneExclusions are the labels and annotations of the objects
+// that garbage collection leaves in place.
+var pruneExclusions = map[string]string{
+	fmt.Sprintf("%s/prune", kustomizev1.GroupVersion.Group):     kustomizev1.DisabledValue,
+	fmt.Sprintf("%s/reconcile", kustomizev1.GroupVersion.Group): kustomizev1.DisabledValue,
+}
+
+// pruneReportEnabled returns true if the Kustomization records the
+// objects that garbage collection would delete in its status.
+func pruneReportEnabled(kustomization kustomizev1.Kustomization) bool {
+	return kustomization.Spec.PruneOptions != nil && kustomization.Spec.PruneOptions.Report
+}
+
+// prunableObjects returns the stale objects that garbage collection would delete,
+// the ones that exist in the cluster, carry the given owner labels and are not
+// excluded from pruning. No object is prunable when the owner labels are disabled.
+func prunableObjects(ctx context.Context, kubeClient client.Client, owner map[string]string,
+	objects []*unstructured.Unstructured) ([]*unstructured.Unstructured, error) {
+	if len(owner) == 0 {
+		return nil, nil
+	}
+
+	selector := labels.SelectorFromSet(owner)
+	var result []*unstructured.Unstructured
+	for _, obj := range objects {
+		existing := &unstructured.Unstructured{}
+		existing.SetGroupVersionKind(obj.GroupVersionKind())
+		if err := kubeClient.Get(ctx, client.ObjectKeyFromObject(obj), existing); err != nil {
+			if apierrors.IsNotFound(err) {
+				continue
+			}
+			return nil, fmt.Errorf("%s query failed, error: %w", ssa.FmtUnstructured(obj), err)
+		}
+		if !selector.Matches(labels.Set(existing.GetLabels())) || ssa.AnyInMetadata(existing, pruneExclusions) {
+			continue
+		}
+		result = append(result, obj)
+	}
+	return result, nil
+}
+
+// NewPruneReport returns the list of objects that garbage collection
+// would delete, truncated to maxPendingPruneEntries.
+func NewPruneReport(revision string, objects []*unstructured.Unstructured) *kustomizev1.PruneReport {
+	entries := objectsToResourceRefs(objects)
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].ID < entries[j].ID
+	})
+	if len(entries) > maxPendingPruneEntries {
+		entries = entries[:maxPendingPruneEntries]
+	}
+
+	return &kustomizev1.PruneReport{
+		Revision: revision,
+		Entries:  entries,
+		Total:    len(objects),
+	}
+}
@@ -0,0 +1,119 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta2"
+)
+
+func Test_prunableObjects(t *testing.T) {
+	g := NewWithT(t)
+
+	owner := map[string]string{
+		"kustomize.toolkit.fluxcd.io/name":      "apps",
+		"kustomize.toolkit.fluxcd.io/namespace": "flux-system",
+	}
+	configMap := func(name string, labels, annotations map[string]string) *corev1.ConfigMap {
+		return &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace:   "apps",
+				Name:        name,
+				Labels:      labels,
+				Annotations: annotations,
+			},
+		}
+	}
+
+	scheme := runtime.NewScheme()
+	g.Expect(corev1.AddToScheme(scheme)).To(Succeed())
+	kubeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+		configMap("owned", owner, nil),
+		configMap("unowned", map[string]string{"app": "other"}, nil),
+		configMap("prune-disabled", owner, map[string]string{"kustomize.toolkit.fluxcd.io/prune": "disabled"}),
+	).Build()
+
+	var stale []*unstructured.Unstructured
+	for _, name := range []string{"owned", "unowned", "prune-disabled", "deleted"} {
+		obj := &unstructured.Unstructured{}
+		obj.SetAPIVersion("v1")
+		obj.SetKind("ConfigMap")
+		obj.SetNamespace("apps")
+		obj.SetName(name)
+		stale = append(stale, obj)
+	}
+
+	prunable, err := prunableObjects(context.TODO(), kubeClient, owner, stale)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(prunable).To(HaveLen(1))
+	g.Expect(prunable[0].GetName()).To(Equal("owned"))
+
+	// no object is deleted when the owner labels are disabled
+	prunable, err = prunableObjects(context.TODO(), kubeClient, map[string]string{}, stale)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(prunable).To(BeEmpty())
+}
+
+func TestNewPruneReport(t *testing.T) {
+	g := NewWithT(t)
+
+	var objects []*unstructured.Unstructured
+	for i := maxPendingPruneEntries + 10; i > 0; i-- {
+		obj := &unstructured.Unstructured{}
+		obj.SetAPIVersion("v1")
+		obj.SetKind("ConfigMap")
+		obj.SetNamespace("apps")
+		obj.SetName(fmt.Sprintf("cm-%03d", i))
+		objects = append(objects, obj)
+	}
+
+	report := NewPruneReport("main/abc", objects)
+	g.Expect(report.Revision).To(Equal("main/abc"))
+	g.Expect(report.Total).To(Equal(maxPendingPruneEntries + 10))
+	g.Expect(report.Entries).To(HaveLen(maxPendingPruneEntries))
+	g.Expect(report.Entries[0].ID).To(Equal("apps_cm-001__ConfigMap"))
+}
+
+func TestPruneReportRecorder(t *testing.T) {
+	g := NewWithT(t)
+
+	r := NewPruneReportRecorder()
+	kustomization := kustomizev1.Kustomization{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "flux-system", Name: "apps"},
+	}
+
+	r.Record(kustomization, 3)
+	g.Expect(testutil.ToFloat64(r.staleGauge.WithLabelValues("apps", "flux-system"))).To(Equal(float64(3)))
+
+	r.Record(kustomization, 1)
+	g.Expect(testutil.CollectAndCount(r.staleGauge)).To(Equal(1))
+	g.Expect(testutil.ToFloat64(r.staleGauge.WithLabelValues("apps", "flux-system"))).To(Equal(float64(1)))
+
+	r.Delete(kustomization)
+	g.Expect(testutil.CollectAndCount(r.staleGauge)).To(Equal(0))
+}
@@ -0,0 +1,184 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// ControllerMetricsRecorder records the health of the controller work queue and
+// of the build cache, in addition to the generic controller-runtime metrics.
+// The queue depth and the age of the oldest pending Kustomization are reported
+// per shard, they account for the reconciliations scheduled with a requeue delay
+// and for the ones triggered by source, dependency and definition changes.
+type ControllerMetricsRecorder struct {
+	shards     int
+	buildCache *BuildCache
+	now        func() time.Time
+
+	mu      sync.Mutex
+	pending map[types.NamespacedName]time.Time
+
+	queueDepthDesc    *prometheus.Desc
+	oldestPendingDesc *prometheus.Desc
+	cacheEntriesDesc  *prometheus.Desc
+	cacheHitRatioDesc *prometheus.Desc
+}
+
+// NewControllerMetricsRecorder returns a ControllerMetricsRecorder for the given
+// number of shards and build cache, its collectors must be registered with the
+// metrics registry.
+func NewControllerMetricsRecorder(shards int, buildCache *BuildCache) *ControllerMetricsRecorder {
+	if shards < 1 {
+		shards = 1
+	}
+	return &ControllerMetricsRecorder{
+		shards:     shards,
+		buildCache: buildCache,
+		now:        time.Now,
+		pending:    make(map[types.NamespacedName]time.Time),
+		queueDepthDesc: prometheus.NewDesc(
+			"gotk_kustomization_queue_depth",
+			"The number of Kustomizations waiting to be reconciled, by shard.",
+			[]string{"shard"}, nil,
+		),
+		oldestPendingDesc: prometheus.NewDesc(
+			"gotk_kustomization_queue_oldest_pending_seconds",
+			"The time in seconds the oldest pending Kustomization has been waiting to be reconciled, by shard.",
+			[]string{"shard"}, nil,
+		),
+		cacheEntriesDesc: prometheus.NewDesc(
+			"gotk_kustomization_build_cache_entries",
+			"The number of build results held in the build cache.",
+			nil, nil,
+		),
+		cacheHitRatioDesc: prometheus.NewDesc(
+			"gotk_kustomization_build_cache_hit_ratio",
+			"The ratio of the build cache lookups that found a build result.",
+			nil, nil,
+		),
+	}
+}
+
+// Collectors returns the metrics.Collector objects for the ControllerMetricsRecorder.
+func (r *ControllerMetricsRecorder) Collectors() []prometheus.Collector {
+	return []prometheus.Collector{r}
+}
+
+// Describe implements prometheus.Collector.
+func (r *ControllerMetricsRecorder) Describe(ch chan<- *prometheus.Desc) {
+	ch <- r.queueDepthDesc
+	ch <- r.oldestPendingDesc
+	ch <- r.cacheEntriesDesc
+	ch <- r.cacheHitRatioDesc
+}
+
+// Collect implements prometheus.Collector, the queue metrics are computed
+// at collection time from the Kustomizations due for reconciliation.
+func (r *ControllerMetricsRecorder) Collect(ch chan<- prometheus.Metric) {
+	depth, oldest := r.queue()
+	for shard := 0; shard < r.shards; shard++ {
+		label := strconv.Itoa(shard)
+		ch <- prometheus.MustNewConstMetric(r.queueDepthDesc, prometheus.GaugeValue, float64(depth[shard]), label)
+		ch <- prometheus.MustNewConstMetric(r.oldestPendingDesc, prometheus.GaugeValue, oldest[shard].Seconds(), label)
+	}
+
+	entries, hits, misses := r.buildCache.Stats()
+	ratio := 0.0
+	if hits+misses > 0 {
+		ratio = float64(hits) / float64(hits+misses)
+	}
+	ch <- prometheus.MustNewConstMetric(r.cacheEntriesDesc, prometheus.GaugeValue, float64(entries))
+	ch <- prometheus.MustNewConstMetric(r.cacheHitRatioDesc, prometheus.GaugeValue, ratio)
+}
+
+// queue returns the number of Kustomizations due for reconciliation and
+// the waiting time of the oldest one, by shard.
+func (r *ControllerMetricsRecorder) queue() (map[int]int, map[int]time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := r.now()
+	depth := make(map[int]int)
+	oldest := make(map[int]time.Duration)
+	for key, due := range r.pending {
+		if due.After(now) {
+			continue
+		}
+		shard := ShardOf(key, r.shards)
+		depth[shard]++
+		if age := now.Sub(due); age > oldest[shard] {
+			oldest[shard] = age
+		}
+	}
+	return depth, oldest
+}
+
+// Queued records the Kustomization as pending from the given time,
+// unless it's already pending from an earlier time.
+func (r *ControllerMetricsRecorder) Queued(key types.NamespacedName, due time.Time) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if current, ok := r.pending[key]; !ok || due.Before(current) {
+		r.pending[key] = due
+	}
+}
+
+// Dequeued records the start of the reconciliation of the Kustomization.
+func (r *ControllerMetricsRecorder) Dequeued(key types.NamespacedName) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.pending, key)
+}
+
+// Requeued records the Kustomization as pending after the delay of the
+// reconciliation result. The requeues with backoff after an error are
+// not recorded, as their delay is decided by the rate limiter.
+func (r *ControllerMetricsRecorder) Requeued(key types.NamespacedName, result ctrl.Result, err error) {
+	if r == nil || err != nil || result.Requeue || result.RequeueAfter <= 0 {
+		return
+	}
+	r.Queued(key, r.now().Add(result.RequeueAfter))
+}
+
+// queuedRequests returns a map function that records the requests
+// returned by fn as pending for the work queue metrics.
+func (r *KustomizationReconciler) queuedRequests(fn handler.MapFunc) handler.MapFunc {
+	return func(obj client.Object) []reconcile.Request {
+		reqs := fn(obj)
+		now := time.Now()
+		for _, req := range reqs {
+			r.ControllerMetrics.Queued(req.NamespacedName, now)
+		}
+		return reqs
+	}
+}
@@ -0,0 +1,97 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+func TestControllerMetricsRecorder(t *testing.T) {
+	g := NewWithT(t)
+
+	now := time.Now()
+	cache := NewBuildCache(2)
+	r := NewControllerMetricsRecorder(2, cache)
+	r.now = func() time.Time { return now }
+
+	var keys []types.NamespacedName
+	for _, name := range []string{"a", "b", "c", "d", "e", "f"} {
+		keys = append(keys, types.NamespacedName{Namespace: "default", Name: name})
+	}
+
+	r.Queued(keys[0], now.Add(-time.Minute))
+	r.Queued(keys[1], now.Add(-10*time.Second))
+	// keeps the earliest time
+	r.Queued(keys[1], now)
+	r.Requeued(keys[2], ctrl.Result{RequeueAfter: time.Minute}, nil)
+	r.Requeued(keys[3], ctrl.Result{}, errors.New("failed"))
+	r.Requeued(keys[4], ctrl.Result{}, nil)
+	r.Queued(keys[5], now)
+	r.Dequeued(keys[5])
+
+	g.Expect(r.pending).To(HaveLen(3))
+	g.Expect(r.pending[keys[1]]).To(Equal(now.Add(-10 * time.Second)))
+	g.Expect(r.pending[keys[2]]).To(Equal(now.Add(time.Minute)))
+
+	depth, oldest := r.queue()
+	total := 0
+	for _, d := range depth {
+		total += d
+	}
+	g.Expect(total).To(Equal(2))
+	shardA := ShardOf(keys[0], 2)
+	g.Expect(oldest[shardA]).To(Equal(time.Minute))
+
+	cache.Set("a", []byte("a"), nil, "")
+	cache.Get("a")
+	cache.Get("b")
+
+	g.Expect(testutil.CollectAndCount(r)).To(Equal(6))
+	g.Expect(testutil.CollectAndCompare(r, strings.NewReader(`
+# HELP gotk_kustomization_build_cache_entries The number of build results held in the build cache.
+# TYPE gotk_kustomization_build_cache_entries gauge
+gotk_kustomization_build_cache_entries 1
+# HELP gotk_kustomization_build_cache_hit_ratio The ratio of the build cache lookups that found a build result.
+# TYPE gotk_kustomization_build_cache_hit_ratio gauge
+gotk_kustomization_build_cache_hit_ratio 0.5
+`), "gotk_kustomization_build_cache_entries", "gotk_kustomization_build_cache_hit_ratio")).To(Succeed())
+
+	// the recorder is optional
+	var disabled *ControllerMetricsRecorder
+	disabled.Queued(keys[0], now)
+	disabled.Dequeued(keys[0])
+	disabled.Requeued(keys[0], ctrl.Result{RequeueAfter: time.Minute}, nil)
+}
+
+func TestControllerMetricsRecorder_withoutShards(t *testing.T) {
+	g := NewWithT(t)
+
+	r := NewControllerMetricsRecorder(0, nil)
+	r.Queued(types.NamespacedName{Namespace: "default", Name: "a"}, time.Now().Add(-time.Second))
+
+	depth, _ := r.queue()
+	g.Expect(depth[0]).To(Equal(1))
+	g.Expect(testutil.CollectAndCount(r)).To(Equal(4))
+}
@@ -0,0 +1,97 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta2"
+)
+
+// ObjectQuota limits the number of objects managed by Kustomizations,
+// a zero value means no limit.
+type ObjectQuota struct {
+	// MaxObjects is the maximum number of objects a Kustomization can manage.
+	MaxObjects int
+
+	// MaxObjectsPerKind is the maximum number of objects of a kind
+	// a Kustomization can manage, a zero value for a kind means no limit.
+	MaxObjectsPerKind map[string]int
+
+	// MaxNamespaceObjects is the maximum number of objects all the
+	// Kustomizations in a namespace can manage.
+	MaxNamespaceObjects int
+}
+
+// checkObjectQuota returns an error if the objects exceed the per Kustomization limits.
+func checkObjectQuota(quota ObjectQuota, objects []*unstructured.Unstructured) error {
+	if quota.MaxObjects > 0 && len(objects) > quota.MaxObjects {
+		return fmt.Errorf("the Kustomization manages %d objects, the quota is %d",
+			len(objects), quota.MaxObjects)
+	}
+
+	kinds := make(map[string]int)
+	for _, obj := range objects {
+		kinds[obj.GetKind()]++
+	}
+
+	var exceeded []string
+	for kind, count := range kinds {
+		if max, ok := quota.MaxObjectsPerKind[kind]; ok && max > 0 && count > max {
+			exceeded = append(exceeded, fmt.Sprintf("%d %s objects, the quota is %d", count, kind, max))
+		}
+	}
+	if len(exceeded) > 0 {
+		sort.Strings(exceeded)
+		return fmt.Errorf("the Kustomization manages %s", strings.Join(exceeded, ", "))
+	}
+
+	return nil
+}
+
+// checkNamespaceQuota returns an error if the objects, together with the inventories of
+// the other Kustomizations in the same namespace, exceed the namespace limit.
+func (r *KustomizationReconciler) checkNamespaceQuota(ctx context.Context, kustomization kustomizev1.Kustomization, objects []*unstructured.Unstructured) error {
+	if r.ObjectQuota.MaxNamespaceObjects <= 0 {
+		return nil
+	}
+
+	var list kustomizev1.KustomizationList
+	if err := r.List(ctx, &list, client.InNamespace(kustomization.GetNamespace())); err != nil {
+		return fmt.Errorf("unable to list Kustomizations: %w", err)
+	}
+
+	total := len(objects)
+	for _, k := range list.Items {
+		if k.GetName() == kustomization.GetName() || k.Status.Inventory == nil {
+			continue
+		}
+		total += len(k.Status.Inventory.Entries)
+	}
+
+	if total > r.ObjectQuota.MaxNamespaceObjects {
+		return fmt.Errorf("the Kustomizations in namespace '%s' manage %d objects, the quota is %d",
+			kustomization.GetNamespace(), total, r.ObjectQuota.MaxNamespaceObjects)
+	}
+	return nil
+}
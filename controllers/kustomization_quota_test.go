@@ -0,0 +1,82 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func Test_checkObjectQuota(t *testing.T) {
+	var objects []*unstructured.Unstructured
+	for i := 0; i < 3; i++ {
+		cm := &unstructured.Unstructured{}
+		cm.SetKind("ConfigMap")
+		cm.SetName(fmt.Sprintf("cm-%d", i))
+		objects = append(objects, cm)
+	}
+	secret := &unstructured.Unstructured{}
+	secret.SetKind("Secret")
+	secret.SetName("secret")
+	objects = append(objects, secret)
+
+	tests := []struct {
+		name    string
+		quota   ObjectQuota
+		wantErr string
+	}{
+		{
+			name:  "no limits",
+			quota: ObjectQuota{},
+		},
+		{
+			name:  "within limits",
+			quota: ObjectQuota{MaxObjects: 4, MaxObjectsPerKind: map[string]int{"ConfigMap": 3}},
+		},
+		{
+			name:    "total exceeded",
+			quota:   ObjectQuota{MaxObjects: 3},
+			wantErr: "manages 4 objects, the quota is 3",
+		},
+		{
+			name:    "kind exceeded",
+			quota:   ObjectQuota{MaxObjectsPerKind: map[string]int{"ConfigMap": 2, "Secret": 1}},
+			wantErr: "manages 3 ConfigMap objects, the quota is 2",
+		},
+		{
+			name:  "zero kind limit",
+			quota: ObjectQuota{MaxObjectsPerKind: map[string]int{"ConfigMap": 0, "Secret": 1}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			err := checkObjectQuota(tt.quota, objects)
+			if tt.wantErr == "" {
+				g.Expect(err).NotTo(HaveOccurred())
+				return
+			}
+			g.Expect(err).To(HaveOccurred())
+			g.Expect(err.Error()).To(ContainSubstring(tt.wantErr))
+		})
+	}
+}
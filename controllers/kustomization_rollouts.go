@@ -0,0 +1,83 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/fluxcd/pkg/ssa"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/cli-utils/pkg/kstatus/status"
+
+	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta2"
+	"github.com/fluxcd/kustomize-controller/internal/statusreaders"
+)
+
+// deploymentGroupKind is the GroupKind of the Deployment objects.
+var deploymentGroupKind = schema.GroupKind{Group: "apps", Kind: "Deployment"}
+
+// deferRollingOut returns the objects to apply, and the Deployments and Argo Rollouts
+// left out because they are being rolled out and applying them would change their
+// spec, which restarts the rollout. The workloads that are not yet created, unchanged
+// or whose rollout failed are applied.
+func deferRollingOut(ctx context.Context, manager *ssa.ResourceManager,
+	objects []*unstructured.Unstructured) ([]*unstructured.Unstructured, []*unstructured.Unstructured, error) {
+	var apply, deferred []*unstructured.Unstructured
+	for _, u := range objects {
+		gk := u.GroupVersionKind().GroupKind()
+		if gk != deploymentGroupKind && gk != statusreaders.RolloutGroupKind {
+			apply = append(apply, u)
+			continue
+		}
+
+		obj := u.DeepCopy()
+		if err := ssa.SetNativeKindsDefaults([]*unstructured.Unstructured{obj}); err != nil {
+			return nil, nil, err
+		}
+		change, existing, _, err := manager.Diff(ctx, obj, ssa.DiffOptions{
+			Exclusions: map[string]string{
+				fmt.Sprintf("%s/reconcile", kustomizev1.GroupVersion.Group): kustomizev1.DisabledValue,
+			},
+		})
+		// the validation errors are reported by the apply
+		if err != nil || change == nil || change.Action != string(ssa.ConfiguredAction) || !rollingOut(existing) {
+			apply = append(apply, u)
+			continue
+		}
+		deferred = append(deferred, u)
+	}
+	return apply, deferred, nil
+}
+
+// rollingOut returns true if the given Deployment or Argo Rollout is being rolled out,
+// including a Rollout paused at a canary step.
+func rollingOut(obj *unstructured.Unstructured) bool {
+	if obj == nil {
+		return false
+	}
+
+	var result *status.Result
+	var err error
+	if obj.GroupVersionKind().GroupKind() == statusreaders.RolloutGroupKind {
+		result, err = statusreaders.RolloutStatus(obj)
+	} else {
+		result, err = status.Compute(obj)
+	}
+	return err == nil && result.Status == status.InProgressStatus
+}
@@ -0,0 +1,75 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func Test_rollingOut(t *testing.T) {
+	newDeployment := func(updatedReplicas int64) *unstructured.Unstructured {
+		return &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "apps/v1",
+			"kind":       "Deployment",
+			"metadata":   map[string]interface{}{"name": "podinfo", "namespace": "test", "generation": int64(2)},
+			"spec":       map[string]interface{}{"replicas": int64(2)},
+			"status": map[string]interface{}{
+				"observedGeneration": int64(2),
+				"replicas":           int64(2),
+				"updatedReplicas":    updatedReplicas,
+				"readyReplicas":      int64(2),
+				"availableReplicas":  int64(2),
+				"conditions": []interface{}{
+					map[string]interface{}{"type": "Progressing", "status": "True", "reason": "NewReplicaSetAvailable"},
+					map[string]interface{}{"type": "Available", "status": "True"},
+				},
+			},
+		}}
+	}
+	newRollout := func(phase string) *unstructured.Unstructured {
+		return &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "argoproj.io/v1alpha1",
+			"kind":       "Rollout",
+			"metadata":   map[string]interface{}{"name": "podinfo", "namespace": "test", "generation": int64(2)},
+			"status":     map[string]interface{}{"observedGeneration": "2", "phase": phase},
+		}}
+	}
+
+	tests := []struct {
+		name   string
+		object *unstructured.Unstructured
+		want   bool
+	}{
+		{name: "not found", object: nil, want: false},
+		{name: "deployment rolled out", object: newDeployment(2), want: false},
+		{name: "deployment rolling out", object: newDeployment(1), want: true},
+		{name: "rollout healthy", object: newRollout("Healthy"), want: false},
+		{name: "rollout progressing", object: newRollout("Progressing"), want: true},
+		{name: "rollout paused at a canary step", object: newRollout("Paused"), want: true},
+		{name: "rollout degraded", object: newRollout("Degraded"), want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			g.Expect(rollingOut(tt.object)).To(Equal(tt.want))
+		})
+	}
+}
@@ -0,0 +1,99 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"sigs.k8s.io/kustomize/api/provider"
+	"sigs.k8s.io/kustomize/api/resmap"
+)
+
+// SandboxBuildCommand is the command that runs kustomize build in a child
+// process of the controller, restricted to read the files of the build root.
+const SandboxBuildCommand = "sandbox-build"
+
+// sandboxBuildKustomization runs kustomize build in a child process started from
+// the controller executable, and decodes the build output written to stdout.
+func sandboxBuildKustomization(ctx context.Context, root, dirPath string) (resmap.ResMap, error) {
+	executable, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, executable, SandboxBuildCommand, root, dirPath)
+	// do not leak the controller environment, e.g. cloud credentials, to the build
+	cmd.Env = []string{}
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("sandboxed build failed: %s", msg)
+		}
+		return nil, fmt.Errorf("sandboxed build failed: %w", err)
+	}
+
+	factory := resmap.NewFactory(provider.NewDefaultDepProvider().GetResourceFactory())
+	return factory.NewResMapFromBytes(stdout.Bytes())
+}
+
+// CheckSandbox returns an error if the builds can't be sandboxed
+// on this platform or with this controller binary.
+func CheckSandbox() error {
+	return checkSandbox()
+}
+
+// RunSandboxBuild is the entrypoint of the SandboxBuildCommand. It restricts
+// the process to read-only access of the root directory and denies it the
+// network access, builds the kustomization at path and writes the resulting
+// YAML to stdout. Remote bases are not supported.
+func RunSandboxBuild(args []string) int {
+	if len(args) != 2 {
+		fmt.Fprintf(os.Stderr, "usage: %s <root> <path>\n", SandboxBuildCommand)
+		return 2
+	}
+	root, dirPath := args[0], args[1]
+
+	if err := restrictProcess(root); err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		return 1
+	}
+
+	m, err := secureBuildKustomization(root, dirPath, false, "")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		return 1
+	}
+
+	resources, err := m.AsYaml()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		return 1
+	}
+
+	if _, err := os.Stdout.Write(resources); err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		return 1
+	}
+	return 0
+}
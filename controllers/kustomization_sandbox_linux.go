@@ -0,0 +1,209 @@
+//go:build linux
+// +build linux
+
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// Landlock system calls, their numbers are the same on all architectures.
+const (
+	sysLandlockCreateRuleset = 444
+	sysLandlockAddRule       = 445
+	sysLandlockRestrictSelf  = 446
+
+	landlockRulePathBeneath = 1
+	prSetNoNewPrivs         = 38
+)
+
+// Filesystem access rights of the Landlock ABI v1.
+const (
+	accessFSExecute = 1 << iota
+	accessFSWriteFile
+	accessFSReadFile
+	accessFSReadDir
+	accessFSRemoveDir
+	accessFSRemoveFile
+	accessFSMakeChar
+	accessFSMakeDir
+	accessFSMakeReg
+	accessFSMakeSock
+	accessFSMakeFifo
+	accessFSMakeBlock
+	accessFSMakeSym
+
+	accessFSAll = accessFSMakeSym<<1 - 1
+)
+
+// Seccomp constants, see linux/seccomp.h and linux/audit.h.
+const (
+	seccompSetModeFilter   = 1
+	seccompFilterFlagTsync = 1
+
+	seccompRetKillProcess = 0x80000000
+	seccompRetErrno       = 0x00050000
+	seccompRetAllow       = 0x7fff0000
+
+	// offsets of the syscall number and of the architecture in struct seccomp_data
+	seccompDataNrOffset   = 0
+	seccompDataArchOffset = 4
+
+	// x32SyscallBit is set in the number of the syscalls made with the x32 ABI on amd64
+	x32SyscallBit = 0x40000000
+)
+
+// seccompAuditArch holds the audit architecture of the GOARCH values
+// the seccomp filter of the sandboxed builds is supported on.
+var seccompAuditArch = map[string]uint32{
+	"amd64": unix.AUDIT_ARCH_X86_64,
+	"arm64": unix.AUDIT_ARCH_AARCH64,
+	"arm":   unix.AUDIT_ARCH_ARM,
+}
+
+// networkSyscalls are the syscalls denied to the sandboxed builds, the build
+// process inherits no socket from the controller and can't create one.
+var networkSyscalls = []uintptr{
+	unix.SYS_SOCKET,
+	unix.SYS_SOCKETPAIR,
+	unix.SYS_CONNECT,
+	unix.SYS_BIND,
+	unix.SYS_LISTEN,
+	unix.SYS_ACCEPT4,
+	unix.SYS_SENDTO,
+	unix.SYS_SENDMSG,
+}
+
+type landlockRulesetAttr struct {
+	handledAccessFS uint64
+}
+
+type landlockPathBeneathAttr struct {
+	allowedAccess uint64
+	parentFd      int32
+}
+
+// checkSandbox returns an error if the builds can't be sandboxed.
+func checkSandbox() error {
+	// the Landlock ruleset is enforced on all the threads with AllThreadsSyscall,
+	// which can't reach the threads created by C code
+	if cgoEnabled {
+		return errors.New("sandboxed build is not supported by a controller binary built with cgo, it must be built with CGO_ENABLED=0")
+	}
+	if _, ok := seccompAuditArch[runtime.GOARCH]; !ok {
+		return fmt.Errorf("sandboxed build is not supported on %s", runtime.GOARCH)
+	}
+	return nil
+}
+
+// restrictProcess denies the process, and all its threads, any filesystem
+// access other than reading the files under root, and any network access.
+func restrictProcess(root string) error {
+	if err := checkSandbox(); err != nil {
+		return err
+	}
+	if err := restrictFilesystem(root); err != nil {
+		return err
+	}
+	return restrictNetwork()
+}
+
+// restrictFilesystem uses Landlock to deny the process, and all its threads,
+// any filesystem access other than reading the files under root.
+func restrictFilesystem(root string) error {
+	attr := landlockRulesetAttr{handledAccessFS: accessFSAll}
+	ruleset, _, errno := syscall.Syscall(sysLandlockCreateRuleset,
+		uintptr(unsafe.Pointer(&attr)), unsafe.Sizeof(attr), 0)
+	if errno != 0 {
+		return fmt.Errorf("landlock is not available: %w", errno)
+	}
+	defer syscall.Close(int(ruleset))
+
+	dir, err := syscall.Open(root, syscall.O_RDONLY|syscall.O_DIRECTORY|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		return fmt.Errorf("failed to open '%s': %w", root, err)
+	}
+	defer syscall.Close(dir)
+
+	rule := landlockPathBeneathAttr{
+		allowedAccess: accessFSReadFile | accessFSReadDir,
+		parentFd:      int32(dir),
+	}
+	if _, _, errno := syscall.Syscall6(sysLandlockAddRule, ruleset, landlockRulePathBeneath,
+		uintptr(unsafe.Pointer(&rule)), 0, 0, 0); errno != 0 {
+		return fmt.Errorf("failed to add landlock rule: %w", errno)
+	}
+
+	// Landlock and seccomp require no_new_privs for unprivileged processes, both
+	// are applied to all threads as the Go runtime may run the build on any of them.
+	if _, _, errno := syscall.AllThreadsSyscall(syscall.SYS_PRCTL, prSetNoNewPrivs, 1, 0); errno != 0 {
+		return fmt.Errorf("failed to set no_new_privs: %w", errno)
+	}
+	if _, _, errno := syscall.AllThreadsSyscall(sysLandlockRestrictSelf, ruleset, 0, 0); errno != 0 {
+		return fmt.Errorf("failed to enforce landlock ruleset: %w", errno)
+	}
+
+	return nil
+}
+
+// restrictNetwork installs a seccomp filter on all the threads of the process
+// that fails the network syscalls with EACCES, and kills the process when
+// a syscall is made with another ABI than the one of the controller binary.
+func restrictNetwork() error {
+	filter := []unix.SockFilter{
+		bpfStmt(unix.BPF_LD|unix.BPF_W|unix.BPF_ABS, seccompDataArchOffset),
+		bpfJump(unix.BPF_JMP|unix.BPF_JEQ|unix.BPF_K, seccompAuditArch[runtime.GOARCH], 1, 0),
+		bpfStmt(unix.BPF_RET|unix.BPF_K, seccompRetKillProcess),
+		bpfStmt(unix.BPF_LD|unix.BPF_W|unix.BPF_ABS, seccompDataNrOffset),
+	}
+	if runtime.GOARCH == "amd64" {
+		filter = append(filter,
+			bpfJump(unix.BPF_JMP|unix.BPF_JGE|unix.BPF_K, x32SyscallBit, 0, 1),
+			bpfStmt(unix.BPF_RET|unix.BPF_K, seccompRetKillProcess),
+		)
+	}
+	for _, nr := range networkSyscalls {
+		filter = append(filter,
+			bpfJump(unix.BPF_JMP|unix.BPF_JEQ|unix.BPF_K, uint32(nr), 0, 1),
+			bpfStmt(unix.BPF_RET|unix.BPF_K, seccompRetErrno|uint32(unix.EACCES)),
+		)
+	}
+	filter = append(filter, bpfStmt(unix.BPF_RET|unix.BPF_K, seccompRetAllow))
+
+	prog := unix.SockFprog{Len: uint16(len(filter)), Filter: &filter[0]}
+	if _, _, errno := unix.Syscall(unix.SYS_SECCOMP, seccompSetModeFilter, seccompFilterFlagTsync,
+		uintptr(unsafe.Pointer(&prog))); errno != 0 {
+		return fmt.Errorf("failed to install seccomp filter: %w", errno)
+	}
+	return nil
+}
+
+func bpfStmt(code uint16, k uint32) unix.SockFilter {
+	return unix.SockFilter{Code: code, K: k}
+}
+
+func bpfJump(code uint16, k uint32, jt, jf uint8) unix.SockFilter {
+	return unix.SockFilter{Code: code, Jt: jt, Jf: jf, K: k}
+}
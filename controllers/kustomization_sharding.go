@@ -0,0 +1,464 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	coordinationv1 "k8s.io/api/coordination/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+
+	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta2"
+)
+
+// ShardLeaseLabel is set on the Leases used for sharding,
+// its value is the name of the controller.
+const ShardLeaseLabel = "kustomize.toolkit.fluxcd.io/shard-lease"
+
+// shardEventsBufferSize is the capacity of the channel on which the Kustomizations
+// of the acquired shards are sent.
+const shardEventsBufferSize = 1024
+
+// ShardOptions contains the configuration of the ShardManager.
+type ShardOptions struct {
+	// Shards is the number of shards the Kustomizations are distributed among.
+	Shards int
+
+	// ControllerName is used as prefix for the Lease names.
+	ControllerName string
+
+	// Namespace is the namespace of the Leases.
+	Namespace string
+
+	// Identity is the unique name of this replica.
+	Identity string
+
+	// LeaseDuration is the duration a replica holds a Lease without renewing it.
+	LeaseDuration time.Duration
+
+	// RenewDeadline is the duration a replica keeps reconciling a shard
+	// after the last successful renewal of its Lease.
+	RenewDeadline time.Duration
+
+	// RetryPeriod is the interval at which the Leases are renewed and balanced.
+	RetryPeriod time.Duration
+}
+
+// ShardManager distributes the Kustomizations among the controller replicas.
+// Every Kustomization belongs to a shard, and a shard is reconciled by the replica
+// holding its Lease. The replicas announce themselves with a member Lease,
+// and balance the shards by acquiring the free or expired shard Leases up to
+// their fair share, and by releasing the shards above it.
+type ShardManager struct {
+	client client.Client
+	reader client.Reader
+	opts   ShardOptions
+	events chan event.GenericEvent
+	now    func() time.Time
+
+	mu    sync.RWMutex
+	owned map[int]time.Time
+
+	// observed holds the resource version of the Leases of the other replicas
+	// and the local time at which it was first observed, a Lease expires when it
+	// doesn't change for its duration, regardless of the clock of its holder.
+	observed map[string]observedLease
+}
+
+// observedLease is the last observed change of a Lease.
+type observedLease struct {
+	resourceVersion string
+	time            time.Time
+}
+
+// NewShardManager returns a ShardManager that writes the Leases with the given client,
+// and reads them with the given reader, which must not be backed by the cache.
+func NewShardManager(c client.Client, reader client.Reader, opts ShardOptions) *ShardManager {
+	return &ShardManager{
+		client:   c,
+		reader:   reader,
+		opts:     opts,
+		events:   make(chan event.GenericEvent, shardEventsBufferSize),
+		now:      time.Now,
+		owned:    make(map[int]time.Time),
+		observed: make(map[string]observedLease),
+	}
+}
+
+// ShardOf returns the shard of the Kustomization with the given name.
+func ShardOf(key types.NamespacedName, shards int) int {
+	h := fnv.New32a()
+	h.Write([]byte(key.String()))
+	return int(h.Sum32() % uint32(shards))
+}
+
+// Owns returns true if the Kustomization belongs to a shard held by this replica.
+func (m *ShardManager) Owns(key types.NamespacedName) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	renewed, ok := m.owned[ShardOf(key, m.opts.Shards)]
+	return ok && m.now().Sub(renewed) < m.opts.RenewDeadline
+}
+
+// OwnedShards returns the shards held by this replica in ascending order.
+func (m *ShardManager) OwnedShards() []int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	shards := make([]int, 0, len(m.owned))
+	for shard := range m.owned {
+		shards = append(shards, shard)
+	}
+	sort.Ints(shards)
+	return shards
+}
+
+// Events returns the channel on which the Kustomizations of
+// the newly acquired shards are sent.
+func (m *ShardManager) Events() <-chan event.GenericEvent {
+	return m.events
+}
+
+// NeedLeaderElection returns false, all the replicas take part in the sharding.
+func (m *ShardManager) NeedLeaderElection() bool {
+	return false
+}
+
+// WithoutLeaderElection returns a manager which runs the controllers set up with it
+// on all the replicas, regardless of the leader election. When sharding is enabled,
+// the Kustomization controller runs on every replica, while the other controllers,
+// which are not sharded, run on the leader only.
+func WithoutLeaderElection(mgr ctrl.Manager) ctrl.Manager {
+	return nonLeaderElectedManager{Manager: mgr}
+}
+
+type nonLeaderElectedManager struct {
+	ctrl.Manager
+}
+
+// Add injects the dependencies of the runnable, and adds it to the manager
+// wrapped in a runnable that doesn't need the leader election.
+func (m nonLeaderElectedManager) Add(r manager.Runnable) error {
+	if err := m.Manager.SetFields(r); err != nil {
+		return err
+	}
+	return m.Manager.Add(nonLeaderElectedRunnable{Runnable: r})
+}
+
+type nonLeaderElectedRunnable struct {
+	manager.Runnable
+}
+
+func (nonLeaderElectedRunnable) NeedLeaderElection() bool {
+	return false
+}
+
+// Start renews and balances the Leases until the context is cancelled,
+// then it releases the Leases held by this replica.
+func (m *ShardManager) Start(ctx context.Context) error {
+	log := ctrl.Log.WithName("sharding")
+	ticker := time.NewTicker(m.opts.RetryPeriod)
+	defer ticker.Stop()
+
+	for {
+		if err := m.sync(ctx); err != nil {
+			log.Error(err, "unable to sync the shard leases")
+		}
+		select {
+		case <-ctx.Done():
+			releaseCtx, cancel := context.WithTimeout(context.Background(), m.opts.RetryPeriod)
+			defer cancel()
+			m.release(releaseCtx)
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+func (m *ShardManager) memberLeaseName() string {
+	return fmt.Sprintf("%s-member-%s", m.opts.ControllerName, m.opts.Identity)
+}
+
+func (m *ShardManager) shardLeaseName(shard int) string {
+	return fmt.Sprintf("%s-shard-%d", m.opts.ControllerName, shard)
+}
+
+// sync renews the member Lease, counts the live replicas and
+// updates the shard Leases to hold the fair share of shards.
+func (m *ShardManager) sync(ctx context.Context) error {
+	log := ctrl.Log.WithName("sharding")
+	now := m.now()
+
+	if err := m.renewMember(ctx, now); err != nil {
+		return fmt.Errorf("unable to renew the member lease: %w", err)
+	}
+
+	var list coordinationv1.LeaseList
+	if err := m.reader.List(ctx, &list, client.InNamespace(m.opts.Namespace),
+		client.MatchingLabels{ShardLeaseLabel: m.opts.ControllerName}); err != nil {
+		return fmt.Errorf("unable to list the shard leases: %w", err)
+	}
+
+	m.observe(list.Items, now)
+
+	members := 0
+	leases := make(map[int]*coordinationv1.Lease)
+	shardPrefix := fmt.Sprintf("%s-shard-", m.opts.ControllerName)
+	for i, lease := range list.Items {
+		switch {
+		case strings.HasPrefix(lease.GetName(), fmt.Sprintf("%s-member-", m.opts.ControllerName)):
+			if !m.leaseExpired(lease, now) {
+				members++
+				continue
+			}
+			// garbage collect the member Leases of the replicas that are gone
+			if err := m.client.Delete(ctx, &list.Items[i]); client.IgnoreNotFound(err) != nil {
+				log.Error(err, "unable to delete the expired member lease", "lease", lease.GetName())
+			}
+		case strings.HasPrefix(lease.GetName(), shardPrefix):
+			shard, err := strconv.Atoi(strings.TrimPrefix(lease.GetName(), shardPrefix))
+			if err == nil && shard >= 0 && shard < m.opts.Shards {
+				leases[shard] = &list.Items[i]
+			}
+		}
+	}
+	if members == 0 {
+		members = 1
+	}
+	fairShare := (m.opts.Shards + members - 1) / members
+
+	var held []int
+	for shard := 0; shard < m.opts.Shards; shard++ {
+		if lease, ok := leases[shard]; ok && leaseHolder(*lease) == m.opts.Identity {
+			held = append(held, shard)
+		} else {
+			m.drop(shard)
+		}
+	}
+
+	// release the shards above the fair share to the new replicas
+	for len(held) > fairShare {
+		shard := held[len(held)-1]
+		held = held[:len(held)-1]
+		m.drop(shard)
+		if err := m.updateLease(ctx, leases[shard], "", now); err != nil {
+			log.Error(err, "unable to release the shard lease", "shard", shard)
+			continue
+		}
+		log.Info("shard released", "shard", shard, "members", members)
+	}
+
+	for _, shard := range held {
+		if err := m.updateLease(ctx, leases[shard], m.opts.Identity, now); err != nil {
+			log.Error(err, "unable to renew the shard lease", "shard", shard)
+			continue
+		}
+		m.hold(shard, now)
+	}
+
+	// acquire the free and expired shards up to the fair share
+	for shard := 0; shard < m.opts.Shards && len(held) < fairShare; shard++ {
+		lease, ok := leases[shard]
+		var err error
+		switch {
+		case !ok:
+			err = m.createLease(ctx, m.shardLeaseName(shard), now)
+		case leaseHolder(*lease) == "" || m.leaseExpired(*lease, now):
+			err = m.updateLease(ctx, lease, m.opts.Identity, now)
+		default:
+			continue
+		}
+		if err != nil {
+			if !apierrors.IsConflict(err) && !apierrors.IsAlreadyExists(err) {
+				log.Error(err, "unable to acquire the shard lease", "shard", shard)
+			}
+			continue
+		}
+		held = append(held, shard)
+		m.hold(shard, now)
+		log.Info("shard acquired", "shard", shard, "members", members)
+
+		// enqueue the Kustomizations without holding up the renewal of the Leases
+		go func(shard int) {
+			if err := m.enqueue(ctx, shard); err != nil {
+				log.Error(err, "unable to enqueue the Kustomizations of the shard", "shard", shard)
+			}
+		}(shard)
+	}
+
+	return nil
+}
+
+func (m *ShardManager) hold(shard int, now time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.owned[shard] = now
+}
+
+func (m *ShardManager) drop(shard int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.owned, shard)
+}
+
+// enqueue triggers the reconciliation of the Kustomizations of the shard.
+func (m *ShardManager) enqueue(ctx context.Context, shard int) error {
+	var list kustomizev1.KustomizationList
+	if err := m.client.List(ctx, &list); err != nil {
+		return err
+	}
+	for i, k := range list.Items {
+		if ShardOf(types.NamespacedName{Namespace: k.GetNamespace(), Name: k.GetName()}, m.opts.Shards) != shard {
+			continue
+		}
+		select {
+		case m.events <- event.GenericEvent{Object: &list.Items[i]}:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// release gives up the shard Leases held by this replica and
+// deletes its member Lease, so that the other replicas take over
+// without waiting for the Leases to expire.
+func (m *ShardManager) release(ctx context.Context) {
+	log := ctrl.Log.WithName("sharding")
+	now := m.now()
+	for _, shard := range m.OwnedShards() {
+		m.drop(shard)
+		lease := &coordinationv1.Lease{}
+		if err := m.reader.Get(ctx, types.NamespacedName{Namespace: m.opts.Namespace, Name: m.shardLeaseName(shard)}, lease); err != nil {
+			log.Error(err, "unable to release the shard lease", "shard", shard)
+			continue
+		}
+		if leaseHolder(*lease) != m.opts.Identity {
+			continue
+		}
+		if err := m.updateLease(ctx, lease, "", now); err != nil {
+			log.Error(err, "unable to release the shard lease", "shard", shard)
+		}
+	}
+
+	member := &coordinationv1.Lease{}
+	member.SetName(m.memberLeaseName())
+	member.SetNamespace(m.opts.Namespace)
+	if err := m.client.Delete(ctx, member); client.IgnoreNotFound(err) != nil {
+		log.Error(err, "unable to delete the member lease")
+	}
+}
+
+func (m *ShardManager) renewMember(ctx context.Context, now time.Time) error {
+	lease := &coordinationv1.Lease{}
+	err := m.reader.Get(ctx, types.NamespacedName{Namespace: m.opts.Namespace, Name: m.memberLeaseName()}, lease)
+	switch {
+	case apierrors.IsNotFound(err):
+		return m.createLease(ctx, m.memberLeaseName(), now)
+	case err != nil:
+		return err
+	default:
+		return m.updateLease(ctx, lease, m.opts.Identity, now)
+	}
+}
+
+func (m *ShardManager) createLease(ctx context.Context, name string, now time.Time) error {
+	lease := &coordinationv1.Lease{}
+	lease.SetName(name)
+	lease.SetNamespace(m.opts.Namespace)
+	lease.SetLabels(map[string]string{ShardLeaseLabel: m.opts.ControllerName})
+	setLeaseHolder(lease, m.opts.Identity, m.opts.LeaseDuration, now)
+	return m.client.Create(ctx, lease)
+}
+
+// updateLease sets the holder of the Lease and renews it, an empty holder releases the Lease.
+// The update fails with a conflict if the Lease was changed since it was read.
+func (m *ShardManager) updateLease(ctx context.Context, lease *coordinationv1.Lease, holder string, now time.Time) error {
+	if leaseHolder(*lease) != holder {
+		transitions := int32(1)
+		if lease.Spec.LeaseTransitions != nil {
+			transitions = *lease.Spec.LeaseTransitions + 1
+		}
+		lease.Spec.LeaseTransitions = &transitions
+	}
+	setLeaseHolder(lease, holder, m.opts.LeaseDuration, now)
+	return m.client.Update(ctx, lease)
+}
+
+func setLeaseHolder(lease *coordinationv1.Lease, holder string, duration time.Duration, now time.Time) {
+	seconds := int32(duration.Seconds())
+	renewTime := metav1.NewMicroTime(now)
+	if leaseHolder(*lease) != holder || lease.Spec.AcquireTime == nil {
+		lease.Spec.AcquireTime = &renewTime
+	}
+	lease.Spec.HolderIdentity = &holder
+	lease.Spec.LeaseDurationSeconds = &seconds
+	lease.Spec.RenewTime = &renewTime
+}
+
+func leaseHolder(lease coordinationv1.Lease) string {
+	if lease.Spec.HolderIdentity == nil {
+		return ""
+	}
+	return *lease.Spec.HolderIdentity
+}
+
+// observe records the local time at which the changes of the Leases are first seen,
+// and forgets the Leases that were deleted.
+func (m *ShardManager) observe(leases []coordinationv1.Lease, now time.Time) {
+	seen := make(map[string]bool, len(leases))
+	for _, lease := range leases {
+		seen[lease.GetName()] = true
+		if observed, ok := m.observed[lease.GetName()]; ok && observed.resourceVersion == lease.GetResourceVersion() {
+			continue
+		}
+		m.observed[lease.GetName()] = observedLease{resourceVersion: lease.GetResourceVersion(), time: now}
+	}
+	for name := range m.observed {
+		if !seen[name] {
+			delete(m.observed, name)
+		}
+	}
+}
+
+// leaseExpired returns true if the Lease was not renewed for its duration since
+// this replica observed its last change. As in the leader election of client-go,
+// the renew time written by the holder is not compared to the local clock, so that
+// the clock skew between the replicas doesn't shorten or extend the Leases.
+func (m *ShardManager) leaseExpired(lease coordinationv1.Lease, now time.Time) bool {
+	if lease.Spec.LeaseDurationSeconds == nil {
+		return true
+	}
+	observed, ok := m.observed[lease.GetName()]
+	if !ok || observed.resourceVersion != lease.GetResourceVersion() {
+		return false
+	}
+	expiry := observed.time.Add(time.Duration(*lease.Spec.LeaseDurationSeconds) * time.Second)
+	return now.After(expiry)
+}
@@ -0,0 +1,151 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	coordinationv1 "k8s.io/api/coordination/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestShardManager_sync(t *testing.T) {
+	g := NewWithT(t)
+
+	scheme := runtime.NewScheme()
+	g.Expect(coordinationv1.AddToScheme(scheme)).To(Succeed())
+	kubeClient := fake.NewClientBuilder().WithScheme(scheme).Build()
+
+	now := time.Now()
+	newManager := func(identity string) *ShardManager {
+		m := NewShardManager(kubeClient, kubeClient, ShardOptions{
+			Shards:         4,
+			ControllerName: "kustomize-controller",
+			Namespace:      "flux-system",
+			Identity:       identity,
+			LeaseDuration:  15 * time.Second,
+			RenewDeadline:  10 * time.Second,
+			RetryPeriod:    2 * time.Second,
+		})
+		m.now = func() time.Time { return now }
+		return m
+	}
+	a, b := newManager("a"), newManager("b")
+	ctx := context.TODO()
+
+	// the first replica acquires all the shards
+	g.Expect(a.sync(ctx)).To(Succeed())
+	g.Expect(a.OwnedShards()).To(Equal([]int{0, 1, 2, 3}))
+
+	// the second replica waits for the first one to release its extra shards
+	g.Expect(b.sync(ctx)).To(Succeed())
+	g.Expect(b.OwnedShards()).To(BeEmpty())
+	g.Expect(a.sync(ctx)).To(Succeed())
+	g.Expect(a.OwnedShards()).To(Equal([]int{0, 1}))
+	g.Expect(b.sync(ctx)).To(Succeed())
+	g.Expect(b.OwnedShards()).To(Equal([]int{2, 3}))
+
+	for i := 0; i < 20; i++ {
+		key := types.NamespacedName{Namespace: "apps", Name: fmt.Sprintf("app-%d", i)}
+		g.Expect(a.Owns(key)).To(Equal(ShardOf(key, 4) < 2))
+		g.Expect(b.Owns(key)).To(Equal(ShardOf(key, 4) >= 2))
+	}
+
+	// the shards of a replica that stopped renewing its leases are taken over
+	now = now.Add(20 * time.Second)
+	g.Expect(b.sync(ctx)).To(Succeed())
+	g.Expect(b.OwnedShards()).To(Equal([]int{0, 1, 2, 3}))
+	g.Expect(a.Owns(types.NamespacedName{Namespace: "apps", Name: "app"})).To(BeFalse())
+
+	var member coordinationv1.Lease
+	err := kubeClient.Get(ctx, types.NamespacedName{Namespace: "flux-system", Name: "kustomize-controller-member-a"}, &member)
+	g.Expect(err).To(HaveOccurred())
+
+	// a replica that shuts down releases its leases immediately
+	b.release(ctx)
+	g.Expect(b.OwnedShards()).To(BeEmpty())
+	g.Expect(a.sync(ctx)).To(Succeed())
+	g.Expect(a.OwnedShards()).To(Equal([]int{0, 1, 2, 3}))
+}
+
+func TestShardManager_leaseExpiry(t *testing.T) {
+	g := NewWithT(t)
+
+	scheme := runtime.NewScheme()
+	g.Expect(coordinationv1.AddToScheme(scheme)).To(Succeed())
+	kubeClient := fake.NewClientBuilder().WithScheme(scheme).Build()
+
+	now := time.Now()
+	m := NewShardManager(kubeClient, kubeClient, ShardOptions{
+		Shards:         1,
+		ControllerName: "kustomize-controller",
+		Namespace:      "flux-system",
+		Identity:       "a",
+		LeaseDuration:  15 * time.Second,
+		RenewDeadline:  10 * time.Second,
+		RetryPeriod:    2 * time.Second,
+	})
+	m.now = func() time.Time { return now }
+	ctx := context.TODO()
+
+	// the shard is held by a replica whose clock is an hour behind
+	lease := &coordinationv1.Lease{}
+	lease.SetName("kustomize-controller-shard-0")
+	lease.SetNamespace("flux-system")
+	lease.SetLabels(map[string]string{ShardLeaseLabel: "kustomize-controller"})
+	setLeaseHolder(lease, "b", 15*time.Second, now.Add(-time.Hour))
+	g.Expect(kubeClient.Create(ctx, lease)).To(Succeed())
+
+	g.Expect(m.sync(ctx)).To(Succeed())
+	g.Expect(m.OwnedShards()).To(BeEmpty())
+
+	// the holder renews the lease, with its skewed clock
+	now = now.Add(10 * time.Second)
+	setLeaseHolder(lease, "b", 15*time.Second, now.Add(-time.Hour))
+	g.Expect(kubeClient.Update(ctx, lease)).To(Succeed())
+	g.Expect(m.sync(ctx)).To(Succeed())
+	g.Expect(m.OwnedShards()).To(BeEmpty())
+
+	// the lease expires once it hasn't changed for its duration
+	now = now.Add(10 * time.Second)
+	g.Expect(m.sync(ctx)).To(Succeed())
+	g.Expect(m.OwnedShards()).To(BeEmpty())
+	now = now.Add(10 * time.Second)
+	g.Expect(m.sync(ctx)).To(Succeed())
+	g.Expect(m.OwnedShards()).To(Equal([]int{0}))
+}
+
+func TestShardOf(t *testing.T) {
+	g := NewWithT(t)
+
+	counts := make([]int, 4)
+	for i := 0; i < 1000; i++ {
+		key := types.NamespacedName{Namespace: "apps", Name: fmt.Sprintf("app-%d", i)}
+		shard := ShardOf(key, 4)
+		g.Expect(shard).To(Equal(ShardOf(key, 4)))
+		counts[shard]++
+	}
+	for _, count := range counts {
+		g.Expect(count).To(BeNumerically(">", 200))
+	}
+}
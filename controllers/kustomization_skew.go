@@ -0,0 +1,67 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	"github.com/fluxcd/pkg/runtime/events"
+
+	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta2"
+)
+
+// recordRevisionSkew records in the status since when the last applied revision
+// differs from the latest revision of the source, and flags the Kustomization as
+// behind its source when the skew lasts longer than spec.revisionSkewThreshold.
+// It returns true if the Kustomization has just fallen behind.
+func recordRevisionSkew(kustomization *kustomizev1.Kustomization, sourceRevision string, now time.Time) bool {
+	threshold := kustomization.Spec.RevisionSkewThreshold
+	if threshold == nil || kustomization.Status.LastAppliedRevision == sourceRevision {
+		kustomization.Status.RevisionSkew = nil
+		return false
+	}
+
+	skew := kustomization.Status.RevisionSkew.DeepCopy()
+	if skew == nil {
+		skew = &kustomizev1.RevisionSkew{Since: metav1.NewTime(now)}
+	}
+	wasBehind := skew.Behind
+	skew.SourceRevision = sourceRevision
+	skew.Behind = now.Sub(skew.Since.Time) > threshold.Duration
+	kustomization.Status.RevisionSkew = skew
+
+	return skew.Behind && !wasBehind
+}
+
+// checkRevisionSkew updates the revision skew of the Kustomization and
+// emits an event when the applied revision falls behind the source.
+func (r *KustomizationReconciler) checkRevisionSkew(ctx context.Context, kustomization *kustomizev1.Kustomization, revision string) {
+	if !recordRevisionSkew(kustomization, revision, time.Now()) {
+		return
+	}
+
+	skew := kustomization.Status.RevisionSkew
+	msg := fmt.Sprintf("Applied revision '%s' is behind the source revision '%s' since %s",
+		kustomization.Status.LastAppliedRevision, skew.SourceRevision, skew.Since.Format(time.RFC3339))
+	ctrl.LoggerFrom(ctx).Info(msg)
+	r.event(ctx, *kustomization, revision, events.EventSeverityInfo, msg, nil)
+}
@@ -0,0 +1,61 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta2"
+)
+
+func TestRecordRevisionSkew(t *testing.T) {
+	g := NewWithT(t)
+
+	now := time.Now()
+	k := &kustomizev1.Kustomization{}
+	k.Status.LastAppliedRevision = "main/1"
+
+	// disabled when the threshold is not set
+	g.Expect(recordRevisionSkew(k, "main/2", now)).To(BeFalse())
+	g.Expect(k.Status.RevisionSkew).To(BeNil())
+
+	k.Spec.RevisionSkewThreshold = &metav1.Duration{Duration: 10 * time.Minute}
+	g.Expect(recordRevisionSkew(k, "main/2", now)).To(BeFalse())
+	g.Expect(k.Status.RevisionSkew.SourceRevision).To(Equal("main/2"))
+	g.Expect(k.Status.RevisionSkew.Since.Time).To(BeTemporally("~", now, time.Second))
+	g.Expect(k.Status.RevisionSkew.Behind).To(BeFalse())
+
+	// a newer source revision doesn't reset the skew
+	g.Expect(recordRevisionSkew(k, "main/3", now.Add(5*time.Minute))).To(BeFalse())
+	g.Expect(k.Status.RevisionSkew.SourceRevision).To(Equal("main/3"))
+	g.Expect(k.Status.RevisionSkew.Since.Time).To(BeTemporally("~", now, time.Second))
+
+	g.Expect(recordRevisionSkew(k, "main/3", now.Add(11*time.Minute))).To(BeTrue())
+	g.Expect(k.Status.RevisionSkew.Behind).To(BeTrue())
+
+	// reported once
+	g.Expect(recordRevisionSkew(k, "main/3", now.Add(12*time.Minute))).To(BeFalse())
+	g.Expect(k.Status.RevisionSkew.Behind).To(BeTrue())
+
+	k.Status.LastAppliedRevision = "main/3"
+	g.Expect(recordRevisionSkew(k, "main/3", now.Add(13*time.Minute))).To(BeFalse())
+	g.Expect(k.Status.RevisionSkew).To(BeNil())
+}
@@ -0,0 +1,167 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"sigs.k8s.io/kustomize/api/konfig"
+	"sigs.k8s.io/kustomize/api/resmap"
+	"sigs.k8s.io/yaml"
+
+	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta2"
+)
+
+// sortOptionsField is the kustomization.yaml field configuring the order of the
+// build output, introduced by kustomize v5 and unknown to the kustomize API used
+// for the build.
+const sortOptionsField = "sortOptions"
+
+// defaultOrderFirst and defaultOrderLast are the kinds placed first and last
+// by the kustomize legacy sort order.
+var (
+	defaultOrderFirst = []string{
+		"Namespace",
+		"ResourceQuota",
+		"StorageClass",
+		"CustomResourceDefinition",
+		"ServiceAccount",
+		"PodSecurityPolicy",
+		"Role",
+		"ClusterRole",
+		"RoleBinding",
+		"ClusterRoleBinding",
+		"ConfigMap",
+		"Secret",
+		"Endpoints",
+		"Service",
+		"LimitRange",
+		"PriorityClass",
+		"PersistentVolume",
+		"PersistentVolumeClaim",
+		"Deployment",
+		"StatefulSet",
+		"CronJob",
+		"PodDisruptionBudget",
+	}
+	defaultOrderLast = []string{
+		"MutatingWebhookConfiguration",
+		"ValidatingWebhookConfiguration",
+	}
+)
+
+// extractSortOptions returns the sortOptions of the kustomization file data,
+// if any, and the data without the field.
+func extractSortOptions(data []byte) (*kustomizev1.SortOptions, []byte, error) {
+	var fields map[string]interface{}
+	if err := yaml.Unmarshal(data, &fields); err != nil {
+		return nil, data, err
+	}
+	raw, ok := fields[sortOptionsField]
+	if !ok {
+		return nil, data, nil
+	}
+
+	b, err := yaml.Marshal(raw)
+	if err != nil {
+		return nil, data, err
+	}
+	var opts kustomizev1.SortOptions
+	if err := yaml.UnmarshalStrict(b, &opts); err != nil {
+		return nil, data, fmt.Errorf("invalid %s: %w", sortOptionsField, err)
+	}
+	if opts.Order != kustomizev1.LegacySortOrder && opts.Order != kustomizev1.FIFOSortOrder {
+		return nil, data, fmt.Errorf("invalid %s: unsupported order '%s', must be '%s' or '%s'",
+			sortOptionsField, opts.Order, kustomizev1.LegacySortOrder, kustomizev1.FIFOSortOrder)
+	}
+
+	delete(fields, sortOptionsField)
+	data, err = yaml.Marshal(fields)
+	if err != nil {
+		return nil, data, err
+	}
+	return &opts, data, nil
+}
+
+// removeSortOptions removes the sortOptions field from the kustomization
+// files found under root, so that the bases setting it can be built.
+// As with kustomize, only the sortOptions of the top-level kustomization apply.
+func removeSortOptions(root string) error {
+	names := make(map[string]bool)
+	for _, name := range konfig.RecognizedKustomizationFileNames() {
+		names[name] = true
+	}
+	return filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() || !names[d.Name()] {
+			return nil
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		opts, out, err := extractSortOptions(data)
+		if err != nil || opts == nil {
+			// the invalid files are reported by the build
+			return nil
+		}
+		return os.WriteFile(path, out, os.ModePerm)
+	})
+}
+
+// sortResources orders the resources of the build output with the given options.
+// The 'fifo' order keeps the order of the build.
+func sortResources(m resmap.ResMap, opts *kustomizev1.SortOptions) error {
+	if opts == nil || opts.Order != kustomizev1.LegacySortOrder {
+		return nil
+	}
+
+	orderFirst, orderLast := defaultOrderFirst, defaultOrderLast
+	if lso := opts.LegacySortOptions; lso != nil {
+		orderFirst, orderLast = lso.OrderFirst, lso.OrderLast
+	}
+	kindOrders := make(map[string]int)
+	for i, kind := range orderFirst {
+		kindOrders[kind] = -len(orderFirst) + i
+	}
+	for i, kind := range orderLast {
+		kindOrders[kind] = 1 + i
+	}
+
+	resources := m.Resources()
+	sort.SliceStable(resources, func(i, j int) bool {
+		oi, oj := kindOrders[resources[i].GetKind()], kindOrders[resources[j].GetKind()]
+		if oi != oj {
+			return oi < oj
+		}
+		return resources[i].CurId().LegacySortString() < resources[j].CurId().LegacySortString()
+	})
+
+	m.Clear()
+	for _, res := range resources {
+		if err := m.Append(res); err != nil {
+			return err
+		}
+	}
+	return nil
+}
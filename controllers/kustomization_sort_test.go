@@ -0,0 +1,165 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"sigs.k8s.io/kustomize/api/resmap"
+	"sigs.k8s.io/kustomize/api/resource"
+
+	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta2"
+)
+
+func Test_extractSortOptions(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		want    *kustomizev1.SortOptions
+		wantErr string
+	}{
+		{
+			name: "not set",
+			data: "resources:\n- deployment.yaml\n",
+		},
+		{
+			name: "fifo",
+			data: "resources:\n- deployment.yaml\nsortOptions:\n  order: fifo\n",
+			want: &kustomizev1.SortOptions{Order: kustomizev1.FIFOSortOrder},
+		},
+		{
+			name: "legacy with kinds",
+			data: "sortOptions:\n  order: legacy\n  legacySortOptions:\n    orderFirst: [Namespace]\n    orderLast: [Job]\n",
+			want: &kustomizev1.SortOptions{
+				Order: kustomizev1.LegacySortOrder,
+				LegacySortOptions: &kustomizev1.LegacySortOptions{
+					OrderFirst: []string{"Namespace"},
+					OrderLast:  []string{"Job"},
+				},
+			},
+		},
+		{
+			name:    "unsupported order",
+			data:    "sortOptions:\n  order: random\n",
+			wantErr: "unsupported order 'random'",
+		},
+		{
+			name:    "unknown field",
+			data:    "sortOptions:\n  order: fifo\n  reverse: true\n",
+			wantErr: "invalid sortOptions",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			opts, data, err := extractSortOptions([]byte(tt.data))
+			if tt.wantErr != "" {
+				g.Expect(err).To(HaveOccurred())
+				g.Expect(err.Error()).To(ContainSubstring(tt.wantErr))
+				return
+			}
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(opts).To(Equal(tt.want))
+			g.Expect(string(data)).NotTo(ContainSubstring("sortOptions"))
+		})
+	}
+}
+
+func Test_removeSortOptions(t *testing.T) {
+	g := NewWithT(t)
+
+	root := t.TempDir()
+	g.Expect(os.MkdirAll(filepath.Join(root, "base"), 0o755)).To(Succeed())
+	base := filepath.Join(root, "base", "kustomization.yaml")
+	g.Expect(os.WriteFile(base, []byte("resources:\n- cm.yaml\nsortOptions:\n  order: legacy\n"), 0o644)).To(Succeed())
+	other := filepath.Join(root, "base", "cm.yaml")
+	g.Expect(os.WriteFile(other, []byte("sortOptions: kept\n"), 0o644)).To(Succeed())
+
+	g.Expect(removeSortOptions(root)).To(Succeed())
+
+	data, err := os.ReadFile(base)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(string(data)).To(Equal("resources:\n- cm.yaml\n"))
+	data, err = os.ReadFile(other)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(string(data)).To(Equal("sortOptions: kept\n"))
+}
+
+func Test_sortResources(t *testing.T) {
+	newResMap := func(t *testing.T) resmap.ResMap {
+		g := NewWithT(t)
+		factory := resmap.NewFactory(resource.NewFactory(nil))
+		m, err := factory.NewResMapFromBytes([]byte(`apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: app
+  namespace: test
+---
+apiVersion: batch/v1
+kind: Job
+metadata:
+  name: migrate
+  namespace: test
+---
+apiVersion: v1
+kind: Namespace
+metadata:
+  name: test
+`))
+		g.Expect(err).NotTo(HaveOccurred())
+		return m
+	}
+	kinds := func(m resmap.ResMap) []string {
+		var result []string
+		for _, res := range m.Resources() {
+			result = append(result, res.GetKind())
+		}
+		return result
+	}
+
+	tests := []struct {
+		name string
+		opts *kustomizev1.SortOptions
+		want []string
+	}{
+		{name: "not set", opts: nil, want: []string{"Deployment", "Job", "Namespace"}},
+		{name: "fifo", opts: &kustomizev1.SortOptions{Order: kustomizev1.FIFOSortOrder}, want: []string{"Deployment", "Job", "Namespace"}},
+		{name: "legacy", opts: &kustomizev1.SortOptions{Order: kustomizev1.LegacySortOrder}, want: []string{"Namespace", "Deployment", "Job"}},
+		{
+			name: "legacy with kinds",
+			opts: &kustomizev1.SortOptions{
+				Order: kustomizev1.LegacySortOrder,
+				LegacySortOptions: &kustomizev1.LegacySortOptions{
+					OrderFirst: []string{"Job"},
+					OrderLast:  []string{"Namespace"},
+				},
+			},
+			want: []string{"Job", "Deployment", "Namespace"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			m := newResMap(t)
+			g.Expect(sortResources(m, tt.opts)).To(Succeed())
+			g.Expect(kinds(m)).To(Equal(tt.want))
+		})
+	}
+}
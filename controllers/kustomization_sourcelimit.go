@@ -0,0 +1,90 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta2"
+)
+
+// SourceLimitRequeueInterval is the interval at which the Kustomizations
+// waiting for a source slot are requeued.
+const SourceLimitRequeueInterval = 5 * time.Second
+
+// SourceLimiter caps the number of Kustomizations that reconcile the
+// artifact of the same source at once.
+type SourceLimiter struct {
+	max int
+
+	mu     sync.Mutex
+	active map[string]int
+}
+
+// NewSourceLimiter returns a SourceLimiter allowing max concurrent
+// reconciliations per source, zero means no limit.
+func NewSourceLimiter(max int) *SourceLimiter {
+	return &SourceLimiter{
+		max:    max,
+		active: make(map[string]int),
+	}
+}
+
+// TryAcquire reserves a slot for a reconciliation of the source,
+// it returns false if all the slots of the source are taken.
+func (l *SourceLimiter) TryAcquire(source string) bool {
+	if l == nil || l.max <= 0 {
+		return true
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.active[source] >= l.max {
+		return false
+	}
+	l.active[source]++
+	return true
+}
+
+// Release frees the slot reserved with TryAcquire.
+func (l *SourceLimiter) Release(source string) {
+	if l == nil || l.max <= 0 {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.active[source] <= 1 {
+		delete(l.active, source)
+		return
+	}
+	l.active[source]--
+}
+
+// sourceLimitKey returns the key identifying the source of the Kustomization.
+func sourceLimitKey(kustomization kustomizev1.Kustomization) string {
+	ref := kustomization.Spec.SourceRef
+	namespace := ref.Namespace
+	if namespace == "" {
+		namespace = kustomization.GetNamespace()
+	}
+	return fmt.Sprintf("%s/%s/%s", ref.Kind, namespace, ref.Name)
+}
@@ -0,0 +1,69 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta2"
+)
+
+func TestSourceLimiter(t *testing.T) {
+	g := NewWithT(t)
+
+	l := NewSourceLimiter(2)
+	g.Expect(l.TryAcquire("GitRepository/flux-system/monorepo")).To(BeTrue())
+	g.Expect(l.TryAcquire("GitRepository/flux-system/monorepo")).To(BeTrue())
+	g.Expect(l.TryAcquire("GitRepository/flux-system/monorepo")).To(BeFalse())
+
+	// the slots are per source
+	g.Expect(l.TryAcquire("GitRepository/flux-system/other")).To(BeTrue())
+
+	l.Release("GitRepository/flux-system/monorepo")
+	g.Expect(l.TryAcquire("GitRepository/flux-system/monorepo")).To(BeTrue())
+
+	l.Release("GitRepository/flux-system/monorepo")
+	l.Release("GitRepository/flux-system/monorepo")
+	l.Release("GitRepository/flux-system/other")
+	g.Expect(l.active).To(BeEmpty())
+
+	// no limit
+	var disabled *SourceLimiter
+	g.Expect(disabled.TryAcquire("GitRepository/flux-system/monorepo")).To(BeTrue())
+	l = NewSourceLimiter(0)
+	for i := 0; i < 10; i++ {
+		g.Expect(l.TryAcquire("GitRepository/flux-system/monorepo")).To(BeTrue())
+	}
+}
+
+func Test_sourceLimitKey(t *testing.T) {
+	g := NewWithT(t)
+
+	k := kustomizev1.Kustomization{
+		ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "apps"},
+		Spec: kustomizev1.KustomizationSpec{
+			SourceRef: kustomizev1.CrossNamespaceSourceReference{Kind: "GitRepository", Name: "monorepo"},
+		},
+	}
+	g.Expect(sourceLimitKey(k)).To(Equal("GitRepository/apps/monorepo"))
+
+	k.Spec.SourceRef.Namespace = "flux-system"
+	g.Expect(sourceLimitKey(k)).To(Equal("GitRepository/flux-system/monorepo"))
+}
@@ -0,0 +1,102 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	securejoin "github.com/cyphar/filepath-securejoin"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	"github.com/fluxcd/pkg/runtime/events"
+	sourcev1 "github.com/fluxcd/source-controller/api/v1beta2"
+
+	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta2"
+)
+
+// fetchSources downloads the artifacts of the additional sources
+// and extracts them at their paths inside the build root.
+func (r *KustomizationReconciler) fetchSources(ctx context.Context, kustomization kustomizev1.Kustomization, root string) error {
+	for _, src := range kustomization.Spec.Sources {
+		source, err := r.getSourceByRef(ctx, kustomization, src.SourceRef)
+		if err != nil {
+			return fmt.Errorf("failed to get source '%s': %w", src.SourceRef.String(), err)
+		}
+
+		if source.GetArtifact() == nil {
+			return fmt.Errorf("source '%s' is not ready, artifact not found", src.SourceRef.String())
+		}
+
+		dir, err := securejoin.SecureJoin(root, src.Path)
+		if err != nil {
+			return err
+		}
+		if dir == root {
+			return fmt.Errorf("source '%s' path must not be the build root", src.SourceRef.String())
+		}
+		if _, err := os.Stat(dir); err == nil {
+			return fmt.Errorf("source '%s' path '%s' already exists in the artifact", src.SourceRef.String(), src.Path)
+		}
+		if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+			return err
+		}
+
+		if err := r.fetchArtifact(ctx, kustomization, source.GetArtifact(), dir, nil); err != nil {
+			return fmt.Errorf("failed to fetch source '%s': %w", src.SourceRef.String(), err)
+		}
+	}
+
+	return nil
+}
+
+// fetchArtifact downloads and extracts the artifact to dir. When the download
+// is corrupted, the extracted files are purged and the artifact is fetched once
+// more, so that a transient failure doesn't fail the reconciliation.
+func (r *KustomizationReconciler) fetchArtifact(ctx context.Context, kustomization kustomizev1.Kustomization,
+	artifact *sourcev1.Artifact, dir string, filter ArtifactFilterFunc) error {
+	err := r.artifactFetcher.FetchWithFilter(artifact, dir, filter)
+	if err == nil || !errors.Is(err, ArtifactCorruptedError) {
+		return err
+	}
+
+	msg := fmt.Sprintf("Artifact '%s' is corrupted, fetching it again: %s", artifact.URL, err.Error())
+	ctrl.LoggerFrom(ctx).Info(msg)
+	r.event(ctx, kustomization, artifact.Revision, events.EventSeverityInfo, msg, nil)
+
+	if err := purgeDir(dir); err != nil {
+		return fmt.Errorf("failed to purge corrupted artifact: %w", err)
+	}
+	return r.artifactFetcher.FetchWithFilter(artifact, dir, filter)
+}
+
+// purgeDir removes the content of dir, keeping the directory itself.
+func purgeDir(dir string) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if err := os.RemoveAll(filepath.Join(dir, entry.Name())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"sigs.k8s.io/cli-utils/pkg/object"
+
+	"github.com/fluxcd/pkg/ssa"
+
+	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta2"
+)
+
+// stabilityCheckInterval is the interval at which the health checks
+// are repeated during the stability window.
+var stabilityCheckInterval = 5 * time.Second
+
+// stabilityWindow returns the duration the health checks must keep passing
+// before the Kustomization is marked as healthy, zero if not set.
+func stabilityWindow(kustomization kustomizev1.Kustomization) time.Duration {
+	if opts := kustomization.Spec.HealthCheckOptions; opts != nil && opts.StableFor != nil {
+		return opts.StableFor.Duration
+	}
+	return 0
+}
+
+// checkStability repeats the health checks of the objects and endpoints until
+// the window has elapsed, and returns an error as soon as one of them fails.
+// An object that is not ready is given one check interval to recover.
+func checkStability(ctx context.Context, manager *ssa.ResourceManager, objects []object.ObjMetadata,
+	prober *EndpointProber, endpoints []kustomizev1.EndpointHealthCheck, window time.Duration) error {
+	start := time.Now()
+	for {
+		remaining := window - time.Since(start)
+		if remaining <= 0 {
+			return nil
+		}
+		delay := stabilityCheckInterval
+		if remaining < delay {
+			delay = remaining
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+
+		if len(objects) > 0 {
+			if err := manager.WaitForSet(objects, ssa.WaitOptions{
+				Interval: time.Second,
+				Timeout:  stabilityCheckInterval,
+			}); err != nil {
+				return fmt.Errorf("health checks did not stay green for %s, %w", window.String(), err)
+			}
+		}
+
+		for _, check := range endpoints {
+			if err := prober.probeEndpoint(ctx, check); err != nil {
+				return fmt.Errorf("health checks did not stay green for %s, %s endpoint '%s' is unavailable: %w",
+					window.String(), endpointCheckType(check), check.Address, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,98 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta2"
+)
+
+func Test_stabilityWindow(t *testing.T) {
+	g := NewWithT(t)
+
+	k := kustomizev1.Kustomization{}
+	g.Expect(stabilityWindow(k)).To(BeZero())
+
+	k.Spec.HealthCheckOptions = &kustomizev1.HealthCheckOptions{}
+	g.Expect(stabilityWindow(k)).To(BeZero())
+
+	k.Spec.HealthCheckOptions.StableFor = &metav1.Duration{Duration: time.Minute}
+	g.Expect(stabilityWindow(k)).To(Equal(time.Minute))
+}
+
+func Test_checkStability(t *testing.T) {
+	stabilityCheckInterval = 10 * time.Millisecond
+	defer func() {
+		stabilityCheckInterval = 5 * time.Second
+	}()
+
+	var healthyRequests int32
+	healthy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&healthyRequests, 1)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer healthy.Close()
+
+	var requests int32
+	crashing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) > 2 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer crashing.Close()
+
+	prober := NewEndpointProber([]string{"127.0.0.1"})
+
+	t.Run("stays green", func(t *testing.T) {
+		g := NewWithT(t)
+		err := checkStability(context.TODO(), nil, nil, prober, []kustomizev1.EndpointHealthCheck{
+			{Address: healthy.URL},
+		}, 100*time.Millisecond)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(atomic.LoadInt32(&healthyRequests)).To(BeNumerically(">", 1))
+	})
+
+	t.Run("fails within the window", func(t *testing.T) {
+		g := NewWithT(t)
+		err := checkStability(context.TODO(), nil, nil, prober, []kustomizev1.EndpointHealthCheck{
+			{Address: healthy.URL},
+			{Address: crashing.URL},
+		}, time.Minute)
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(err.Error()).To(ContainSubstring("did not stay green for 1m0s"))
+		g.Expect(err.Error()).To(ContainSubstring("503 Service Unavailable"))
+	})
+
+	t.Run("stops when the context is cancelled", func(t *testing.T) {
+		g := NewWithT(t)
+		ctx, cancel := context.WithCancel(context.TODO())
+		cancel()
+		err := checkStability(ctx, nil, nil, prober, nil, time.Minute)
+		g.Expect(err).To(MatchError(context.Canceled))
+	})
+}
@@ -0,0 +1,112 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/fluxcd/pkg/ssa"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/cli-utils/pkg/object"
+
+	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta2"
+)
+
+// applyStage is a group of objects applied together, and health checked
+// before the objects of the next stage are applied.
+type applyStage struct {
+	name    string
+	objects []*unstructured.Unstructured
+}
+
+// ids returns the metadata of the objects of the stage.
+func (s applyStage) ids() object.ObjMetadataSet {
+	set := make(object.ObjMetadataSet, 0, len(s.objects))
+	for _, u := range s.objects {
+		set = append(set, object.UnstructuredToObjMetadata(u))
+	}
+	return set
+}
+
+// applyStages splits the objects in the spec.applyOptions.stages, in order,
+// followed by the objects that are not part of any stage. An object is part
+// of the stage named by its apply-stage annotation, or else of the first
+// stage with a matching selector. The objects of each stage are sorted by
+// kind and the stages without objects are left out.
+func applyStages(kustomization kustomizev1.Kustomization,
+	objects []*unstructured.Unstructured) ([]applyStage, error) {
+	var specStages []kustomizev1.ApplyStage
+	if kustomization.Spec.ApplyOptions != nil {
+		specStages = kustomization.Spec.ApplyOptions.Stages
+	}
+
+	index := make(map[string]int, len(specStages))
+	for i, stage := range specStages {
+		index[stage.Name] = i
+	}
+
+	// the last stage holds the objects that are not part of any stage
+	stages := make([]applyStage, len(specStages)+1)
+	for i, stage := range specStages {
+		stages[i].name = stage.Name
+	}
+
+	for _, u := range objects {
+		i, err := stageIndex(specStages, index, u)
+		if err != nil {
+			return nil, err
+		}
+		stages[i].objects = append(stages[i].objects, u)
+	}
+
+	var result []applyStage
+	for _, stage := range stages {
+		if len(stage.objects) == 0 {
+			continue
+		}
+		sort.Sort(ssa.SortableUnstructureds(stage.objects))
+		result = append(result, stage)
+	}
+	return result, nil
+}
+
+// stageIndex returns the index of the stage the object is part of,
+// or len(stages) if the object is not part of any stage.
+func stageIndex(stages []kustomizev1.ApplyStage, index map[string]int, u *unstructured.Unstructured) (int, error) {
+	if name, ok := u.GetAnnotations()[kustomizev1.ApplyStageAnnotation]; ok {
+		i, found := index[name]
+		if !found {
+			return 0, fmt.Errorf("%s references the apply stage '%s' which is not defined in spec.applyOptions.stages",
+				ssa.FmtUnstructured(u), name)
+		}
+		return i, nil
+	}
+
+	for i, stage := range stages {
+		for _, selector := range stage.Selectors {
+			ok, err := selectorMatches(selector, u)
+			if err != nil {
+				return 0, err
+			}
+			if ok {
+				return i, nil
+			}
+		}
+	}
+	return len(stages), nil
+}
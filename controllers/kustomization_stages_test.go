@@ -0,0 +1,92 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"testing"
+
+	"github.com/fluxcd/pkg/apis/kustomize"
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta2"
+)
+
+func Test_applyStages(t *testing.T) {
+	g := NewWithT(t)
+
+	newObject := func(apiVersion, kind, name, stage string) *unstructured.Unstructured {
+		u := &unstructured.Unstructured{}
+		u.SetAPIVersion(apiVersion)
+		u.SetKind(kind)
+		u.SetNamespace("apps")
+		u.SetName(name)
+		if stage != "" {
+			u.SetAnnotations(map[string]string{kustomizev1.ApplyStageAnnotation: stage})
+		}
+		return u
+	}
+
+	objects := []*unstructured.Unstructured{
+		newObject("cert-manager.io/v1", "Certificate", "tls", ""),
+		newObject("apps/v1", "Deployment", "operator", ""),
+		newObject("v1", "ServiceAccount", "operator", "operators"),
+		newObject("v1", "ConfigMap", "dashboards", ""),
+	}
+
+	names := func(stages []applyStage) map[string][]string {
+		result := make(map[string][]string)
+		for _, stage := range stages {
+			for _, u := range stage.objects {
+				result[stage.name] = append(result[stage.name], u.GetKind())
+			}
+		}
+		return result
+	}
+
+	k := kustomizev1.Kustomization{}
+	k.Spec.ApplyOptions = &kustomizev1.ApplyOptions{
+		Stages: []kustomizev1.ApplyStage{
+			{Name: "operators", Selectors: []kustomize.Selector{{Kind: "Deployment", Name: "operator"}}},
+			{Name: "resources", Selectors: []kustomize.Selector{{Group: "cert-manager.io"}}},
+			{Name: "empty"},
+		},
+	}
+
+	stages, err := applyStages(k, objects)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(stages).To(HaveLen(3))
+	g.Expect(stages[0].name).To(Equal("operators"))
+	g.Expect(stages[1].name).To(Equal("resources"))
+	g.Expect(stages[2].name).To(BeEmpty())
+	g.Expect(names(stages)).To(Equal(map[string][]string{
+		"operators": {"ServiceAccount", "Deployment"},
+		"resources": {"Certificate"},
+		"":          {"ConfigMap"},
+	}))
+	g.Expect(stages[0].ids()).To(HaveLen(2))
+
+	stages, err = applyStages(kustomizev1.Kustomization{}, objects[:2])
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(stages).To(HaveLen(1))
+	g.Expect(stages[0].objects).To(HaveLen(2))
+
+	objects = append(objects, newObject("v1", "Secret", "token", "unknown"))
+	_, err = applyStages(k, objects)
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(err.Error()).To(ContainSubstring("apply stage 'unknown'"))
+}
@@ -0,0 +1,120 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/reference"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	"github.com/fluxcd/pkg/apis/meta"
+	"github.com/fluxcd/pkg/runtime/events"
+	sourcev1 "github.com/fluxcd/source-controller/api/v1beta2"
+
+	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta2"
+)
+
+// sourceStaleSince returns since when the source has failed to produce a new
+// artifact, and false if the source is ready. The time is the latest of the
+// artifact update and the transition of the source out of readiness.
+func sourceStaleSince(source sourcev1.Source) (time.Time, bool) {
+	var since time.Time
+	if obj, ok := source.(metav1.Object); ok {
+		since = obj.GetCreationTimestamp().Time
+	}
+
+	if obj, ok := source.(interface{ GetConditions() []metav1.Condition }); ok {
+		ready := apimeta.FindStatusCondition(obj.GetConditions(), meta.ReadyCondition)
+		if ready != nil {
+			if ready.Status == metav1.ConditionTrue {
+				return time.Time{}, false
+			}
+			since = ready.LastTransitionTime.Time
+		}
+	}
+
+	if artifact := source.GetArtifact(); artifact != nil && artifact.LastUpdateTime.After(since) {
+		since = artifact.LastUpdateTime.Time
+	}
+	return since, true
+}
+
+// recordSourceStaleness sets the SourceStaleCondition on the Kustomization when
+// the source has been failing for longer than the threshold, and removes it
+// otherwise. It returns true if the source has just become stale.
+func recordSourceStaleness(kustomization *kustomizev1.Kustomization, source sourcev1.Source,
+	threshold time.Duration, now time.Time) bool {
+	since, failing := sourceStaleSince(source)
+	if threshold <= 0 || !failing || now.Sub(since) <= threshold {
+		apimeta.RemoveStatusCondition(kustomization.GetStatusConditions(), kustomizev1.SourceStaleCondition)
+		return false
+	}
+
+	reason := kustomizev1.StaleArtifactReason
+	msg := fmt.Sprintf("Source has not produced a new artifact since %s", since.Format(time.RFC3339))
+	if source.GetArtifact() == nil {
+		reason = kustomizev1.ArtifactFailedReason
+		msg = fmt.Sprintf("Source has no artifact since %s", since.Format(time.RFC3339))
+	}
+
+	wasStale := apimeta.IsStatusConditionTrue(kustomization.Status.Conditions, kustomizev1.SourceStaleCondition)
+	apimeta.SetStatusCondition(kustomization.GetStatusConditions(), metav1.Condition{
+		Type:               kustomizev1.SourceStaleCondition,
+		Status:             metav1.ConditionTrue,
+		Reason:             reason,
+		Message:            msg,
+		ObservedGeneration: kustomization.Generation,
+	})
+	return !wasStale
+}
+
+// checkSourceStaleness updates the SourceStaleCondition of the Kustomization,
+// records it as a metric and emits an event when the source becomes stale.
+func (r *KustomizationReconciler) checkSourceStaleness(ctx context.Context, kustomization *kustomizev1.Kustomization, source sourcev1.Source) {
+	log := ctrl.LoggerFrom(ctx)
+	if recordSourceStaleness(kustomization, source, r.SourceStaleThreshold, time.Now()) {
+		msg := apimeta.FindStatusCondition(kustomization.Status.Conditions, kustomizev1.SourceStaleCondition).Message
+		revision := "unknown"
+		if artifact := source.GetArtifact(); artifact != nil {
+			revision = artifact.Revision
+		}
+		log.Info(msg)
+		r.event(ctx, *kustomization, revision, events.EventSeverityInfo, msg, nil)
+	}
+
+	if r.MetricsRecorder == nil || r.SourceStaleThreshold <= 0 {
+		return
+	}
+	objRef, err := reference.GetReference(r.Scheme, kustomization)
+	if err != nil {
+		log.Error(err, "unable to record source stale metric")
+		return
+	}
+	condition := metav1.Condition{
+		Type:   kustomizev1.SourceStaleCondition,
+		Status: metav1.ConditionFalse,
+	}
+	if c := apimeta.FindStatusCondition(kustomization.Status.Conditions, kustomizev1.SourceStaleCondition); c != nil {
+		condition = *c
+	}
+	r.MetricsRecorder.RecordCondition(*objRef, condition, !kustomization.DeletionTimestamp.IsZero())
+}
@@ -0,0 +1,89 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/fluxcd/pkg/apis/meta"
+	sourcev1 "github.com/fluxcd/source-controller/api/v1beta2"
+
+	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta2"
+)
+
+func TestRecordSourceStaleness(t *testing.T) {
+	g := NewWithT(t)
+
+	now := time.Now()
+	k := &kustomizev1.Kustomization{}
+	k.Generation = 2
+	source := &sourcev1.GitRepository{}
+	source.CreationTimestamp = metav1.NewTime(now.Add(-time.Hour))
+	source.Status.Artifact = &sourcev1.Artifact{
+		Revision:       "main/1",
+		LastUpdateTime: metav1.NewTime(now.Add(-30 * time.Minute)),
+	}
+	source.Status.Conditions = []metav1.Condition{{
+		Type:               meta.ReadyCondition,
+		Status:             metav1.ConditionTrue,
+		LastTransitionTime: metav1.NewTime(now.Add(-time.Hour)),
+	}}
+
+	// a ready source is never stale
+	g.Expect(recordSourceStaleness(k, source, 10*time.Minute, now)).To(BeFalse())
+	g.Expect(apimeta.FindStatusCondition(k.Status.Conditions, kustomizev1.SourceStaleCondition)).To(BeNil())
+
+	// the failure started after the last artifact update
+	source.Status.Conditions[0].Status = metav1.ConditionFalse
+	source.Status.Conditions[0].LastTransitionTime = metav1.NewTime(now.Add(-5 * time.Minute))
+	g.Expect(recordSourceStaleness(k, source, 10*time.Minute, now)).To(BeFalse())
+
+	g.Expect(recordSourceStaleness(k, source, 10*time.Minute, now.Add(6*time.Minute))).To(BeTrue())
+	c := apimeta.FindStatusCondition(k.Status.Conditions, kustomizev1.SourceStaleCondition)
+	g.Expect(c).ToNot(BeNil())
+	g.Expect(c.Status).To(Equal(metav1.ConditionTrue))
+	g.Expect(c.Reason).To(Equal(kustomizev1.StaleArtifactReason))
+	g.Expect(c.ObservedGeneration).To(Equal(int64(2)))
+
+	// reported once
+	g.Expect(recordSourceStaleness(k, source, 10*time.Minute, now.Add(7*time.Minute))).To(BeFalse())
+	g.Expect(apimeta.IsStatusConditionTrue(k.Status.Conditions, kustomizev1.SourceStaleCondition)).To(BeTrue())
+
+	// a missing artifact is stale since the source failed
+	source.Status.Artifact = nil
+	recordSourceStaleness(k, source, 10*time.Minute, now.Add(7*time.Minute))
+	c = apimeta.FindStatusCondition(k.Status.Conditions, kustomizev1.SourceStaleCondition)
+	g.Expect(c.Reason).To(Equal(kustomizev1.ArtifactFailedReason))
+
+	// the source recovered
+	source.Status.Conditions[0].Status = metav1.ConditionTrue
+	g.Expect(recordSourceStaleness(k, source, 10*time.Minute, now.Add(8*time.Minute))).To(BeFalse())
+	g.Expect(apimeta.FindStatusCondition(k.Status.Conditions, kustomizev1.SourceStaleCondition)).To(BeNil())
+
+	// a source without conditions is stale since its creation
+	source.Status.Conditions = nil
+	g.Expect(recordSourceStaleness(k, source, 10*time.Minute, now)).To(BeTrue())
+
+	// disabled when the threshold is not set
+	g.Expect(recordSourceStaleness(k, source, 0, now)).To(BeFalse())
+	g.Expect(apimeta.FindStatusCondition(k.Status.Conditions, kustomizev1.SourceStaleCondition)).To(BeNil())
+}
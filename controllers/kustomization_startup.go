@@ -0,0 +1,155 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta2"
+)
+
+const (
+	// StartupReconcileAll reconciles all the Kustomizations at once after the controller starts.
+	StartupReconcileAll = "all"
+
+	// StartupReconcileOrdered reconciles the Kustomizations after the controller starts
+	// in the order of their dependencies, one at a time at the stagger interval.
+	StartupReconcileOrdered = "ordered"
+)
+
+// StartupScheduler delays the first reconciliation of the Kustomizations
+// after the controller starts, according to the startup strategy.
+type StartupScheduler struct {
+	strategy string
+	stagger  time.Duration
+	now      func() time.Time
+
+	mu       sync.Mutex
+	schedule map[types.NamespacedName]time.Time
+}
+
+// NewStartupScheduler returns a StartupScheduler for the given strategy,
+// with the ordered strategy the Kustomizations start at the stagger interval.
+func NewStartupScheduler(strategy string, stagger time.Duration) (*StartupScheduler, error) {
+	switch strategy {
+	case StartupReconcileAll, StartupReconcileOrdered:
+	default:
+		return nil, fmt.Errorf("unsupported startup reconcile strategy '%s', must be one of: %s, %s",
+			strategy, StartupReconcileAll, StartupReconcileOrdered)
+	}
+	return &StartupScheduler{
+		strategy: strategy,
+		stagger:  stagger,
+		now:      time.Now,
+	}, nil
+}
+
+// Delay returns the duration the reconciliation of the Kustomization must wait for.
+// The schedule is computed from the Kustomizations found on the first call,
+// the Kustomizations created afterwards are never delayed.
+func (s *StartupScheduler) Delay(ctx context.Context, reader client.Reader, key types.NamespacedName) (time.Duration, error) {
+	if s == nil || s.strategy != StartupReconcileOrdered {
+		return 0, nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	if s.schedule == nil {
+		var list kustomizev1.KustomizationList
+		if err := reader.List(ctx, &list); err != nil {
+			return 0, fmt.Errorf("unable to list Kustomizations: %w", err)
+		}
+		s.schedule = make(map[types.NamespacedName]time.Time)
+		for i, key := range startupOrder(list.Items) {
+			s.schedule[key] = now.Add(time.Duration(i) * s.stagger)
+		}
+	}
+
+	at, ok := s.schedule[key]
+	if !ok {
+		return 0, nil
+	}
+	if delay := at.Sub(now); delay > 0 {
+		return delay, nil
+	}
+	delete(s.schedule, key)
+	return 0, nil
+}
+
+// startupOrder returns the Kustomizations that aren't suspended
+// sorted by the depth of their dependencies, then by namespace and name.
+func startupOrder(kustomizations []kustomizev1.Kustomization) []types.NamespacedName {
+	index := make(map[types.NamespacedName]kustomizev1.Kustomization, len(kustomizations))
+	for _, k := range kustomizations {
+		index[types.NamespacedName{Namespace: k.GetNamespace(), Name: k.GetName()}] = k
+	}
+
+	depths := make(map[types.NamespacedName]int, len(kustomizations))
+	visiting := make(map[types.NamespacedName]bool)
+	var depth func(key types.NamespacedName) int
+	depth = func(key types.NamespacedName) int {
+		if d, ok := depths[key]; ok {
+			return d
+		}
+		k, ok := index[key]
+		// missing dependencies and cycles don't add to the depth
+		if !ok || visiting[key] {
+			return -1
+		}
+		visiting[key] = true
+		d := 0
+		for _, dep := range k.Spec.DependsOn {
+			namespace := k.GetNamespace()
+			if dep.Namespace != "" {
+				namespace = dep.Namespace
+			}
+			if dd := depth(types.NamespacedName{Namespace: namespace, Name: dep.Name}) + 1; dd > d {
+				d = dd
+			}
+		}
+		visiting[key] = false
+		depths[key] = d
+		return d
+	}
+
+	var keys []types.NamespacedName
+	for key, k := range index {
+		if !k.Spec.Suspend {
+			keys = append(keys, key)
+		}
+	}
+	// walk the Kustomizations in a stable order for the cycles to always break at the same point
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].String() < keys[j].String()
+	})
+	for _, key := range keys {
+		depth(key)
+	}
+	sort.SliceStable(keys, func(i, j int) bool {
+		return depths[keys[i]] < depths[keys[j]]
+	})
+	return keys
+}
@@ -0,0 +1,121 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/fluxcd/pkg/apis/meta"
+
+	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta2"
+)
+
+func newStartupKustomization(namespace, name string, dependsOn ...meta.NamespacedObjectReference) *kustomizev1.Kustomization {
+	return &kustomizev1.Kustomization{
+		ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name},
+		Spec:       kustomizev1.KustomizationSpec{DependsOn: dependsOn},
+	}
+}
+
+func Test_startupOrder(t *testing.T) {
+	g := NewWithT(t)
+
+	suspended := newStartupKustomization("flux-system", "suspended")
+	suspended.Spec.Suspend = true
+
+	order := startupOrder([]kustomizev1.Kustomization{
+		*newStartupKustomization("apps", "frontend",
+			meta.NamespacedObjectReference{Name: "backend"}),
+		*newStartupKustomization("apps", "backend",
+			meta.NamespacedObjectReference{Name: "infra", Namespace: "flux-system"}),
+		*newStartupKustomization("flux-system", "infra"),
+		*newStartupKustomization("flux-system", "crds"),
+		*newStartupKustomization("flux-system", "orphan",
+			meta.NamespacedObjectReference{Name: "missing"}),
+		*newStartupKustomization("flux-system", "cycle-a",
+			meta.NamespacedObjectReference{Name: "cycle-b"}),
+		*newStartupKustomization("flux-system", "cycle-b",
+			meta.NamespacedObjectReference{Name: "cycle-a"}),
+		*suspended,
+	})
+
+	g.Expect(order).To(Equal([]types.NamespacedName{
+		{Namespace: "flux-system", Name: "crds"},
+		{Namespace: "flux-system", Name: "cycle-b"},
+		{Namespace: "flux-system", Name: "infra"},
+		{Namespace: "flux-system", Name: "orphan"},
+		{Namespace: "apps", Name: "backend"},
+		{Namespace: "flux-system", Name: "cycle-a"},
+		{Namespace: "apps", Name: "frontend"},
+	}))
+}
+
+func TestStartupScheduler_Delay(t *testing.T) {
+	g := NewWithT(t)
+
+	scheme := runtime.NewScheme()
+	g.Expect(kustomizev1.AddToScheme(scheme)).To(Succeed())
+	kubeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+		newStartupKustomization("apps", "app", meta.NamespacedObjectReference{Name: "infra", Namespace: "flux-system"}),
+		newStartupKustomization("flux-system", "infra"),
+	).Build()
+
+	_, err := NewStartupScheduler("random", time.Second)
+	g.Expect(err).To(HaveOccurred())
+
+	all, err := NewStartupScheduler(StartupReconcileAll, time.Second)
+	g.Expect(err).NotTo(HaveOccurred())
+	delay, err := all.Delay(context.TODO(), kubeClient, types.NamespacedName{Namespace: "apps", Name: "app"})
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(delay).To(BeZero())
+
+	now := time.Now()
+	ordered, err := NewStartupScheduler(StartupReconcileOrdered, 5*time.Second)
+	g.Expect(err).NotTo(HaveOccurred())
+	ordered.now = func() time.Time { return now }
+
+	delay, err = ordered.Delay(context.TODO(), kubeClient, types.NamespacedName{Namespace: "apps", Name: "app"})
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(delay).To(Equal(5 * time.Second))
+
+	delay, err = ordered.Delay(context.TODO(), kubeClient, types.NamespacedName{Namespace: "flux-system", Name: "infra"})
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(delay).To(BeZero())
+
+	now = now.Add(5 * time.Second)
+	delay, err = ordered.Delay(context.TODO(), kubeClient, types.NamespacedName{Namespace: "apps", Name: "app"})
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(delay).To(BeZero())
+
+	// the Kustomizations created after the start aren't delayed
+	delay, err = ordered.Delay(context.TODO(), kubeClient, types.NamespacedName{Namespace: "apps", Name: "new"})
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(delay).To(BeZero())
+
+	var nilScheduler *StartupScheduler
+	delay, err = nilScheduler.Delay(context.TODO(), kubeClient, types.NamespacedName{Namespace: "apps", Name: "app"})
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(delay).To(BeZero())
+}
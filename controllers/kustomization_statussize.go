@@ -0,0 +1,147 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta2"
+)
+
+// maxStatusWarningLength is the maximum length of a warning recorded in the status.
+const maxStatusWarningLength = 1024
+
+// limitStatus keeps the given status within its size limits. The warnings are
+// truncated to kustomizev1.MaxStatusWarnings entries, and while the serialized
+// status exceeds kustomizev1.MaxStatusSize, the informational fields are cleared
+// starting with the least useful one. The truncated fields are recorded in
+// status.truncatedFields, and the returned messages hold their content.
+func limitStatus(status *kustomizev1.KustomizationStatus) []string {
+	status.TruncatedFields = nil
+	var overflow []string
+
+	warnings, omitted := limitWarnings(status.Warnings)
+	status.Warnings = warnings
+	if len(omitted) > 0 {
+		overflow = append(overflow, fmt.Sprintf("status.warnings truncated to %d entries, not recorded:\n%s",
+			kustomizev1.MaxStatusWarnings, strings.Join(omitted, "\n")))
+		status.TruncatedFields = append(status.TruncatedFields, "warnings")
+	}
+
+	clearers := []struct {
+		field string
+		clear func() string
+	}{
+		{"slowestApplies", func() string {
+			var lines []string
+			for _, entry := range status.SlowestApplies {
+				lines = append(lines, fmt.Sprintf("%s %s", entry.ID, entry.Duration.Duration))
+			}
+			status.SlowestApplies = nil
+			return strings.Join(lines, "\n")
+		}},
+		{"warnings", func() string {
+			lines := status.Warnings
+			status.Warnings = nil
+			return strings.Join(lines, "\n")
+		}},
+		{"objectPolicies", func() string {
+			var lines []string
+			for _, policy := range status.ObjectPolicies {
+				for _, entry := range policy.Entries {
+					lines = append(lines, fmt.Sprintf("%s %s", policy.Policy, entry.ID))
+				}
+			}
+			status.ObjectPolicies = nil
+			return strings.Join(lines, "\n")
+		}},
+		{"inventory.entries.images", func() string {
+			if status.Inventory == nil {
+				return ""
+			}
+			var lines []string
+			for i, entry := range status.Inventory.Entries {
+				if len(entry.Images) > 0 {
+					lines = append(lines, fmt.Sprintf("%s %s", entry.ID, strings.Join(entry.Images, ",")))
+					status.Inventory.Entries[i].Images = nil
+				}
+			}
+			return strings.Join(lines, "\n")
+		}},
+		{"lastAttemptedFailures", func() string {
+			var lines []string
+			for _, failure := range status.LastAttemptedFailures {
+				lines = append(lines, fmt.Sprintf("%s %s: %s", failure.ID, failure.Reason, failure.Message))
+			}
+			status.LastAttemptedFailures = nil
+			return strings.Join(lines, "\n")
+		}},
+	}
+
+	for _, c := range clearers {
+		if statusSize(status) <= kustomizev1.MaxStatusSize {
+			break
+		}
+		content := c.clear()
+		if content == "" {
+			continue
+		}
+		overflow = append(overflow, fmt.Sprintf("status.%s cleared to keep the status under %d bytes:\n%s",
+			c.field, kustomizev1.MaxStatusSize, content))
+		if len(omitted) == 0 || c.field != "warnings" {
+			status.TruncatedFields = append(status.TruncatedFields, c.field)
+		}
+	}
+
+	return overflow
+}
+
+// limitWarnings returns at most kustomizev1.MaxStatusWarnings warnings, each
+// trimmed to maxStatusWarningLength, the last one being a truncation marker
+// when some warnings were left out, along with the warnings left out.
+func limitWarnings(warnings []string) ([]string, []string) {
+	if len(warnings) == 0 {
+		return warnings, nil
+	}
+
+	result := make([]string, 0, len(warnings))
+	for _, warning := range warnings {
+		if len(warning) > maxStatusWarningLength {
+			warning = warning[:maxStatusWarningLength] + "..."
+		}
+		result = append(result, warning)
+	}
+	if len(result) <= kustomizev1.MaxStatusWarnings {
+		return result, nil
+	}
+
+	kept := kustomizev1.MaxStatusWarnings - 1
+	omitted := warnings[kept:]
+	result = append(result[:kept], fmt.Sprintf("... and %d more warnings", len(omitted)))
+	return result, omitted
+}
+
+// statusSize returns the size in bytes of the serialized status.
+func statusSize(status *kustomizev1.KustomizationStatus) int {
+	data, err := json.Marshal(status)
+	if err != nil {
+		return 0
+	}
+	return len(data)
+}
@@ -0,0 +1,116 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	. "github.com/onsi/gomega"
+
+	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta2"
+)
+
+func Test_limitWarnings(t *testing.T) {
+	g := NewWithT(t)
+
+	warnings, omitted := limitWarnings(nil)
+	g.Expect(warnings).To(BeNil())
+	g.Expect(omitted).To(BeEmpty())
+
+	warnings, omitted = limitWarnings([]string{"a", strings.Repeat("b", maxStatusWarningLength+10)})
+	g.Expect(omitted).To(BeEmpty())
+	g.Expect(warnings).To(HaveLen(2))
+	g.Expect(warnings[1]).To(HaveLen(maxStatusWarningLength + 3))
+	g.Expect(warnings[1]).To(HaveSuffix("..."))
+
+	var many []string
+	for i := 0; i < kustomizev1.MaxStatusWarnings+10; i++ {
+		many = append(many, fmt.Sprintf("warning %d", i))
+	}
+	warnings, omitted = limitWarnings(many)
+	g.Expect(warnings).To(HaveLen(kustomizev1.MaxStatusWarnings))
+	g.Expect(warnings[kustomizev1.MaxStatusWarnings-1]).To(Equal("... and 11 more warnings"))
+	g.Expect(omitted).To(HaveLen(11))
+	g.Expect(omitted[0]).To(Equal(fmt.Sprintf("warning %d", kustomizev1.MaxStatusWarnings-1)))
+}
+
+func Test_limitStatus(t *testing.T) {
+	t.Run("keeps a small status", func(t *testing.T) {
+		g := NewWithT(t)
+
+		status := &kustomizev1.KustomizationStatus{
+			Warnings:        []string{"deprecated field 'bases'"},
+			TruncatedFields: []string{"warnings"},
+			LastAttemptedFailures: []kustomizev1.ObjectFailure{
+				{ID: "default_backend__Service", Reason: "Invalid", Message: "invalid"},
+			},
+		}
+		g.Expect(limitStatus(status)).To(BeEmpty())
+		g.Expect(status.TruncatedFields).To(BeNil())
+		g.Expect(status.Warnings).To(HaveLen(1))
+		g.Expect(status.LastAttemptedFailures).To(HaveLen(1))
+	})
+
+	t.Run("truncates the warnings", func(t *testing.T) {
+		g := NewWithT(t)
+
+		status := &kustomizev1.KustomizationStatus{}
+		for i := 0; i < kustomizev1.MaxStatusWarnings+1; i++ {
+			status.Warnings = append(status.Warnings, fmt.Sprintf("warning %d", i))
+		}
+		overflow := limitStatus(status)
+		g.Expect(overflow).To(HaveLen(1))
+		g.Expect(overflow[0]).To(ContainSubstring("warning 50"))
+		g.Expect(status.Warnings).To(HaveLen(kustomizev1.MaxStatusWarnings))
+		g.Expect(status.TruncatedFields).To(Equal([]string{"warnings"}))
+	})
+
+	t.Run("clears the informational fields of a large status", func(t *testing.T) {
+		g := NewWithT(t)
+
+		status := &kustomizev1.KustomizationStatus{
+			SlowestApplies: []kustomizev1.ApplyDuration{
+				{ID: "default_backend__Service"},
+			},
+			ObjectPolicies: []kustomizev1.ObjectPolicy{
+				{Policy: kustomizev1.ForceObjectPolicy, Entries: []kustomizev1.ResourceRef{{ID: "default_backend__Service"}}},
+			},
+			LastAttemptedFailures: []kustomizev1.ObjectFailure{
+				{ID: "default_backend__Service", Reason: "Invalid", Message: strings.Repeat("x", 1024)},
+			},
+		}
+		for i := 0; i < 10000; i++ {
+			status.ObjectPolicies[0].Entries = append(status.ObjectPolicies[0].Entries,
+				kustomizev1.ResourceRef{ID: fmt.Sprintf("default_cm-%d__ConfigMap", i), Version: "v1"})
+		}
+
+		g.Expect(statusSize(status)).To(BeNumerically(">", kustomizev1.MaxStatusSize))
+
+		overflow := limitStatus(status)
+		g.Expect(overflow).To(HaveLen(2))
+		g.Expect(overflow[0]).To(HavePrefix("status.slowestApplies cleared"))
+		g.Expect(overflow[1]).To(HavePrefix("status.objectPolicies cleared"))
+		g.Expect(overflow[1]).To(ContainSubstring("default_cm-0__ConfigMap"))
+		g.Expect(status.TruncatedFields).To(Equal([]string{"slowestApplies", "objectPolicies"}))
+		g.Expect(status.SlowestApplies).To(BeNil())
+		g.Expect(status.ObjectPolicies).To(BeNil())
+		g.Expect(status.LastAttemptedFailures).To(HaveLen(1))
+		g.Expect(statusSize(status)).To(BeNumerically("<=", kustomizev1.MaxStatusSize))
+	})
+}
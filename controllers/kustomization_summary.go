@@ -0,0 +1,49 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta2"
+)
+
+// patchStatusSummary publishes the status summary of the Kustomization in the
+// status-summary annotation, if it changed. It's called once with the result of
+// the reconciliation, not at every status change, as the intermediate states
+// would double the writes to the API server.
+func (r *KustomizationReconciler) patchStatusSummary(ctx context.Context, kustomization kustomizev1.Kustomization) error {
+	data, err := json.Marshal(kustomizev1.NewStatusSummary(kustomization.Status))
+	if err != nil {
+		return err
+	}
+	if kustomization.GetAnnotations()[kustomizev1.StatusSummaryAnnotation] == string(data) {
+		return nil
+	}
+
+	patch := client.MergeFrom(kustomization.DeepCopy())
+	annotations := kustomization.GetAnnotations()
+	if annotations == nil {
+		annotations = make(map[string]string)
+	}
+	annotations[kustomizev1.StatusSummaryAnnotation] = string(data)
+	kustomization.SetAnnotations(annotations)
+	return r.Patch(ctx, &kustomization, patch, client.FieldOwner(r.statusManager))
+}
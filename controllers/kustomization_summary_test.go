@@ -0,0 +1,92 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/fluxcd/pkg/apis/meta"
+
+	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta2"
+)
+
+func TestKustomizationReconciler_patchStatusSummary(t *testing.T) {
+	g := NewWithT(t)
+
+	scheme := runtime.NewScheme()
+	g.Expect(kustomizev1.AddToScheme(scheme)).To(Succeed())
+	key := types.NamespacedName{Namespace: "flux-system", Name: "apps"}
+	kubeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(&kustomizev1.Kustomization{
+		ObjectMeta: metav1.ObjectMeta{Namespace: key.Namespace, Name: key.Name},
+	}).Build()
+	r := &KustomizationReconciler{Client: kubeClient, statusManager: "gotk-kustomize-controller"}
+
+	status := kustomizev1.KustomizationStatus{
+		Conditions: []metav1.Condition{{
+			Type:    meta.ReadyCondition,
+			Status:  metav1.ConditionTrue,
+			Reason:  meta.ReconciliationSucceededReason,
+			Message: "Applied revision: main/1",
+		}},
+		LastAppliedRevision: "main/1",
+		Inventory: &kustomizev1.ResourceInventory{Entries: []kustomizev1.ResourceRef{
+			{ID: "apps_podinfo_apps_Deployment", Version: "v1"},
+			{ID: "apps_podinfo__Service", Version: "v1"},
+		}},
+		Warnings: []string{"deprecated field 'bases' in kustomization.yaml"},
+	}
+	g.Expect(r.patchStatus(context.TODO(), ctrl.Request{NamespacedName: key}, status)).To(Succeed())
+
+	var result kustomizev1.Kustomization
+	g.Expect(kubeClient.Get(context.TODO(), key, &result)).To(Succeed())
+	expected := &kustomizev1.StatusSummary{
+		Version:             kustomizev1.StatusSummaryVersion,
+		LastAppliedRevision: "main/1",
+		Objects:             2,
+		Warnings:            1,
+		Conditions: []kustomizev1.ConditionSummary{{
+			Type:   meta.ReadyCondition,
+			Status: metav1.ConditionTrue,
+			Reason: meta.ReconciliationSucceededReason,
+		}},
+	}
+	g.Expect(result.Status.Summary).To(Equal(expected))
+
+	// the annotation is not patched with the status
+	g.Expect(result.GetAnnotations()).NotTo(HaveKey(kustomizev1.StatusSummaryAnnotation))
+
+	g.Expect(r.patchStatusSummary(context.TODO(), result)).To(Succeed())
+	g.Expect(kubeClient.Get(context.TODO(), key, &result)).To(Succeed())
+	var published kustomizev1.StatusSummary
+	g.Expect(json.Unmarshal([]byte(result.GetAnnotations()[kustomizev1.StatusSummaryAnnotation]), &published)).To(Succeed())
+	g.Expect(&published).To(Equal(expected))
+
+	// the annotation is not patched when the summary is unchanged
+	resourceVersion := result.ResourceVersion
+	g.Expect(r.patchStatusSummary(context.TODO(), result)).To(Succeed())
+	g.Expect(kubeClient.Get(context.TODO(), key, &result)).To(Succeed())
+	g.Expect(result.ResourceVersion).To(Equal(resourceVersion))
+}
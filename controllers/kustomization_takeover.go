@@ -0,0 +1,225 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/cli-utils/pkg/object"
+
+	"github.com/fluxcd/pkg/apis/meta"
+	"github.com/fluxcd/pkg/ssa"
+
+	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta2"
+)
+
+// takesOverFrom returns true if the taker lists the given Kustomization in spec.takeoverFrom.
+func takesOverFrom(taker kustomizev1.Kustomization, kustomization kustomizev1.Kustomization) bool {
+	for _, ref := range taker.Spec.TakeoverFrom {
+		namespace := ref.Namespace
+		if namespace == "" {
+			namespace = taker.GetNamespace()
+		}
+		if namespace == kustomization.GetNamespace() && ref.Name == kustomization.GetName() {
+			return true
+		}
+	}
+	return false
+}
+
+// takeoverClaims returns the inventory IDs of the objects claimed by the Kustomizations
+// that take over objects from the given one. The first map contains the objects applied
+// by a ready Kustomization, which can be handed over. The second map contains the objects
+// applied or rendered by a Kustomization that has not yet applied its latest configuration,
+// along with the name of that Kustomization.
+func (r *KustomizationReconciler) takeoverClaims(ctx context.Context,
+	kustomization kustomizev1.Kustomization) (map[string]bool, map[string]string, error) {
+	var list kustomizev1.KustomizationList
+	if err := r.List(ctx, &list); err != nil {
+		return nil, nil, fmt.Errorf("failed to list Kustomizations: %w", err)
+	}
+
+	handedOver := make(map[string]bool)
+	pending := make(map[string]string)
+	for _, taker := range list.Items {
+		if !taker.ObjectMeta.DeletionTimestamp.IsZero() || taker.Spec.Suspend {
+			continue
+		}
+		if r.NoCrossNamespaceRefs && taker.GetNamespace() != kustomization.GetNamespace() {
+			continue
+		}
+		if taker.GetNamespace() == kustomization.GetNamespace() && taker.GetName() == kustomization.GetName() {
+			continue
+		}
+		if !takesOverFrom(taker, kustomization) {
+			continue
+		}
+
+		name := fmt.Sprintf("%s/%s", taker.GetNamespace(), taker.GetName())
+		ready := taker.Status.ObservedGeneration == taker.GetGeneration() &&
+			apimeta.IsStatusConditionTrue(taker.Status.Conditions, meta.ReadyCondition)
+
+		applied := make(map[string]bool)
+		if taker.Status.Inventory != nil {
+			for _, entry := range taker.Status.Inventory.Entries {
+				applied[entry.ID] = true
+				if ready {
+					handedOver[entry.ID] = true
+				} else {
+					pending[entry.ID] = name
+				}
+			}
+		}
+
+		// objects rendered by the taker but not applied yet
+		for _, entry := range taker.Status.TakeoverClaims {
+			if !applied[entry.ID] {
+				pending[entry.ID] = name
+			}
+		}
+	}
+
+	return handedOver, pending, nil
+}
+
+// handOverObjects removes from the stale objects the ones that were taken over by other
+// Kustomizations. The stale objects applied or rendered by a Kustomization which is not
+// ready yet are held back, along with the sorted names of the Kustomizations they wait for,
+// to prevent deleting objects that are about to change hands.
+func (r *KustomizationReconciler) handOverObjects(ctx context.Context,
+	kustomization kustomizev1.Kustomization,
+	objects []*unstructured.Unstructured) (stale []*unstructured.Unstructured, held []*unstructured.Unstructured, takers []string, err error) {
+	if len(objects) == 0 {
+		return objects, nil, nil, nil
+	}
+
+	handedOver, pending, err := r.takeoverClaims(ctx, kustomization)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	waitingFor := make(map[string]bool)
+	for _, obj := range objects {
+		id := object.UnstructuredToObjMetadata(obj).String()
+		switch {
+		case handedOver[id]:
+			continue
+		case pending[id] != "":
+			held = append(held, obj)
+			waitingFor[pending[id]] = true
+		default:
+			stale = append(stale, obj)
+		}
+	}
+
+	for name := range waitingFor {
+		takers = append(takers, name)
+	}
+	sort.Strings(takers)
+	return stale, held, takers, nil
+}
+
+// takeoverRenders returns the inventory entries of the objects that are part of the
+// inventory of the Kustomizations listed in spec.takeoverFrom.
+func (r *KustomizationReconciler) takeoverRenders(ctx context.Context,
+	kustomization kustomizev1.Kustomization,
+	objects []*unstructured.Unstructured) ([]kustomizev1.ResourceRef, error) {
+	owners, err := r.takeoverSourceInventories(ctx, kustomization)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []kustomizev1.ResourceRef
+	for _, ref := range objectsToResourceRefs(objects) {
+		if owners[ref.ID] != "" {
+			entries = append(entries, ref)
+		}
+	}
+	return entries, nil
+}
+
+// takeoverSourceInventories returns the inventory IDs of the Kustomizations listed in
+// spec.takeoverFrom, along with the '<namespace>/<name>' of the Kustomization that owns them.
+func (r *KustomizationReconciler) takeoverSourceInventories(ctx context.Context,
+	kustomization kustomizev1.Kustomization) (map[string]string, error) {
+	owners := make(map[string]string)
+	for _, ref := range kustomization.Spec.TakeoverFrom {
+		namespace := ref.Namespace
+		if namespace == "" {
+			namespace = kustomization.GetNamespace()
+		}
+
+		var source kustomizev1.Kustomization
+		if err := r.Get(ctx, types.NamespacedName{Namespace: namespace, Name: ref.Name}, &source); err != nil {
+			if apierrors.IsNotFound(err) {
+				continue
+			}
+			return nil, fmt.Errorf("failed to get Kustomization '%s/%s': %w", namespace, ref.Name, err)
+		}
+		if source.Status.Inventory == nil {
+			continue
+		}
+
+		for _, entry := range source.Status.Inventory.Entries {
+			if _, ok := owners[entry.ID]; !ok {
+				owners[entry.ID] = fmt.Sprintf("%s/%s", namespace, ref.Name)
+			}
+		}
+	}
+	return owners, nil
+}
+
+// takeoverChangeLog returns a log entry for each object of the new inventory that
+// was previously part of the inventory of a Kustomization listed in spec.takeoverFrom.
+func (r *KustomizationReconciler) takeoverChangeLog(ctx context.Context,
+	kustomization kustomizev1.Kustomization,
+	oldInventory *kustomizev1.ResourceInventory,
+	newInventory *kustomizev1.ResourceInventory) (string, error) {
+	owned := make(map[string]bool)
+	if oldInventory != nil {
+		for _, entry := range oldInventory.Entries {
+			owned[entry.ID] = true
+		}
+	}
+
+	previous, err := r.takeoverSourceInventories(ctx, kustomization)
+	if err != nil {
+		return "", err
+	}
+
+	var changeLog strings.Builder
+	for _, entry := range newInventory.Entries {
+		if owned[entry.ID] || previous[entry.ID] == "" {
+			continue
+		}
+		objMetadata, err := object.ParseObjMetadata(entry.ID)
+		if err != nil {
+			return "", err
+		}
+		changeLog.WriteString(fmt.Sprintf("%s taken over from Kustomization/%s\n",
+			ssa.FmtObjMetadata(objMetadata), previous[entry.ID]))
+	}
+
+	return strings.TrimSuffix(changeLog.String(), "\n"), nil
+}
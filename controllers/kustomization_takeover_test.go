@@ -0,0 +1,217 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/fluxcd/pkg/apis/meta"
+	"github.com/fluxcd/pkg/testserver"
+	sourcev1 "github.com/fluxcd/source-controller/api/v1beta2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta2"
+)
+
+func Test_takesOverFrom(t *testing.T) {
+	source := kustomizev1.Kustomization{
+		ObjectMeta: metav1.ObjectMeta{Name: "infra", Namespace: "flux-system"},
+	}
+
+	tests := []struct {
+		name string
+		refs []meta.NamespacedObjectReference
+		ns   string
+		want bool
+	}{
+		{name: "no references", refs: nil, ns: "flux-system", want: false},
+		{name: "same namespace", refs: []meta.NamespacedObjectReference{{Name: "infra"}}, ns: "flux-system", want: true},
+		{name: "cross namespace", refs: []meta.NamespacedObjectReference{{Name: "infra", Namespace: "flux-system"}}, ns: "apps", want: true},
+		{name: "other namespace", refs: []meta.NamespacedObjectReference{{Name: "infra"}}, ns: "apps", want: false},
+		{name: "other name", refs: []meta.NamespacedObjectReference{{Name: "apps"}}, ns: "flux-system", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			taker := kustomizev1.Kustomization{
+				ObjectMeta: metav1.ObjectMeta{Name: "apps", Namespace: tt.ns},
+				Spec: kustomizev1.KustomizationSpec{
+					TakeoverFrom: tt.refs,
+				},
+			}
+
+			g.Expect(takesOverFrom(taker, source)).To(Equal(tt.want))
+		})
+	}
+}
+
+func TestKustomizationReconciler_Takeover(t *testing.T) {
+	g := NewWithT(t)
+	id := "takeover-" + randStringRunes(5)
+	revision := "v1.0.0"
+
+	err := createNamespace(id)
+	g.Expect(err).NotTo(HaveOccurred(), "failed to create test namespace")
+
+	err = createKubeConfigSecret(id)
+	g.Expect(err).NotTo(HaveOccurred(), "failed to create kubeconfig secret")
+
+	manifests := func(names ...string) []testserver.File {
+		var files []testserver.File
+		for _, name := range names {
+			files = append(files, testserver.File{
+				Name: name + ".yaml",
+				Body: fmt.Sprintf(`---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: %[1]s
+data:
+  key: "%[1]s"
+`, name),
+			})
+		}
+		return files
+	}
+
+	newKustomization := func(name string, repositoryName types.NamespacedName) *kustomizev1.Kustomization {
+		return &kustomizev1.Kustomization{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: id,
+			},
+			Spec: kustomizev1.KustomizationSpec{
+				Interval: metav1.Duration{Duration: reconciliationInterval},
+				Path:     "./",
+				KubeConfig: &kustomizev1.KubeConfig{
+					SecretRef: meta.SecretKeyReference{
+						Name: "kubeconfig",
+					},
+				},
+				SourceRef: kustomizev1.CrossNamespaceSourceReference{
+					Name:      repositoryName.Name,
+					Namespace: repositoryName.Namespace,
+					Kind:      sourcev1.GitRepositoryKind,
+				},
+				TargetNamespace: id,
+				Prune:           true,
+			},
+		}
+	}
+
+	infraRepository := types.NamespacedName{Name: "infra-" + randStringRunes(5), Namespace: id}
+	artifact, err := testServer.ArtifactFromFiles(manifests("first", "second"))
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(applyGitRepository(infraRepository, artifact, revision)).To(Succeed())
+
+	infra := newKustomization("infra", infraRepository)
+	g.Expect(k8sClient.Create(context.Background(), infra)).To(Succeed())
+
+	resultInfra := &kustomizev1.Kustomization{}
+	g.Eventually(func() bool {
+		_ = k8sClient.Get(context.Background(), client.ObjectKeyFromObject(infra), resultInfra)
+		return resultInfra.Status.LastAppliedRevision == revision
+	}, timeout, time.Second).Should(BeTrue())
+
+	// the apps Kustomization takes over the second object, and is kept
+	// not ready by a health check on a missing Deployment
+	appsRepository := types.NamespacedName{Name: "apps-" + randStringRunes(5), Namespace: id}
+	artifact, err = testServer.ArtifactFromFiles(manifests("second"))
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(applyGitRepository(appsRepository, artifact, revision)).To(Succeed())
+
+	apps := newKustomization("apps", appsRepository)
+	apps.Spec.TakeoverFrom = []meta.NamespacedObjectReference{{Name: infra.GetName()}}
+	apps.Spec.Timeout = &metav1.Duration{Duration: 2 * time.Second}
+	apps.Spec.HealthChecks = []meta.NamespacedObjectKindReference{
+		{
+			APIVersion: "apps/v1",
+			Kind:       "Deployment",
+			Name:       "missing",
+			Namespace:  id,
+		},
+	}
+	g.Expect(k8sClient.Create(context.Background(), apps)).To(Succeed())
+
+	resultApps := &kustomizev1.Kustomization{}
+	g.Eventually(func() bool {
+		_ = k8sClient.Get(context.Background(), client.ObjectKeyFromObject(apps), resultApps)
+		return resultApps.Status.LastAttemptedRevision == revision && len(resultApps.Status.TakeoverClaims) == 1
+	}, timeout, time.Second).Should(BeTrue())
+	g.Expect(resultApps.Status.TakeoverClaims[0].ID).To(Equal(fmt.Sprintf("%s_second__ConfigMap", id)))
+	g.Expect(apimeta.IsStatusConditionTrue(resultApps.Status.Conditions, meta.ReadyCondition)).To(BeFalse())
+
+	configMapExists := func(name string) bool {
+		err := k8sClient.Get(context.Background(), types.NamespacedName{Name: name, Namespace: id}, &corev1.ConfigMap{})
+		return err == nil
+	}
+
+	t.Run("holds objects claimed by a Kustomization that is not ready", func(t *testing.T) {
+		artifact, err := testServer.ArtifactFromFiles(manifests("first"))
+		g.Expect(err).NotTo(HaveOccurred())
+		revision = "v2.0.0"
+		g.Expect(applyGitRepository(infraRepository, artifact, revision)).To(Succeed())
+
+		g.Eventually(func() bool {
+			_ = k8sClient.Get(context.Background(), client.ObjectKeyFromObject(infra), resultInfra)
+			return resultInfra.Status.LastAppliedRevision == revision
+		}, timeout, time.Second).Should(BeTrue())
+
+		g.Expect(resultInfra.Status.HeldForTakeover).To(HaveLen(1))
+		g.Expect(resultInfra.Status.HeldForTakeover[0].ID).To(Equal(fmt.Sprintf("%s_second__ConfigMap", id)))
+		g.Expect(resultInfra.Status.Inventory.Entries).To(HaveLen(2))
+		g.Expect(configMapExists("second")).To(BeTrue())
+
+		events := getEvents(infra.GetName(), nil)
+		g.Expect(events).To(ContainElement(WithTransform(func(e corev1.Event) string { return e.Message },
+			ContainSubstring("held until taken over by Kustomization %s/apps", id))))
+	})
+
+	t.Run("hands over objects once the Kustomization is ready", func(t *testing.T) {
+		g.Expect(k8sClient.Get(context.Background(), client.ObjectKeyFromObject(apps), resultApps)).To(Succeed())
+		resultApps.Spec.HealthChecks = nil
+		g.Expect(k8sClient.Update(context.Background(), resultApps)).To(Succeed())
+
+		g.Eventually(func() bool {
+			_ = k8sClient.Get(context.Background(), client.ObjectKeyFromObject(apps), resultApps)
+			return resultApps.Status.ObservedGeneration == resultApps.GetGeneration() &&
+				apimeta.IsStatusConditionTrue(resultApps.Status.Conditions, meta.ReadyCondition)
+		}, timeout, time.Second).Should(BeTrue())
+
+		g.Eventually(func() bool {
+			_ = k8sClient.Get(context.Background(), client.ObjectKeyFromObject(infra), resultInfra)
+			return len(resultInfra.Status.HeldForTakeover) == 0 && len(resultInfra.Status.Inventory.Entries) == 1
+		}, timeout, time.Second).Should(BeTrue())
+
+		g.Expect(configMapExists("first")).To(BeTrue())
+		g.Expect(configMapExists("second")).To(BeTrue())
+
+		second := &corev1.ConfigMap{}
+		g.Expect(k8sClient.Get(context.Background(), types.NamespacedName{Name: "second", Namespace: id}, second)).To(Succeed())
+		g.Expect(second.GetLabels()["kustomize.toolkit.fluxcd.io/name"]).To(Equal(apps.GetName()))
+	})
+}
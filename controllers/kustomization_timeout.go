@@ -0,0 +1,104 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/cli-utils/pkg/object"
+
+	"github.com/fluxcd/pkg/ssa"
+
+	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta2"
+)
+
+// maxSlowestApplies is the number of objects reported in the SlowestApplies status.
+const maxSlowestApplies = 5
+
+// applyTimings records the duration of the server-side apply of each object.
+type applyTimings struct {
+	entries []kustomizev1.ApplyDuration
+}
+
+func (t *applyTimings) record(obj *unstructured.Unstructured, d time.Duration) {
+	t.entries = append(t.entries, kustomizev1.ApplyDuration{
+		ID:       object.UnstructuredToObjMetadata(obj).String(),
+		Duration: metav1.Duration{Duration: d.Round(time.Millisecond)},
+	})
+}
+
+// slowest returns the n objects that took the longest to apply,
+// objects with the same duration are ordered by ID.
+func (t *applyTimings) slowest(n int) []kustomizev1.ApplyDuration {
+	if t == nil || len(t.entries) == 0 {
+		return nil
+	}
+	result := make([]kustomizev1.ApplyDuration, len(t.entries))
+	copy(result, t.entries)
+	sort.SliceStable(result, func(i, j int) bool {
+		if result[i].Duration.Duration == result[j].Duration.Duration {
+			return result[i].ID < result[j].ID
+		}
+		return result[i].Duration.Duration > result[j].Duration.Duration
+	})
+	if len(result) > n {
+		result = result[:n]
+	}
+	return result
+}
+
+// objectTimeout returns the per object apply timeout, or zero if not set.
+func objectTimeout(kustomization kustomizev1.Kustomization) time.Duration {
+	if opts := kustomization.Spec.ApplyOptions; opts != nil && opts.ObjectTimeout != nil {
+		return opts.ObjectTimeout.Duration
+	}
+	return 0
+}
+
+// applyEach applies the objects one at a time, each within the given timeout,
+// and records the duration of every server-side apply.
+func applyEach(ctx context.Context, manager *ssa.ResourceManager, objects []*unstructured.Unstructured,
+	opts ssa.ApplyOptions, timeout time.Duration, timings *applyTimings) (*ssa.ChangeSet, error) {
+	changeSet := ssa.NewChangeSet()
+	for _, u := range objects {
+		objOpts := opts
+		if hasObjectPolicy(u, kustomizev1.ForceObjectPolicy) {
+			objOpts.Force = true
+		}
+
+		applyCtx, cancel := context.WithTimeout(ctx, timeout)
+		start := time.Now()
+		entry, err := manager.Apply(applyCtx, u, objOpts)
+		timedOut := applyCtx.Err() == context.DeadlineExceeded
+		cancel()
+		timings.record(u, time.Since(start))
+
+		if err != nil {
+			if timedOut {
+				return nil, fmt.Errorf("%s apply timed out after %s", ssa.FmtUnstructured(u), timeout.String())
+			}
+			return nil, err
+		}
+		changeSet.Add(*entry)
+	}
+	return changeSet, nil
+}
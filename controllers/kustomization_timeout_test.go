@@ -0,0 +1,80 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta2"
+)
+
+func Test_applyTimings_slowest(t *testing.T) {
+	g := NewWithT(t)
+
+	timings := &applyTimings{}
+	g.Expect(timings.slowest(maxSlowestApplies)).To(BeEmpty())
+
+	for i, d := range []time.Duration{2, 7, 1, 5, 3, 6, 4} {
+		cm := &unstructured.Unstructured{}
+		cm.SetAPIVersion("v1")
+		cm.SetKind("ConfigMap")
+		cm.SetNamespace("default")
+		cm.SetName(fmt.Sprintf("cm-%d", i))
+		timings.record(cm, d*time.Second)
+	}
+
+	slowest := timings.slowest(3)
+	g.Expect(slowest).To(HaveLen(3))
+	g.Expect(slowest[0].ID).To(Equal("default_cm-1__ConfigMap"))
+	g.Expect(slowest[0].Duration.Duration).To(Equal(7 * time.Second))
+	g.Expect(slowest[1].ID).To(Equal("default_cm-5__ConfigMap"))
+	g.Expect(slowest[2].ID).To(Equal("default_cm-3__ConfigMap"))
+	g.Expect(timings.entries).To(HaveLen(7))
+
+	tied := &applyTimings{}
+	for _, name := range []string{"c", "a", "b"} {
+		cm := &unstructured.Unstructured{}
+		cm.SetAPIVersion("v1")
+		cm.SetKind("ConfigMap")
+		cm.SetNamespace("default")
+		cm.SetName(name)
+		tied.record(cm, time.Second)
+	}
+	slowest = tied.slowest(2)
+	g.Expect(slowest).To(HaveLen(2))
+	g.Expect(slowest[0].ID).To(Equal("default_a__ConfigMap"))
+	g.Expect(slowest[1].ID).To(Equal("default_b__ConfigMap"))
+}
+
+func Test_objectTimeout(t *testing.T) {
+	g := NewWithT(t)
+
+	k := kustomizev1.Kustomization{}
+	g.Expect(objectTimeout(k)).To(BeZero())
+
+	k.Spec.ApplyOptions = &kustomizev1.ApplyOptions{}
+	g.Expect(objectTimeout(k)).To(BeZero())
+
+	k.Spec.ApplyOptions.ObjectTimeout = &metav1.Duration{Duration: 30 * time.Second}
+	g.Expect(objectTimeout(k)).To(Equal(30 * time.Second))
+}
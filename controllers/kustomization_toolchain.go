@@ -0,0 +1,90 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+	"runtime"
+	"runtime/debug"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// toolchainModules maps the toolchain components to the Go modules that implement them.
+var toolchainModules = map[string]string{
+	"kustomize": "sigs.k8s.io/kustomize/api",
+	"kyaml":     "sigs.k8s.io/kustomize/kyaml",
+	"sops":      "go.mozilla.org/sops/v3",
+}
+
+var (
+	toolchainOnce     sync.Once
+	toolchainVersions map[string]string
+)
+
+// toolchain returns the versions of the tools embedded in the controller binary
+// that take part in the build, read from the binary build information.
+func toolchain() map[string]string {
+	toolchainOnce.Do(func() {
+		toolchainVersions = map[string]string{"go": runtime.Version()}
+
+		info, ok := debug.ReadBuildInfo()
+		if !ok {
+			return
+		}
+		for _, dep := range info.Deps {
+			for name, path := range toolchainModules {
+				if dep.Path != path {
+					continue
+				}
+				version := dep.Version
+				if dep.Replace != nil && dep.Replace.Version != "" {
+					version = dep.Replace.Version
+				}
+				toolchainVersions[name] = version
+			}
+		}
+	})
+
+	result := make(map[string]string, len(toolchainVersions))
+	for k, v := range toolchainVersions {
+		result[k] = v
+	}
+	return result
+}
+
+// toolchainChanges returns a message listing the version changes between
+// the observed and the current toolchain, or an empty string.
+func toolchainChanges(observed, current map[string]string) string {
+	if len(observed) == 0 {
+		return ""
+	}
+
+	var changes []string
+	for name, version := range current {
+		if old, ok := observed[name]; ok && old != version {
+			changes = append(changes, fmt.Sprintf("%s %s -> %s", name, old, version))
+		}
+	}
+	if len(changes) == 0 {
+		return ""
+	}
+
+	sort.Strings(changes)
+	return fmt.Sprintf("Toolchain upgraded: %s", strings.Join(changes, ", "))
+}
@@ -0,0 +1,45 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func Test_toolchain(t *testing.T) {
+	g := NewWithT(t)
+
+	versions := toolchain()
+	g.Expect(versions).To(HaveKey("go"))
+
+	// the returned map must not alias the cached versions
+	versions["go"] = "modified"
+	g.Expect(toolchain()["go"]).NotTo(Equal("modified"))
+}
+
+func Test_toolchainChanges(t *testing.T) {
+	g := NewWithT(t)
+
+	current := map[string]string{"go": "go1.18.5", "kustomize": "v0.13.0", "kyaml": "v0.13.9"}
+
+	g.Expect(toolchainChanges(nil, current)).To(BeEmpty())
+	g.Expect(toolchainChanges(current, current)).To(BeEmpty())
+	g.Expect(toolchainChanges(map[string]string{"go": "go1.18.4", "kustomize": "v0.12.1", "kyaml": "v0.13.9"}, current)).
+		To(Equal("Toolchain upgraded: go go1.18.4 -> go1.18.5, kustomize v0.12.1 -> v0.13.0"))
+}
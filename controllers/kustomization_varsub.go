@@ -1,8 +1,14 @@
 package controllers
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"os"
 	"regexp"
 	"strings"
 
@@ -21,14 +27,79 @@ import (
 // the var names before substitution
 const varsubRegex = "^[_[:alpha:]][_[:alpha:][:digit:]]*$"
 
-// substituteVariables replaces the vars with their values in the specified resource.
+// varsubFuncRegex matches the functions applied to a var, e.g. ${b64:var}, along
+// with the preceding dollar signs to leave out the escaped ones, e.g. $${b64:var}.
+var varsubFuncRegex = regexp.MustCompile(`(\$+)\{(b64|sha256|json):([_[:alpha:]][_[:alpha:][:digit:]]*)\}`)
+
+// varsubFuncs are the functions that can be applied to a var value.
+var varsubFuncs = map[string]func(string) string{
+	"b64": func(v string) string {
+		return base64.StdEncoding.EncodeToString([]byte(v))
+	},
+	"sha256": func(v string) string {
+		return fmt.Sprintf("%x", sha256.Sum256([]byte(v)))
+	},
+	"json": func(v string) string {
+		var buf bytes.Buffer
+		enc := json.NewEncoder(&buf)
+		enc.SetEscapeHTML(false)
+		// encoding a string never fails
+		_ = enc.Encode(v)
+		return strings.TrimSuffix(buf.String(), "\n")
+	},
+}
+
+// varsubEscaper escapes the characters that envsubst unescapes in text.
+var varsubEscaper = strings.NewReplacer(`$`, `$$`, `\`, `\\`)
+
+// substituteFunctions replaces the functions applied to a var, e.g. ${sha256:var},
+// with their result, an undefined var has an empty value. The results are escaped
+// so that they are left as is by the envsubst evaluation that follows.
+func substituteFunctions(data string, vars map[string]string) string {
+	return varsubFuncRegex.ReplaceAllStringFunc(data, func(match string) string {
+		groups := varsubFuncRegex.FindStringSubmatch(match)
+		dollars := groups[1]
+		if len(dollars)%2 == 0 {
+			return match
+		}
+		result := varsubFuncs[groups[2]](vars[groups[3]])
+		return dollars[:len(dollars)-1] + varsubEscaper.Replace(result)
+	})
+}
+
+// LoadDefaultSubstitutions returns the vars available to the substitutions of all
+// the Kustomizations, read from the YAML map in the given file, if any, and
+// from the given vars, which override the ones from the file.
+func LoadDefaultSubstitutions(path string, vars map[string]string) (map[string]string, error) {
+	result := make(map[string]string)
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read default substitutions: %w", err)
+		}
+		if err := yaml.Unmarshal(data, &result); err != nil {
+			return nil, fmt.Errorf("failed to parse default substitutions from '%s': %w", path, err)
+		}
+	}
+	for k, v := range vars {
+		result[k] = v
+	}
+
+	r := regexp.MustCompile(varsubRegex)
+	for k, v := range result {
+		if !r.MatchString(k) {
+			return nil, fmt.Errorf("'%s' var name is invalid, must match '%s'", k, varsubRegex)
+		}
+		result[k] = strings.ReplaceAll(v, "\n", "")
+	}
+	return result, nil
+}
+
+// substituteVariables replaces the vars with their values in the specified resource,
+// the vars are loaded once per build with loadVariables.
 // If a resource is labeled or annotated with
 // 'kustomize.toolkit.fluxcd.io/substitute: disabled' the substitution is skipped.
-func substituteVariables(
-	ctx context.Context,
-	kubeClient client.Client,
-	kustomization kustomizev1.Kustomization,
-	res *resource.Resource) (*resource.Resource, error) {
+func substituteVariables(vars map[string]string, res *resource.Resource) (*resource.Resource, error) {
 	resData, err := res.AsYAML()
 	if err != nil {
 		return nil, err
@@ -40,8 +111,53 @@ func substituteVariables(
 		return nil, nil
 	}
 
+	// run bash variable substitutions
+	if len(vars) > 0 {
+		r, _ := regexp.Compile(varsubRegex)
+		for v := range vars {
+			if !r.MatchString(v) {
+				return nil, fmt.Errorf("'%s' var name is invalid, must match '%s'", v, varsubRegex)
+			}
+		}
+
+		output, err := envsubst.Eval(substituteFunctions(string(resData), vars), func(s string) string {
+			return vars[s]
+		})
+		if err != nil {
+			return nil, fmt.Errorf("variable substitution failed: %w", err)
+		}
+
+		jsonData, err := yaml.YAMLToJSON([]byte(output))
+		if err != nil {
+			return nil, fmt.Errorf("YAMLToJSON: %w", err)
+		}
+
+		err = res.UnmarshalJSON(jsonData)
+		if err != nil {
+			return nil, fmt.Errorf("UnmarshalJSON: %w", err)
+		}
+	}
+
+	return res, nil
+}
+
+// loadVariables returns the vars available to the substitutions of the Kustomization:
+// the default vars, overridden by the ones of the ConfigMaps, Secrets and external
+// secrets listed in spec.postBuild.substituteFrom, overridden by the ones of
+// spec.postBuild.substitute.
+func loadVariables(
+	ctx context.Context,
+	kubeClient client.Client,
+	secrets ExternalSecretResolver,
+	kustomization kustomizev1.Kustomization,
+	defaults map[string]string) (map[string]string, error) {
 	vars := make(map[string]string)
 
+	// load the vars set for all the Kustomizations
+	for k, v := range defaults {
+		vars[k] = v
+	}
+
 	// load vars from ConfigMaps and Secrets data keys
 	for _, reference := range kustomization.Spec.PostBuild.SubstituteFrom {
 		namespacedName := types.NamespacedName{Namespace: kustomization.Namespace, Name: reference.Name}
@@ -68,6 +184,17 @@ func substituteVariables(
 			for k, v := range resource.Data {
 				vars[k] = strings.ReplaceAll(string(v), "\n", "")
 			}
+		case ExternalSecretKind:
+			values, err := loadExternalSecret(ctx, kubeClient, secrets, kustomization.Namespace, reference)
+			if err != nil {
+				if reference.Optional && errors.Is(err, errExternalSecretNotFound) {
+					continue
+				}
+				return nil, fmt.Errorf("substitute from '%s/%s' error: %w", ExternalSecretKind, reference.Name, err)
+			}
+			for k, v := range values {
+				vars[k] = strings.ReplaceAll(v, "\n", "")
+			}
 		}
 	}
 
@@ -78,32 +205,5 @@ func substituteVariables(
 		}
 	}
 
-	// run bash variable substitutions
-	if len(vars) > 0 {
-		r, _ := regexp.Compile(varsubRegex)
-		for v := range vars {
-			if !r.MatchString(v) {
-				return nil, fmt.Errorf("'%s' var name is invalid, must match '%s'", v, varsubRegex)
-			}
-		}
-
-		output, err := envsubst.Eval(string(resData), func(s string) string {
-			return vars[s]
-		})
-		if err != nil {
-			return nil, fmt.Errorf("variable substitution failed: %w", err)
-		}
-
-		jsonData, err := yaml.YAMLToJSON([]byte(output))
-		if err != nil {
-			return nil, fmt.Errorf("YAMLToJSON: %w", err)
-		}
-
-		err = res.UnmarshalJSON(jsonData)
-		if err != nil {
-			return nil, fmt.Errorf("UnmarshalJSON: %w", err)
-		}
-	}
-
-	return res, nil
+	return vars, nil
 }
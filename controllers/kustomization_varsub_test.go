@@ -19,6 +19,8 @@ package controllers
 import (
 	"context"
 	"fmt"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/fluxcd/pkg/apis/meta"
@@ -28,8 +30,11 @@ import (
 	corev1 "k8s.io/api/core/v1"
 	apimeta "k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/kustomize/api/provider"
 
 	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta2"
 )
@@ -349,3 +354,98 @@ metadata:
 		g.Expect(resultSA.Labels["shape"]).To(Equal("square"))
 	})
 }
+
+func Test_substituteFunctions(t *testing.T) {
+	vars := map[string]string{
+		"name":     "podinfo",
+		"password": `s3cr$t\x"q`,
+	}
+
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{name: "base64", in: "${b64:name}", want: "cG9kaW5mbw=="},
+		{name: "sha256", in: "${sha256:name}", want: "04d1b7ec0eaa8700acbf2951a468e3c4376422d3807247296e669d6c0439690c"},
+		{name: "json", in: "${json:password}", want: `"s3cr$$t\\\\x\\"q"`},
+		{name: "escaped result", in: "${b64:password}", want: "czNjciR0XHgicQ=="},
+		{name: "undefined var", in: "${json:missing}", want: `""`},
+		{name: "escaped function", in: "$${b64:name}", want: "$${b64:name}"},
+		{name: "escaped dollar before function", in: "$$${b64:name}", want: "$$cG9kaW5mbw=="},
+		{name: "plain var", in: "${name}", want: "${name}"},
+		{name: "unknown function", in: "${md5:name}", want: "${md5:name}"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			g.Expect(substituteFunctions(tt.in, vars)).To(Equal(tt.want))
+		})
+	}
+}
+
+func TestLoadDefaultSubstitutions(t *testing.T) {
+	g := NewWithT(t)
+
+	path := filepath.Join(t.TempDir(), "vars.yaml")
+	g.Expect(os.WriteFile(path, []byte("cluster_name: staging\ncluster_region: eu-west-1\n"), 0o644)).To(Succeed())
+
+	vars, err := LoadDefaultSubstitutions(path, map[string]string{"cluster_name": "prod", "env": "production"})
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(vars).To(Equal(map[string]string{
+		"cluster_name":   "prod",
+		"cluster_region": "eu-west-1",
+		"env":            "production",
+	}))
+
+	vars, err = LoadDefaultSubstitutions("", nil)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(vars).To(BeEmpty())
+
+	_, err = LoadDefaultSubstitutions("", map[string]string{"cluster-name": "prod"})
+	g.Expect(err).To(MatchError(ContainSubstring("'cluster-name' var name is invalid")))
+
+	_, err = LoadDefaultSubstitutions(filepath.Join(t.TempDir(), "missing.yaml"), nil)
+	g.Expect(err).To(HaveOccurred())
+}
+
+func Test_substituteVariables_defaults(t *testing.T) {
+	g := NewWithT(t)
+
+	scheme := runtime.NewScheme()
+	g.Expect(corev1.AddToScheme(scheme)).To(Succeed())
+	kubeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(&corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "apps", Name: "vars"},
+		Data:       map[string]string{"env": "staging"},
+	}).Build()
+
+	res, err := provider.NewDefaultDepProvider().GetResourceFactory().FromBytes([]byte(`apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: cluster-info
+  namespace: apps
+data:
+  cluster: ${cluster_name}
+  env: ${env}
+  region: ${cluster_region}
+`))
+	g.Expect(err).NotTo(HaveOccurred())
+
+	kustomization := kustomizev1.Kustomization{ObjectMeta: metav1.ObjectMeta{Namespace: "apps", Name: "app"}}
+	kustomization.Spec.PostBuild = &kustomizev1.PostBuild{
+		Substitute:     map[string]string{"cluster_region": "us-east-1"},
+		SubstituteFrom: []kustomizev1.SubstituteReference{{Kind: "ConfigMap", Name: "vars"}},
+	}
+	defaults := map[string]string{"cluster_name": "prod", "env": "production", "cluster_region": "eu-west-1"}
+
+	vars, err := loadVariables(context.TODO(), kubeClient, nil, kustomization, defaults)
+	g.Expect(err).NotTo(HaveOccurred())
+	out, err := substituteVariables(vars, res)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(out.GetDataMap()).To(Equal(map[string]string{
+		"cluster": "prod",
+		"env":     "staging",
+		"region":  "us-east-1",
+	}))
+}
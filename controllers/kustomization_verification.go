@@ -0,0 +1,254 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	corev1client "k8s.io/client-go/kubernetes/typed/core/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/fluxcd/pkg/ssa"
+
+	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta2"
+)
+
+// verificationPollInterval is the interval at which
+// the verification Jobs are checked for completion.
+var verificationPollInterval = 2 * time.Second
+
+// verificationLabel is set on the verification Jobs to the name of the Job in the build.
+var verificationLabel = fmt.Sprintf("%s/verification", kustomizev1.GroupVersion.Group)
+
+// maxVerificationLogLines is the number of lines of the logs
+// of a failed verification Job reported in the Ready condition.
+const maxVerificationLogLines int64 = 20
+
+// maxVerificationLogSize is the maximum size in bytes of the logs
+// of a failed verification Job reported in the Ready condition.
+const maxVerificationLogSize = 4096
+
+// splitVerificationJobs returns the objects to apply, and the Jobs
+// annotated with 'kustomize.toolkit.fluxcd.io/verification: enabled'.
+func splitVerificationJobs(objects []*unstructured.Unstructured) ([]*unstructured.Unstructured, []*unstructured.Unstructured) {
+	var apply, jobs []*unstructured.Unstructured
+	for _, obj := range objects {
+		if obj.GetKind() == "Job" && obj.GroupVersionKind().Group == batchv1.GroupName &&
+			obj.GetAnnotations()[kustomizev1.VerificationAnnotation] == kustomizev1.EnabledValue {
+			jobs = append(jobs, obj)
+			continue
+		}
+		apply = append(apply, obj)
+	}
+	return apply, jobs
+}
+
+// newVerificationJob returns the Job to run for the verification Job of the build.
+// The Job name is suffixed with a hash of the revision and the Job spec, so that
+// a Job is run once per revision, and again when its spec changes.
+func newVerificationJob(kustomization kustomizev1.Kustomization, revision string,
+	obj *unstructured.Unstructured) (*batchv1.Job, error) {
+	var job batchv1.Job
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, &job); err != nil {
+		return nil, fmt.Errorf("invalid verification Job '%s': %w", ssa.FmtUnstructured(obj), err)
+	}
+
+	spec, err := obj.MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(append([]byte(revision+"\n"), spec...))
+
+	name := job.GetName()
+	if len(name) > 52 {
+		name = name[:52]
+	}
+	if job.GetNamespace() == "" {
+		job.SetNamespace(kustomization.GetNamespace())
+	}
+	labels := job.GetLabels()
+	if labels == nil {
+		labels = make(map[string]string)
+	}
+	labels[verificationLabel] = job.GetName()
+	job.SetLabels(labels)
+	job.SetName(fmt.Sprintf("%s-%x", strings.TrimSuffix(name, "-"), sum[:5]))
+	job.SetResourceVersion("")
+	job.Status = batchv1.JobStatus{}
+	return &job, nil
+}
+
+// runVerificationJobs runs the verification Jobs one after the other and waits
+// for them to complete, a Job that completed in a previous reconciliation isn't
+// run again. The runs of the previous revisions are deleted, and a failed Job is
+// deleted after its logs have been collected, for it to be retried at the next
+// reconciliation.
+func (r *KustomizationReconciler) runVerificationJobs(ctx context.Context,
+	kubeClient client.Client,
+	coreClient corev1client.PodsGetter,
+	kustomization kustomizev1.Kustomization,
+	revision string,
+	objects []*unstructured.Unstructured,
+	owner map[string]string,
+	timeout time.Duration) error {
+	start := time.Now()
+	for _, obj := range objects {
+		job, err := newVerificationJob(kustomization, revision, obj)
+		if err != nil {
+			return err
+		}
+		if err := runVerificationJob(ctx, kubeClient, coreClient, job, owner, timeout-time.Since(start)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// runVerificationJob creates the Job if it doesn't exist and waits for it to complete.
+func runVerificationJob(ctx context.Context,
+	kubeClient client.Client,
+	coreClient corev1client.PodsGetter,
+	job *batchv1.Job,
+	owner map[string]string,
+	timeout time.Duration) error {
+	jobName := client.ObjectKeyFromObject(job)
+
+	// delete the runs of the previous revisions
+	selector := client.MatchingLabels{verificationLabel: job.GetLabels()[verificationLabel]}
+	for k, v := range owner {
+		selector[k] = v
+	}
+	var previous batchv1.JobList
+	if err := kubeClient.List(ctx, &previous, client.InNamespace(job.GetNamespace()), selector); err != nil {
+		return fmt.Errorf("failed to list the verification Jobs: %w", err)
+	}
+	for i := range previous.Items {
+		if previous.Items[i].GetName() == job.GetName() {
+			continue
+		}
+		if err := kubeClient.Delete(ctx, &previous.Items[i],
+			client.PropagationPolicy(metav1.DeletePropagationBackground)); err != nil && !apierrors.IsNotFound(err) {
+			return fmt.Errorf("failed to delete Job '%s': %w", client.ObjectKeyFromObject(&previous.Items[i]), err)
+		}
+	}
+
+	if err := kubeClient.Get(ctx, jobName, job); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return fmt.Errorf("failed to get Job '%s': %w", jobName, err)
+		}
+		if err := kubeClient.Create(ctx, job); err != nil {
+			return fmt.Errorf("failed to create Job '%s': %w", jobName, err)
+		}
+	}
+
+	var jobErr error
+	pollCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	err := wait.PollImmediateUntilWithContext(pollCtx, verificationPollInterval, func(ctx context.Context) (bool, error) {
+		if err := kubeClient.Get(ctx, jobName, job); err != nil {
+			return false, err
+		}
+		for _, c := range job.Status.Conditions {
+			if c.Status != corev1.ConditionTrue {
+				continue
+			}
+			switch c.Type {
+			case batchv1.JobComplete:
+				return true, nil
+			case batchv1.JobFailed:
+				jobErr = fmt.Errorf("verification Job '%s' failed: %s", jobName, c.Message)
+				return true, nil
+			}
+		}
+		return false, nil
+	})
+	if err != nil {
+		if errors.Is(err, wait.ErrWaitTimeout) {
+			err = fmt.Errorf("timeout waiting for verification Job '%s' to complete", jobName)
+		}
+		jobErr = err
+	}
+
+	if jobErr != nil {
+		if logs := verificationJobLogs(ctx, kubeClient, coreClient, job); logs != "" {
+			jobErr = fmt.Errorf("%w\n%s", jobErr, logs)
+		}
+		if err := kubeClient.Delete(ctx, job, client.PropagationPolicy(metav1.DeletePropagationBackground)); err != nil &&
+			!apierrors.IsNotFound(err) {
+			ctrl.LoggerFrom(ctx).Error(err, fmt.Sprintf("failed to delete Job '%s'", jobName))
+		}
+		return jobErr
+	}
+	return nil
+}
+
+// verificationJobLogs returns the last lines of the logs of the Pods of the Job,
+// limited to maxVerificationLogSize. The errors are logged and ignored, as the
+// logs are only used to describe the failure.
+func verificationJobLogs(ctx context.Context,
+	kubeClient client.Client,
+	coreClient corev1client.PodsGetter,
+	job *batchv1.Job) string {
+	if coreClient == nil {
+		return ""
+	}
+
+	var pods corev1.PodList
+	if err := kubeClient.List(ctx, &pods, client.InNamespace(job.GetNamespace()),
+		client.MatchingLabels{"job-name": job.GetName()}); err != nil {
+		ctrl.LoggerFrom(ctx).Error(err, fmt.Sprintf("failed to list the Pods of Job '%s'", client.ObjectKeyFromObject(job)))
+		return ""
+	}
+
+	var sb strings.Builder
+	tail := maxVerificationLogLines
+	for _, pod := range pods.Items {
+		for _, container := range pod.Spec.Containers {
+			logs, err := coreClient.Pods(pod.GetNamespace()).GetLogs(pod.GetName(), &corev1.PodLogOptions{
+				Container: container.Name,
+				TailLines: &tail,
+			}).DoRaw(ctx)
+			if err != nil {
+				ctrl.LoggerFrom(ctx).Error(err, fmt.Sprintf("failed to get the logs of Pod '%s/%s'",
+					pod.GetNamespace(), pod.GetName()))
+				continue
+			}
+			if text := strings.TrimSpace(string(logs)); text != "" {
+				sb.WriteString(fmt.Sprintf("logs of %s/%s:\n%s\n", pod.GetName(), container.Name, text))
+			}
+		}
+	}
+
+	logs := strings.TrimSpace(sb.String())
+	if len(logs) > maxVerificationLogSize {
+		logs = "..." + logs[len(logs)-maxVerificationLogSize:]
+	}
+	return logs
+}
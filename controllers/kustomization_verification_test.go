@@ -0,0 +1,181 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta2"
+)
+
+func newVerificationObject(name string, annotated bool) *unstructured.Unstructured {
+	obj := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "batch/v1",
+		"kind":       "Job",
+		"metadata": map[string]interface{}{
+			"name":   name,
+			"labels": map[string]interface{}{"kustomize.toolkit.fluxcd.io/name": "apps"},
+		},
+		"spec": map[string]interface{}{
+			"template": map[string]interface{}{
+				"spec": map[string]interface{}{
+					"restartPolicy": "Never",
+					"containers": []interface{}{
+						map[string]interface{}{"name": "smoke", "image": "curlimages/curl:7.85.0"},
+					},
+				},
+			},
+		},
+	}}
+	if annotated {
+		obj.SetAnnotations(map[string]string{kustomizev1.VerificationAnnotation: kustomizev1.EnabledValue})
+	}
+	return obj
+}
+
+func Test_splitVerificationJobs(t *testing.T) {
+	g := NewWithT(t)
+
+	configMap := &unstructured.Unstructured{}
+	configMap.SetAPIVersion("v1")
+	configMap.SetKind("ConfigMap")
+	configMap.SetName("smoke")
+	configMap.SetAnnotations(map[string]string{kustomizev1.VerificationAnnotation: kustomizev1.EnabledValue})
+
+	migration := newVerificationObject("migration", false)
+	smoke := newVerificationObject("smoke", true)
+
+	apply, jobs := splitVerificationJobs([]*unstructured.Unstructured{configMap, migration, smoke})
+	g.Expect(apply).To(Equal([]*unstructured.Unstructured{configMap, migration}))
+	g.Expect(jobs).To(Equal([]*unstructured.Unstructured{smoke}))
+}
+
+func Test_newVerificationJob(t *testing.T) {
+	g := NewWithT(t)
+
+	kustomization := kustomizev1.Kustomization{
+		ObjectMeta: metav1.ObjectMeta{Name: "apps", Namespace: "flux-system"},
+	}
+	obj := newVerificationObject("smoke", true)
+
+	job, err := newVerificationJob(kustomization, "main/1", obj)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(job.GetNamespace()).To(Equal("flux-system"))
+	g.Expect(job.GetName()).To(HavePrefix("smoke-"))
+	g.Expect(job.GetName()).To(HaveLen(len("smoke-") + 10))
+	g.Expect(job.GetLabels()).To(HaveKeyWithValue(verificationLabel, "smoke"))
+	g.Expect(job.GetLabels()).To(HaveKeyWithValue("kustomize.toolkit.fluxcd.io/name", "apps"))
+	g.Expect(job.Spec.Template.Spec.Containers[0].Image).To(Equal("curlimages/curl:7.85.0"))
+
+	same, err := newVerificationJob(kustomization, "main/1", obj)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(same.GetName()).To(Equal(job.GetName()))
+
+	next, err := newVerificationJob(kustomization, "main/2", obj)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(next.GetName()).NotTo(Equal(job.GetName()))
+
+	long, err := newVerificationJob(kustomization, "main/1",
+		newVerificationObject("smoke-tests-for-the-podinfo-frontend-and-backend-services", true))
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(len(long.GetName())).To(BeNumerically("<=", 63))
+}
+
+func Test_runVerificationJob(t *testing.T) {
+	g := NewWithT(t)
+
+	verificationPollInterval = 10 * time.Millisecond
+	defer func() {
+		verificationPollInterval = 2 * time.Second
+	}()
+
+	scheme := runtime.NewScheme()
+	g.Expect(corev1.AddToScheme(scheme)).To(Succeed())
+	g.Expect(batchv1.AddToScheme(scheme)).To(Succeed())
+
+	kustomization := kustomizev1.Kustomization{
+		ObjectMeta: metav1.ObjectMeta{Name: "apps", Namespace: "flux-system"},
+	}
+	owner := map[string]string{"kustomize.toolkit.fluxcd.io/name": "apps"}
+	job, err := newVerificationJob(kustomization, "main/2", newVerificationObject("smoke", true))
+	g.Expect(err).NotTo(HaveOccurred())
+
+	finished := func(condition batchv1.JobConditionType) *batchv1.Job {
+		j := job.DeepCopy()
+		j.Status.Conditions = []batchv1.JobCondition{
+			{Type: condition, Status: corev1.ConditionTrue, Message: "BackoffLimitExceeded"},
+		}
+		return j
+	}
+
+	t.Run("completed Job and previous run deleted", func(t *testing.T) {
+		g := NewWithT(t)
+		previous, err := newVerificationJob(kustomization, "main/1", newVerificationObject("smoke", true))
+		g.Expect(err).NotTo(HaveOccurred())
+		kubeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(finished(batchv1.JobComplete), previous).Build()
+
+		g.Expect(runVerificationJob(context.TODO(), kubeClient, nil, job.DeepCopy(), owner, time.Second)).To(Succeed())
+
+		err = kubeClient.Get(context.TODO(), client.ObjectKeyFromObject(previous), &batchv1.Job{})
+		g.Expect(apierrors.IsNotFound(err)).To(BeTrue())
+		g.Expect(kubeClient.Get(context.TODO(), client.ObjectKeyFromObject(job), &batchv1.Job{})).To(Succeed())
+	})
+
+	t.Run("failed Job reports the logs and is deleted", func(t *testing.T) {
+		g := NewWithT(t)
+		pod := &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      job.GetName() + "-x7k2p",
+				Namespace: job.GetNamespace(),
+				Labels:    map[string]string{"job-name": job.GetName()},
+			},
+			Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: "smoke"}}},
+		}
+		kubeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(finished(batchv1.JobFailed), pod).Build()
+		coreClient := kubefake.NewSimpleClientset(pod).CoreV1()
+
+		err := runVerificationJob(context.TODO(), kubeClient, coreClient, job.DeepCopy(), owner, time.Second)
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(err.Error()).To(ContainSubstring("failed: BackoffLimitExceeded"))
+		g.Expect(err.Error()).To(ContainSubstring("logs of " + pod.GetName() + "/smoke:\nfake logs"))
+
+		err = kubeClient.Get(context.TODO(), client.ObjectKeyFromObject(job), &batchv1.Job{})
+		g.Expect(apierrors.IsNotFound(err)).To(BeTrue())
+	})
+
+	t.Run("Job is created and times out", func(t *testing.T) {
+		g := NewWithT(t)
+		kubeClient := fake.NewClientBuilder().WithScheme(scheme).Build()
+		err := runVerificationJob(context.TODO(), kubeClient, nil, job.DeepCopy(), owner, 100*time.Millisecond)
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(err.Error()).To(ContainSubstring("timeout waiting for verification Job"))
+		g.Expect(kubeClient.Get(context.TODO(), client.ObjectKeyFromObject(job), &batchv1.Job{})).NotTo(Succeed())
+	})
+}
@@ -0,0 +1,343 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	apiequality "k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/cli-utils/pkg/object"
+	"sigs.k8s.io/yaml"
+
+	"github.com/fluxcd/pkg/ssa"
+
+	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta2"
+)
+
+// VerifyCommand is the command that builds the fixtures of a directory
+// and compares the output with their expected YAML.
+const VerifyCommand = "verify"
+
+const (
+	// fixtureInputDir is the directory holding the overlay of a fixture.
+	fixtureInputDir = "input"
+
+	// fixtureExpectedFile is the file holding the expected build output of a fixture.
+	fixtureExpectedFile = "expected.yaml"
+
+	// fixtureSpecFile is the optional file holding a Kustomization
+	// whose spec is used to generate the kustomization.yaml of a fixture.
+	fixtureSpecFile = "flux-kustomization.yaml"
+)
+
+// RunVerify is the entrypoint of the VerifyCommand.
+func RunVerify(args []string) int {
+	fs := flag.NewFlagSet(VerifyCommand, flag.ContinueOnError)
+	update := fs.Bool("update", false, "Write the build output to the expected files instead of comparing them.")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: %s [--update] <dir>\n", VerifyCommand)
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return 2
+	}
+
+	if err := VerifyFixtures(fs.Arg(0), *update, os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		return 1
+	}
+	return 0
+}
+
+// VerifyFixtures builds the fixtures found in the subdirectories of root
+// and compares the output with the expected objects. A fixture is a directory
+// containing an 'input' overlay, an 'expected.yaml' file and optionally a
+// 'flux-kustomization.yaml' file. With update, the expected files are
+// overwritten with the build output.
+func VerifyFixtures(root string, update bool, out io.Writer) error {
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		return err
+	}
+
+	var failed []string
+	for _, entry := range entries {
+		dir := filepath.Join(root, entry.Name())
+		if !entry.IsDir() {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(dir, fixtureInputDir)); err != nil {
+			continue
+		}
+
+		actual, err := buildFixture(dir)
+		if err == nil {
+			if update {
+				err = os.WriteFile(filepath.Join(dir, fixtureExpectedFile), actual, 0o644)
+			} else {
+				err = compareFixture(dir, actual)
+			}
+		}
+
+		if err != nil {
+			failed = append(failed, entry.Name())
+			fmt.Fprintf(out, "FAIL %s: %s\n", entry.Name(), err.Error())
+			continue
+		}
+		fmt.Fprintf(out, "ok   %s\n", entry.Name())
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("%d fixtures failed: %s", len(failed), strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// buildFixture generates the kustomization.yaml for a copy of the fixture
+// input and returns the build output.
+func buildFixture(dir string) ([]byte, error) {
+	var kustomization kustomizev1.Kustomization
+	data, err := os.ReadFile(filepath.Join(dir, fixtureSpecFile))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if err := yaml.Unmarshal(data, &kustomization); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", fixtureSpecFile, err)
+	}
+
+	tmpDir, err := os.MkdirTemp("", "fixture-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	if err := copyDir(filepath.Join(dir, fixtureInputDir), tmpDir); err != nil {
+		return nil, err
+	}
+
+	if err := NewGenerator(tmpDir, kustomization).WriteFile(tmpDir); err != nil {
+		return nil, err
+	}
+
+	m, err := secureBuildKustomization(tmpDir, tmpDir, false, "")
+	if err != nil {
+		return nil, err
+	}
+	return m.AsYaml()
+}
+
+// compareFixture compares the objects of the build output with the expected ones,
+// the order of the objects and the formatting of the YAML are not relevant.
+func compareFixture(dir string, actual []byte) error {
+	data, err := os.ReadFile(filepath.Join(dir, fixtureExpectedFile))
+	if err != nil {
+		return err
+	}
+
+	expectedObjects, err := ssa.ReadObjects(bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("invalid %s: %w", fixtureExpectedFile, err)
+	}
+	actualObjects, err := ssa.ReadObjects(bytes.NewReader(actual))
+	if err != nil {
+		return err
+	}
+
+	expected := objectsByID(expectedObjects)
+	got := objectsByID(actualObjects)
+
+	var diffs []string
+	for id, obj := range expected {
+		other, ok := got[id]
+		switch {
+		case !ok:
+			diffs = append(diffs, fmt.Sprintf("missing %s", ssa.FmtUnstructured(obj)))
+		case !apiequality.Semantic.DeepEqual(obj.Object, other.Object):
+			diff, err := objectDiff(obj, other)
+			if err != nil {
+				return err
+			}
+			diffs = append(diffs, fmt.Sprintf("%s differs:\n%s", ssa.FmtUnstructured(obj), diff))
+		}
+	}
+	for id, obj := range got {
+		if _, ok := expected[id]; !ok {
+			diffs = append(diffs, fmt.Sprintf("unexpected %s", ssa.FmtUnstructured(obj)))
+		}
+	}
+
+	if len(diffs) > 0 {
+		sort.Strings(diffs)
+		return fmt.Errorf("%s", strings.Join(diffs, "\n"))
+	}
+	return nil
+}
+
+// objectDiff returns the unified diff of the YAML of the expected and actual objects.
+func objectDiff(expected, actual *unstructured.Unstructured) (string, error) {
+	a, err := yaml.Marshal(expected.Object)
+	if err != nil {
+		return "", err
+	}
+	b, err := yaml.Marshal(actual.Object)
+	if err != nil {
+		return "", err
+	}
+	return unifiedDiff(string(a), string(b), fixtureExpectedFile, "actual"), nil
+}
+
+// diffLine is a line of a diff, kind is ' ' for context, '-' for removed and '+' for added lines.
+type diffLine struct {
+	kind byte
+	text string
+	a, b int
+}
+
+// unifiedDiff returns the line diff of a and b in the unified format,
+// with three lines of context around the changes.
+func unifiedDiff(a, b, fromFile, toFile string) string {
+	const context = 3
+
+	x := strings.Split(strings.TrimSuffix(a, "\n"), "\n")
+	y := strings.Split(strings.TrimSuffix(b, "\n"), "\n")
+
+	// lcs[i][j] is the length of the longest common subsequence of x[i:] and y[j:]
+	lcs := make([][]int, len(x)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(y)+1)
+	}
+	for i := len(x) - 1; i >= 0; i-- {
+		for j := len(y) - 1; j >= 0; j-- {
+			if x[i] == y[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	var lines []diffLine
+	i, j := 0, 0
+	for i < len(x) || j < len(y) {
+		switch {
+		case i < len(x) && j < len(y) && x[i] == y[j]:
+			lines = append(lines, diffLine{' ', x[i], i, j})
+			i++
+			j++
+		case j == len(y) || (i < len(x) && lcs[i+1][j] >= lcs[i][j+1]):
+			lines = append(lines, diffLine{'-', x[i], i, j})
+			i++
+		default:
+			lines = append(lines, diffLine{'+', y[j], i, j})
+			j++
+		}
+	}
+
+	var out strings.Builder
+	fmt.Fprintf(&out, "--- %s\n+++ %s\n", fromFile, toFile)
+	for start := 0; start < len(lines); {
+		first := start
+		for first < len(lines) && lines[first].kind == ' ' {
+			first++
+		}
+		if first == len(lines) {
+			break
+		}
+
+		// extend the hunk until the context between two changes is too large to merge them
+		last := first
+		for k := first; k < len(lines) && k-last <= 2*context; k++ {
+			if lines[k].kind != ' ' {
+				last = k
+			}
+		}
+		if first-context > start {
+			first -= context
+		} else {
+			first = start
+		}
+		end := last + context + 1
+		if end > len(lines) {
+			end = len(lines)
+		}
+
+		var aLen, bLen int
+		for _, line := range lines[first:end] {
+			if line.kind != '+' {
+				aLen++
+			}
+			if line.kind != '-' {
+				bLen++
+			}
+		}
+		fmt.Fprintf(&out, "@@ -%d,%d +%d,%d @@\n", lines[first].a+1, aLen, lines[first].b+1, bLen)
+		for _, line := range lines[first:end] {
+			fmt.Fprintf(&out, "%c%s\n", line.kind, line.text)
+		}
+		start = end
+	}
+	return out.String()
+}
+
+func objectsByID(objects []*unstructured.Unstructured) map[string]*unstructured.Unstructured {
+	result := make(map[string]*unstructured.Unstructured, len(objects))
+	for _, obj := range objects {
+		result[object.UnstructuredToObjMetadata(obj).String()] = obj
+	}
+	return result
+}
+
+// copyDir copies the regular files and directories of src into dst.
+func copyDir(src, dst string) error {
+	return filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+
+		switch {
+		case info.IsDir():
+			return os.MkdirAll(target, os.ModePerm)
+		case info.Mode().IsRegular():
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			return os.WriteFile(target, data, info.Mode().Perm())
+		default:
+			return nil
+		}
+	})
+}
@@ -0,0 +1,94 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestVerifyFixtures(t *testing.T) {
+	g := NewWithT(t)
+
+	var out bytes.Buffer
+	err := VerifyFixtures("testdata/conformance", false, &out)
+	g.Expect(err).NotTo(HaveOccurred(), out.String())
+}
+
+func TestVerifyFixtures_mismatch(t *testing.T) {
+	g := NewWithT(t)
+
+	root := t.TempDir()
+	g.Expect(copyDir("testdata/conformance/generated", filepath.Join(root, "generated"))).To(Succeed())
+	expected := filepath.Join(root, "generated", fixtureExpectedFile)
+	g.Expect(os.WriteFile(expected, []byte("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: config\n  namespace: default\n"), 0o644)).To(Succeed())
+
+	var out bytes.Buffer
+	err := VerifyFixtures(root, false, &out)
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(out.String()).To(ContainSubstring("FAIL generated"))
+
+	out.Reset()
+	g.Expect(os.WriteFile(expected, []byte("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: config\n  namespace: apps\ndata:\n  key: other\n"), 0o644)).To(Succeed())
+	g.Expect(VerifyFixtures(root, false, &out)).NotTo(Succeed())
+	g.Expect(out.String()).To(ContainSubstring("ConfigMap/apps/config differs"))
+	g.Expect(out.String()).To(ContainSubstring("-  key: other\n+  key: value\n"))
+
+	out.Reset()
+	g.Expect(VerifyFixtures(root, true, &out)).To(Succeed())
+	g.Expect(VerifyFixtures(root, false, &out)).To(Succeed())
+}
+
+func Test_unifiedDiff(t *testing.T) {
+	g := NewWithT(t)
+
+	g.Expect(unifiedDiff("a\nb\n", "a\nb\n", "expected", "actual")).To(Equal("--- expected\n+++ actual\n"))
+
+	var a, b []string
+	for i := 0; i < 20; i++ {
+		a = append(a, fmt.Sprintf("line %d", i))
+	}
+	b = append(b, a...)
+	b[1] = "changed 1"
+	b[15] = "changed 15"
+
+	g.Expect(unifiedDiff(strings.Join(a, "\n"), strings.Join(b, "\n"), "expected", "actual")).To(Equal(`--- expected
++++ actual
+@@ -1,5 +1,5 @@
+ line 0
+-line 1
++changed 1
+ line 2
+ line 3
+ line 4
+@@ -13,7 +13,7 @@
+ line 12
+ line 13
+ line 14
+-line 15
++changed 15
+ line 16
+ line 17
+ line 18
+`))
+}
@@ -0,0 +1,81 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+
+	"github.com/blang/semver"
+	"k8s.io/apimachinery/pkg/version"
+	"k8s.io/client-go/discovery"
+
+	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta2"
+)
+
+// matchClusterVersion returns the Kubernetes version of the API server and
+// whether it satisfies the semver range, e.g. '>=1.27.0 <1.30.0'.
+func matchClusterVersion(discoveryClient discovery.ServerVersionInterface, constraint string) (string, bool, error) {
+	versionRange, err := semver.ParseRange(constraint)
+	if err != nil {
+		return "", false, fmt.Errorf("invalid target cluster version '%s': %w", constraint, err)
+	}
+
+	info, err := discoveryClient.ServerVersion()
+	if err != nil {
+		return "", false, fmt.Errorf("failed to get the cluster version: %w", err)
+	}
+
+	v, err := parseClusterVersion(info)
+	if err != nil {
+		return "", false, err
+	}
+	return v.String(), versionRange(v), nil
+}
+
+// serverVersion returns the Kubernetes version of the API server,
+// without the pre-release and build metadata.
+func serverVersion(discoveryClient discovery.ServerVersionInterface) (string, error) {
+	info, err := discoveryClient.ServerVersion()
+	if err != nil {
+		return "", fmt.Errorf("failed to get the cluster version: %w", err)
+	}
+
+	v, err := parseClusterVersion(info)
+	if err != nil {
+		return "", err
+	}
+	return v.String(), nil
+}
+
+// clusterUpgraded returns true if the inventory was applied to
+// another Kubernetes version of the target cluster.
+func clusterUpgraded(kustomization kustomizev1.Kustomization, version string) bool {
+	recorded := kustomization.Status.ClusterVersion
+	return recorded != "" && version != "" && recorded != version
+}
+
+// parseClusterVersion parses the git version of the API server, ignoring the
+// pre-release and build metadata added by some providers, e.g. 'v1.24.8-eks-ffeb93d'.
+func parseClusterVersion(info *version.Info) (semver.Version, error) {
+	v, err := semver.ParseTolerant(info.GitVersion)
+	if err != nil {
+		return semver.Version{}, fmt.Errorf("invalid Kubernetes version '%s': %w", info.GitVersion, err)
+	}
+	v.Pre = nil
+	v.Build = nil
+	return v, nil
+}
@@ -0,0 +1,103 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/version"
+
+	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta2"
+)
+
+type fakeServerVersion struct {
+	info version.Info
+}
+
+func (f fakeServerVersion) ServerVersion() (*version.Info, error) {
+	return &f.info, nil
+}
+
+func Test_matchClusterVersion(t *testing.T) {
+	tests := []struct {
+		name       string
+		gitVersion string
+		constraint string
+		want       string
+		wantMatch  bool
+		wantErr    bool
+	}{
+		{
+			name:       "in range",
+			gitVersion: "v1.27.3",
+			constraint: ">=1.27.0 <1.30.0",
+			want:       "1.27.3",
+			wantMatch:  true,
+		},
+		{
+			name:       "provider suffix",
+			gitVersion: "v1.27.0-eks-ffeb93d",
+			constraint: ">=1.27.0",
+			want:       "1.27.0",
+			wantMatch:  true,
+		},
+		{
+			name:       "out of range",
+			gitVersion: "v1.26.5",
+			constraint: ">=1.27.0",
+			want:       "1.26.5",
+		},
+		{
+			name:       "invalid range",
+			gitVersion: "v1.26.5",
+			constraint: "1.27+",
+			wantErr:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			got, ok, err := matchClusterVersion(fakeServerVersion{version.Info{GitVersion: tt.gitVersion}}, tt.constraint)
+			if tt.wantErr {
+				g.Expect(err).To(HaveOccurred())
+				return
+			}
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(got).To(Equal(tt.want))
+			g.Expect(ok).To(Equal(tt.wantMatch))
+		})
+	}
+}
+
+func Test_clusterUpgraded(t *testing.T) {
+	g := NewWithT(t)
+
+	v, err := serverVersion(fakeServerVersion{version.Info{GitVersion: "v1.28.2-gke.1157000"}})
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(v).To(Equal("1.28.2"))
+
+	k := kustomizev1.Kustomization{}
+	g.Expect(clusterUpgraded(k, v)).To(BeFalse())
+
+	k.Status.ClusterVersion = "1.28.2"
+	g.Expect(clusterUpgraded(k, v)).To(BeFalse())
+	g.Expect(clusterUpgraded(k, "")).To(BeFalse())
+	g.Expect(clusterUpgraded(k, "1.29.0")).To(BeTrue())
+}
@@ -0,0 +1,54 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/cli-utils/pkg/object"
+
+	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta2"
+)
+
+// waitObjects returns the applied objects that are health checked when
+// spec.wait is enabled, restricted to the ones matching the
+// spec.waitOptions.selectors if any.
+func waitObjects(kustomization kustomizev1.Kustomization,
+	objects []*unstructured.Unstructured, applied object.ObjMetadataSet) (object.ObjMetadataSet, error) {
+	if kustomization.Spec.WaitOptions == nil || len(kustomization.Spec.WaitOptions.Selectors) == 0 {
+		return applied, nil
+	}
+
+	var selected object.ObjMetadataSet
+	for _, obj := range objects {
+		id := object.UnstructuredToObjMetadata(obj)
+		if !applied.Contains(id) {
+			continue
+		}
+		for _, selector := range kustomization.Spec.WaitOptions.Selectors {
+			ok, err := selectorMatches(selector, obj)
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				selected = append(selected, id)
+				break
+			}
+		}
+	}
+
+	return selected, nil
+}
@@ -22,13 +22,16 @@ import (
 	"testing"
 	"time"
 
+	"github.com/fluxcd/pkg/apis/kustomize"
 	"github.com/fluxcd/pkg/apis/meta"
 	"github.com/fluxcd/pkg/testserver"
 	sourcev1 "github.com/fluxcd/source-controller/api/v1beta2"
 	. "github.com/onsi/gomega"
 	apimeta "k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/cli-utils/pkg/object"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta2"
@@ -196,3 +199,68 @@ data:
 		g.Expect(events[len(events)-2].Message).To(ContainSubstring(expectedMessage))
 	})
 }
+
+func Test_waitObjects(t *testing.T) {
+	g := NewWithT(t)
+
+	newObject := func(apiVersion, kind, name string, labels map[string]string) *unstructured.Unstructured {
+		u := &unstructured.Unstructured{}
+		u.SetAPIVersion(apiVersion)
+		u.SetKind(kind)
+		u.SetNamespace("apps")
+		u.SetName(name)
+		u.SetLabels(labels)
+		return u
+	}
+
+	objects := []*unstructured.Unstructured{
+		newObject("apps/v1", "Deployment", "frontend", map[string]string{"tier": "critical"}),
+		newObject("apps/v1", "Deployment", "backend", nil),
+		newObject("monitoring.coreos.com/v1", "PodMonitor", "frontend", map[string]string{"tier": "critical"}),
+		newObject("v1", "ConfigMap", "dashboards", map[string]string{"tier": "critical"}),
+	}
+	var applied object.ObjMetadataSet
+	for _, obj := range objects[:3] {
+		applied = append(applied, object.UnstructuredToObjMetadata(obj))
+	}
+
+	k := kustomizev1.Kustomization{}
+	k.Spec.Wait = true
+
+	set, err := waitObjects(k, objects, applied)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(set).To(Equal(applied))
+
+	k.Spec.WaitOptions = &kustomizev1.WaitOptions{
+		Selectors: []kustomize.Selector{
+			{Kind: "Deployment", LabelSelector: "tier=critical"},
+			{Kind: "ConfigMap"},
+		},
+	}
+	set, err = waitObjects(k, objects, applied)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(set).To(Equal(object.ObjMetadataSet{object.UnstructuredToObjMetadata(objects[0])}))
+
+	k.Spec.WaitOptions.Selectors = []kustomize.Selector{{Name: "["}}
+	_, err = waitObjects(k, objects, applied)
+	g.Expect(err).To(HaveOccurred())
+}
+
+func Test_isProgressing(t *testing.T) {
+	g := NewWithT(t)
+
+	k := kustomizev1.Kustomization{}
+	k.Spec.Interval = metav1.Duration{Duration: 10 * time.Minute}
+	k.Spec.RetryInterval = &metav1.Duration{Duration: 2 * time.Minute}
+	g.Expect(isProgressing(k)).To(BeFalse())
+	g.Expect(k.GetProgressingInterval()).To(Equal(2 * time.Minute))
+
+	k.Spec.ProgressingInterval = &metav1.Duration{Duration: 30 * time.Second}
+	g.Expect(k.GetProgressingInterval()).To(Equal(30 * time.Second))
+
+	k = kustomizev1.KustomizationNotReady(k, "v1", kustomizev1.HealthCheckFailedReason, "timeout")
+	g.Expect(isProgressing(k)).To(BeTrue())
+
+	k = kustomizev1.KustomizationNotReady(k, "v1", kustomizev1.ReconciliationFailedReason, "invalid")
+	g.Expect(isProgressing(k)).To(BeFalse())
+}
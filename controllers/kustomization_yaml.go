@@ -0,0 +1,146 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"sigs.k8s.io/kustomize/api/provider"
+)
+
+// yamlLineRegexp matches the line numbers in the YAML decoding errors.
+var yamlLineRegexp = regexp.MustCompile(`line (\d+)`)
+
+// manifestPathRegexp matches the manifest paths in the kustomize build errors.
+var manifestPathRegexp = regexp.MustCompile(`[\w./-]+\.(?:yaml|yml|json)`)
+
+// yamlDocument is a document of a multi-document YAML,
+// with the line numbers of its first and last lines.
+type yamlDocument struct {
+	data      []byte
+	firstLine int
+	lastLine  int
+}
+
+// splitYAMLDocuments splits the multi-document YAML on the '---' separators,
+// the documents holding only comments or whitespace are left out.
+func splitYAMLDocuments(data []byte) []yamlDocument {
+	var docs []yamlDocument
+	var current bytes.Buffer
+	first, n, empty := 1, 0, true
+
+	flush := func(last int) {
+		if !empty {
+			docs = append(docs, yamlDocument{data: append([]byte(nil), current.Bytes()...), firstLine: first, lastLine: last})
+		}
+		current.Reset()
+		empty = true
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), len(data)+1)
+	for scanner.Scan() {
+		n++
+		line := scanner.Text()
+		if line == "---" || strings.HasPrefix(line, "--- ") {
+			flush(n - 1)
+			first = n + 1
+			continue
+		}
+		trimmed := strings.TrimSpace(line)
+		if trimmed != "" && !strings.HasPrefix(trimmed, "#") {
+			empty = false
+		}
+		current.WriteString(line + "\n")
+	}
+	flush(n)
+	return docs
+}
+
+// locateYAMLError decodes the documents of a multi-document YAML one at a time,
+// and returns the error of the first document that fails to decode, along with
+// its position in the file. The line numbers of the decoding error are made
+// relative to the file. It returns nil if all the documents decode.
+func locateYAMLError(data []byte, decode func([]byte) error) error {
+	for i, doc := range splitYAMLDocuments(data) {
+		err := decode(doc.data)
+		if err == nil {
+			continue
+		}
+
+		msg := yamlLineRegexp.ReplaceAllStringFunc(err.Error(), func(match string) string {
+			line, convErr := strconv.Atoi(strings.TrimPrefix(match, "line "))
+			if convErr != nil {
+				return match
+			}
+			return fmt.Sprintf("line %d", line+doc.firstLine-1)
+		})
+		return fmt.Errorf("document %d (lines %d-%d): %s", i+1, doc.firstLine, doc.lastLine, msg)
+	}
+	return nil
+}
+
+// decodeManifestError returns the error of a manifest that fails to decode,
+// with the document index and line number of the offending document.
+func decodeManifestError(path string, data []byte, err error) error {
+	rf := provider.NewDefaultDepProvider().GetResourceFactory()
+	if docErr := locateYAMLError(data, func(doc []byte) error {
+		_, err := rf.SliceFromBytes(doc)
+		return err
+	}); docErr != nil {
+		return fmt.Errorf("failed to decode Kubernetes YAML from %s, %w", path, docErr)
+	}
+	return fmt.Errorf("failed to decode Kubernetes YAML from %s: %w", path, err)
+}
+
+// buildErrorContext returns the position of the first malformed document in the
+// manifests named by the kustomize build error, empty if none is found.
+// The paths are resolved relative to the kustomization directory.
+func buildErrorContext(root, dirPath string, err error) string {
+	rf := provider.NewDefaultDepProvider().GetResourceFactory()
+	seen := make(map[string]bool)
+	for _, name := range manifestPathRegexp.FindAllString(err.Error(), -1) {
+		path := name
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(dirPath, path)
+		}
+		path = filepath.Clean(path)
+		if seen[path] || !strings.HasPrefix(path, filepath.Clean(root)+string(filepath.Separator)) {
+			continue
+		}
+		seen[path] = true
+
+		data, readErr := os.ReadFile(path)
+		if readErr != nil {
+			continue
+		}
+		if docErr := locateYAMLError(data, func(doc []byte) error {
+			_, err := rf.SliceFromBytes(doc)
+			return err
+		}); docErr != nil {
+			return fmt.Sprintf("%s %s", strings.TrimPrefix(path, filepath.Clean(root)+string(filepath.Separator)), docErr)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,91 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"sigs.k8s.io/yaml"
+
+	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta2"
+)
+
+const multiDocumentYAML = `# leading comment
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: first
+---
+# empty document
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: second
+data:
+  key: value
+   bad: indent
+--- # trailing separator comment
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: third
+`
+
+func Test_splitYAMLDocuments(t *testing.T) {
+	g := NewWithT(t)
+
+	docs := splitYAMLDocuments([]byte(multiDocumentYAML))
+	g.Expect(docs).To(HaveLen(3))
+	g.Expect(docs[0].firstLine).To(Equal(3))
+	g.Expect(docs[0].lastLine).To(Equal(6))
+	g.Expect(docs[1].firstLine).To(Equal(10))
+	g.Expect(docs[1].lastLine).To(Equal(16))
+	g.Expect(docs[2].firstLine).To(Equal(18))
+	g.Expect(string(docs[2].data)).To(ContainSubstring("name: third"))
+}
+
+func Test_locateYAMLError(t *testing.T) {
+	g := NewWithT(t)
+
+	decode := func(doc []byte) error {
+		var obj map[string]interface{}
+		return yaml.Unmarshal(doc, &obj)
+	}
+
+	err := locateYAMLError([]byte(multiDocumentYAML), decode)
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(err.Error()).To(HavePrefix("document 2 (lines 10-16): "))
+	g.Expect(err.Error()).To(MatchRegexp(`yaml: line 1[0-6]: `))
+
+	g.Expect(locateYAMLError([]byte("apiVersion: v1\nkind: ConfigMap\n"), decode)).To(Succeed())
+}
+
+func Test_decodeManifestError(t *testing.T) {
+	g := NewWithT(t)
+
+	dir := t.TempDir()
+	g.Expect(os.WriteFile(filepath.Join(dir, "configmaps.yaml"), []byte(multiDocumentYAML), 0o644)).To(Succeed())
+
+	err := NewGenerator(dir, kustomizev1.Kustomization{}).generateKustomization(dir)
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(err.Error()).To(ContainSubstring("configmaps.yaml, document 2 (lines 10-16)"))
+}
@@ -0,0 +1,312 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	apiacl "github.com/fluxcd/pkg/apis/acl"
+	"github.com/fluxcd/pkg/apis/meta"
+	"github.com/fluxcd/pkg/runtime/acl"
+
+	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta2"
+)
+
+// +kubebuilder:rbac:groups=kustomize.toolkit.fluxcd.io,resources=kustomizationgroups,verbs=get;list;watch
+// +kubebuilder:rbac:groups=kustomize.toolkit.fluxcd.io,resources=kustomizationgroups/status,verbs=get;update;patch
+
+// KustomizationGroupReconciler aggregates the readiness, the applied revisions
+// and the failures of the Kustomizations of a group in its status.
+type KustomizationGroupReconciler struct {
+	client.Client
+	ControllerName       string
+	NoCrossNamespaceRefs bool
+}
+
+func (r *KustomizationGroupReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&kustomizev1.KustomizationGroup{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Watches(
+			&source.Kind{Type: &kustomizev1.Kustomization{}},
+			handler.EnqueueRequestsFromMapFunc(r.requestsForKustomizationChange),
+		).
+		Complete(r)
+}
+
+func (r *KustomizationGroupReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := ctrl.LoggerFrom(ctx)
+
+	var group kustomizev1.KustomizationGroup
+	if err := r.Get(ctx, req.NamespacedName, &group); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	if !group.DeletionTimestamp.IsZero() {
+		return ctrl.Result{}, nil
+	}
+
+	patch := client.MergeFrom(group.DeepCopy())
+	members, missing, err := r.members(ctx, group)
+	if err != nil {
+		reason := kustomizev1.ReconciliationFailedReason
+		if acl.IsAccessDenied(err) {
+			reason = apiacl.AccessDeniedReason
+		}
+		group.Status.ObservedGeneration = group.Generation
+		apimeta.SetStatusCondition(&group.Status.Conditions, metav1.Condition{
+			Type:               meta.ReadyCondition,
+			Status:             metav1.ConditionFalse,
+			Reason:             reason,
+			Message:            err.Error(),
+			ObservedGeneration: group.Generation,
+		})
+		if perr := r.Status().Patch(ctx, &group, patch, client.FieldOwner(r.ControllerName)); perr != nil {
+			return ctrl.Result{}, perr
+		}
+		log.Error(err, "unable to select the Kustomizations of the group")
+		if acl.IsAccessDenied(err) {
+			// retrying will not help until the spec is changed
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{}, err
+	}
+
+	conditions := group.Status.Conditions
+	group.Status = summarizeKustomizations(members, missing)
+	group.Status.Conditions = conditions
+	group.Status.ObservedGeneration = group.Generation
+	ready := groupReadiness(group.Status)
+	ready.ObservedGeneration = group.Generation
+	apimeta.SetStatusCondition(&group.Status.Conditions, ready)
+
+	if err := r.Status().Patch(ctx, &group, patch, client.FieldOwner(r.ControllerName)); err != nil {
+		return ctrl.Result{}, err
+	}
+	return ctrl.Result{}, nil
+}
+
+// members returns the Kustomizations referenced or selected by the group,
+// and the referenced Kustomizations that don't exist.
+func (r *KustomizationGroupReconciler) members(ctx context.Context,
+	group kustomizev1.KustomizationGroup) ([]kustomizev1.Kustomization, []types.NamespacedName, error) {
+	var members []kustomizev1.Kustomization
+	var missing []types.NamespacedName
+	seen := make(map[types.NamespacedName]bool)
+
+	for _, ref := range group.Spec.Kustomizations {
+		key := types.NamespacedName{Namespace: group.GetNamespace(), Name: ref.Name}
+		if ref.Namespace != "" {
+			key.Namespace = ref.Namespace
+		}
+
+		if r.NoCrossNamespaceRefs && key.Namespace != group.GetNamespace() {
+			return nil, nil, acl.AccessDeniedError(
+				fmt.Sprintf("can't access '%s/%s', cross-namespace references have been blocked",
+					kustomizev1.KustomizationKind, key))
+		}
+
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		var k kustomizev1.Kustomization
+		if err := r.Get(ctx, key, &k); err != nil {
+			if apierrors.IsNotFound(err) {
+				missing = append(missing, key)
+				continue
+			}
+			return nil, nil, fmt.Errorf("unable to get Kustomization '%s': %w", key, err)
+		}
+		members = append(members, k)
+	}
+
+	if group.Spec.Selector != nil {
+		selector, err := metav1.LabelSelectorAsSelector(group.Spec.Selector)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid selector: %w", err)
+		}
+
+		var list kustomizev1.KustomizationList
+		if err := r.List(ctx, &list,
+			client.InNamespace(group.GetNamespace()),
+			client.MatchingLabelsSelector{Selector: selector}); err != nil {
+			return nil, nil, fmt.Errorf("unable to list Kustomizations: %w", err)
+		}
+
+		for _, k := range list.Items {
+			key := client.ObjectKeyFromObject(&k)
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			members = append(members, k)
+		}
+	}
+
+	return members, missing, nil
+}
+
+// requestsForKustomizationChange returns the groups that reference or
+// select the given Kustomization.
+func (r *KustomizationGroupReconciler) requestsForKustomizationChange(obj client.Object) []reconcile.Request {
+	ctx := context.Background()
+	var list kustomizev1.KustomizationGroupList
+	if err := r.List(ctx, &list); err != nil {
+		return nil
+	}
+
+	var reqs []reconcile.Request
+	for _, group := range list.Items {
+		if groupContains(group, obj) {
+			reqs = append(reqs, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&group)})
+		}
+	}
+	return reqs
+}
+
+// groupContains returns true if the group references the given object,
+// or if its selector matches the labels of the object.
+func groupContains(group kustomizev1.KustomizationGroup, obj client.Object) bool {
+	for _, ref := range group.Spec.Kustomizations {
+		namespace := group.GetNamespace()
+		if ref.Namespace != "" {
+			namespace = ref.Namespace
+		}
+		if ref.Name == obj.GetName() && namespace == obj.GetNamespace() {
+			return true
+		}
+	}
+
+	if group.Spec.Selector == nil || group.GetNamespace() != obj.GetNamespace() {
+		return false
+	}
+	selector, err := metav1.LabelSelectorAsSelector(group.Spec.Selector)
+	if err != nil {
+		return false
+	}
+	return selector.Matches(labels.Set(obj.GetLabels()))
+}
+
+// summarizeKustomizations returns the number of ready, failed and suspended
+// Kustomizations, the number of Kustomizations for each last applied revision,
+// and the failures ordered by namespace and name. The missing Kustomizations
+// are counted as failed.
+func summarizeKustomizations(members []kustomizev1.Kustomization,
+	missing []types.NamespacedName) kustomizev1.KustomizationGroupStatus {
+	status := kustomizev1.KustomizationGroupStatus{
+		Total:  len(members) + len(missing),
+		Failed: len(missing),
+	}
+
+	var failures []kustomizev1.KustomizationFailure
+	for _, key := range missing {
+		failures = append(failures, kustomizev1.KustomizationFailure{
+			Name:      key.Name,
+			Namespace: key.Namespace,
+			Reason:    kustomizev1.KustomizationNotFoundReason,
+			Message:   "Kustomization not found",
+		})
+	}
+
+	revisions := make(map[string]int)
+	for _, k := range members {
+		if k.Spec.Suspend {
+			status.Suspended++
+		}
+		if rev := k.Status.LastAppliedRevision; rev != "" {
+			revisions[rev]++
+		}
+
+		ready := apimeta.FindStatusCondition(k.Status.Conditions, meta.ReadyCondition)
+		switch {
+		case ready == nil:
+		case ready.Status == metav1.ConditionTrue:
+			status.Ready++
+		case ready.Status == metav1.ConditionFalse:
+			status.Failed++
+			failures = append(failures, kustomizev1.KustomizationFailure{
+				Name:      k.GetName(),
+				Namespace: k.GetNamespace(),
+				Revision:  k.Status.LastAttemptedRevision,
+				Reason:    ready.Reason,
+				Message:   ready.Message,
+			})
+		}
+	}
+
+	for rev, count := range revisions {
+		status.Revisions = append(status.Revisions, kustomizev1.RevisionCount{Revision: rev, Count: count})
+	}
+	sort.Slice(status.Revisions, func(i, j int) bool {
+		if status.Revisions[i].Count != status.Revisions[j].Count {
+			return status.Revisions[i].Count > status.Revisions[j].Count
+		}
+		return status.Revisions[i].Revision < status.Revisions[j].Revision
+	})
+
+	sort.Slice(failures, func(i, j int) bool {
+		if failures[i].Namespace != failures[j].Namespace {
+			return failures[i].Namespace < failures[j].Namespace
+		}
+		return failures[i].Name < failures[j].Name
+	})
+	if len(failures) > kustomizev1.MaxKustomizationGroupFailures {
+		failures = failures[:kustomizev1.MaxKustomizationGroupFailures]
+	}
+	status.Failures = failures
+
+	return status
+}
+
+// groupReadiness returns the Ready condition of a group, the group is ready
+// when all its Kustomizations are ready, not ready when at least one of them
+// failed, and progressing otherwise.
+func groupReadiness(status kustomizev1.KustomizationGroupStatus) metav1.Condition {
+	condition := metav1.Condition{
+		Type:    meta.ReadyCondition,
+		Message: fmt.Sprintf("%d of %d Kustomizations are ready", status.Ready, status.Total),
+	}
+	switch {
+	case status.Failed > 0:
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = kustomizev1.ReconciliationFailedReason
+		condition.Message = fmt.Sprintf("%d of %d Kustomizations failed", status.Failed, status.Total)
+	case status.Ready == status.Total:
+		condition.Status = metav1.ConditionTrue
+		condition.Reason = kustomizev1.ReconciliationSucceededReason
+	default:
+		condition.Status = metav1.ConditionUnknown
+		condition.Reason = meta.ProgressingReason
+	}
+	return condition
+}
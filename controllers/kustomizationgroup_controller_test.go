@@ -0,0 +1,165 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/fluxcd/pkg/apis/meta"
+
+	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta2"
+)
+
+func newGroupMember(namespace, name string, status metav1.ConditionStatus, reason, revision string) *kustomizev1.Kustomization {
+	k := &kustomizev1.Kustomization{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+			Labels:    map[string]string{"fleet": "edge"},
+		},
+	}
+	k.Status.LastAppliedRevision = revision
+	k.Status.LastAttemptedRevision = revision
+	if status != "" {
+		k.Status.Conditions = []metav1.Condition{{Type: meta.ReadyCondition, Status: status, Reason: reason, Message: reason}}
+	}
+	return k
+}
+
+func TestSummarizeKustomizations(t *testing.T) {
+	g := NewWithT(t)
+
+	suspended := newGroupMember("apps", "suspended", metav1.ConditionTrue, kustomizev1.ReconciliationSucceededReason, "main/2")
+	suspended.Spec.Suspend = true
+	members := []kustomizev1.Kustomization{
+		*newGroupMember("apps", "b", metav1.ConditionFalse, kustomizev1.HealthCheckFailedReason, "main/2"),
+		*newGroupMember("apps", "a", metav1.ConditionTrue, kustomizev1.ReconciliationSucceededReason, "main/1"),
+		*newGroupMember("apps", "c", metav1.ConditionUnknown, meta.ProgressingReason, ""),
+		*suspended,
+	}
+	missing := []types.NamespacedName{{Namespace: "apps", Name: "absent"}}
+
+	status := summarizeKustomizations(members, missing)
+	g.Expect(status.Total).To(Equal(5))
+	g.Expect(status.Ready).To(Equal(2))
+	g.Expect(status.Failed).To(Equal(2))
+	g.Expect(status.Suspended).To(Equal(1))
+	g.Expect(status.Revisions).To(Equal([]kustomizev1.RevisionCount{
+		{Revision: "main/2", Count: 2},
+		{Revision: "main/1", Count: 1},
+	}))
+	g.Expect(status.Failures).To(Equal([]kustomizev1.KustomizationFailure{
+		{Name: "absent", Namespace: "apps", Reason: kustomizev1.KustomizationNotFoundReason, Message: "Kustomization not found"},
+		{Name: "b", Namespace: "apps", Revision: "main/2", Reason: kustomizev1.HealthCheckFailedReason, Message: kustomizev1.HealthCheckFailedReason},
+	}))
+
+	ready := groupReadiness(status)
+	g.Expect(ready.Status).To(Equal(metav1.ConditionFalse))
+	g.Expect(ready.Message).To(Equal("2 of 5 Kustomizations failed"))
+
+	g.Expect(groupReadiness(summarizeKustomizations(members[1:], nil)).Status).To(Equal(metav1.ConditionUnknown))
+	g.Expect(groupReadiness(summarizeKustomizations(members[1:2], nil)).Status).To(Equal(metav1.ConditionTrue))
+
+	var many []kustomizev1.Kustomization
+	for i := 0; i < kustomizev1.MaxKustomizationGroupFailures+10; i++ {
+		many = append(many, *newGroupMember("apps", fmt.Sprintf("app-%03d", i), metav1.ConditionFalse, "BuildFailed", "main/1"))
+	}
+	status = summarizeKustomizations(many, nil)
+	g.Expect(status.Failed).To(Equal(kustomizev1.MaxKustomizationGroupFailures + 10))
+	g.Expect(status.Failures).To(HaveLen(kustomizev1.MaxKustomizationGroupFailures))
+}
+
+func TestGroupContains(t *testing.T) {
+	g := NewWithT(t)
+
+	group := kustomizev1.KustomizationGroup{
+		ObjectMeta: metav1.ObjectMeta{Name: "fleet", Namespace: "apps"},
+		Spec: kustomizev1.KustomizationGroupSpec{
+			Kustomizations: []meta.NamespacedObjectReference{
+				{Name: "infra", Namespace: "flux-system"},
+				{Name: "podinfo"},
+			},
+			Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"fleet": "edge"}},
+		},
+	}
+
+	g.Expect(groupContains(group, newGroupMember("flux-system", "infra", "", "", ""))).To(BeTrue())
+	g.Expect(groupContains(group, &kustomizev1.Kustomization{
+		ObjectMeta: metav1.ObjectMeta{Name: "podinfo", Namespace: "apps"},
+	})).To(BeTrue())
+	g.Expect(groupContains(group, newGroupMember("apps", "edge", "", "", ""))).To(BeTrue())
+	// the selector applies to the namespace of the group only
+	g.Expect(groupContains(group, newGroupMember("default", "edge", "", "", ""))).To(BeFalse())
+	g.Expect(groupContains(group, &kustomizev1.Kustomization{
+		ObjectMeta: metav1.ObjectMeta{Name: "other", Namespace: "apps"},
+	})).To(BeFalse())
+}
+
+func TestKustomizationGroupReconciler_Reconcile(t *testing.T) {
+	g := NewWithT(t)
+
+	scheme := runtime.NewScheme()
+	g.Expect(kustomizev1.AddToScheme(scheme)).To(Succeed())
+
+	group := &kustomizev1.KustomizationGroup{
+		ObjectMeta: metav1.ObjectMeta{Name: "fleet", Namespace: "apps", Generation: 1},
+		Spec: kustomizev1.KustomizationGroupSpec{
+			Kustomizations: []meta.NamespacedObjectReference{
+				{Name: "infra", Namespace: "flux-system"},
+			},
+			Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"fleet": "edge"}},
+		},
+	}
+	kubeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+		group,
+		newGroupMember("flux-system", "infra", metav1.ConditionTrue, kustomizev1.ReconciliationSucceededReason, "main/1"),
+		newGroupMember("apps", "edge-1", metav1.ConditionTrue, kustomizev1.ReconciliationSucceededReason, "main/1"),
+		newGroupMember("apps", "edge-2", metav1.ConditionTrue, kustomizev1.ReconciliationSucceededReason, "main/1"),
+	).Build()
+	r := &KustomizationGroupReconciler{Client: kubeClient, ControllerName: "kustomize-controller"}
+
+	ctx := context.TODO()
+	key := client.ObjectKeyFromObject(group)
+	_, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+	g.Expect(err).NotTo(HaveOccurred())
+
+	g.Expect(kubeClient.Get(ctx, key, group)).To(Succeed())
+	g.Expect(group.Status.Total).To(Equal(3))
+	g.Expect(group.Status.Ready).To(Equal(3))
+	g.Expect(group.Status.Revisions).To(Equal([]kustomizev1.RevisionCount{{Revision: "main/1", Count: 3}}))
+	g.Expect(apimeta.IsStatusConditionTrue(group.Status.Conditions, meta.ReadyCondition)).To(BeTrue())
+
+	r.NoCrossNamespaceRefs = true
+	_, err = r.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+	g.Expect(err).NotTo(HaveOccurred())
+
+	g.Expect(kubeClient.Get(ctx, key, group)).To(Succeed())
+	ready := apimeta.FindStatusCondition(group.Status.Conditions, meta.ReadyCondition)
+	g.Expect(ready.Status).To(Equal(metav1.ConditionFalse))
+	g.Expect(ready.Message).To(ContainSubstring("cross-namespace references have been blocked"))
+}
@@ -0,0 +1,285 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	"github.com/fluxcd/pkg/apis/meta"
+
+	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta2"
+)
+
+// +kubebuilder:rbac:groups=kustomize.toolkit.fluxcd.io,resources=kustomizationsets,verbs=get;list;watch;patch
+// +kubebuilder:rbac:groups=kustomize.toolkit.fluxcd.io,resources=kustomizationsets/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=kustomize.toolkit.fluxcd.io,resources=kustomizationsets/finalizers,verbs=get;create;update;patch;delete
+
+// KustomizationSetReconciler generates a Kustomization for each element
+// of a KustomizationSet.
+type KustomizationSetReconciler struct {
+	client.Client
+	Scheme         *runtime.Scheme
+	ControllerName string
+}
+
+func (r *KustomizationSetReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&kustomizev1.KustomizationSet{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Owns(&kustomizev1.Kustomization{}, builder.WithPredicates(
+			predicate.Or(predicate.GenerationChangedPredicate{}, KustomizationStatusChangePredicate{}),
+		)).
+		Complete(r)
+}
+
+func (r *KustomizationSetReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := ctrl.LoggerFrom(ctx)
+
+	var set kustomizev1.KustomizationSet
+	if err := r.Get(ctx, req.NamespacedName, &set); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	// the generated Kustomizations are garbage collected through their owner reference
+	if !set.DeletionTimestamp.IsZero() {
+		return ctrl.Result{}, nil
+	}
+
+	if set.Spec.Suspend {
+		log.Info("Reconciliation is suspended for this object")
+		return ctrl.Result{}, nil
+	}
+
+	names, reconcileErr := r.reconcileSet(ctx, &set)
+
+	targets, err := r.targets(ctx, &set, names)
+	if err != nil && reconcileErr == nil {
+		reconcileErr = err
+	}
+
+	patch := client.MergeFrom(set.DeepCopy())
+	set.Status.ObservedGeneration = set.Generation
+	set.Status.Kustomizations = names
+	set.Status.Targets = targets
+	condition := metav1.Condition{
+		Type:               meta.ReadyCondition,
+		Status:             metav1.ConditionTrue,
+		Reason:             kustomizev1.ReconciliationSucceededReason,
+		Message:            fmt.Sprintf("Generated %d Kustomizations", len(names)),
+		ObservedGeneration: set.Generation,
+	}
+	if reconcileErr != nil {
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = kustomizev1.ReconciliationFailedReason
+		condition.Message = reconcileErr.Error()
+	}
+	apimeta.SetStatusCondition(&set.Status.Conditions, condition)
+
+	if err := r.Status().Patch(ctx, &set, patch, client.FieldOwner(r.ControllerName)); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if reconcileErr != nil {
+		log.Error(reconcileErr, "failed to generate the Kustomizations")
+		return ctrl.Result{}, reconcileErr
+	}
+
+	log.Info(condition.Message)
+	return ctrl.Result{}, nil
+}
+
+// reconcileSet creates or updates the Kustomizations of the set elements and
+// deletes the ones generated for elements removed from the set. It returns the
+// names of the Kustomizations generated so far.
+func (r *KustomizationSetReconciler) reconcileSet(ctx context.Context, set *kustomizev1.KustomizationSet) ([]string, error) {
+	desired, err := generateKustomizations(*set)
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	keep := make(map[string]bool, len(desired))
+	for i := range desired {
+		if err := r.apply(ctx, set, &desired[i]); err != nil {
+			return names, err
+		}
+		names = append(names, desired[i].Name)
+		keep[desired[i].Name] = true
+	}
+
+	var generated kustomizev1.KustomizationList
+	if err := r.List(ctx, &generated,
+		client.InNamespace(set.Namespace),
+		client.MatchingLabels{kustomizev1.KustomizationSetLabel: set.Name}); err != nil {
+		return names, fmt.Errorf("failed to list the generated Kustomizations: %w", err)
+	}
+
+	for i := range generated.Items {
+		ks := &generated.Items[i]
+		if keep[ks.Name] || !metav1.IsControlledBy(ks, set) {
+			continue
+		}
+		if err := r.Delete(ctx, ks); client.IgnoreNotFound(err) != nil {
+			return names, fmt.Errorf("failed to delete Kustomization '%s': %w", ks.Name, err)
+		}
+	}
+
+	return names, nil
+}
+
+// targets returns the applied revision and the readiness of the generated
+// Kustomizations with the given names, in the same order.
+func (r *KustomizationSetReconciler) targets(ctx context.Context, set *kustomizev1.KustomizationSet,
+	names []string) ([]kustomizev1.KustomizationSetTarget, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+
+	var generated kustomizev1.KustomizationList
+	if err := r.List(ctx, &generated,
+		client.InNamespace(set.Namespace),
+		client.MatchingLabels{kustomizev1.KustomizationSetLabel: set.Name}); err != nil {
+		return nil, fmt.Errorf("failed to list the generated Kustomizations: %w", err)
+	}
+	return kustomizationSetTargets(*set, names, generated.Items), nil
+}
+
+// kustomizationSetTargets returns the state of the Kustomizations with the given
+// names, the ones that are not found yet are reported with an Unknown readiness.
+func kustomizationSetTargets(set kustomizev1.KustomizationSet, names []string,
+	generated []kustomizev1.Kustomization) []kustomizev1.KustomizationSetTarget {
+	byName := make(map[string]*kustomizev1.Kustomization, len(generated))
+	for i := range generated {
+		byName[generated[i].Name] = &generated[i]
+	}
+
+	targets := make([]kustomizev1.KustomizationSetTarget, 0, len(names))
+	for _, name := range names {
+		target := kustomizev1.KustomizationSetTarget{
+			Name:          strings.TrimPrefix(name, set.Name+"-"),
+			Kustomization: name,
+			Ready:         metav1.ConditionUnknown,
+		}
+		if ks, ok := byName[name]; ok {
+			target.LastAppliedRevision = ks.Status.LastAppliedRevision
+			target.LastAttemptedRevision = ks.Status.LastAttemptedRevision
+			if ready := apimeta.FindStatusCondition(ks.Status.Conditions, meta.ReadyCondition); ready != nil {
+				target.Ready = ready.Status
+				target.Reason = ready.Reason
+				target.Message = ready.Message
+			}
+		}
+		targets = append(targets, target)
+	}
+	return targets
+}
+
+// apply creates or updates the given Kustomization, refusing to take over
+// Kustomizations that were not generated by the set.
+func (r *KustomizationSetReconciler) apply(ctx context.Context, set *kustomizev1.KustomizationSet, desired *kustomizev1.Kustomization) error {
+	ks := &kustomizev1.Kustomization{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      desired.Name,
+			Namespace: desired.Namespace,
+		},
+	}
+
+	_, err := controllerutil.CreateOrUpdate(ctx, r.Client, ks, func() error {
+		if ks.ResourceVersion != "" && !metav1.IsControlledBy(ks, set) {
+			return fmt.Errorf("Kustomization '%s' already exists and is not managed by this set", ks.Name)
+		}
+		ks.Labels = mergeStringMaps(ks.Labels, desired.Labels)
+		ks.Annotations = mergeStringMaps(ks.Annotations, desired.Annotations)
+		ks.Spec = desired.Spec
+		return controllerutil.SetControllerReference(set, ks, r.Scheme)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to generate Kustomization '%s': %w", desired.Name, err)
+	}
+	return nil
+}
+
+// generateKustomizations returns the Kustomizations described by the template
+// of the set, one for each element. The element variables are added to the
+// post build substitutions of the template, the referenced Secrets and
+// ConfigMaps are substituted after the ones of the template.
+func generateKustomizations(set kustomizev1.KustomizationSet) ([]kustomizev1.Kustomization, error) {
+	var result []kustomizev1.Kustomization
+	seen := make(map[string]bool, len(set.Spec.Elements))
+	for _, element := range set.Spec.Elements {
+		if seen[element.Name] {
+			return nil, fmt.Errorf("duplicate element '%s'", element.Name)
+		}
+		seen[element.Name] = true
+
+		name := fmt.Sprintf("%s-%s", set.Name, element.Name)
+		if errs := validation.IsDNS1123Subdomain(name); len(errs) > 0 {
+			return nil, fmt.Errorf("invalid Kustomization name '%s' for element '%s': %s",
+				name, element.Name, strings.Join(errs, ", "))
+		}
+
+		spec := *set.Spec.Template.Spec.DeepCopy()
+		if len(element.Variables) > 0 || len(element.VariablesFrom) > 0 {
+			if spec.PostBuild == nil {
+				spec.PostBuild = &kustomizev1.PostBuild{}
+			}
+			if len(element.Variables) > 0 {
+				spec.PostBuild.Substitute = mergeStringMaps(spec.PostBuild.Substitute, element.Variables)
+			}
+			spec.PostBuild.SubstituteFrom = append(spec.PostBuild.SubstituteFrom, element.VariablesFrom...)
+		}
+
+		labels := mergeStringMaps(nil, set.Spec.Template.Labels)
+		labels = mergeStringMaps(labels, map[string]string{kustomizev1.KustomizationSetLabel: set.Name})
+
+		result = append(result, kustomizev1.Kustomization{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        name,
+				Namespace:   set.Namespace,
+				Labels:      labels,
+				Annotations: mergeStringMaps(nil, set.Spec.Template.Annotations),
+			},
+			Spec: spec,
+		})
+	}
+	return result, nil
+}
+
+// mergeStringMaps returns dst with the key/value pairs of src added to it.
+func mergeStringMaps(dst, src map[string]string) map[string]string {
+	if len(src) == 0 {
+		return dst
+	}
+	if dst == nil {
+		dst = make(map[string]string, len(src))
+	}
+	for k, v := range src {
+		dst[k] = v
+	}
+	return dst
+}
@@ -0,0 +1,222 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/fluxcd/pkg/apis/meta"
+
+	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta2"
+)
+
+func newTestKustomizationSet() *kustomizev1.KustomizationSet {
+	return &kustomizev1.KustomizationSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "clusters", Namespace: "flux-system", Generation: 1},
+		Spec: kustomizev1.KustomizationSetSpec{
+			Elements: []kustomizev1.KustomizationSetElement{
+				{
+					Name:      "eu",
+					Variables: map[string]string{"region": "eu-west-1"},
+					VariablesFrom: []kustomizev1.SubstituteReference{
+						{Kind: "Secret", Name: "eu-credentials"},
+					},
+				},
+				{
+					Name:      "us",
+					Variables: map[string]string{"region": "us-east-1", "tier": "premium"},
+				},
+			},
+			Template: kustomizev1.KustomizationTemplate{
+				Labels: map[string]string{"team": "platform"},
+				Spec: kustomizev1.KustomizationSpec{
+					Path: "./deploy",
+					SourceRef: kustomizev1.CrossNamespaceSourceReference{
+						Kind: "GitRepository",
+						Name: "fleet",
+					},
+					PostBuild: &kustomizev1.PostBuild{
+						Substitute: map[string]string{"tier": "standard"},
+						SubstituteFrom: []kustomizev1.SubstituteReference{
+							{Kind: "ConfigMap", Name: "defaults"},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func TestGenerateKustomizations(t *testing.T) {
+	g := NewWithT(t)
+
+	set := newTestKustomizationSet()
+	result, err := generateKustomizations(*set)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(result).To(HaveLen(2))
+
+	eu := result[0]
+	g.Expect(eu.Name).To(Equal("clusters-eu"))
+	g.Expect(eu.Namespace).To(Equal("flux-system"))
+	g.Expect(eu.Labels).To(Equal(map[string]string{
+		"team":                            "platform",
+		kustomizev1.KustomizationSetLabel: "clusters",
+	}))
+	g.Expect(eu.Spec.PostBuild.Substitute).To(Equal(map[string]string{"region": "eu-west-1", "tier": "standard"}))
+	g.Expect(eu.Spec.PostBuild.SubstituteFrom).To(Equal([]kustomizev1.SubstituteReference{
+		{Kind: "ConfigMap", Name: "defaults"},
+		{Kind: "Secret", Name: "eu-credentials"},
+	}))
+
+	us := result[1]
+	g.Expect(us.Name).To(Equal("clusters-us"))
+	g.Expect(us.Spec.PostBuild.Substitute).To(Equal(map[string]string{"region": "us-east-1", "tier": "premium"}))
+	g.Expect(us.Spec.PostBuild.SubstituteFrom).To(HaveLen(1))
+
+	// the template is not modified
+	g.Expect(set.Spec.Template.Spec.PostBuild.Substitute).To(Equal(map[string]string{"tier": "standard"}))
+	g.Expect(set.Spec.Template.Spec.PostBuild.SubstituteFrom).To(HaveLen(1))
+
+	set.Spec.Elements = append(set.Spec.Elements, kustomizev1.KustomizationSetElement{Name: "eu"})
+	_, err = generateKustomizations(*set)
+	g.Expect(err).To(MatchError(ContainSubstring("duplicate element 'eu'")))
+}
+
+func TestKustomizationSetReconciler_Reconcile(t *testing.T) {
+	g := NewWithT(t)
+
+	scheme := runtime.NewScheme()
+	g.Expect(kustomizev1.AddToScheme(scheme)).To(Succeed())
+
+	set := newTestKustomizationSet()
+	kubeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(set).Build()
+	r := &KustomizationSetReconciler{Client: kubeClient, Scheme: scheme, ControllerName: "kustomize-controller"}
+
+	ctx := context.TODO()
+	key := client.ObjectKeyFromObject(set)
+	_, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+	g.Expect(err).NotTo(HaveOccurred())
+
+	var generated kustomizev1.KustomizationList
+	g.Expect(kubeClient.List(ctx, &generated, client.InNamespace("flux-system"))).To(Succeed())
+	g.Expect(generated.Items).To(HaveLen(2))
+	for _, ks := range generated.Items {
+		g.Expect(metav1.IsControlledBy(&ks, set)).To(BeTrue())
+	}
+
+	g.Expect(kubeClient.Get(ctx, key, set)).To(Succeed())
+	g.Expect(set.Status.Kustomizations).To(Equal([]string{"clusters-eu", "clusters-us"}))
+	g.Expect(set.Status.Targets).To(HaveLen(2))
+	g.Expect(set.Status.Targets[1].Name).To(Equal("us"))
+	g.Expect(set.Status.Targets[1].Kustomization).To(Equal("clusters-us"))
+	g.Expect(set.Status.Targets[1].Ready).To(Equal(metav1.ConditionUnknown))
+	g.Expect(apimeta.IsStatusConditionTrue(set.Status.Conditions, meta.ReadyCondition)).To(BeTrue())
+
+	set.Spec.Elements = set.Spec.Elements[:1]
+	g.Expect(kubeClient.Update(ctx, set)).To(Succeed())
+	_, err = r.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+	g.Expect(err).NotTo(HaveOccurred())
+
+	g.Expect(kubeClient.List(ctx, &generated, client.InNamespace("flux-system"))).To(Succeed())
+	g.Expect(generated.Items).To(HaveLen(1))
+	g.Expect(generated.Items[0].Name).To(Equal("clusters-eu"))
+
+	// Kustomizations not generated by the set are left untouched
+	g.Expect(kubeClient.Create(ctx, &kustomizev1.Kustomization{
+		ObjectMeta: metav1.ObjectMeta{Name: "clusters-us", Namespace: "flux-system"},
+	})).To(Succeed())
+	set.Spec.Elements = newTestKustomizationSet().Spec.Elements
+	g.Expect(kubeClient.Update(ctx, set)).To(Succeed())
+	_, err = r.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+	g.Expect(err).To(MatchError(ContainSubstring("not managed by this set")))
+
+	var existing kustomizev1.Kustomization
+	g.Expect(kubeClient.Get(ctx, types.NamespacedName{Namespace: "flux-system", Name: "clusters-us"}, &existing)).To(Succeed())
+	g.Expect(existing.OwnerReferences).To(BeEmpty())
+
+	g.Expect(kubeClient.Get(ctx, key, set)).To(Succeed())
+	g.Expect(apimeta.IsStatusConditionFalse(set.Status.Conditions, meta.ReadyCondition)).To(BeTrue())
+}
+
+func TestKustomizationSetTargets(t *testing.T) {
+	g := NewWithT(t)
+
+	set := newTestKustomizationSet()
+	generated := []kustomizev1.Kustomization{
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "clusters-eu", Namespace: "flux-system"},
+			Status: kustomizev1.KustomizationStatus{
+				LastAppliedRevision:   "main/1",
+				LastAttemptedRevision: "main/2",
+				Conditions: []metav1.Condition{{
+					Type:    meta.ReadyCondition,
+					Status:  metav1.ConditionFalse,
+					Reason:  kustomizev1.HealthCheckFailedReason,
+					Message: "timeout waiting for: [Deployment/apps/backend status: 'InProgress']",
+				}},
+			},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "clusters-us", Namespace: "flux-system"},
+			Status: kustomizev1.KustomizationStatus{
+				LastAppliedRevision:   "main/2",
+				LastAttemptedRevision: "main/2",
+				Conditions: []metav1.Condition{{
+					Type:   meta.ReadyCondition,
+					Status: metav1.ConditionTrue,
+					Reason: kustomizev1.ReconciliationSucceededReason,
+				}},
+			},
+		},
+	}
+
+	targets := kustomizationSetTargets(*set, []string{"clusters-eu", "clusters-us", "clusters-ap"}, generated)
+	g.Expect(targets).To(Equal([]kustomizev1.KustomizationSetTarget{
+		{
+			Name:                  "eu",
+			Kustomization:         "clusters-eu",
+			LastAppliedRevision:   "main/1",
+			LastAttemptedRevision: "main/2",
+			Ready:                 metav1.ConditionFalse,
+			Reason:                kustomizev1.HealthCheckFailedReason,
+			Message:               "timeout waiting for: [Deployment/apps/backend status: 'InProgress']",
+		},
+		{
+			Name:                  "us",
+			Kustomization:         "clusters-us",
+			LastAppliedRevision:   "main/2",
+			LastAttemptedRevision: "main/2",
+			Ready:                 metav1.ConditionTrue,
+			Reason:                kustomizev1.ReconciliationSucceededReason,
+		},
+		{
+			Name:          "ap",
+			Kustomization: "clusters-ap",
+			Ready:         metav1.ConditionUnknown,
+		},
+	}))
+}
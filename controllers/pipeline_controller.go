@@ -0,0 +1,328 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	apiacl "github.com/fluxcd/pkg/apis/acl"
+	"github.com/fluxcd/pkg/apis/meta"
+	"github.com/fluxcd/pkg/runtime/acl"
+
+	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta2"
+)
+
+// +kubebuilder:rbac:groups=kustomize.toolkit.fluxcd.io,resources=pipelines,verbs=get;list;watch
+// +kubebuilder:rbac:groups=kustomize.toolkit.fluxcd.io,resources=pipelines/status,verbs=get;update;patch
+
+// PipelineReconciler promotes the revisions applied by the Kustomization of a
+// stage to the Kustomization of the next stage, once the soak time of the stage
+// has elapsed and the promotion has been approved.
+type PipelineReconciler struct {
+	client.Client
+	ControllerName       string
+	NoCrossNamespaceRefs bool
+}
+
+func (r *PipelineReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&kustomizev1.Pipeline{}, builder.WithPredicates(
+			predicate.Or(
+				predicate.GenerationChangedPredicate{},
+				AnnotationChangedPredicate{Annotations: []string{kustomizev1.PromotionApprovalAnnotation}},
+			),
+		)).
+		Watches(
+			&source.Kind{Type: &kustomizev1.Kustomization{}},
+			handler.EnqueueRequestsFromMapFunc(r.requestsForKustomizationChange),
+		).
+		Complete(r)
+}
+
+func (r *PipelineReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := ctrl.LoggerFrom(ctx)
+
+	var pipeline kustomizev1.Pipeline
+	if err := r.Get(ctx, req.NamespacedName, &pipeline); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	if !pipeline.DeletionTimestamp.IsZero() {
+		return ctrl.Result{}, nil
+	}
+
+	patch := client.MergeFrom(pipeline.DeepCopy())
+	members, err := r.members(ctx, pipeline)
+	if err != nil {
+		reason := kustomizev1.ReconciliationFailedReason
+		if acl.IsAccessDenied(err) {
+			reason = apiacl.AccessDeniedReason
+		}
+		pipeline.Status.ObservedGeneration = pipeline.Generation
+		apimeta.SetStatusCondition(&pipeline.Status.Conditions, metav1.Condition{
+			Type:               meta.ReadyCondition,
+			Status:             metav1.ConditionFalse,
+			Reason:             reason,
+			Message:            err.Error(),
+			ObservedGeneration: pipeline.Generation,
+		})
+		if perr := r.Status().Patch(ctx, &pipeline, patch, client.FieldOwner(r.ControllerName)); perr != nil {
+			return ctrl.Result{}, perr
+		}
+		log.Error(err, "unable to get the Kustomizations of the pipeline")
+		if acl.IsAccessDenied(err) {
+			// retrying will not help until the spec is changed
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{}, err
+	}
+
+	now := metav1.Now()
+	stages, requeue := promoteRevisions(pipeline, members, now)
+
+	for i, stage := range stages {
+		k := members[i]
+		if k == nil || stage.PromotedRevision == "" ||
+			k.GetAnnotations()[kustomizev1.PromotedRevisionAnnotation] == stage.PromotedRevision {
+			continue
+		}
+		kpatch := client.MergeFrom(k.DeepCopy())
+		annotations := k.GetAnnotations()
+		if annotations == nil {
+			annotations = make(map[string]string)
+		}
+		annotations[kustomizev1.PromotedRevisionAnnotation] = stage.PromotedRevision
+		k.SetAnnotations(annotations)
+		if err := r.Patch(ctx, k, kpatch, client.FieldOwner(r.ControllerName)); err != nil {
+			return ctrl.Result{}, fmt.Errorf("unable to promote revision '%s' to stage '%s': %w",
+				stage.PromotedRevision, stage.Name, err)
+		}
+		log.Info(fmt.Sprintf("Revision '%s' promoted to stage '%s'", stage.PromotedRevision, stage.Name))
+	}
+
+	pipeline.Status.Stages = stages
+	pipeline.Status.ObservedGeneration = pipeline.Generation
+	ready := pipelineReadiness(pipeline, members, now)
+	ready.ObservedGeneration = pipeline.Generation
+	apimeta.SetStatusCondition(&pipeline.Status.Conditions, ready)
+
+	if err := r.Status().Patch(ctx, &pipeline, patch, client.FieldOwner(r.ControllerName)); err != nil {
+		return ctrl.Result{}, err
+	}
+	return ctrl.Result{RequeueAfter: requeue}, nil
+}
+
+// members returns the Kustomizations of the stages of the pipeline, in the
+// order of the stages, the Kustomizations that don't exist are nil.
+func (r *PipelineReconciler) members(ctx context.Context,
+	pipeline kustomizev1.Pipeline) ([]*kustomizev1.Kustomization, error) {
+	members := make([]*kustomizev1.Kustomization, len(pipeline.Spec.Stages))
+	for i, stage := range pipeline.Spec.Stages {
+		key := pipelineStageKey(pipeline, stage)
+		if r.NoCrossNamespaceRefs && key.Namespace != pipeline.GetNamespace() {
+			return nil, acl.AccessDeniedError(
+				fmt.Sprintf("can't access '%s/%s', cross-namespace references have been blocked",
+					kustomizev1.KustomizationKind, key))
+		}
+
+		var k kustomizev1.Kustomization
+		if err := r.Get(ctx, key, &k); err != nil {
+			if apierrors.IsNotFound(err) {
+				continue
+			}
+			return nil, fmt.Errorf("unable to get Kustomization '%s': %w", key, err)
+		}
+		members[i] = &k
+	}
+	return members, nil
+}
+
+// requestsForKustomizationChange returns the pipelines that have a stage
+// bound to the given Kustomization.
+func (r *PipelineReconciler) requestsForKustomizationChange(obj client.Object) []reconcile.Request {
+	ctx := context.Background()
+	var list kustomizev1.PipelineList
+	if err := r.List(ctx, &list); err != nil {
+		return nil
+	}
+
+	var reqs []reconcile.Request
+	for _, pipeline := range list.Items {
+		for _, stage := range pipeline.Spec.Stages {
+			if pipelineStageKey(pipeline, stage) == client.ObjectKeyFromObject(obj) {
+				reqs = append(reqs, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&pipeline)})
+				break
+			}
+		}
+	}
+	return reqs
+}
+
+// pipelineStageKey returns the namespaced name of the Kustomization of a stage.
+func pipelineStageKey(pipeline kustomizev1.Pipeline, stage kustomizev1.PipelineStage) types.NamespacedName {
+	key := types.NamespacedName{Namespace: pipeline.GetNamespace(), Name: stage.KustomizationRef.Name}
+	if stage.KustomizationRef.Namespace != "" {
+		key.Namespace = stage.KustomizationRef.Namespace
+	}
+	return key
+}
+
+// promoteRevisions returns the status of the stages of a pipeline, and the
+// duration after which the soak time of the first pending revision elapses.
+// The revision last applied by a stage is promoted to the next stage when
+// the stage has been ready with it for the soak time of the next stage,
+// and when the promotion has been approved if the next stage requires it.
+// A stage that has not been promoted to yet is pinned to the revision
+// it last applied.
+func promoteRevisions(pipeline kustomizev1.Pipeline, members []*kustomizev1.Kustomization,
+	now metav1.Time) ([]kustomizev1.PipelineStageStatus, time.Duration) {
+	previous := make(map[string]kustomizev1.PipelineStageStatus)
+	for _, stage := range pipeline.Status.Stages {
+		previous[stage.Name] = stage
+	}
+	approval := pipeline.GetAnnotations()[kustomizev1.PromotionApprovalAnnotation]
+
+	var requeue time.Duration
+	stages := make([]kustomizev1.PipelineStageStatus, len(pipeline.Spec.Stages))
+	for i, spec := range pipeline.Spec.Stages {
+		prev := previous[spec.Name]
+		stage := kustomizev1.PipelineStageStatus{
+			Name:             spec.Name,
+			PromotedRevision: prev.PromotedRevision,
+		}
+
+		if k := members[i]; k != nil {
+			stage.LastAppliedRevision = k.Status.LastAppliedRevision
+			if stage.LastAppliedRevision != "" && apimeta.IsStatusConditionTrue(k.Status.Conditions, meta.ReadyCondition) {
+				stage.ReadySince = &now
+				if prev.ReadySince != nil && prev.LastAppliedRevision == stage.LastAppliedRevision {
+					stage.ReadySince = prev.ReadySince
+				}
+			}
+			if i > 0 && stage.PromotedRevision == "" {
+				stage.PromotedRevision = k.GetAnnotations()[kustomizev1.PromotedRevisionAnnotation]
+				if stage.PromotedRevision == "" {
+					stage.PromotedRevision = stage.LastAppliedRevision
+				}
+			}
+		}
+
+		stages[i] = stage
+		if i == 0 || pipeline.Spec.Suspend {
+			continue
+		}
+
+		upstream := stages[i-1]
+		if upstream.ReadySince == nil || upstream.LastAppliedRevision == stage.PromotedRevision {
+			continue
+		}
+
+		if spec.SoakTime != nil {
+			if wait := upstream.ReadySince.Add(spec.SoakTime.Duration).Sub(now.Time); wait > 0 {
+				stages[i].PendingRevision = upstream.LastAppliedRevision
+				if requeue == 0 || wait < requeue {
+					requeue = wait
+				}
+				continue
+			}
+		}
+
+		if spec.RequireApproval && approval != fmt.Sprintf("%s@%s", spec.Name, upstream.LastAppliedRevision) {
+			stages[i].PendingRevision = upstream.LastAppliedRevision
+			continue
+		}
+
+		stages[i].PromotedRevision = upstream.LastAppliedRevision
+	}
+
+	return stages, requeue
+}
+
+// pipelineReadiness returns the Ready condition of a pipeline, the pipeline
+// is not ready when the Kustomization of a stage is missing or failed,
+// pending when a promotion is waiting for approval, progressing while
+// a revision soaks or is being applied, and ready otherwise.
+func pipelineReadiness(pipeline kustomizev1.Pipeline, members []*kustomizev1.Kustomization,
+	now metav1.Time) metav1.Condition {
+	condition := metav1.Condition{
+		Type:   meta.ReadyCondition,
+		Status: metav1.ConditionUnknown,
+		Reason: meta.ProgressingReason,
+	}
+
+	for i, stage := range pipeline.Status.Stages {
+		k := members[i]
+		if k == nil {
+			condition.Status = metav1.ConditionFalse
+			condition.Reason = kustomizev1.KustomizationNotFoundReason
+			condition.Message = fmt.Sprintf("Kustomization of stage '%s' not found", stage.Name)
+			return condition
+		}
+		if ready := apimeta.FindStatusCondition(k.Status.Conditions, meta.ReadyCondition); ready != nil &&
+			ready.Status == metav1.ConditionFalse {
+			condition.Status = metav1.ConditionFalse
+			condition.Reason = ready.Reason
+			condition.Message = fmt.Sprintf("Stage '%s' is not ready: %s", stage.Name, ready.Message)
+			return condition
+		}
+	}
+
+	for i, stage := range pipeline.Status.Stages {
+		switch {
+		case stage.PendingRevision != "":
+			spec := pipeline.Spec.Stages[i]
+			soaked := spec.SoakTime == nil ||
+				!pipeline.Status.Stages[i-1].ReadySince.Add(spec.SoakTime.Duration).After(now.Time)
+			if soaked {
+				condition.Reason = kustomizev1.PromotionPendingReason
+				condition.Message = fmt.Sprintf("Revision '%s' is waiting for approval to be promoted to stage '%s'",
+					stage.PendingRevision, stage.Name)
+			} else {
+				condition.Message = fmt.Sprintf("Revision '%s' is soaking in stage '%s' before its promotion to stage '%s'",
+					stage.PendingRevision, pipeline.Status.Stages[i-1].Name, stage.Name)
+			}
+			return condition
+		case stage.LastAppliedRevision == "" || (i > 0 && stage.LastAppliedRevision != stage.PromotedRevision):
+			condition.Message = fmt.Sprintf("Stage '%s' is reconciling", stage.Name)
+			return condition
+		}
+	}
+
+	if len(pipeline.Status.Stages) == 0 {
+		condition.Message = "No stages defined"
+		return condition
+	}
+	last := pipeline.Status.Stages[len(pipeline.Status.Stages)-1]
+	condition.Status = metav1.ConditionTrue
+	condition.Reason = kustomizev1.ReconciliationSucceededReason
+	condition.Message = fmt.Sprintf("Revision '%s' promoted to all stages", last.LastAppliedRevision)
+	return condition
+}
@@ -0,0 +1,181 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/fluxcd/pkg/apis/meta"
+
+	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta2"
+)
+
+func newTestPipeline() kustomizev1.Pipeline {
+	return kustomizev1.Pipeline{
+		ObjectMeta: metav1.ObjectMeta{Name: "podinfo", Namespace: "apps", Generation: 1},
+		Spec: kustomizev1.PipelineSpec{
+			Stages: []kustomizev1.PipelineStage{
+				{Name: "dev", KustomizationRef: meta.NamespacedObjectReference{Name: "podinfo-dev"}},
+				{
+					Name:             "staging",
+					KustomizationRef: meta.NamespacedObjectReference{Name: "podinfo-staging"},
+					SoakTime:         &metav1.Duration{Duration: time.Hour},
+				},
+				{
+					Name:             "prod",
+					KustomizationRef: meta.NamespacedObjectReference{Name: "podinfo-prod"},
+					RequireApproval:  true,
+				},
+			},
+		},
+	}
+}
+
+func TestPromoteRevisions(t *testing.T) {
+	g := NewWithT(t)
+
+	pipeline := newTestPipeline()
+	now := metav1.Now()
+	members := []*kustomizev1.Kustomization{
+		newGroupMember("apps", "podinfo-dev", metav1.ConditionTrue, kustomizev1.ReconciliationSucceededReason, "main/2"),
+		newGroupMember("apps", "podinfo-staging", metav1.ConditionTrue, kustomizev1.ReconciliationSucceededReason, "main/1"),
+		newGroupMember("apps", "podinfo-prod", metav1.ConditionTrue, kustomizev1.ReconciliationSucceededReason, "main/1"),
+	}
+
+	// the stages are pinned to their last applied revision,
+	// and the new revision soaks in dev
+	stages, requeue := promoteRevisions(pipeline, members, now)
+	g.Expect(stages[0].PromotedRevision).To(BeEmpty())
+	g.Expect(stages[0].ReadySince).To(Equal(&now))
+	g.Expect(stages[1].PromotedRevision).To(Equal("main/1"))
+	g.Expect(stages[1].PendingRevision).To(Equal("main/2"))
+	g.Expect(stages[2].PromotedRevision).To(Equal("main/1"))
+	g.Expect(stages[2].PendingRevision).To(BeEmpty())
+	g.Expect(requeue).To(Equal(time.Hour))
+	g.Expect(pipelineReadiness(kustomizev1.Pipeline{Spec: pipeline.Spec,
+		Status: kustomizev1.PipelineStatus{Stages: stages}}, members, now).Message).To(ContainSubstring("soaking in stage 'dev'"))
+
+	// the revision is promoted to staging once the soak time has elapsed
+	pipeline.Status.Stages = stages
+	later := metav1.NewTime(now.Add(time.Hour))
+	stages, requeue = promoteRevisions(pipeline, members, later)
+	g.Expect(stages[0].ReadySince).To(Equal(&now))
+	g.Expect(stages[1].PromotedRevision).To(Equal("main/2"))
+	g.Expect(stages[1].PendingRevision).To(BeEmpty())
+	g.Expect(requeue).To(BeZero())
+
+	// the revision waits for approval before its promotion to prod
+	pipeline.Status.Stages = stages
+	members[1].Status.LastAppliedRevision = "main/2"
+	stages, _ = promoteRevisions(pipeline, members, later)
+	g.Expect(stages[1].ReadySince).To(Equal(&later))
+	g.Expect(stages[2].PromotedRevision).To(Equal("main/1"))
+	g.Expect(stages[2].PendingRevision).To(Equal("main/2"))
+	ready := pipelineReadiness(kustomizev1.Pipeline{Spec: pipeline.Spec,
+		Status: kustomizev1.PipelineStatus{Stages: stages}}, members, later)
+	g.Expect(ready.Reason).To(Equal(kustomizev1.PromotionPendingReason))
+
+	pipeline.Status.Stages = stages
+	pipeline.SetAnnotations(map[string]string{kustomizev1.PromotionApprovalAnnotation: "prod@main/1"})
+	stages, _ = promoteRevisions(pipeline, members, later)
+	g.Expect(stages[2].PromotedRevision).To(Equal("main/1"))
+
+	pipeline.SetAnnotations(map[string]string{kustomizev1.PromotionApprovalAnnotation: "prod@main/2"})
+	stages, _ = promoteRevisions(pipeline, members, later)
+	g.Expect(stages[2].PromotedRevision).To(Equal("main/2"))
+	g.Expect(stages[2].PendingRevision).To(BeEmpty())
+
+	// the promotions stop while the pipeline is suspended
+	pipeline.Status.Stages = nil
+	pipeline.Spec.Suspend = true
+	stages, _ = promoteRevisions(pipeline, members, later)
+	g.Expect(stages[1].PromotedRevision).To(Equal("main/2"))
+	g.Expect(stages[2].PromotedRevision).To(Equal("main/1"))
+	g.Expect(stages[2].PendingRevision).To(BeEmpty())
+}
+
+func TestPipelineReadiness(t *testing.T) {
+	g := NewWithT(t)
+
+	pipeline := newTestPipeline()
+	now := metav1.Now()
+	members := []*kustomizev1.Kustomization{
+		newGroupMember("apps", "podinfo-dev", metav1.ConditionTrue, kustomizev1.ReconciliationSucceededReason, "main/1"),
+		newGroupMember("apps", "podinfo-staging", metav1.ConditionFalse, kustomizev1.HealthCheckFailedReason, "main/1"),
+		nil,
+	}
+	pipeline.Status.Stages, _ = promoteRevisions(pipeline, members, now)
+
+	ready := pipelineReadiness(pipeline, members, now)
+	g.Expect(ready.Status).To(Equal(metav1.ConditionFalse))
+	g.Expect(ready.Reason).To(Equal(kustomizev1.HealthCheckFailedReason))
+	g.Expect(ready.Message).To(ContainSubstring("Stage 'staging' is not ready"))
+
+	members[1] = newGroupMember("apps", "podinfo-staging", metav1.ConditionTrue, kustomizev1.ReconciliationSucceededReason, "main/1")
+	ready = pipelineReadiness(pipeline, members, now)
+	g.Expect(ready.Reason).To(Equal(kustomizev1.KustomizationNotFoundReason))
+
+	members[2] = newGroupMember("apps", "podinfo-prod", metav1.ConditionTrue, kustomizev1.ReconciliationSucceededReason, "main/1")
+	pipeline.Status.Stages, _ = promoteRevisions(pipeline, members, now)
+	ready = pipelineReadiness(pipeline, members, now)
+	g.Expect(ready.Status).To(Equal(metav1.ConditionTrue))
+	g.Expect(ready.Message).To(Equal("Revision 'main/1' promoted to all stages"))
+}
+
+func TestPipelineReconciler_Reconcile(t *testing.T) {
+	g := NewWithT(t)
+
+	scheme := runtime.NewScheme()
+	g.Expect(kustomizev1.AddToScheme(scheme)).To(Succeed())
+
+	pipeline := newTestPipeline()
+	pipeline.Spec.Stages[1].SoakTime = nil
+	kubeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+		&pipeline,
+		newGroupMember("apps", "podinfo-dev", metav1.ConditionTrue, kustomizev1.ReconciliationSucceededReason, "main/2"),
+		newGroupMember("apps", "podinfo-staging", metav1.ConditionTrue, kustomizev1.ReconciliationSucceededReason, "main/1"),
+		newGroupMember("apps", "podinfo-prod", metav1.ConditionTrue, kustomizev1.ReconciliationSucceededReason, "main/1"),
+	).Build()
+	r := &PipelineReconciler{Client: kubeClient, ControllerName: "kustomize-controller"}
+
+	ctx := context.TODO()
+	key := client.ObjectKeyFromObject(&pipeline)
+	_, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+	g.Expect(err).NotTo(HaveOccurred())
+
+	var staging, prod kustomizev1.Kustomization
+	g.Expect(kubeClient.Get(ctx, client.ObjectKey{Namespace: "apps", Name: "podinfo-staging"}, &staging)).To(Succeed())
+	g.Expect(staging.GetAnnotations()).To(HaveKeyWithValue(kustomizev1.PromotedRevisionAnnotation, "main/2"))
+	g.Expect(kubeClient.Get(ctx, client.ObjectKey{Namespace: "apps", Name: "podinfo-prod"}, &prod)).To(Succeed())
+	g.Expect(prod.GetAnnotations()).To(HaveKeyWithValue(kustomizev1.PromotedRevisionAnnotation, "main/1"))
+
+	g.Expect(kubeClient.Get(ctx, key, &pipeline)).To(Succeed())
+	g.Expect(pipeline.Status.Stages).To(HaveLen(3))
+	ready := apimeta.FindStatusCondition(pipeline.Status.Conditions, meta.ReadyCondition)
+	g.Expect(ready.Status).To(Equal(metav1.ConditionUnknown))
+	g.Expect(ready.Message).To(Equal("Stage 'staging' is reconciling"))
+}
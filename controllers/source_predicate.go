@@ -17,10 +17,15 @@ limitations under the License.
 package controllers
 
 import (
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"sigs.k8s.io/controller-runtime/pkg/event"
 	"sigs.k8s.io/controller-runtime/pkg/predicate"
 
+	"github.com/fluxcd/pkg/apis/meta"
 	sourcev1 "github.com/fluxcd/source-controller/api/v1beta2"
+
+	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta2"
 )
 
 type SourceRevisionChangePredicate struct {
@@ -53,3 +58,94 @@ func (SourceRevisionChangePredicate) Update(e event.UpdateEvent) bool {
 
 	return false
 }
+
+// LatestImageChangePredicate triggers an update event when the
+// status.latestImage of an ImagePolicy changes.
+type LatestImageChangePredicate struct {
+	predicate.Funcs
+}
+
+func (LatestImageChangePredicate) Update(e event.UpdateEvent) bool {
+	if e.ObjectOld == nil || e.ObjectNew == nil {
+		return false
+	}
+
+	oldPolicy, ok := e.ObjectOld.(*unstructured.Unstructured)
+	if !ok {
+		return false
+	}
+
+	newPolicy, ok := e.ObjectNew.(*unstructured.Unstructured)
+	if !ok {
+		return false
+	}
+
+	oldImage, _, _ := unstructured.NestedString(oldPolicy.Object, "status", "latestImage")
+	newImage, _, _ := unstructured.NestedString(newPolicy.Object, "status", "latestImage")
+	return newImage != "" && oldImage != newImage
+}
+
+// DependencyReadyPredicate triggers an update event when a Kustomization
+// becomes ready for its current generation, or applies a new revision while ready.
+type DependencyReadyPredicate struct {
+	predicate.Funcs
+}
+
+func (DependencyReadyPredicate) Update(e event.UpdateEvent) bool {
+	if e.ObjectOld == nil || e.ObjectNew == nil {
+		return false
+	}
+
+	oldKs, ok := e.ObjectOld.(*kustomizev1.Kustomization)
+	if !ok {
+		return false
+	}
+
+	newKs, ok := e.ObjectNew.(*kustomizev1.Kustomization)
+	if !ok {
+		return false
+	}
+
+	if newKs.Generation != newKs.Status.ObservedGeneration ||
+		!apimeta.IsStatusConditionTrue(newKs.Status.Conditions, meta.ReadyCondition) {
+		return false
+	}
+
+	return !apimeta.IsStatusConditionTrue(oldKs.Status.Conditions, meta.ReadyCondition) ||
+		oldKs.Status.ObservedGeneration != newKs.Status.ObservedGeneration ||
+		oldKs.Status.LastAppliedRevision != newKs.Status.LastAppliedRevision
+}
+
+// KustomizationStatusChangePredicate triggers an update event when the Ready
+// condition or the last applied or attempted revision of a Kustomization changes.
+type KustomizationStatusChangePredicate struct {
+	predicate.Funcs
+}
+
+func (KustomizationStatusChangePredicate) Update(e event.UpdateEvent) bool {
+	if e.ObjectOld == nil || e.ObjectNew == nil {
+		return false
+	}
+
+	oldKs, ok := e.ObjectOld.(*kustomizev1.Kustomization)
+	if !ok {
+		return false
+	}
+
+	newKs, ok := e.ObjectNew.(*kustomizev1.Kustomization)
+	if !ok {
+		return false
+	}
+
+	if oldKs.Status.LastAppliedRevision != newKs.Status.LastAppliedRevision ||
+		oldKs.Status.LastAttemptedRevision != newKs.Status.LastAttemptedRevision {
+		return true
+	}
+
+	oldReady := apimeta.FindStatusCondition(oldKs.Status.Conditions, meta.ReadyCondition)
+	newReady := apimeta.FindStatusCondition(newKs.Status.Conditions, meta.ReadyCondition)
+	if oldReady == nil || newReady == nil {
+		return oldReady != newReady
+	}
+	return oldReady.Status != newReady.Status || oldReady.Reason != newReady.Reason
+}
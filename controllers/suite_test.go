@@ -156,6 +156,11 @@ func runInContext(registerControllers func(*testenv.Environment), run func() err
 }
 
 func TestMain(m *testing.M) {
+	// run the sandboxed build when the test binary is started by sandboxBuildKustomization
+	if len(os.Args) > 1 && os.Args[1] == SandboxBuildCommand {
+		os.Exit(RunSandboxBuild(os.Args[2:]))
+	}
+
 	code := 0
 
 	runInContext(func(testEnv *testenv.Environment) {
@@ -0,0 +1,335 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/cli-utils/pkg/object"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	"github.com/fluxcd/pkg/apis/meta"
+
+	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta2"
+)
+
+// +kubebuilder:rbac:groups=kustomize.toolkit.fluxcd.io,resources=tenants,verbs=get;list;watch
+// +kubebuilder:rbac:groups=kustomize.toolkit.fluxcd.io,resources=tenants/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=kustomize.toolkit.fluxcd.io,resources=tenants/finalizers,verbs=get;create;update;patch;delete
+// +kubebuilder:rbac:groups="",resources=namespaces;serviceaccounts,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=rbac.authorization.k8s.io,resources=rolebindings,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=rbac.authorization.k8s.io,resources=clusterroles,verbs=bind
+
+// TenantReconciler generates the namespace, the service account, the role binding
+// and the Kustomization of a Tenant, and prunes the ones it no longer describes.
+type TenantReconciler struct {
+	client.Client
+	Scheme         *runtime.Scheme
+	ControllerName string
+}
+
+func (r *TenantReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&kustomizev1.Tenant{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Owns(&corev1.Namespace{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Owns(&corev1.ServiceAccount{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Owns(&rbacv1.RoleBinding{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Owns(&kustomizev1.Kustomization{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Complete(r)
+}
+
+func (r *TenantReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := ctrl.LoggerFrom(ctx)
+
+	var tenant kustomizev1.Tenant
+	if err := r.Get(ctx, req.NamespacedName, &tenant); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	// the generated objects are garbage collected through their owner reference,
+	// the retained namespace has none and is left in place
+	if !tenant.DeletionTimestamp.IsZero() {
+		return ctrl.Result{}, nil
+	}
+
+	patch := client.MergeFrom(tenant.DeepCopy())
+	inventory, reconcileErr := r.reconcileTenant(ctx, &tenant)
+
+	tenant.Status.ObservedGeneration = tenant.Generation
+	condition := metav1.Condition{
+		Type:               meta.ReadyCondition,
+		Status:             metav1.ConditionTrue,
+		Reason:             kustomizev1.ReconciliationSucceededReason,
+		Message:            fmt.Sprintf("Tenant namespace '%s' is ready", tenant.GetNamespaceName()),
+		ObservedGeneration: tenant.Generation,
+	}
+	if reconcileErr != nil {
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = kustomizev1.ReconciliationFailedReason
+		condition.Message = reconcileErr.Error()
+	} else {
+		tenant.Status.Inventory = inventory
+	}
+	apimeta.SetStatusCondition(&tenant.Status.Conditions, condition)
+
+	if err := r.Status().Patch(ctx, &tenant, patch, client.FieldOwner(r.ControllerName)); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if reconcileErr != nil {
+		log.Error(reconcileErr, "failed to reconcile the tenant")
+		return ctrl.Result{}, reconcileErr
+	}
+
+	log.Info(condition.Message)
+	return ctrl.Result{}, nil
+}
+
+// reconcileTenant creates or updates the objects of the tenant, deletes the
+// objects recorded in the previous inventory that are no longer generated,
+// and returns the new inventory.
+func (r *TenantReconciler) reconcileTenant(ctx context.Context, tenant *kustomizev1.Tenant) (*kustomizev1.ResourceInventory, error) {
+	inventory := NewInventory()
+	for _, obj := range tenantObjects(*tenant) {
+		gvk, err := apiutil.GVKForObject(obj, r.Scheme)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := r.apply(ctx, tenant, obj); err != nil {
+			return nil, fmt.Errorf("failed to reconcile %s '%s': %w", gvk.Kind, obj.GetName(), err)
+		}
+
+		inventory.Entries = append(inventory.Entries, kustomizev1.ResourceRef{
+			ID: object.ObjMetadata{
+				Namespace: obj.GetNamespace(),
+				Name:      obj.GetName(),
+				GroupKind: gvk.GroupKind(),
+			}.String(),
+			Version: gvk.Version,
+			Retain:  retainedByTenant(*tenant, gvk.Kind),
+		})
+	}
+
+	if tenant.Status.Inventory != nil {
+		stale, err := DiffInventory(tenant.Status.Inventory, inventory)
+		if err != nil {
+			return nil, err
+		}
+
+		retained := make(map[string]bool)
+		for _, entry := range tenant.Status.Inventory.Entries {
+			retained[entry.ID] = entry.Retain
+		}
+
+		// delete in the reverse apply order, the Kustomization before its namespace
+		for i := len(stale) - 1; i >= 0; i-- {
+			if retained[object.UnstructuredToObjMetadata(stale[i]).String()] || retainedByTenant(*tenant, stale[i].GetKind()) {
+				if err := r.orphan(ctx, tenant, stale[i]); err != nil {
+					return nil, fmt.Errorf("failed to orphan %s '%s': %w", stale[i].GetKind(), stale[i].GetName(), err)
+				}
+				continue
+			}
+			if err := r.prune(ctx, tenant, stale[i]); err != nil {
+				return nil, fmt.Errorf("failed to delete %s '%s': %w", stale[i].GetKind(), stale[i].GetName(), err)
+			}
+		}
+	}
+
+	return inventory, nil
+}
+
+// apply creates or updates the given object, refusing to take over
+// objects that were not generated for the tenant. The retained objects
+// are not owned by the tenant, so they are not garbage collected with it.
+func (r *TenantReconciler) apply(ctx context.Context, tenant *kustomizev1.Tenant, desired client.Object) error {
+	gvk, err := apiutil.GVKForObject(desired, r.Scheme)
+	if err != nil {
+		return err
+	}
+	retain := retainedByTenant(*tenant, gvk.Kind)
+	newObj, err := r.Scheme.New(gvk)
+	if err != nil {
+		return err
+	}
+	obj := newObj.(client.Object)
+	obj.SetName(desired.GetName())
+	obj.SetNamespace(desired.GetNamespace())
+
+	_, err = controllerutil.CreateOrUpdate(ctx, r.Client, obj, func() error {
+		if obj.GetResourceVersion() != "" && !managedByTenant(obj, tenant) {
+			return fmt.Errorf("already exists and is not managed by tenant '%s'", tenant.GetName())
+		}
+
+		obj.SetLabels(mergeStringMaps(obj.GetLabels(), desired.GetLabels()))
+		obj.SetAnnotations(mergeStringMaps(obj.GetAnnotations(), desired.GetAnnotations()))
+		switch o := obj.(type) {
+		case *rbacv1.RoleBinding:
+			o.RoleRef = desired.(*rbacv1.RoleBinding).RoleRef
+			o.Subjects = desired.(*rbacv1.RoleBinding).Subjects
+		case *kustomizev1.Kustomization:
+			o.Spec = desired.(*kustomizev1.Kustomization).Spec
+		}
+		if retain {
+			obj.SetOwnerReferences(withoutOwner(obj.GetOwnerReferences(), tenant))
+			return nil
+		}
+		return controllerutil.SetControllerReference(tenant, obj, r.Scheme)
+	})
+	return err
+}
+
+// orphan removes the owner reference of the tenant from the given object,
+// so that it's kept when the tenant is deleted.
+func (r *TenantReconciler) orphan(ctx context.Context, tenant *kustomizev1.Tenant, obj *unstructured.Unstructured) error {
+	existing := &unstructured.Unstructured{}
+	existing.SetGroupVersionKind(obj.GroupVersionKind())
+	if err := r.Get(ctx, client.ObjectKeyFromObject(obj), existing); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+
+	refs := withoutOwner(existing.GetOwnerReferences(), tenant)
+	if len(refs) == len(existing.GetOwnerReferences()) {
+		return nil
+	}
+
+	patch := client.MergeFrom(existing.DeepCopy())
+	existing.SetOwnerReferences(refs)
+	return r.Patch(ctx, existing, patch)
+}
+
+// prune deletes the given object if it's controlled by the tenant.
+func (r *TenantReconciler) prune(ctx context.Context, tenant *kustomizev1.Tenant, obj *unstructured.Unstructured) error {
+	existing := &unstructured.Unstructured{}
+	existing.SetGroupVersionKind(obj.GroupVersionKind())
+	if err := r.Get(ctx, client.ObjectKeyFromObject(obj), existing); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+
+	if !metav1.IsControlledBy(existing, tenant) {
+		return nil
+	}
+
+	if err := r.Delete(ctx, existing); err != nil && !apierrors.IsNotFound(err) {
+		return err
+	}
+	return nil
+}
+
+// retainedByTenant returns true if the objects of the given kind are orphaned
+// instead of being deleted, i.e. the namespace when spec.retainNamespace is set.
+func retainedByTenant(tenant kustomizev1.Tenant, kind string) bool {
+	return kind == "Namespace" && tenant.Spec.RetainNamespace
+}
+
+// managedByTenant returns true if the object is controlled by the tenant, or is
+// a namespace labeled with the tenant name and not controlled by another owner,
+// i.e. a namespace retained by the tenant.
+func managedByTenant(obj client.Object, tenant *kustomizev1.Tenant) bool {
+	if metav1.IsControlledBy(obj, tenant) {
+		return true
+	}
+	_, isNamespace := obj.(*corev1.Namespace)
+	return isNamespace &&
+		obj.GetLabels()[kustomizev1.TenantLabel] == tenant.GetName() &&
+		metav1.GetControllerOf(obj) == nil
+}
+
+// withoutOwner returns the owner references that don't point to the tenant.
+func withoutOwner(refs []metav1.OwnerReference, tenant *kustomizev1.Tenant) []metav1.OwnerReference {
+	var result []metav1.OwnerReference
+	for _, ref := range refs {
+		if ref.UID != tenant.GetUID() {
+			result = append(result, ref)
+		}
+	}
+	return result
+}
+
+// tenantObjects returns the namespace, the service account, the role binding
+// and the Kustomization generated for the tenant, in apply order.
+func tenantObjects(tenant kustomizev1.Tenant) []client.Object {
+	namespace := tenant.GetNamespaceName()
+	serviceAccount := tenant.GetServiceAccountName()
+	clusterRole := tenant.GetClusterRole()
+	labels := func() map[string]string {
+		return map[string]string{kustomizev1.TenantLabel: tenant.GetName()}
+	}
+
+	spec := *tenant.Spec.Kustomization.Spec.DeepCopy()
+	spec.ServiceAccountName = serviceAccount
+	if spec.TargetNamespace == "" {
+		spec.TargetNamespace = namespace
+	}
+
+	return []client.Object{
+		&corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:   namespace,
+				Labels: labels(),
+			},
+		},
+		&corev1.ServiceAccount{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      serviceAccount,
+				Namespace: namespace,
+				Labels:    labels(),
+			},
+		},
+		&rbacv1.RoleBinding{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      fmt.Sprintf("%s-%s", serviceAccount, clusterRole),
+				Namespace: namespace,
+				Labels:    labels(),
+			},
+			RoleRef: rbacv1.RoleRef{
+				APIGroup: rbacv1.GroupName,
+				Kind:     "ClusterRole",
+				Name:     clusterRole,
+			},
+			Subjects: []rbacv1.Subject{
+				{
+					Kind:      rbacv1.ServiceAccountKind,
+					Name:      serviceAccount,
+					Namespace: namespace,
+				},
+			},
+		},
+		&kustomizev1.Kustomization{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        tenant.GetName(),
+				Namespace:   namespace,
+				Labels:      mergeStringMaps(mergeStringMaps(nil, tenant.Spec.Kustomization.Labels), labels()),
+				Annotations: mergeStringMaps(nil, tenant.Spec.Kustomization.Annotations),
+			},
+			Spec: spec,
+		},
+	}
+}
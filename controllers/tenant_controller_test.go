@@ -0,0 +1,207 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/fluxcd/pkg/apis/meta"
+
+	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta2"
+)
+
+func newTestTenant() *kustomizev1.Tenant {
+	return &kustomizev1.Tenant{
+		ObjectMeta: metav1.ObjectMeta{Name: "team-a", Generation: 1},
+		Spec: kustomizev1.TenantSpec{
+			Kustomization: kustomizev1.KustomizationTemplate{
+				Labels: map[string]string{"team": "a"},
+				Spec: kustomizev1.KustomizationSpec{
+					Interval: metav1.Duration{Duration: 10 * time.Minute},
+					Path:     "./tenants/team-a",
+					Prune:    true,
+					SourceRef: kustomizev1.CrossNamespaceSourceReference{
+						Kind:      "GitRepository",
+						Name:      "tenants",
+						Namespace: "flux-system",
+					},
+				},
+			},
+		},
+	}
+}
+
+func TestTenantObjects(t *testing.T) {
+	g := NewWithT(t)
+
+	tenant := newTestTenant()
+	objects := tenantObjects(*tenant)
+	g.Expect(objects).To(HaveLen(4))
+
+	ns := objects[0].(*corev1.Namespace)
+	g.Expect(ns.Name).To(Equal("team-a"))
+	g.Expect(ns.Labels).To(HaveKeyWithValue(kustomizev1.TenantLabel, "team-a"))
+
+	sa := objects[1].(*corev1.ServiceAccount)
+	g.Expect(sa.Namespace).To(Equal("team-a"))
+	g.Expect(sa.Name).To(Equal("team-a"))
+
+	rb := objects[2].(*rbacv1.RoleBinding)
+	g.Expect(rb.Name).To(Equal("team-a-admin"))
+	g.Expect(rb.RoleRef.Name).To(Equal("admin"))
+	g.Expect(rb.Subjects).To(ConsistOf(rbacv1.Subject{Kind: "ServiceAccount", Name: "team-a", Namespace: "team-a"}))
+
+	ks := objects[3].(*kustomizev1.Kustomization)
+	g.Expect(ks.Namespace).To(Equal("team-a"))
+	g.Expect(ks.Labels).To(Equal(map[string]string{"team": "a", kustomizev1.TenantLabel: "team-a"}))
+	g.Expect(ks.Spec.ServiceAccountName).To(Equal("team-a"))
+	g.Expect(ks.Spec.TargetNamespace).To(Equal("team-a"))
+	g.Expect(ks.Spec.SourceRef.Namespace).To(Equal("flux-system"))
+
+	tenant.Spec.Namespace = "apps-a"
+	tenant.Spec.ServiceAccountName = "reconciler"
+	tenant.Spec.ClusterRole = "edit"
+	tenant.Spec.Kustomization.Spec.TargetNamespace = "apps-a-dev"
+	objects = tenantObjects(*tenant)
+	g.Expect(objects[2].GetName()).To(Equal("reconciler-edit"))
+	g.Expect(objects[3].GetNamespace()).To(Equal("apps-a"))
+	g.Expect(objects[3].(*kustomizev1.Kustomization).Spec.TargetNamespace).To(Equal("apps-a-dev"))
+	g.Expect(objects[3].(*kustomizev1.Kustomization).Spec.ServiceAccountName).To(Equal("reconciler"))
+}
+
+func TestTenantReconciler_Reconcile(t *testing.T) {
+	g := NewWithT(t)
+
+	scheme := runtime.NewScheme()
+	g.Expect(corev1.AddToScheme(scheme)).To(Succeed())
+	g.Expect(rbacv1.AddToScheme(scheme)).To(Succeed())
+	g.Expect(kustomizev1.AddToScheme(scheme)).To(Succeed())
+
+	tenant := newTestTenant()
+	kubeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(tenant).Build()
+	r := &TenantReconciler{Client: kubeClient, Scheme: scheme, ControllerName: "kustomize-controller"}
+
+	ctx := context.TODO()
+	key := client.ObjectKeyFromObject(tenant)
+	_, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+	g.Expect(err).NotTo(HaveOccurred())
+
+	var ks kustomizev1.Kustomization
+	g.Expect(kubeClient.Get(ctx, types.NamespacedName{Namespace: "team-a", Name: "team-a"}, &ks)).To(Succeed())
+	g.Expect(metav1.IsControlledBy(&ks, tenant)).To(BeTrue())
+
+	g.Expect(kubeClient.Get(ctx, key, tenant)).To(Succeed())
+	g.Expect(tenant.Status.Inventory.Entries).To(HaveLen(4))
+	g.Expect(apimeta.IsStatusConditionTrue(tenant.Status.Conditions, meta.ReadyCondition)).To(BeTrue())
+
+	// changing the cluster role replaces the role binding
+	tenant.Spec.ClusterRole = "edit"
+	g.Expect(kubeClient.Update(ctx, tenant)).To(Succeed())
+	_, err = r.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+	g.Expect(err).NotTo(HaveOccurred())
+
+	var rb rbacv1.RoleBinding
+	g.Expect(kubeClient.Get(ctx, types.NamespacedName{Namespace: "team-a", Name: "team-a-edit"}, &rb)).To(Succeed())
+	err = kubeClient.Get(ctx, types.NamespacedName{Namespace: "team-a", Name: "team-a-admin"}, &rb)
+	g.Expect(apierrors.IsNotFound(err)).To(BeTrue())
+
+	// objects not generated for the tenant are not taken over
+	g.Expect(kubeClient.Create(ctx, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "team-b"}})).To(Succeed())
+	g.Expect(kubeClient.Get(ctx, key, tenant)).To(Succeed())
+	tenant.Spec.Namespace = "team-b"
+	g.Expect(kubeClient.Update(ctx, tenant)).To(Succeed())
+	_, err = r.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+	g.Expect(err).To(MatchError(ContainSubstring("not managed by tenant 'team-a'")))
+
+	g.Expect(kubeClient.Get(ctx, key, tenant)).To(Succeed())
+	g.Expect(apimeta.IsStatusConditionFalse(tenant.Status.Conditions, meta.ReadyCondition)).To(BeTrue())
+	g.Expect(tenant.Status.Inventory.Entries).To(HaveLen(4))
+}
+
+func TestTenantReconciler_RetainNamespace(t *testing.T) {
+	g := NewWithT(t)
+
+	scheme := runtime.NewScheme()
+	g.Expect(corev1.AddToScheme(scheme)).To(Succeed())
+	g.Expect(rbacv1.AddToScheme(scheme)).To(Succeed())
+	g.Expect(kustomizev1.AddToScheme(scheme)).To(Succeed())
+
+	tenant := newTestTenant()
+	tenant.UID = "tenant-uid"
+	kubeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(tenant).Build()
+	r := &TenantReconciler{Client: kubeClient, Scheme: scheme, ControllerName: "kustomize-controller"}
+
+	ctx := context.TODO()
+	key := client.ObjectKeyFromObject(tenant)
+	_, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+	g.Expect(err).NotTo(HaveOccurred())
+
+	var ns corev1.Namespace
+	g.Expect(kubeClient.Get(ctx, types.NamespacedName{Name: "team-a"}, &ns)).To(Succeed())
+	g.Expect(metav1.IsControlledBy(&ns, tenant)).To(BeTrue())
+
+	// retaining the namespace removes the owner reference and flags the inventory entry
+	g.Expect(kubeClient.Get(ctx, key, tenant)).To(Succeed())
+	tenant.Spec.RetainNamespace = true
+	g.Expect(kubeClient.Update(ctx, tenant)).To(Succeed())
+	_, err = r.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+	g.Expect(err).NotTo(HaveOccurred())
+
+	g.Expect(kubeClient.Get(ctx, types.NamespacedName{Name: "team-a"}, &ns)).To(Succeed())
+	g.Expect(ns.OwnerReferences).To(BeEmpty())
+	g.Expect(ns.Labels).To(HaveKeyWithValue(kustomizev1.TenantLabel, "team-a"))
+
+	g.Expect(kubeClient.Get(ctx, key, tenant)).To(Succeed())
+	g.Expect(tenant.Status.Inventory.Entries).To(ContainElement(kustomizev1.ResourceRef{ID: "_team-a__Namespace", Version: "v1", Retain: true}))
+	g.Expect(tenant.Status.Inventory.Entries).To(ContainElement(kustomizev1.ResourceRef{ID: "team-a_team-a__ServiceAccount", Version: "v1"}))
+
+	// the retained namespace is still managed by the tenant
+	_, err = r.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+	g.Expect(err).NotTo(HaveOccurred())
+
+	// renaming the namespace orphans the retained one
+	g.Expect(kubeClient.Get(ctx, key, tenant)).To(Succeed())
+	tenant.Spec.Namespace = "team-a-v2"
+	g.Expect(kubeClient.Update(ctx, tenant)).To(Succeed())
+	_, err = r.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(kubeClient.Get(ctx, types.NamespacedName{Name: "team-a"}, &ns)).To(Succeed())
+	g.Expect(kubeClient.Get(ctx, types.NamespacedName{Name: "team-a-v2"}, &ns)).To(Succeed())
+
+	// a namespace of another tenant is not taken over
+	other := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+		Name:   "team-b",
+		Labels: map[string]string{kustomizev1.TenantLabel: "team-b"},
+	}}
+	g.Expect(managedByTenant(other, tenant)).To(BeFalse())
+	other.Labels[kustomizev1.TenantLabel] = "team-a"
+	g.Expect(managedByTenant(other, tenant)).To(BeTrue())
+}
@@ -0,0 +1,100 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package eventsinks
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/fluxcd/pkg/runtime/events"
+)
+
+// cloudEventsContentType is the content type of the CloudEvents in structured mode.
+const cloudEventsContentType = "application/cloudevents+json"
+
+// cloudEvent is a CloudEvent v1.0 in the JSON structured content mode,
+// the data is the event in the notification-controller format.
+type cloudEvent struct {
+	SpecVersion     string       `json:"specversion"`
+	ID              string       `json:"id"`
+	Source          string       `json:"source"`
+	Type            string       `json:"type"`
+	Subject         string       `json:"subject"`
+	Time            string       `json:"time"`
+	DataContentType string       `json:"datacontenttype"`
+	Data            events.Event `json:"data"`
+}
+
+// CloudEventsSink posts the events as CloudEvents to an HTTP endpoint.
+type CloudEventsSink struct {
+	address *url.URL
+	client  *http.Client
+}
+
+// NewCloudEventsSink returns a CloudEventsSink posting to the HTTP(S) address.
+func NewCloudEventsSink(address *url.URL) (*CloudEventsSink, error) {
+	if err := checkHTTPAddress(CloudEventsSinkType, address); err != nil {
+		return nil, err
+	}
+	return &CloudEventsSink{address: address, client: &http.Client{}}, nil
+}
+
+// Send posts the event as a CloudEvent in the structured content mode.
+func (s *CloudEventsSink) Send(ctx context.Context, event events.Event) error {
+	ce, err := newCloudEvent(event)
+	if err != nil {
+		return err
+	}
+	payload, err := json.Marshal(ce)
+	if err != nil {
+		return err
+	}
+	_, err = postJSON(ctx, s.client, s.address, cloudEventsContentType, payload, nil)
+	return err
+}
+
+func (s *CloudEventsSink) String() string {
+	return fmt.Sprintf("%s=%s", CloudEventsSinkType, redacted(s.address))
+}
+
+// newCloudEvent wraps the event in a CloudEvent, the source is the controller
+// that reported it, the subject is the involved object and the type is derived
+// from the event severity, e.g. 'io.fluxcd.toolkit.event.error'.
+func newCloudEvent(event events.Event) (*cloudEvent, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return nil, fmt.Errorf("failed to generate the event ID: %w", err)
+	}
+
+	obj := event.InvolvedObject
+	return &cloudEvent{
+		SpecVersion:     "1.0",
+		ID:              hex.EncodeToString(id),
+		Source:          event.ReportingController,
+		Type:            "io.fluxcd.toolkit.event." + event.Severity,
+		Subject:         fmt.Sprintf("%s/%s/%s", obj.Kind, obj.Namespace, obj.Name),
+		Time:            event.Timestamp.UTC().Format(time.RFC3339),
+		DataContentType: "application/json",
+		Data:            event,
+	}, nil
+}
@@ -0,0 +1,94 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package eventsinks
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/fluxcd/pkg/runtime/events"
+)
+
+func testEvent() events.Event {
+	return events.Event{
+		InvolvedObject: corev1.ObjectReference{
+			Kind:      "Kustomization",
+			Namespace: "apps",
+			Name:      "podinfo",
+		},
+		Severity:            events.EventSeverityError,
+		Timestamp:           metav1.Now(),
+		Message:             "Health check failed",
+		Reason:              "HealthCheckFailed",
+		ReportingController: "kustomize-controller",
+	}
+}
+
+func TestCloudEventsSink_Send(t *testing.T) {
+	g := NewWithT(t)
+
+	var contentType string
+	var received cloudEvent
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		contentType = r.Header.Get("Content-Type")
+		body, _ := io.ReadAll(r.Body)
+		g.Expect(json.Unmarshal(body, &received)).To(Succeed())
+	}))
+	defer server.Close()
+
+	address, err := url.Parse(server.URL + "/events")
+	g.Expect(err).ToNot(HaveOccurred())
+	sink, err := NewCloudEventsSink(address)
+	g.Expect(err).ToNot(HaveOccurred())
+
+	g.Expect(sink.Send(context.TODO(), testEvent())).To(Succeed())
+	g.Expect(contentType).To(Equal(cloudEventsContentType))
+	g.Expect(received.SpecVersion).To(Equal("1.0"))
+	g.Expect(received.ID).ToNot(BeEmpty())
+	g.Expect(received.Source).To(Equal("kustomize-controller"))
+	g.Expect(received.Type).To(Equal("io.fluxcd.toolkit.event.error"))
+	g.Expect(received.Subject).To(Equal("Kustomization/apps/podinfo"))
+	g.Expect(received.Data.Message).To(Equal("Health check failed"))
+}
+
+func TestCloudEventsSink_SendError(t *testing.T) {
+	g := NewWithT(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		_, _ = w.Write([]byte("collector unavailable"))
+	}))
+	defer server.Close()
+
+	address, err := url.Parse(server.URL)
+	g.Expect(err).ToNot(HaveOccurred())
+	sink, err := NewCloudEventsSink(address)
+	g.Expect(err).ToNot(HaveOccurred())
+
+	err = sink.Send(context.TODO(), testEvent())
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(err.Error()).To(ContainSubstring("collector unavailable"))
+}
@@ -0,0 +1,63 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package eventsinks
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+)
+
+// maxResponseSize is the maximum size of the response body read from an HTTP sink.
+const maxResponseSize = 64 * 1024
+
+// postJSON sends the payload to the address with the given content type, and
+// returns the response body, or an error if the response status is not 2xx.
+// The user credentials of the address are sent with basic authentication.
+func postJSON(ctx context.Context, client *http.Client, address *url.URL, contentType string,
+	payload []byte, headers map[string]string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, redacted(address), bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	if address.User != nil {
+		password, _ := address.User.Password()
+		req.SetBasicAuth(address.User.Username(), password)
+	}
+	req.Header.Set("Content-Type", contentType)
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to post the event to '%s': %w", redacted(address), err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the response of '%s': %w", redacted(address), err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, fmt.Errorf("failed to post the event to '%s': %s %s", redacted(address), resp.Status, bytes.TrimSpace(body))
+	}
+	return body, nil
+}
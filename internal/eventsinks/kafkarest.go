@@ -0,0 +1,99 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package eventsinks
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/fluxcd/pkg/runtime/events"
+)
+
+// kafkaRESTContentType is the content type of the JSON records of the Kafka REST Proxy v2 API.
+const kafkaRESTContentType = "application/vnd.kafka.json.v2+json"
+
+// kafkaRecords is the body of a produce request to the Kafka REST Proxy.
+type kafkaRecords struct {
+	Records []kafkaRecord `json:"records"`
+}
+
+type kafkaRecord struct {
+	Key   string       `json:"key"`
+	Value events.Event `json:"value"`
+}
+
+// kafkaOffsets is the response of a produce request, with an error per record
+// that could not be produced.
+type kafkaOffsets struct {
+	Offsets []struct {
+		ErrorCode *int   `json:"error_code"`
+		Error     string `json:"error"`
+	} `json:"offsets"`
+}
+
+// KafkaRESTSink produces the events to a Kafka topic through the Kafka REST Proxy,
+// the address is the URL of the topic, e.g. 'http://kafka-rest-proxy:8082/topics/flux-events'.
+// The records are keyed by the namespace and name of the involved object, so that the
+// events of an object land in the same partition and are consumed in order.
+type KafkaRESTSink struct {
+	address *url.URL
+	client  *http.Client
+}
+
+// NewKafkaRESTSink returns a KafkaRESTSink producing to the topic URL of a Kafka REST Proxy.
+func NewKafkaRESTSink(address *url.URL) (*KafkaRESTSink, error) {
+	if err := checkHTTPAddress(KafkaRESTSinkType, address); err != nil {
+		return nil, err
+	}
+	return &KafkaRESTSink{address: address, client: &http.Client{}}, nil
+}
+
+// Send produces the event as a JSON record.
+func (s *KafkaRESTSink) Send(ctx context.Context, event events.Event) error {
+	obj := event.InvolvedObject
+	payload, err := json.Marshal(kafkaRecords{Records: []kafkaRecord{{
+		Key:   fmt.Sprintf("%s/%s", obj.Namespace, obj.Name),
+		Value: event,
+	}}})
+	if err != nil {
+		return err
+	}
+	body, err := postJSON(ctx, s.client, s.address, kafkaRESTContentType, payload,
+		map[string]string{"Accept": "application/vnd.kafka.v2+json"})
+	if err != nil {
+		return err
+	}
+
+	var offsets kafkaOffsets
+	if err := json.Unmarshal(body, &offsets); err != nil {
+		return fmt.Errorf("invalid response from '%s': %w", redacted(s.address), err)
+	}
+	for _, o := range offsets.Offsets {
+		if o.ErrorCode != nil {
+			return fmt.Errorf("failed to produce the event to '%s': error code %d: %s",
+				redacted(s.address), *o.ErrorCode, o.Error)
+		}
+	}
+	return nil
+}
+
+func (s *KafkaRESTSink) String() string {
+	return fmt.Sprintf("%s=%s", KafkaRESTSinkType, redacted(s.address))
+}
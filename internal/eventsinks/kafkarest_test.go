@@ -0,0 +1,71 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package eventsinks
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestKafkaRESTSink_Send(t *testing.T) {
+	g := NewWithT(t)
+
+	var path, contentType, user string
+	var received kafkaRecords
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		contentType = r.Header.Get("Content-Type")
+		user, _, _ = r.BasicAuth()
+		body, _ := io.ReadAll(r.Body)
+		g.Expect(json.Unmarshal(body, &received)).To(Succeed())
+		if path == "/topics/full" {
+			_, _ = w.Write([]byte(`{"offsets":[{"partition":null,"offset":null,"error_code":50003,"error":"Kafka error"}]}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"offsets":[{"partition":0,"offset":42,"error_code":null,"error":null}]}`))
+	}))
+	defer server.Close()
+
+	address, err := url.Parse(server.URL + "/topics/flux-events")
+	g.Expect(err).ToNot(HaveOccurred())
+	address.User = url.UserPassword("flux", "secret")
+	sink, err := NewKafkaRESTSink(address)
+	g.Expect(err).ToNot(HaveOccurred())
+
+	g.Expect(sink.Send(context.TODO(), testEvent())).To(Succeed())
+	g.Expect(path).To(Equal("/topics/flux-events"))
+	g.Expect(contentType).To(Equal(kafkaRESTContentType))
+	g.Expect(user).To(Equal("flux"))
+	g.Expect(received.Records).To(HaveLen(1))
+	g.Expect(received.Records[0].Key).To(Equal("apps/podinfo"))
+	g.Expect(received.Records[0].Value.Reason).To(Equal("HealthCheckFailed"))
+
+	address, err = url.Parse(server.URL + "/topics/full")
+	g.Expect(err).ToNot(HaveOccurred())
+	sink, err = NewKafkaRESTSink(address)
+	g.Expect(err).ToNot(HaveOccurred())
+	err = sink.Send(context.TODO(), testEvent())
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(err.Error()).To(ContainSubstring("error code 50003"))
+}
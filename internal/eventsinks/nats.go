@@ -0,0 +1,119 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package eventsinks
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/nats-io/nats.go"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	"github.com/fluxcd/pkg/runtime/events"
+)
+
+// NATSSink publishes the events to a NATS subject, the address is formatted as
+// 'nats://[user:password@]host[:port]/<subject>', or with the 'tls' scheme to
+// connect with TLS. The connection is opened at the first event and kept open,
+// it's reestablished by the client when lost. An event is acknowledged once the
+// server has processed it, the permission violations reported asynchronously
+// by the server are logged.
+type NATSSink struct {
+	address *url.URL
+	server  string
+	subject string
+
+	mu   sync.Mutex
+	conn *nats.Conn
+}
+
+// NewNATSSink returns a NATSSink publishing to the subject of the address.
+func NewNATSSink(address *url.URL) (*NATSSink, error) {
+	subject := strings.TrimPrefix(address.Path, "/")
+	if (address.Scheme != "nats" && address.Scheme != "tls") || address.Host == "" ||
+		subject == "" || strings.ContainsAny(subject, " \t/") {
+		return nil, fmt.Errorf("invalid %s sink address '%s', must be formatted as 'nats://host[:port]/<subject>'",
+			NATSSinkType, redacted(address))
+	}
+
+	server := *address
+	server.Path = ""
+	server.RawPath = ""
+	return &NATSSink{address: address, server: server.String(), subject: subject}, nil
+}
+
+// Send publishes the event in JSON format, and waits for the server
+// to acknowledge it until the context is done.
+func (s *NATSSink) Send(ctx context.Context, event events.Event) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	conn, err := s.connect()
+	if err != nil {
+		return fmt.Errorf("failed to connect to '%s': %w", redacted(s.address), err)
+	}
+	if err := conn.Publish(s.subject, payload); err != nil {
+		return fmt.Errorf("failed to publish to '%s': %w", redacted(s.address), err)
+	}
+	if err := conn.FlushWithContext(ctx); err != nil {
+		return fmt.Errorf("failed to publish to '%s': %w", redacted(s.address), err)
+	}
+	return nil
+}
+
+// Close closes the connection to the server.
+func (s *NATSSink) Close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.conn != nil {
+		s.conn.Close()
+		s.conn = nil
+	}
+}
+
+func (s *NATSSink) String() string {
+	return fmt.Sprintf("%s=%s", NATSSinkType, redacted(s.address))
+}
+
+// connect returns the connection to the server, opened at the first call.
+func (s *NATSSink) connect() (*nats.Conn, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.conn != nil {
+		return s.conn, nil
+	}
+
+	log := ctrl.Log.WithName("event-sinks")
+	conn, err := nats.Connect(s.server,
+		nats.Name("kustomize-controller"),
+		nats.MaxReconnects(-1),
+		nats.ErrorHandler(func(_ *nats.Conn, _ *nats.Subscription, err error) {
+			log.Error(err, "NATS error", "sink", s.String())
+		}),
+	)
+	if err != nil {
+		return nil, err
+	}
+	s.conn = conn
+	return conn, nil
+}
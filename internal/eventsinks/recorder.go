@@ -0,0 +1,184 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package eventsinks
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	kuberecorder "k8s.io/client-go/tools/record"
+	"k8s.io/client-go/tools/reference"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	"github.com/fluxcd/pkg/runtime/events"
+)
+
+const (
+	// queueSize is the number of events buffered for delivery per sink, the events
+	// recorded while the queue of a sink is full are dropped and counted in the
+	// gotk_event_sink_dropped_events_total metric.
+	queueSize = 1000
+
+	// sendTimeout is the time given to a sink to deliver an event.
+	sendTimeout = 15 * time.Second
+)
+
+// Recorder is an EventRecorder that records the events with the wrapped recorder,
+// e.g. the GitOps Toolkit events.Recorder posting to notification-controller,
+// and delivers them to additional sinks in the background. Each sink has its own
+// queue, so that a sink that is slow or unavailable doesn't delay the others.
+// The sinks don't receive the trace events, in line with notification-controller.
+type Recorder struct {
+	kuberecorder.EventRecorder
+	scheme              *runtime.Scheme
+	reportingController string
+	queues              []*sinkQueue
+	droppedCounter      *prometheus.CounterVec
+}
+
+// sinkQueue holds the events waiting to be delivered to a sink.
+type sinkQueue struct {
+	sink   Sink
+	events chan events.Event
+}
+
+// NewRecorder returns a Recorder delivering the events to the sinks,
+// it must be added to the manager for the events to be delivered.
+func NewRecorder(recorder kuberecorder.EventRecorder, scheme *runtime.Scheme,
+	reportingController string, sinks []Sink) *Recorder {
+	queues := make([]*sinkQueue, 0, len(sinks))
+	for _, sink := range sinks {
+		queues = append(queues, &sinkQueue{sink: sink, events: make(chan events.Event, queueSize)})
+	}
+	return &Recorder{
+		EventRecorder:       recorder,
+		scheme:              scheme,
+		reportingController: reportingController,
+		queues:              queues,
+		droppedCounter: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "gotk_event_sink_dropped_events_total",
+				Help: "The number of events dropped because the delivery queue of the event sink was full.",
+			},
+			[]string{"sink"},
+		),
+	}
+}
+
+// Collectors returns the metrics of the Recorder.
+func (r *Recorder) Collectors() []prometheus.Collector {
+	return []prometheus.Collector{r.droppedCounter}
+}
+
+// Event records an event with the wrapped recorder and queues it for the sinks.
+func (r *Recorder) Event(object runtime.Object, eventtype, reason, message string) {
+	r.AnnotatedEventf(object, nil, eventtype, reason, "%s", message)
+}
+
+// Eventf records an event with the wrapped recorder and queues it for the sinks.
+func (r *Recorder) Eventf(object runtime.Object, eventtype, reason, messageFmt string, args ...interface{}) {
+	r.AnnotatedEventf(object, nil, eventtype, reason, messageFmt, args...)
+}
+
+// AnnotatedEventf records an event with the wrapped recorder and queues it for the sinks.
+func (r *Recorder) AnnotatedEventf(object runtime.Object, annotations map[string]string,
+	eventtype, reason, messageFmt string, args ...interface{}) {
+	r.EventRecorder.AnnotatedEventf(object, annotations, eventtype, reason, messageFmt, args...)
+
+	if len(r.queues) == 0 || eventtype == events.EventTypeTrace {
+		return
+	}
+	severity := events.EventSeverityInfo
+	if eventtype == corev1.EventTypeWarning {
+		severity = events.EventSeverityError
+	}
+
+	log := ctrl.Log.WithName("event-sinks")
+	ref, err := reference.GetReference(r.scheme, object)
+	if err != nil {
+		log.Error(err, "failed to get object reference")
+		return
+	}
+
+	event := events.Event{
+		InvolvedObject:      *ref,
+		Severity:            severity,
+		Timestamp:           metav1.Now(),
+		Message:             fmt.Sprintf(messageFmt, args...),
+		Reason:              reason,
+		Metadata:            annotations,
+		ReportingController: r.reportingController,
+	}
+
+	for _, q := range r.queues {
+		select {
+		case q.events <- event:
+		default:
+			r.droppedCounter.WithLabelValues(q.sink.String()).Inc()
+			log.Error(fmt.Errorf("queue is full"), "dropped event", "sink", q.sink.String(),
+				"name", ref.Name, "namespace", ref.Namespace, "reason", reason)
+		}
+	}
+}
+
+// Start delivers the queued events to the sinks until the context is done,
+// the events of a sink are delivered in order, the sinks are independent.
+func (r *Recorder) Start(ctx context.Context) error {
+	var wg sync.WaitGroup
+	for _, q := range r.queues {
+		wg.Add(1)
+		go func(q *sinkQueue) {
+			defer wg.Done()
+			q.run(ctx)
+		}(q)
+	}
+	wg.Wait()
+	return nil
+}
+
+// run delivers the events of the queue to the sink until the context is done,
+// and closes the sink if it holds a connection.
+func (q *sinkQueue) run(ctx context.Context) {
+	log := ctrl.Log.WithName("event-sinks")
+	if closer, ok := q.sink.(interface{ Close() }); ok {
+		defer closer.Close()
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event := <-q.events:
+			sendCtx, cancel := context.WithTimeout(ctx, sendTimeout)
+			if err := q.sink.Send(sendCtx, event); err != nil {
+				log.Error(err, "failed to deliver event", "sink", q.sink.String(),
+					"name", event.InvolvedObject.Name, "namespace", event.InvolvedObject.Namespace)
+			}
+			cancel()
+		}
+	}
+}
+
+// NeedLeaderElection returns false, the events are delivered by every replica.
+func (r *Recorder) NeedLeaderElection() bool {
+	return false
+}
@@ -0,0 +1,123 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package eventsinks
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+
+	"github.com/fluxcd/pkg/runtime/events"
+)
+
+type fakeSink struct {
+	events chan events.Event
+}
+
+func (s *fakeSink) Send(ctx context.Context, event events.Event) error {
+	s.events <- event
+	return nil
+}
+
+func (s *fakeSink) String() string {
+	return "fake"
+}
+
+// blockedSink doesn't answer until the context is done.
+type blockedSink struct{}
+
+func (s *blockedSink) Send(ctx context.Context, event events.Event) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func (s *blockedSink) String() string {
+	return "blocked"
+}
+
+func TestRecorder(t *testing.T) {
+	g := NewWithT(t)
+
+	scheme := runtime.NewScheme()
+	g.Expect(corev1.AddToScheme(scheme)).To(Succeed())
+
+	inner := record.NewFakeRecorder(10)
+	sink := &fakeSink{events: make(chan events.Event, 10)}
+	recorder := NewRecorder(inner, scheme, "kustomize-controller", []Sink{sink})
+
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+	go func() {
+		_ = recorder.Start(ctx)
+	}()
+
+	obj := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: "apps", Name: "podinfo"}}
+	recorder.AnnotatedEventf(obj, map[string]string{"revision": "main/1"}, corev1.EventTypeWarning,
+		"HealthCheckFailed", "Health check failed after %s", "5m")
+	recorder.Event(obj, events.EventTypeTrace, "Progressing", "reconciliation in progress")
+	recorder.Event(obj, corev1.EventTypeNormal, "ReconciliationSucceeded", "Applied revision: main/1")
+
+	g.Expect(inner.Events).To(HaveLen(3))
+
+	var event events.Event
+	g.Eventually(sink.events, time.Second).Should(Receive(&event))
+	g.Expect(event.InvolvedObject.Kind).To(Equal("ConfigMap"))
+	g.Expect(event.InvolvedObject.Name).To(Equal("podinfo"))
+	g.Expect(event.Severity).To(Equal(events.EventSeverityError))
+	g.Expect(event.Message).To(Equal("Health check failed after 5m"))
+	g.Expect(event.Metadata).To(HaveKeyWithValue("revision", "main/1"))
+	g.Expect(event.ReportingController).To(Equal("kustomize-controller"))
+
+	g.Eventually(sink.events, time.Second).Should(Receive(&event))
+	g.Expect(event.Severity).To(Equal(events.EventSeverityInfo))
+	g.Expect(event.Reason).To(Equal("ReconciliationSucceeded"))
+	g.Consistently(sink.events, 100*time.Millisecond).ShouldNot(Receive())
+}
+
+func TestRecorder_independentSinks(t *testing.T) {
+	g := NewWithT(t)
+
+	scheme := runtime.NewScheme()
+	g.Expect(corev1.AddToScheme(scheme)).To(Succeed())
+
+	sink := &fakeSink{events: make(chan events.Event, queueSize+10)}
+	recorder := NewRecorder(record.NewFakeRecorder(queueSize+10), scheme, "kustomize-controller",
+		[]Sink{&blockedSink{}, sink})
+
+	// the events that overflow the queues are dropped and counted
+	obj := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: "apps", Name: "podinfo"}}
+	for i := 0; i < queueSize+2; i++ {
+		recorder.Event(obj, corev1.EventTypeNormal, "ReconciliationSucceeded", "Applied revision: main/1")
+	}
+	g.Expect(testutil.ToFloat64(recorder.droppedCounter.WithLabelValues("blocked"))).To(BeEquivalentTo(2))
+	g.Expect(testutil.ToFloat64(recorder.droppedCounter.WithLabelValues("fake"))).To(BeEquivalentTo(2))
+
+	// the events are delivered to the sink while the other is blocked
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+	go func() {
+		_ = recorder.Start(ctx)
+	}()
+	g.Eventually(func() int { return len(sink.events) }, time.Second).Should(Equal(queueSize))
+}
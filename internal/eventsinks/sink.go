@@ -0,0 +1,136 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package eventsinks
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/fluxcd/pkg/runtime/events"
+)
+
+// Sink delivers the events recorded by the controller to an external system.
+type Sink interface {
+	// Send delivers the event, it must return when the context is done.
+	Send(ctx context.Context, event events.Event) error
+
+	// String returns the type and address of the sink, without credentials.
+	String() string
+}
+
+// The supported types of sinks.
+const (
+	CloudEventsSinkType = "cloudevents"
+	KafkaRESTSinkType   = "kafka-rest"
+	NATSSinkType        = "nats"
+)
+
+// ParseSink returns the Sink configured as '<type>=<url>', e.g.
+// 'cloudevents=https://collector.example.com/events',
+// 'kafka-rest=http://kafka-rest-proxy:8082/topics/flux-events' or
+// 'nats=nats://nats:4222/flux.events'. The address can't contain credentials,
+// as the specs passed on the command line are visible in the pod spec,
+// the sinks with credentials are configured with ParseSinksFile.
+func ParseSink(spec string) (Sink, error) {
+	sink, address, err := parseSink(spec)
+	if err != nil {
+		return nil, err
+	}
+	if address.User != nil {
+		return nil, fmt.Errorf("invalid event sink '%s', the credentials must be given in the sinks file", sink)
+	}
+	return sink, nil
+}
+
+// ParseSinksFile returns the sinks configured in the file, e.g. a Secret mounted
+// in the controller pod, with one '<type>=<url>' spec per line. The addresses can
+// contain credentials, the empty lines and the lines starting with '#' are ignored.
+func ParseSinksFile(path string) ([]Sink, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var sinks []Sink
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		spec := strings.TrimSpace(scanner.Text())
+		if spec == "" || strings.HasPrefix(spec, "#") {
+			continue
+		}
+		sink, _, err := parseSink(spec)
+		if err != nil {
+			// the spec isn't part of the error, it can contain credentials
+			return nil, fmt.Errorf("invalid event sink at line %d of '%s': %w", n, path, err)
+		}
+		sinks = append(sinks, sink)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return sinks, nil
+}
+
+// parseSink returns the Sink configured as '<type>=<url>' and its address.
+func parseSink(spec string) (Sink, *url.URL, error) {
+	parts := strings.SplitN(spec, "=", 2)
+	if len(parts) != 2 || parts[1] == "" {
+		return nil, nil, fmt.Errorf("invalid event sink '%s', must be formatted as '<type>=<url>'", parts[0])
+	}
+
+	address, err := url.Parse(parts[1])
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid event sink '%s' address", parts[0])
+	}
+
+	var sink Sink
+	switch parts[0] {
+	case CloudEventsSinkType:
+		sink, err = NewCloudEventsSink(address)
+	case KafkaRESTSinkType:
+		sink, err = NewKafkaRESTSink(address)
+	case NATSSinkType:
+		sink, err = NewNATSSink(address)
+	default:
+		err = fmt.Errorf("unsupported event sink type '%s', must be one of: %s, %s, %s",
+			parts[0], CloudEventsSinkType, KafkaRESTSinkType, NATSSinkType)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return sink, address, nil
+}
+
+// redacted returns the address without the user credentials.
+func redacted(address *url.URL) string {
+	u := *address
+	u.User = nil
+	return u.String()
+}
+
+// checkHTTPAddress returns an error if the address is not an HTTP(S) URL.
+func checkHTTPAddress(sinkType string, address *url.URL) error {
+	if (address.Scheme != "http" && address.Scheme != "https") || address.Host == "" {
+		return fmt.Errorf("invalid %s sink address '%s', must be an http or https URL", sinkType, redacted(address))
+	}
+	return nil
+}
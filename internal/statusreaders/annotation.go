@@ -0,0 +1,191 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package statusreaders
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/cli-utils/pkg/kstatus/polling/engine"
+	"sigs.k8s.io/cli-utils/pkg/kstatus/polling/event"
+	kstatusreaders "sigs.k8s.io/cli-utils/pkg/kstatus/polling/statusreaders"
+	"sigs.k8s.io/cli-utils/pkg/kstatus/status"
+	"sigs.k8s.io/cli-utils/pkg/object"
+)
+
+const (
+	// HealthAnnotation set to 'ready' on an object makes it healthy as soon as it's applied.
+	HealthAnnotation = "kustomize.toolkit.fluxcd.io/health"
+
+	// HealthReadyValue is the value of the HealthAnnotation which skips the health assessment.
+	HealthReadyValue = "ready"
+
+	// HealthConditionAnnotation on an object is the type of the status condition
+	// which tells whether the object is healthy.
+	HealthConditionAnnotation = "kustomize.toolkit.fluxcd.io/health-condition"
+)
+
+type annotationStatusReader struct {
+	mapper        meta.RESTMapper
+	statusReaders []engine.StatusReader
+}
+
+// NewAnnotationStatusReader returns a StatusReader that computes the status of the objects
+// from their health annotations. The objects without annotations are delegated to the first
+// of the given status readers that supports them, or to the kstatus default status reader.
+func NewAnnotationStatusReader(mapper meta.RESTMapper, statusReaders ...engine.StatusReader) engine.StatusReader {
+	return &annotationStatusReader{
+		mapper:        mapper,
+		statusReaders: append(statusReaders, kstatusreaders.NewDefaultStatusReader(mapper)),
+	}
+}
+
+func (a *annotationStatusReader) Supports(gk schema.GroupKind) bool {
+	return true
+}
+
+func (a *annotationStatusReader) ReadStatus(ctx context.Context, reader engine.ClusterReader, resource object.ObjMetadata) (*event.ResourceStatus, error) {
+	mapping, err := a.mapper.RESTMapping(resource.GroupKind)
+	if err != nil {
+		return identifierErrorStatus(err, resource)
+	}
+
+	var u unstructured.Unstructured
+	u.SetGroupVersionKind(mapping.GroupVersionKind)
+	if err := reader.Get(ctx, types.NamespacedName{Namespace: resource.Namespace, Name: resource.Name}, &u); err != nil {
+		return identifierErrorStatus(err, resource)
+	}
+	return a.ReadStatusForObject(ctx, reader, &u)
+}
+
+func (a *annotationStatusReader) ReadStatusForObject(ctx context.Context, reader engine.ClusterReader, resource *unstructured.Unstructured) (*event.ResourceStatus, error) {
+	result, ok, err := annotationConditions(resource)
+	if ok {
+		rs := &event.ResourceStatus{
+			Identifier: object.UnstructuredToObjMetadata(resource),
+			Resource:   resource,
+		}
+		if err != nil {
+			rs.Status = status.UnknownStatus
+			rs.Error = err
+		} else {
+			rs.Status = result.Status
+			rs.Message = result.Message
+		}
+		return rs, nil
+	}
+
+	gk := resource.GroupVersionKind().GroupKind()
+	for _, sr := range a.statusReaders {
+		if sr.Supports(gk) {
+			return sr.ReadStatusForObject(ctx, reader, resource)
+		}
+	}
+	return nil, fmt.Errorf("no status reader supports this resource: %v", gk)
+}
+
+// identifierErrorStatus returns the status of an object that couldn't be read,
+// the context errors are returned as is for the poller to stop.
+func identifierErrorStatus(err error, resource object.ObjMetadata) (*event.ResourceStatus, error) {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return nil, err
+	}
+	if apierrors.IsNotFound(err) {
+		return &event.ResourceStatus{
+			Identifier: resource,
+			Status:     status.NotFoundStatus,
+			Message:    "Resource not found",
+		}, nil
+	}
+	return &event.ResourceStatus{
+		Identifier: resource,
+		Status:     status.UnknownStatus,
+		Error:      err,
+	}, nil
+}
+
+// annotationConditions computes the status of an object from its health annotations,
+// it returns false if the object has no health annotations.
+func annotationConditions(u *unstructured.Unstructured) (*status.Result, bool, error) {
+	annotations := u.GetAnnotations()
+
+	if value, ok := annotations[HealthAnnotation]; ok {
+		if value != HealthReadyValue {
+			return nil, true, fmt.Errorf("unsupported value '%s' of the %s annotation, must be '%s'",
+				value, HealthAnnotation, HealthReadyValue)
+		}
+		return &status.Result{
+			Status:  status.CurrentStatus,
+			Message: "Health assessment skipped by annotation",
+		}, true, nil
+	}
+
+	conditionType, ok := annotations[HealthConditionAnnotation]
+	if !ok {
+		return nil, false, nil
+	}
+
+	obj := u.UnstructuredContent()
+	generation := status.GetIntField(obj, ".metadata.generation", 0)
+	observedGeneration := status.GetIntField(obj, ".status.observedGeneration", generation)
+	if observedGeneration < generation {
+		return inProgressResult(fmt.Sprintf("%s generation is %d, but latest observed generation is %d",
+			u.GetKind(), generation, observedGeneration)), true, nil
+	}
+
+	objc, err := status.GetObjectWithConditions(obj)
+	if err != nil {
+		return nil, true, err
+	}
+	for _, c := range objc.Status.Conditions {
+		if string(c.Type) != conditionType {
+			continue
+		}
+		if c.Status == corev1.ConditionTrue {
+			return &status.Result{
+				Status:     status.CurrentStatus,
+				Message:    c.Message,
+				Conditions: []status.Condition{},
+			}, true, nil
+		}
+		return inProgressResult(fmt.Sprintf("%s condition is %s: %s", conditionType, c.Status, c.Message)), true, nil
+	}
+
+	return inProgressResult(fmt.Sprintf("%s condition not found", conditionType)), true, nil
+}
+
+func inProgressResult(message string) *status.Result {
+	return &status.Result{
+		Status:  status.InProgressStatus,
+		Message: message,
+		Conditions: []status.Condition{
+			{
+				Type:    status.ConditionReconciling,
+				Status:  corev1.ConditionTrue,
+				Reason:  "HealthConditionNotReady",
+				Message: message,
+			},
+		},
+	}
+}
@@ -0,0 +1,119 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package statusreaders
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/cli-utils/pkg/kstatus/status"
+)
+
+func newAnnotatedObject(annotations map[string]interface{}, generation int64, statusFields map[string]interface{}) *unstructured.Unstructured {
+	u := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "example.com/v1",
+		"kind":       "Database",
+		"metadata": map[string]interface{}{
+			"name":        "db",
+			"namespace":   "default",
+			"generation":  generation,
+			"annotations": annotations,
+		},
+	}}
+	if statusFields != nil {
+		u.Object["status"] = statusFields
+	}
+	return u
+}
+
+func Test_annotationConditions(t *testing.T) {
+	available := func(conditionStatus string) map[string]interface{} {
+		return map[string]interface{}{
+			"observedGeneration": int64(2),
+			"conditions": []interface{}{
+				map[string]interface{}{"type": "Available", "status": conditionStatus, "message": "database is up"},
+			},
+		}
+	}
+
+	tests := []struct {
+		name       string
+		object     *unstructured.Unstructured
+		wantOK     bool
+		wantErr    bool
+		wantStatus status.Status
+	}{
+		{
+			name:   "no annotations",
+			object: newAnnotatedObject(nil, 1, nil),
+		},
+		{
+			name:       "health ready",
+			object:     newAnnotatedObject(map[string]interface{}{HealthAnnotation: HealthReadyValue}, 1, nil),
+			wantOK:     true,
+			wantStatus: status.CurrentStatus,
+		},
+		{
+			name:    "unsupported health value",
+			object:  newAnnotatedObject(map[string]interface{}{HealthAnnotation: "ignore"}, 1, nil),
+			wantOK:  true,
+			wantErr: true,
+		},
+		{
+			name:       "custom condition true",
+			object:     newAnnotatedObject(map[string]interface{}{HealthConditionAnnotation: "Available"}, 2, available("True")),
+			wantOK:     true,
+			wantStatus: status.CurrentStatus,
+		},
+		{
+			name:       "custom condition false",
+			object:     newAnnotatedObject(map[string]interface{}{HealthConditionAnnotation: "Available"}, 2, available("False")),
+			wantOK:     true,
+			wantStatus: status.InProgressStatus,
+		},
+		{
+			name:       "custom condition not found",
+			object:     newAnnotatedObject(map[string]interface{}{HealthConditionAnnotation: "Synced"}, 2, available("True")),
+			wantOK:     true,
+			wantStatus: status.InProgressStatus,
+		},
+		{
+			name:       "generation not observed",
+			object:     newAnnotatedObject(map[string]interface{}{HealthConditionAnnotation: "Available"}, 3, available("True")),
+			wantOK:     true,
+			wantStatus: status.InProgressStatus,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			result, ok, err := annotationConditions(tt.object)
+			g.Expect(ok).To(Equal(tt.wantOK))
+			if tt.wantErr {
+				g.Expect(err).To(HaveOccurred())
+				return
+			}
+			g.Expect(err).ToNot(HaveOccurred())
+			if tt.wantOK {
+				g.Expect(result.Status).To(Equal(tt.wantStatus))
+			}
+		})
+	}
+}
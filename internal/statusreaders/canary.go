@@ -0,0 +1,117 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package statusreaders
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/cli-utils/pkg/kstatus/polling/engine"
+	"sigs.k8s.io/cli-utils/pkg/kstatus/polling/event"
+	kstatusreaders "sigs.k8s.io/cli-utils/pkg/kstatus/polling/statusreaders"
+	"sigs.k8s.io/cli-utils/pkg/kstatus/status"
+	"sigs.k8s.io/cli-utils/pkg/object"
+)
+
+// CanaryGroupKind is the GroupKind of the Flagger Canary objects.
+var CanaryGroupKind = schema.GroupKind{Group: "flagger.app", Kind: "Canary"}
+
+type canaryStatusReader struct {
+	genericStatusReader engine.StatusReader
+}
+
+// NewCanaryStatusReader returns a StatusReader for the Flagger Canary objects.
+// A Canary is healthy once initialized and unhealthy when its analysis failed,
+// when waitForAnalysis is true a Canary is also in progress during its analysis.
+func NewCanaryStatusReader(mapper meta.RESTMapper, waitForAnalysis bool) engine.StatusReader {
+	genericStatusReader := kstatusreaders.NewGenericStatusReader(mapper, func(u *unstructured.Unstructured) (*status.Result, error) {
+		return canaryConditions(u, waitForAnalysis)
+	})
+	return &canaryStatusReader{
+		genericStatusReader: genericStatusReader,
+	}
+}
+
+func (c *canaryStatusReader) Supports(gk schema.GroupKind) bool {
+	return gk == CanaryGroupKind
+}
+
+func (c *canaryStatusReader) ReadStatus(ctx context.Context, reader engine.ClusterReader, resource object.ObjMetadata) (*event.ResourceStatus, error) {
+	return c.genericStatusReader.ReadStatus(ctx, reader, resource)
+}
+
+func (c *canaryStatusReader) ReadStatusForObject(ctx context.Context, reader engine.ClusterReader, resource *unstructured.Unstructured) (*event.ResourceStatus, error) {
+	return c.genericStatusReader.ReadStatusForObject(ctx, reader, resource)
+}
+
+// Ref: https://github.com/fluxcd/flagger/blob/v1.22.0/pkg/apis/flagger/v1beta1/status.go
+func canaryConditions(u *unstructured.Unstructured, waitForAnalysis bool) (*status.Result, error) {
+	obj := u.UnstructuredContent()
+	phase := status.GetStringField(obj, ".status.phase", "")
+
+	switch phase {
+	case "Initialized", "Succeeded":
+		return &status.Result{
+			Status:     status.CurrentStatus,
+			Message:    fmt.Sprintf("Canary %s", phase),
+			Conditions: []status.Condition{},
+		}, nil
+	case "Failed":
+		message := "Canary analysis failed, the rollout was rolled back"
+		return &status.Result{
+			Status:  status.FailedStatus,
+			Message: message,
+			Conditions: []status.Condition{
+				{
+					Type:    status.ConditionStalled,
+					Status:  corev1.ConditionTrue,
+					Reason:  "CanaryFailed",
+					Message: message,
+				},
+			},
+		}, nil
+	case "Waiting", "Progressing", "WaitingPromotion", "Promoting", "Finalising":
+		if !waitForAnalysis {
+			return &status.Result{
+				Status:     status.CurrentStatus,
+				Message:    fmt.Sprintf("Canary analysis in progress: %s", phase),
+				Conditions: []status.Condition{},
+			}, nil
+		}
+	}
+
+	message := "Canary is initializing"
+	if phase != "" && phase != "Initializing" {
+		message = fmt.Sprintf("Canary phase is %s", phase)
+	}
+	return &status.Result{
+		Status:  status.InProgressStatus,
+		Message: message,
+		Conditions: []status.Condition{
+			{
+				Type:    status.ConditionReconciling,
+				Status:  corev1.ConditionTrue,
+				Reason:  "CanaryInProgress",
+				Message: message,
+			},
+		},
+	}, nil
+}
@@ -0,0 +1,64 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package statusreaders
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/cli-utils/pkg/kstatus/status"
+)
+
+func Test_canaryConditions(t *testing.T) {
+	newCanary := func(phase string) *unstructured.Unstructured {
+		u := &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "flagger.app/v1beta1",
+			"kind":       "Canary",
+			"metadata":   map[string]interface{}{"name": "podinfo", "namespace": "test"},
+		}}
+		if phase != "" {
+			u.Object["status"] = map[string]interface{}{"phase": phase}
+		}
+		return u
+	}
+
+	tests := []struct {
+		phase           string
+		waitForAnalysis bool
+		want            status.Status
+	}{
+		{phase: "", want: status.InProgressStatus},
+		{phase: "Initializing", want: status.InProgressStatus},
+		{phase: "Initialized", want: status.CurrentStatus},
+		{phase: "Progressing", want: status.CurrentStatus},
+		{phase: "Progressing", waitForAnalysis: true, want: status.InProgressStatus},
+		{phase: "WaitingPromotion", waitForAnalysis: true, want: status.InProgressStatus},
+		{phase: "Succeeded", waitForAnalysis: true, want: status.CurrentStatus},
+		{phase: "Failed", want: status.FailedStatus},
+		{phase: "Failed", waitForAnalysis: true, want: status.FailedStatus},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.phase, func(t *testing.T) {
+			g := NewWithT(t)
+			result, err := canaryConditions(newCanary(tt.phase), tt.waitForAnalysis)
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(result.Status).To(Equal(tt.want))
+		})
+	}
+}
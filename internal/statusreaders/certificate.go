@@ -0,0 +1,138 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package statusreaders
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/cli-utils/pkg/kstatus/polling/engine"
+	"sigs.k8s.io/cli-utils/pkg/kstatus/polling/event"
+	kstatusreaders "sigs.k8s.io/cli-utils/pkg/kstatus/polling/statusreaders"
+	"sigs.k8s.io/cli-utils/pkg/kstatus/status"
+	"sigs.k8s.io/cli-utils/pkg/object"
+)
+
+// CertificateGroupKind is the GroupKind of the cert-manager Certificate objects.
+var CertificateGroupKind = schema.GroupKind{Group: "cert-manager.io", Kind: "Certificate"}
+
+type certificateStatusReader struct {
+	genericStatusReader engine.StatusReader
+}
+
+// NewCertificateStatusReader returns a StatusReader for the cert-manager Certificate objects.
+// A Certificate is healthy once the certificate is issued and stored in its Secret.
+func NewCertificateStatusReader(mapper meta.RESTMapper) engine.StatusReader {
+	genericStatusReader := kstatusreaders.NewGenericStatusReader(mapper, certificateConditions)
+	return &certificateStatusReader{
+		genericStatusReader: genericStatusReader,
+	}
+}
+
+func (c *certificateStatusReader) Supports(gk schema.GroupKind) bool {
+	return gk == CertificateGroupKind
+}
+
+func (c *certificateStatusReader) ReadStatus(ctx context.Context, reader engine.ClusterReader, resource object.ObjMetadata) (*event.ResourceStatus, error) {
+	return c.genericStatusReader.ReadStatus(ctx, reader, resource)
+}
+
+func (c *certificateStatusReader) ReadStatusForObject(ctx context.Context, reader engine.ClusterReader, resource *unstructured.Unstructured) (*event.ResourceStatus, error) {
+	return c.genericStatusReader.ReadStatusForObject(ctx, reader, resource)
+}
+
+// Ref: https://github.com/cert-manager/cert-manager/blob/v1.8.0/pkg/apis/certmanager/v1/types_certificate.go
+// The Certificate is current when its Ready condition is true for the latest generation,
+// failed when the last issuance failed, and in progress while it's being issued.
+func certificateConditions(u *unstructured.Unstructured) (*status.Result, error) {
+	conditions, _, err := unstructured.NestedSlice(u.UnstructuredContent(), "status", "conditions")
+	if err != nil {
+		return nil, err
+	}
+
+	var ready, issuing map[string]interface{}
+	for _, c := range conditions {
+		condition, ok := c.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		switch condition["type"] {
+		case "Ready":
+			ready = condition
+		case "Issuing":
+			issuing = condition
+		}
+	}
+
+	if ready != nil && ready["status"] == string(corev1.ConditionTrue) {
+		observedGeneration, found, _ := unstructured.NestedInt64(ready, "observedGeneration")
+		if !found || observedGeneration == u.GetGeneration() {
+			return &status.Result{
+				Status:     status.CurrentStatus,
+				Message:    fmt.Sprintf("Certificate is ready: %s", status.GetStringField(ready, ".message", "")),
+				Conditions: []status.Condition{},
+			}, nil
+		}
+		return certificateInProgress("CertificateGenerationNotObserved",
+			fmt.Sprintf("Certificate generation is %d, but latest observed generation is %d", u.GetGeneration(), observedGeneration)), nil
+	}
+
+	if issuing != nil {
+		switch {
+		case issuing["status"] == string(corev1.ConditionTrue):
+			return certificateInProgress("CertificateIssuing", fmt.Sprintf("Certificate is being issued: %s", status.GetStringField(issuing, ".message", ""))), nil
+		case issuing["reason"] == "Failed":
+			message := fmt.Sprintf("Certificate issuance failed: %s", status.GetStringField(issuing, ".message", ""))
+			return &status.Result{
+				Status:  status.FailedStatus,
+				Message: message,
+				Conditions: []status.Condition{
+					{
+						Type:    status.ConditionStalled,
+						Status:  corev1.ConditionTrue,
+						Reason:  "CertificateIssuanceFailed",
+						Message: message,
+					},
+				},
+			}, nil
+		}
+	}
+
+	if ready != nil {
+		return certificateInProgress("CertificateNotReady", fmt.Sprintf("Certificate is not ready: %s", status.GetStringField(ready, ".message", ""))), nil
+	}
+	return certificateInProgress("CertificateNotReady", "Certificate status not observed"), nil
+}
+
+func certificateInProgress(reason, message string) *status.Result {
+	return &status.Result{
+		Status:  status.InProgressStatus,
+		Message: message,
+		Conditions: []status.Condition{
+			{
+				Type:    status.ConditionReconciling,
+				Status:  corev1.ConditionTrue,
+				Reason:  reason,
+				Message: message,
+			},
+		},
+	}
+}
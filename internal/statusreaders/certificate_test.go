@@ -0,0 +1,92 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package statusreaders
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/cli-utils/pkg/kstatus/status"
+)
+
+func Test_certificateConditions(t *testing.T) {
+	condition := func(conditionType, conditionStatus, reason string, observedGeneration int64) interface{} {
+		return map[string]interface{}{
+			"type":               conditionType,
+			"status":             conditionStatus,
+			"reason":             reason,
+			"message":            "test",
+			"observedGeneration": observedGeneration,
+		}
+	}
+	newCertificate := func(conditions ...interface{}) *unstructured.Unstructured {
+		u := &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "cert-manager.io/v1",
+			"kind":       "Certificate",
+			"metadata":   map[string]interface{}{"name": "podinfo", "namespace": "test", "generation": int64(2)},
+		}}
+		if len(conditions) > 0 {
+			u.Object["status"] = map[string]interface{}{"conditions": conditions}
+		}
+		return u
+	}
+
+	tests := []struct {
+		name   string
+		object *unstructured.Unstructured
+		want   status.Status
+	}{
+		{name: "no status", object: newCertificate(), want: status.InProgressStatus},
+		{name: "ready", object: newCertificate(condition("Ready", "True", "Ready", 2)), want: status.CurrentStatus},
+		{name: "generation not observed", object: newCertificate(condition("Ready", "True", "Ready", 1)), want: status.InProgressStatus},
+		{name: "not ready", object: newCertificate(condition("Ready", "False", "DoesNotExist", 2)), want: status.InProgressStatus},
+		{
+			name: "issuing",
+			object: newCertificate(
+				condition("Ready", "False", "DoesNotExist", 2),
+				condition("Issuing", "True", "DoesNotExist", 2),
+			),
+			want: status.InProgressStatus,
+		},
+		{
+			name: "issuance failed",
+			object: newCertificate(
+				condition("Ready", "False", "DoesNotExist", 2),
+				condition("Issuing", "False", "Failed", 2),
+			),
+			want: status.FailedStatus,
+		},
+		{
+			name: "renewal in progress",
+			object: newCertificate(
+				condition("Ready", "True", "Ready", 2),
+				condition("Issuing", "True", "Renewing", 2),
+			),
+			want: status.CurrentStatus,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			result, err := certificateConditions(tt.object)
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(result.Status).To(Equal(tt.want))
+		})
+	}
+}
@@ -0,0 +1,95 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package statusreaders
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/cli-utils/pkg/kstatus/polling/engine"
+	"sigs.k8s.io/cli-utils/pkg/kstatus/polling/event"
+	kstatusreaders "sigs.k8s.io/cli-utils/pkg/kstatus/polling/statusreaders"
+	"sigs.k8s.io/cli-utils/pkg/kstatus/status"
+	"sigs.k8s.io/cli-utils/pkg/object"
+)
+
+// DNSEndpointGroupKind is the GroupKind of the ExternalDNS DNSEndpoint objects.
+var DNSEndpointGroupKind = schema.GroupKind{Group: "externaldns.k8s.io", Kind: "DNSEndpoint"}
+
+type dnsEndpointStatusReader struct {
+	genericStatusReader engine.StatusReader
+}
+
+// NewDNSEndpointStatusReader returns a StatusReader for the ExternalDNS DNSEndpoint objects.
+// A DNSEndpoint is healthy once ExternalDNS has synced its latest generation with the DNS provider.
+func NewDNSEndpointStatusReader(mapper meta.RESTMapper) engine.StatusReader {
+	genericStatusReader := kstatusreaders.NewGenericStatusReader(mapper, dnsEndpointConditions)
+	return &dnsEndpointStatusReader{
+		genericStatusReader: genericStatusReader,
+	}
+}
+
+func (d *dnsEndpointStatusReader) Supports(gk schema.GroupKind) bool {
+	return gk == DNSEndpointGroupKind
+}
+
+func (d *dnsEndpointStatusReader) ReadStatus(ctx context.Context, reader engine.ClusterReader, resource object.ObjMetadata) (*event.ResourceStatus, error) {
+	return d.genericStatusReader.ReadStatus(ctx, reader, resource)
+}
+
+func (d *dnsEndpointStatusReader) ReadStatusForObject(ctx context.Context, reader engine.ClusterReader, resource *unstructured.Unstructured) (*event.ResourceStatus, error) {
+	return d.genericStatusReader.ReadStatusForObject(ctx, reader, resource)
+}
+
+// Ref: https://github.com/kubernetes-sigs/external-dns/blob/v0.12.0/endpoint/endpoint.go
+// ExternalDNS sets the observed generation in the status of the DNSEndpoint
+// after the records are created or updated with the DNS provider.
+func dnsEndpointConditions(u *unstructured.Unstructured) (*status.Result, error) {
+	observedGeneration, found, err := unstructured.NestedInt64(u.UnstructuredContent(), "status", "observedGeneration")
+	if err != nil {
+		return nil, err
+	}
+
+	if found && observedGeneration == u.GetGeneration() {
+		return &status.Result{
+			Status:     status.CurrentStatus,
+			Message:    "DNS records are synced",
+			Conditions: []status.Condition{},
+		}, nil
+	}
+
+	message := "DNS records are not synced"
+	if found {
+		message = fmt.Sprintf("DNSEndpoint generation is %d, but latest synced generation is %d", u.GetGeneration(), observedGeneration)
+	}
+	return &status.Result{
+		Status:  status.InProgressStatus,
+		Message: message,
+		Conditions: []status.Condition{
+			{
+				Type:    status.ConditionReconciling,
+				Status:  corev1.ConditionTrue,
+				Reason:  "DNSEndpointNotSynced",
+				Message: message,
+			},
+		},
+	}, nil
+}
@@ -0,0 +1,58 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package statusreaders
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/cli-utils/pkg/kstatus/status"
+)
+
+func Test_dnsEndpointConditions(t *testing.T) {
+	newDNSEndpoint := func(observedGeneration interface{}) *unstructured.Unstructured {
+		u := &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "externaldns.k8s.io/v1alpha1",
+			"kind":       "DNSEndpoint",
+			"metadata":   map[string]interface{}{"name": "podinfo", "namespace": "test", "generation": int64(2)},
+		}}
+		if observedGeneration != nil {
+			u.Object["status"] = map[string]interface{}{"observedGeneration": observedGeneration}
+		}
+		return u
+	}
+
+	tests := []struct {
+		name   string
+		object *unstructured.Unstructured
+		want   status.Status
+	}{
+		{name: "no status", object: newDNSEndpoint(nil), want: status.InProgressStatus},
+		{name: "generation not synced", object: newDNSEndpoint(int64(1)), want: status.InProgressStatus},
+		{name: "synced", object: newDNSEndpoint(int64(2)), want: status.CurrentStatus},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			result, err := dnsEndpointConditions(tt.object)
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(result.Status).To(Equal(tt.want))
+		})
+	}
+}
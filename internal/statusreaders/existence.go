@@ -0,0 +1,87 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package statusreaders
+
+import (
+	"context"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/cli-utils/pkg/kstatus/polling/engine"
+	"sigs.k8s.io/cli-utils/pkg/kstatus/polling/event"
+	kstatusreaders "sigs.k8s.io/cli-utils/pkg/kstatus/polling/statusreaders"
+	"sigs.k8s.io/cli-utils/pkg/kstatus/status"
+	"sigs.k8s.io/cli-utils/pkg/object"
+)
+
+type existenceStatusReader struct {
+	kinds               map[schema.GroupKind]bool
+	genericStatusReader engine.StatusReader
+}
+
+// NewExistenceStatusReader returns a StatusReader for the kinds of objects that
+// have no status, e.g. some objects served by aggregated APIs. The objects of
+// these kinds are healthy as soon as they exist.
+func NewExistenceStatusReader(mapper meta.RESTMapper, kinds []schema.GroupKind) engine.StatusReader {
+	genericStatusReader := kstatusreaders.NewGenericStatusReader(mapper, existenceConditions)
+	supported := make(map[schema.GroupKind]bool, len(kinds))
+	for _, gk := range kinds {
+		supported[gk] = true
+	}
+	return &existenceStatusReader{
+		kinds:               supported,
+		genericStatusReader: genericStatusReader,
+	}
+}
+
+// ParseGroupKinds parses the kinds given as '<Kind>.<group>', or as
+// '<Kind>' for the core group, e.g. 'PodMetrics.metrics.k8s.io'.
+func ParseGroupKinds(values []string) ([]schema.GroupKind, error) {
+	var kinds []schema.GroupKind
+	for _, value := range values {
+		gk := schema.ParseGroupKind(value)
+		if gk.Kind == "" {
+			return nil, fmt.Errorf("invalid kind '%s', must be '<Kind>.<group>'", value)
+		}
+		kinds = append(kinds, gk)
+	}
+	return kinds, nil
+}
+
+func (e *existenceStatusReader) Supports(gk schema.GroupKind) bool {
+	return e.kinds[gk]
+}
+
+func (e *existenceStatusReader) ReadStatus(ctx context.Context, reader engine.ClusterReader, resource object.ObjMetadata) (*event.ResourceStatus, error) {
+	return e.genericStatusReader.ReadStatus(ctx, reader, resource)
+}
+
+func (e *existenceStatusReader) ReadStatusForObject(ctx context.Context, reader engine.ClusterReader, resource *unstructured.Unstructured) (*event.ResourceStatus, error) {
+	return e.genericStatusReader.ReadStatusForObject(ctx, reader, resource)
+}
+
+// existenceConditions considers the object current as soon as it exists,
+// the objects not found are reported by the generic status reader.
+func existenceConditions(u *unstructured.Unstructured) (*status.Result, error) {
+	return &status.Result{
+		Status:     status.CurrentStatus,
+		Message:    fmt.Sprintf("%s exists", u.GetKind()),
+		Conditions: []status.Condition{},
+	}, nil
+}
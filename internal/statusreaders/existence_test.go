@@ -0,0 +1,56 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package statusreaders
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/cli-utils/pkg/kstatus/status"
+)
+
+func TestParseGroupKinds(t *testing.T) {
+	g := NewWithT(t)
+
+	kinds, err := ParseGroupKinds([]string{"PodMetrics.metrics.k8s.io", "ConfigMap"})
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(kinds).To(Equal([]schema.GroupKind{
+		{Group: "metrics.k8s.io", Kind: "PodMetrics"},
+		{Kind: "ConfigMap"},
+	}))
+
+	_, err = ParseGroupKinds([]string{".metrics.k8s.io"})
+	g.Expect(err).To(HaveOccurred())
+}
+
+func TestExistenceStatusReader(t *testing.T) {
+	g := NewWithT(t)
+
+	sr := NewExistenceStatusReader(nil, []schema.GroupKind{{Group: "metrics.k8s.io", Kind: "PodMetrics"}})
+	g.Expect(sr.Supports(schema.GroupKind{Group: "metrics.k8s.io", Kind: "PodMetrics"})).To(BeTrue())
+	g.Expect(sr.Supports(schema.GroupKind{Group: "apps", Kind: "Deployment"})).To(BeFalse())
+
+	result, err := existenceConditions(&unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "metrics.k8s.io/v1beta1",
+		"kind":       "PodMetrics",
+		"metadata":   map[string]interface{}{"name": "podinfo", "namespace": "test"},
+	}})
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(result.Status).To(Equal(status.CurrentStatus))
+}
@@ -0,0 +1,130 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package statusreaders
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/cli-utils/pkg/kstatus/polling/engine"
+	"sigs.k8s.io/cli-utils/pkg/kstatus/polling/event"
+	kstatusreaders "sigs.k8s.io/cli-utils/pkg/kstatus/polling/statusreaders"
+	"sigs.k8s.io/cli-utils/pkg/kstatus/status"
+	"sigs.k8s.io/cli-utils/pkg/object"
+)
+
+// RolloutGroupKind is the GroupKind of the Argo Rollouts Rollout objects.
+var RolloutGroupKind = schema.GroupKind{Group: "argoproj.io", Kind: "Rollout"}
+
+type rolloutStatusReader struct {
+	genericStatusReader engine.StatusReader
+}
+
+// NewRolloutStatusReader returns a StatusReader for the Argo Rollouts Rollout objects.
+func NewRolloutStatusReader(mapper meta.RESTMapper) engine.StatusReader {
+	genericStatusReader := kstatusreaders.NewGenericStatusReader(mapper, rolloutConditions)
+	return &rolloutStatusReader{
+		genericStatusReader: genericStatusReader,
+	}
+}
+
+func (r *rolloutStatusReader) Supports(gk schema.GroupKind) bool {
+	return gk == RolloutGroupKind
+}
+
+func (r *rolloutStatusReader) ReadStatus(ctx context.Context, reader engine.ClusterReader, resource object.ObjMetadata) (*event.ResourceStatus, error) {
+	return r.genericStatusReader.ReadStatus(ctx, reader, resource)
+}
+
+func (r *rolloutStatusReader) ReadStatusForObject(ctx context.Context, reader engine.ClusterReader, resource *unstructured.Unstructured) (*event.ResourceStatus, error) {
+	return r.genericStatusReader.ReadStatusForObject(ctx, reader, resource)
+}
+
+// RolloutStatus computes the status of the given Rollout object.
+func RolloutStatus(u *unstructured.Unstructured) (*status.Result, error) {
+	return rolloutConditions(u)
+}
+
+// Ref: https://github.com/argoproj/argo-rollouts/blob/v1.2.0/pkg/apis/rollouts/v1alpha1/types.go
+// The Rollout is current when its phase is Healthy, failed when Degraded,
+// and in progress when Progressing or Paused, e.g. waiting for a promotion.
+func rolloutConditions(u *unstructured.Unstructured) (*status.Result, error) {
+	obj := u.UnstructuredContent()
+
+	// the observed generation is a string in the Rollout status
+	observed, found, err := unstructured.NestedFieldNoCopy(obj, "status", "observedGeneration")
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return rolloutInProgress("RolloutGenerationNotObserved", "Rollout status not observed"), nil
+	}
+	if observedGeneration, err := strconv.ParseInt(fmt.Sprint(observed), 10, 64); err == nil && observedGeneration != u.GetGeneration() {
+		return rolloutInProgress("RolloutGenerationNotObserved",
+			fmt.Sprintf("Rollout generation is %d, but latest observed generation is %d", u.GetGeneration(), observedGeneration)), nil
+	}
+
+	phase := status.GetStringField(obj, ".status.phase", "")
+	message := status.GetStringField(obj, ".status.message", "")
+
+	switch phase {
+	case "Healthy":
+		return &status.Result{
+			Status:     status.CurrentStatus,
+			Message:    "Rollout is healthy",
+			Conditions: []status.Condition{},
+		}, nil
+	case "Degraded":
+		message = fmt.Sprintf("Rollout is degraded: %s", message)
+		return &status.Result{
+			Status:  status.FailedStatus,
+			Message: message,
+			Conditions: []status.Condition{
+				{
+					Type:    status.ConditionStalled,
+					Status:  corev1.ConditionTrue,
+					Reason:  "RolloutDegraded",
+					Message: message,
+				},
+			},
+		}, nil
+	case "Paused":
+		return rolloutInProgress("RolloutPaused", fmt.Sprintf("Rollout is paused: %s", message)), nil
+	default:
+		return rolloutInProgress("RolloutProgressing", fmt.Sprintf("Rollout is progressing: %s", message)), nil
+	}
+}
+
+func rolloutInProgress(reason, message string) *status.Result {
+	return &status.Result{
+		Status:  status.InProgressStatus,
+		Message: message,
+		Conditions: []status.Condition{
+			{
+				Type:    status.ConditionReconciling,
+				Status:  corev1.ConditionTrue,
+				Reason:  reason,
+				Message: message,
+			},
+		},
+	}
+}
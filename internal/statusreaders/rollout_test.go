@@ -0,0 +1,66 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package statusreaders
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/cli-utils/pkg/kstatus/status"
+)
+
+func Test_rolloutConditions(t *testing.T) {
+	newRollout := func(observedGeneration interface{}, phase string) *unstructured.Unstructured {
+		u := &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "argoproj.io/v1alpha1",
+			"kind":       "Rollout",
+			"metadata":   map[string]interface{}{"name": "podinfo", "namespace": "test", "generation": int64(2)},
+		}}
+		if observedGeneration != nil {
+			u.Object["status"] = map[string]interface{}{
+				"observedGeneration": observedGeneration,
+				"phase":              phase,
+				"message":            "test",
+			}
+		}
+		return u
+	}
+
+	tests := []struct {
+		name   string
+		object *unstructured.Unstructured
+		want   status.Status
+	}{
+		{name: "no status", object: newRollout(nil, ""), want: status.InProgressStatus},
+		{name: "generation not observed", object: newRollout("1", "Healthy"), want: status.InProgressStatus},
+		{name: "healthy", object: newRollout("2", "Healthy"), want: status.CurrentStatus},
+		{name: "healthy with integer generation", object: newRollout(int64(2), "Healthy"), want: status.CurrentStatus},
+		{name: "progressing", object: newRollout("2", "Progressing"), want: status.InProgressStatus},
+		{name: "paused", object: newRollout("2", "Paused"), want: status.InProgressStatus},
+		{name: "degraded", object: newRollout("2", "Degraded"), want: status.FailedStatus},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			result, err := rolloutConditions(tt.object)
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(result.Status).To(Equal(tt.want))
+		})
+	}
+}
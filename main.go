@@ -17,15 +17,20 @@ limitations under the License.
 package main
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
 	flag "github.com/spf13/pflag"
+	"k8s.io/apimachinery/pkg/api/resource"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	_ "k8s.io/client-go/plugin/pkg/client/auth/azure"
 	_ "k8s.io/client-go/plugin/pkg/client/auth/gcp"
+	kuberecorder "k8s.io/client-go/tools/record"
 	"sigs.k8s.io/cli-utils/pkg/kstatus/polling"
 	"sigs.k8s.io/cli-utils/pkg/kstatus/polling/engine"
 	ctrl "sigs.k8s.io/controller-runtime"
@@ -44,6 +49,7 @@ import (
 
 	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta2"
 	"github.com/fluxcd/kustomize-controller/controllers"
+	"github.com/fluxcd/kustomize-controller/internal/eventsinks"
 	"github.com/fluxcd/kustomize-controller/internal/statusreaders"
 	// +kubebuilder:scaffold:imports
 )
@@ -64,35 +70,158 @@ func init() {
 }
 
 func main() {
+	// the controller starts itself with this command to run kustomize build in a sandbox
+	if len(os.Args) > 1 && os.Args[1] == controllers.SandboxBuildCommand {
+		os.Exit(controllers.RunSandboxBuild(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == controllers.VerifyCommand {
+		os.Exit(controllers.RunVerify(os.Args[2:]))
+	}
+
 	var (
-		metricsAddr           string
-		eventsAddr            string
-		healthAddr            string
-		concurrent            int
-		requeueDependency     time.Duration
-		clientOptions         client.Options
-		kubeConfigOpts        client.KubeConfigOptions
-		logOptions            logger.Options
-		leaderElectionOptions leaderelection.Options
-		rateLimiterOptions    helper.RateLimiterOptions
-		aclOptions            acl.Options
-		watchAllNamespaces    bool
-		noRemoteBases         bool
-		httpRetry             int
-		defaultServiceAccount string
+		metricsAddr            string
+		eventsAddr             string
+		healthAddr             string
+		concurrent             int
+		requeueDependency      time.Duration
+		clientOptions          client.Options
+		kubeConfigOpts         client.KubeConfigOptions
+		logOptions             logger.Options
+		leaderElectionOptions  leaderelection.Options
+		rateLimiterOptions     helper.RateLimiterOptions
+		aclOptions             acl.Options
+		watchAllNamespaces     bool
+		noRemoteBases          bool
+		enableHelm             bool
+		enableExternalSecrets  bool
+		enableDependencyGraph  bool
+		enablePruneReport      bool
+		endpointCheckHosts     []string
+		pruneHookHosts         []string
+		helmCommand            string
+		httpRetry              int
+		artifactMirrors        map[string]string
+		defaultServiceAccount  string
+		pruneNamespaceDenyList []string
+		allowedObjectPolicies  []string
+		requireImageDigests    bool
+		sandboxBuild           bool
+		objectQuota            controllers.ObjectQuota
+		outputLimits           controllers.OutputLimits
+		outputMaxBytes         string
+		outputMaxBytesPerNs    map[string]string
+		shards                 int
+		startupStrategy        string
+		startupStagger         time.Duration
+		persistBackoff         bool
+		metricsCardinality     controllers.MetricsCardinalityOptions
+		waitForCanaryAnalysis  bool
+		readyOnExistenceKinds  []string
+		sourceStaleThreshold   time.Duration
+		concurrentPerSource    int
+		concurrentPerCluster   int
+		buildCacheSize         int
+		memoryBudget           string
+		eventSinks             []string
+		eventSinksFile         string
+		defaultSubstitute      map[string]string
+		defaultSubstituteFile  string
 	)
 
 	flag.StringVar(&metricsAddr, "metrics-addr", ":8080", "The address the metric endpoint binds to.")
 	flag.StringVar(&eventsAddr, "events-addr", "", "The address of the events receiver.")
+	flag.StringArrayVar(&eventSinks, "event-sink", nil,
+		"An additional sink the events are delivered to, formatted as '<type>=<url>' where type is one of: cloudevents, kafka-rest, nats. "+
+			"The URL can't contain credentials, the sinks with credentials are configured with --event-sinks-file. Can be repeated.")
+	flag.StringVar(&eventSinksFile, "event-sinks-file", "",
+		"The path to a file with additional sinks the events are delivered to, one '<type>=<url>' per line, e.g. mounted from a Secret. "+
+			"The URLs can contain credentials.")
 	flag.StringVar(&healthAddr, "health-addr", ":9440", "The address the health endpoint binds to.")
 	flag.IntVar(&concurrent, "concurrent", 4, "The number of concurrent kustomize reconciles.")
-	flag.DurationVar(&requeueDependency, "requeue-dependency", 30*time.Second, "The interval at which failing dependencies are reevaluated.")
+	flag.IntVar(&concurrentPerSource, "concurrent-per-source", 0,
+		"The maximum number of Kustomizations that reconcile the same source at once, zero means no limit.")
+	flag.IntVar(&concurrentPerCluster, "concurrent-per-cluster", 0,
+		"The maximum number of Kustomizations that reconcile the same remote cluster at once, zero means no limit.")
+	flag.DurationVar(&requeueDependency, "requeue-dependency", 30*time.Second, "The interval at which the Kustomizations are requeued when the source artifact is not found.")
 	flag.BoolVar(&watchAllNamespaces, "watch-all-namespaces", true,
-		"Watch for custom resources in all namespaces, if set to false it will only watch the runtime namespace.")
+		"Watch for custom resources in all namespaces, if set to false it will only watch the runtime namespace and use a controller identity scoped to that namespace.")
 	flag.BoolVar(&noRemoteBases, "no-remote-bases", false,
 		"Disallow remote bases usage in Kustomize overlays. When this flag is enabled, all resources must refer to local files included in the source artifact.")
+	flag.BoolVar(&enableHelm, "enable-helm", false,
+		"Allow the Kustomizations with spec.buildOptions.enableHelm to inflate the helmCharts of their overlays with the HelmChartInflationGenerator.")
+	flag.StringVar(&helmCommand, "helm-command", "helm", "The helm binary used by the HelmChartInflationGenerator.")
+	flag.BoolVar(&enableExternalSecrets, "enable-external-secrets", false,
+		"Allow the Kustomizations to substitute the values of the AWS Secrets Manager, GCP Secret Manager and Azure Key Vault secrets, read with the workload identity of the controller.")
+	flag.BoolVar(&enableDependencyGraph, "enable-dependency-graph-endpoint", false,
+		"Serve the dependsOn graph of the Kustomizations of all the namespaces at the /dependencies path of the metrics address, which is not authenticated.")
+	flag.BoolVar(&enablePruneReport, "enable-prune-report-metrics", false,
+		"Export the number of objects that garbage collection would delete for the Kustomizations with prune disabled, at the cost of a query per object removed from the source at each reconciliation.")
+	flag.StringSliceVar(&endpointCheckHosts, "endpoint-health-check-hosts", nil,
+		"The hosts the endpoint health checks of the Kustomizations are allowed to probe from the controller pod, e.g. 'podinfo.example.com,*.svc.cluster.local', "+
+			"'*' allows any host. Empty disables the endpoint health checks.")
+	flag.StringSliceVar(&pruneHookHosts, "prune-hook-hosts", nil,
+		"The hosts the HTTP prune hooks of the Kustomizations are allowed to post to from the controller pod, e.g. 'dns.example.com,*.svc.cluster.local', "+
+			"'*' allows any host. Empty disables the HTTP prune hooks.")
 	flag.IntVar(&httpRetry, "http-retry", 9, "The maximum number of retries when failing to fetch artifacts over HTTP.")
+	flag.StringToStringVar(&artifactMirrors, "artifact-mirror", nil,
+		"The mirrors the artifacts are fetched from, given as '<source host>=<mirror URL>', e.g. "+
+			"'source-controller.flux-system.svc.cluster.local.=https://mirror.internal/flux', the host '*' matches any other host.")
 	flag.StringVar(&defaultServiceAccount, "default-service-account", "", "Default service account used for impersonation.")
+	flag.StringSliceVar(&pruneNamespaceDenyList, "prune-namespace-deny-list",
+		[]string{"default", "kube-system", "kube-public", "kube-node-lease"},
+		"The list of namespaces that are never deleted when garbage collecting empty namespaces.")
+	flag.StringSliceVar(&allowedObjectPolicies, "allowed-object-policies",
+		[]string{kustomizev1.SkipObjectPolicy, kustomizev1.DryRunObjectPolicy, kustomizev1.ForceObjectPolicy, kustomizev1.PruneDisabledObjectPolicy},
+		"The list of reconcile policies that objects are allowed to set with labels or annotations.")
+	flag.StringToStringVar(&defaultSubstitute, "default-substitute", nil,
+		"The variables available to the post-build substitution of all the Kustomizations, e.g. 'cluster_name=prod,cluster_region=eu-west-1', "+
+			"the variables of a Kustomization take precedence.")
+	flag.StringVar(&defaultSubstituteFile, "default-substitute-file", "",
+		"The path to a YAML file with a map of the variables available to the post-build substitution of all the Kustomizations, "+
+			"overridden by --default-substitute.")
+	flag.BoolVar(&requireImageDigests, "require-image-digests", false,
+		"When enabled, the Kustomizations that reference container images by tag instead of digest fail to reconcile.")
+	flag.IntVar(&buildCacheSize, "build-cache-size", 0,
+		"The maximum number of build results kept in memory, so that the Kustomizations are not built again when their inputs haven't changed, zero disables the cache.")
+	flag.StringVar(&memoryBudget, "memory-budget", "",
+		"The approximate memory the in-flight reconciliations can use, e.g. '1Gi', the reconciliations that would exceed it are deferred. Empty means no limit.")
+	flag.BoolVar(&sandboxBuild, "sandbox-build", false,
+		"When enabled, kustomize build runs in a child process that can only read the source files and can't access the network, remote bases are not supported. Requires Linux with Landlock and a binary built with CGO_ENABLED=0.")
+	flag.IntVar(&objectQuota.MaxObjects, "quota-max-objects", 0,
+		"The maximum number of objects a Kustomization can manage, zero means no limit.")
+	flag.StringToIntVar(&objectQuota.MaxObjectsPerKind, "quota-max-objects-per-kind", nil,
+		"The maximum number of objects of a kind a Kustomization can manage, e.g. 'ConfigMap=100,Secret=50', zero means no limit.")
+	flag.IntVar(&objectQuota.MaxNamespaceObjects, "quota-max-namespace-objects", 0,
+		"The maximum number of objects all the Kustomizations in a namespace can manage, zero means no limit.")
+	flag.StringVar(&outputMaxBytes, "output-max-bytes", "",
+		"The maximum size of the build output of a Kustomization, e.g. '10Mi'. Empty means no limit.")
+	flag.IntVar(&outputLimits.MaxObjects, "output-max-objects", 0,
+		"The maximum number of objects the build of a Kustomization can render, zero means no limit.")
+	flag.StringToStringVar(&outputMaxBytesPerNs, "output-max-bytes-per-namespace", nil,
+		"The maximum size of the build output of the Kustomizations in a namespace, e.g. 'team-a=5Mi,team-b=20Mi', overrides --output-max-bytes.")
+	flag.StringToIntVar(&outputLimits.MaxObjectsPerNamespace, "output-max-objects-per-namespace", nil,
+		"The maximum number of objects the builds of the Kustomizations in a namespace can render, e.g. 'team-a=500', overrides --output-max-objects.")
+	flag.IntVar(&shards, "shards", 0,
+		"The number of shards the Kustomizations are distributed in among the controller replicas, each shard has its own lease. "+
+			"Zero disables sharding, when enabled the Kustomizations are reconciled by all the replicas while the other kinds are reconciled by the leader.")
+	flag.StringVar(&startupStrategy, "startup-reconcile-strategy", controllers.StartupReconcileAll,
+		"The order in which the Kustomizations are reconciled after the controller starts, "+
+			"'all' reconciles them at once, 'ordered' reconciles them in the order of their dependencies at the startup stagger interval.")
+	flag.DurationVar(&startupStagger, "startup-reconcile-stagger", time.Second,
+		"The interval between the starts of the Kustomizations reconciliations after the controller starts, used by the 'ordered' strategy.")
+	flag.BoolVar(&persistBackoff, "persist-backoff", false,
+		"When enabled, the next retry of the failing Kustomizations is persisted in a ConfigMap in the runtime namespace, "+
+			"so that the failing Kustomizations are not reconciled all at once after a restart.")
+	flag.StringSliceVar(&metricsCardinality.ObjectLabels, "metrics-object-labels", []string{"name", "namespace"},
+		"The per-object labels of the Kustomization metrics, the series of the objects are summed when a label is dropped. Supported values: name, namespace.")
+	flag.IntVar(&metricsCardinality.MaxObjectsPerNamespace, "metrics-max-objects-per-namespace", 0,
+		"The maximum number of Kustomizations per namespace with their own metrics series, the others are summed in a series without name label, zero means no limit.")
+	flag.BoolVar(&waitForCanaryAnalysis, "wait-for-canary-analysis", false,
+		"When enabled, the health checks of the Flagger Canary objects wait for the canary analysis to finish.")
+	flag.StringSliceVar(&readyOnExistenceKinds, "ready-on-existence-kinds", nil,
+		"The kinds of objects without status that are healthy as soon as they exist, given as '<Kind>.<group>', e.g. 'PodMetrics.metrics.k8s.io'.")
+	flag.DurationVar(&sourceStaleThreshold, "source-stale-threshold", 0,
+		"The duration after which a source that fails to produce a new artifact is flagged as stale on the Kustomizations, zero disables the detection.")
 	clientOptions.BindFlags(flag.CommandLine)
 	logOptions.BindFlags(flag.CommandLine)
 	leaderElectionOptions.BindFlags(flag.CommandLine)
@@ -103,12 +232,49 @@ func main() {
 
 	ctrl.SetLogger(logger.NewLogger(logOptions))
 
-	metricsRecorder := metrics.NewRecorder()
-	crtlmetrics.Registry.MustRegister(metricsRecorder.Collectors()...)
-
+	// in namespace-scoped mode, every instance has its own leader election ID, leases
+	// and metrics labels so that multiple controllers can run side by side on the same
+	// cluster, while the field manager stays the same as in the cluster-wide mode
 	watchNamespace := ""
+	instanceName := controllerName
+	var metricsRegisterer prometheus.Registerer = crtlmetrics.Registry
 	if !watchAllNamespaces {
 		watchNamespace = os.Getenv("RUNTIME_NAMESPACE")
+		if watchNamespace != "" {
+			instanceName = fmt.Sprintf("%s-%s", controllerName, watchNamespace)
+			metricsRegisterer = prometheus.WrapRegistererWith(prometheus.Labels{"watch_namespace": watchNamespace}, crtlmetrics.Registry)
+		}
+	}
+
+	var metricsRecorder controllers.ObjectMetricsRecorder
+	if metricsCardinality.Limited() {
+		limitedRecorder, err := controllers.NewLimitedMetricsRecorder(metricsCardinality)
+		if err != nil {
+			setupLog.Error(err, "unable to create the metrics recorder")
+			os.Exit(1)
+		}
+		metricsRegisterer.MustRegister(limitedRecorder.Collectors()...)
+		metricsRecorder = limitedRecorder
+	} else {
+		toolkitRecorder := metrics.NewRecorder()
+		metricsRegisterer.MustRegister(toolkitRecorder.Collectors()...)
+		metricsRecorder = toolkitRecorder
+	}
+	dependentsRecorder := controllers.NewDependentsRecorder()
+	metricsRegisterer.MustRegister(dependentsRecorder.Collectors()...)
+	var pruneReportRecorder *controllers.PruneReportRecorder
+	if enablePruneReport {
+		pruneReportRecorder = controllers.NewPruneReportRecorder()
+		metricsRegisterer.MustRegister(pruneReportRecorder.Collectors()...)
+	}
+	clusterMetricsRecorder := controllers.NewClusterMetricsRecorder()
+	metricsRegisterer.MustRegister(clusterMetricsRecorder.Collectors()...)
+
+	if sandboxBuild {
+		if err := controllers.CheckSandbox(); err != nil {
+			setupLog.Error(err, "unable to enable the sandboxed builds")
+			os.Exit(1)
+		}
 	}
 
 	restConfig := client.GetConfigOrDie(clientOptions)
@@ -122,7 +288,7 @@ func main() {
 		LeaseDuration:                 &leaderElectionOptions.LeaseDuration,
 		RenewDeadline:                 &leaderElectionOptions.RenewDeadline,
 		RetryPeriod:                   &leaderElectionOptions.RetryPeriod,
-		LeaderElectionID:              fmt.Sprintf("%s-leader-election", controllerName),
+		LeaderElectionID:              fmt.Sprintf("%s-leader-election", instanceName),
 		Namespace:                     watchNamespace,
 		Logger:                        ctrl.Log,
 	})
@@ -134,37 +300,268 @@ func main() {
 	probes.SetupChecks(mgr, setupLog)
 	pprof.SetupHandlers(mgr, setupLog)
 
+	if enableDependencyGraph {
+		if err := mgr.AddMetricsExtraHandler(controllers.DependencyGraphPath, controllers.DependencyGraphHandler(mgr.GetClient())); err != nil {
+			setupLog.Error(err, "unable to register the dependency graph handler")
+			os.Exit(1)
+		}
+	}
+
+	startupScheduler, err := controllers.NewStartupScheduler(startupStrategy, startupStagger)
+	if err != nil {
+		setupLog.Error(err, "unable to create the startup scheduler")
+		os.Exit(1)
+	}
+
+	var buildCache *controllers.BuildCache
+	if buildCacheSize > 0 {
+		buildCache = controllers.NewBuildCache(buildCacheSize)
+	}
+
+	if outputMaxBytes != "" {
+		limit, err := resource.ParseQuantity(outputMaxBytes)
+		if err != nil {
+			setupLog.Error(err, "invalid output size limit")
+			os.Exit(1)
+		}
+		outputLimits.MaxBytes = limit.Value()
+	}
+	if outputLimits.MaxBytesPerNamespace, err = controllers.ParseOutputByteLimits(outputMaxBytesPerNs); err != nil {
+		setupLog.Error(err, "invalid output size limit")
+		os.Exit(1)
+	}
+
+	mirrors, err := controllers.ParseArtifactMirrors(artifactMirrors)
+	if err != nil {
+		setupLog.Error(err, "invalid artifact mirrors")
+		os.Exit(1)
+	}
+
+	defaultSubstitutions, err := controllers.LoadDefaultSubstitutions(defaultSubstituteFile, defaultSubstitute)
+	if err != nil {
+		setupLog.Error(err, "invalid default substitutions")
+		os.Exit(1)
+	}
+
+	var externalSecrets controllers.ExternalSecretResolver
+	if enableExternalSecrets {
+		externalSecrets = controllers.NewCloudSecretResolver()
+	}
+
+	var endpointProber *controllers.EndpointProber
+	if len(endpointCheckHosts) > 0 {
+		endpointProber = controllers.NewEndpointProber(endpointCheckHosts)
+	}
+
+	var pruneHookClient *controllers.PruneHookClient
+	if len(pruneHookHosts) > 0 {
+		pruneHookClient = controllers.NewPruneHookClient(pruneHookHosts)
+	}
+
+	var memoryBudgetManager *controllers.MemoryBudget
+	if memoryBudget != "" {
+		limit, err := resource.ParseQuantity(memoryBudget)
+		if err != nil {
+			setupLog.Error(err, "unable to parse the memory budget")
+			os.Exit(1)
+		}
+		memoryBudgetManager = controllers.NewMemoryBudget(limit.Value())
+	}
+
+	var shardManager *controllers.ShardManager
+	if shards > 0 {
+		identity, err := os.Hostname()
+		if err != nil {
+			setupLog.Error(err, "unable to determine the replica identity")
+			os.Exit(1)
+		}
+		leaseNamespace := os.Getenv("RUNTIME_NAMESPACE")
+		if leaseNamespace == "" {
+			setupLog.Error(fmt.Errorf("RUNTIME_NAMESPACE is not set"), "unable to enable sharding")
+			os.Exit(1)
+		}
+		shardManager = controllers.NewShardManager(mgr.GetClient(), mgr.GetAPIReader(), controllers.ShardOptions{
+			Shards:         shards,
+			ControllerName: instanceName,
+			Namespace:      leaseNamespace,
+			Identity:       identity,
+			LeaseDuration:  leaderElectionOptions.LeaseDuration,
+			RenewDeadline:  leaderElectionOptions.RenewDeadline,
+			RetryPeriod:    leaderElectionOptions.RetryPeriod,
+		})
+		if err := mgr.Add(shardManager); err != nil {
+			setupLog.Error(err, "unable to add the shard manager")
+			os.Exit(1)
+		}
+	}
+
+	controllerMetrics := controllers.NewControllerMetricsRecorder(shards, buildCache)
+	metricsRegisterer.MustRegister(controllerMetrics.Collectors()...)
+
+	var backoffStore *controllers.BackoffStore
+	if persistBackoff {
+		runtimeNamespace := os.Getenv("RUNTIME_NAMESPACE")
+		if runtimeNamespace == "" {
+			setupLog.Error(fmt.Errorf("RUNTIME_NAMESPACE is not set"), "unable to persist the backoff")
+			os.Exit(1)
+		}
+		backoffStore = controllers.NewBackoffStore(mgr.GetClient(), mgr.GetAPIReader(),
+			types.NamespacedName{Namespace: runtimeNamespace, Name: fmt.Sprintf("%s-backoff", instanceName)}, 10*time.Second)
+		loadCtx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		err := backoffStore.Load(loadCtx)
+		cancel()
+		if err != nil {
+			setupLog.Error(err, "unable to load the backoff state")
+			os.Exit(1)
+		}
+		if err := mgr.Add(backoffStore); err != nil {
+			setupLog.Error(err, "unable to add the backoff store")
+			os.Exit(1)
+		}
+	}
+
 	var eventRecorder *events.Recorder
 	if eventRecorder, err = events.NewRecorder(mgr, ctrl.Log, eventsAddr, controllerName); err != nil {
 		setupLog.Error(err, "unable to create event recorder")
 		os.Exit(1)
 	}
 
+	var kustomizationEventRecorder kuberecorder.EventRecorder = eventRecorder
+	var sinks []eventsinks.Sink
+	for _, spec := range eventSinks {
+		sink, err := eventsinks.ParseSink(spec)
+		if err != nil {
+			setupLog.Error(err, "unable to configure event sink")
+			os.Exit(1)
+		}
+		sinks = append(sinks, sink)
+	}
+	if eventSinksFile != "" {
+		fileSinks, err := eventsinks.ParseSinksFile(eventSinksFile)
+		if err != nil {
+			setupLog.Error(err, "unable to configure the event sinks")
+			os.Exit(1)
+		}
+		sinks = append(sinks, fileSinks...)
+	}
+	if len(sinks) > 0 {
+		sinkRecorder := eventsinks.NewRecorder(eventRecorder, mgr.GetScheme(), controllerName, sinks)
+		metricsRegisterer.MustRegister(sinkRecorder.Collectors()...)
+		if err := mgr.Add(sinkRecorder); err != nil {
+			setupLog.Error(err, "unable to add the event sinks")
+			os.Exit(1)
+		}
+		kustomizationEventRecorder = sinkRecorder
+	}
+
+	existenceKinds, err := statusreaders.ParseGroupKinds(readyOnExistenceKinds)
+	if err != nil {
+		setupLog.Error(err, "invalid ready on existence kinds")
+		os.Exit(1)
+	}
+	existenceStatusReader := statusreaders.NewExistenceStatusReader(mgr.GetRESTMapper(), existenceKinds)
 	jobStatusReader := statusreaders.NewCustomJobStatusReader(mgr.GetRESTMapper())
+	canaryStatusReader := statusreaders.NewCanaryStatusReader(mgr.GetRESTMapper(), waitForCanaryAnalysis)
+	rolloutStatusReader := statusreaders.NewRolloutStatusReader(mgr.GetRESTMapper())
+	certificateStatusReader := statusreaders.NewCertificateStatusReader(mgr.GetRESTMapper())
+	dnsEndpointStatusReader := statusreaders.NewDNSEndpointStatusReader(mgr.GetRESTMapper())
 	pollingOpts := polling.Options{
-		CustomStatusReaders: []engine.StatusReader{jobStatusReader},
-	}
-	if err = (&controllers.KustomizationReconciler{
-		ControllerName:        controllerName,
-		DefaultServiceAccount: defaultServiceAccount,
-		Client:                mgr.GetClient(),
-		Scheme:                mgr.GetScheme(),
-		EventRecorder:         eventRecorder,
-		MetricsRecorder:       metricsRecorder,
-		NoCrossNamespaceRefs:  aclOptions.NoCrossNamespaceRefs,
-		NoRemoteBases:         noRemoteBases,
-		KubeConfigOpts:        kubeConfigOpts,
-		PollingOpts:           pollingOpts,
-		StatusPoller:          polling.NewStatusPoller(mgr.GetClient(), mgr.GetRESTMapper(), pollingOpts),
-	}).SetupWithManager(mgr, controllers.KustomizationReconcilerOptions{
+		CustomStatusReaders: []engine.StatusReader{
+			statusreaders.NewAnnotationStatusReader(mgr.GetRESTMapper(),
+				existenceStatusReader,
+				jobStatusReader,
+				canaryStatusReader,
+				rolloutStatusReader,
+				certificateStatusReader,
+				dnsEndpointStatusReader,
+			),
+		},
+	}
+	kustomizationReconciler := &controllers.KustomizationReconciler{
+		ControllerName:         controllerName,
+		DefaultServiceAccount:  defaultServiceAccount,
+		PruneNamespaceDenyList: pruneNamespaceDenyList,
+		AllowedObjectPolicies:  allowedObjectPolicies,
+		RequireImageDigests:    requireImageDigests,
+		SandboxBuild:           sandboxBuild,
+		ObjectQuota:            objectQuota,
+		OutputLimits:           outputLimits,
+		DefaultSubstitutions:   defaultSubstitutions,
+		ExternalSecrets:        externalSecrets,
+		EndpointProber:         endpointProber,
+		PruneHookClient:        pruneHookClient,
+		Shards:                 shardManager,
+		StartupScheduler:       startupScheduler,
+		BackoffStore:           backoffStore,
+		SourceStaleThreshold:   sourceStaleThreshold,
+		SourceLimiter:          controllers.NewSourceLimiter(concurrentPerSource),
+		ClusterLimiter:         controllers.NewClusterLimiter(concurrentPerCluster),
+		BuildCache:             buildCache,
+		MemoryBudget:           memoryBudgetManager,
+		Client:                 mgr.GetClient(),
+		Scheme:                 mgr.GetScheme(),
+		EventRecorder:          kustomizationEventRecorder,
+		MetricsRecorder:        metricsRecorder,
+		DependentsRecorder:     dependentsRecorder,
+		PruneReportRecorder:    pruneReportRecorder,
+		ClusterMetricsRecorder: clusterMetricsRecorder,
+		ControllerMetrics:      controllerMetrics,
+		NoCrossNamespaceRefs:   aclOptions.NoCrossNamespaceRefs,
+		NoRemoteBases:          noRemoteBases,
+		EnableHelm:             enableHelm,
+		HelmCommand:            helmCommand,
+		KubeConfigOpts:         kubeConfigOpts,
+		PollingOpts:            pollingOpts,
+		StatusPoller:           polling.NewStatusPoller(mgr.GetClient(), mgr.GetRESTMapper(), pollingOpts),
+	}
+	// the sharded Kustomization controller runs on all the replicas,
+	// the other controllers run on the leader only
+	kustomizationMgr := mgr
+	if shards > 0 {
+		kustomizationMgr = controllers.WithoutLeaderElection(mgr)
+	}
+	if err = kustomizationReconciler.SetupWithManager(kustomizationMgr, controllers.KustomizationReconcilerOptions{
 		MaxConcurrentReconciles:   concurrent,
 		DependencyRequeueInterval: requeueDependency,
 		HTTPRetry:                 httpRetry,
+		ArtifactMirrors:           mirrors,
 		RateLimiter:               helper.GetRateLimiter(rateLimiterOptions),
 	}); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", controllerName)
 		os.Exit(1)
 	}
+	if err = (&controllers.KustomizationGroupReconciler{
+		ControllerName:       controllerName,
+		NoCrossNamespaceRefs: aclOptions.NoCrossNamespaceRefs,
+		Client:               mgr.GetClient(),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", kustomizev1.KustomizationGroupKind)
+		os.Exit(1)
+	}
+	if err = (&controllers.PipelineReconciler{
+		ControllerName:       controllerName,
+		NoCrossNamespaceRefs: aclOptions.NoCrossNamespaceRefs,
+		Client:               mgr.GetClient(),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", kustomizev1.PipelineKind)
+		os.Exit(1)
+	}
+	if err = (&controllers.KustomizationSetReconciler{
+		ControllerName: controllerName,
+		Client:         mgr.GetClient(),
+		Scheme:         mgr.GetScheme(),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", kustomizev1.KustomizationSetKind)
+		os.Exit(1)
+	}
+	if err = (&controllers.TenantReconciler{
+		ControllerName: controllerName,
+		Client:         mgr.GetClient(),
+		Scheme:         mgr.GetScheme(),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", kustomizev1.TenantKind)
+		os.Exit(1)
+	}
 	// +kubebuilder:scaffold:builder
 
 	setupLog.Info("starting manager")
@@ -0,0 +1,117 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package diff computes the changes that the server-side apply of a set of
+// Kubernetes objects would make to a cluster, the same way the controller
+// applies them, so that clients can preview a reconciliation.
+package diff
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/fluxcd/pkg/ssa"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/cli-utils/pkg/object"
+
+	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta2"
+)
+
+// Entry contains the change that the server-side apply would make to a
+// Kubernetes resource object.
+type Entry struct {
+	// ID is the string representation of the object's metadata,
+	// in the format '<namespace>_<name>_<group>_<kind>'.
+	ID string `json:"id"`
+
+	// Action is the server-side apply action, one of 'created',
+	// 'configured' or 'unchanged'.
+	Action string `json:"action"`
+
+	// Live is the object in the cluster, set for the configured objects.
+	// The data of Secrets is masked.
+	Live *unstructured.Unstructured `json:"live,omitempty"`
+
+	// Merged is the result of the server-side dry-run, set for the
+	// configured objects. The data of Secrets is masked.
+	Merged *unstructured.Unstructured `json:"merged,omitempty"`
+}
+
+// Options holds the options of the diff.
+type Options struct {
+	// Exclusions are the labels and annotations of the objects in the
+	// cluster that the apply leaves untouched, reported as unchanged.
+	Exclusions map[string]string
+
+	// Skip returns true for the objects left out of the diff.
+	Skip func(obj *unstructured.Unstructured) bool
+}
+
+// DefaultOptions returns the options used by the controller, which leaves
+// untouched the objects with reconciliation disabled.
+func DefaultOptions() Options {
+	return Options{
+		Exclusions: map[string]string{
+			fmt.Sprintf("%s/reconcile", kustomizev1.GroupVersion.Group): kustomizev1.DisabledValue,
+		},
+	}
+}
+
+// Objects performs a server-side dry-run of the given objects and returns
+// their changes, in the order of the objects. The objects whose definition
+// or namespace is part of the same set can't be validated before the apply,
+// they are reported as created.
+func Objects(ctx context.Context, manager *ssa.ResourceManager, objects []*unstructured.Unstructured,
+	opts Options) ([]Entry, error) {
+	entries := make([]Entry, 0, len(objects))
+	for _, u := range objects {
+		if opts.Skip != nil && opts.Skip(u) {
+			continue
+		}
+
+		obj := u.DeepCopy()
+		if err := ssa.SetNativeKindsDefaults([]*unstructured.Unstructured{obj}); err != nil {
+			return nil, err
+		}
+		entry := Entry{ID: object.UnstructuredToObjMetadata(u).String()}
+		change, live, merged, err := manager.Diff(ctx, obj, ssa.DiffOptions{Exclusions: opts.Exclusions})
+		if err != nil {
+			if !missingKindOrNamespace(err) {
+				return nil, err
+			}
+			entry.Action = string(ssa.CreatedAction)
+			entries = append(entries, entry)
+			continue
+		}
+
+		entry.Action = change.Action
+		if change.Action == string(ssa.ConfiguredAction) {
+			entry.Live, entry.Merged = live, merged
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// missingKindOrNamespace returns true if the dry-run failed because the
+// object's kind is not registered or its namespace does not exist.
+func missingKindOrNamespace(err error) bool {
+	var noKindMatch *apimeta.NoKindMatchError
+	return errors.As(err, &noKindMatch) || apierrors.IsNotFound(err)
+}
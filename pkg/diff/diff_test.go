@@ -0,0 +1,56 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package diff
+
+import (
+	"fmt"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestDefaultOptions(t *testing.T) {
+	g := NewWithT(t)
+
+	opts := DefaultOptions()
+	g.Expect(opts.Exclusions).To(HaveKeyWithValue("kustomize.toolkit.fluxcd.io/reconcile", "disabled"))
+	g.Expect(opts.Skip).To(BeNil())
+}
+
+func Test_missingKindOrNamespace(t *testing.T) {
+	gr := schema.GroupResource{Group: "", Resource: "namespaces"}
+	noKind := &apimeta.NoKindMatchError{GroupKind: schema.GroupKind{Group: "example.com", Kind: "Widget"}}
+
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "no kind match", err: fmt.Errorf("dry-run failed: %w", noKind), want: true},
+		{name: "namespace not found", err: fmt.Errorf("dry-run failed: %w", apierrors.NewNotFound(gr, "apps")), want: true},
+		{name: "invalid", err: fmt.Errorf("dry-run failed: %w", apierrors.NewBadRequest("invalid spec")), want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			g.Expect(missingKindOrNamespace(tt.err)).To(Equal(tt.want))
+		})
+	}
+}